@@ -0,0 +1,404 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+const (
+	ReconcileCreate = "create"
+	ReconcileUpdate = "update"
+	ReconcileDelete = "delete"
+	ReconcileSkip   = "skip"
+)
+
+// AccessModel declares the users, groups and ACLs that should exist on
+// the XO server. Users are identified by email and groups by name.
+type AccessModel struct {
+	Users  []AccessUser
+	Groups []AccessGroup
+	Acls   []AccessAcl
+
+	// When set, users, groups, group memberships and ACLs that are not
+	// declared in the model are deleted. Every account that should keep
+	// access to XO (including the one used by this client) must be part
+	// of the model.
+	Prune bool
+
+	// Maximum number of concurrent calls made while reconciling.
+	Concurrency int
+}
+
+type AccessUser struct {
+	Email string
+	// Only used when creating the user. Passwords of existing users
+	// are never changed.
+	Password string
+	// Left untouched when empty
+	Permission string
+}
+
+type AccessGroup struct {
+	Name string
+	// Emails of the users belonging to the group
+	Members []string
+}
+
+type AccessAcl struct {
+	// Email of a user or name of a group
+	Subject string
+	Object  string
+	Action  string
+}
+
+type ReconcileAction struct {
+	// One of create, update, delete or skip
+	Action string
+	// One of user, group, membership or acl
+	Type string
+	Key  string
+	Err  error
+}
+
+type ReconcileReport struct {
+	Actions []ReconcileAction
+}
+
+func (r ReconcileReport) Failed() []ReconcileAction {
+	failed := []ReconcileAction{}
+	for _, action := range r.Actions {
+		if action.Err != nil {
+			failed = append(failed, action)
+		}
+	}
+	return failed
+}
+
+type reconcileOp struct {
+	action ReconcileAction
+	// nil for skipped actions
+	apply func() error
+}
+
+// accessReconciler holds the ids of users and groups as they get
+// created so that later phases can reference them.
+type accessReconciler struct {
+	c       *Client
+	desired AccessModel
+
+	mu       sync.Mutex
+	userIds  map[string]string
+	groupIds map[string]string
+}
+
+// userKey is the key of a user in the reconciler, XO compares emails
+// regardless of their case.
+func userKey(email string) string {
+	return strings.ToLower(email)
+}
+
+func (r *accessReconciler) setUserId(email, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.userIds[userKey(email)] = id
+}
+
+func (r *accessReconciler) setGroupId(name, id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.groupIds[name] = id
+}
+
+func (r *accessReconciler) apply(ops []reconcileOp) []ReconcileAction {
+	actions := make([]ReconcileAction, len(ops))
+	forEachConcurrently(len(ops), r.desired.Concurrency, func(i int) {
+		action := ops[i].action
+		if ops[i].apply != nil {
+			action.Err = ops[i].apply()
+		}
+		actions[i] = action
+	})
+	return actions
+}
+
+// ReconcileAccessModel diffs the desired access model against the users,
+// groups and ACLs currently defined in XO and only performs the calls
+// needed to converge. Every action taken or skipped is listed in the
+// returned report. An error is returned alongside the report when at
+// least one of the actions failed.
+func (c *Client) ReconcileAccessModel(desired AccessModel) (*ReconcileReport, error) {
+	users, err := c.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := c.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+	acls, err := c.GetAcls()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &accessReconciler{
+		c:        c,
+		desired:  desired,
+		userIds:  map[string]string{},
+		groupIds: map[string]string{},
+	}
+	for _, user := range users {
+		r.userIds[userKey(user.Email)] = user.Id
+	}
+	for _, group := range groups {
+		r.groupIds[group.Name] = group.Id
+	}
+
+	report := &ReconcileReport{}
+	report.Actions = append(report.Actions, r.apply(r.planUsers(users))...)
+	report.Actions = append(report.Actions, r.apply(r.planGroups())...)
+	report.Actions = append(report.Actions, r.apply(r.planMemberships(groups))...)
+	report.Actions = append(report.Actions, r.apply(r.planAcls(acls))...)
+	if desired.Prune {
+		report.Actions = append(report.Actions, r.apply(r.planPrune(users, groups))...)
+	}
+
+	log.Printf("[DEBUG] Reconciled access model with actions: %+v\n", report.Actions)
+	if failed := report.Failed(); len(failed) > 0 {
+		return report, errors.New(fmt.Sprintf("%d of %d actions failed while reconciling the access model, first error: %v", len(failed), len(report.Actions), failed[0].Err))
+	}
+	return report, nil
+}
+
+func (r *accessReconciler) planUsers(existing []User) []reconcileOp {
+	byEmail := map[string]User{}
+	for _, user := range existing {
+		byEmail[userKey(user.Email)] = user
+	}
+
+	ops := []reconcileOp{}
+	for _, desired := range r.desired.Users {
+		desired := desired
+		action := ReconcileAction{Type: "user", Key: desired.Email}
+		user, ok := byEmail[userKey(desired.Email)]
+
+		switch {
+		case !ok:
+			action.Action = ReconcileCreate
+			ops = append(ops, reconcileOp{action, func() error {
+				params := map[string]interface{}{
+					"email":    desired.Email,
+					"password": desired.Password,
+				}
+				if desired.Permission != "" {
+					params["permission"] = desired.Permission
+				}
+				var id string
+				if err := r.c.Call("user.create", params, &id); err != nil {
+					return err
+				}
+				r.setUserId(desired.Email, id)
+				return nil
+			}})
+		case desired.Permission != "" && desired.Permission != user.Permission:
+			action.Action = ReconcileUpdate
+			ops = append(ops, reconcileOp{action, func() error {
+				var success bool
+				params := map[string]interface{}{
+					"id":         user.Id,
+					"permission": desired.Permission,
+				}
+				return r.c.Call("user.set", params, &success)
+			}})
+		default:
+			action.Action = ReconcileSkip
+			ops = append(ops, reconcileOp{action: action})
+		}
+	}
+	return ops
+}
+
+func (r *accessReconciler) planGroups() []reconcileOp {
+	ops := []reconcileOp{}
+	for _, desired := range r.desired.Groups {
+		name := desired.Name
+		action := ReconcileAction{Type: "group", Key: name}
+		if _, ok := r.groupIds[name]; ok {
+			action.Action = ReconcileSkip
+			ops = append(ops, reconcileOp{action: action})
+			continue
+		}
+
+		action.Action = ReconcileCreate
+		ops = append(ops, reconcileOp{action, func() error {
+			group, err := r.c.CreateGroup(name)
+			if err != nil {
+				return err
+			}
+			r.setGroupId(name, group.Id)
+			return nil
+		}})
+	}
+	return ops
+}
+
+func (r *accessReconciler) planMemberships(existing []Group) []reconcileOp {
+	membersById := map[string][]string{}
+	for _, group := range existing {
+		membersById[group.Id] = group.Users
+	}
+
+	ops := []reconcileOp{}
+	for _, desired := range r.desired.Groups {
+		groupId, ok := r.groupIds[desired.Name]
+		if !ok {
+			// The group failed to be created which is already reported
+			continue
+		}
+		current := membersById[groupId]
+
+		desiredIds := []string{}
+		for _, email := range desired.Members {
+			action := ReconcileAction{Type: "membership", Key: fmt.Sprintf("%s/%s", desired.Name, email)}
+			userId, ok := r.userIds[userKey(email)]
+			if !ok {
+				action.Action = ReconcileCreate
+				action.Err = errors.New(fmt.Sprintf("user `%s` does not exist", email))
+				ops = append(ops, reconcileOp{action: action})
+				continue
+			}
+			desiredIds = append(desiredIds, userId)
+
+			if stringInSlice(userId, current) {
+				action.Action = ReconcileSkip
+				ops = append(ops, reconcileOp{action: action})
+				continue
+			}
+
+			action.Action = ReconcileCreate
+			ops = append(ops, reconcileOp{action, func() error {
+				return r.c.AddUserToGroup(groupId, userId)
+			}})
+		}
+
+		if !r.desired.Prune {
+			continue
+		}
+		for _, userId := range current {
+			if stringInSlice(userId, desiredIds) {
+				continue
+			}
+			userId := userId
+			ops = append(ops, reconcileOp{
+				ReconcileAction{Action: ReconcileDelete, Type: "membership", Key: fmt.Sprintf("%s/%s", desired.Name, userId)},
+				func() error {
+					return r.c.RemoveUserFromGroup(groupId, userId)
+				},
+			})
+		}
+	}
+	return ops
+}
+
+func (r *accessReconciler) resolveSubject(subject string) (string, bool) {
+	if id, ok := r.userIds[userKey(subject)]; ok {
+		return id, true
+	}
+	id, ok := r.groupIds[subject]
+	return id, ok
+}
+
+func (r *accessReconciler) planAcls(existing []Acl) []reconcileOp {
+	ops := []reconcileOp{}
+	desiredAcls := []Acl{}
+	for _, desired := range r.desired.Acls {
+		action := ReconcileAction{Type: "acl", Key: fmt.Sprintf("%s %s %s", desired.Subject, desired.Object, desired.Action)}
+		subjectId, ok := r.resolveSubject(desired.Subject)
+		if !ok {
+			action.Action = ReconcileCreate
+			action.Err = errors.New(fmt.Sprintf("subject `%s` is neither a known user email nor a group name", desired.Subject))
+			ops = append(ops, reconcileOp{action: action})
+			continue
+		}
+
+		acl := Acl{Subject: subjectId, Object: desired.Object, Action: desired.Action}
+		desiredAcls = append(desiredAcls, acl)
+		if aclInSlice(acl, existing) {
+			action.Action = ReconcileSkip
+			ops = append(ops, reconcileOp{action: action})
+			continue
+		}
+
+		action.Action = ReconcileCreate
+		ops = append(ops, reconcileOp{action, func() error {
+			_, err := r.c.CreateAcl(acl)
+			return err
+		}})
+	}
+
+	if !r.desired.Prune {
+		return ops
+	}
+	for _, acl := range existing {
+		if aclInSlice(acl, desiredAcls) {
+			continue
+		}
+		acl := acl
+		ops = append(ops, reconcileOp{
+			ReconcileAction{Action: ReconcileDelete, Type: "acl", Key: fmt.Sprintf("%s %s %s", acl.Subject, acl.Object, acl.Action)},
+			func() error {
+				return r.c.DeleteAcl(acl)
+			},
+		})
+	}
+	return ops
+}
+
+func (r *accessReconciler) planPrune(users []User, groups []Group) []reconcileOp {
+	desiredGroups := []string{}
+	for _, group := range r.desired.Groups {
+		desiredGroups = append(desiredGroups, group.Name)
+	}
+	desiredUsers := []string{}
+	for _, user := range r.desired.Users {
+		desiredUsers = append(desiredUsers, userKey(user.Email))
+	}
+
+	ops := []reconcileOp{}
+	for _, group := range groups {
+		if stringInSlice(group.Name, desiredGroups) {
+			continue
+		}
+		id := group.Id
+		ops = append(ops, reconcileOp{
+			ReconcileAction{Action: ReconcileDelete, Type: "group", Key: group.Name},
+			func() error {
+				return r.c.DeleteGroup(id)
+			},
+		})
+	}
+	for _, user := range users {
+		if stringInSlice(userKey(user.Email), desiredUsers) {
+			continue
+		}
+		user := user
+		ops = append(ops, reconcileOp{
+			ReconcileAction{Action: ReconcileDelete, Type: "user", Key: user.Email},
+			func() error {
+				return r.c.DeleteUser(user)
+			},
+		})
+	}
+	return ops
+}
+
+func aclInSlice(needle Acl, haystack []Acl) bool {
+	for _, acl := range haystack {
+		if acl.Subject == needle.Subject && acl.Object == needle.Object && acl.Action == needle.Action {
+			return true
+		}
+	}
+	return false
+}
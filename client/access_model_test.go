@@ -0,0 +1,244 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAccessServer keeps users, groups and ACLs in memory and answers the
+// json rpc methods used by ReconcileAccessModel.
+type fakeAccessServer struct {
+	mu     sync.Mutex
+	nextId int
+	users  map[string]map[string]interface{}
+	groups map[string]map[string]interface{}
+	acls   []map[string]interface{}
+}
+
+func newFakeAccessServer() *fakeAccessServer {
+	return &fakeAccessServer{
+		users:  map[string]map[string]interface{}{},
+		groups: map[string]map[string]interface{}{},
+	}
+}
+
+func (s *fakeAccessServer) id() string {
+	s.nextId++
+	return fmt.Sprintf("id-%d", s.nextId)
+}
+
+func (s *fakeAccessServer) handle(method string, params map[string]interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch method {
+	case "user.getAll":
+		users := []map[string]interface{}{}
+		for _, user := range s.users {
+			users = append(users, user)
+		}
+		return users, nil
+	case "user.create":
+		id := s.id()
+		permission := params["permission"]
+		if permission == nil {
+			permission = "none"
+		}
+		s.users[id] = map[string]interface{}{"id": id, "email": params["email"], "permission": permission}
+		return id, nil
+	case "user.set":
+		s.users[params["id"].(string)]["permission"] = params["permission"]
+		return true, nil
+	case "user.delete":
+		delete(s.users, params["id"].(string))
+		return true, nil
+	case "group.getAll":
+		groups := []map[string]interface{}{}
+		for _, group := range s.groups {
+			users := append([]string{}, group["users"].([]string)...)
+			groups = append(groups, map[string]interface{}{"id": group["id"], "name": group["name"], "users": users})
+		}
+		return groups, nil
+	case "group.create":
+		id := s.id()
+		s.groups[id] = map[string]interface{}{"id": id, "name": params["name"], "users": []string{}}
+		return id, nil
+	case "group.delete":
+		delete(s.groups, params["id"].(string))
+		return true, nil
+	case "group.addUser":
+		group := s.groups[params["id"].(string)]
+		group["users"] = append(group["users"].([]string), params["userId"].(string))
+		return true, nil
+	case "group.removeUser":
+		group := s.groups[params["id"].(string)]
+		users := []string{}
+		for _, user := range group["users"].([]string) {
+			if user != params["userId"] {
+				users = append(users, user)
+			}
+		}
+		group["users"] = users
+		return true, nil
+	case "acl.get":
+		return s.acls, nil
+	case "acl.add":
+		params["id"] = s.id()
+		s.acls = append(s.acls, params)
+		return true, nil
+	case "acl.remove":
+		acls := []map[string]interface{}{}
+		for _, acl := range s.acls {
+			if acl["subject"] != params["subject"] || acl["object"] != params["object"] || acl["action"] != params["action"] {
+				acls = append(acls, acl)
+			}
+		}
+		s.acls = acls
+		return true, nil
+	}
+	return nil, fmt.Errorf("unexpected method %s", method)
+}
+
+func reconcileSummary(report *ReconcileReport) []string {
+	summary := []string{}
+	for _, action := range report.Actions {
+		summary = append(summary, fmt.Sprintf("%s %s %s", action.Action, action.Type, action.Key))
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func testAccessModel() AccessModel {
+	return AccessModel{
+		Users: []AccessUser{
+			{Email: "alice@example.com", Password: "secret", Permission: "admin"},
+			{Email: "bob@example.com", Password: "secret", Permission: "none"},
+		},
+		Groups: []AccessGroup{
+			{Name: "operators", Members: []string{"alice@example.com", "bob@example.com"}},
+		},
+		Acls: []AccessAcl{
+			{Subject: "operators", Object: "vm-1", Action: "operator"},
+			{Subject: "bob@example.com", Object: "vm-2", Action: "viewer"},
+		},
+		Concurrency: 2,
+	}
+}
+
+func TestReconcileAccessModel_isIdempotent(t *testing.T) {
+	server := newFakeAccessServer()
+	server.users["existing"] = map[string]interface{}{"id": "existing", "email": "alice@example.com", "permission": "none"}
+	server.groups["ops"] = map[string]interface{}{"id": "ops", "name": "operators", "users": []string{"existing"}}
+	c := Client{rpc: &fakeRPC{handler: server.handle}}
+
+	report, err := c.ReconcileAccessModel(testAccessModel())
+	if err != nil {
+		t.Fatalf("failed to reconcile access model with error: %v", err)
+	}
+
+	expected := []string{
+		"create acl bob@example.com vm-2 viewer",
+		"create acl operators vm-1 operator",
+		"create membership operators/bob@example.com",
+		"create user bob@example.com",
+		"skip group operators",
+		"skip membership operators/alice@example.com",
+		"update user alice@example.com",
+	}
+	if got := reconcileSummary(report); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected actions %v but received %v", expected, got)
+	}
+
+	report, err = c.ReconcileAccessModel(testAccessModel())
+	if err != nil {
+		t.Fatalf("failed to reconcile access model a second time with error: %v", err)
+	}
+	for _, action := range report.Actions {
+		if action.Action != ReconcileSkip {
+			t.Errorf("expected only skipped actions on the second run but received %+v", action)
+		}
+	}
+}
+
+func TestReconcileAccessModel_pruneRemovesUndeclaredAccess(t *testing.T) {
+	server := newFakeAccessServer()
+	server.users["existing"] = map[string]interface{}{"id": "existing", "email": "alice@example.com", "permission": "admin"}
+	server.users["stale"] = map[string]interface{}{"id": "stale", "email": "mallory@example.com", "permission": "admin"}
+	server.groups["old"] = map[string]interface{}{"id": "old", "name": "legacy", "users": []string{"stale"}}
+	server.acls = []map[string]interface{}{{"subject": "stale", "object": "vm-1", "action": "admin"}}
+	c := Client{rpc: &fakeRPC{handler: server.handle}}
+
+	model := testAccessModel()
+	model.Prune = true
+	if _, err := c.ReconcileAccessModel(model); err != nil {
+		t.Fatalf("failed to reconcile access model with error: %v", err)
+	}
+
+	emails := []string{}
+	for _, user := range server.users {
+		emails = append(emails, user["email"].(string))
+	}
+	sort.Strings(emails)
+	if fmt.Sprint(emails) != "[alice@example.com bob@example.com]" {
+		t.Errorf("expected only declared users to remain but found %v", emails)
+	}
+	if _, ok := server.groups["old"]; ok {
+		t.Errorf("expected undeclared group to be deleted")
+	}
+	for _, acl := range server.acls {
+		if acl["subject"] == "stale" {
+			t.Errorf("expected undeclared acl %v to be removed", acl)
+		}
+	}
+	if len(server.acls) != 2 {
+		t.Errorf("expected 2 acls to remain but found %v", server.acls)
+	}
+}
+
+func TestReconcileAccessModel_matchesEmailsRegardlessOfCase(t *testing.T) {
+	server := newFakeAccessServer()
+	server.users["existing"] = map[string]interface{}{"id": "existing", "email": "Alice@Example.com", "permission": "admin"}
+	server.users["bob"] = map[string]interface{}{"id": "bob", "email": "bob@example.com", "permission": "none"}
+	server.groups["ops"] = map[string]interface{}{"id": "ops", "name": "operators", "users": []string{"existing", "bob"}}
+	c := Client{rpc: &fakeRPC{handler: server.handle}}
+
+	model := testAccessModel()
+	model.Groups[0].Members = []string{"ALICE@example.com", "bob@example.com"}
+	model.Acls[1].Subject = "Bob@Example.com"
+	model.Prune = true
+	report, err := c.ReconcileAccessModel(model)
+	if err != nil {
+		t.Fatalf("failed to reconcile access model with error: %v", err)
+	}
+
+	for _, action := range report.Actions {
+		if action.Type == "user" && action.Action != ReconcileSkip {
+			t.Errorf("expected the users to be matched regardless of the case of their email but received %+v", action)
+		}
+	}
+	if len(server.users) != 2 {
+		t.Errorf("expected no user to be created or pruned but found %v", server.users)
+	}
+	acls := fmt.Sprint(server.acls)
+	if !strings.Contains(acls, "subject:bob") {
+		t.Errorf("expected the acl of Bob@Example.com to be granted to bob but found %v", acls)
+	}
+}
+
+func TestReconcileAccessModel_reportsUnknownSubjects(t *testing.T) {
+	server := newFakeAccessServer()
+	c := Client{rpc: &fakeRPC{handler: server.handle}}
+
+	report, err := c.ReconcileAccessModel(AccessModel{
+		Acls: []AccessAcl{{Subject: "nobody", Object: "vm-1", Action: "viewer"}},
+	})
+	if err == nil {
+		t.Fatalf("expected an error for an unknown acl subject")
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0].Type != "acl" {
+		t.Errorf("expected the acl action to be reported as failed but received %+v", failed)
+	}
+}
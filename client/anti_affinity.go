@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VMs tagged `xo:anti-affinity=<group>` belong to the anti-affinity group,
+// CreateVm and StartVmWithOptions start them on a host running no other VM
+// of the group.
+const AntiAffinityTag = "xo:anti-affinity"
+
+func antiAffinityTag(group string) string {
+	return AntiAffinityTag + "=" + group
+}
+
+// antiAffinityGroup returns the anti-affinity group the VM is tagged with,
+// an empty string when it belongs to none.
+func (v Vm) antiAffinityGroup() string {
+	for _, tag := range v.Tags {
+		if strings.HasPrefix(tag, AntiAffinityTag+"=") {
+			return strings.TrimPrefix(tag, AntiAffinityTag+"=")
+		}
+	}
+	return ""
+}
+
+// AntiAffinityError is returned when every host of the pool already runs a
+// VM of the anti-affinity group, or the requested host does.
+type AntiAffinityError struct {
+	Group  string
+	PoolId string
+	// Hosts running a VM of the group
+	OccupiedHosts []string
+}
+
+func (e AntiAffinityError) Error() string {
+	return fmt.Sprintf("no host of pool `%s` is free of VMs of anti-affinity group `%s`, hosts running one: %s", e.PoolId, e.Group, strings.Join(e.OccupiedHosts, ", "))
+}
+
+// antiAffinityHost returns the host a VM of the group is started on: the
+// requested host when it runs no other VM of the group, or else the enabled
+// host with the most free memory among those running none. The placements
+// are read once, VMs of the group started concurrently may still land on
+// the same host.
+func (c *Client) antiAffinityHost(poolId, group, vmId, requestedHost string) (string, error) {
+	var vmsRes map[string]Vm
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":    "VM",
+			"$poolId": poolId,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &vmsRes); err != nil {
+		return "", err
+	}
+
+	occupied := []string{}
+	for _, id := range sortedKeys(vmsRes) {
+		vm := vmsRes[id]
+		if id == vmId || vm.antiAffinityGroup() != group {
+			continue
+		}
+		if vm.PowerState != PowerStateRunning && vm.PowerState != PowerStatePaused {
+			continue
+		}
+		if !stringInSlice(vm.Host, occupied) {
+			occupied = append(occupied, vm.Host)
+		}
+	}
+
+	placementErr := AntiAffinityError{Group: group, PoolId: poolId, OccupiedHosts: occupied}
+	if requestedHost != "" {
+		if stringInSlice(requestedHost, occupied) {
+			return "", placementErr
+		}
+		return requestedHost, nil
+	}
+
+	hosts, err := c.GetPoolHosts(poolId)
+	if err != nil {
+		return "", err
+	}
+	var best *Host
+	for i, host := range hosts {
+		if !host.Enabled || stringInSlice(host.Id, occupied) {
+			continue
+		}
+		if best == nil || host.Memory.Size-host.Memory.Usage > best.Memory.Size-best.Memory.Usage {
+			best = &hosts[i]
+		}
+	}
+	if best == nil {
+		return "", placementErr
+	}
+	c.logf("[DEBUG] Placing VM of anti-affinity group `%s` on host `%s`\n", group, best.Id)
+	return best.Id, nil
+}
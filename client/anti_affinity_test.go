@@ -0,0 +1,103 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// antiAffinityRPC places the VMs it creates and starts on the hosts they
+// are started on, the hosts having the given free memory.
+func antiAffinityRPC(freeMemory ...int64) *fakeRPC {
+	objects := []map[string]interface{}{
+		{"id": testUuid, "type": "VM-template", "name_label": "Debian", "$poolId": "pool-1"},
+	}
+	for i, free := range freeMemory {
+		objects = append(objects, map[string]interface{}{"id": fmt.Sprintf("host-%d", i+1), "type": "host", "$pool": "pool-1", "enabled": true, "memory": map[string]interface{}{"size": free, "usage": 0}})
+	}
+	vms := map[string]map[string]interface{}{}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			objs := append([]map[string]interface{}{}, objects...)
+			for _, id := range sortedKeys(vms) {
+				objs = append(objs, vms[id])
+			}
+			return fakeGetAllObjects(params, objs...), nil
+		case "vm.create":
+			id := fmt.Sprintf("vm-%d", len(vms)+1)
+			vms[id] = map[string]interface{}{"id": id, "type": "VM", "$poolId": "pool-1", "$container": "pool-1", "power_state": "Halted", "tags": params["tags"]}
+			return id, nil
+		case "vm.start":
+			vm := vms[params["id"].(string)]
+			vm["power_state"], vm["$container"] = "Running", params["host"]
+		}
+		return true, nil
+	}}
+}
+
+func TestCreateVm_antiAffinityGroup(t *testing.T) {
+	rpc := antiAffinityRPC(minVmMemory*8, minVmMemory*4)
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.AntiAffinityGroup = "web"
+
+	hosts := []string{}
+	for i := 0; i < 2; i++ {
+		vm, err := c.CreateVm(vmReq, time.Minute)
+		if err != nil {
+			t.Fatalf("failed to create VM %d with error: %v", i+1, err)
+		}
+		hosts = append(hosts, vm.Host)
+	}
+	if !reflect.DeepEqual(hosts, []string{"host-1", "host-2"}) {
+		t.Errorf("expected the VMs to land on distinct hosts, the freest first, but received: %v", hosts)
+	}
+	create := rpc.callsTo("vm.create")[0].params
+	if create["bootAfterCreate"] != false || !reflect.DeepEqual(create["tags"], []interface{}{"xo:anti-affinity=web"}) {
+		t.Errorf("expected the VM to be tagged with its group and started by vm.start but received: %v", create)
+	}
+
+	var antiAffinity AntiAffinityError
+	if _, err := c.CreateVm(vmReq, time.Minute); !errors.As(err, &antiAffinity) || !reflect.DeepEqual(antiAffinity.OccupiedHosts, []string{"host-1", "host-2"}) {
+		t.Fatalf("expected an AntiAffinityError once every host runs a VM of the group but received: %v", err)
+	}
+	if creates := rpc.callsTo("vm.create"); len(creates) != 2 {
+		t.Errorf("expected no VM to be created without a free host but received %d vm.create calls", len(creates))
+	}
+
+	vmReq.AntiAffinityGroup = "db"
+	if vm, err := c.CreateVm(vmReq, time.Minute); err != nil || vm.Host != "host-1" {
+		t.Errorf("expected the VMs of other groups to be placed independently but received: %+v, %v", vm, err)
+	}
+}
+
+func TestStartVm_antiAffinityGroup(t *testing.T) {
+	rpc := antiAffinityRPC(minVmMemory*8, minVmMemory*4)
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.AntiAffinityGroup = "web"
+	if _, err := c.CreateVm(vmReq, time.Minute); err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	// A halted VM of the group, created without CreateVm starting it
+	rpc.handler("vm.create", map[string]interface{}{"tags": []interface{}{"xo:anti-affinity=web"}})
+
+	var antiAffinity AntiAffinityError
+	if err := c.StartVmWithOptions("vm-2", StartVmOptions{HostId: "host-1"}); !errors.As(err, &antiAffinity) {
+		t.Fatalf("expected an AntiAffinityError when starting on the host of the group but received: %v", err)
+	}
+	if err := c.StartVmWithOptions("vm-2", StartVmOptions{}); err != nil {
+		t.Fatalf("failed to start VM with error: %v", err)
+	}
+	starts := rpc.callsTo("vm.start")
+	if last := starts[len(starts)-1].params; last["id"] != "vm-2" || last["host"] != "host-2" {
+		t.Errorf("expected the VM to be started on the free host but received: %v", last)
+	}
+}
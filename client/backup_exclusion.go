@@ -0,0 +1,83 @@
+package client
+
+import (
+	"strings"
+)
+
+const (
+	// VMs with this tag are skipped by XO's smart mode backups. The tag
+	// may carry a reason, e.g. `xo:no-bak=scratch VM`.
+	NoBackupTag = "xo:no-bak"
+	// Disks whose name starts with this prefix are skipped by backups.
+	NoBackupDiskPrefix = "[NOBAK]"
+)
+
+func isNoBackupTag(tag string) bool {
+	return tag == NoBackupTag || strings.HasPrefix(tag, NoBackupTag+"=")
+}
+
+// IsBackupExcluded reports whether XO's smart mode backups skip the VM.
+func (v Vm) IsBackupExcluded() bool {
+	for _, tag := range v.Tags {
+		if isNoBackupTag(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBackupExcluded reports whether XO's backups skip the disk.
+func (v VDI) IsBackupExcluded() bool {
+	return strings.HasPrefix(v.NameLabel, NoBackupDiskPrefix)
+}
+
+// SetVmBackupExclusion tags or untags the VM so XO's smart mode backups
+// skip it. Excluding a VM that is already excluded is a no-op.
+func (c *Client) SetVmBackupExclusion(vmId string, excluded bool) error {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return err
+	}
+
+	if excluded {
+		if vm.IsBackupExcluded() {
+			return nil
+		}
+		return c.AddTag(vmId, NoBackupTag)
+	}
+
+	for _, tag := range vm.Tags {
+		if !isNoBackupTag(tag) {
+			continue
+		}
+		if err := c.RemoveTag(vmId, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetDiskBackupExclusion adds or removes the [NOBAK] prefix of the VDI's
+// name so XO's backups skip it. The rest of the name is left untouched.
+func (c *Client) SetDiskBackupExclusion(vdiId string, excluded bool) error {
+	vdi, err := c.getVdiById(vdiId)
+	if err != nil {
+		return err
+	}
+
+	name := vdi.NameLabel
+	switch {
+	case excluded && !vdi.IsBackupExcluded():
+		name = NoBackupDiskPrefix + name
+	case !excluded && vdi.IsBackupExcluded():
+		name = strings.TrimPrefix(name, NoBackupDiskPrefix)
+	default:
+		return nil
+	}
+
+	var success bool
+	return c.Call("vdi.set", map[string]interface{}{
+		"id":         vdiId,
+		"name_label": name,
+	}, &success)
+}
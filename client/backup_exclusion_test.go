@@ -0,0 +1,106 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestIsBackupExcluded(t *testing.T) {
+	vmTests := map[string]bool{
+		"xo:no-bak":         true,
+		"xo:no-bak=scratch": true,
+		"xo:no-bakery":      false,
+		"no-bak":            false,
+	}
+	for tag, expected := range vmTests {
+		vm := Vm{Tags: []string{"prod", tag}}
+		if vm.IsBackupExcluded() != expected {
+			t.Errorf("expected a VM tagged `%s` to be excluded: %t", tag, expected)
+		}
+	}
+
+	diskTests := map[string]bool{
+		"[NOBAK] data": true,
+		"[NOBAK]data":  true,
+		"data [NOBAK]": false,
+		"[nobak] data": false,
+	}
+	for name, expected := range diskTests {
+		if (VDI{NameLabel: name}).IsBackupExcluded() != expected {
+			t.Errorf("expected a disk named `%s` to be excluded: %t", name, expected)
+		}
+	}
+}
+
+func TestSetVmBackupExclusion(t *testing.T) {
+	tags := []string{"prod"}
+	rpc := &fakeRPC{}
+	rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "tag.add":
+			tags = append(tags, params["tag"].(string))
+		case "tag.remove":
+			kept := []string{}
+			for _, tag := range tags {
+				if tag != params["tag"] {
+					kept = append(kept, tag)
+				}
+			}
+			tags = kept
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, map[string]interface{}{"id": "vm-1", "type": "VM", "tags": tags}), nil
+		}
+		return true, nil
+	}
+	c := &Client{rpc: rpc}
+
+	if err := c.SetVmBackupExclusion("vm-1", true); err != nil {
+		t.Fatalf("failed to exclude VM with error: %v", err)
+	}
+	if err := c.SetVmBackupExclusion("vm-1", true); err != nil {
+		t.Fatalf("failed to exclude VM with error: %v", err)
+	}
+	if len(rpc.callsTo("tag.add")) != 1 || !(Vm{Tags: tags}).IsBackupExcluded() {
+		t.Errorf("expected the VM to be tagged once but received tags %v", tags)
+	}
+
+	tags = append(tags, "xo:no-bak=scratch")
+	if err := c.SetVmBackupExclusion("vm-1", false); err != nil {
+		t.Fatalf("failed to include VM with error: %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "prod" {
+		t.Errorf("expected every exclusion tag to be removed but received tags %v", tags)
+	}
+}
+
+func TestSetDiskBackupExclusion(t *testing.T) {
+	name := "  data disk [1]"
+	rpc := &fakeRPC{}
+	rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "vdi.set":
+			name = params["name_label"].(string)
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, map[string]interface{}{"id": "vdi-1", "type": "VDI", "name_label": name}), nil
+		}
+		return true, nil
+	}
+	c := &Client{rpc: rpc}
+
+	if err := c.SetDiskBackupExclusion("vdi-1", true); err != nil {
+		t.Fatalf("failed to exclude disk with error: %v", err)
+	}
+	if name != "[NOBAK]  data disk [1]" {
+		t.Errorf("expected the disk name to be prefixed but received `%s`", name)
+	}
+
+	if err := c.SetDiskBackupExclusion("vdi-1", true); err != nil || len(rpc.callsTo("vdi.set")) != 1 {
+		t.Errorf("expected excluding an excluded disk to be a no-op, error: %v", err)
+	}
+
+	if err := c.SetDiskBackupExclusion("vdi-1", false); err != nil {
+		t.Fatalf("failed to include disk with error: %v", err)
+	}
+	if name != "  data disk [1]" {
+		t.Errorf("expected the original disk name to be restored but received `%s`", name)
+	}
+}
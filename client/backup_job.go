@@ -0,0 +1,156 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// BackupJob is a backup job of XO's backup-ng engine.
+type BackupJob struct {
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// `full` or `delta`
+	Mode string `json:"mode"`
+	// `native`, `zstd` or empty for none. XO stores it on the job rather
+	// than in its settings.
+	Compression string `json:"compression,omitempty"`
+	// Patterns selecting the VMs, remotes and SRs of the job
+	Vms     map[string]interface{} `json:"vms,omitempty"`
+	Remotes map[string]interface{} `json:"remotes,omitempty"`
+	Srs     map[string]interface{} `json:"srs,omitempty"`
+	// Settings of the job, under the empty key, and their overrides for
+	// each schedule keyed by schedule id
+	Settings map[string]BackupSettings `json:"settings,omitempty"`
+}
+
+// BackupSettings are the settings of a backup job or the overrides of one
+// of its schedules, nil fields are inherited from the job. Settings the
+// SDK doesn't model are kept so they are sent back untouched on update.
+type BackupSettings struct {
+	// Number of VMs backed up in parallel
+	Concurrency *int `json:"concurrency,omitempty"`
+	// Number of NBD connections used to export each disk
+	NbdConcurrency *int `json:"nbdConcurrency,omitempty"`
+	// In bytes per second, 0 for no limit
+	MaxExportRate      *int64 `json:"maxExportRate,omitempty"`
+	OfflineBackup      *bool  `json:"offlineBackup,omitempty"`
+	OfflineSnapshot    *bool  `json:"offlineSnapshot,omitempty"`
+	CheckpointSnapshot *bool  `json:"checkpointSnapshot,omitempty"`
+	Timezone           string `json:"timezone,omitempty"`
+	// ReportWhenAlways, ReportWhenFailure or ReportWhenNever
+	ReportWhen string `json:"reportWhen,omitempty"`
+
+	unknown map[string]json.RawMessage
+}
+
+// backupSettings has the fields of BackupSettings without its json
+// methods.
+type backupSettings BackupSettings
+
+func (s *BackupSettings) UnmarshalJSON(data []byte) error {
+	var known backupSettings
+	if err := json.Unmarshal(data, &known); err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for _, name := range jsonFieldNames(reflect.TypeOf(known)) {
+		delete(all, name)
+	}
+
+	*s = BackupSettings(known)
+	s.unknown = all
+	return nil
+}
+
+func (s BackupSettings) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(backupSettings(s))
+	if err != nil || len(s.unknown) == 0 {
+		return data, err
+	}
+
+	all := map[string]json.RawMessage{}
+	for name, value := range s.unknown {
+		all[name] = value
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	return json.Marshal(all)
+}
+
+// jsonFieldNames returns the json names of the exported fields of a
+// struct type.
+func jsonFieldNames(t reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func (c *Client) GetBackupJobs() ([]BackupJob, error) {
+	var jobs []BackupJob
+	err := c.Call("backupNg.getAllJobs", map[string]interface{}{}, &jobs)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (c *Client) GetBackupJob(id string) (*BackupJob, error) {
+	var job BackupJob
+	err := c.Call("backupNg.getJob", map[string]interface{}{"id": id}, &job)
+	if err != nil {
+		return nil, err
+	}
+	if job.Id == "" {
+		return nil, errors.New(fmt.Sprintf("could not find backup job `%s`", id))
+	}
+	return &job, nil
+}
+
+// UpdateBackupJob updates the backup job with the fields of job. Fields
+// of the job the SDK doesn't model are left untouched by XO.
+func (c *Client) UpdateBackupJob(job BackupJob) error {
+	if err := c.validateUpdateBackupJob(job); err != nil {
+		return err
+	}
+
+	params := map[string]interface{}{
+		"id":          job.Id,
+		"name":        job.Name,
+		"mode":        job.Mode,
+		"compression": job.Compression,
+		"settings":    job.Settings,
+	}
+	if job.Vms != nil {
+		params["vms"] = job.Vms
+	}
+	if job.Remotes != nil {
+		params["remotes"] = job.Remotes
+	}
+	if job.Srs != nil {
+		params["srs"] = job.Srs
+	}
+
+	var success bool
+	return c.Call("backupNg.editJob", params, &success)
+}
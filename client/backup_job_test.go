@@ -0,0 +1,119 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const backupJobFixture = `{
+	"id": "job-1",
+	"type": "backup",
+	"name": "nightly delta",
+	"mode": "delta",
+	"compression": "zstd",
+	"vms": {"tags": {"__or": ["prod"]}},
+	"remotes": {"id": {"__or": ["remote-1"]}},
+	"settings": {
+		"": {
+			"concurrency": 2,
+			"nbdConcurrency": 4,
+			"maxExportRate": 52428800,
+			"offlineBackup": false,
+			"offlineSnapshot": false,
+			"checkpointSnapshot": true,
+			"timezone": "Europe/Paris",
+			"reportWhen": "failure",
+			"reportRecipients": ["ops@example.org"],
+			"longTermRetention": {"daily": {"retention": 7}}
+		},
+		"schedule-1": {
+			"exportRetention": 7,
+			"concurrency": 1,
+			"maxExportRate": 10485760
+		}
+	}
+}`
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	var valueA, valueB interface{}
+	if err := json.Unmarshal(a, &valueA); err != nil {
+		t.Fatalf("failed to decode %s with error: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &valueB); err != nil {
+		t.Fatalf("failed to decode %s with error: %v", b, err)
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
+
+func TestBackupSettings_roundTrip(t *testing.T) {
+	var job BackupJob
+	if err := json.Unmarshal([]byte(backupJobFixture), &job); err != nil {
+		t.Fatalf("failed to decode backup job with error: %v", err)
+	}
+
+	global := job.Settings[""]
+	if *global.Concurrency != 2 || *global.NbdConcurrency != 4 || *global.MaxExportRate != 52428800 || *global.OfflineBackup || !*global.CheckpointSnapshot || global.Timezone != "Europe/Paris" || global.ReportWhen != "failure" {
+		t.Errorf("expected the job settings to be decoded but received: %+v", global)
+	}
+	override := job.Settings["schedule-1"]
+	if *override.Concurrency != 1 || *override.MaxExportRate != 10485760 || override.NbdConcurrency != nil || override.OfflineSnapshot != nil {
+		t.Errorf("expected the schedule to only override concurrency and export rate but received: %+v", override)
+	}
+	if job.Compression != "zstd" {
+		t.Errorf("expected zstd compression but received: %s", job.Compression)
+	}
+
+	settings, err := json.Marshal(job.Settings)
+	if err != nil {
+		t.Fatalf("failed to encode backup settings with error: %v", err)
+	}
+	var fixture struct {
+		Settings json.RawMessage `json:"settings"`
+	}
+	json.Unmarshal([]byte(backupJobFixture), &fixture)
+	if !jsonEqual(t, settings, fixture.Settings) {
+		t.Errorf("expected settings to round trip untouched, expected %s but received %s", fixture.Settings, settings)
+	}
+}
+
+func TestUpdateBackupJob_keepsUnknownSettings(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "backupNg.getJob" {
+			return json.RawMessage(backupJobFixture), nil
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	job, err := c.GetBackupJob("job-1")
+	if err != nil {
+		t.Fatalf("failed to get backup job with error: %v", err)
+	}
+
+	rate := int64(1048576)
+	override := job.Settings["schedule-1"]
+	override.MaxExportRate = &rate
+	job.Settings["schedule-1"] = override
+
+	if err := c.UpdateBackupJob(*job); err != nil {
+		t.Fatalf("failed to update backup job with error: %v", err)
+	}
+
+	calls := rpc.callsTo("backupNg.editJob")
+	if len(calls) != 1 {
+		t.Fatalf("expected the job to be edited once but received: %v", calls)
+	}
+	settings := calls[0].params["settings"].(map[string]interface{})
+	global := settings[""].(map[string]interface{})
+	if !reflect.DeepEqual(global["reportRecipients"], []interface{}{"ops@example.org"}) || global["longTermRetention"] == nil {
+		t.Errorf("expected unknown job settings to be sent back but received: %v", global)
+	}
+	schedule := settings["schedule-1"].(map[string]interface{})
+	if schedule["exportRetention"] != float64(7) || schedule["maxExportRate"] != float64(rate) || schedule["concurrency"] != float64(1) {
+		t.Errorf("expected the schedule override to be updated and keep its unknown settings but received: %v", schedule)
+	}
+	if calls[0].params["compression"] != "zstd" || calls[0].params["srs"] != nil {
+		t.Errorf("expected compression to be sent and srs to be left untouched but received: %v", calls[0].params)
+	}
+}
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"log"
+)
+
+const (
+	emailTransportPluginId = "transport-email"
+	backupReportsPluginId  = "backup-reports"
+)
+
+// Values of BackupSettings.ReportWhen understood by the backup-reports
+// plugin.
+const (
+	ReportWhenAlways  = "always"
+	ReportWhenFailure = "failure"
+	ReportWhenNever   = "never"
+)
+
+var reportWhenValues = []string{ReportWhenAlways, ReportWhenFailure, ReportWhenNever}
+
+// EmailTransportConfig is the configuration of the transport-email plugin
+// through which XO sends emails, e.g. backup reports.
+type EmailTransportConfig struct {
+	FromAddress string
+	FromName    string
+	// SMTP server
+	Host string
+	// Port of the SMTP server, the default one of Secure when 0
+	Port int
+	// `auto`, `force` or `disabled`, `auto` when empty
+	Secure string
+	// Accept the certificate of the SMTP server even when it isn't valid
+	IgnoreUnauthorized bool
+	Username           string
+	Password           string
+}
+
+func (e EmailTransportConfig) pluginConfig() map[string]interface{} {
+	from := map[string]interface{}{
+		"address": e.FromAddress,
+	}
+	if e.FromName != "" {
+		from["name"] = e.FromName
+	}
+
+	transport := map[string]interface{}{
+		"host":               e.Host,
+		"ignoreUnauthorized": e.IgnoreUnauthorized,
+	}
+	if e.Port != 0 {
+		transport["port"] = e.Port
+	}
+	if e.Secure != "" {
+		transport["secure"] = e.Secure
+	}
+	if e.Username != "" {
+		transport["user"] = e.Username
+		transport["password"] = e.Password
+	}
+
+	return map[string]interface{}{
+		"from":      from,
+		"transport": transport,
+	}
+}
+
+// ConfigureEmailTransport configures and enables the transport-email
+// plugin.
+func (c *Client) ConfigureEmailTransport(config EmailTransportConfig) error {
+	if err := c.validateEmailTransport(config); err != nil {
+		return err
+	}
+
+	err := c.ConfigurePlugin(emailTransportPluginId, config.pluginConfig())
+	if err != nil {
+		return err
+	}
+	return c.EnablePlugin(emailTransportPluginId)
+}
+
+type BackupReportsOptions struct {
+	// Backup jobs whose ReportWhen setting is changed so that they ask
+	// the plugin for their reports, none when empty. Jobs set to
+	// ReportWhenNever are left as is.
+	JobIds []string
+	// Set the ReportWhen setting of the jobs to `failure` rather than
+	// `always`
+	OnFailureOnly bool
+}
+
+// ConfigureBackupReports configures and enables the backup-reports plugin
+// like ConfigureBackupReportsForJobs. The backup jobs which already
+// ask for reports, `always` or `failure`, are switched to `failure` or
+// `always` depending on onFailureOnly. The other jobs are left untouched,
+// see ConfigureBackupReportsForJobs to make them ask for reports.
+func (c *Client) ConfigureBackupReports(recipients []string, onFailureOnly bool) error {
+	jobs, err := c.GetBackupJobs()
+	if err != nil {
+		return err
+	}
+
+	opts := BackupReportsOptions{OnFailureOnly: onFailureOnly}
+	for _, job := range jobs {
+		reportWhen := job.Settings[""].ReportWhen
+		if reportWhen == ReportWhenAlways || reportWhen == ReportWhenFailure {
+			opts.JobIds = append(opts.JobIds, job.Id)
+		}
+	}
+	return c.ConfigureBackupReportsForJobs(recipients, opts)
+}
+
+// ConfigureBackupReportsForJobs configures and enables the
+// backup-reports plugin to email the reports of backup jobs to the
+// recipients. The plugin only sends the reports the jobs ask for with
+// their ReportWhen setting, which is set for opts.JobIds. The emails are
+// sent through the transport-email plugin, see ConfigureEmailTransport.
+func (c *Client) ConfigureBackupReportsForJobs(recipients []string, opts BackupReportsOptions) error {
+	config := map[string]interface{}{
+		"toMails": recipients,
+	}
+	err := c.ConfigurePlugin(backupReportsPluginId, config)
+	if err != nil {
+		return err
+	}
+	if err := c.EnablePlugin(backupReportsPluginId); err != nil {
+		return err
+	}
+
+	reportWhen := ReportWhenAlways
+	if opts.OnFailureOnly {
+		reportWhen = ReportWhenFailure
+	}
+	for _, id := range opts.JobIds {
+		job, err := c.GetBackupJob(id)
+		if err != nil {
+			return err
+		}
+		settings := job.Settings[""]
+		if settings.ReportWhen == reportWhen || settings.ReportWhen == ReportWhenNever {
+			continue
+		}
+
+		log.Printf("[DEBUG] Setting the reportWhen setting of backup job `%s` to `%s`\n", job.Id, reportWhen)
+		settings.ReportWhen = reportWhen
+		if job.Settings == nil {
+			job.Settings = map[string]BackupSettings{}
+		}
+		job.Settings[""] = settings
+		if err := c.UpdateBackupJob(*job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendTestEmail sends a test email through the transport-email plugin.
+func (c *Client) SendTestEmail(to string) error {
+	params := map[string]interface{}{
+		"id": emailTransportPluginId,
+		"data": map[string]interface{}{
+			"to": to,
+		},
+	}
+	return c.Call("plugin.test", params, nil)
+}
@@ -0,0 +1,193 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConfigureEmailTransport(t *testing.T) {
+	rpc := fakePluginRPC(map[string]interface{}{"id": "transport-email", "loaded": false, "autoload": false})
+	c := &Client{rpc: rpc}
+
+	err := c.ConfigureEmailTransport(EmailTransportConfig{
+		FromAddress: "xo@example.org",
+		FromName:    "XO",
+		Host:        "smtp.example.org",
+		Port:        587,
+		Secure:      "force",
+		Username:    "xo",
+		Password:    "hunter2",
+	})
+	if err != nil {
+		t.Fatalf("failed to configure the email transport with error: %v", err)
+	}
+
+	calls := rpc.callsTo("plugin.configure")
+	if len(calls) != 1 || calls[0].params["id"] != "transport-email" {
+		t.Fatalf("expected transport-email to be configured but received: %v", calls)
+	}
+	expected := map[string]interface{}{
+		"from": map[string]interface{}{"address": "xo@example.org", "name": "XO"},
+		"transport": map[string]interface{}{
+			"host":               "smtp.example.org",
+			"port":               float64(587),
+			"secure":             "force",
+			"ignoreUnauthorized": false,
+			"user":               "xo",
+			"password":           "hunter2",
+		},
+	}
+	if !reflect.DeepEqual(calls[0].params["configuration"], expected) {
+		t.Errorf("expected configuration %v but received %v", expected, calls[0].params["configuration"])
+	}
+	if len(rpc.callsTo("plugin.load")) != 1 {
+		t.Errorf("expected the plugin to be loaded but received calls: %v", rpc.methods())
+	}
+}
+
+func TestConfigureEmailTransport_validation(t *testing.T) {
+	rpc := fakePluginRPC()
+	c := &Client{rpc: rpc}
+
+	err := c.ConfigureEmailTransport(EmailTransportConfig{Port: 70000, Secure: "tls", Password: "hunter2"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 5 {
+		t.Fatalf("expected 5 validation errors but received: %v", err)
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected no call to be made but received: %v", rpc.methods())
+	}
+}
+
+func fakeBackupReportsRPC() *fakeRPC {
+	jobs := map[string]map[string]interface{}{
+		"job-1": {"id": "job-1", "name": "nightly", "mode": "delta", "settings": map[string]interface{}{"": map[string]interface{}{"reportWhen": "always", "reportRecipients": []string{"ops@example.org"}}}},
+		"job-2": {"id": "job-2", "name": "weekly", "mode": "full", "settings": map[string]interface{}{"": map[string]interface{}{"reportWhen": "failure"}}},
+		"job-3": {"id": "job-3", "name": "monthly", "mode": "full"},
+		"job-4": {"id": "job-4", "name": "scratch", "mode": "full", "settings": map[string]interface{}{"": map[string]interface{}{"reportWhen": "never"}}},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "plugin.get":
+			return []map[string]interface{}{{"id": "backup-reports", "loaded": true, "autoload": true}}, nil
+		case "backupNg.getAllJobs":
+			all := []map[string]interface{}{}
+			for _, id := range sortedKeys(jobs) {
+				all = append(all, jobs[id])
+			}
+			return all, nil
+		case "backupNg.getJob":
+			if job, ok := jobs[params["id"].(string)]; ok {
+				return job, nil
+			}
+			return map[string]interface{}{}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestConfigureBackupReports(t *testing.T) {
+	rpc := fakeBackupReportsRPC()
+	c := &Client{rpc: rpc}
+
+	if err := c.ConfigureBackupReports([]string{"ops@example.org", "admin@example.org"}, true); err != nil {
+		t.Fatalf("failed to configure backup reports with error: %v", err)
+	}
+
+	configure := rpc.callsTo("plugin.configure")
+	if len(configure) != 1 || configure[0].params["id"] != "backup-reports" {
+		t.Fatalf("expected backup-reports to be configured but received: %v", configure)
+	}
+	expected := map[string]interface{}{"toMails": []interface{}{"ops@example.org", "admin@example.org"}}
+	if !reflect.DeepEqual(configure[0].params["configuration"], expected) {
+		t.Errorf("expected configuration %v but received %v", expected, configure[0].params["configuration"])
+	}
+	edits := rpc.callsTo("backupNg.editJob")
+	if len(edits) != 1 || edits[0].params["id"] != "job-1" {
+		t.Fatalf("expected only the job reporting always to be edited but received: %v", edits)
+	}
+
+	rpc = fakeBackupReportsRPC()
+	c = &Client{rpc: rpc}
+	if err := c.ConfigureBackupReports([]string{"ops@example.org"}, false); err != nil {
+		t.Fatalf("failed to configure backup reports with error: %v", err)
+	}
+	edits = rpc.callsTo("backupNg.editJob")
+	if len(edits) != 1 || edits[0].params["id"] != "job-2" {
+		t.Errorf("expected only the job reporting failures to be edited but received: %v", edits)
+	}
+}
+
+func TestConfigureBackupReportsForJobs_jobs(t *testing.T) {
+	rpc := fakeBackupReportsRPC()
+	c := &Client{rpc: rpc}
+
+	opts := BackupReportsOptions{JobIds: []string{"job-1", "job-2", "job-3", "job-4"}, OnFailureOnly: true}
+	if err := c.ConfigureBackupReportsForJobs([]string{"ops@example.org"}, opts); err != nil {
+		t.Fatalf("failed to configure backup reports with error: %v", err)
+	}
+
+	edits := rpc.callsTo("backupNg.editJob")
+	if len(edits) != 2 || edits[0].params["id"] != "job-1" || edits[1].params["id"] != "job-3" {
+		t.Fatalf("expected only the jobs not reporting failures nor set to never to be edited but received: %v", edits)
+	}
+	for _, edit := range edits {
+		settings := edit.params["settings"].(map[string]interface{})[""].(map[string]interface{})
+		if settings["reportWhen"] != "failure" {
+			t.Errorf("expected job `%s` to report failures but received settings: %v", edit.params["id"], settings)
+		}
+	}
+	settings := edits[0].params["settings"].(map[string]interface{})[""].(map[string]interface{})
+	if settings["reportRecipients"] == nil {
+		t.Errorf("expected the other settings of the job to be kept but received: %v", settings)
+	}
+
+	if err := c.ConfigureBackupReportsForJobs([]string{"ops@example.org"}, BackupReportsOptions{JobIds: []string{"job-missing"}}); err == nil {
+		t.Errorf("expected a missing job to fail")
+	}
+}
+
+func TestUpdateBackupJob_reportWhenValidation(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc}
+
+	job := BackupJob{
+		Id:   "job-1",
+		Mode: "delta",
+		Settings: map[string]BackupSettings{
+			"":           {ReportWhen: "error"},
+			"schedule-1": {ReportWhen: ReportWhenNever},
+		},
+	}
+	err := c.UpdateBackupJob(job)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("expected a single validation error but received: %v", err)
+	}
+	if field := errs[0].(ValidationError).Field; field != `Settings[""].ReportWhen` {
+		t.Errorf("expected the job's reportWhen to be rejected but received: %v", errs[0])
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected no call to be made but received: %v", rpc.methods())
+	}
+
+	job.Settings[""] = BackupSettings{ReportWhen: ReportWhenFailure}
+	if err := c.UpdateBackupJob(job); err != nil {
+		t.Errorf("expected a valid reportWhen to be accepted but received: %v", err)
+	}
+}
+
+func TestSendTestEmail(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc}
+
+	if err := c.SendTestEmail("ops@example.org"); err != nil {
+		t.Fatalf("failed to send test email with error: %v", err)
+	}
+	params := rpc.callsTo("plugin.test")[0].params
+	expected := map[string]interface{}{"id": "transport-email", "data": map[string]interface{}{"to": "ops@example.org"}}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected params %v but received %v", expected, params)
+	}
+}
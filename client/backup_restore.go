@@ -0,0 +1,126 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type RestoreOptions struct {
+	// SR the disks of the restored VM are created on
+	SrId string
+	// Start the VM once it is restored and its VIFs are remapped
+	Start bool
+	// Network the VIFs are connected to instead of the one they were
+	// connected to when the VM was backed up, keyed by the id of the
+	// backed up network. The VIFs are left untouched when empty.
+	NetworkMapping map[string]string
+	// Network of the VIFs whose network isn't in NetworkMapping. When
+	// empty, restoring a VM with such a VIF fails with an
+	// UnmappedNetworkError.
+	DefaultNetworkId string
+}
+
+// UnmappedNetworkError is returned when a restored VM has VIFs connected
+// to networks missing from the NetworkMapping of the restore, without a
+// default network to fall back on. The VM is deleted rather than left
+// connected to the backed up networks.
+type UnmappedNetworkError struct {
+	BackupId string
+	Networks []string
+}
+
+func (e UnmappedNetworkError) Error() string {
+	return fmt.Sprintf("backup `%s` has VIFs connected to networks missing from the network mapping: %s", e.BackupId, strings.Join(e.Networks, ", "))
+}
+
+// ImportVmBackup restores the VM of a backup and returns it. With a
+// NetworkMapping, its VIFs are moved to the target networks once the VM
+// is imported, before it is started.
+func (c *Client) ImportVmBackup(backupId string, opts RestoreOptions) (*Vm, error) {
+	params := map[string]interface{}{
+		"id": backupId,
+		"sr": opts.SrId,
+	}
+
+	var vmId string
+	if err := c.Call("backupNg.importVmBackup", params, &vmId); err != nil {
+		return nil, featureDetect("backupNg.importVmBackup", err)
+	}
+
+	if len(opts.NetworkMapping) > 0 {
+		if err := c.remapRestoredVifs(backupId, vmId, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Start {
+		if err := c.StartVm(vmId); err != nil {
+			return nil, err
+		}
+	}
+	return c.GetVm(Vm{Id: vmId})
+}
+
+// remapRestoredVifs moves the VIFs of the restored VM to the networks of the
+// mapping, or to the default one. The VM is deleted when a VIF can't be
+// mapped.
+func (c *Client) remapRestoredVifs(backupId, vmId string, opts RestoreOptions) error {
+	var vifsRes map[string]VIF
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type": "VIF",
+			"$VM":  vmId,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &vifsRes); err != nil {
+		return err
+	}
+
+	targets := []string{}
+	for _, target := range opts.NetworkMapping {
+		targets = append(targets, target)
+	}
+
+	moves := map[string]string{}
+	unmapped := []string{}
+	for _, id := range sortedKeys(vifsRes) {
+		vif := vifsRes[id]
+		target, ok := opts.NetworkMapping[vif.Network]
+		switch {
+		case ok:
+		case stringInSlice(vif.Network, targets):
+			// Already connected to a target network
+			continue
+		case opts.DefaultNetworkId != "":
+			target = opts.DefaultNetworkId
+		default:
+			if !stringInSlice(vif.Network, unmapped) {
+				unmapped = append(unmapped, vif.Network)
+			}
+			continue
+		}
+		if target != vif.Network {
+			moves[id] = target
+		}
+	}
+
+	if len(unmapped) > 0 {
+		if err := c.DeleteVm(vmId); err != nil {
+			c.logf("[WARN] Failed to delete VM %s restored with unmapped networks: %v\n", vmId, err)
+		}
+		return UnmappedNetworkError{BackupId: backupId, Networks: unmapped}
+	}
+
+	for _, id := range sortedKeys(moves) {
+		var success bool
+		params := map[string]interface{}{
+			"id":      id,
+			"network": moves[id],
+		}
+		if err := c.Call("vif.set", params, &success); err != nil {
+			return errors.New(fmt.Sprintf("failed to move VIF %s of restored VM %s to network %s: %v", id, vmId, moves[id], err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// restoreRPC restores a VM whose VIFs are connected to the given networks.
+func restoreRPC(networks ...string) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "backupNg.importVmBackup":
+			return "restored-vm", nil
+		case "xo.getAllObjects":
+			objs := []map[string]interface{}{{"id": "restored-vm", "type": "VM", "power_state": "Running"}}
+			for i, network := range networks {
+				objs = append(objs, map[string]interface{}{"id": "vif-" + string(rune('0'+i)), "type": "VIF", "$VM": "restored-vm", "$network": network})
+			}
+			return fakeGetAllObjects(params, objs...), nil
+		}
+		return true, nil
+	}}
+}
+
+func vifMoves(rpc *fakeRPC) map[string]interface{} {
+	moves := map[string]interface{}{}
+	for _, call := range rpc.callsTo("vif.set") {
+		moves[call.params["id"].(string)] = call.params["network"]
+	}
+	return moves
+}
+
+func TestImportVmBackup_rewritesVifs(t *testing.T) {
+	rpc := restoreRPC("prod-net", "prod-storage-net", "test-net")
+	c := &Client{rpc: rpc}
+
+	opts := RestoreOptions{
+		SrId:  "sr-1",
+		Start: true,
+		NetworkMapping: map[string]string{
+			"prod-net":         "test-net",
+			"prod-storage-net": "test-storage-net",
+		},
+	}
+	vm, err := c.ImportVmBackup("backup-1", opts)
+	if err != nil {
+		t.Fatalf("failed to restore the backup with error: %v", err)
+	}
+	if vm.Id != "restored-vm" {
+		t.Errorf("expected the restored VM to be returned but received: %+v", vm)
+	}
+
+	imports := rpc.callsTo("backupNg.importVmBackup")
+	if len(imports) != 1 || !reflect.DeepEqual(imports[0].params, map[string]interface{}{"id": "backup-1", "sr": "sr-1"}) {
+		t.Errorf("expected the backup to be imported without network mapping but received: %v", imports)
+	}
+	expected := map[string]interface{}{"vif-0": "test-net", "vif-1": "test-storage-net"}
+	if moves := vifMoves(rpc); !reflect.DeepEqual(moves, expected) {
+		t.Errorf("expected the VIFs to be moved to %v but received: %v", expected, moves)
+	}
+
+	methods := rpc.methods()
+	lastMove, start := -1, -1
+	for i, method := range methods {
+		switch method {
+		case "vif.set":
+			lastMove = i
+		case "vm.start":
+			start = i
+		}
+	}
+	if start < lastMove {
+		t.Errorf("expected the VM to be started once its VIFs are moved but received: %v", methods)
+	}
+}
+
+func TestImportVmBackup_unmappedNetwork(t *testing.T) {
+	rpc := restoreRPC("prod-net", "backup-net", "mgmt-net")
+	c := &Client{rpc: rpc}
+
+	mapping := map[string]string{"prod-net": "test-net"}
+	_, err := c.ImportVmBackup("backup-1", RestoreOptions{SrId: "sr-1", Start: true, NetworkMapping: mapping})
+	var unmapped UnmappedNetworkError
+	if !errors.As(err, &unmapped) || !reflect.DeepEqual(unmapped.Networks, []string{"backup-net", "mgmt-net"}) {
+		t.Fatalf("expected an UnmappedNetworkError for backup-net and mgmt-net but received: %v", err)
+	}
+	if deletes := rpc.callsTo("vm.delete"); len(deletes) != 1 || deletes[0].params["id"] != "restored-vm" {
+		t.Errorf("expected the restored VM to be deleted but received: %v", deletes)
+	}
+	if moves, starts := vifMoves(rpc), rpc.callsTo("vm.start"); len(moves) != 0 || len(starts) != 0 {
+		t.Errorf("expected the VM to be neither remapped nor started but received: %v", rpc.methods())
+	}
+}
+
+func TestImportVmBackup_defaultNetwork(t *testing.T) {
+	rpc := restoreRPC("prod-net", "backup-net")
+	c := &Client{rpc: rpc}
+
+	opts := RestoreOptions{SrId: "sr-1", NetworkMapping: map[string]string{"prod-net": "test-net"}, DefaultNetworkId: "isolated-net"}
+	if _, err := c.ImportVmBackup("backup-1", opts); err != nil {
+		t.Fatalf("failed to restore the backup with error: %v", err)
+	}
+	expected := map[string]interface{}{"vif-0": "test-net", "vif-1": "isolated-net"}
+	if moves := vifMoves(rpc); !reflect.DeepEqual(moves, expected) {
+		t.Errorf("expected the VIFs to be moved to %v but received: %v", expected, moves)
+	}
+}
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// BlockDevice is a disk of a host as listed by lsblk.
+type BlockDevice struct {
+	Name string
+	// e.g. `/dev/nvme0n1`
+	Path       string
+	Size       int64
+	Model      string
+	Rotational bool
+	// Whether the disk is partitioned or holds mounted filesystems, as
+	// the disk of the control domain does
+	InUseBySystem bool
+	// Whether the disk backs an SR through one of the host's PBDs
+	InUseBySr bool
+	SrId      string
+}
+
+// lsblkDevice is a device reported by `lsblk --json`. Depending on the
+// lsblk version, sizes and flags are either strings or native values.
+type lsblkDevice struct {
+	Name       string          `json:"name"`
+	Type       string          `json:"type"`
+	Size       json.RawMessage `json:"size"`
+	Model      string          `json:"model"`
+	Rota       json.RawMessage `json:"rota"`
+	Mountpoint string          `json:"mountpoint"`
+	Children   []lsblkDevice   `json:"children"`
+}
+
+func (d lsblkDevice) mounted() bool {
+	if d.Mountpoint != "" {
+		return true
+	}
+	for _, child := range d.Children {
+		if child.mounted() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHostBlockDevices lists the disks of a host and flags the ones
+// already backing an SR. It relies on the lsblk plugin of the host and
+// returns an UnsupportedOnThisServerError when XO can't list them.
+func (c *Client) GetHostBlockDevices(hostId string) ([]BlockDevice, error) {
+	var response struct {
+		BlockDevices []lsblkDevice `json:"blockdevices"`
+	}
+	params := map[string]interface{}{
+		"id": hostId,
+	}
+	err := c.Call("host.getBlockdevices", params, &response)
+	if err != nil {
+		return nil, featureDetect("host.getBlockdevices", err)
+	}
+
+	pbds := map[string]PBD{}
+	err = c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type": "PBD",
+			"host": hostId,
+		},
+	}, &pbds)
+	if err != nil {
+		return nil, err
+	}
+
+	// SRs are configured with device paths, possibly several separated
+	// by commas, which are matched by device name.
+	srByDevice := map[string]string{}
+	for _, pbd := range pbds {
+		for _, device := range strings.Split(pbd.DeviceConfig["device"], ",") {
+			if device = strings.TrimSpace(device); device != "" {
+				srByDevice[path.Base(device)] = pbd.SR
+			}
+		}
+	}
+
+	devices := []BlockDevice{}
+	for _, d := range response.BlockDevices {
+		if d.Type != "disk" {
+			continue
+		}
+
+		device := BlockDevice{
+			Name:          d.Name,
+			Path:          devicePath(d.Name),
+			Size:          parseLsblkSize(d.Size),
+			Model:         strings.TrimSpace(d.Model),
+			Rotational:    parseLsblkBool(d.Rota),
+			InUseBySystem: len(d.Children) > 0 || d.mounted(),
+		}
+
+		device.SrId = srByDevice[d.Name]
+		for _, child := range d.Children {
+			if srId, ok := srByDevice[child.Name]; ok && device.SrId == "" {
+				device.SrId = srId
+			}
+		}
+		device.InUseBySr = device.SrId != ""
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// FindUnusedDevices returns the disks of a host that neither back an SR
+// nor are used by the host itself, i.e. the ones a local SR can be
+// created on.
+func (c *Client) FindUnusedDevices(hostId string) ([]BlockDevice, error) {
+	devices, err := c.GetHostBlockDevices(hostId)
+	if err != nil {
+		return nil, err
+	}
+
+	unused := []BlockDevice{}
+	for _, device := range devices {
+		if !device.InUseBySr && !device.InUseBySystem {
+			unused = append(unused, device)
+		}
+	}
+	return unused, nil
+}
+
+func parseLsblkSize(raw json.RawMessage) int64 {
+	var size int64
+	if json.Unmarshal(raw, &size) == nil {
+		return size
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		size, _ = strconv.ParseInt(s, 10, 64)
+	}
+	return size
+}
+
+func parseLsblkBool(raw json.RawMessage) bool {
+	var b bool
+	if json.Unmarshal(raw, &b) == nil {
+		return b
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s == "1"
+	}
+	return false
+}
+
+func devicePath(device string) string {
+	if strings.HasPrefix(device, "/dev/") {
+		return device
+	}
+	return "/dev/" + device
+}
@@ -0,0 +1,137 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Output of `lsblk --json` on a host with NVMe disks, recent lsblk
+// versions report sizes and flags as native values.
+const nvmeLsblkFixture = `{"blockdevices": [
+	{"name": "nvme0n1", "type": "disk", "size": 512110190592, "model": "Samsung SSD 970 EVO Plus 500GB", "rota": false, "mountpoint": null, "children": [
+		{"name": "nvme0n1p1", "type": "part", "size": 19327352832, "rota": false, "mountpoint": "/"},
+		{"name": "nvme0n1p3", "type": "part", "size": 492782837760, "rota": false, "mountpoint": null}
+	]},
+	{"name": "nvme1n1", "type": "disk", "size": 1000204886016, "model": "WD_BLACK SN770 1TB", "rota": false, "mountpoint": null}
+]}`
+
+// Output of `lsblk --json` on a host with SATA disks, older lsblk
+// versions report every value as a string.
+const sataLsblkFixture = `{"blockdevices": [
+	{"name": "sda", "type": "disk", "size": "2000398934016", "model": "ST2000DM008-2FR1 ", "rota": "1", "mountpoint": null},
+	{"name": "sdb", "type": "disk", "size": "4000787030016", "model": "ST4000VN008-2DR1 ", "rota": "1", "mountpoint": null},
+	{"name": "sr0", "type": "rom", "size": "1073741312", "model": "DVD-ROM", "rota": "1", "mountpoint": null}
+]}`
+
+func fakeBlockDevicesRPC(lsblk string, pbds ...map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "host.getBlockdevices":
+			return json.RawMessage(lsblk), nil
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, pbds...), nil
+		}
+		return nil, nil
+	}}
+}
+
+func TestGetHostBlockDevices_nvme(t *testing.T) {
+	c := &Client{rpc: fakeBlockDevicesRPC(nvmeLsblkFixture)}
+
+	devices, err := c.GetHostBlockDevices("host-1")
+	if err != nil {
+		t.Fatalf("failed to get block devices with error: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 block devices but received: %+v", devices)
+	}
+
+	system := devices[0]
+	if system.Path != "/dev/nvme0n1" || system.Size != 512110190592 || system.Model != "Samsung SSD 970 EVO Plus 500GB" || system.Rotational {
+		t.Errorf("expected nvme0n1 to be decoded but received: %+v", system)
+	}
+	if !system.InUseBySystem || system.InUseBySr {
+		t.Errorf("expected the partitioned disk to be in use by the system only but received: %+v", system)
+	}
+
+	empty := devices[1]
+	if empty.Path != "/dev/nvme1n1" || empty.Size != 1000204886016 || empty.InUseBySystem || empty.InUseBySr {
+		t.Errorf("expected nvme1n1 to be decoded as unused but received: %+v", empty)
+	}
+}
+
+func TestGetHostBlockDevices_sata(t *testing.T) {
+	c := &Client{rpc: fakeBlockDevicesRPC(sataLsblkFixture)}
+
+	devices, err := c.GetHostBlockDevices("host-1")
+	if err != nil {
+		t.Fatalf("failed to get block devices with error: %v", err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("expected the cdrom drive to be skipped but received: %+v", devices)
+	}
+	if d := devices[0]; d.Path != "/dev/sda" || d.Size != 2000398934016 || d.Model != "ST2000DM008-2FR1" || !d.Rotational {
+		t.Errorf("expected sda to be decoded but received: %+v", d)
+	}
+	if d := devices[1]; d.Path != "/dev/sdb" || d.Size != 4000787030016 || !d.Rotational {
+		t.Errorf("expected sdb to be decoded but received: %+v", d)
+	}
+}
+
+func TestFindUnusedDevices_skipsDevicesBackingAnSr(t *testing.T) {
+	c := &Client{rpc: fakeBlockDevicesRPC(sataLsblkFixture,
+		map[string]interface{}{"id": "pbd-1", "type": "PBD", "host": "host-1", "SR": "sr-1", "device_config": map[string]string{"device": "/dev/sda"}},
+		map[string]interface{}{"id": "pbd-2", "type": "PBD", "host": "host-2", "SR": "sr-2", "device_config": map[string]string{"device": "/dev/sdb"}},
+	)}
+
+	devices, err := c.GetHostBlockDevices("host-1")
+	if err != nil {
+		t.Fatalf("failed to get block devices with error: %v", err)
+	}
+	if !devices[0].InUseBySr || devices[0].SrId != "sr-1" {
+		t.Errorf("expected sda to back sr-1 but received: %+v", devices[0])
+	}
+	if devices[1].InUseBySr {
+		t.Errorf("expected sdb not to be in use since the PBD belongs to another host but received: %+v", devices[1])
+	}
+
+	unused, err := c.FindUnusedDevices("host-1")
+	if err != nil {
+		t.Fatalf("failed to find unused devices with error: %v", err)
+	}
+	if len(unused) != 1 || unused[0].Path != "/dev/sdb" {
+		t.Errorf("expected only sdb to be unused but received: %+v", unused)
+	}
+}
+
+func TestFindUnusedDevices_multipleDevicesPerSr(t *testing.T) {
+	c := &Client{rpc: fakeBlockDevicesRPC(nvmeLsblkFixture,
+		map[string]interface{}{"id": "pbd-1", "type": "PBD", "host": "host-1", "SR": "sr-1", "device_config": map[string]string{"device": "/dev/nvme0n1p3, /dev/nvme1n1"}},
+	)}
+
+	unused, err := c.FindUnusedDevices("host-1")
+	if err != nil {
+		t.Fatalf("failed to find unused devices with error: %v", err)
+	}
+	if len(unused) != 0 {
+		t.Errorf("expected no unused device but received: %+v", unused)
+	}
+}
+
+func TestGetHostBlockDevices_unsupported(t *testing.T) {
+	c := Client{
+		rpc: jsonRPCFail{
+			err: &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeMethodNotFound,
+				Message: "method not found",
+			},
+		},
+	}
+
+	_, err := c.FindUnusedDevices("host-1")
+	if _, ok := err.(UnsupportedOnThisServerError); !ok {
+		t.Errorf("expected UnsupportedOnThisServerError but received: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"sort"
+)
+
+// Bond aggregates the physical NICs of a host, its slaves, behind a
+// master PIF attached to the bonded network.
+type Bond struct {
+	Id     string
+	Mode   string
+	Master PIF
+	Slaves []PIF
+}
+
+type bondObject struct {
+	Id     string   `json:"id"`
+	Master string   `json:"master"`
+	Mode   string   `json:"mode"`
+	Slaves []string `json:"slaves"`
+}
+
+// GetNetworkWithBonds returns the network matching netReq with the bonds
+// backing it on each host.
+func (c *Client) GetNetworkWithBonds(netReq Network) (*Network, error) {
+	net, err := c.GetNetwork(netReq)
+	if err != nil {
+		return nil, err
+	}
+
+	masters := map[string]PIF{}
+	err = c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":         "PIF",
+			"$network":     net.Id,
+			"isBondMaster": true,
+		},
+	}, &masters)
+	if err != nil {
+		return nil, err
+	}
+
+	net.Bonds = []Bond{}
+	for _, master := range masters {
+		bonds := map[string]bondObject{}
+		err := c.Call("xo.getAllObjects", map[string]interface{}{
+			"filter": map[string]interface{}{
+				"type":   "bond",
+				"master": master.Id,
+			},
+		}, &bonds)
+		if err != nil {
+			return nil, err
+		}
+
+		hostPifs := map[string]PIF{}
+		err = c.Call("xo.getAllObjects", map[string]interface{}{
+			"filter": map[string]interface{}{
+				"type":  "PIF",
+				"$host": master.Host,
+			},
+		}, &hostPifs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range bonds {
+			bond := Bond{
+				Id:     b.Id,
+				Mode:   b.Mode,
+				Master: master,
+				Slaves: []PIF{},
+			}
+			for _, id := range b.Slaves {
+				if slave, ok := hostPifs[id]; ok {
+					bond.Slaves = append(bond.Slaves, slave)
+				}
+			}
+			sort.Slice(bond.Slaves, func(i, j int) bool {
+				return bond.Slaves[i].Device < bond.Slaves[j].Device
+			})
+			net.Bonds = append(net.Bonds, bond)
+		}
+	}
+
+	sort.Slice(net.Bonds, func(i, j int) bool {
+		return net.Bonds[i].Master.Host < net.Bonds[j].Master.Host
+	})
+	return net, nil
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"testing"
+)
+
+func fakeBondRPC() *fakeRPC {
+	objects := []map[string]interface{}{
+		{"id": "net-bond", "type": "network", "name_label": "Bond 0+1", "$poolId": "pool-1"},
+		{"id": "net-plain", "type": "network", "name_label": "Plain", "$poolId": "pool-1"},
+		{"id": "pif-bond", "type": "PIF", "device": "bond0", "$host": "host-1", "$network": "net-bond", "isBondMaster": true, "carrier": true},
+		{"id": "pif-eth0", "type": "PIF", "device": "eth0", "$host": "host-1", "$network": "net-eth0", "isBondSlave": true, "carrier": true},
+		{"id": "pif-eth1", "type": "PIF", "device": "eth1", "$host": "host-1", "$network": "net-eth1", "isBondSlave": true, "carrier": false},
+		{"id": "pif-eth2", "type": "PIF", "device": "eth2", "$host": "host-1", "$network": "net-plain", "isBondMaster": false, "carrier": true},
+		{"id": "bond-1", "type": "bond", "master": "pif-bond", "mode": "active-backup", "slaves": []string{"pif-eth1", "pif-eth0"}},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, objects...), nil
+	}}
+}
+
+func TestGetNetworkWithBonds_resolvesSlaves(t *testing.T) {
+	c := &Client{rpc: fakeBondRPC()}
+
+	net, err := c.GetNetworkWithBonds(Network{Id: "net-bond"})
+	if err != nil {
+		t.Fatalf("failed to get network with error: %v", err)
+	}
+	if len(net.Bonds) != 1 {
+		t.Fatalf("expected a single bond but received: %+v", net.Bonds)
+	}
+
+	bond := net.Bonds[0]
+	if bond.Mode != "active-backup" || bond.Master.Id != "pif-bond" {
+		t.Errorf("expected the active-backup bond of pif-bond but received: %+v", bond)
+	}
+	if len(bond.Slaves) != 2 || bond.Slaves[0].Device != "eth0" || bond.Slaves[1].Device != "eth1" {
+		t.Fatalf("expected eth0 and eth1 to back the bond but received: %+v", bond.Slaves)
+	}
+	if !bond.Slaves[0].Carrier || bond.Slaves[1].Carrier {
+		t.Errorf("expected eth0's link to be up and eth1's to be down but received: %+v", bond.Slaves)
+	}
+}
+
+func TestGetNetworkWithBonds_notBonded(t *testing.T) {
+	c := &Client{rpc: fakeBondRPC()}
+
+	net, err := c.GetNetworkWithBonds(Network{Id: "net-plain"})
+	if err != nil {
+		t.Fatalf("failed to get network with error: %v", err)
+	}
+	if len(net.Bonds) != 0 {
+		t.Errorf("expected no bond for a plain network but received: %+v", net.Bonds)
+	}
+}
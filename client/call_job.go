@@ -0,0 +1,182 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Key XO gives to the call jobs created from its jobs page.
+const callJobKey = "genericTask"
+
+// CallJob is a job of XO's generic job engine which calls an api method,
+// e.g. `vm.rollingSnapshot` or `host.installAllPatches`, when one of its
+// schedules triggers.
+type CallJob struct {
+	Id     string `json:"id,omitempty"`
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	// Params of the calls, as an XO params vector, e.g.
+	// {"type": "crossProduct", "items": [{"type": "set", "values": [{"id": "<vm>"}]}]}.
+	// They are sent and received untouched.
+	ParamsVector json.RawMessage `json:"paramsVector,omitempty"`
+	// In milliseconds, 0 for none
+	Timeout int64 `json:"timeout,omitempty"`
+
+	Schedules []Schedule `json:"-"`
+}
+
+// Schedule triggers a job following a cron pattern.
+type Schedule struct {
+	Id       string `json:"id,omitempty"`
+	JobId    string `json:"jobId"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Enabled  bool   `json:"enabled"`
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type callJob struct {
+	CallJob
+	Type string `json:"type"`
+}
+
+// GetCallJobs returns the call jobs along with their schedules.
+func (c *Client) GetCallJobs() ([]CallJob, error) {
+	var all []callJob
+	err := c.Call("job.getAll", map[string]interface{}{}, &all)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules, err := c.getSchedules()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []CallJob{}
+	for _, job := range all {
+		if job.Type != "call" {
+			continue
+		}
+		for _, schedule := range schedules {
+			if schedule.JobId == job.Id {
+				job.Schedules = append(job.Schedules, schedule)
+			}
+		}
+		jobs = append(jobs, job.CallJob)
+	}
+	return jobs, nil
+}
+
+func (c *Client) getSchedules() ([]Schedule, error) {
+	var schedules []Schedule
+	err := c.Call("schedule.getAll", map[string]interface{}{}, &schedules)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// CreateCallJob creates the job and its schedules. The job is deleted
+// again when one of its schedules can't be created.
+func (c *Client) CreateCallJob(job CallJob) (*CallJob, error) {
+	params := map[string]interface{}{
+		"job": callJobParams(job),
+	}
+	var id string
+	err := c.Call("job.create", params, &id)
+	if err != nil {
+		return nil, err
+	}
+
+	created := job
+	created.Id = id
+	created.Schedules = []Schedule{}
+	for _, schedule := range job.Schedules {
+		params := map[string]interface{}{
+			"jobId":   id,
+			"name":    schedule.Name,
+			"cron":    schedule.Cron,
+			"enabled": schedule.Enabled,
+		}
+		if schedule.Timezone != "" {
+			params["timezone"] = schedule.Timezone
+		}
+
+		var s Schedule
+		err = c.Call("schedule.create", params, &s)
+		if err != nil {
+			if deleteErr := c.DeleteCallJob(id); deleteErr != nil {
+				log.Printf("[WARN] Failed to delete job `%s` after failing to create its schedules: %v\n", id, deleteErr)
+			}
+			return nil, err
+		}
+		created.Schedules = append(created.Schedules, s)
+	}
+	return &created, nil
+}
+
+// UpdateCallJob updates the name, method, params and timeout of the job.
+// Its schedules are left untouched.
+func (c *Client) UpdateCallJob(job CallJob) error {
+	if job.Id == "" {
+		return errors.New("cannot update a call job without an id")
+	}
+
+	params := map[string]interface{}{
+		"job": callJobParams(job),
+	}
+	var success bool
+	return c.Call("job.set", params, &success)
+}
+
+// DeleteCallJob deletes the job along with its schedules.
+func (c *Client) DeleteCallJob(id string) error {
+	schedules, err := c.getSchedules()
+	if err != nil {
+		return err
+	}
+	for _, schedule := range schedules {
+		if schedule.JobId != id {
+			continue
+		}
+		var success bool
+		err = c.Call("schedule.delete", map[string]interface{}{"id": schedule.Id}, &success)
+		if err != nil {
+			return errors.New(fmt.Sprintf("failed to delete schedule `%s` of job `%s`: %v", schedule.Id, id, err))
+		}
+	}
+
+	var success bool
+	return c.Call("job.delete", map[string]interface{}{"id": id}, &success)
+}
+
+// RunCallJob runs the job now, regardless of its schedules.
+func (c *Client) RunCallJob(id string) error {
+	var success bool
+	params := map[string]interface{}{
+		"idSequence": []string{id},
+	}
+	return c.Call("job.runSequence", params, &success)
+}
+
+func callJobParams(job CallJob) map[string]interface{} {
+	params := map[string]interface{}{
+		"type":   "call",
+		"key":    callJobKey,
+		"name":   job.Name,
+		"method": job.Method,
+	}
+	if job.Id != "" {
+		params["id"] = job.Id
+	}
+	if job.ParamsVector != nil {
+		params["paramsVector"] = job.ParamsVector
+	}
+	if job.Timeout != 0 {
+		params["timeout"] = job.Timeout
+	}
+	return params
+}
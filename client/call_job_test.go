@@ -0,0 +1,147 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const snapshotParamsVector = `{"type":"crossProduct","items":[{"type":"set","values":[{"id":"vm-1","depth":7,"tag":"nightly"}]}]}`
+
+func TestCreateCallJob(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "job.create":
+			return "job-1", nil
+		case "schedule.create":
+			schedule := map[string]interface{}{"id": "schedule-1"}
+			for k, v := range params {
+				schedule[k] = v
+			}
+			return schedule, nil
+		}
+		return nil, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	job, err := c.CreateCallJob(CallJob{
+		Name:         "nightly snapshot",
+		Method:       "vm.snapshot",
+		ParamsVector: json.RawMessage(snapshotParamsVector),
+		Schedules:    []Schedule{{Name: "nightly", Cron: "0 2 * * *", Enabled: true, Timezone: "Europe/Paris"}},
+	})
+	if err != nil {
+		t.Fatalf("failed to create call job with error: %v", err)
+	}
+
+	create := rpc.callsTo("job.create")[0].params["job"].(map[string]interface{})
+	if create["type"] != "call" || create["method"] != "vm.snapshot" || create["name"] != "nightly snapshot" {
+		t.Errorf("expected a call job of vm.snapshot to be created but received: %v", create)
+	}
+	sent, _ := json.Marshal(create["paramsVector"])
+	if !jsonEqual(t, sent, []byte(snapshotParamsVector)) {
+		t.Errorf("expected the params to be sent untouched but received: %s", sent)
+	}
+
+	expected := []Schedule{{Id: "schedule-1", JobId: "job-1", Name: "nightly", Cron: "0 2 * * *", Enabled: true, Timezone: "Europe/Paris"}}
+	if job.Id != "job-1" || !reflect.DeepEqual(job.Schedules, expected) {
+		t.Errorf("expected job-1 to be returned with its schedule but received: %+v", job)
+	}
+}
+
+func TestCreateCallJob_deletesJobWhenScheduleFails(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "job.create":
+			return "job-1", nil
+		case "schedule.create":
+			return nil, errors.New("invalid cron pattern")
+		case "schedule.getAll":
+			return []interface{}{}, nil
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	_, err := c.CreateCallJob(CallJob{Name: "snapshot", Method: "vm.snapshot", Schedules: []Schedule{{Cron: "every day"}}})
+	if err == nil {
+		t.Fatalf("expected the schedule error to be returned")
+	}
+	if deletes := rpc.callsTo("job.delete"); len(deletes) != 1 || deletes[0].params["id"] != "job-1" {
+		t.Errorf("expected job-1 to be deleted but received: %v", deletes)
+	}
+}
+
+func TestGetCallJobs(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "job.getAll":
+			// 2^53 + 1 can't be represented by a float64
+			return json.RawMessage(`[
+				{"id": "job-1", "type": "call", "key": "genericTask", "name": "patches", "method": "host.installAllPatches", "paramsVector": {"type":"crossProduct","items":[{"type":"set","values":[{"host":"host-1","size":9007199254740993}]}]}},
+				{"id": "job-2", "type": "backup", "name": "nightly"}
+			]`), nil
+		case "schedule.getAll":
+			return []map[string]interface{}{
+				{"id": "schedule-1", "jobId": "job-1", "cron": "0 4 * * 0", "enabled": true},
+				{"id": "schedule-2", "jobId": "job-2", "cron": "0 2 * * *", "enabled": true},
+			}, nil
+		}
+		return nil, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	jobs, err := c.GetCallJobs()
+	if err != nil {
+		t.Fatalf("failed to get call jobs with error: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Id != "job-1" || jobs[0].Method != "host.installAllPatches" {
+		t.Fatalf("expected only the call job to be returned but received: %+v", jobs)
+	}
+	if string(jobs[0].ParamsVector) != `{"type":"crossProduct","items":[{"type":"set","values":[{"host":"host-1","size":9007199254740993}]}]}` {
+		t.Errorf("expected the params to be decoded untouched but received: %s", jobs[0].ParamsVector)
+	}
+	if len(jobs[0].Schedules) != 1 || jobs[0].Schedules[0].Id != "schedule-1" {
+		t.Errorf("expected the job's schedule to be linked but received: %+v", jobs[0].Schedules)
+	}
+}
+
+func TestDeleteCallJob(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "schedule.getAll" {
+			return []map[string]interface{}{
+				{"id": "schedule-1", "jobId": "job-1"},
+				{"id": "schedule-2", "jobId": "job-2"},
+			}, nil
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	if err := c.DeleteCallJob("job-1"); err != nil {
+		t.Fatalf("failed to delete call job with error: %v", err)
+	}
+	expected := []string{"schedule.getAll", "schedule.delete", "job.delete"}
+	if !reflect.DeepEqual(rpc.methods(), expected) {
+		t.Errorf("expected calls %v but received %v", expected, rpc.methods())
+	}
+	if id := rpc.callsTo("schedule.delete")[0].params["id"]; id != "schedule-1" {
+		t.Errorf("expected only the job's schedule to be deleted but received: %v", id)
+	}
+}
+
+func TestRunCallJob(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	if err := c.RunCallJob("job-1"); err != nil {
+		t.Fatalf("failed to run call job with error: %v", err)
+	}
+	params := rpc.callsTo("job.runSequence")[0].params
+	if !reflect.DeepEqual(params["idSequence"], []interface{}{"job-1"}) {
+		t.Errorf("expected job-1 to be run but received: %v", params)
+	}
+}
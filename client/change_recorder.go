@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChangeRecord describes a call of the client which changed the state of
+// XO or XAPI.
+type ChangeRecord struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	// Type and id of the object the call changed, as far as they can be
+	// told from the method and its params. The id of created objects is
+	// the one XO returned.
+	ObjectType string `json:"objectType,omitempty"`
+	ObjectId   string `json:"objectId,omitempty"`
+	// Params of the call with the values of credentials and other secrets
+	// replaced by `<redacted>`.
+	Params   map[string]interface{} `json:"params"`
+	Duration time.Duration          `json:"duration"`
+	// Email of the user of the session making the call
+	User string `json:"user,omitempty"`
+}
+
+// ChangeRecorder receives a ChangeRecord after every successful call
+// changing the state of XO or XAPI, e.g. to keep an audit trail of the
+// changes made by an automation independently of XO's audit plugin.
+//
+// Record is called synchronously after the call returned. An error doesn't
+// fail the call, it is logged and counted by FailedChangeRecords.
+type ChangeRecorder interface {
+	Record(ctx context.Context, record ChangeRecord) error
+}
+
+// changeRecording holds the state of the client's ChangeRecorder.
+type changeRecording struct {
+	// failures is accessed atomically and must stay the first field
+	// to be 64-bit aligned on 32-bit platforms.
+	failures uint64
+
+	recorder ChangeRecorder
+
+	mu   sync.Mutex
+	user string
+}
+
+func newChangeRecording(recorder ChangeRecorder) *changeRecording {
+	if recorder == nil {
+		return nil
+	}
+	return &changeRecording{recorder: recorder}
+}
+
+// recordChange sends the record of a successful call to the client's
+// ChangeRecorder when it has one and the call changed the state.
+func (c *Client) recordChange(method string, params, result interface{}, duration time.Duration) {
+	if c.changes == nil || isReadOnlyMethod(method) || strings.HasPrefix(method, "session.") {
+		return
+	}
+
+	record := ChangeRecord{
+		Time:     time.Now(),
+		Method:   method,
+		Params:   sanitizeParams(params),
+		Duration: duration,
+		User:     c.changeUser(),
+	}
+	if i := strings.LastIndex(method, "."); i > 0 {
+		record.ObjectType = method[:i]
+	}
+	if id, ok := record.Params["id"].(string); ok {
+		record.ObjectId = id
+	} else if v := reflect.ValueOf(result); v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.String {
+		record.ObjectId = v.Elem().String()
+	}
+
+	if err := c.changes.recorder.Record(context.Background(), record); err != nil {
+		atomic.AddUint64(&c.changes.failures, 1)
+		c.logf("[WARN] Failed to record the change made by rpc call `%s`: %v\n", method, err)
+	}
+}
+
+// changeUser returns the email of the session's user. It is only looked
+// up once since a client's session keeps the same user.
+func (c *Client) changeUser() string {
+	c.changes.mu.Lock()
+	defer c.changes.mu.Unlock()
+	if c.changes.user == "" {
+		if session, err := c.SessionInfo(); err == nil {
+			c.changes.user = session.User.Email
+		} else {
+			c.logf("[WARN] Failed to get the session's user to record a change: %v\n", err)
+		}
+	}
+	return c.changes.user
+}
+
+// FailedChangeRecords returns the number of changes the client's
+// ChangeRecorder failed to record.
+func (c *Client) FailedChangeRecords() uint64 {
+	if c.changes == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.changes.failures)
+}
+
+const redacted = "<redacted>"
+
+// Parts of the names of the params holding secrets
+var secretParams = []string{"password", "passphrase", "secret", "token", "privatekey"}
+
+func isSecretParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, secret := range secretParams {
+		if strings.Contains(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeParams returns a copy of the params of a call whose secrets are
+// redacted.
+func sanitizeParams(params interface{}) map[string]interface{} {
+	sanitized, ok := sanitizeParam("", params).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+	return sanitized
+}
+
+func sanitizeParam(name string, value interface{}) interface{} {
+	if isSecretParam(name) {
+		return redacted
+	}
+
+	switch v := value.(type) {
+	case pluginConfiguration:
+		return redacted
+	case map[string]interface{}:
+		sanitized := make(map[string]interface{}, len(v))
+		for key, e := range v {
+			sanitized[key] = sanitizeParam(key, e)
+		}
+		return sanitized
+	case []interface{}:
+		sanitized := make([]interface{}, len(v))
+		for i, e := range v {
+			sanitized[i] = sanitizeParam(name, e)
+		}
+		return sanitized
+	}
+
+	// Structs and typed collections are sanitized once decoded as json
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return redacted
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			return redacted
+		}
+		return sanitizeParam(name, decoded)
+	}
+	return value
+}
+
+// FileChangeRecorder is a ChangeRecorder appending each record as a line
+// of json to a file.
+type FileChangeRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileChangeRecorder opens the file at path to append records to it,
+// creating it when it doesn't exist.
+func NewFileChangeRecorder(path string) (*FileChangeRecorder, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileChangeRecorder{file: file}, nil
+}
+
+func (r *FileChangeRecorder) Record(ctx context.Context, record ChangeRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.file.Write(append(line, '\n'))
+	return err
+}
+
+func (r *FileChangeRecorder) Close() error {
+	return r.file.Close()
+}
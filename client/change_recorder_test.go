@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type memoryChangeRecorder struct {
+	records []ChangeRecord
+	err     error
+}
+
+func (r *memoryChangeRecorder) Record(ctx context.Context, record ChangeRecord) error {
+	r.records = append(r.records, record)
+	return r.err
+}
+
+func fakeUserRPC() *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "session.getUser":
+			return map[string]interface{}{"id": "admin-1", "email": "automation@example.com", "permission": "admin"}, nil
+		case "user.create":
+			return "user-1", nil
+		case "user.getAll":
+			return []map[string]interface{}{{"id": "user-1", "email": "alice@example.com"}}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestChangeRecorder_createUpdateDelete(t *testing.T) {
+	recorder := &memoryChangeRecorder{}
+	c := &Client{rpc: fakeUserRPC(), changes: newChangeRecording(recorder)}
+
+	user, err := c.CreateUser(User{Email: "alice@example.com", Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("failed to create user with error: %v", err)
+	}
+	var success bool
+	if err := c.Call("user.set", map[string]interface{}{"id": user.Id, "permission": "admin"}, &success); err != nil {
+		t.Fatalf("failed to update user with error: %v", err)
+	}
+	if err := c.DeleteUser(*user); err != nil {
+		t.Fatalf("failed to delete user with error: %v", err)
+	}
+
+	expected := []struct {
+		method, objectId string
+	}{
+		{"user.create", "user-1"},
+		{"user.set", "user-1"},
+		{"user.delete", "user-1"},
+	}
+	if len(recorder.records) != len(expected) {
+		t.Fatalf("expected a record for each change but received: %+v", recorder.records)
+	}
+	for i, record := range recorder.records {
+		if record.Method != expected[i].method || record.ObjectType != "user" || record.ObjectId != expected[i].objectId {
+			t.Errorf("expected record %d to be %s of user `%s` but received: %+v", i, expected[i].method, expected[i].objectId, record)
+		}
+		if record.User != "automation@example.com" {
+			t.Errorf("expected the session's user to be recorded but received: %+v", record)
+		}
+		if record.Time.IsZero() || record.Duration < 0 {
+			t.Errorf("expected the time and duration of the call to be recorded but received: %+v", record)
+		}
+	}
+	if password := recorder.records[0].Params["password"]; password != redacted {
+		t.Errorf("expected the password to be redacted but received: %v", password)
+	}
+	if email := recorder.records[0].Params["email"]; email != "alice@example.com" {
+		t.Errorf("expected the other params to be kept but received: %v", recorder.records[0].Params)
+	}
+}
+
+func TestSanitizeParams(t *testing.T) {
+	params := map[string]interface{}{
+		"id":            "remote-1",
+		"configuration": pluginConfiguration{"apiKey": "abc"},
+		"credentials": map[string]interface{}{
+			"username": "root",
+			"password": "secret",
+		},
+		"tokens":  []string{"a", "b"},
+		"sshKeys": []SshKey{{Title: "laptop", Key: "ssh-ed25519 AAAA"}},
+		"options": struct {
+			PrivateKey string `json:"privateKey"`
+			Port       int    `json:"port"`
+		}{"-----BEGIN", 22},
+	}
+
+	expected := map[string]interface{}{
+		"id":            "remote-1",
+		"configuration": redacted,
+		"credentials": map[string]interface{}{
+			"username": "root",
+			"password": redacted,
+		},
+		"tokens":  redacted,
+		"sshKeys": []interface{}{map[string]interface{}{"Title": "laptop", "Key": "ssh-ed25519 AAAA"}},
+		"options": map[string]interface{}{"privateKey": redacted, "port": float64(22)},
+	}
+	if sanitized := sanitizeParams(params); !reflect.DeepEqual(sanitized, expected) {
+		t.Errorf("expected the params to be sanitized to %v but received %v", expected, sanitized)
+	}
+	if params["credentials"].(map[string]interface{})["password"] != "secret" {
+		t.Errorf("expected the params of the call to be left untouched")
+	}
+}
+
+func TestChangeRecorder_skipsReadsAndFailedCalls(t *testing.T) {
+	recorder := &memoryChangeRecorder{}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "vm.delete" {
+			return nil, errors.New("VM_NOT_FOUND")
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc, changes: newChangeRecording(recorder)}
+
+	var result interface{}
+	c.Call("xo.getAllObjects", map[string]interface{}{}, &result)
+	c.Call("vm.delete", map[string]interface{}{"id": "vm-1"}, &result)
+	c.Call("session.signOut", map[string]interface{}{}, &result)
+
+	if len(recorder.records) != 0 {
+		t.Errorf("expected no record for reads and failed calls but received: %+v", recorder.records)
+	}
+}
+
+func TestChangeRecorder_failureDoesNotFailCall(t *testing.T) {
+	recorder := &memoryChangeRecorder{err: errors.New("disk full")}
+	c := &Client{rpc: fakeUserRPC(), changes: newChangeRecording(recorder)}
+
+	var success bool
+	if err := c.Call("user.delete", map[string]interface{}{"id": "user-1"}, &success); err != nil {
+		t.Errorf("expected the call to succeed despite the recorder failure but received: %v", err)
+	}
+	if failures := c.FailedChangeRecords(); failures != 1 {
+		t.Errorf("expected 1 failed record but received %d", failures)
+	}
+}
+
+func TestFileChangeRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.jsonl")
+	recorder, err := NewFileChangeRecorder(path)
+	if err != nil {
+		t.Fatalf("failed to open the recorder with error: %v", err)
+	}
+	c := &Client{rpc: fakeUserRPC(), changes: newChangeRecording(recorder)}
+
+	var success bool
+	for _, id := range []string{"user-1", "user-2"} {
+		if err := c.Call("user.delete", map[string]interface{}{"id": id}, &success); err != nil {
+			t.Fatalf("failed to delete user with error: %v", err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("failed to close the recorder with error: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open the records with error: %v", err)
+	}
+	defer f.Close()
+	ids := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record ChangeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("failed to decode record `%s` with error: %v", scanner.Text(), err)
+		}
+		ids = append(ids, record.ObjectId)
+	}
+	if !reflect.DeepEqual(ids, []string{"user-1", "user-2"}) {
+		t.Errorf("expected a line per record but received records of %v", ids)
+	}
+}
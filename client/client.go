@@ -6,10 +6,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"reflect"
+	"strings"
 	"time"
 
 	gorillawebsocket "github.com/gorilla/websocket"
@@ -25,26 +29,97 @@ const (
 
 type XOClient interface {
 	GetObjectsWithTags(tags []string) ([]Object, error)
+	GetObjectById(id string) (interface{}, error)
+	GetXapiRef(objectId string) (string, error)
+	FindByXapiRef(ref string) (objectId string, objectType string, err error)
+	GetAllObjectsOfType(obj XoObject, response interface{}) error
+	GetAllObjectsOfTypeWithOptions(obj XoObject, response interface{}, opts GetAllObjectsOptions) error
+	Exists(objectType, id string) (bool, error)
+	ExistsVm(id string) (bool, error)
+	ExistsHost(id string) (bool, error)
+	ExistsNetwork(id string) (bool, error)
+	ExistsVdi(id string) (bool, error)
+
+	GetPoolOfVm(vm Vm) (*Pool, error)
+	GetHostOfVm(vm Vm) (*Host, error)
+	GetSrOfVdi(vdi VDI) (*StorageRepository, error)
+	GetPifsOfNetwork(network Network) ([]PIF, error)
+	GetPoolHosts(poolId string) ([]Host, error)
+	GetHostPool(hostId string) (*Pool, error)
+	ExportInventorySnapshot(ctx context.Context, w io.Writer, opts SnapshotOptions) error
 
 	CreateVm(vmReq Vm, d time.Duration) (*Vm, error)
 	GetVm(vmReq Vm) (*Vm, error)
 	GetVms(vm Vm) ([]Vm, error)
+	SearchVms(params SearchParams) (*VmSearchResult, error)
 	UpdateVm(vmReq Vm) (*Vm, error)
+	UpdateVmWithOptions(vmReq Vm, opts UpdateVmOptions) (*Vm, error)
+	CreateLinkedClone(vmId, name string) (*Vm, error)
+	RenameVm(id, nameLabel string, opts SetMetadataOptions) error
+	SetVmDescription(id, description string, opts SetMetadataOptions) error
+	RenameHost(id, nameLabel string, opts SetMetadataOptions) error
+	SetHostDescription(id, description string, opts SetMetadataOptions) error
+	RenameSr(id, nameLabel string, opts SetMetadataOptions) error
+	SetSrDescription(id, description string, opts SetMetadataOptions) error
+	RenameNetwork(id, nameLabel string, opts SetMetadataOptions) error
+	SetNetworkDescription(id, description string, opts SetMetadataOptions) error
+	RenameVdi(id, nameLabel string, opts SetMetadataOptions) error
+	SetVdiDescription(id, description string, opts SetMetadataOptions) error
 	DeleteVm(id string) error
+	DeleteVmContext(ctx context.Context, id string, opts DeleteVmOptions) error
+	DeleteVmWithResult(ctx context.Context, id string, opts DeleteVmOptions) (*DeleteResult, error)
 	HaltVm(vmReq Vm) error
 	StartVm(id string) error
+	StartVmWithOptions(id string, opts StartVmOptions) error
+	StartVmWithDiagnostics(vmId string) (*StartResult, error)
+	MigrateVm(vmId, hostId string) error
+	MigrateVmWithOptions(vmId, hostId string, opts MigrateVmOptions) error
+	MigrateVmAsync(vmId, hostId string, opts MigrateVmOptions) (*PendingOperation, error)
+
+	GetPGPUs(hostId string) ([]PGPU, error)
+	GetVGPUTypes(pgpuId string) ([]VGPUType, error)
+	AttachVgpu(vmId, vgpuTypeId string) error
+	DetachVgpu(vmId, vgpuTypeId string) error
+	StartVmsInOrder(ctx context.Context, vmIds []string, respectDelays bool) error
+	StartVmsInOrderBestEffort(ctx context.Context, vmIds []string, respectDelays bool) error
+	SetVmSecureBootKeys(vmId string, keys SecureBootKeys) error
+	GetVmSecureBootReadiness(vmId string) (SecureBootReadiness, error)
+	GetVmStorageUsage(vmId string) (*VmStorageUsage, error)
+	GetGuestOsInventory(filter Vm) (map[string][]VmSummary, error)
 
 	GetCloudConfigByName(name string) ([]CloudConfig, error)
 	CreateCloudConfig(name, template string) (*CloudConfig, error)
+	EnsureCloudConfig(name, template string) (*CloudConfig, bool, error)
 	GetCloudConfig(id string) (*CloudConfig, error)
 	DeleteCloudConfig(id string) error
 	GetAllCloudConfigs() ([]CloudConfig, error)
 
 	GetHostById(id string) (host Host, err error)
+	GetHostWithInventory(id string) (*Host, error)
+	GetVmWithInventory(id string) (*Vm, error)
+	EnterMaintenanceMode(ctx context.Context, hostId string) error
+	ExitMaintenanceMode(hostId string) error
+	GetHostBlockDevices(hostId string) ([]BlockDevice, error)
+	FindUnusedDevices(hostId string) ([]BlockDevice, error)
+	GetHostDiskHealth(hostId string) ([]DiskHealth, error)
+	GetUnhealthyDisks(poolId string) ([]DiskHealth, error)
+	GetHostsNeedingReboot(poolId string) ([]Host, error)
+	GetHostsInEmergencyMode(poolId string) ([]Host, error)
+	RecoverPool(poolId string) error
+	GetHostTime(hostId string) (time.Time, error)
+	SetHostSyslogRemote(hostId string, server string) error
+	GetHostSyslogRemote(hostId string) (string, error)
+	SetPoolSyslogRemote(poolId, server string) (*PoolSyslogResult, error)
+	CheckMigrationCompatibility(vmId, targetHostId string) (*CompatibilityReport, error)
 	GetHostByName(nameLabel string) (hosts []Host, err error)
 
 	GetPools(pool Pool) ([]Pool, error)
 	GetPoolByName(name string) (pools []Pool, err error)
+	GetPoolById(id string) (*Pool, error)
+	GetPoolsWithTags(tags []string) ([]Pool, error)
+	UpdatePool(req UpdatePoolRequest) (*Pool, error)
+	InstallPoolPatches(poolId string, patches []string) error
+	InstallPoolPatchesAsync(poolId string, patches []string) (*PendingOperation, error)
 
 	GetSortedHosts(host Host, sortBy, sortOrder string) (hosts []Host, err error)
 
@@ -52,6 +127,7 @@ type XOClient interface {
 	GetResourceSets() ([]ResourceSet, error)
 	GetResourceSet(rsReq ResourceSet) ([]ResourceSet, error)
 	GetResourceSetById(id string) (*ResourceSet, error)
+	GetResourceSetUsage(setId string) (*ResourceSetUsage, error)
 	DeleteResourceSet(rsReq ResourceSet) error
 	AddResourceSetSubject(rsReq ResourceSet, subject string) error
 	AddResourceSetObject(rsReq ResourceSet, object string) error
@@ -61,42 +137,115 @@ type XOClient interface {
 	RemoveResourceSetLimit(rsReq ResourceSet, limit string) error
 
 	CreateUser(user User) (*User, error)
+	EnsureUser(user User) (*User, bool, error)
 	GetAllUsers() ([]User, error)
 	GetUser(userReq User) (*User, error)
+	GetUserByEmail(email string) (*User, error)
 	DeleteUser(userReq User) error
+	DeleteUserWithResult(user User) (*DeleteResult, error)
+
+	GetGroups() ([]Group, error)
+	GetGroup(groupReq Group) (*Group, error)
+	CreateGroup(name string) (*Group, error)
+	DeleteGroup(id string) error
+	DeleteGroupWithResult(id string) (*DeleteResult, error)
+	AddUserToGroup(groupId, userId string) error
+	RemoveUserFromGroup(groupId, userId string) error
+
+	ReconcileAccessModel(desired AccessModel) (*ReconcileReport, error)
+
+	GetTask(id string) (*Task, error)
+	CancelTask(id string) error
+	DestroyTask(id string) error
+	WaitForTask(ctx context.Context, id string) (*Task, error)
 
 	CreateNetwork(netReq Network) (*Network, error)
+	CreateVlanNetworks(poolId, pifId string, vlans []int, nameTemplate string) (*VlanNetworksReport, error)
+	EnsureNetwork(spec NetworkSpec) (*Network, bool, error)
 	GetNetwork(netReq Network) (*Network, error)
 	GetNetworks() ([]Network, error)
+	GetNetworkWithBonds(netReq Network) (*Network, error)
 	DeleteNetwork(id string) error
+	DeleteNetworkWithOptions(id string, opts DeleteNetworkOptions) error
+	DeleteNetworkWithResult(id string, opts DeleteNetworkOptions) (*DeleteResult, error)
 
 	GetPIF(pifReq PIF) (pifs []PIF, err error)
 	GetPIFByDevice(dev string, vlan int) ([]PIF, error)
 
 	GetStorageRepository(sr StorageRepository) ([]StorageRepository, error)
 	GetStorageRepositoryById(id string) (StorageRepository, error)
+	GetSrMultipathStatus(srId string) ([]MultipathStatus, error)
+	SetMultipathing(srId string, enabled bool) error
+	ForecastSrUsage(srId string, additionalDisks []DiskSpec) (*Forecast, error)
+	ForecastSrUsageWithOptions(srId string, additionalDisks []DiskSpec, opts ForecastSrUsageOptions) (*Forecast, error)
 
 	GetTemplate(template Template) ([]Template, error)
 
 	GetVDIs(vdiReq VDI) ([]VDI, error)
 	UpdateVDI(d Disk) error
+	SetVdiSharable(vdiId string, sharable bool) error
+	SetDiskQos(vbdId string, qos DiskQos) error
+	EnableVdiCbt(vdiId string) error
+	DisableVdiCbt(vdiId string, force bool) error
+	GetCbtStatusForVm(vmId string) (map[string]bool, error)
+	GetChangedBlocks(vdiId, baseSnapshotId string) (io.ReadCloser, error)
+	ExportVdiDelta(ctx context.Context, vdiId, baseSnapshotId string) (io.ReadCloser, error)
+	ExportVdiDeltaTo(ctx context.Context, vdiId, baseSnapshotId string, w io.Writer) error
+	VerifyVdiChecksum(ctx context.Context, vdiId string) (string, error)
+	ImportVdiContent(ctx context.Context, vdiId string, r io.Reader, format string) error
+	ImportVm(ctx context.Context, r io.Reader, opts ImportVmOptions) (string, error)
+	ImportVmAsync(ctx context.Context, r io.Reader, opts ImportVmOptions) (*PendingOperation, error)
+	ExportVm(ctx context.Context, vmId string, w io.Writer, opts ExportVmOptions) error
+	ExportVmAsync(ctx context.Context, vmId string, w io.Writer, opts ExportVmOptions) (*PendingOperation, error)
 
 	CreateAcl(acl Acl) (*Acl, error)
 	GetAcl(aclReq Acl) (*Acl, error)
 	DeleteAcl(acl Acl) error
 
+	GetPlugins() ([]Plugin, error)
+	ConfigurePlugin(id string, config map[string]interface{}) error
+	EnablePlugin(id string) error
+	DisablePlugin(id string) error
+	ConfigureEmailTransport(config EmailTransportConfig) error
+	ConfigureBackupReports(recipients []string, onFailureOnly bool) error
+	ConfigureBackupReportsForJobs(recipients []string, opts BackupReportsOptions) error
+	SendTestEmail(to string) error
+
 	AddTag(id, tag string) error
 	RemoveTag(id, tag string) error
+	SetTags(objectId string, tags []string) error
+	BulkRetag(changes []TagChange, dryRun bool) (*RetagResult, error)
+	GetTagReport(poolId string, requiredPrefixes []string) (*TagReport, error)
+	EnsureTagOnObject(objectId, tag string) (bool, error)
+	GetBackupJobs() ([]BackupJob, error)
+	GetBackupJob(id string) (*BackupJob, error)
+	UpdateBackupJob(job BackupJob) error
+	ImportVmBackup(backupId string, opts RestoreOptions) (*Vm, error)
+	GetCallJobs() ([]CallJob, error)
+	CreateCallJob(job CallJob) (*CallJob, error)
+	UpdateCallJob(job CallJob) error
+	DeleteCallJob(id string) error
+	RunCallJob(id string) error
+	SetVmBackupExclusion(vmId string, excluded bool) error
+	SetDiskBackupExclusion(vdiId string, excluded bool) error
 
 	GetDisks(vm *Vm) ([]Disk, error)
 	CreateDisk(vm Vm, d Disk) (string, error)
+	CreateVmDisk(vm Vm, d Disk) (*Disk, error)
+	DetachDisk(d Disk, opts DetachDiskOptions) error
 	DeleteDisk(vm Vm, d Disk) error
+	CopyVdi(vdiId, targetSrId, name string) (*VDI, error)
+	CopyVdiWithOptions(vdiId, targetSrId, name string, opts CopyVdiOptions) (*VDI, error)
 	ConnectDisk(d Disk) error
 	DisconnectDisk(d Disk) error
 
 	GetVIF(vifReq *VIF) (*VIF, error)
 	GetVIFs(vm *Vm) ([]VIF, error)
+	GetVIFsWithFilter(filter VIFFilter) ([]VIF, error)
+	GetVIFsByNetwork(networkId string) ([]VIF, error)
+	GetVIFByMac(mac string) (*VIF, error)
 	CreateVIF(vm *Vm, vif *VIF) (*VIF, error)
+	UpdateVIF(vif *VIF) (*VIF, error)
 	DeleteVIF(vifReq *VIF) (err error)
 	DisconnectVIF(vifReq *VIF) (err error)
 	ConnectVIF(vifReq *VIF) (err error)
@@ -104,10 +253,43 @@ type XOClient interface {
 	GetCdroms(vm *Vm) ([]Disk, error)
 	EjectCd(id string) error
 	InsertCd(vmId, cdId string) error
+	FindGuestToolsIso(poolId string) (*VDI, error)
+	InstallGuestToolsCd(vmId string) error
+	InstallGuestToolsCdWithOptions(vmId string, opts InstallGuestToolsCdOptions) error
+	WaitForGuestTools(vmId string, timeout time.Duration) error
+	WaitForStableVm(ctx context.Context, vmId string, quiet time.Duration) (*Vm, error)
+	VmUptime(vmId string) (time.Duration, error)
+
+	RawNotifications(ctx context.Context) (<-chan RawNotification, error)
+	Subscribe(ctx context.Context) (<-chan ObjectEvent, error)
+	DroppedNotifications() uint64
+	FailedChangeRecords() uint64
+
+	SignOut() error
+	SessionInfo() (*Session, error)
+	GetCurrentPermissions() (*PermissionSummary, error)
+	RequireAdmin() error
+	Close() error
 }
 
 type Client struct {
-	rpc jsonrpc2.JSONRPC2
+	rpc        jsonrpc2.JSONRPC2
+	url        string
+	httpClient *http.Client
+	// Overrides url and httpClient when the endpoint serving the client
+	// can change, see MultiClient
+	endpoint func() (string, *http.Client)
+	notifier *notifier
+
+	skipValidation bool
+	requireAdmin   bool
+	timeout        time.Duration
+	maxRetries     int
+	retryJitter    bool
+	logger         *log.Logger
+	dryRun         bool
+	changes        *changeRecording
+	interceptors   []CallInterceptor
 }
 
 type Config struct {
@@ -115,6 +297,51 @@ type Config struct {
 	Username           string
 	Password           string
 	InsecureSkipVerify bool
+
+	// Authentication token used to sign in instead of the username and
+	// password when set.
+	Token string
+	// TLS configuration of the connections to XO. InsecureSkipVerify
+	// takes precedence over the one of this configuration.
+	TLSConfig *tls.Config
+	// Timeout of the connection to XO and of each api call, no timeout
+	// when 0. File transfers aren't limited.
+	Timeout time.Duration
+	// Number of times read only calls are retried when they fail without
+	// an answer from XO, e.g. because of a network error or a timeout.
+	MaxRetries int
+	// Wait a random delay between 0 and the backoff's delay before each
+	// retry, and between the checks of a MultiClient's primary endpoint,
+	// so that clients failing at the same time, e.g. after a restart of
+	// XO, don't try again in lockstep.
+	RetryJitter bool
+	// Logger of the messages about the client's calls, the standard
+	// logger when nil.
+	Logger *log.Logger
+
+	// Transport used to talk to XO, either TransportJsonRpc or
+	// TransportRest. When empty, the json rpc api is used and the REST api
+	// only when the websocket connection can't be established.
+	Transport string
+
+	// Send create and update requests to XO without validating them
+	// first, e.g. to observe how XO handles invalid requests.
+	SkipValidation bool
+
+	// Check that the session's user is an admin before calling the
+	// methods XO restricts to admins, failing early with an
+	// InsufficientPermissionsError rather than once a change is half
+	// done. Each check costs a session.getUser call.
+	RequireAdmin bool
+
+	// Recorder of every successful call changing the state of XO or XAPI,
+	// none when nil.
+	ChangeRecorder ChangeRecorder
+
+	// Interceptors wrapping every api call, the first one being the
+	// outermost. They run around the client's own handling of the calls:
+	// the admin checks, dry run, change recording, retries and logging.
+	CallInterceptors []CallInterceptor
 }
 
 var dialer = gorillawebsocket.Dialer{
@@ -122,6 +349,11 @@ var dialer = gorillawebsocket.Dialer{
 	WriteBufferSize: MaxMessageSize,
 }
 
+// GetConfigFromEnv reads the configuration of the client from the XOA_URL,
+// XOA_USER, XOA_PASSWORD, XOA_TOKEN and XOA_INSECURE environment
+// variables. Clients sign in with XOA_TOKEN when it is set, even along
+// with XOA_USER and XOA_PASSWORD. Missing variables are left empty, see
+// LoadConfigFromEnv to have them reported.
 func GetConfigFromEnv() Config {
 	var url string
 	var username string
@@ -143,21 +375,120 @@ func GetConfigFromEnv() Config {
 		Url:                url,
 		Username:           username,
 		Password:           password,
+		Token:              os.Getenv("XOA_TOKEN"),
 		InsecureSkipVerify: insecure,
 	}
 }
 
 func NewClient(config Config) (XOClient, error) {
-	url := config.Url
-	username := config.Username
-	password := config.Password
-	skipVerify := config.InsecureSkipVerify
+	if err := checkTransport(config); err != nil {
+		return nil, err
+	}
+
+	n := newNotifier()
+	httpClient := newHttpClient(config)
+	rpc, err := dialTransport(config, n, httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		rpc:            rpc,
+		url:            config.Url,
+		httpClient:     httpClient,
+		notifier:       n,
+		skipValidation: config.SkipValidation,
+		requireAdmin:   config.RequireAdmin,
+		timeout:        config.Timeout,
+		maxRetries:     config.MaxRetries,
+		retryJitter:    config.RetryJitter,
+		logger:         config.Logger,
+		changes:        newChangeRecording(config.ChangeRecorder),
+		interceptors:   config.CallInterceptors,
+	}, nil
+}
 
-	if skipVerify {
-		dialer.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+func checkTransport(config Config) error {
+	switch config.Transport {
+	case "", TransportJsonRpc, TransportRest:
+		return nil
 	}
+	return errors.New(fmt.Sprintf("unknown transport `%s`, must be `%s` or `%s`", config.Transport, TransportJsonRpc, TransportRest))
+}
 
-	ws, _, err := dialer.Dial(fmt.Sprintf("%s/api/", url), http.Header{})
+// dialTransport connects to XO with the transport of the config.
+// Notifications received on the json rpc connection are dispatched to n.
+func dialTransport(config Config, n *notifier, httpClient *http.Client) (jsonrpc2.JSONRPC2, error) {
+	var rpc jsonrpc2.JSONRPC2
+	c, err := connect(config, n)
+	switch config.Transport {
+	case "":
+		rpc = c
+		if err == nil {
+			break
+		}
+		var rpcErr *jsonrpc2.Error
+		if errors.As(err, &rpcErr) {
+			return nil, err
+		}
+
+		rest := newRestRPC(config, httpClient, nil)
+		if restErr := rest.ping(context.Background()); restErr != nil {
+			return nil, err
+		}
+		log.Printf("[WARN] Failed to connect to the XO json rpc api, using the REST api instead: %v\n", err)
+		rpc, err = rest, nil
+	case TransportJsonRpc:
+		rpc = c
+	case TransportRest:
+		if err != nil {
+			rest := newRestRPC(config, httpClient, nil)
+			if restErr := rest.ping(context.Background()); restErr != nil {
+				return nil, restErr
+			}
+			rpc, err = rest, nil
+		} else {
+			rpc = newRestRPC(config, httpClient, c)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rpc, nil
+}
+
+func newHttpClient(config Config) *http.Client {
+	httpClient := &http.Client{}
+	if tlsConfig := newTLSConfig(config); tlsConfig != nil {
+		httpClient.Transport = &http.Transport{
+			TLSClientConfig: tlsConfig,
+		}
+	}
+	return httpClient
+}
+
+// newTLSConfig returns the TLS configuration of the connections to XO, nil
+// for the default one.
+func newTLSConfig(config Config) *tls.Config {
+	if !config.InsecureSkipVerify {
+		return config.TLSConfig
+	}
+	if config.TLSConfig == nil {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	tlsConfig := config.TLSConfig.Clone()
+	tlsConfig.InsecureSkipVerify = true
+	return tlsConfig
+}
+
+// connect opens the websocket connection to XO and signs in. Notifications
+// received on the connection are dispatched to n.
+func connect(config Config, n *notifier) (*jsonrpc2.Conn, error) {
+	d := dialer
+	d.TLSClientConfig = newTLSConfig(config)
+	d.HandshakeTimeout = config.Timeout
+
+	ws, _, err := d.Dial(fmt.Sprintf("%s/api/", config.Url), http.Header{})
 
 	if err != nil {
 		return nil, err
@@ -165,34 +496,87 @@ func NewClient(config Config) (XOClient, error) {
 
 	objStream := websocket.NewObjectStream(ws)
 	var h jsonrpc2.Handler
-	h = &handler{}
+	h = &handler{notifier: n}
 	c := jsonrpc2.NewConn(context.Background(), objStream, h)
 
-	reqParams := map[string]interface{}{
-		"email":    username,
-		"password": password,
-	}
+	method, reqParams := signInParams(config)
 	var reply signInResponse
-	err = c.Call(context.Background(), "session.signInWithPassword", reqParams, &reply)
+	err = c.Call(context.Background(), method, reqParams, &reply)
 	if err != nil {
+		c.Close()
 		return nil, err
 	}
-	return &Client{
-		rpc: c,
-	}, nil
+	return c, nil
+}
+
+// signInParams returns the method and params signing in with the token of
+// the config if any, with its username and password otherwise.
+func signInParams(config Config) (string, map[string]interface{}) {
+	if config.Token != "" {
+		return "session.signInWithToken", map[string]interface{}{
+			"token": config.Token,
+		}
+	}
+	return "session.signInWithPassword", map[string]interface{}{
+		"email":    config.Username,
+		"password": config.Password,
+	}
 }
 
+// Delays between the retries of a call, growing from the initial one up
+// to the max.
+var (
+	retryInitialDelay = time.Second
+	retryMaxDelay     = 5 * time.Second
+)
+
+// Source of the retry jitter, replaced by a seeded one in tests
+var retryJitterRand *rand.Rand
+
+// sleepRetryDelay waits for the delay before a retry, or returns the error
+// of ctx once it is done. Replaced in tests.
+var sleepRetryDelay = func(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Call makes an api call through the interceptors of the client, see
+// CallInterceptor.
 func (c *Client) Call(method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
-	err := c.rpc.Call(context.Background(), method, params, result, opt...)
-	var callRes interface{}
-	t := reflect.TypeOf(result)
-	if t == nil || t.Kind() != reflect.Ptr {
-		callRes = result
-	} else {
-		callRes = reflect.ValueOf(result).Elem()
+	return c.callContext(context.Background(), method, params, result, opt...)
+}
+
+// callContext is Call for the methods taking a context, the call is
+// abandoned when ctx is done.
+func (c *Client) callContext(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	call := func(ctx context.Context, method string, params, result interface{}) error {
+		return c.call(ctx, method, params, result, opt...)
 	}
-	log.Printf("[TRACE] Made rpc call `%s` with params: %v and received %+v: result with error: %v\n", method, params, callRes, err)
+	interceptors := append(append([]CallInterceptor{}, c.interceptors...), c.builtinInterceptors()...)
+	return chainInterceptors(call, interceptors...)(ctx, method, params, result)
+}
 
+// isRetryable reports whether a failed call can safely be made again:
+// the method must not change any state and XO must not have answered.
+func isRetryable(method string, err error) bool {
+	var rpcErr *jsonrpc2.Error
+	return isReadOnlyMethod(method) && !errors.As(err, &rpcErr)
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	err := c.rpc.Call(ctx, method, params, result, opt...)
 	if err != nil {
 		rpcErr, ok := err.(*jsonrpc2.Error)
 
@@ -206,11 +590,104 @@ func (c *Client) Call(method string, params, result interface{}, opt ...jsonrpc2
 			return err
 		}
 
-		return errors.New(fmt.Sprintf("%s: %s", err, *data))
+		return fmt.Errorf("%w: %s", err, *data)
 	}
 	return nil
 }
 
+func (c *Client) logf(format string, v ...interface{}) {
+	if c.logger != nil {
+		c.logger.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+// XO serves file transfers (imports and exports) over plain HTTP(S) on
+// the same host as the websocket api. The methods that need this receive
+// a `$sendTo` or `$getFrom` path from the rpc call which is resolved
+// against the client url.
+func transferUrl(url, path string) string {
+	if strings.HasPrefix(url, "ws") {
+		url = "http" + strings.TrimPrefix(url, "ws")
+	}
+	return strings.TrimSuffix(url, "/") + path
+}
+
+// transferEndpoint returns the url and the http client of the XO endpoint
+// currently serving the client.
+func (c *Client) transferEndpoint() (string, *http.Client) {
+	if c.endpoint != nil {
+		return c.endpoint()
+	}
+	if c.httpClient == nil {
+		return c.url, http.DefaultClient
+	}
+	return c.url, c.httpClient
+}
+
+// upload streams body to the XO http handler found at path. size should be
+// -1 when the length of body is not known ahead of time. When result isn't
+// nil, it receives the result of the JSON-RPC response of the handler.
+func (c *Client) upload(ctx context.Context, path string, body io.Reader, size int64, result interface{}) error {
+	if c.dryRun {
+		c.logf("[INFO] Dry run, skipping the upload to `%s`\n", path)
+		return nil
+	}
+	url, httpClient := c.transferEndpoint()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, transferUrl(url, path), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	log.Printf("[TRACE] Uploaded content to `%s` and received status: %s\n", path, resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.New(fmt.Sprintf("upload to `%s` failed with status %s: %s", path, resp.Status, msg))
+	}
+	if result == nil {
+		return nil
+	}
+
+	response := struct {
+		Result json.RawMessage `json:"result"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return errors.New(fmt.Sprintf("failed to decode the response of the upload to `%s`: %v", path, err))
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// download streams the content served by the XO http handler found at
+// path. The caller must close the returned body.
+func (c *Client) download(ctx context.Context, path string) (io.ReadCloser, error) {
+	url, httpClient := c.transferEndpoint()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, transferUrl(url, path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[TRACE] Downloading content from `%s` and received status: %s\n", path, resp.Status)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.New(fmt.Sprintf("download from `%s` failed with status %s: %s", path, resp.Status, msg))
+	}
+	return resp.Body, nil
+}
+
 type XoObject interface {
 	Compare(obj interface{}) bool
 }
@@ -238,6 +715,8 @@ func (c *Client) getObjectTypeFilter(obj XoObject) map[string]interface{} {
 		xoApiType = "VBD"
 	case VDI:
 		xoApiType = "VDI"
+	case Task:
+		xoApiType = "task"
 	default:
 		panic(fmt.Sprintf("XO client does not support type: %T", t))
 	}
@@ -248,13 +727,62 @@ func (c *Client) getObjectTypeFilter(obj XoObject) map[string]interface{} {
 	}
 }
 
+// Deprecated: use GetAllObjectsOfTypeWithOptions.
 func (c *Client) GetAllObjectsOfType(obj XoObject, response interface{}) error {
-	return c.Call("xo.getAllObjects", c.getObjectTypeFilter(obj), response)
+	return c.GetAllObjectsOfTypeWithOptions(obj, response, GetAllObjectsOptions{})
+}
+
+type GetAllObjectsOptions struct {
+	// Json names of the fields of the objects to decode, e.g. `name_label`,
+	// the other fields are left zero-valued. Every field is decoded when
+	// empty.
+	Fields []string
+}
+
+// GetAllObjectsOfTypeWithOptions is GetAllObjectsOfType decoding only some
+// fields of the objects. XO can't limit the fields it returns so whole
+// objects are still sent, the other fields are dropped before decoding.
+func (c *Client) GetAllObjectsOfTypeWithOptions(obj XoObject, response interface{}, opts GetAllObjectsOptions) error {
+	if len(opts.Fields) == 0 {
+		return c.Call("xo.getAllObjects", c.getObjectTypeFilter(obj), response)
+	}
+
+	known := jsonFieldNames(reflect.TypeOf(obj))
+	unknown := []string{}
+	for _, field := range opts.Fields {
+		if !stringInSlice(field, known) {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		return errors.New(fmt.Sprintf("%T has no field with json name %v", obj, unknown))
+	}
+
+	var objsRes map[string]map[string]json.RawMessage
+	err := c.Call("xo.getAllObjects", c.getObjectTypeFilter(obj), &objsRes)
+	if err != nil {
+		return err
+	}
+
+	for _, fields := range objsRes {
+		for name := range fields {
+			if !stringInSlice(name, opts.Fields) {
+				delete(fields, name)
+			}
+		}
+	}
+	data, err := json.Marshal(objsRes)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, response)
 }
 
 func (c *Client) FindFromGetAllObjects(obj XoObject) (interface{}, error) {
+	// Objects are decoded straight into their struct, decoding them into
+	// interface{} first would round sizes above 2^53 bytes
 	var objsRes struct {
-		Objects map[string]interface{} `json:"-"`
+		Objects map[string]json.RawMessage `json:"-"`
 	}
 	err := c.GetAllObjectsOfType(obj, &objsRes.Objects)
 	if err != nil {
@@ -265,17 +793,8 @@ func (c *Client) FindFromGetAllObjects(obj XoObject) (interface{}, error) {
 	t := reflect.TypeOf(obj)
 	objs := reflect.MakeSlice(reflect.SliceOf(t), 0, 0)
 	for _, resObj := range objsRes.Objects {
-		v, ok := resObj.(map[string]interface{})
-		if !ok {
-			return obj, errors.New("Could not coerce interface{} into map")
-		}
-		b, err := json.Marshal(v)
-
-		if err != nil {
-			return objs, err
-		}
 		value := reflect.New(t)
-		err = json.Unmarshal(b, value.Interface())
+		err = json.Unmarshal(resObj, value.Interface())
 		if err != nil {
 			return objs, err
 		}
@@ -293,11 +812,112 @@ func (c *Client) FindFromGetAllObjects(obj XoObject) (interface{}, error) {
 	return objs.Interface(), nil
 }
 
-type handler struct{}
+// xoObjectTypes maps XO types to the struct the client decodes them into.
+var xoObjectTypes = map[string]reflect.Type{
+	"network":     reflect.TypeOf(Network{}),
+	"PIF":         reflect.TypeOf(PIF{}),
+	"pool":        reflect.TypeOf(Pool{}),
+	"host":        reflect.TypeOf(Host{}),
+	"SR":          reflect.TypeOf(StorageRepository{}),
+	"VM":          reflect.TypeOf(Vm{}),
+	"VM-template": reflect.TypeOf(Template{}),
+	"VIF":         reflect.TypeOf(VIF{}),
+	"VBD":         reflect.TypeOf(VBD{}),
+	"VDI":         reflect.TypeOf(VDI{}),
+	"task":        reflect.TypeOf(Task{}),
+	"PBD":         reflect.TypeOf(PBD{}),
+}
+
+// GetObjectById returns the object with the given id decoded into the
+// struct of its type, e.g. a Vm for a VM, without listing every object of
+// the type. A NotFound error is returned when no object has this id.
+func (c *Client) GetObjectById(id string) (interface{}, error) {
+	var objsRes map[string]json.RawMessage
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"id": id,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := objsRes[id]
+	if !ok {
+		return nil, NotFound{Query: Object{Id: id}}
+	}
+
+	var object struct {
+		Type string `json:"type"`
+	}
+	err = json.Unmarshal(obj, &object)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := xoObjectTypes[object.Type]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("XO client does not support type: %s", object.Type))
+	}
+
+	value := reflect.New(t)
+	err = json.Unmarshal(obj, value.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return value.Elem().Interface(), nil
+}
+
+// Exists reports whether an object of the XO type (e.g. `VM` or `host`)
+// has the given id. Unlike the Get methods, a missing object isn't an
+// error: an error means the existence of the object is unknown. XO can't
+// limit the fields it returns so the object is still sent but it isn't
+// decoded.
+func (c *Client) Exists(objectType, id string) (bool, error) {
+	var objsRes map[string]struct{}
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"id":   id,
+			"type": objectType,
+		},
+		"limit": 1,
+	}
+	err := c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := objsRes[id]
+	return ok, nil
+}
+
+func (c *Client) ExistsVm(id string) (bool, error) {
+	return c.Exists("VM", id)
+}
+
+func (c *Client) ExistsHost(id string) (bool, error) {
+	return c.Exists("host", id)
+}
+
+func (c *Client) ExistsNetwork(id string) (bool, error) {
+	return c.Exists("network", id)
+}
+
+func (c *Client) ExistsVdi(id string) (bool, error) {
+	return c.Exists("VDI", id)
+}
+
+type handler struct {
+	notifier *notifier
+}
 
 func (h *handler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
-	// We do not react to json rpc notifications and are only acting as a client.
-	// So there is no need to handle these callbacks.
+	// We are only acting as a client so the only requests we receive are
+	// notifications. These are passed along to whoever subscribed to them.
+	if !req.Notif {
+		return
+	}
+	h.notifier.dispatch(req)
 }
 
 type signInResponse struct {
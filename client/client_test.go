@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/sourcegraph/jsonrpc2"
@@ -26,6 +29,112 @@ func (rpc jsonRPCFail) Close() error {
 	return nil
 }
 
+type fakeRPCCall struct {
+	method string
+	params map[string]interface{}
+}
+
+// fakeRPC records every call made through it and answers them with the
+// result returned by handler. Params and results go through a json round
+// trip so tests observe exactly what would be sent over the wire.
+type fakeRPC struct {
+	mu      sync.Mutex
+	calls   []fakeRPCCall
+	handler func(method string, params map[string]interface{}) (interface{}, error)
+}
+
+func (rpc *fakeRPC) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	var p map[string]interface{}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	rpc.mu.Lock()
+	rpc.calls = append(rpc.calls, fakeRPCCall{method: method, params: p})
+	rpc.mu.Unlock()
+
+	if rpc.handler == nil {
+		return nil
+	}
+	res, err := rpc.handler(method, p)
+	if err != nil || res == nil || result == nil {
+		return err
+	}
+	b, err = json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, result)
+}
+
+func (rpc *fakeRPC) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	return nil
+}
+
+func (rpc *fakeRPC) Close() error {
+	return nil
+}
+
+func (rpc *fakeRPC) callsTo(method string) []fakeRPCCall {
+	rpc.mu.Lock()
+	defer rpc.mu.Unlock()
+	calls := []fakeRPCCall{}
+	for _, call := range rpc.calls {
+		if call.method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (rpc *fakeRPC) methods() []string {
+	rpc.mu.Lock()
+	defer rpc.mu.Unlock()
+	methods := []string{}
+	for _, call := range rpc.calls {
+		methods = append(methods, call.method)
+	}
+	return methods
+}
+
+// fakeGetAllObjects answers an xo.getAllObjects call the way XO does: every
+// property of the filter must match for an object to be returned.
+func fakeGetAllObjects(params map[string]interface{}, objects ...map[string]interface{}) map[string]interface{} {
+	filter, _ := params["filter"].(map[string]interface{})
+	res := map[string]interface{}{}
+	for _, obj := range objects {
+		matches := true
+		for k, v := range filter {
+			if !fakeFilterMatches(obj[k], v) {
+				matches = false
+			}
+		}
+		if matches {
+			res[obj["id"].(string)] = obj
+		}
+	}
+	return res
+}
+
+// fakeFilterMatches matches value against a value of an XO filter, either
+// a plain value or an `__or` of values.
+func fakeFilterMatches(value, pattern interface{}) bool {
+	if or, ok := pattern.(map[string]interface{}); ok {
+		alternatives, _ := or["__or"].([]interface{})
+		for _, alternative := range alternatives {
+			if fmt.Sprint(value) == fmt.Sprint(alternative) {
+				return true
+			}
+		}
+		return false
+	}
+	return fmt.Sprint(value) == fmt.Sprint(pattern)
+}
+
 func TestCall_withJsonRPC2Error(t *testing.T) {
 	var jsonRpcErr string = `{"errors":[{"code":null,"reason":"type","message":"must be string, but is object","property":"@.template"}]}`
 	rpcCode := 10
@@ -105,3 +214,154 @@ func TestCall_withNonJsonRPC2Error(t *testing.T) {
 		t.Errorf("Call method should return an error as is if not of type `jsonrpc2.Error`. Expected: %v received: %v", expectedErr, err)
 	}
 }
+
+func TestGetObjectById(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "web", "power_state": "Running"},
+			map[string]interface{}{"id": "host-1", "type": "host", "name_label": "xcp-1", "$pool": "pool-1"},
+			map[string]interface{}{"id": "sm-1", "type": "SM"},
+		), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	obj, err := c.GetObjectById("vm-1")
+	if err != nil {
+		t.Fatalf("failed to get object with error: %v", err)
+	}
+	vm, ok := obj.(Vm)
+	if !ok || vm.Id != "vm-1" || vm.NameLabel != "web" || vm.PowerState != PowerStateRunning {
+		t.Errorf("expected vm-1 to be returned as a Vm but received: %#v", obj)
+	}
+
+	obj, err = c.GetObjectById("host-1")
+	if host, ok := obj.(Host); err != nil || !ok || host.Pool != "pool-1" {
+		t.Errorf("expected host-1 to be returned as a Host but received %#v with error: %v", obj, err)
+	}
+
+	if filter := rpc.callsTo("xo.getAllObjects")[0].params["filter"]; !reflect.DeepEqual(filter, map[string]interface{}{"id": "vm-1"}) {
+		t.Errorf("expected objects to be filtered by id but received filter: %v", filter)
+	}
+
+	_, err = c.GetObjectById("missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing id but received: %v", err)
+	}
+
+	if _, err = c.GetObjectById("sm-1"); err == nil {
+		t.Errorf("expected an unsupported type to be reported")
+	}
+}
+
+func TestExists(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "vm-1", "type": "VM"},
+			map[string]interface{}{"id": "net-1", "type": "network"},
+		), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	tests := []struct {
+		exists func(id string) (bool, error)
+		id     string
+		found  bool
+	}{
+		{c.ExistsVm, "vm-1", true},
+		{c.ExistsNetwork, "net-1", true},
+		{c.ExistsVm, "vm-2", false},
+		// An object of another type with the same id
+		{c.ExistsHost, "vm-1", false},
+		{c.ExistsVdi, "vdi-1", false},
+	}
+	for _, test := range tests {
+		found, err := test.exists(test.id)
+		if err != nil || found != test.found {
+			t.Errorf("expected %s to exist: %t but received %t with error: %v", test.id, test.found, found, err)
+		}
+	}
+
+	params := rpc.callsTo("xo.getAllObjects")[0].params
+	expected := map[string]interface{}{"filter": map[string]interface{}{"id": "vm-1", "type": "VM"}, "limit": float64(1)}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected the VM to be filtered by id but received: %v", params)
+	}
+}
+
+func TestExists_serverError(t *testing.T) {
+	expectedErr := errors.New("connection reset by peer")
+	c := &Client{rpc: jsonRPCFail{err: expectedErr}}
+
+	found, err := c.ExistsVm("vm-1")
+	if err != expectedErr || found {
+		t.Errorf("expected the transport error to be returned but received %t with error: %v", found, err)
+	}
+}
+
+func TestGetAllObjectsOfTypeWithOptions_fields(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "web", "power_state": "Running", "$poolId": "pool-1", "tags": []string{"prod"}},
+			map[string]interface{}{"id": "host-1", "type": "host", "name_label": "host"},
+		), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	var vms map[string]Vm
+	err := c.GetAllObjectsOfTypeWithOptions(Vm{}, &vms, GetAllObjectsOptions{Fields: []string{"id", "name_label"}})
+	if err != nil {
+		t.Fatalf("failed to get the vms with error: %v", err)
+	}
+
+	expected := map[string]Vm{"vm-1": {Id: "vm-1", NameLabel: "web"}}
+	if !reflect.DeepEqual(vms, expected) {
+		t.Errorf("expected only the id and name of the vms to be decoded but received: %+v", vms)
+	}
+}
+
+func TestGetAllObjectsOfTypeWithOptions_unknownField(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc}
+
+	var vms map[string]Vm
+	err := c.GetAllObjectsOfTypeWithOptions(Vm{}, &vms, GetAllObjectsOptions{Fields: []string{"name_label", "nameLabel"}})
+	if err == nil || !strings.Contains(err.Error(), "nameLabel") {
+		t.Errorf("expected an error naming the unknown field but received: %v", err)
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected no call for an invalid projection but received: %v", rpc.methods())
+	}
+}
+
+func TestFindFromGetAllObjects_sizesAbove2Pow53(t *testing.T) {
+	// 2^53 + 1 can't be represented by a float64
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if filter := params["filter"].(map[string]interface{}); filter["type"] == "VDI" {
+			return json.RawMessage(`{"vdi-1": {"id": "vdi-1", "type": "VDI", "size": 9007199254740993, "usage": 9007199254740995}}`), nil
+		}
+		return json.RawMessage(`{"vm-1": {"id": "vm-1", "type": "VM", "memory": {"static": [0, 9007199254740993], "dynamic": [1, 9007199254740993], "size": 9007199254740993}}}`), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	vdis, err := c.GetVDIs(VDI{VDIId: "vdi-1"})
+	if err != nil || len(vdis) != 1 {
+		t.Fatalf("expected to find vdi-1 but received %+v with error: %v", vdis, err)
+	}
+	if vdis[0].Size != 1<<53+1 || vdis[0].Usage != 1<<53+3 {
+		t.Errorf("expected the size and usage of vdi-1 to be decoded exactly but received %d and %d", vdis[0].Size, vdis[0].Usage)
+	}
+
+	obj, err := c.GetObjectById("vm-1")
+	if err != nil {
+		t.Fatalf("failed to get vm-1 with error: %v", err)
+	}
+	memory := obj.(Vm).Memory
+	if memory.Size != 1<<53+1 || memory.Static[1] != 1<<53+1 || memory.Dynamic[1] != 1<<53+1 {
+		t.Errorf("expected the memory of vm-1 to be decoded exactly but received: %+v", memory)
+	}
+
+	b, err := json.Marshal(vdis[0])
+	if err != nil || !strings.Contains(string(b), `"size":9007199254740993`) {
+		t.Errorf("expected the size of vdi-1 to be encoded exactly but received %s with error: %v", b, err)
+	}
+}
@@ -82,6 +82,10 @@ func (c *Client) GetAllCloudConfigs() ([]CloudConfig, error) {
 }
 
 func (c *Client) CreateCloudConfig(name, template string) (*CloudConfig, error) {
+	if err := c.validateCreateCloudConfig(name, template); err != nil {
+		return nil, err
+	}
+
 	params := map[string]interface{}{
 		"name":     name,
 		"template": template,
@@ -0,0 +1,343 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateCloudConfig checks that a cloud config starting with
+// `#cloud-config` is a YAML mapping. Other user data, e.g. scripts, isn't
+// checked. The problems are returned as ValidationErrors whose messages
+// start with the line of the problem.
+func ValidateCloudConfig(template string) error {
+	v := &validator{}
+	v.cloudConfig("CloudConfig", template)
+	return v.err()
+}
+
+// ValidateCloudNetworkConfig checks the structure of a cloud-init network
+// config of version 1 or 2, such as the types of its devices and their
+// required keys. The problems are returned as ValidationErrors whose
+// messages start with the line of the problem.
+func ValidateCloudNetworkConfig(config string) error {
+	v := &validator{}
+	v.cloudNetworkConfig("CloudNetworkConfig", config)
+	return v.err()
+}
+
+func (v *validator) cloudConfig(field, template string) {
+	if !strings.HasPrefix(template, "#cloud-config") {
+		return
+	}
+
+	// XO replaces the {name} of the templates by the name of the VM, which
+	// would otherwise be read as a YAML mapping
+	root, err := parseYaml(strings.ReplaceAll(template, "{name}", "name"))
+	if err != nil {
+		v.yamlErrors(field, err)
+		return
+	}
+	if root != nil && root.Kind != yaml.MappingNode && root.Tag != "!!null" {
+		v.addf(field, "line %d: expected a mapping of cloud-init modules", root.Line)
+	}
+}
+
+func (v *validator) cloudNetworkConfig(field, config string) {
+	if config == "" {
+		return
+	}
+
+	root, err := parseYaml(config)
+	if err != nil {
+		v.yamlErrors(field, err)
+		return
+	}
+	if root == nil || root.Kind != yaml.MappingNode {
+		line := 1
+		if root != nil {
+			line = root.Line
+		}
+		v.addf(field, "line %d: expected a mapping with the `version` of the network config", line)
+		return
+	}
+	// The config may be nested in a `network` key like in netplan files
+	if network, ok := yamlValue(root, "network"); ok && len(root.Content) == 2 && network.Kind == yaml.MappingNode {
+		root = network
+	}
+
+	n := &networkConfigValidator{v: v, field: field}
+	version, ok := yamlValue(root, "version")
+	switch {
+	case !ok:
+		n.addf(root, "missing the `version` of the network config, 1 or 2")
+	case version.Value == "1":
+		n.v1(root)
+	case version.Value == "2":
+		n.v2(root)
+	default:
+		n.addf(version, "unsupported network config version `%s`, expected 1 or 2", version.Value)
+	}
+}
+
+// parseYaml returns the root node of the YAML document, nil when it is
+// empty. Duplicate keys are rejected like cloud-init does.
+func parseYaml(doc string) (*yaml.Node, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(doc), &document); err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return nil, nil
+	}
+	var value interface{}
+	if err := document.Decode(&value); err != nil {
+		return nil, err
+	}
+	return yamlResolve(document.Content[0]), nil
+}
+
+// yamlErrors adds the errors of the YAML parser, which start with their
+// line.
+func (v *validator) yamlErrors(field string, err error) {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, e := range typeErr.Errors {
+			v.addf(field, "%s", e)
+		}
+		return
+	}
+	v.addf(field, "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+}
+
+func yamlResolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// yamlValue returns the value of key in the mapping, including the
+// mappings merged into it with `<<`.
+func yamlValue(mapping *yaml.Node, key string) (*yaml.Node, bool) {
+	merged := []*yaml.Node{}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		k, value := mapping.Content[i], yamlResolve(mapping.Content[i+1])
+		switch {
+		case k.Value == key:
+			return value, true
+		case k.Value == "<<" && value.Kind == yaml.MappingNode:
+			merged = append(merged, value)
+		case k.Value == "<<" && value.Kind == yaml.SequenceNode:
+			for _, item := range value.Content {
+				merged = append(merged, yamlResolve(item))
+			}
+		}
+	}
+	for _, m := range merged {
+		if value, ok := yamlValue(m, key); ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// yamlKeys returns the keys of the mapping in the order of the document,
+// without the merge keys.
+func yamlKeys(mapping *yaml.Node) []string {
+	keys := []string{}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if key := mapping.Content[i].Value; key != "<<" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func yamlItems(sequence *yaml.Node) []*yaml.Node {
+	items := []*yaml.Node{}
+	for _, item := range sequence.Content {
+		items = append(items, yamlResolve(item))
+	}
+	return items
+}
+
+// networkConfigValidator reports the problems of a network config with
+// their line.
+type networkConfigValidator struct {
+	v     *validator
+	field string
+}
+
+func (n *networkConfigValidator) addf(node *yaml.Node, format string, a ...interface{}) {
+	n.v.addf(n.field, "line %d: %s", node.Line, fmt.Sprintf(format, a...))
+}
+
+// mapping reports whether node is a mapping, an error is added when it
+// isn't.
+func (n *networkConfigValidator) mapping(node *yaml.Node, what string) bool {
+	if node.Kind != yaml.MappingNode {
+		n.addf(node, "%s must be a mapping", what)
+		return false
+	}
+	return true
+}
+
+func (n *networkConfigValidator) sequence(node *yaml.Node, what string) bool {
+	if node.Kind != yaml.SequenceNode {
+		n.addf(node, "%s must be a list", what)
+		return false
+	}
+	return true
+}
+
+func (n *networkConfigValidator) requiredKeys(node *yaml.Node, what string, keys ...string) {
+	for _, key := range keys {
+		if value, ok := yamlValue(node, key); !ok || value.Tag == "!!null" {
+			n.addf(node, "%s requires `%s`", what, key)
+		}
+	}
+}
+
+// cloud-init reads YAML 1.1, where yes, no, on and off are booleans too
+var yamlBooleans = []string{"true", "false", "yes", "no", "on", "off"}
+
+func (n *networkConfigValidator) boolean(node *yaml.Node, what string) {
+	if node.Kind != yaml.ScalarNode || !stringInSlice(strings.ToLower(node.Value), yamlBooleans) {
+		n.addf(node, "%s must be a boolean, got `%s`", what, node.Value)
+	}
+}
+
+// Keys required by each type of config of a network config v1, a
+// nameserver may only set the search domains
+var networkConfigV1Types = map[string][]string{
+	"physical":   {"name"},
+	"bond":       {"name", "bond_interfaces"},
+	"bridge":     {"name", "bridge_interfaces"},
+	"vlan":       {"name", "vlan_link", "vlan_id"},
+	"infiniband": {"name"},
+	"nameserver": {},
+	"route":      {"destination", "gateway"},
+}
+
+var networkConfigV1SubnetTypes = []string{"dhcp", "dhcp4", "dhcp6", "static", "static6", "manual", "ipv6_dhcpv6-stateful", "ipv6_dhcpv6-stateless", "ipv6_slaac"}
+
+func (n *networkConfigValidator) v1(root *yaml.Node) {
+	config, ok := yamlValue(root, "config")
+	if !ok {
+		n.addf(root, "missing the `config` list of the network config")
+		return
+	}
+	if !n.sequence(config, "`config`") {
+		return
+	}
+
+	for _, item := range yamlItems(config) {
+		if !n.mapping(item, "a `config` entry") {
+			continue
+		}
+		t, ok := yamlValue(item, "type")
+		if !ok {
+			n.addf(item, "a `config` entry requires a `type`")
+			continue
+		}
+		required, ok := networkConfigV1Types[t.Value]
+		if !ok {
+			n.addf(t, "unknown config type `%s`, expected one of %v", t.Value, sortedKeys(networkConfigV1Types))
+			continue
+		}
+		what := fmt.Sprintf("config type `%s`", t.Value)
+		n.requiredKeys(item, what, required...)
+
+		subnets, ok := yamlValue(item, "subnets")
+		if !ok || !n.sequence(subnets, "`subnets`") {
+			continue
+		}
+		for _, subnet := range yamlItems(subnets) {
+			if !n.mapping(subnet, "a subnet") {
+				continue
+			}
+			t, ok := yamlValue(subnet, "type")
+			if !ok {
+				n.addf(subnet, "a subnet requires a `type`")
+				continue
+			}
+			if !stringInSlice(t.Value, networkConfigV1SubnetTypes) {
+				n.addf(t, "unknown subnet type `%s`, expected one of %v", t.Value, networkConfigV1SubnetTypes)
+				continue
+			}
+			if t.Value == "static" || t.Value == "static6" {
+				n.requiredKeys(subnet, fmt.Sprintf("subnet type `%s`", t.Value), "address")
+			}
+		}
+	}
+}
+
+// Keys required by each kind of device of a network config v2, the other
+// keys are left to netplan
+var networkConfigV2Devices = map[string][]string{
+	"ethernets": {},
+	"bonds":     {},
+	"bridges":   {},
+	"vlans":     {"id", "link"},
+	"wifis":     {"access-points"},
+}
+
+func (n *networkConfigValidator) v2(root *yaml.Node) {
+	for _, section := range yamlKeys(root) {
+		required, ok := networkConfigV2Devices[section]
+		if !ok {
+			continue
+		}
+		devices, _ := yamlValue(root, section)
+		if !n.mapping(devices, fmt.Sprintf("`%s`", section)) {
+			continue
+		}
+
+		for _, id := range yamlKeys(devices) {
+			device, _ := yamlValue(devices, id)
+			what := fmt.Sprintf("`%s` of `%s`", id, section)
+			if !n.mapping(device, what) {
+				continue
+			}
+			n.requiredKeys(device, what, required...)
+			n.v2Device(device, what)
+		}
+	}
+}
+
+func (n *networkConfigValidator) v2Device(device *yaml.Node, what string) {
+	for _, key := range []string{"dhcp4", "dhcp6", "optional", "critical", "accept-ra", "wakeonlan"} {
+		if value, ok := yamlValue(device, key); ok {
+			n.boolean(value, fmt.Sprintf("`%s` of %s", key, what))
+		}
+	}
+	for _, key := range []string{"addresses", "interfaces"} {
+		if value, ok := yamlValue(device, key); ok {
+			n.sequence(value, fmt.Sprintf("`%s` of %s", key, what))
+		}
+	}
+	if _, ok := yamlValue(device, "set-name"); ok {
+		if _, ok := yamlValue(device, "match"); !ok {
+			n.addf(device, "`set-name` of %s requires a `match`", what)
+		}
+	}
+
+	if nameservers, ok := yamlValue(device, "nameservers"); ok && n.mapping(nameservers, fmt.Sprintf("`nameservers` of %s", what)) {
+		for _, key := range []string{"addresses", "search"} {
+			if value, ok := yamlValue(nameservers, key); ok {
+				n.sequence(value, fmt.Sprintf("`nameservers.%s` of %s", key, what))
+			}
+		}
+	}
+
+	if routes, ok := yamlValue(device, "routes"); ok && n.sequence(routes, fmt.Sprintf("`routes` of %s", what)) {
+		for _, route := range yamlItems(routes) {
+			if n.mapping(route, fmt.Sprintf("a route of %s", what)) {
+				n.requiredKeys(route, fmt.Sprintf("a route of %s", what), "to")
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const validNetworkConfigV2 = `network:
+  version: 2
+  ethernets:
+    eth0:
+      match:
+        macaddress: "02:16:3e:00:00:01"
+      set-name: eth0
+      dhcp4: false
+      addresses: [10.0.20.5/24, "fd00::5/64"]
+      routes:
+      - to: default
+        via: 10.0.20.1
+      nameservers:
+        addresses:
+          - 10.0.20.1
+        search: [example.com]
+  vlans:
+    vlan20:
+      id: 20
+      link: eth0
+      dhcp4: yes # tenant network
+`
+
+// Keys unknown to the validator are left to netplan
+const validNetworkConfigV2Netplan = `version: 2
+ethernets:
+  eth0: &eth
+    dhcp4: true
+    ipv6-mtu: 1400
+    link-local: [ipv6]
+vlans:
+  vlan30:
+    <<: *eth
+    id: 30
+    link: eth0
+`
+
+const validNetworkConfigV1 = `version: 1
+config:
+  - type: physical
+    name: eth0
+    mac_address: '02:16:3e:00:00:01'
+    subnets:
+      - type: static
+        address: 10.0.20.5/24
+        gateway: 10.0.20.1
+  - type: infiniband
+    name: ib0
+    mac_address: 'a0:00:02:20:fe:80:00:00:00:00:00:00:ec:0d:9a:03:00:15:e2:c1'
+    subnets:
+      - type: dhcp
+  - type: nameserver
+    address: [10.0.20.1]
+  - type: nameserver
+    search: [example.com]
+`
+
+func TestValidateCloudNetworkConfig_valid(t *testing.T) {
+	for _, config := range []string{validNetworkConfigV2, validNetworkConfigV2Netplan, validNetworkConfigV1} {
+		if err := ValidateCloudNetworkConfig(config); err != nil {
+			t.Errorf("expected the network config to be valid but received: %v\n%s", err, config)
+		}
+	}
+}
+
+func TestValidateCloudNetworkConfig_malformedYaml(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected string
+	}{
+		{"version: 2\nethernets:\n  eth0:\n      dhcp4: true\n    mtu: 1500\n", "did not find expected key"},
+		{"version: 2\nethernets:\n  eth0:\n    addresses: [10.0.0.5/24\n", "line 3: did not find expected ',' or ']'"},
+		{"version: 2\nethernets:\n  eth0:\n    macaddress: \"02:16:3e\n", "line 4: found unexpected end of stream"},
+		{"version: 2\nethernets:\n\teth0: {}\n", "line 3: found character that cannot start any token"},
+		{"version: 2\nethernets:\n  eth0\n", "line 3: `ethernets` must be a mapping"},
+		{"version: 2\nversion: 2\n", "line 2: mapping key \"version\" already defined at line 1"},
+	}
+	for _, test := range tests {
+		err := ValidateCloudNetworkConfig(test.config)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("expected an error containing %q for config:\n%s\nbut received: %v", test.expected, test.config, err)
+		}
+	}
+}
+
+func TestValidateCloudNetworkConfig_structure(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected []string
+	}{
+		{"ethernets: {}\n", []string{"line 1: missing the `version`"}},
+		{"version: 3\n", []string{"line 1: unsupported network config version `3`"}},
+		{
+			"version: 2\nethernets:\n  eth0:\n    dhcp4: maybe\n    addresses: 10.0.0.5/24\n",
+			[]string{
+				"line 4: `dhcp4` of `eth0` of `ethernets` must be a boolean, got `maybe`",
+				"line 5: `addresses` of `eth0` of `ethernets` must be a list",
+			},
+		},
+		{"version: 2\nvlans:\n  vlan20:\n    id: 20\n", []string{"line 4: `vlan20` of `vlans` requires `link`"}},
+		{
+			"version: 1\nconfig:\n  - type: physcial\n    name: eth0\n  - type: vlan\n    name: eth0.20\n",
+			[]string{"line 3: unknown config type `physcial`", "line 5: config type `vlan` requires `vlan_link`"},
+		},
+	}
+	for _, test := range tests {
+		err := ValidateCloudNetworkConfig(test.config)
+		for _, expected := range test.expected {
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("expected an error containing %q for config:\n%s\nbut received: %v", expected, test.config, err)
+			}
+		}
+	}
+}
+
+func TestValidateCloudConfig(t *testing.T) {
+	valid := []string{
+		"#cloud-config\nhostname: {name}%\nssh_authorized_keys:\n  - ssh-ed25519 AAAA user@host\n",
+		"#cloud-config\nwrite_files:\n- path: /etc/motd\n  content: |\n    # managed by terraform\n    welcome: home\n  permissions: '0644'\nruncmd:\n  - [systemctl, restart, sshd]\n",
+		"#cloud-config\nruncmd:\n  - echo hello\n    world\n",
+		"#cloud-config\nssh_authorized_keys:\n  - ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC7\n    AAAAB3NzaC1yc2EAAAADAQABAAABAQC8 user@host\n",
+		"#cloud-config\nbase: &b\n  a: 1\nother: *b\n",
+		// Scripts aren't YAML
+		"#!/bin/sh\necho: \"\n",
+	}
+	for _, template := range valid {
+		if err := ValidateCloudConfig(template); err != nil {
+			t.Errorf("expected the cloud config to be valid but received: %v\n%s", err, template)
+		}
+	}
+
+	err := ValidateCloudConfig("#cloud-config\npackages:\n  - nginx\n packages:\n")
+	if err == nil || !strings.Contains(err.Error(), "did not find expected key") {
+		t.Errorf("expected an error for the misindented key but received: %v", err)
+	}
+}
+
+func TestCreateVm_invalidCloudNetworkConfig(t *testing.T) {
+	rpc := fakeCreateVmRPC()
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.CloudNetworkConfig = "version: 2\nethernets:\n  eth0:\n    dhcp4: true\n   addresses: [10.0.0.5/24]\n"
+	_, err := c.CreateVm(vmReq, time.Minute)
+	if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "CloudNetworkConfig" {
+		t.Errorf("expected the network config to be rejected but received: %v", err)
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected no call for an invalid network config but received: %v", rpc.methods())
+	}
+}
+
+func TestCreateCloudConfig_invalidTemplate(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc}
+
+	_, err := c.CreateCloudConfig("web", "#cloud-config\nusers:\n  - name: admin\n    groups: [sudo\n")
+	if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "Template" {
+		t.Errorf("expected the template to be rejected but received: %v", err)
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected no call for an invalid template but received: %v", rpc.methods())
+	}
+}
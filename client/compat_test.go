@@ -0,0 +1,129 @@
+package client
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// legacyShimParams returns the parameters the legacy form of a context or
+// options variant must take: the same ones without the leading context
+// and the trailing options.
+func legacyShimParams(m reflect.Method) []reflect.Type {
+	params := []reflect.Type{}
+	// Skip the receiver
+	for i := 1; i < m.Type.NumIn(); i++ {
+		params = append(params, m.Type.In(i))
+	}
+
+	if len(params) > 0 && params[0] == contextType {
+		params = params[1:]
+	}
+	if n := len(params); n > 0 && strings.HasSuffix(params[n-1].Name(), "Options") {
+		params = params[:n-1]
+	}
+	return params
+}
+
+// legacyShims maps the methods the SDK had before gaining a context or
+// options variant to that variant. They keep their signature and delegate
+// to the variant with the default options. Methods added along with their
+// variant aren't shims.
+var legacyShims = map[string]string{
+	"DeleteNetwork":       "DeleteNetworkWithOptions",
+	"DeleteVm":            "DeleteVmContext",
+	"GetAllObjectsOfType": "GetAllObjectsOfTypeWithOptions",
+	"StartVm":             "StartVmWithOptions",
+	"UpdateVm":            "UpdateVmWithOptions",
+}
+
+func TestLegacyShims_matchNewStyleMethods(t *testing.T) {
+	clientType := reflect.TypeOf(&Client{})
+	xoClientType := reflect.TypeOf((*XOClient)(nil)).Elem()
+
+	for legacyName, name := range legacyShims {
+		m, ok := clientType.MethodByName(name)
+		if !ok {
+			t.Errorf("expected %s to have a %s variant", legacyName, name)
+			continue
+		}
+		legacy, ok := clientType.MethodByName(legacyName)
+		if !ok {
+			t.Errorf("expected %s to have a legacy %s shim", m.Name, legacyName)
+			continue
+		}
+
+		expected := legacyShimParams(m)
+		actual := []reflect.Type{}
+		for i := 1; i < legacy.Type.NumIn(); i++ {
+			actual = append(actual, legacy.Type.In(i))
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("expected %s to take %v like %s but it takes %v", legacyName, expected, m.Name, actual)
+		}
+
+		if legacy.Type.NumOut() != m.Type.NumOut() {
+			t.Errorf("expected %s to return the same values as %s", legacyName, m.Name)
+		}
+
+		for _, name := range []string{m.Name, legacyName} {
+			if _, ok := xoClientType.MethodByName(name); !ok {
+				t.Errorf("expected %s to be part of the XOClient interface", name)
+			}
+		}
+	}
+}
+
+func TestLegacyShims_deprecated(t *testing.T) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), ".", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("failed to parse the package with error: %v", err)
+	}
+	docs := map[string]string{}
+	for _, file := range pkgs["client"].Files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil {
+				docs[fn.Name.Name] = fn.Doc.Text()
+			}
+		}
+	}
+
+	clientType := reflect.TypeOf(&Client{})
+	for i := 0; i < clientType.NumMethod(); i++ {
+		name := clientType.Method(i).Name
+		for _, suffix := range []string{"Context", "WithOptions"} {
+			legacyName := strings.TrimSuffix(name, suffix)
+			if legacyName == name {
+				continue
+			}
+			marker := "Deprecated: use " + name + "."
+			deprecated := strings.Contains(docs[legacyName], marker)
+			if _, ok := legacyShims[legacyName]; ok && !deprecated {
+				t.Errorf("expected the doc of %s to contain %q", legacyName, marker)
+			}
+			if _, ok := legacyShims[legacyName]; !ok && deprecated {
+				t.Errorf("expected %s not to be deprecated, it was added along with %s", legacyName, name)
+			}
+		}
+	}
+}
+
+func TestLegacyShims_delegateWithDefaults(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc}
+
+	if err := c.DeleteVm("vm-1"); err != nil {
+		t.Fatalf("failed to delete VM with error: %v", err)
+	}
+	// The only lookup checks whether vm-1 is the base of linked clones
+	expected := []string{"xo.getAllObjects", "vm.delete"}
+	if methods := rpc.methods(); !reflect.DeepEqual(methods, expected) {
+		t.Errorf("expected DeleteVm to neither delete disks nor wait but received calls: %v", methods)
+	}
+}
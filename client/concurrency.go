@@ -0,0 +1,32 @@
+package client
+
+import (
+	"sync"
+)
+
+// Default number of concurrent calls made by the bulk helpers
+// when the caller does not specify one.
+const defaultConcurrency = 4
+
+// forEachConcurrently calls fn for every index in [0, n) running at most
+// limit calls at the same time and returns once every call has returned.
+func forEachConcurrently(n, limit int, fn func(i int)) {
+	if limit <= 0 {
+		limit = defaultConcurrency
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ConfigEnvError lists every missing or invalid XOA_* environment variable
+// found by LoadConfigFromEnv. Each of them is a ValidationError whose field
+// is the name of the variable.
+type ConfigEnvError struct {
+	Errs ValidationErrors
+}
+
+func (e ConfigEnvError) Error() string {
+	return fmt.Sprintf("invalid XO configuration from the environment: %s", e.Errs.Error())
+}
+
+func (e ConfigEnvError) Unwrap() error {
+	return e.Errs
+}
+
+// LoadConfigFromEnv reads the configuration of the client from the same
+// variables as GetConfigFromEnv, and XOA_TIMEOUT, a duration such as `30s`.
+// XOA_USER and XOA_PASSWORD are only required without XOA_TOKEN, which
+// takes precedence when both are set. Rather
+// than leaving the client half configured, every missing or invalid
+// variable is reported at once in a ConfigEnvError.
+func LoadConfigFromEnv() (Config, error) {
+	config := GetConfigFromEnv()
+
+	v := &validator{}
+	v.required("XOA_URL", config.Url)
+	if config.Url != "" {
+		u, err := url.Parse(config.Url)
+		switch {
+		case err != nil:
+			v.addf("XOA_URL", "`%s` is not a valid URL: %v", config.Url, err)
+		case u.Scheme != "ws" && u.Scheme != "wss" && u.Scheme != "http" && u.Scheme != "https":
+			v.addf("XOA_URL", "`%s` must start with ws://, wss://, http:// or https://", config.Url)
+		case u.Host == "":
+			v.addf("XOA_URL", "`%s` has no host", config.Url)
+		}
+	}
+	if config.Token == "" {
+		v.required("XOA_USER", config.Username)
+		v.required("XOA_PASSWORD", config.Password)
+	}
+
+	if timeout := os.Getenv("XOA_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d < 0 {
+			v.addf("XOA_TIMEOUT", "`%s` is not a duration such as `30s`", timeout)
+		}
+		config.Timeout = d
+	}
+
+	if errs, ok := v.err().(ValidationErrors); ok {
+		return config, ConfigEnvError{Errs: errs}
+	}
+	return config, nil
+}
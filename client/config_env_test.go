@@ -0,0 +1,121 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// setConfigEnv sets the XOA_* variables for the duration of the test, an
+// empty value unsets the variable.
+func setConfigEnv(t *testing.T, vars map[string]string) {
+	for name, value := range vars {
+		previous, found := os.LookupEnv(name)
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+
+		name := name
+		t.Cleanup(func() {
+			if found {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromEnv(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"XOA_URL":      "wss://xo.example.com",
+		"XOA_USER":     "admin@admin.net",
+		"XOA_PASSWORD": "admin",
+		"XOA_INSECURE": "true",
+		"XOA_TIMEOUT":  "45s",
+	})
+
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatalf("failed to load the configuration with error: %v", err)
+	}
+	expected := Config{Url: "wss://xo.example.com", Username: "admin@admin.net", Password: "admin", InsecureSkipVerify: true, Timeout: 45 * time.Second}
+	if config.Url != expected.Url || config.Username != expected.Username || config.Password != expected.Password || !config.InsecureSkipVerify || config.Timeout != expected.Timeout {
+		t.Errorf("expected %+v but received %+v", expected, config)
+	}
+}
+
+func TestLoadConfigFromEnv_token(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"XOA_URL":      "wss://xo.example.com",
+		"XOA_USER":     "",
+		"XOA_PASSWORD": "",
+		"XOA_TOKEN":    "token",
+	})
+
+	config, err := LoadConfigFromEnv()
+	if err != nil {
+		t.Fatalf("expected a token to replace the username and password but received: %v", err)
+	}
+	if config.Token != "token" || config.Username != "" {
+		t.Errorf("expected the token to be read but received %+v", config)
+	}
+}
+
+func TestGetConfigFromEnv_tokenTakesPrecedence(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"XOA_URL":      "wss://xo.example.com",
+		"XOA_USER":     "admin@admin.net",
+		"XOA_PASSWORD": "admin",
+		"XOA_TOKEN":    "token",
+	})
+
+	for _, load := range []func() (Config, error){
+		func() (Config, error) { return GetConfigFromEnv(), nil },
+		LoadConfigFromEnv,
+	} {
+		config, err := load()
+		if err != nil {
+			t.Fatalf("expected both the credentials and the token to be accepted but received: %v", err)
+		}
+		if method, params := signInParams(config); method != "session.signInWithToken" || params["token"] != "token" {
+			t.Errorf("expected the client to sign in with the token but received %s with %v", method, params)
+		}
+	}
+}
+
+func TestLoadConfigFromEnv_reportsEveryProblem(t *testing.T) {
+	setConfigEnv(t, map[string]string{
+		"XOA_URL":      "",
+		"XOA_USER":     "admin@admin.net",
+		"XOA_PASSWORD": "admin",
+		"XOA_TOKEN":    "",
+		"XOA_TIMEOUT":  "45 seconds",
+	})
+
+	_, err := LoadConfigFromEnv()
+	var envErr ConfigEnvError
+	if !errors.As(err, &envErr) {
+		t.Fatalf("expected a ConfigEnvError but received: %v", err)
+	}
+	fields := []string{}
+	for _, e := range envErr.Errs {
+		fields = append(fields, e.(ValidationError).Field)
+	}
+	if len(fields) != 2 || fields[0] != "XOA_URL" || fields[1] != "XOA_TIMEOUT" {
+		t.Errorf("expected the missing url and the invalid timeout in one error but received: %v", err)
+	}
+
+	setConfigEnv(t, map[string]string{
+		"XOA_URL":      "xo.example.com",
+		"XOA_PASSWORD": "",
+		"XOA_TIMEOUT":  "",
+	})
+	_, err = LoadConfigFromEnv()
+	if !errors.As(err, &envErr) || len(envErr.Errs) != 2 {
+		t.Errorf("expected a url without scheme and the missing password to be reported but received: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CpuMask is the set of host CPUs the vCPUs of a VM are pinned to, XAPI's
+// `VCPUs-params:mask`. An empty mask lets the VM run on any CPU.
+type CpuMask []int
+
+// UnpinnedCpuMask lets the VM run on any CPU when passed to UpdateVm, unlike
+// a nil mask which leaves the pinning of the VM unchanged. Any empty mask
+// which isn't nil, e.g. parsed from an empty string, does the same.
+var UnpinnedCpuMask = CpuMask{}
+
+// ParseCpuMask parses a mask in the list format of cpusets, e.g. `0,2-4`.
+func ParseCpuMask(s string) (CpuMask, error) {
+	mask := CpuMask{}
+	if strings.TrimSpace(s) == "" {
+		return mask, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		bounds := strings.SplitN(part, "-", 2)
+		first, err := parseCpuId(bounds[0])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid CPU `%s` in mask `%s`: %v", part, s, err))
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = parseCpuId(bounds[1]); err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid CPU range `%s` in mask `%s`: %v", part, s, err))
+			}
+			if last < first {
+				return nil, errors.New(fmt.Sprintf("invalid CPU range `%s` in mask `%s`: the range is reversed", part, s))
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			mask = append(mask, cpu)
+		}
+	}
+	return mask.normalized(), nil
+}
+
+func parseCpuId(s string) (int, error) {
+	cpu, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return 0, errors.New("not a number")
+	}
+	if cpu < 0 {
+		return 0, errors.New("CPUs are numbered from 0")
+	}
+	return cpu, nil
+}
+
+// normalized returns the CPUs of the mask sorted and without duplicates.
+func (m CpuMask) normalized() CpuMask {
+	cpus := append(CpuMask{}, m...)
+	sort.Ints(cpus)
+	normalized := CpuMask{}
+	for i, cpu := range cpus {
+		if i == 0 || cpu != cpus[i-1] {
+			normalized = append(normalized, cpu)
+		}
+	}
+	return normalized
+}
+
+// String formats the mask in the list format of cpusets with consecutive
+// CPUs grouped in ranges, e.g. `0,2-4`.
+func (m CpuMask) String() string {
+	cpus := m.normalized()
+	parts := []string{}
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", cpus[i], cpus[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(cpus[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// HostNumaTopology is the NUMA topology of a host as far as XO exposes it.
+// XAPI only reports the sockets of a host, each socket is taken to be a
+// NUMA node holding the same number of CPUs.
+type HostNumaTopology struct {
+	Nodes       int
+	CpusPerNode int
+}
+
+// NumaTopology returns the NUMA topology of the host, nil when XO doesn't
+// report the CPUs and sockets of the host.
+func (h Host) NumaTopology() *HostNumaTopology {
+	if h.Cpus.Cores <= 0 || h.Cpus.Sockets <= 0 {
+		return nil
+	}
+	return &HostNumaTopology{
+		Nodes:       int(h.Cpus.Sockets),
+		CpusPerNode: int(h.Cpus.Cores / h.Cpus.Sockets),
+	}
+}
+
+func (v *validator) cpuMask(field string, mask CpuMask) {
+	for _, cpu := range mask {
+		if cpu < 0 {
+			v.addf(field, "CPUs are numbered from 0, got %d", cpu)
+		}
+	}
+}
+
+// validateCpuMaskOnHost checks that the CPUs of the mask exist on the host
+// the VM is pinned to.
+func (c *Client) validateCpuMaskOnHost(vm Vm) error {
+	if c.skipValidation || len(vm.VcpuMask) == 0 || vm.AffinityHost == "" {
+		return nil
+	}
+
+	var host Host
+	if err := c.getObjectOfType("host", vm.AffinityHost, Host{Id: vm.AffinityHost}, &host); err != nil {
+		return err
+	}
+
+	v := &validator{}
+	cpus := host.Cpus.Cores
+	for _, cpu := range vm.VcpuMask.normalized() {
+		if cpus > 0 && int64(cpu) >= cpus {
+			v.addf("VcpuMask", "CPU %d doesn't exist on affinity host `%s` with %d CPUs", cpu, host.NameLabel, cpus)
+		}
+	}
+	return v.err()
+}
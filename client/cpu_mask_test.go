@@ -0,0 +1,185 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCpuMask(t *testing.T) {
+	tests := []struct {
+		mask      string
+		expected  CpuMask
+		formatted string
+	}{
+		{"", CpuMask{}, ""},
+		{"3", CpuMask{3}, "3"},
+		{"0,2-4", CpuMask{0, 2, 3, 4}, "0,2-4"},
+		{" 4-5, 0 ,1,3", CpuMask{0, 1, 3, 4, 5}, "0-1,3-5"},
+		{"2,2,1-2", CpuMask{1, 2}, "1-2"},
+	}
+	for _, test := range tests {
+		mask, err := ParseCpuMask(test.mask)
+		if err != nil {
+			t.Fatalf("failed to parse mask `%s` with error: %v", test.mask, err)
+		}
+		if !reflect.DeepEqual(mask, test.expected) {
+			t.Errorf("expected mask `%s` to be parsed as %v but received %v", test.mask, test.expected, mask)
+		}
+		if s := mask.String(); s != test.formatted {
+			t.Errorf("expected mask `%s` to be formatted as `%s` but received `%s`", test.mask, test.formatted, s)
+		}
+	}
+
+	for _, mask := range []string{"a", "1,,2", "4-2", "-1", "1-", "0-2-4"} {
+		if _, err := ParseCpuMask(mask); err == nil {
+			t.Errorf("expected mask `%s` to be rejected", mask)
+		}
+	}
+}
+
+func TestVm_decodesCpuMask(t *testing.T) {
+	var vm Vm
+	if err := json.Unmarshal([]byte(`{"id": "vm-1", "cpuMask": [0, 2, 3, 4]}`), &vm); err != nil {
+		t.Fatalf("failed to decode the VM with error: %v", err)
+	}
+	if vm.VcpuMask.String() != "0,2-4" {
+		t.Errorf("expected the mask to be decoded but received %v", vm.VcpuMask)
+	}
+}
+
+func cpuMaskClient(vm map[string]interface{}) (*Client, *fakeRPC) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, vm, map[string]interface{}{"id": testUuid2, "type": "host", "name_label": "latency", "cpus": map[string]interface{}{"cores": 8, "sockets": 1}}), nil
+		}
+		return true, nil
+	}}
+	return &Client{rpc: rpc, logger: log.New(&bytes.Buffer{}, "", 0)}, rpc
+}
+
+func TestUpdateVm_cpuMask(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	c, rpc := cpuMaskClient(map[string]interface{}{"id": testUuid, "type": "VM", "power_state": "Halted", "CPUs": map[string]interface{}{"number": 4}, "memory": map[string]interface{}{"static": []int64{0, minVmMemory}}})
+
+	vmReq := Vm{Id: testUuid, AffinityHost: testUuid2, CPUs: CPUs{Number: 4}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, VcpuMask: CpuMask{7, 4, 5, 6}}
+	if _, err := c.UpdateVm(vmReq); err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	set := rpc.callsTo("vm.set")
+	if len(set) != 1 || !reflect.DeepEqual(set[0].params["cpuMask"], []interface{}{float64(4), float64(5), float64(6), float64(7)}) {
+		t.Errorf("expected vm.set to receive the sorted mask but received: %v", set)
+	}
+}
+
+func TestUpdateVm_cpuMaskRequiresReboot(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	c, _ := cpuMaskClient(map[string]interface{}{"id": testUuid, "type": "VM", "power_state": "Running", "CPUs": map[string]interface{}{"number": 4}, "memory": map[string]interface{}{"static": []int64{0, minVmMemory}}, "cpuMask": []int{0, 1, 2, 3}})
+
+	vmReq := Vm{Id: testUuid, CPUs: CPUs{Number: 4}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, VcpuMask: CpuMask{4, 5, 6, 7}}
+	vm, err := c.UpdateVm(vmReq)
+	if err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	if !reflect.DeepEqual(vm.RebootRequiredFields, []string{"cpuMask"}) {
+		t.Errorf("expected the new mask to require a reboot but received: %v", vm.RebootRequiredFields)
+	}
+
+	vmReq.VcpuMask = CpuMask{0, 1, 2, 3}
+	if vm, err = c.UpdateVm(vmReq); err != nil || len(vm.RebootRequiredFields) != 0 {
+		t.Errorf("expected the unchanged mask not to require a reboot but received %v with error: %v", vm.RebootRequiredFields, err)
+	}
+}
+
+func TestUpdateVm_cpuMaskUnchangedUnlessSet(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	c, rpc := cpuMaskClient(map[string]interface{}{"id": testUuid, "type": "VM", "power_state": "Halted", "CPUs": map[string]interface{}{"number": 4}, "memory": map[string]interface{}{"static": []int64{0, minVmMemory}}, "cpuMask": []int{16, 17}})
+
+	vmReq := Vm{Id: testUuid, CPUs: CPUs{Number: 4}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}}
+	if _, err := c.UpdateVm(vmReq); err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	if _, ok := rpc.callsTo("vm.set")[0].params["cpuMask"]; ok {
+		t.Errorf("expected the pinning to be left unchanged without a mask but received: %v", rpc.callsTo("vm.set")[0].params)
+	}
+
+	vmReq.VcpuMask = UnpinnedCpuMask
+	if _, err := c.UpdateVm(vmReq); err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	if mask, ok := rpc.callsTo("vm.set")[1].params["cpuMask"]; !ok || mask != nil {
+		t.Errorf("expected the VM to be unpinned but received: %v", rpc.callsTo("vm.set")[1].params)
+	}
+}
+
+func TestUpdateVm_cpuMaskBeyondAffinityHostCpus(t *testing.T) {
+	c, rpc := cpuMaskClient(map[string]interface{}{"id": testUuid, "type": "VM"})
+
+	vmReq := Vm{Id: testUuid, AffinityHost: testUuid2, CPUs: CPUs{Number: 4}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, VcpuMask: CpuMask{6, 7, 8, 9}}
+	_, err := c.UpdateVm(vmReq)
+	if fields := validationFields(t, err); len(fields) != 2 || fields[0] != "VcpuMask" || !strings.Contains(err.Error(), "CPU 8 doesn't exist on affinity host `latency` with 8 CPUs") {
+		t.Errorf("expected CPUs 8 and 9 to be rejected but received: %v", err)
+	}
+	if set := rpc.callsTo("vm.set"); len(set) != 0 {
+		t.Errorf("expected no vm.set call for an invalid mask but received: %v", set)
+	}
+
+	vmReq.VcpuMask = CpuMask{-1}
+	vmReq.AffinityHost = ""
+	_, err = c.UpdateVm(vmReq)
+	if fields := validationFields(t, err); len(fields) != 1 || fields[0] != "VcpuMask" {
+		t.Errorf("expected a negative CPU to be rejected but received: %v", err)
+	}
+}
+
+func TestHostNumaTopology(t *testing.T) {
+	var host Host
+	if err := json.Unmarshal([]byte(`{"id": "host-1", "cpus": {"cores": 32, "sockets": 2}}`), &host); err != nil {
+		t.Fatalf("failed to unmarshal host with error: %v", err)
+	}
+	if topology := host.NumaTopology(); topology == nil || topology.Nodes != 2 || topology.CpusPerNode != 16 {
+		t.Errorf("expected 2 NUMA nodes of 16 CPUs but received: %+v", topology)
+	}
+
+	if topology := (Host{Id: "host-2"}).NumaTopology(); topology != nil {
+		t.Errorf("expected no topology for a host without CPU information but received: %+v", topology)
+	}
+}
+
+func TestDiffVm_cpuMaskDrift(t *testing.T) {
+	actual := Vm{NameLabel: "web", PowerState: PowerStateRunning, VcpuMask: CpuMask{0, 1, 2}}
+
+	if changes := DiffVm(Vm{NameLabel: "web", VcpuMask: CpuMask{2, 0, 1}}, actual); len(changes) != 0 {
+		t.Errorf("expected the same CPUs in another order to produce no change but received %+v", changes)
+	}
+
+	if changes := DiffVm(Vm{NameLabel: "web"}, actual); len(changes) != 0 {
+		t.Errorf("expected a nil mask to leave the pinning unmanaged but received %+v", changes)
+	}
+	if changes := DiffVm(Vm{NameLabel: "web", VcpuMask: UnpinnedCpuMask}, actual); len(changes) != 1 || changes[0].New != "" {
+		t.Errorf("expected unpinning to change the mask but received %+v", changes)
+	}
+
+	changes := DiffVm(Vm{NameLabel: "web", VcpuMask: CpuMask{0, 2, 3, 4}}, actual)
+	if len(changes) != 1 || changes[0].Field != "cpuMask" || changes[0].Old != "0-2" || changes[0].New != "0,2-4" {
+		t.Fatalf("expected the mask to change from `0-2` to `0,2-4` but received %+v", changes)
+	}
+	if fields := RebootRequiredFields(changes); !reflect.DeepEqual(fields, []string{"cpuMask"}) {
+		t.Errorf("expected a mask change to require a reboot but received %v", fields)
+	}
+	if fields := RebootRequiredFields(DiffVm(Vm{NameLabel: "db", VcpuMask: actual.VcpuMask}, actual)); len(fields) != 0 {
+		t.Errorf("expected a rename not to require a reboot but received %v", fields)
+	}
+}
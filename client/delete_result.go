@@ -0,0 +1,43 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Code of XO's noSuchObject error, returned when deleting an object that
+// doesn't exist
+const noSuchObjectCode = 1
+
+type DeletedObject struct {
+	// XO type of the object, e.g. VM or VDI
+	Type string
+	Id   string
+}
+
+// DeleteResult describes what a deletion removed, for audit logs.
+type DeleteResult struct {
+	DeletedObject
+	// The object didn't exist so nothing was deleted
+	AlreadyAbsent bool
+	// Objects deleted along with the object, e.g. the disks of a VM
+	Cascaded []DeletedObject
+}
+
+func isNoSuchObject(err error) bool {
+	var rpcErr *jsonrpc2.Error
+	return errors.As(err, &rpcErr) && rpcErr.Code == noSuchObjectCode
+}
+
+// absentIfNoSuchObject reports an object XO failed to delete because it
+// doesn't exist as already absent rather than as an error.
+func absentIfNoSuchObject(res *DeleteResult, err error) (*DeleteResult, error) {
+	if isNoSuchObject(err) {
+		return &DeleteResult{DeletedObject: res.DeletedObject, AlreadyAbsent: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
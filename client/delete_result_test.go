@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestDeleteVmWithResult_cascadedDisks(t *testing.T) {
+	c := Client{rpc: fakeDeletingVmRPC()}
+
+	res, err := c.DeleteVmWithResult(context.Background(), "vm-1", DeleteVmOptions{DeleteDisks: true})
+	if err != nil {
+		t.Fatalf("failed to delete vm with error: %v", err)
+	}
+
+	expected := &DeleteResult{
+		DeletedObject: DeletedObject{Type: "VM", Id: "vm-1"},
+		Cascaded: []DeletedObject{
+			{Type: "VDI", Id: "vdi-1"},
+			{Type: "VDI", Id: "vdi-2"},
+			{Type: "VDI", Id: "vdi-3"},
+		},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected the VM and its disks to be reported but received: %+v", res)
+	}
+}
+
+func TestDeleteNetworkWithResult_cascadedUsers(t *testing.T) {
+	c := &Client{rpc: fakeNetworkInUseRPC(haltedVmVif)}
+
+	res, err := c.DeleteNetworkWithResult("network-1", DeleteNetworkOptions{Cascade: true})
+	if err != nil {
+		t.Fatalf("failed to delete the network with error: %v", err)
+	}
+	expected := []DeletedObject{{Type: "VIF", Id: "vif-1"}, {Type: "PIF", Id: "pif-vlan"}}
+	if res.Id != "network-1" || !reflect.DeepEqual(res.Cascaded, expected) {
+		t.Errorf("expected the VIF and VLAN PIF to be reported but received: %+v", res)
+	}
+}
+
+func TestDeleteUserWithResult_alreadyAbsent(t *testing.T) {
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, &jsonrpc2.Error{Code: noSuchObjectCode, Message: "no such user user-1"}
+	}}}
+
+	res, err := c.DeleteUserWithResult(User{Id: "user-1"})
+	if err != nil {
+		t.Fatalf("expected a missing user not to be an error but received: %v", err)
+	}
+	if !res.AlreadyAbsent || res.Type != "user" || res.Id != "user-1" {
+		t.Errorf("expected the user to be reported as already absent but received: %+v", res)
+	}
+
+	if err := c.DeleteUser(User{Id: "user-1"}); !isNoSuchObject(err) {
+		t.Errorf("expected DeleteUser to keep returning the error of XO but received: %v", err)
+	}
+}
+
+func TestDeleteGroupWithResult_failure(t *testing.T) {
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("unauthorized")
+	}}}
+
+	if res, err := c.DeleteGroupWithResult("group-1"); err == nil || res != nil {
+		t.Errorf("expected other errors to be returned but received: %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,177 @@
+package client
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+type DiskHealthStatus string
+
+const (
+	DiskHealthPassed DiskHealthStatus = "passed"
+	DiskHealthFailed DiskHealthStatus = "failed"
+	// The host can't report the health of its disks, usually because the
+	// smartctl plugin isn't installed
+	DiskHealthUnknown DiskHealthStatus = "unknown"
+)
+
+// ataReallocatedSectorCount is the id of the SMART attribute counting the
+// sectors a disk remapped after failing to write them.
+const ataReallocatedSectorCount = 5
+
+// DiskHealth is the SMART health of a disk of a host.
+type DiskHealth struct {
+	HostId string
+	// e.g. `/dev/sda`, empty when the health of the host's disks is unknown
+	Device string
+	// Result of the disk's SMART self-assessment
+	Status DiskHealthStatus
+	// In degrees Celsius, 0 when not reported
+	Temperature        int
+	ReallocatedSectors int64
+	// Why the health is unknown
+	Err error
+}
+
+// Healthy reports whether the disk passes its self-assessment without
+// having remapped any sector yet, which is usually the first sign of a
+// failing disk.
+func (d DiskHealth) Healthy() bool {
+	return d.Status == DiskHealthPassed && d.ReallocatedSectors == 0
+}
+
+// smartctlInformation is the part of `smartctl --json` output reported
+// for each disk by XO.
+type smartctlInformation struct {
+	SmartStatus *struct {
+		Passed bool `json:"passed"`
+	} `json:"smart_status"`
+	Temperature struct {
+		Current int `json:"current"`
+	} `json:"temperature"`
+	AtaSmartAttributes struct {
+		Table []struct {
+			Id  int `json:"id"`
+			Raw struct {
+				Value int64 `json:"value"`
+			} `json:"raw"`
+		} `json:"table"`
+	} `json:"ata_smart_attributes"`
+}
+
+// GetHostDiskHealth returns the health of every disk of a host, sorted by
+// device. It relies on the smartctl plugin of XCP-ng hosts and returns an
+// UnsupportedOnThisServerError when XO can't query it.
+func (c *Client) GetHostDiskHealth(hostId string) ([]DiskHealth, error) {
+	params := map[string]interface{}{
+		"id": hostId,
+	}
+	var health map[string]string
+	err := c.Call("host.getSmartctlHealth", params, &health)
+	if err != nil {
+		return nil, featureDetect("host.getSmartctlHealth", err)
+	}
+
+	var information map[string]smartctlInformation
+	err = c.Call("host.getSmartctlInformation", params, &information)
+	if err != nil {
+		return nil, featureDetect("host.getSmartctlInformation", err)
+	}
+
+	disks := []DiskHealth{}
+	for device, status := range health {
+		disk := DiskHealth{
+			HostId: hostId,
+			Device: devicePath(device),
+			Status: DiskHealthUnknown,
+		}
+		switch strings.ToUpper(status) {
+		case "PASSED", "OK":
+			disk.Status = DiskHealthPassed
+		case "FAILED":
+			disk.Status = DiskHealthFailed
+		}
+
+		info, ok := information[device]
+		if ok {
+			if info.SmartStatus != nil && !info.SmartStatus.Passed {
+				disk.Status = DiskHealthFailed
+			}
+			disk.Temperature = info.Temperature.Current
+			for _, attr := range info.AtaSmartAttributes.Table {
+				if attr.Id == ataReallocatedSectorCount {
+					disk.ReallocatedSectors = attr.Raw.Value
+				}
+			}
+		}
+		disks = append(disks, disk)
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		return disks[i].Device < disks[j].Device
+	})
+	return disks, nil
+}
+
+// Codes of XO's unauthorized and invalidCredentials errors
+const (
+	unauthorizedCode       = 2
+	invalidCredentialsCode = 3
+)
+
+// isHostDiskHealthError reports whether err is the failure of a host to
+// report the health of its disks, rather than a failure to reach XO or to
+// be allowed to query it.
+func isHostDiskHealthError(err error) bool {
+	var unsupported UnsupportedOnThisServerError
+	if errors.As(err, &unsupported) {
+		return true
+	}
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	return rpcErr.Code != unauthorizedCode && rpcErr.Code != invalidCredentialsCode
+}
+
+// GetUnhealthyDisks returns the disks of every host of the pool that are
+// not Healthy. Hosts which fail to report the health of their disks are
+// reported with a single disk of DiskHealthUnknown status rather than
+// failing the call, errors reaching XO or authenticating to it are
+// returned.
+func (c *Client) GetUnhealthyDisks(poolId string) ([]DiskHealth, error) {
+	hosts := map[string]Host{}
+	err := c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":  "host",
+			"$pool": poolId,
+		},
+	}, &hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	unhealthy := []DiskHealth{}
+	for _, hostId := range sortedKeys(hosts) {
+		disks, err := c.GetHostDiskHealth(hostId)
+		if err != nil && !isHostDiskHealthError(err) {
+			return nil, err
+		}
+		if err != nil {
+			unhealthy = append(unhealthy, DiskHealth{
+				HostId: hostId,
+				Status: DiskHealthUnknown,
+				Err:    err,
+			})
+			continue
+		}
+		for _, disk := range disks {
+			if !disk.Healthy() {
+				unhealthy = append(unhealthy, disk)
+			}
+		}
+	}
+	return unhealthy, nil
+}
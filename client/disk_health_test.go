@@ -0,0 +1,169 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Output of the smartctl plugin of a host with a healthy SATA SSD and a
+// hard drive that started remapping sectors.
+const healthySmartctlFixture = `{
+	"/dev/sda": {
+		"model_name": "Samsung SSD 860 EVO 500GB",
+		"smart_status": {"passed": true},
+		"temperature": {"current": 31},
+		"ata_smart_attributes": {"table": [
+			{"id": 5, "name": "Reallocated_Sector_Ct", "raw": {"value": 0, "string": "0"}},
+			{"id": 9, "name": "Power_On_Hours", "raw": {"value": 18230, "string": "18230"}}
+		]}
+	},
+	"/dev/sdb": {
+		"model_name": "ST4000VN008-2DR166",
+		"smart_status": {"passed": true},
+		"temperature": {"current": 38},
+		"ata_smart_attributes": {"table": [
+			{"id": 5, "name": "Reallocated_Sector_Ct", "raw": {"value": 24, "string": "24"}}
+		]}
+	},
+	"/dev/nvme0n1": {
+		"model_name": "Samsung SSD 970 EVO Plus 500GB",
+		"smart_status": {"passed": true},
+		"temperature": {"current": 42},
+		"nvme_smart_health_information_log": {"critical_warning": 0, "media_errors": 0}
+	}
+}`
+
+// Output of the smartctl plugin of a host with a failing hard drive.
+const failingSmartctlFixture = `{
+	"sda": {
+		"model_name": "WDC WD20EFRX-68EUZN0",
+		"smart_status": {"passed": false},
+		"temperature": {"current": 51},
+		"ata_smart_attributes": {"table": [
+			{"id": 5, "name": "Reallocated_Sector_Ct", "raw": {"value": 1837, "string": "1837"}}
+		]}
+	}
+}`
+
+func fakeDiskHealthRPC(health map[string]map[string]string, information map[string]string) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		hostId, _ := params["id"].(string)
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params,
+				map[string]interface{}{"id": "host-1", "type": "host", "$pool": "pool-1"},
+				map[string]interface{}{"id": "host-2", "type": "host", "$pool": "pool-1"},
+				map[string]interface{}{"id": "host-3", "type": "host", "$pool": "pool-1"},
+				map[string]interface{}{"id": "host-4", "type": "host", "$pool": "pool-2"},
+			), nil
+		case "host.getSmartctlHealth":
+			if _, ok := health[hostId]; !ok {
+				return nil, xapiError("XENAPI_MISSING_PLUGIN", "smartctl.py")
+			}
+			return health[hostId], nil
+		case "host.getSmartctlInformation":
+			return json.RawMessage(information[hostId]), nil
+		}
+		return nil, nil
+	}}
+}
+
+func TestGetHostDiskHealth_healthy(t *testing.T) {
+	c := &Client{rpc: fakeDiskHealthRPC(
+		map[string]map[string]string{"host-1": {"/dev/sda": "PASSED", "/dev/sdb": "PASSED", "/dev/nvme0n1": "PASSED"}},
+		map[string]string{"host-1": healthySmartctlFixture},
+	)}
+
+	disks, err := c.GetHostDiskHealth("host-1")
+	if err != nil {
+		t.Fatalf("failed to get disk health with error: %v", err)
+	}
+	if len(disks) != 3 {
+		t.Fatalf("expected 3 disks but received: %+v", disks)
+	}
+
+	nvme, ssd, hdd := disks[0], disks[1], disks[2]
+	if nvme.Device != "/dev/nvme0n1" || nvme.Status != DiskHealthPassed || nvme.Temperature != 42 || !nvme.Healthy() {
+		t.Errorf("expected the nvme disk to be healthy but received: %+v", nvme)
+	}
+	if ssd.Device != "/dev/sda" || ssd.Temperature != 31 || ssd.ReallocatedSectors != 0 || !ssd.Healthy() {
+		t.Errorf("expected sda to be healthy but received: %+v", ssd)
+	}
+	if hdd.Status != DiskHealthPassed || hdd.ReallocatedSectors != 24 || hdd.Healthy() {
+		t.Errorf("expected sdb to pass its self-assessment but not be healthy with reallocated sectors, received: %+v", hdd)
+	}
+}
+
+func TestGetHostDiskHealth_failing(t *testing.T) {
+	c := &Client{rpc: fakeDiskHealthRPC(
+		map[string]map[string]string{"host-2": {"sda": "FAILED"}},
+		map[string]string{"host-2": failingSmartctlFixture},
+	)}
+
+	disks, err := c.GetHostDiskHealth("host-2")
+	if err != nil {
+		t.Fatalf("failed to get disk health with error: %v", err)
+	}
+	if len(disks) != 1 {
+		t.Fatalf("expected a single disk but received: %+v", disks)
+	}
+	d := disks[0]
+	if d.Device != "/dev/sda" || d.Status != DiskHealthFailed || d.Temperature != 51 || d.ReallocatedSectors != 1837 || d.Healthy() {
+		t.Errorf("expected sda to be failing but received: %+v", d)
+	}
+}
+
+func TestGetUnhealthyDisks(t *testing.T) {
+	c := &Client{rpc: fakeDiskHealthRPC(
+		map[string]map[string]string{
+			"host-1": {"/dev/sda": "PASSED", "/dev/sdb": "PASSED", "/dev/nvme0n1": "PASSED"},
+			"host-2": {"sda": "FAILED"},
+		},
+		map[string]string{
+			"host-1": healthySmartctlFixture,
+			"host-2": failingSmartctlFixture,
+		},
+	)}
+
+	disks, err := c.GetUnhealthyDisks("pool-1")
+	if err != nil {
+		t.Fatalf("expected hosts without smartctl not to fail the aggregation but received error: %v", err)
+	}
+	if len(disks) != 3 {
+		t.Fatalf("expected 3 unhealthy disks but received: %+v", disks)
+	}
+	if disks[0].HostId != "host-1" || disks[0].Device != "/dev/sdb" {
+		t.Errorf("expected sdb of host-1 to be reported but received: %+v", disks[0])
+	}
+	if disks[1].HostId != "host-2" || disks[1].Status != DiskHealthFailed {
+		t.Errorf("expected sda of host-2 to be reported as failed but received: %+v", disks[1])
+	}
+	if disks[2].HostId != "host-3" || disks[2].Status != DiskHealthUnknown || disks[2].Err == nil {
+		t.Errorf("expected host-3 to be reported as unknown but received: %+v", disks[2])
+	}
+}
+
+func TestGetUnhealthyDisks_returnsConnectionAndAuthErrors(t *testing.T) {
+	for _, callErr := range []error{
+		jsonrpc2.ErrClosed,
+		&jsonrpc2.Error{Code: unauthorizedCode, Message: "not authenticated"},
+	} {
+		rpc := fakeDiskHealthRPC(map[string]map[string]string{"host-1": {"/dev/sda": "PASSED"}}, map[string]string{"host-1": healthySmartctlFixture})
+		handler := rpc.handler
+		rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+			if method == "host.getSmartctlHealth" && params["id"] == "host-2" {
+				return nil, callErr
+			}
+			return handler(method, params)
+		}
+		c := &Client{rpc: rpc}
+
+		disks, err := c.GetUnhealthyDisks("pool-1")
+		if !errors.Is(err, callErr) || disks != nil {
+			t.Errorf("expected the error %v to be returned but received %+v with error: %v", callErr, disks, err)
+		}
+	}
+}
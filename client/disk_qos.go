@@ -0,0 +1,35 @@
+package client
+
+// DiskQos caps the I/O of a disk, 0 means no limit.
+type DiskQos struct {
+	// I/O operations per second
+	Iops int64
+	// Throughput in MB per second
+	MBps int64
+}
+
+func (v *validator) diskQos(field string, qos DiskQos) {
+	if qos.Iops < 0 {
+		v.addf(field+".Iops", "must not be negative, got %d", qos.Iops)
+	}
+	if qos.MBps < 0 {
+		v.addf(field+".MBps", "must not be negative, got %d", qos.MBps)
+	}
+}
+
+// SetDiskQos caps the I/O of the disk attached by the VBD. XAPI has no
+// IOPS or throughput cap for VBDs, its qos_algorithm_type only sets the
+// ionice priority of the disk, and XO neither accepts the qos params in
+// vbd.set nor reports them on VBDs. An UnsupportedOnThisServerError is
+// returned once the limits are validated.
+func (c *Client) SetDiskQos(vbdId string, qos DiskQos) error {
+	if !c.skipValidation {
+		v := &validator{}
+		v.diskQos("qos", qos)
+		if err := v.err(); err != nil {
+			return err
+		}
+	}
+
+	return UnsupportedOnThisServerError{Method: "vbd.set", Reason: "XAPI has no IOPS or throughput cap for VBDs"}
+}
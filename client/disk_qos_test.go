@@ -0,0 +1,25 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetDiskQos_unsupported(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := Client{rpc: rpc}
+
+	var unsupported UnsupportedOnThisServerError
+	if err := c.SetDiskQos("vbd-1", DiskQos{Iops: 500, MBps: 100}); !errors.As(err, &unsupported) || unsupported.Method != "vbd.set" {
+		t.Errorf("expected an UnsupportedOnThisServerError but received: %v", err)
+	}
+
+	err := c.SetDiskQos("vbd-1", DiskQos{Iops: -1, MBps: 10})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"qos.Iops"}) {
+		t.Errorf("expected the negative limit to be rejected but received: %v", err)
+	}
+	if methods := rpc.methods(); len(methods) != 0 {
+		t.Errorf("expected nothing to be sent to XO but received calls: %v", methods)
+	}
+}
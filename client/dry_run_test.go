@@ -0,0 +1,66 @@
+package client
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRun_createVmIssuesNoVmCreate(t *testing.T) {
+	rpc := fakeCreateVmRPC()
+	c := &Client{rpc: rpc, dryRun: true}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vm, err := c.CreateVm(vmReq, time.Minute)
+	if err != nil || vm.Id != "" || vm.NameLabel != vmReq.NameLabel {
+		t.Errorf("expected the requested VM to be returned without reading it back but received %+v with error: %v", vm, err)
+	}
+
+	if creates := rpc.callsTo("vm.create"); len(creates) != 0 {
+		t.Errorf("expected no vm.create call in dry run mode but received: %v", creates)
+	}
+	if lookups := rpc.callsTo("xo.getAllObjects"); len(lookups) == 0 {
+		t.Errorf("expected the lookups of CreateVm to be sent to XO but received: %v", rpc.methods())
+	}
+}
+
+func TestDryRun_synthesizesSuccess(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc, dryRun: true}
+
+	var success bool
+	if err := c.Call("vm.delete", map[string]interface{}{"id": "vm-1"}, &success); err != nil {
+		t.Fatalf("expected a synthesized success but received: %v", err)
+	}
+	if !success {
+		t.Errorf("expected the success flag to be set")
+	}
+
+	var id string
+	if err := c.Call("network.create", map[string]interface{}{"name": "net"}, &id); err != nil || id != "" {
+		t.Errorf("expected a zero result without error but received `%s` with error: %v", id, err)
+	}
+
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, nil); err != nil {
+		t.Fatalf("failed to call xo.getAllObjects with error: %v", err)
+	}
+	if methods := rpc.methods(); !reflect.DeepEqual(methods, []string{"xo.getAllObjects"}) {
+		t.Errorf("expected only the read call to be sent but received: %v", methods)
+	}
+}
+
+func TestDryRun_logsSanitizedParams(t *testing.T) {
+	var logs bytes.Buffer
+	c := &Client{rpc: &fakeRPC{}, dryRun: true, logger: log.New(&logs, "", 0)}
+
+	if err := c.Call("user.create", map[string]interface{}{"email": "ops@example.org", "password": "hunter2"}, nil); err != nil {
+		t.Fatalf("failed to call user.create in a dry run with error: %v", err)
+	}
+	if out := logs.String(); strings.Contains(out, "hunter2") || !strings.Contains(out, "ops@example.org") {
+		t.Errorf("expected the password to be redacted from the logged call but received: %s", out)
+	}
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"log"
+	"sync"
+)
+
+// The Ensure helpers make sure an object exists with the given mutable
+// fields. They look the object up by its natural key, create it when it is
+// absent and update the fields that drifted otherwise, reporting whether
+// anything was changed.
+
+// NetworkSpec is the desired state of a network. Its natural key is its
+// pool, name and VLAN, only its description is updated on drift.
+type NetworkSpec struct {
+	PoolId    string
+	NameLabel string
+	// 0 for a network without VLAN
+	Vlan int
+	// PIF the VLAN is created on, only used when the network is created
+	PIFId       string
+	Description string
+}
+
+// Serializes the creation of networks without VLAN by EnsureNetwork, by
+// pool and name
+var ensureNetworkLocks sync.Map
+
+// EnsureNetwork makes sure the network of the spec exists. Concurrent calls
+// for the same VLAN spec create a single network: the VLAN of a PIF can
+// only be created once, so a failed creation is followed by another lookup
+// which returns the network created by the other call. Nothing prevents
+// XAPI from creating several networks without VLAN with the same name,
+// concurrent calls for such a spec are serialized within the process
+// instead. Networks created by other processes meanwhile make the lookup
+// return an AmbiguousResultError.
+func (c *Client) EnsureNetwork(spec NetworkSpec) (*Network, bool, error) {
+	if spec.Vlan == 0 {
+		mu, _ := ensureNetworkLocks.LoadOrStore(spec.PoolId+"/"+spec.NameLabel, &sync.Mutex{})
+		mu.(*sync.Mutex).Lock()
+		defer mu.(*sync.Mutex).Unlock()
+	}
+
+	net, err := c.findNetworkBySpec(spec)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if net == nil {
+		net, err = c.CreateNetwork(Network{
+			PoolId:          spec.PoolId,
+			NameLabel:       spec.NameLabel,
+			NameDescription: spec.Description,
+			PIFId:           spec.PIFId,
+			Vlan:            spec.Vlan,
+		})
+		if err == nil {
+			return net, true, nil
+		}
+
+		createErr := err
+		net, err = c.findNetworkBySpec(spec)
+		if err != nil {
+			return nil, false, err
+		}
+		if net == nil {
+			return nil, false, createErr
+		}
+		log.Printf("[DEBUG] Network `%s` was created concurrently, using network `%s`\n", spec.NameLabel, net.Id)
+	}
+
+	if net.NameDescription == spec.Description {
+		return net, false, nil
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id":               net.Id,
+		"name_description": spec.Description,
+	}
+	err = c.Call("network.set", params, &success)
+	if err != nil {
+		return nil, false, err
+	}
+	net, err = c.GetNetwork(Network{Id: net.Id})
+	if err != nil {
+		return nil, false, err
+	}
+	return net, true, nil
+}
+
+// findNetworkBySpec returns the network of the spec's pool with its name
+// and VLAN, nil when there is none. The VLAN of a network is the one of
+// its PIFs, networks without PIFs have none.
+func (c *Client) findNetworkBySpec(spec NetworkSpec) (*Network, error) {
+	var netsRes map[string]Network
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":       "network",
+			"$poolId":    spec.PoolId,
+			"name_label": spec.NameLabel,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &netsRes)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []Network{}
+	for _, id := range sortedKeys(netsRes) {
+		pifs, err := c.GetPifsOfNetwork(netsRes[id])
+		if err != nil {
+			return nil, err
+		}
+
+		vlan := 0
+		if len(pifs) > 0 && pifs[0].Vlan > 0 {
+			vlan = pifs[0].Vlan
+		}
+		if vlan == spec.Vlan {
+			matches = append(matches, netsRes[id])
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, AmbiguousResultError{Query: Network{PoolId: spec.PoolId, NameLabel: spec.NameLabel, Vlan: spec.Vlan}, Matches: len(matches)}
+}
+
+// EnsureTagOnObject makes sure the object has the tag, other tags are left
+// untouched.
+func (c *Client) EnsureTagOnObject(objectId, tag string) (bool, error) {
+	var objsRes map[string]struct {
+		Tags []string `json:"tags"`
+	}
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"id": objectId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return false, err
+	}
+	obj, ok := objsRes[objectId]
+	if !ok {
+		return false, NotFound{Query: Object{Id: objectId}}
+	}
+
+	if stringInSlice(tag, obj.Tags) {
+		return false, nil
+	}
+	if err := c.AddTag(objectId, tag); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// EnsureCloudConfig makes sure a cloud config with the name exists with the
+// template. Its natural key is its name, which XO doesn't require to be
+// unique: an AmbiguousResultError is returned when several cloud configs
+// share it.
+func (c *Client) EnsureCloudConfig(name, template string) (*CloudConfig, bool, error) {
+	cloudConfigs, err := c.GetCloudConfigByName(name)
+	if _, ok := err.(NotFound); ok {
+		cloudConfig, err := c.CreateCloudConfig(name, template)
+		if err != nil {
+			return nil, false, err
+		}
+		return cloudConfig, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(cloudConfigs) > 1 {
+		return nil, false, AmbiguousResultError{Query: CloudConfig{Name: name}, Matches: len(cloudConfigs)}
+	}
+
+	cloudConfig := cloudConfigs[0]
+	if cloudConfig.Template == template {
+		return &cloudConfig, false, nil
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id":       cloudConfig.Id,
+		"template": template,
+	}
+	err = c.Call("cloudConfig.update", params, &success)
+	if err != nil {
+		return nil, false, err
+	}
+	cloudConfig.Template = template
+	return &cloudConfig, true, nil
+}
+
+// EnsureUser makes sure a user with the email exists. Its natural key is
+// its email, only its permission is updated on drift when set. The
+// password is only used to create the user since XO never returns it.
+func (c *Client) EnsureUser(user User) (*User, bool, error) {
+	existing, err := c.GetUser(User{Email: user.Email})
+	changed := false
+	if _, ok := err.(NotFound); ok {
+		existing, err = c.CreateUser(user)
+		changed = err == nil
+		if err != nil {
+			// XO refuses to create a second user with the same email,
+			// another call may have created it in the meantime
+			createErr := err
+			existing, err = c.GetUser(User{Email: user.Email})
+			if _, ok := err.(NotFound); ok {
+				return nil, false, createErr
+			}
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if user.Permission == "" || user.Permission == existing.Permission {
+		return existing, changed, nil
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id":         existing.Id,
+		"permission": user.Permission,
+	}
+	err = c.Call("user.set", params, &success)
+	if err != nil {
+		return nil, false, err
+	}
+	existing.Permission = user.Permission
+	return existing, true, nil
+}
@@ -0,0 +1,291 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// fakeEnsureNetworkRPC creates networks with a VLAN PIF the way XAPI does,
+// refusing a second VLAN with the same tag on the PIF. The first lookups
+// of the networks wait for each other so that the concurrent calls all
+// find none and race to create it.
+func fakeEnsureNetworkRPC(racers int) *fakeRPC {
+	var mu sync.Mutex
+	objects := []map[string]interface{}{
+		{"id": "pif-eth0", "type": "PIF", "device": "eth0", "vlan": -1, "$network": "net-untagged", "$poolId": "pool-1"},
+		{"id": "net-untagged", "type": "network", "name_label": "tenants", "$poolId": "pool-1"},
+	}
+	barrier := &sync.WaitGroup{}
+	barrier.Add(racers)
+	lookups := 0
+
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			filter := params["filter"].(map[string]interface{})
+			mu.Lock()
+			first := filter["type"] == "network" && lookups < racers
+			if first {
+				lookups++
+			}
+			res := fakeGetAllObjects(params, objects...)
+			mu.Unlock()
+			if first {
+				barrier.Done()
+				barrier.Wait()
+			}
+			return res, nil
+		case "network.create":
+			mu.Lock()
+			defer mu.Unlock()
+			id := fmt.Sprintf("net-%d", len(objects))
+			network := map[string]interface{}{"id": id, "type": "network", "name_label": params["name"], "name_description": params["description"], "$poolId": params["pool"]}
+			if params["pif"] == nil {
+				objects = append(objects, network)
+				return id, nil
+			}
+			vlan := int(params["vlan"].(float64))
+			for _, obj := range objects {
+				if obj["type"] == "PIF" && obj["vlan"] == vlan && obj["$vlanOf"] == params["pif"] {
+					return nil, errors.New("PIF_VLAN_EXISTS")
+				}
+			}
+			objects = append(objects,
+				network,
+				map[string]interface{}{"id": "pif-" + id, "type": "PIF", "vlan": vlan, "$vlanOf": params["pif"], "$network": id, "$poolId": params["pool"]},
+			)
+			return id, nil
+		case "network.set":
+			mu.Lock()
+			defer mu.Unlock()
+			for _, obj := range objects {
+				if obj["id"] == params["id"] {
+					obj["name_description"] = params["name_description"]
+				}
+			}
+			return true, nil
+		}
+		return nil, nil
+	}}
+}
+
+var tenantNetworkSpec = NetworkSpec{
+	PoolId:    "6d3b5b4f-3b6a-4d32-9e6f-1d2a3f4b5c6d",
+	NameLabel: "tenants",
+	Vlan:      20,
+	PIFId:     "0f4d3c2b-1a09-4877-a665-544332211000",
+}
+
+func TestEnsureNetwork_createsThenIsIdempotent(t *testing.T) {
+	rpc := fakeEnsureNetworkRPC(0)
+	c := Client{rpc: rpc}
+
+	net, changed, err := c.EnsureNetwork(tenantNetworkSpec)
+	if err != nil {
+		t.Fatalf("failed to ensure network with error: %v", err)
+	}
+	if !changed || net.NameLabel != "tenants" {
+		t.Errorf("expected the network to be created but received: %+v, changed: %v", net, changed)
+	}
+
+	again, changed, err := c.EnsureNetwork(tenantNetworkSpec)
+	if err != nil {
+		t.Fatalf("failed to ensure network with error: %v", err)
+	}
+	if changed || again.Id != net.Id {
+		t.Errorf("expected network `%s` to be left unchanged but received: %+v, changed: %v", net.Id, again, changed)
+	}
+	if creates := rpc.callsTo("network.create"); len(creates) != 1 {
+		t.Errorf("expected a single network to be created but received: %v", creates)
+	}
+}
+
+func TestEnsureNetwork_updatesDescription(t *testing.T) {
+	rpc := fakeEnsureNetworkRPC(0)
+	c := Client{rpc: rpc}
+
+	if _, _, err := c.EnsureNetwork(tenantNetworkSpec); err != nil {
+		t.Fatalf("failed to ensure network with error: %v", err)
+	}
+
+	spec := tenantNetworkSpec
+	spec.Description = "gold tier"
+	net, changed, err := c.EnsureNetwork(spec)
+	if err != nil {
+		t.Fatalf("failed to ensure network with error: %v", err)
+	}
+	if !changed || net.NameDescription != "gold tier" {
+		t.Errorf("expected the description to be updated but received: %+v, changed: %v", net, changed)
+	}
+	if creates := rpc.callsTo("network.create"); len(creates) != 1 {
+		t.Errorf("expected the network to be updated rather than created again but received: %v", creates)
+	}
+}
+
+func TestEnsureNetwork_concurrentCallsCreateOneNetwork(t *testing.T) {
+	rpc := fakeEnsureNetworkRPC(2)
+	c := Client{rpc: rpc}
+
+	var wg sync.WaitGroup
+	nets := make([]*Network, 2)
+	errs := make([]error, 2)
+	for i := range nets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nets[i], _, errs[i] = c.EnsureNetwork(tenantNetworkSpec)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("expected call %d to succeed but received: %v", i, err)
+		}
+	}
+	if nets[0].Id != nets[1].Id {
+		t.Errorf("expected both calls to return the same network but received `%s` and `%s`", nets[0].Id, nets[1].Id)
+	}
+	if creates := rpc.callsTo("network.create"); len(creates) != 2 {
+		t.Errorf("expected both calls to race to create the network but received: %v", creates)
+	}
+
+	var all map[string]interface{}
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{"filter": map[string]string{"type": "network", "name_label": "tenants"}}, &all); err != nil {
+		t.Fatalf("failed to list networks with error: %v", err)
+	}
+	// The untagged network shares the name but not the VLAN
+	if len(all) != 2 {
+		t.Errorf("expected exactly one VLAN network to be created but found: %v", all)
+	}
+}
+
+func TestEnsureNetwork_concurrentCallsWithoutVlanCreateOneNetwork(t *testing.T) {
+	rpc := fakeEnsureNetworkRPC(0)
+	c := Client{rpc: rpc}
+	spec := NetworkSpec{PoolId: tenantNetworkSpec.PoolId, NameLabel: "storage"}
+
+	var wg sync.WaitGroup
+	nets := make([]*Network, 4)
+	errs := make([]error, 4)
+	for i := range nets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nets[i], _, errs[i] = c.EnsureNetwork(spec)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("expected call %d to succeed but received: %v", i, err)
+		}
+		if nets[i].Id != nets[0].Id {
+			t.Errorf("expected every call to return network `%s` but call %d received `%s`", nets[0].Id, i, nets[i].Id)
+		}
+	}
+	if creates := rpc.callsTo("network.create"); len(creates) != 1 {
+		t.Errorf("expected a single network to be created but received: %v", creates)
+	}
+}
+
+func TestEnsureTagOnObject(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, map[string]interface{}{"id": "vm-1", "type": "VM", "tags": []string{"prod"}}), nil
+		}
+		return true, nil
+	}}
+	c := Client{rpc: rpc}
+
+	changed, err := c.EnsureTagOnObject("vm-1", "prod")
+	if err != nil || changed {
+		t.Errorf("expected an existing tag to be left alone but received changed: %v, err: %v", changed, err)
+	}
+	changed, err = c.EnsureTagOnObject("vm-1", "tier-gold")
+	if err != nil || !changed {
+		t.Errorf("expected a missing tag to be added but received changed: %v, err: %v", changed, err)
+	}
+	if adds := rpc.callsTo("tag.add"); len(adds) != 1 || adds[0].params["tag"] != "tier-gold" {
+		t.Errorf("expected only the missing tag to be added but received: %v", adds)
+	}
+
+	var notFound NotFound
+	if _, err := c.EnsureTagOnObject("vm-2", "prod"); !errors.As(err, &notFound) {
+		t.Errorf("expected a NotFound error for an unknown object but received: %v", err)
+	}
+}
+
+func TestEnsureCloudConfig(t *testing.T) {
+	configs := []map[string]interface{}{
+		{"id": "cc-1", "name": "base", "template": "#cloud-config\n"},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "cloudConfig.getAll":
+			return configs, nil
+		case "cloudConfig.create":
+			configs = append(configs, map[string]interface{}{"id": "cc-2", "name": params["name"], "template": params["template"]})
+		case "cloudConfig.update":
+			configs[0]["template"] = params["template"]
+		}
+		return true, nil
+	}}
+	c := Client{rpc: rpc}
+
+	cc, changed, err := c.EnsureCloudConfig("base", "#cloud-config\n")
+	if err != nil || changed || cc.Id != "cc-1" {
+		t.Errorf("expected cc-1 to be left unchanged but received: %+v, changed: %v, err: %v", cc, changed, err)
+	}
+
+	cc, changed, err = c.EnsureCloudConfig("base", "#cloud-config\nhostname: web\n")
+	if err != nil || !changed || cc.Template != "#cloud-config\nhostname: web\n" {
+		t.Errorf("expected the template of cc-1 to be updated but received: %+v, changed: %v, err: %v", cc, changed, err)
+	}
+	if updates := rpc.callsTo("cloudConfig.update"); len(updates) != 1 || updates[0].params["id"] != "cc-1" {
+		t.Errorf("expected cc-1 to be updated but received: %v", updates)
+	}
+
+	cc, changed, err = c.EnsureCloudConfig("web", "#cloud-config\n")
+	if err != nil || !changed || cc.Id != "cc-2" {
+		t.Errorf("expected a cloud config to be created but received: %+v, changed: %v, err: %v", cc, changed, err)
+	}
+}
+
+func TestEnsureUser(t *testing.T) {
+	users := []map[string]interface{}{
+		{"id": "user-1", "email": "alice@example.com", "permission": "none"},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "user.getAll":
+			return users, nil
+		case "user.create":
+			users = append(users, map[string]interface{}{"id": "user-2", "email": params["email"]})
+			return "user-2", nil
+		}
+		return true, nil
+	}}
+	c := Client{rpc: rpc}
+
+	user, changed, err := c.EnsureUser(User{Email: "alice@example.com"})
+	if err != nil || changed || user.Id != "user-1" {
+		t.Errorf("expected user-1 to be left unchanged but received: %+v, changed: %v, err: %v", user, changed, err)
+	}
+
+	user, changed, err = c.EnsureUser(User{Email: "alice@example.com", Permission: "admin"})
+	if err != nil || !changed || user.Permission != "admin" {
+		t.Errorf("expected the permission of user-1 to be updated but received: %+v, changed: %v, err: %v", user, changed, err)
+	}
+	if sets := rpc.callsTo("user.set"); len(sets) != 1 || sets[0].params["permission"] != "admin" {
+		t.Errorf("expected the permission to be set but received: %v", sets)
+	}
+
+	user, changed, err = c.EnsureUser(User{Email: "bob@example.com", Password: "secret"})
+	if err != nil || !changed || user.Id != "user-2" {
+		t.Errorf("expected bob to be created but received: %+v, changed: %v, err: %v", user, changed, err)
+	}
+}
@@ -1,9 +1,16 @@
 package client
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
 )
 
+// ErrNotFound matches every NotFound error with errors.Is.
+var ErrNotFound = errors.New("object not found")
+
 type NotFound struct {
 	Query XoObject
 }
@@ -11,3 +18,110 @@ type NotFound struct {
 func (e NotFound) Error() string {
 	return fmt.Sprintf("Could not find %[1]T with query: %+[1]v", e.Query)
 }
+
+func (e NotFound) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// UnsupportedOnThisServerError is returned when the XO server does not
+// implement a method the client relies on, usually because it is too old.
+type UnsupportedOnThisServerError struct {
+	Method string
+	// Set when the server implements the method but isn't set up for it
+	Reason string
+}
+
+func (e UnsupportedOnThisServerError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("XO server cannot use the `%s` method: %s", e.Method, e.Reason)
+	}
+	return fmt.Sprintf("XO server does not support the `%s` method", e.Method)
+}
+
+// featureDetect converts the error returned by XO for unknown
+// methods into an UnsupportedOnThisServerError.
+func featureDetect(method string, err error) error {
+	var rpcErr *jsonrpc2.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc2.CodeMethodNotFound {
+		return UnsupportedOnThisServerError{Method: method}
+	}
+	return err
+}
+
+// GuestToolsIsoNotFoundError is returned by FindGuestToolsIso when no SR
+// of the pool holds a guest tools ISO. It matches ErrNotFound with
+// errors.Is.
+type GuestToolsIsoNotFoundError struct {
+	PoolId string
+	// Names of the SRs searched
+	ToolsSrs []string
+	IsoSrs   []string
+}
+
+func (e GuestToolsIsoNotFoundError) Error() string {
+	if len(e.ToolsSrs)+len(e.IsoSrs) == 0 {
+		return fmt.Sprintf("could not find the guest tools ISO of pool `%s`: the pool has no ISO SR", e.PoolId)
+	}
+	searched := []string{}
+	for _, name := range e.ToolsSrs {
+		searched = append(searched, fmt.Sprintf("`%s` (tools SR)", name))
+	}
+	for _, name := range e.IsoSrs {
+		searched = append(searched, fmt.Sprintf("`%s`", name))
+	}
+	return fmt.Sprintf("could not find the guest tools ISO of pool `%s` in SRs %s, expected a tools SR or an ISO named like one of %v", e.PoolId, strings.Join(searched, ", "), guestToolsIsoNames)
+}
+
+func (e GuestToolsIsoNotFoundError) Unwrap() error {
+	return NotFound{Query: VDI{PoolId: e.PoolId}}
+}
+
+// AmbiguousResultError is returned when a query expected to match a
+// single object matches several.
+type AmbiguousResultError struct {
+	Query   XoObject
+	Matches int
+}
+
+func (e AmbiguousResultError) Error() string {
+	return fmt.Sprintf("expected a single %[1]T to match query %+[1]v, instead found %[2]d", e.Query, e.Matches)
+}
+
+// NoDefaultSrError is returned when an SR is required to fall back on
+// the default SR of a pool that doesn't have one.
+type NoDefaultSrError struct {
+	PoolId string
+}
+
+func (e NoDefaultSrError) Error() string {
+	return fmt.Sprintf("pool `%s` does not have a default SR", e.PoolId)
+}
+
+// NameConflictError is returned when a VM is created with a name already
+// used by another VM of the pool.
+type NameConflictError struct {
+	NameLabel string
+	PoolId    string
+	VmId      string
+}
+
+func (e NameConflictError) Error() string {
+	return fmt.Sprintf("VM `%s` already exists in pool `%s` with the name `%s`", e.VmId, e.PoolId, e.NameLabel)
+}
+
+// ConflictError is returned when an update is given the object it is based
+// on and the object was modified since it was read. Changes goes from the
+// value read to the current one.
+type ConflictError struct {
+	Type    string
+	Id      string
+	Changes []FieldChange
+}
+
+func (e ConflictError) Error() string {
+	fields := make([]string, 0, len(e.Changes))
+	for _, change := range e.Changes {
+		fields = append(fields, change.Field)
+	}
+	return fmt.Sprintf("%s `%s` was modified since it was read, fields changed: %s", e.Type, e.Id, strings.Join(fields, ", "))
+}
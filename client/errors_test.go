@@ -1,8 +1,11 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
 )
 
 func TestNotFoundErrorMessage(t *testing.T) {
@@ -20,3 +23,21 @@ func TestNotFoundErrorMessage(t *testing.T) {
 		t.Errorf("NotFound Error() message expected to be '%s' but received '%s'", expectedMsg, msg)
 	}
 }
+
+func TestFeatureDetect_methodNotFound(t *testing.T) {
+	var data json.RawMessage = []byte(`"vdi.enableCbt"`)
+	c := Client{
+		rpc: jsonRPCFail{
+			err: &jsonrpc2.Error{
+				Code:    jsonrpc2.CodeMethodNotFound,
+				Message: "method not found",
+				Data:    &data,
+			},
+		},
+	}
+
+	err := c.EnableVdiCbt("vdi-id")
+	if _, ok := err.(UnsupportedOnThisServerError); !ok {
+		t.Errorf("expected UnsupportedOnThisServerError but received: %v", err)
+	}
+}
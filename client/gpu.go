@@ -0,0 +1,254 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// PGPU is a physical GPU of a host.
+type PGPU struct {
+	Id       string `json:"id"`
+	Host     string `json:"$host"`
+	PoolId   string `json:"$poolId"`
+	GpuGroup string `json:"gpuGroup"`
+	// Ids of the vGPU types the GPU can provide and of the ones it is
+	// allowed to provide
+	SupportedVgpuTypes []string `json:"supportedVgpuTypes"`
+	EnabledVgpuTypes   []string `json:"enabledVgpuTypes"`
+	// Number of vGPUs of each type the GPU can provide, keyed by vGPU
+	// type id. XO misspells the property.
+	MaxCapacities map[string]int64 `json:"supportedVgpuMaxCapcities"`
+	// Ids of the vGPUs of running VMs the GPU provides
+	Vgpus []string `json:"vgpus"`
+}
+
+func (p PGPU) Compare(obj interface{}) bool {
+	other := obj.(PGPU)
+	return p.Id == other.Id
+}
+
+// VGPUType is a kind of virtual GPU a physical GPU can be split into.
+type VGPUType struct {
+	Id              string `json:"id"`
+	ModelName       string `json:"modelName"`
+	VendorName      string `json:"vendorName"`
+	FramebufferSize int64  `json:"framebufferSize"`
+	MaxHeads        int    `json:"maxHeads"`
+	MaxResolutionX  int    `json:"maxResolutionX"`
+	MaxResolutionY  int    `json:"maxResolutionY"`
+	Experimental    bool   `json:"experimental"`
+}
+
+// VGPU is a virtual GPU of a VM.
+type VGPU struct {
+	Id       string `json:"id"`
+	Vm       string `json:"vm"`
+	VgpuType string `json:"vgpuType"`
+	GpuGroup string `json:"gpuGroup"`
+	// Id of the physical GPU providing the vGPU, empty while the VM is
+	// halted
+	ResidentOn        string `json:"resident_on"`
+	CurrentlyAttached bool   `json:"currentlyAttached"`
+}
+
+// VgpuCapacityExceededError is returned when attaching a vGPU to a VM
+// while the GPUs able to provide it have none left.
+type VgpuCapacityExceededError struct {
+	VmId       string
+	VgpuTypeId string
+	// Number of vGPUs of the type the GPUs of the host with the most
+	// room can provide and how many of them are already attached
+	Capacity int64
+	Used     int64
+}
+
+func (e VgpuCapacityExceededError) Error() string {
+	return fmt.Sprintf("cannot attach a vgpu of type `%s` to vm `%s`: %d of the %d vgpus available are already attached", e.VgpuTypeId, e.VmId, e.Used, e.Capacity)
+}
+
+// GetPGPUs returns the physical GPUs of the host, sorted by id.
+func (c *Client) GetPGPUs(hostId string) ([]PGPU, error) {
+	var pgpusRes map[string]PGPU
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":  "PGPU",
+			"$host": hostId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &pgpusRes)
+	if err != nil {
+		return nil, err
+	}
+
+	pgpus := []PGPU{}
+	for _, id := range sortedKeys(pgpusRes) {
+		pgpus = append(pgpus, pgpusRes[id])
+	}
+	return pgpus, nil
+}
+
+// GetVGPUTypes returns the vGPU types the physical GPU supports, sorted by
+// id.
+func (c *Client) GetVGPUTypes(pgpuId string) ([]VGPUType, error) {
+	var pgpu PGPU
+	if err := c.getObjectOfType("PGPU", pgpuId, PGPU{Id: pgpuId}, &pgpu); err != nil {
+		return nil, err
+	}
+
+	var vgpuTypesRes map[string]VGPUType
+	if err := c.getAllObjectsOfXoType("vgpuType", &vgpuTypesRes); err != nil {
+		return nil, err
+	}
+
+	vgpuTypes := []VGPUType{}
+	for _, id := range sortedKeys(vgpuTypesRes) {
+		if stringInSlice(id, pgpu.SupportedVgpuTypes) {
+			vgpuTypes = append(vgpuTypes, vgpuTypesRes[id])
+		}
+	}
+	return vgpuTypes, nil
+}
+
+// vgpuSlot groups the GPUs of a GPU group on a host, which provide the
+// vGPUs of the VMs started on the host.
+type vgpuSlot struct {
+	host     string
+	gpuGroup string
+	capacity int64
+	used     int64
+}
+
+// AttachVgpu attaches a vGPU of the type to a halted VM. The vGPU is
+// taken from the GPUs of the VM's pool with the most room left, or from
+// the ones of the VM's affinity host when it has one. A VM can only start
+// on a host with a GPU providing its vGPU, so the VM's affinity host is set
+// to the GPU's host and isn't reset by DetachVgpu.
+func (c *Client) AttachVgpu(vmId, vgpuTypeId string) error {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return err
+	}
+
+	slots, err := c.getVgpuSlots(*vm, vgpuTypeId)
+	if err != nil {
+		return err
+	}
+	if len(slots) == 0 {
+		return errors.New(fmt.Sprintf("no GPU of pool `%s` provides vgpus of type `%s`", vm.PoolId, vgpuTypeId))
+	}
+
+	best := slots[0]
+	for _, slot := range slots[1:] {
+		if slot.capacity-slot.used > best.capacity-best.used {
+			best = slot
+		}
+	}
+	if best.used >= best.capacity {
+		return VgpuCapacityExceededError{VmId: vmId, VgpuTypeId: vgpuTypeId, Capacity: best.capacity, Used: best.used}
+	}
+
+	var vgpuId string
+	params := map[string]interface{}{
+		"vm":       vmId,
+		"gpuGroup": best.gpuGroup,
+		"vgpuType": vgpuTypeId,
+	}
+	err = c.Call("vm.createVgpu", params, &vgpuId)
+	if err != nil {
+		return err
+	}
+
+	if vm.AffinityHost == best.host {
+		return nil
+	}
+	log.Printf("[DEBUG] Setting the affinity host of vm `%s` to host `%s` providing its vgpu `%s`\n", vmId, best.host, vgpuId)
+	var success bool
+	params = map[string]interface{}{
+		"id":           vmId,
+		"affinityHost": best.host,
+	}
+	return c.Call("vm.set", params, &success)
+}
+
+// getVgpuSlots returns the GPUs of the VM's pool able to provide vGPUs of
+// the type, grouped by host and GPU group and sorted. The vGPUs of halted
+// VMs aren't resident on any GPU, they are counted on the GPUs of their
+// VM's affinity host.
+func (c *Client) getVgpuSlots(vm Vm, vgpuTypeId string) ([]*vgpuSlot, error) {
+	var pgpus map[string]PGPU
+	if err := c.getAllObjectsOfXoType("PGPU", &pgpus); err != nil {
+		return nil, err
+	}
+	var vgpus map[string]VGPU
+	if err := c.getAllObjectsOfXoType("vgpu", &vgpus); err != nil {
+		return nil, err
+	}
+	var vms map[string]Vm
+	if err := c.getAllObjectsOfXoType("VM", &vms); err != nil {
+		return nil, err
+	}
+
+	slotsByKey := map[string]*vgpuSlot{}
+	for _, id := range sortedKeys(pgpus) {
+		pgpu := pgpus[id]
+		if pgpu.PoolId != vm.PoolId || !stringInSlice(vgpuTypeId, pgpu.EnabledVgpuTypes) {
+			continue
+		}
+		if vm.AffinityHost != "" && pgpu.Host != vm.AffinityHost {
+			continue
+		}
+
+		key := pgpu.Host + "/" + pgpu.GpuGroup
+		slot, ok := slotsByKey[key]
+		if !ok {
+			slot = &vgpuSlot{host: pgpu.Host, gpuGroup: pgpu.GpuGroup}
+			slotsByKey[key] = slot
+		}
+		slot.capacity += pgpu.MaxCapacities[vgpuTypeId]
+	}
+
+	for _, vgpu := range vgpus {
+		host := vms[vgpu.Vm].AffinityHost
+		if pgpu, ok := pgpus[vgpu.ResidentOn]; ok {
+			host = pgpu.Host
+		}
+		if slot, ok := slotsByKey[host+"/"+vgpu.GpuGroup]; ok {
+			slot.used++
+		}
+	}
+
+	slots := []*vgpuSlot{}
+	for _, key := range sortedKeys(slotsByKey) {
+		slots = append(slots, slotsByKey[key])
+	}
+	return slots, nil
+}
+
+// DetachVgpu removes the vGPUs of the type from a halted VM.
+func (c *Client) DetachVgpu(vmId, vgpuTypeId string) error {
+	var vgpus map[string]VGPU
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":     "vgpu",
+			"vm":       vmId,
+			"vgpuType": vgpuTypeId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &vgpus)
+	if err != nil {
+		return err
+	}
+	if len(vgpus) == 0 {
+		return errors.New(fmt.Sprintf("vm `%s` has no vgpu of type `%s`", vmId, vgpuTypeId))
+	}
+
+	for _, id := range sortedKeys(vgpus) {
+		var success bool
+		err := c.Call("vm.deleteVgpu", map[string]interface{}{"vgpu": id}, &success)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
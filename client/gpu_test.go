@@ -0,0 +1,137 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+// host-1 has a GPU providing 2 vgpus of type-1 and host-2 one providing
+// 4. host-1's GPU provides vm-running's vgpu and vm-halted is pinned to
+// host-2 with a vgpu not resident on any GPU yet.
+var gpuObjects = []map[string]interface{}{
+	{"id": "pgpu-1", "type": "PGPU", "$host": "host-1", "$poolId": "pool-1", "gpuGroup": "group-1",
+		"supportedVgpuTypes": []string{"type-1", "type-2"}, "enabledVgpuTypes": []string{"type-1"},
+		"supportedVgpuMaxCapcities": map[string]int64{"type-1": 2, "type-2": 8}, "vgpus": []string{"vgpu-1"}},
+	{"id": "pgpu-2", "type": "PGPU", "$host": "host-2", "$poolId": "pool-1", "gpuGroup": "group-1",
+		"supportedVgpuTypes": []string{"type-1"}, "enabledVgpuTypes": []string{"type-1"},
+		"supportedVgpuMaxCapcities": map[string]int64{"type-1": 4}, "vgpus": []string{}},
+	{"id": "type-1", "type": "vgpuType", "modelName": "GRID M60-2Q", "vendorName": "NVIDIA Corporation", "maxHeads": 4},
+	{"id": "type-2", "type": "vgpuType", "modelName": "GRID M60-0B", "vendorName": "NVIDIA Corporation", "maxHeads": 2},
+	{"id": "type-3", "type": "vgpuType", "modelName": "GRID K1", "vendorName": "NVIDIA Corporation", "maxHeads": 2},
+	{"id": "vgpu-1", "type": "vgpu", "vm": "vm-running", "vgpuType": "type-1", "gpuGroup": "group-1", "resident_on": "pgpu-1"},
+	{"id": "vgpu-2", "type": "vgpu", "vm": "vm-halted", "vgpuType": "type-1", "gpuGroup": "group-1", "resident_on": ""},
+	{"id": "vm-running", "type": "VM", "name_label": "running", "power_state": "Running", "$poolId": "pool-1", "$container": "host-1"},
+	{"id": "vm-halted", "type": "VM", "name_label": "halted", "power_state": "Halted", "$poolId": "pool-1", "$container": "pool-1", "affinityHost": "host-2"},
+	{"id": "vm-1", "type": "VM", "name_label": "workstation", "power_state": "Halted", "$poolId": "pool-1", "$container": "pool-1"},
+}
+
+func fakeGpuRPC(objects ...map[string]interface{}) *fakeRPC {
+	objects = append(objects, gpuObjects...)
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "vm.createVgpu":
+			return "vgpu-new", nil
+		}
+		return true, nil
+	}}
+}
+
+func TestGetPGPUs(t *testing.T) {
+	c := &Client{rpc: fakeGpuRPC()}
+
+	pgpus, err := c.GetPGPUs("host-1")
+	if err != nil {
+		t.Fatalf("failed to get pgpus with error: %v", err)
+	}
+	if len(pgpus) != 1 || pgpus[0].Id != "pgpu-1" || pgpus[0].MaxCapacities["type-2"] != 8 {
+		t.Errorf("expected pgpu-1 to be returned with its capacities but received: %+v", pgpus)
+	}
+}
+
+func TestGetVGPUTypes(t *testing.T) {
+	c := &Client{rpc: fakeGpuRPC()}
+
+	vgpuTypes, err := c.GetVGPUTypes("pgpu-1")
+	if err != nil {
+		t.Fatalf("failed to get vgpu types with error: %v", err)
+	}
+	if len(vgpuTypes) != 2 || vgpuTypes[0].ModelName != "GRID M60-2Q" || vgpuTypes[1].Id != "type-2" {
+		t.Errorf("expected the supported vgpu types to be returned but received: %+v", vgpuTypes)
+	}
+}
+
+func TestAttachVgpu_pinsVmToGpuHost(t *testing.T) {
+	rpc := fakeGpuRPC()
+	c := &Client{rpc: rpc}
+
+	if err := c.AttachVgpu("vm-1", "type-1"); err != nil {
+		t.Fatalf("failed to attach vgpu with error: %v", err)
+	}
+
+	// host-2 has 3 vgpus left, host-1 only 1
+	create := rpc.callsTo("vm.createVgpu")
+	if len(create) != 1 {
+		t.Fatalf("expected a vgpu to be created but received calls: %v", rpc.methods())
+	}
+	params := create[0].params
+	if params["vm"] != "vm-1" || params["gpuGroup"] != "group-1" || params["vgpuType"] != "type-1" {
+		t.Errorf("expected a vgpu of type-1 from group-1 to be created for vm-1 but received: %v", params)
+	}
+
+	set := rpc.callsTo("vm.set")
+	if len(set) != 1 || set[0].params["id"] != "vm-1" || set[0].params["affinityHost"] != "host-2" {
+		t.Errorf("expected vm-1 to be pinned to host-2 but received: %v", set)
+	}
+}
+
+func TestAttachVgpu_overcommit(t *testing.T) {
+	rpc := fakeGpuRPC(
+		map[string]interface{}{"id": "vm-2", "type": "VM", "name_label": "pinned", "power_state": "Halted", "$poolId": "pool-1", "$container": "pool-1", "affinityHost": "host-1"},
+		map[string]interface{}{"id": "vgpu-3", "type": "vgpu", "vm": "vm-2", "vgpuType": "type-1", "gpuGroup": "group-1", "resident_on": ""},
+		map[string]interface{}{"id": "vm-3", "type": "VM", "name_label": "pinned too", "power_state": "Halted", "$poolId": "pool-1", "$container": "pool-1", "affinityHost": "host-1"},
+	)
+	c := &Client{rpc: rpc}
+
+	err := c.AttachVgpu("vm-3", "type-1")
+	var capacityErr VgpuCapacityExceededError
+	if !errors.As(err, &capacityErr) {
+		t.Fatalf("expected a VgpuCapacityExceededError but received: %v", err)
+	}
+	if capacityErr.Capacity != 2 || capacityErr.Used != 2 {
+		t.Errorf("expected the 2 vgpus of host-1 to be reported as used but received: %+v", capacityErr)
+	}
+	if len(rpc.callsTo("vm.createVgpu")) != 0 || len(rpc.callsTo("vm.set")) != 0 {
+		t.Errorf("expected no vgpu to be created but received calls: %v", rpc.methods())
+	}
+}
+
+func TestAttachVgpu_unsupportedType(t *testing.T) {
+	rpc := fakeGpuRPC()
+	c := &Client{rpc: rpc}
+
+	if err := c.AttachVgpu("vm-1", "type-2"); err == nil {
+		t.Errorf("expected attaching a vgpu type no GPU has enabled to fail")
+	}
+	if len(rpc.callsTo("vm.createVgpu")) != 0 {
+		t.Errorf("expected no vgpu to be created but received calls: %v", rpc.methods())
+	}
+}
+
+func TestDetachVgpu(t *testing.T) {
+	rpc := fakeGpuRPC()
+	c := &Client{rpc: rpc}
+
+	if err := c.DetachVgpu("vm-halted", "type-1"); err != nil {
+		t.Fatalf("failed to detach vgpu with error: %v", err)
+	}
+	calls := rpc.callsTo("vm.deleteVgpu")
+	if len(calls) != 1 || calls[0].params["vgpu"] != "vgpu-2" {
+		t.Errorf("expected vgpu-2 to be deleted but received: %v", calls)
+	}
+
+	if err := c.DetachVgpu("vm-1", "type-1"); err == nil {
+		t.Errorf("expected detaching a vgpu from a vm without one to fail")
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+type Group struct {
+	Id    string   `json:"id"`
+	Name  string   `json:"name"`
+	Users []string `json:"users"`
+}
+
+func (g Group) Compare(obj interface{}) bool {
+	other := obj.(Group)
+
+	if g.Id != "" && g.Id == other.Id {
+		return true
+	}
+
+	if g.Name != "" && g.Name == other.Name {
+		return true
+	}
+
+	return false
+}
+
+func (c *Client) GetGroups() ([]Group, error) {
+	params := map[string]interface{}{
+		"dummy": "dummy",
+	}
+	groups := []Group{}
+	err := c.Call("group.getAll", params, &groups)
+
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[DEBUG] Found the following groups: %v\n", groups)
+	return groups, nil
+}
+
+func (c *Client) GetGroup(groupReq Group) (*Group, error) {
+	groups, err := c.GetGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		if groupReq.Compare(group) {
+			return &group, nil
+		}
+	}
+
+	return nil, NotFound{Query: groupReq}
+}
+
+func (c *Client) CreateGroup(name string) (*Group, error) {
+	var id string
+	params := map[string]interface{}{
+		"name": name,
+	}
+	err := c.Call("group.create", params, &id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Group{Id: id, Name: name, Users: []string{}}, nil
+}
+
+func (c *Client) DeleteGroup(id string) error {
+	_, err := c.deleteGroup(id)
+	return err
+}
+
+// DeleteGroupWithResult deletes the group like DeleteGroup. A group that
+// doesn't exist is reported as already absent.
+func (c *Client) DeleteGroupWithResult(id string) (*DeleteResult, error) {
+	return absentIfNoSuchObject(c.deleteGroup(id))
+}
+
+func (c *Client) deleteGroup(id string) (*DeleteResult, error) {
+	res := &DeleteResult{DeletedObject: DeletedObject{Type: "group", Id: id}}
+	var success bool
+	params := map[string]interface{}{
+		"id": id,
+	}
+	err := c.Call("group.delete", params, &success)
+
+	if err != nil {
+		return res, err
+	}
+
+	if !success {
+		return res, errors.New(fmt.Sprintf("failed to delete group `%s`", id))
+	}
+	return res, nil
+}
+
+func (c *Client) AddUserToGroup(groupId, userId string) error {
+	var success bool
+	params := map[string]interface{}{
+		"id":     groupId,
+		"userId": userId,
+	}
+	return c.Call("group.addUser", params, &success)
+}
+
+func (c *Client) RemoveUserFromGroup(groupId, userId string) error {
+	var success bool
+	params := map[string]interface{}{
+		"id":     groupId,
+		"userId": userId,
+	}
+	return c.Call("group.removeUser", params, &success)
+}
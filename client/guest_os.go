@@ -0,0 +1,115 @@
+package client
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GuestOsUnknown groups the VMs whose OS isn't reported by their guest
+// tools or isn't recognized.
+const GuestOsUnknown = "unknown"
+
+// guestOsNames normalizes the OS names reported by the guest tools, in
+// order of precedence.
+var guestOsNames = []struct {
+	pattern *regexp.Regexp
+	name    string
+}{
+	{regexp.MustCompile(`(?i)centos stream`), "centos stream"},
+	{regexp.MustCompile(`(?i)centos`), "centos"},
+	{regexp.MustCompile(`(?i)red hat enterprise linux|\brhel\b`), "rhel"},
+	{regexp.MustCompile(`(?i)rocky`), "rocky"},
+	{regexp.MustCompile(`(?i)almalinux`), "almalinux"},
+	{regexp.MustCompile(`(?i)oracle linux`), "oracle linux"},
+	{regexp.MustCompile(`(?i)ubuntu`), "ubuntu"},
+	{regexp.MustCompile(`(?i)debian`), "debian"},
+	{regexp.MustCompile(`(?i)alpine`), "alpine"},
+	{regexp.MustCompile(`(?i)freebsd`), "freebsd"},
+	{regexp.MustCompile(`(?i)windows server`), "windows server"},
+	{regexp.MustCompile(`(?i)windows`), "windows"},
+}
+
+var (
+	guestOsMajorVersion = regexp.MustCompile(`\d+`)
+	windowsR2Release    = regexp.MustCompile(`(?i)^\D*\d+ r2\b`)
+)
+
+// VmSummary identifies a VM in reports covering many VMs.
+type VmSummary struct {
+	Id        string
+	NameLabel string
+	PoolId    string
+	HostId    string
+	// Whether the guest tools are running in the VM
+	ManagementAgentDetected bool
+	PvDriversDetected       bool
+}
+
+// NormalizeGuestOs returns the OS name and major version, e.g. `centos 7`
+// or `windows server 2012 r2`, from the `os_version` reported by the
+// guest tools, or GuestOsUnknown.
+func NormalizeGuestOs(osVersion map[string]string) string {
+	// Windows guests report the name followed by the system directory and
+	// partition, separated by `|`
+	name := strings.Split(osVersion["name"], "|")[0]
+	for _, entry := range guestOsNames {
+		loc := entry.pattern.FindStringIndex(name)
+		if loc == nil {
+			continue
+		}
+
+		rest := name[loc[1]:]
+		version := guestOsMajorVersion.FindString(rest)
+		if version == "" {
+			version = osVersion["major"]
+		}
+		if version == "" {
+			return entry.name
+		}
+		if entry.name == "windows server" && windowsR2Release.MatchString(rest) {
+			version += " r2"
+		}
+		return entry.name + " " + version
+	}
+
+	if distro := strings.ToLower(osVersion["distro"]); distro != "" {
+		if major := osVersion["major"]; major != "" {
+			return distro + " " + major
+		}
+		return distro
+	}
+	return GuestOsUnknown
+}
+
+// GetGuestOsInventory groups the running VMs matching filter, every
+// running VM when filter is empty, by the OS normalized by
+// NormalizeGuestOs.
+func (c *Client) GetGuestOsInventory(filter Vm) (map[string][]VmSummary, error) {
+	vms, err := c.findVms(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := map[string][]VmSummary{}
+	for _, vm := range vms {
+		if vm.PowerState != PowerStateRunning {
+			continue
+		}
+		os := NormalizeGuestOs(vm.OsVersion)
+		inventory[os] = append(inventory[os], VmSummary{
+			Id:                      vm.Id,
+			NameLabel:               vm.NameLabel,
+			PoolId:                  vm.PoolId,
+			HostId:                  vm.Host,
+			ManagementAgentDetected: vm.ManagementAgentDetected,
+			PvDriversDetected:       vm.PvDriversDetected,
+		})
+	}
+	for _, summaries := range inventory {
+		sort.Slice(summaries, func(i, j int) bool {
+			return summaries[i].Id < summaries[j].Id
+		})
+	}
+	return inventory, nil
+}
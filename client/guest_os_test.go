@@ -0,0 +1,75 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestNormalizeGuestOs(t *testing.T) {
+	tests := []struct {
+		osVersion map[string]string
+		expected  string
+	}{
+		{map[string]string{"name": "CentOS Linux release 7.9.2009 (Core)", "distro": "centos", "major": "7"}, "centos 7"},
+		{map[string]string{"name": "CentOS Linux 7 (Core)"}, "centos 7"},
+		{map[string]string{"name": "CentOS release 6.10 (Final)"}, "centos 6"},
+		{map[string]string{"name": "CentOS Stream release 9", "distro": "centos", "major": "9"}, "centos stream 9"},
+		{map[string]string{"name": "Red Hat Enterprise Linux Server release 7.9 (Maipo)"}, "rhel 7"},
+		{map[string]string{"name": "Rocky Linux 8.6 (Green Obsidian)"}, "rocky 8"},
+		{map[string]string{"name": "AlmaLinux 9.1 (Lime Lynx)"}, "almalinux 9"},
+		{map[string]string{"name": "Ubuntu 20.04.6 LTS", "distro": "ubuntu", "major": "20"}, "ubuntu 20"},
+		{map[string]string{"name": "Ubuntu 18.04 LTS"}, "ubuntu 18"},
+		{map[string]string{"name": "Debian GNU/Linux 11 (bullseye)"}, "debian 11"},
+		{map[string]string{"name": "Alpine Linux v3.17"}, "alpine 3"},
+		{map[string]string{"name": "FreeBSD 13.1-RELEASE"}, "freebsd 13"},
+		{map[string]string{"name": "Microsoft Windows Server 2019 Standard|C:\\Windows|\\Device\\Harddisk0\\Partition2"}, "windows server 2019"},
+		{map[string]string{"name": "Microsoft Windows Server 2012 R2 Datacenter|C:\\Windows|\\Device\\Harddisk0\\Partition2"}, "windows server 2012 r2"},
+		{map[string]string{"name": "Microsoft Windows 10 Pro|C:\\WINDOWS|\\Device\\Harddisk0\\Partition2"}, "windows 10"},
+		{map[string]string{"name": "openSUSE Leap 15.4", "distro": "opensuse-leap", "major": "15"}, "opensuse-leap 15"},
+		{map[string]string{"name": "Arch Linux"}, GuestOsUnknown},
+		{map[string]string{}, GuestOsUnknown},
+		{nil, GuestOsUnknown},
+	}
+
+	for _, test := range tests {
+		if os := NormalizeGuestOs(test.osVersion); os != test.expected {
+			t.Errorf("expected %v to be normalized to `%s` but received `%s`", test.osVersion, test.expected, os)
+		}
+	}
+}
+
+func TestGetGuestOsInventory(t *testing.T) {
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "legacy", "power_state": "Running", "$poolId": "pool-1", "$container": "host-1", "managementAgentDetected": true, "pvDriversDetected": true, "os_version": map[string]string{"name": "CentOS Linux release 7.9.2009 (Core)"}},
+			map[string]interface{}{"id": "vm-2", "type": "VM", "name_label": "legacy-2", "power_state": "Running", "$poolId": "pool-2", "os_version": map[string]string{"name": "CentOS Linux 7 (Core)"}},
+			map[string]interface{}{"id": "vm-3", "type": "VM", "name_label": "no-tools", "power_state": "Running", "$poolId": "pool-1", "os_version": nil},
+			map[string]interface{}{"id": "vm-4", "type": "VM", "name_label": "stopped", "power_state": "Halted", "$poolId": "pool-1", "os_version": map[string]string{"name": "Ubuntu 22.04.3 LTS"}},
+		), nil
+	}}}
+
+	inventory, err := c.GetGuestOsInventory(Vm{})
+	if err != nil {
+		t.Fatalf("failed to get the guest OS inventory with error: %v", err)
+	}
+	if len(inventory) != 2 {
+		t.Errorf("expected only running VMs to be grouped but received: %+v", inventory)
+	}
+
+	centos := inventory["centos 7"]
+	if len(centos) != 2 || centos[0].Id != "vm-1" || centos[1].Id != "vm-2" {
+		t.Fatalf("expected vm-1 and vm-2 to run CentOS 7 but received: %+v", centos)
+	}
+	expected := VmSummary{Id: "vm-1", NameLabel: "legacy", PoolId: "pool-1", HostId: "host-1", ManagementAgentDetected: true, PvDriversDetected: true}
+	if centos[0] != expected {
+		t.Errorf("expected summary %+v but received %+v", expected, centos[0])
+	}
+
+	if unknown := inventory[GuestOsUnknown]; len(unknown) != 1 || unknown[0].Id != "vm-3" {
+		t.Errorf("expected vm-3 to be reported with an unknown OS but received: %+v", unknown)
+	}
+
+	inventory, err = c.GetGuestOsInventory(Vm{PoolId: "pool-2"})
+	if err != nil || len(inventory["centos 7"]) != 1 || inventory["centos 7"][0].Id != "vm-2" {
+		t.Errorf("expected only the VMs of pool-2 to be reported but received %+v with error: %v", inventory, err)
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Substrings of the names of the guest tools ISOs shipped by XCP-ng and
+// Citrix Hypervisor, matched case-insensitively
+var guestToolsIsoNames = []string{"guest-tools", "xcp-ng-tools", "xs-tools"}
+
+func isGuestToolsIsoName(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range guestToolsIsoNames {
+		if strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindGuestToolsIso returns the guest tools ISO of the pool. The ISOs of
+// the SRs flagged as tools SRs are searched first, then the ones of the
+// other ISO SRs whose name looks like a guest tools ISO. A
+// GuestToolsIsoNotFoundError listing the searched SRs is returned when
+// none is found.
+func (c *Client) FindGuestToolsIso(poolId string) (*VDI, error) {
+	var srs map[string]StorageRepository
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":    "SR",
+			"$poolId": poolId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &srs)
+	if err != nil {
+		return nil, err
+	}
+
+	var vdis map[string]VDI
+	params = map[string]interface{}{
+		"filter": map[string]string{
+			"type":    "VDI",
+			"$poolId": poolId,
+		},
+	}
+	err = c.Call("xo.getAllObjects", params, &vdis)
+	if err != nil {
+		return nil, err
+	}
+
+	vdisBySr := map[string][]VDI{}
+	for _, id := range sortedKeys(vdis) {
+		vdi := vdis[id]
+		vdisBySr[vdi.SrId] = append(vdisBySr[vdi.SrId], vdi)
+	}
+
+	toolsSrs := []string{}
+	isoSrs := []string{}
+	for _, id := range sortedKeys(srs) {
+		sr := srs[id]
+		if sr.IsToolsSr {
+			toolsSrs = append(toolsSrs, id)
+		} else if sr.ContentType == "iso" || sr.SRType == "iso" {
+			isoSrs = append(isoSrs, id)
+		}
+	}
+
+	// A tools SR only holds the guest tools, its single ISO is used even
+	// when its name is unusual.
+	for _, srId := range toolsSrs {
+		isos := vdisBySr[srId]
+		for _, vdi := range isos {
+			if isGuestToolsIsoName(vdi.NameLabel) {
+				return &vdi, nil
+			}
+		}
+		if len(isos) == 1 {
+			return &isos[0], nil
+		}
+	}
+	for _, srId := range isoSrs {
+		for _, vdi := range vdisBySr[srId] {
+			if isGuestToolsIsoName(vdi.NameLabel) {
+				return &vdi, nil
+			}
+		}
+	}
+
+	notFound := GuestToolsIsoNotFoundError{PoolId: poolId}
+	for _, srId := range toolsSrs {
+		notFound.ToolsSrs = append(notFound.ToolsSrs, srs[srId].NameLabel)
+	}
+	for _, srId := range isoSrs {
+		notFound.IsoSrs = append(notFound.IsoSrs, srs[srId].NameLabel)
+	}
+	return nil, notFound
+}
+
+type InstallGuestToolsCdOptions struct {
+	// Eject the CD once the VM reports its guest tools, the eject is
+	// skipped when they aren't detected within Timeout
+	Eject bool
+	// Defaults to 5 minutes
+	Timeout time.Duration
+}
+
+// InstallGuestToolsCd inserts the guest tools ISO of the VM's pool in the
+// VM's CD drive. Installing the tools from it is left to the guest.
+func (c *Client) InstallGuestToolsCd(vmId string) error {
+	return c.InstallGuestToolsCdWithOptions(vmId, InstallGuestToolsCdOptions{})
+}
+
+func (c *Client) InstallGuestToolsCdWithOptions(vmId string, opts InstallGuestToolsCdOptions) error {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return err
+	}
+
+	iso, err := c.FindGuestToolsIso(vm.PoolId)
+	if err != nil {
+		return err
+	}
+
+	err = c.InsertCd(vmId, iso.VDIId)
+	if err != nil {
+		return err
+	}
+
+	if !opts.Eject {
+		return nil
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Minute
+	}
+	if err := c.WaitForGuestTools(vmId, timeout); err != nil {
+		c.logf("[WARN] Leaving guest tools ISO `%s` in the CD drive of vm `%s`: %v\n", iso.NameLabel, vmId, err)
+		return nil
+	}
+	return c.EjectCd(vmId)
+}
+
+// WaitForGuestTools waits for the management agent of the VM's guest tools
+// to be detected.
+func (c *Client) WaitForGuestTools(vmId string, timeout time.Duration) error {
+	refreshFn := func() (result interface{}, state string, err error) {
+		vm, err := c.GetVm(Vm{Id: vmId})
+
+		if err != nil {
+			return vm, "", err
+		}
+
+		if !vm.ManagementAgentDetected {
+			return vm, "Waiting", nil
+		}
+		return vm, "Ready", nil
+	}
+	waiter := stateWait{
+		Pending: []string{"Waiting"},
+		Refresh: refreshFn,
+		Target:  []string{"Ready"},
+		Timeout: timeout,
+	}
+	_, err := waiter.wait(context.Background())
+	return err
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func guestToolsClient(objects ...map[string]interface{}) (*Client, *fakeRPC) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, objects...), nil
+		}
+		return true, nil
+	}}
+	return &Client{rpc: rpc}, rpc
+}
+
+var guestToolsSrs = []map[string]interface{}{
+	{"id": "sr-local", "type": "SR", "name_label": "Local storage", "SR_type": "ext", "content_type": "user", "$poolId": "pool-1"},
+	{"id": "sr-iso", "type": "SR", "name_label": "ISOs", "SR_type": "iso", "content_type": "iso", "$poolId": "pool-1"},
+	{"id": "vdi-disk", "type": "VDI", "name_label": "guest-tools backup", "$SR": "sr-local", "$poolId": "pool-1"},
+	{"id": "vdi-debian", "type": "VDI", "name_label": "debian-12.iso", "$SR": "sr-iso", "$poolId": "pool-1"},
+}
+
+func TestFindGuestToolsIso_namingSchemes(t *testing.T) {
+	for _, name := range []string{"guest-tools.iso", "xcp-ng-tools-8.2.1.iso", "XS-Tools.iso"} {
+		objects := append([]map[string]interface{}{
+			{"id": "vdi-tools", "type": "VDI", "name_label": name, "$SR": "sr-iso", "$poolId": "pool-1"},
+		}, guestToolsSrs...)
+		c, _ := guestToolsClient(objects...)
+
+		iso, err := c.FindGuestToolsIso("pool-1")
+		if err != nil {
+			t.Fatalf("failed to find the guest tools iso named `%s` with error: %v", name, err)
+		}
+		if iso.VDIId != "vdi-tools" {
+			t.Errorf("expected the iso named `%s` to be found but received: %+v", name, iso)
+		}
+	}
+}
+
+func TestFindGuestToolsIso_prefersToolsSr(t *testing.T) {
+	objects := append([]map[string]interface{}{
+		{"id": "sr-tools", "type": "SR", "name_label": "XCP-ng Tools", "SR_type": "udev", "is_tools_sr": true, "$poolId": "pool-1"},
+		{"id": "vdi-tools", "type": "VDI", "name_label": "tools.iso", "$SR": "sr-tools", "$poolId": "pool-1"},
+		{"id": "vdi-stale", "type": "VDI", "name_label": "guest-tools-old.iso", "$SR": "sr-iso", "$poolId": "pool-1"},
+	}, guestToolsSrs...)
+	c, _ := guestToolsClient(objects...)
+
+	iso, err := c.FindGuestToolsIso("pool-1")
+	if err != nil {
+		t.Fatalf("failed to find the guest tools iso with error: %v", err)
+	}
+	if iso.VDIId != "vdi-tools" {
+		t.Errorf("expected the iso of the tools SR to be found but received: %+v", iso)
+	}
+}
+
+func TestFindGuestToolsIso_notFoundListsSearchedSrs(t *testing.T) {
+	objects := append([]map[string]interface{}{
+		{"id": "sr-tools", "type": "SR", "name_label": "XCP-ng Tools", "is_tools_sr": true, "$poolId": "pool-1"},
+	}, guestToolsSrs...)
+	c, _ := guestToolsClient(objects...)
+	_, err := c.FindGuestToolsIso("pool-1")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected a NotFound error but received: %v", err)
+	}
+	var notFound GuestToolsIsoNotFoundError
+	if !errors.As(err, &notFound) || !reflect.DeepEqual(notFound.ToolsSrs, []string{"XCP-ng Tools"}) {
+		t.Fatalf("expected the searched SRs to be returned but received: %v", err)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "`XCP-ng Tools` (tools SR)") || !strings.Contains(msg, "`ISOs`") {
+		t.Errorf("expected the searched SRs to be listed but received: %s", msg)
+	}
+	if strings.Contains(msg, "Local storage") {
+		t.Errorf("expected SRs without ISOs not to be listed but received: %s", msg)
+	}
+}
+
+func TestInstallGuestToolsCd(t *testing.T) {
+	objects := append([]map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "$poolId": "pool-1"},
+		{"id": "vdi-tools", "type": "VDI", "name_label": "guest-tools.iso", "$SR": "sr-iso", "$poolId": "pool-1"},
+	}, guestToolsSrs...)
+	c, rpc := guestToolsClient(objects...)
+
+	err := c.InstallGuestToolsCd("vm-1")
+	if err != nil {
+		t.Fatalf("failed to insert the guest tools cd with error: %v", err)
+	}
+	inserts := rpc.callsTo("vm.insertCd")
+	if len(inserts) != 1 || inserts[0].params["id"] != "vm-1" || inserts[0].params["cd_id"] != "vdi-tools" {
+		t.Errorf("expected the guest tools iso to be inserted but received: %v", inserts)
+	}
+	if ejects := rpc.callsTo("vm.ejectCd"); len(ejects) != 0 {
+		t.Errorf("expected the cd not to be ejected but received: %v", ejects)
+	}
+}
+
+func TestInstallGuestToolsCdWithOptions_ejectsOnceToolsDetected(t *testing.T) {
+	objects := append([]map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "$poolId": "pool-1", "managementAgentDetected": true},
+		{"id": "vdi-tools", "type": "VDI", "name_label": "guest-tools.iso", "$SR": "sr-iso", "$poolId": "pool-1"},
+	}, guestToolsSrs...)
+	c, rpc := guestToolsClient(objects...)
+
+	err := c.InstallGuestToolsCdWithOptions("vm-1", InstallGuestToolsCdOptions{Eject: true, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("failed to install the guest tools with error: %v", err)
+	}
+	if ejects := rpc.callsTo("vm.ejectCd"); len(ejects) != 1 || ejects[0].params["id"] != "vm-1" {
+		t.Errorf("expected the cd to be ejected but received: %v", ejects)
+	}
+}
@@ -5,20 +5,59 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"time"
 )
 
 type Host struct {
 	Id        string           `json:"id"`
+	XapiRef   string           `json:"_xapiRef,omitempty"`
 	NameLabel string           `json:"name_label"`
 	Tags      []interface{}    `json:"tags,omitempty"`
 	Pool      string           `json:"$pool"`
 	Memory    HostMemoryObject `json:"memory"`
 	Cpus      CpuInfo          `json:"cpus"`
+	// XO exposes XAPI's cpu_info as `CPUs`, distinct from `cpus`
+	CpuDetails HostCpuDetails `json:"CPUs"`
+	// Only reported by XO versions exposing the host's time sync
+	// status, nil otherwise
+	NtpSynchronized *bool `json:"ntpSynchronized,omitempty"`
+	// Boot time of the host and start time of its XAPI agent
+	StartTime      Timestamp `json:"startTime"`
+	AgentStartTime Timestamp `json:"agentStartTime"`
+
+	// Disabled hosts don't accept new VMs
+	Enabled bool `json:"enabled"`
+	// Whether storage is accessed through every available path
+	Multipathing bool `json:"multipathing"`
+	// Set by XO when updates installed on the host only apply once it
+	// is rebooted
+	RebootRequired bool `json:"rebootRequired"`
+	// Running, Halted or Unknown when the host is unreachable
+	PowerState PowerState `json:"power_state"`
+	// Logging settings of the host, e.g. `syslog_destination` for the
+	// remote syslog server set by SetHostSyslogRemote
+	Logging map[string]string `json:"logging"`
+
+	ControlDomain string   `json:"controlDomain"`
+	PBDIds        []string `json:"$PBDs"`
+	PIFIds        []string `json:"$PIFs"`
+
+	// Only filled by GetHostWithInventory
+	PBDs                []PBD `json:"-"`
+	PIFs                []PIF `json:"-"`
+	ResidentVms         []Vm  `json:"-"`
+	ControlDomainMemory int64 `json:"-"`
+	// Memory XAPI reserves for the host and its VMs on top of their own
+	// memory. XO doesn't report it so it is left to the caller.
+	MemoryOverhead int64 `json:"-"`
+	// Maximum ratio of vCPUs of running VMs to physical cores checked by
+	// CanHostFitVm, 0 disables the check.
+	MaxVcpuRatio float64 `json:"-"`
 }
 
 type HostMemoryObject struct {
-	Usage int `json:"usage"`
-	Size  int `json:"size"`
+	Usage int64 `json:"usage"`
+	Size  int64 `json:"size"`
 }
 
 func (h Host) Compare(obj interface{}) bool {
@@ -61,6 +100,74 @@ func (c *Client) GetHostById(id string) (host Host, err error) {
 	return hosts[0], nil
 }
 
+// GetHostsNeedingReboot returns the hosts of the pool which must be
+// rebooted for their installed updates to apply, sorted by id.
+func (c *Client) GetHostsNeedingReboot(poolId string) ([]Host, error) {
+	hosts := map[string]Host{}
+	err := c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":  "host",
+			"$pool": poolId,
+		},
+	}, &hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	needingReboot := []Host{}
+	for _, id := range sortedKeys(hosts) {
+		if hosts[id].RebootRequired {
+			needingReboot = append(needingReboot, hosts[id])
+		}
+	}
+	return needingReboot, nil
+}
+
+// HostUnreachableError is returned when XAPI cannot contact a host over
+// its management network.
+type HostUnreachableError struct {
+	HostId string
+	Err    error
+}
+
+func (e HostUnreachableError) Error() string {
+	return fmt.Sprintf("host `%s` is unreachable: %v", e.HostId, e.Err)
+}
+
+func (e HostUnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// XAPI reports dates in a compact ISO 8601 format
+const xapiTimeFormat = "20060102T15:04:05Z"
+
+// GetHostTime returns the current time of the host's clock.
+func (c *Client) GetHostTime(hostId string) (time.Time, error) {
+	var serverTime string
+	params := map[string]interface{}{
+		"id": hostId,
+	}
+	err := c.Call("host.getServerTime", params, &serverTime)
+
+	if err != nil {
+		code, _ := xapiErrorCode(err)
+		if code == "HOST_OFFLINE" || code == "HOST_NOT_LIVE" {
+			return time.Time{}, HostUnreachableError{HostId: hostId, Err: err}
+		}
+		return time.Time{}, featureDetect("host.getServerTime", err)
+	}
+
+	return parseXapiTime(serverTime)
+}
+
+func parseXapiTime(s string) (time.Time, error) {
+	t, err := time.Parse(xapiTimeFormat, s)
+	if err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
 func FindHostForTests(hostId string, host *Host) {
 	c, err := NewClient(GetConfigFromEnv())
 	if err != nil {
@@ -86,6 +193,14 @@ func (c *Client) GetSortedHosts(host Host, sortBy, sortOrder string) (hosts []Ho
 	}
 	slice := obj.([]Host)
 
+	// XO returns the hosts in no particular order, they are sorted by id
+	// when no sort is given
+	if sortBy == "" {
+		sortBy = sortFieldId
+	}
+	if sortOrder == "" {
+		sortOrder = sortOrderAsc
+	}
 	return sortHostsByField(slice, sortBy, sortOrder), nil
 }
 
@@ -115,7 +230,10 @@ func sortHostsByField(hosts []Host, by, order string) []Host {
 func sortByField(hosts []Host, field string, i, j int) bool {
 	switch field {
 	case sortFieldNameLabel:
-		return hosts[i].NameLabel < hosts[j].NameLabel
+		if hosts[i].NameLabel != hosts[j].NameLabel {
+			return hosts[i].NameLabel < hosts[j].NameLabel
+		}
+		return hosts[i].Id < hosts[j].Id
 	case sortFieldId:
 		return hosts[i].Id < hosts[j].Id
 	}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"fmt"
+)
+
+// PBD connects a host to an SR. The SR is only usable from the host while
+// the PBD is attached.
+type PBD struct {
+	Id       string `json:"id"`
+	Host     string `json:"host"`
+	SR       string `json:"SR"`
+	Attached bool   `json:"attached"`
+	// Parameters of the SR driver, e.g. the `device` backing a local SR
+	DeviceConfig map[string]string `json:"device_config"`
+	// Holds the multipathing status of the SR on the host, see
+	// GetSrMultipathStatus
+	OtherConfig map[string]string `json:"otherConfig"`
+}
+
+// GetHostWithInventory returns the host with the PBDs, PIFs, running VMs
+// and control domain memory FreeMemory and CanHostFitVm rely on.
+func (c *Client) GetHostWithInventory(id string) (*Host, error) {
+	host, err := c.GetHostById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pbds := map[string]PBD{}
+	err = c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type": "PBD",
+			"host": host.Id,
+		},
+	}, &pbds)
+	if err != nil {
+		return nil, err
+	}
+	for _, pbd := range pbds {
+		host.PBDs = append(host.PBDs, pbd)
+	}
+
+	pifs := map[string]PIF{}
+	err = c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":  "PIF",
+			"$host": host.Id,
+		},
+	}, &pifs)
+	if err != nil {
+		return nil, err
+	}
+	for _, pif := range pifs {
+		host.PIFs = append(host.PIFs, pif)
+	}
+
+	vms := map[string]Vm{}
+	err = c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":        "VM",
+			"$container":  host.Id,
+			"power_state": PowerStateRunning,
+		},
+	}, &vms)
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range vms {
+		host.ResidentVms = append(host.ResidentVms, vm)
+	}
+
+	if host.ControlDomain != "" {
+		controlDomains := map[string]Vm{}
+		err = c.Call("xo.getAllObjects", map[string]interface{}{
+			"filter": map[string]interface{}{
+				"type": "VM-controller",
+				"id":   host.ControlDomain,
+			},
+		}, &controlDomains)
+		if err != nil {
+			return nil, err
+		}
+		for _, vm := range controlDomains {
+			host.ControlDomainMemory = vmDynamicMemoryMax(vm)
+		}
+	}
+	return &host, nil
+}
+
+// GetVmWithInventory returns the VM with the disks and VIFs CanHostFitVm
+// checks the SRs and networks of, which GetVm leaves empty.
+func (c *Client) GetVmWithInventory(id string) (*Vm, error) {
+	vm, err := c.GetVm(Vm{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.loadVmResources(vm); err != nil {
+		return nil, err
+	}
+	return vm, nil
+}
+
+// loadVmResources fills the disks and VIFs of the VM hostMissingResources
+// checks.
+func (c *Client) loadVmResources(vm *Vm) error {
+	disks, err := c.GetDisks(vm)
+	if _, ok := err.(NotFound); err != nil && !ok {
+		return err
+	}
+	vm.Disks = disks
+
+	vifs, err := c.GetVIFs(vm)
+	if err != nil {
+		return err
+	}
+	vm.VIFsMap = nil
+	for _, vif := range vifs {
+		vm.VIFsMap = append(vm.VIFsMap, map[string]string{"network": vif.Network})
+	}
+	return nil
+}
+
+// FreeMemory computes the memory available to new VMs the way XAPI does:
+// the host's memory minus the control domain's, the dynamic maximum of
+// every running VM and the memory overhead.
+func (h Host) FreeMemory() int64 {
+	free := h.Memory.Size - h.ControlDomainMemory - h.MemoryOverhead
+	for _, vm := range h.ResidentVms {
+		if vm.PowerState == PowerStateRunning && vm.Id != h.ControlDomain {
+			free -= vmDynamicMemoryMax(vm)
+		}
+	}
+	return free
+}
+
+// CanHostFitVm reports whether vm could be started on host, a host
+// returned by GetHostWithInventory, and the limiting factor otherwise:
+// the host's free memory, its vCPU overcommit ratio when MaxVcpuRatio is
+// set, or SRs and networks of the VM's disks and VIFs the host can't
+// reach. The SRs and networks are only checked for the Disks and VIFsMap
+// of vm, an existing VM must be read with GetVmWithInventory.
+func CanHostFitVm(host Host, vm Vm) (bool, string) {
+	required := vmDynamicMemoryMax(vm)
+	if free := host.FreeMemory(); required > free {
+		return false, fmt.Sprintf("memory: VM requires %d bytes but host `%s` only has %d bytes free", required, host.Id, free)
+	}
+
+	if host.MaxVcpuRatio > 0 && host.Cpus.Cores > 0 {
+		vcpus := vm.CPUs.Number
+		for _, resident := range host.ResidentVms {
+			if resident.PowerState == PowerStateRunning && resident.Id != host.ControlDomain {
+				vcpus += resident.CPUs.Number
+			}
+		}
+		if ratio := float64(vcpus) / float64(host.Cpus.Cores); ratio > host.MaxVcpuRatio {
+			return false, fmt.Sprintf("vcpus: %d vCPUs on %d cores exceed the overcommit ratio of %g", vcpus, host.Cpus.Cores, host.MaxVcpuRatio)
+		}
+	}
+
+	if missing := hostMissingResources(host, vm); len(missing) > 0 {
+		return false, missing[0]
+	}
+	return true, ""
+}
+
+// hostMissingResources returns the SRs of the VM's disks and the networks
+// of its VIFs the host, a host returned by GetHostWithInventory, can't
+// reach.
+func hostMissingResources(host Host, vm Vm) []string {
+	missing := []string{}
+	srs := map[string]bool{}
+	for _, pbd := range host.PBDs {
+		if pbd.Attached {
+			srs[pbd.SR] = true
+		}
+	}
+	for _, disk := range vm.Disks {
+		if disk.SrId != "" && !srs[disk.SrId] {
+			missing = append(missing, fmt.Sprintf("sr: SR `%s` of disk `%s` is not attached to host `%s`", disk.SrId, disk.NameLabel, host.Id))
+		}
+	}
+
+	networks := map[string]bool{}
+	for _, pif := range host.PIFs {
+		networks[pif.Network] = true
+	}
+	for _, vif := range vm.VIFsMap {
+		if network := vif["network"]; network != "" && !networks[network] {
+			missing = append(missing, fmt.Sprintf("network: network `%s` has no PIF on host `%s`", network, host.Id))
+		}
+	}
+	return missing
+}
+
+// vmDynamicMemoryMax returns the memory a VM may use once running.
+func vmDynamicMemoryMax(vm Vm) int64 {
+	if len(vm.Memory.Dynamic) > 1 {
+		return vm.Memory.Dynamic[1]
+	}
+	return vmMemoryMax(vm)
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"strings"
+	"testing"
+)
+
+const gib = 1024 * 1024 * 1024
+
+func craftedHost() Host {
+	return Host{
+		Id:                  "host-1",
+		Memory:              HostMemoryObject{Size: 64 * gib},
+		Cpus:                CpuInfo{Cores: 8},
+		ControlDomain:       "dom0",
+		ControlDomainMemory: 4 * gib,
+		MemoryOverhead:      1 * gib,
+		PBDs: []PBD{
+			{Id: "pbd-1", SR: "sr-local", Attached: true},
+			{Id: "pbd-2", SR: "sr-nfs", Attached: false},
+		},
+		PIFs: []PIF{{Id: "pif-1", Network: "net-1"}},
+		ResidentVms: []Vm{
+			{Id: "vm-1", PowerState: "Running", CPUs: CPUs{Number: 4}, Memory: MemoryObject{Dynamic: []int64{8 * gib, 16 * gib}, Static: []int64{0, 32 * gib}}},
+			{Id: "vm-2", PowerState: "Running", CPUs: CPUs{Number: 4}, Memory: MemoryObject{Static: []int64{0, 8 * gib}}},
+		},
+	}
+}
+
+func vmRequiring(memory int64) Vm {
+	return Vm{
+		CPUs:    CPUs{Number: 2},
+		Memory:  MemoryObject{Static: []int64{0, memory}},
+		Disks:   []Disk{{VDI: VDI{SrId: "sr-local", NameLabel: "root"}}},
+		VIFsMap: []map[string]string{{"network": "net-1"}},
+	}
+}
+
+func TestHostFreeMemory(t *testing.T) {
+	// 64 - 4 (dom0) - 1 (overhead) - 16 (vm-1 dynamic max) - 8 (vm-2)
+	if free := craftedHost().FreeMemory(); free != 35*gib {
+		t.Errorf("expected 35GiB to be free but received %d", free)
+	}
+}
+
+func TestCanHostFitVm(t *testing.T) {
+	tests := []struct {
+		name     string
+		host     func(Host) Host
+		vm       func(Vm) Vm
+		fits     bool
+		limiting string
+	}{
+		{name: "fits", fits: true},
+		{
+			name:     "memory",
+			vm:       func(vm Vm) Vm { vm.Memory.Static[1] = 36 * gib; return vm },
+			limiting: "memory",
+		},
+		{
+			name:     "vcpu overcommit",
+			host:     func(h Host) Host { h.MaxVcpuRatio = 1; return h },
+			limiting: "vcpus",
+		},
+		{
+			name: "vcpu overcommit within ratio",
+			host: func(h Host) Host { h.MaxVcpuRatio = 2; return h },
+			fits: true,
+		},
+		{
+			name:     "detached sr",
+			vm:       func(vm Vm) Vm { vm.Disks[0].SrId = "sr-nfs"; return vm },
+			limiting: "sr",
+		},
+		{
+			name:     "missing network",
+			vm:       func(vm Vm) Vm { vm.VIFsMap[0]["network"] = "net-2"; return vm },
+			limiting: "network",
+		},
+	}
+
+	for _, test := range tests {
+		host := craftedHost()
+		if test.host != nil {
+			host = test.host(host)
+		}
+		vm := vmRequiring(32 * gib)
+		if test.vm != nil {
+			vm = test.vm(vm)
+		}
+
+		fits, reason := CanHostFitVm(host, vm)
+		if fits != test.fits {
+			t.Errorf("%s: expected CanHostFitVm to return %t but received %t: %s", test.name, test.fits, fits, reason)
+		}
+		if !test.fits && !strings.HasPrefix(reason, test.limiting+":") {
+			t.Errorf("%s: expected %s to be the limiting factor but received: %s", test.name, test.limiting, reason)
+		}
+	}
+}
+
+func TestGetHostWithInventory(t *testing.T) {
+	objects := []map[string]interface{}{
+		{"id": "host-1", "type": "host", "$pool": "pool-1", "controlDomain": "dom0", "memory": map[string]interface{}{"size": 64 * gib}},
+		{"id": "dom0", "type": "VM-controller", "memory": map[string]interface{}{"dynamic": []int{4 * gib, 4 * gib}}},
+		{"id": "pbd-1", "type": "PBD", "host": "host-1", "SR": "sr-local", "attached": true},
+		{"id": "pbd-2", "type": "PBD", "host": "host-2", "SR": "sr-local", "attached": true},
+		{"id": "pif-1", "type": "PIF", "$host": "host-1", "$network": "net-1"},
+		{"id": "vm-1", "type": "VM", "$container": "host-1", "power_state": "Running", "memory": map[string]interface{}{"static": []int{0, 8 * gib}}},
+		{"id": "vm-2", "type": "VM", "$container": "host-1", "power_state": "Halted", "memory": map[string]interface{}{"static": []int{0, 8 * gib}}},
+	}
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, objects...), nil
+	}}}
+
+	host, err := c.GetHostWithInventory("host-1")
+	if err != nil {
+		t.Fatalf("failed to get host with error: %v", err)
+	}
+	if len(host.PBDs) != 1 || len(host.PIFs) != 1 || len(host.ResidentVms) != 1 {
+		t.Errorf("expected the host's PBD, PIF and running VM but received: %+v %+v %+v", host.PBDs, host.PIFs, host.ResidentVms)
+	}
+	if free := host.FreeMemory(); free != 52*gib {
+		t.Errorf("expected 52GiB to be free but received %d", free)
+	}
+}
+
+func TestGetVmWithInventory_checksDisksAndVifs(t *testing.T) {
+	objects := []map[string]interface{}{
+		{"id": "vm-3", "type": "VM", "power_state": "Halted", "memory": map[string]interface{}{"static": []int{0, 2 * gib}}},
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-3", "VDI": "vdi-1", "is_cd_drive": false},
+		{"id": "vdi-1", "type": "VDI", "name_label": "data", "$SR": "sr-nfs"},
+		{"id": "vif-1", "type": "VIF", "$VM": "vm-3", "$network": "net-1"},
+	}
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, objects...), nil
+	}}}
+
+	vm, err := c.GetVmWithInventory("vm-3")
+	if err != nil {
+		t.Fatalf("failed to get vm with error: %v", err)
+	}
+	if len(vm.Disks) != 1 || len(vm.VIFsMap) != 1 || vm.VIFsMap[0]["network"] != "net-1" {
+		t.Fatalf("expected the disk and VIF of the VM but received: %+v %+v", vm.Disks, vm.VIFsMap)
+	}
+
+	// The PBD of sr-nfs isn't attached to the host
+	fits, reason := CanHostFitVm(craftedHost(), *vm)
+	if fits || !strings.Contains(reason, "sr-nfs") {
+		t.Errorf("expected the unreachable SR of the disk to be the limiting factor but received %t: %s", fits, reason)
+	}
+}
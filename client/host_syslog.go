@@ -0,0 +1,149 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Key of Host.Logging holding the remote syslog server of the host
+const syslogDestinationKey = "syslog_destination"
+
+func (v *validator) syslogServer(field, server string) {
+	if server == "" {
+		return
+	}
+
+	host := server
+	if strings.HasPrefix(server, "[") || strings.Count(server, ":") == 1 {
+		var port string
+		var err error
+		if host, port, err = net.SplitHostPort(server); err != nil {
+			v.addf(field, "must be of the form host[:port], got `%s`", server)
+			return
+		}
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			v.addf(field, "port `%s` of `%s` must be between 1 and 65535", port, server)
+			return
+		}
+	}
+	if net.ParseIP(host) == nil && !isHostname(host) {
+		v.addf(field, "must be of the form host[:port], got `%s`", server)
+	}
+}
+
+func isHostname(s string) bool {
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SetHostSyslogRemote sends the logs of the host to the syslog server, of
+// the form host[:port]. An empty server keeps the logs on the host.
+func (c *Client) SetHostSyslogRemote(hostId string, server string) error {
+	if !c.skipValidation {
+		v := &validator{}
+		v.syslogServer("server", server)
+		if err := v.err(); err != nil {
+			return err
+		}
+	}
+
+	params := map[string]interface{}{
+		"id":                hostId,
+		"syslogDestination": server,
+	}
+	var success bool
+	if err := c.Call("host.setRemoteSyslogHost", params, &success); err != nil {
+		return featureDetect("host.setRemoteSyslogHost", err)
+	}
+	return nil
+}
+
+// GetHostSyslogRemote returns the syslog server the host sends its logs
+// to, empty when it keeps them.
+func (c *Client) GetHostSyslogRemote(hostId string) (string, error) {
+	var host Host
+	if err := c.getObjectOfType("host", hostId, Host{Id: hostId}, &host); err != nil {
+		return "", err
+	}
+	return host.Logging[syslogDestinationKey], nil
+}
+
+type HostSyslogResult struct {
+	HostId string
+	// Syslog server of the host before the change
+	Previous string
+	Err      error
+}
+
+type PoolSyslogResult struct {
+	PoolId string
+	// Result of each host of the pool, sorted by host id
+	Results []HostSyslogResult
+}
+
+// Failed returns the results of the hosts which failed.
+func (r PoolSyslogResult) Failed() []HostSyslogResult {
+	failed := []HostSyslogResult{}
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// SetPoolSyslogRemote calls SetHostSyslogRemote on every host of the pool
+// concurrently. Every host is attempted, an error is returned alongside
+// the result when any of them failed.
+func (c *Client) SetPoolSyslogRemote(poolId, server string) (*PoolSyslogResult, error) {
+	if !c.skipValidation {
+		v := &validator{}
+		v.syslogServer("server", server)
+		if err := v.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	hosts, err := c.GetPoolHosts(poolId)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return nil, NotFound{Query: Pool{Id: poolId}}
+	}
+
+	result := &PoolSyslogResult{
+		PoolId:  poolId,
+		Results: make([]HostSyslogResult, len(hosts)),
+	}
+	forEachConcurrently(len(hosts), defaultConcurrency, func(i int) {
+		result.Results[i] = HostSyslogResult{
+			HostId:   hosts[i].Id,
+			Previous: hosts[i].Logging[syslogDestinationKey],
+			Err:      c.SetHostSyslogRemote(hosts[i].Id, server),
+		}
+	})
+
+	failed := result.Failed()
+	log.Printf("[DEBUG] Set the syslog server of %d hosts of pool `%s` to `%s`, failed: %d\n", len(hosts), poolId, server, len(failed))
+	if len(failed) > 0 {
+		return result, errors.New(fmt.Sprintf("failed to set the syslog server of %d of %d hosts of pool `%s`, first error on `%s`: %v", len(failed), len(hosts), poolId, failed[0].HostId, failed[0].Err))
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSyslogRPC serves the hosts of pool-1 and applies
+// host.setRemoteSyslogHost to them, failing for the offline host.
+func fakeSyslogRPC() *fakeRPC {
+	var mu sync.Mutex
+	hosts := map[string]map[string]interface{}{
+		"host-1": {"id": "host-1", "type": "host", "$pool": "pool-1", "logging": map[string]string{"syslog_destination": "old.example.com"}},
+		"host-2": {"id": "host-2", "type": "host", "$pool": "pool-1", "logging": map[string]string{}},
+		"host-3": {"id": "host-3", "type": "host", "$pool": "pool-1", "logging": map[string]string{}},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch method {
+		case "xo.getAllObjects":
+			objs := []map[string]interface{}{}
+			for _, id := range sortedKeys(hosts) {
+				objs = append(objs, hosts[id])
+			}
+			return fakeGetAllObjects(params, objs...), nil
+		case "host.setRemoteSyslogHost":
+			id := params["id"].(string)
+			if id == "host-3" {
+				return nil, errors.New("HOST_OFFLINE")
+			}
+			// Replace the host rather than changing the one already returned
+			hosts[id] = map[string]interface{}{"id": id, "type": "host", "$pool": "pool-1", "logging": map[string]string{"syslog_destination": params["syslogDestination"].(string)}}
+		}
+		return true, nil
+	}}
+}
+
+func TestSetHostSyslogRemote(t *testing.T) {
+	rpc := fakeSyslogRPC()
+	c := &Client{rpc: rpc}
+
+	if err := c.SetHostSyslogRemote("host-2", "syslog.example.com:514"); err != nil {
+		t.Fatalf("failed to set the syslog server with error: %v", err)
+	}
+	call := rpc.callsTo("host.setRemoteSyslogHost")[0].params
+	if !reflect.DeepEqual(call, map[string]interface{}{"id": "host-2", "syslogDestination": "syslog.example.com:514"}) {
+		t.Errorf("expected the server to be passed as is but received: %v", call)
+	}
+	if server, err := c.GetHostSyslogRemote("host-2"); err != nil || server != "syslog.example.com:514" {
+		t.Errorf("expected the new syslog server to be read back but received `%s` with error: %v", server, err)
+	}
+
+	if err := c.SetHostSyslogRemote("host-1", ""); err != nil {
+		t.Fatalf("failed to clear the syslog server with error: %v", err)
+	}
+	if server, err := c.GetHostSyslogRemote("host-1"); err != nil || server != "" {
+		t.Errorf("expected the syslog server to be cleared but received `%s` with error: %v", server, err)
+	}
+}
+
+func TestSetHostSyslogRemote_validation(t *testing.T) {
+	rpc := fakeSyslogRPC()
+	c := &Client{rpc: rpc}
+
+	for _, server := range []string{"syslog.example.com", "10.0.0.1", "10.0.0.1:514", "[2001:db8::1]:6514", "2001:db8::1"} {
+		if err := c.SetHostSyslogRemote("host-1", server); err != nil {
+			t.Errorf("expected `%s` to be accepted but received: %v", server, err)
+		}
+	}
+
+	calls := len(rpc.callsTo("host.setRemoteSyslogHost"))
+	for _, server := range []string{"syslog.example.com:", "syslog.example.com:0", "syslog.example.com:70000", "udp://syslog.example.com", "-syslog.example.com", "syslog..example.com"} {
+		err := c.SetHostSyslogRemote("host-1", server)
+		if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"server"}) {
+			t.Errorf("expected `%s` to be rejected but received: %v", server, err)
+		}
+	}
+	if len(rpc.callsTo("host.setRemoteSyslogHost")) != calls {
+		t.Errorf("expected no call for invalid servers")
+	}
+}
+
+func TestSetPoolSyslogRemote_partialFailure(t *testing.T) {
+	rpc := fakeSyslogRPC()
+	c := &Client{rpc: rpc}
+
+	result, err := c.SetPoolSyslogRemote("pool-1", "syslog.example.com")
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 hosts") {
+		t.Fatalf("expected the offline host to fail the call but received: %v", err)
+	}
+	if failed := result.Failed(); len(failed) != 1 || failed[0].HostId != "host-3" {
+		t.Errorf("expected only host-3 to fail but received: %+v", failed)
+	}
+	if len(result.Results) != 3 || result.Results[0].HostId != "host-1" || result.Results[0].Previous != "old.example.com" || result.Results[1].Err != nil {
+		t.Errorf("expected a result per host sorted by id but received: %+v", result.Results)
+	}
+
+	hosts, err := c.GetPoolHosts("pool-1")
+	if err != nil {
+		t.Fatalf("failed to get the hosts with error: %v", err)
+	}
+	servers := []string{}
+	for _, host := range hosts {
+		servers = append(servers, host.Logging[syslogDestinationKey])
+	}
+	if !reflect.DeepEqual(servers, []string{"syslog.example.com", "syslog.example.com", ""}) {
+		t.Errorf("expected the drift of host-3 to be visible on the hosts but received: %v", servers)
+	}
+
+	if _, err := c.SetPoolSyslogRemote("pool-2", "syslog.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a NotFound error for a pool without hosts but received: %v", err)
+	}
+}
@@ -1,8 +1,10 @@
 package client
 
 import (
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestHostCompare(t *testing.T) {
@@ -86,6 +88,38 @@ func TestGetSortedHosts(t *testing.T) {
 	}
 }
 
+func TestGetSortedHosts_defaultsToIds(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "host-3", "type": "host", "name_label": "b", "$pool": "pool-1"},
+			map[string]interface{}{"id": "host-1", "type": "host", "name_label": "b", "$pool": "pool-1"},
+			map[string]interface{}{"id": "host-2", "type": "host", "name_label": "a", "$pool": "pool-1"},
+		), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	tests := []struct {
+		sortBy   string
+		expected []string
+	}{
+		{"", []string{"host-1", "host-2", "host-3"}},
+		{sortFieldNameLabel, []string{"host-2", "host-1", "host-3"}},
+	}
+	for _, test := range tests {
+		hosts, err := c.GetSortedHosts(Host{Pool: "pool-1"}, test.sortBy, "")
+		if err != nil {
+			t.Fatalf("failed to get hosts with error: %v", err)
+		}
+		ids := []string{}
+		for _, host := range hosts {
+			ids = append(ids, host.Id)
+		}
+		if !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("expected hosts sorted by `%s` to be %v but received %v", test.sortBy, test.expected, ids)
+		}
+	}
+}
+
 func Test_sortHostsByField(t *testing.T) {
 	type args struct {
 		hosts []Host
@@ -112,3 +146,64 @@ func Test_sortHostsByField(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHostTime_parsesXapiTime(t *testing.T) {
+	tests := []struct {
+		serverTime string
+		expected   time.Time
+	}{
+		{"20240315T08:30:05Z", time.Date(2024, 3, 15, 8, 30, 5, 0, time.UTC)},
+		{"2024-03-15T08:30:05Z", time.Date(2024, 3, 15, 8, 30, 5, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		serverTime := test.serverTime
+		rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+			return serverTime, nil
+		}}
+		c := Client{rpc: rpc}
+
+		hostTime, err := c.GetHostTime("host-1")
+		if err != nil {
+			t.Fatalf("failed to get host time with error: %v", err)
+		}
+
+		if !hostTime.Equal(test.expected) {
+			t.Errorf("expected host time %s but received %s", test.expected, hostTime)
+		}
+		if id := rpc.callsTo("host.getServerTime")[0].params["id"]; id != "host-1" {
+			t.Errorf("expected host.getServerTime to be called for host-1 but received %v", id)
+		}
+	}
+}
+
+func TestGetHostTime_unreachableHost(t *testing.T) {
+	c := Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, xapiError("HOST_OFFLINE", "host-1")
+	}}}
+
+	_, err := c.GetHostTime("host-1")
+	var unreachable HostUnreachableError
+	if !errors.As(err, &unreachable) || unreachable.HostId != "host-1" {
+		t.Errorf("expected a HostUnreachableError for host-1 but received: %v", err)
+	}
+}
+
+func TestGetHostsNeedingReboot(t *testing.T) {
+	c := Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "host-1", "type": "host", "$pool": "pool-1", "rebootRequired": true},
+			map[string]interface{}{"id": "host-2", "type": "host", "$pool": "pool-1", "rebootRequired": false},
+			map[string]interface{}{"id": "host-3", "type": "host", "$pool": "pool-1"},
+			map[string]interface{}{"id": "host-4", "type": "host", "$pool": "pool-2", "rebootRequired": true},
+		), nil
+	}}}
+
+	hosts, err := c.GetHostsNeedingReboot("pool-1")
+	if err != nil {
+		t.Fatalf("failed to get hosts needing reboot with error: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Id != "host-1" || !hosts[0].RebootRequired {
+		t.Errorf("expected only host-1 to need a reboot but received: %+v", hosts)
+	}
+}
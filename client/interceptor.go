@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/vatesfr/xo-sdk-go/client/wait"
+)
+
+// CallFunc makes an api call, decoding its result into result.
+type CallFunc func(ctx context.Context, method string, params, result interface{}) error
+
+// CallInterceptor wraps the api calls of a client, e.g. to refresh
+// credentials, collect metrics or change the params. It returns the
+// CallFunc called instead of next, which may call next any number of
+// times or not at all to short-circuit the call.
+type CallInterceptor func(next CallFunc) CallFunc
+
+// chainInterceptors returns the CallFunc running call through the
+// interceptors, the first one being the outermost.
+func chainInterceptors(call CallFunc, interceptors ...CallInterceptor) CallFunc {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		call = interceptors[i](call)
+	}
+	return call
+}
+
+// builtinInterceptors returns the interceptors of every client, run inside
+// the ones of its configuration.
+func (c *Client) builtinInterceptors() []CallInterceptor {
+	return []CallInterceptor{
+		c.requireAdminInterceptor,
+		c.dryRunInterceptor,
+		c.recordChangesInterceptor,
+		c.retryInterceptor,
+		c.logCallsInterceptor,
+	}
+}
+
+func (c *Client) requireAdminInterceptor(next CallFunc) CallFunc {
+	return func(ctx context.Context, method string, params, result interface{}) error {
+		if c.requireAdmin && isAdminMethod(method) {
+			if err := c.requireAdminFor(method); err != nil {
+				return err
+			}
+		}
+		return next(ctx, method, params, result)
+	}
+}
+
+func (c *Client) dryRunInterceptor(next CallFunc) CallFunc {
+	return func(ctx context.Context, method string, params, result interface{}) error {
+		if c.dryRun && !isReadOnlyMethod(method) {
+			return c.dryRunCall(method, params, result)
+		}
+		return next(ctx, method, params, result)
+	}
+}
+
+func (c *Client) recordChangesInterceptor(next CallFunc) CallFunc {
+	return func(ctx context.Context, method string, params, result interface{}) error {
+		start := time.Now()
+		err := next(ctx, method, params, result)
+		if err == nil {
+			c.recordChange(method, params, result, time.Since(start))
+		}
+		return err
+	}
+}
+
+func (c *Client) retryInterceptor(next CallFunc) CallFunc {
+	return func(ctx context.Context, method string, params, result interface{}) error {
+		backoff := wait.Backoff{Initial: retryInitialDelay, Max: retryMaxDelay, FullJitter: c.retryJitter, Rand: retryJitterRand}
+		for attempt := 0; ; attempt++ {
+			err := next(ctx, method, params, result)
+			if err == nil || attempt >= c.maxRetries || !isRetryable(method, err) {
+				return err
+			}
+
+			delay := backoff.Next()
+			c.logf("[WARN] Retrying rpc call `%s` in %s after error: %v\n", method, delay, err)
+			if err := sleepRetryDelay(ctx, delay); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Client) logCallsInterceptor(next CallFunc) CallFunc {
+	return func(ctx context.Context, method string, params, result interface{}) error {
+		err := next(ctx, method, params, result)
+		var callRes interface{}
+		t := reflect.TypeOf(result)
+		if t == nil || t.Kind() != reflect.Ptr {
+			callRes = result
+		} else {
+			callRes = reflect.ValueOf(result).Elem()
+		}
+		c.logf("[TRACE] Made rpc call `%s` with params: %v and received %+v: result with error: %v\n", method, params, callRes, err)
+		return err
+	}
+}
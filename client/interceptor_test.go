@@ -0,0 +1,113 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func recordingInterceptor(name string, calls *[]string) CallInterceptor {
+	return func(next CallFunc) CallFunc {
+		return func(ctx context.Context, method string, params, result interface{}) error {
+			*calls = append(*calls, name+" "+method)
+			return next(ctx, method, params, result)
+		}
+	}
+}
+
+func TestCall_interceptorsRunOutermostFirst(t *testing.T) {
+	calls := []string{}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		calls = append(calls, "rpc "+method)
+		return true, nil
+	}}
+	c := &Client{rpc: rpc, interceptors: []CallInterceptor{recordingInterceptor("first", &calls), recordingInterceptor("second", &calls)}}
+
+	if err := c.Call("vm.start", map[string]interface{}{"id": testUuid}, nil); err != nil {
+		t.Fatalf("failed to call vm.start with error: %v", err)
+	}
+	expected := []string{"first vm.start", "second vm.start", "rpc vm.start"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected the calls %v but received %v", expected, calls)
+	}
+}
+
+func TestCall_interceptorsWrapRetries(t *testing.T) {
+	defer func(d time.Duration) { retryInitialDelay = d }(retryInitialDelay)
+	retryInitialDelay = time.Millisecond
+
+	attempts := 0
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return map[string]interface{}{}, nil
+	}}
+	calls := []string{}
+	c := &Client{rpc: rpc, maxRetries: 2, logger: log.New(&bytes.Buffer{}, "", 0), interceptors: []CallInterceptor{recordingInterceptor("interceptor", &calls)}}
+
+	var res map[string]interface{}
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, &res); err != nil {
+		t.Fatalf("expected the call to succeed once retried but received: %v", err)
+	}
+	if attempts != 3 || len(calls) != 1 {
+		t.Errorf("expected the interceptor to see a single call retried 3 times but saw %d calls for %d attempts", len(calls), attempts)
+	}
+}
+
+func TestCall_interceptorShortCircuits(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("unexpected call")
+	}}
+	cached := func(next CallFunc) CallFunc {
+		return func(ctx context.Context, method string, params, result interface{}) error {
+			if method == "xo.getAllObjects" {
+				*result.(*map[string]interface{}) = map[string]interface{}{"cached": true}
+				return nil
+			}
+			return next(ctx, method, params, result)
+		}
+	}
+	c := &Client{rpc: rpc, interceptors: []CallInterceptor{cached}}
+
+	var res map[string]interface{}
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, &res); err != nil || res["cached"] != true {
+		t.Errorf("expected the interceptor to return the result but received %v with error: %v", res, err)
+	}
+	if len(rpc.methods()) != 0 {
+		t.Errorf("expected no call to reach XO but received: %v", rpc.methods())
+	}
+}
+
+func TestCall_interceptorsSeeDryRunCalls(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return true, nil
+	}}
+	calls := []string{}
+	c := &Client{rpc: rpc, dryRun: true, interceptors: []CallInterceptor{recordingInterceptor("interceptor", &calls)}}
+
+	if err := c.Call("vm.delete", map[string]interface{}{"id": testUuid}, nil); err != nil {
+		t.Fatalf("failed to call vm.delete in a dry run with error: %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"interceptor vm.delete"}) || len(rpc.callsTo("vm.delete")) != 0 {
+		t.Errorf("expected the interceptor to see the call skipped by the dry run but received %v, %v", calls, rpc.methods())
+	}
+}
+
+func TestWithCallInterceptors(t *testing.T) {
+	calls := []string{}
+	config := newConfig("", WithCallInterceptors(recordingInterceptor("first", &calls)), WithCallInterceptors(recordingInterceptor("second", &calls)))
+
+	if len(config.CallInterceptors) != 2 {
+		t.Fatalf("expected the interceptors of both options to be kept but received %d", len(config.CallInterceptors))
+	}
+	chainInterceptors(func(ctx context.Context, method string, params, result interface{}) error { return nil }, config.CallInterceptors...)(context.Background(), "vm.start", nil, nil)
+	if !reflect.DeepEqual(calls, []string{"first vm.start", "second vm.start"}) {
+		t.Errorf("expected the interceptors to run in the order of the options but received %v", calls)
+	}
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Fields XO computes per server or that change from one read to the
+// next, ignored when comparing objects across servers.
+var volatileInventoryFields = map[string]bool{
+	"_xapiRef":           true,
+	"current_operations": true,
+	"startTime":          true,
+	"usage":              true,
+}
+
+// InventoryRecord identifies an object seen by one of the compared
+// servers. Server is the url of the XO server the record comes from.
+type InventoryRecord struct {
+	Server    string
+	Type      string
+	Uuid      string
+	Id        string
+	NameLabel string
+}
+
+// InventoryFieldDiff is a field whose value differs between the two
+// servers. A and B hold the value seen by each of them.
+type InventoryFieldDiff struct {
+	Field string
+	A     interface{}
+	B     interface{}
+}
+
+type InventoryObjectDiff struct {
+	Type      string
+	Uuid      string
+	NameLabel string
+	Fields    []InventoryFieldDiff
+}
+
+type InventoryDiff struct {
+	// Urls of the compared servers
+	A string
+	B string
+
+	OnlyInA []InventoryRecord
+	OnlyInB []InventoryRecord
+	// Objects seen by both servers with different field values
+	Changed []InventoryObjectDiff
+}
+
+// DiffInventories compares the objects of the given XO types, e.g. `VM`
+// or `SR`, seen by two XO servers. Objects are matched by UUID so pools
+// connected to both servers are reported as shared. Tags and other lists
+// of strings are compared regardless of their order and fields managed by
+// each XO server are ignored.
+func DiffInventories(a, b *Client, types []string) (*InventoryDiff, error) {
+	diff := &InventoryDiff{
+		A:       a.url,
+		B:       b.url,
+		OnlyInA: []InventoryRecord{},
+		OnlyInB: []InventoryRecord{},
+		Changed: []InventoryObjectDiff{},
+	}
+
+	for _, xoType := range types {
+		objsA, err := a.getInventory(xoType)
+		if err != nil {
+			return nil, err
+		}
+		objsB, err := b.getInventory(xoType)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, uuid := range sortedKeys(objsA) {
+			objA := objsA[uuid]
+			objB, ok := objsB[uuid]
+			if !ok {
+				diff.OnlyInA = append(diff.OnlyInA, inventoryRecord(a.url, xoType, uuid, objA))
+				continue
+			}
+
+			fields := diffInventoryObjects(objA, objB)
+			if len(fields) > 0 {
+				name, _ := objA["name_label"].(string)
+				diff.Changed = append(diff.Changed, InventoryObjectDiff{
+					Type:      xoType,
+					Uuid:      uuid,
+					NameLabel: name,
+					Fields:    fields,
+				})
+			}
+		}
+
+		for _, uuid := range sortedKeys(objsB) {
+			if _, ok := objsA[uuid]; !ok {
+				diff.OnlyInB = append(diff.OnlyInB, inventoryRecord(b.url, xoType, uuid, objsB[uuid]))
+			}
+		}
+	}
+	return diff, nil
+}
+
+// getInventory returns the objects of the given type keyed by UUID.
+func (c *Client) getInventory(xoType string) (map[string]map[string]interface{}, error) {
+	var response map[string]map[string]interface{}
+	if err := c.getAllObjectsOfXoType(xoType, &response); err != nil {
+		return nil, err
+	}
+
+	objs := map[string]map[string]interface{}{}
+	for id, obj := range response {
+		uuid, _ := obj["uuid"].(string)
+		if uuid == "" {
+			uuid = id
+		}
+		objs[uuid] = obj
+	}
+	return objs, nil
+}
+
+func inventoryRecord(server, xoType, uuid string, obj map[string]interface{}) InventoryRecord {
+	id, _ := obj["id"].(string)
+	name, _ := obj["name_label"].(string)
+	return InventoryRecord{
+		Server:    server,
+		Type:      xoType,
+		Uuid:      uuid,
+		Id:        id,
+		NameLabel: name,
+	}
+}
+
+func diffInventoryObjects(a, b map[string]interface{}) []InventoryFieldDiff {
+	fields := map[string]bool{}
+	for field := range a {
+		fields[field] = true
+	}
+	for field := range b {
+		fields[field] = true
+	}
+
+	diffs := []InventoryFieldDiff{}
+	for _, field := range sortedKeys(fields) {
+		if volatileInventoryFields[field] {
+			continue
+		}
+
+		valueA, valueB := normalizeInventoryValue(a[field]), normalizeInventoryValue(b[field])
+		if !reflect.DeepEqual(valueA, valueB) {
+			diffs = append(diffs, InventoryFieldDiff{Field: field, A: valueA, B: valueB})
+		}
+	}
+	return diffs
+}
+
+// normalizeInventoryValue sorts lists of strings so they are compared
+// regardless of their order and normalizes MAC addresses.
+func normalizeInventoryValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []interface{}:
+		s := []string{}
+		for _, item := range value {
+			str, ok := item.(string)
+			if !ok {
+				return v
+			}
+			s = append(s, str)
+		}
+		return sortedCopy(s)
+	case string:
+		if mac, err := NormalizeMacAddress(value); err == nil && strings.Count(value, ":") == 5 {
+			return mac
+		}
+	}
+	return v
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func fakeInventoryClient(url string, objects ...map[string]interface{}) *Client {
+	return &Client{
+		url: url,
+		rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+			return fakeGetAllObjects(params, objects...), nil
+		}},
+	}
+}
+
+func TestDiffInventories(t *testing.T) {
+	a := fakeInventoryClient("ws://xo-a",
+		map[string]interface{}{"id": "a-pool", "uuid": "pool-shared", "type": "pool", "name_label": "Shared", "_xapiRef": "OpaqueRef:1"},
+		map[string]interface{}{"id": "vm-1", "uuid": "vm-1", "type": "VM", "name_label": "web", "tags": []string{"prod", "web"}, "CPUs": map[string]interface{}{"number": 2}},
+		map[string]interface{}{"id": "vm-2", "uuid": "vm-2", "type": "VM", "name_label": "db", "CPUs": map[string]interface{}{"number": 4}},
+		map[string]interface{}{"id": "vm-a", "uuid": "vm-a", "type": "VM", "name_label": "only a"},
+		map[string]interface{}{"id": "sr-1", "uuid": "sr-1", "type": "SR", "name_label": "local"},
+	)
+	b := fakeInventoryClient("ws://xo-b",
+		map[string]interface{}{"id": "b-pool", "uuid": "pool-shared", "type": "pool", "name_label": "Shared", "_xapiRef": "OpaqueRef:2"},
+		map[string]interface{}{"id": "vm-1", "uuid": "vm-1", "type": "VM", "name_label": "web", "tags": []string{"web", "prod"}, "CPUs": map[string]interface{}{"number": 2}},
+		map[string]interface{}{"id": "vm-2", "uuid": "vm-2", "type": "VM", "name_label": "db", "CPUs": map[string]interface{}{"number": 8}},
+		map[string]interface{}{"id": "vm-b", "uuid": "vm-b", "type": "VM", "name_label": "only b"},
+		map[string]interface{}{"id": "sr-2", "uuid": "sr-2", "type": "SR", "name_label": "nfs"},
+	)
+
+	diff, err := DiffInventories(a, b, []string{"pool", "VM"})
+	if err != nil {
+		t.Fatalf("failed to diff inventories with error: %v", err)
+	}
+
+	if diff.A != "ws://xo-a" || diff.B != "ws://xo-b" {
+		t.Errorf("expected the diff to identify both servers but received %s and %s", diff.A, diff.B)
+	}
+	expectedOnlyInA := []InventoryRecord{{Server: "ws://xo-a", Type: "VM", Uuid: "vm-a", Id: "vm-a", NameLabel: "only a"}}
+	if !reflect.DeepEqual(diff.OnlyInA, expectedOnlyInA) {
+		t.Errorf("expected %+v to only be on xo-a but received %+v", expectedOnlyInA, diff.OnlyInA)
+	}
+	expectedOnlyInB := []InventoryRecord{{Server: "ws://xo-b", Type: "VM", Uuid: "vm-b", Id: "vm-b", NameLabel: "only b"}}
+	if !reflect.DeepEqual(diff.OnlyInB, expectedOnlyInB) {
+		t.Errorf("expected %+v to only be on xo-b but received %+v", expectedOnlyInB, diff.OnlyInB)
+	}
+
+	// The shared pool only differs by its id and XAPI ref, vm-1 by the
+	// order of its tags
+	if len(diff.Changed) != 2 {
+		t.Fatalf("expected the pool ids and vm-2 to differ but received: %+v", diff.Changed)
+	}
+	if pool := diff.Changed[0]; pool.Uuid != "pool-shared" || len(pool.Fields) != 1 || pool.Fields[0].Field != "id" {
+		t.Errorf("expected the shared pool to only differ by id but received: %+v", pool)
+	}
+	vm := diff.Changed[1]
+	if vm.Uuid != "vm-2" || len(vm.Fields) != 1 || vm.Fields[0].Field != "CPUs" {
+		t.Fatalf("expected vm-2 to differ by its CPUs but received: %+v", vm)
+	}
+	cpusA := vm.Fields[0].A.(map[string]interface{})["number"]
+	cpusB := vm.Fields[0].B.(map[string]interface{})["number"]
+	if cpusA != float64(4) || cpusB != float64(8) {
+		t.Errorf("expected 4 CPUs on xo-a and 8 on xo-b but received %v and %v", cpusA, cpusB)
+	}
+}
@@ -0,0 +1,259 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+type SnapshotFormat string
+
+const (
+	// A single json document holding the metadata and the objects
+	SnapshotFormatJson SnapshotFormat = "json"
+	// One json document per line, the metadata first and then an object
+	// per line
+	SnapshotFormatNdjson SnapshotFormat = "ndjson"
+)
+
+// Types of the objects exported by ExportInventorySnapshot when the options
+// don't list any.
+var DefaultSnapshotTypes = []string{"pool", "host", "SR", "PBD", "VDI", "VBD", "VM", "VIF", "network", "PIF"}
+
+type SnapshotOptions struct {
+	// Types of the objects to export, e.g. `VM` or `SR`. Defaults to
+	// DefaultSnapshotTypes.
+	Types []string
+	// Defaults to SnapshotFormatJson.
+	Format SnapshotFormat
+	// Replace the name labels and descriptions, IP and MAC addresses by
+	// a hash of their value. Equal values have the same hash within an
+	// export so the relationships between objects remain visible.
+	Redact bool
+	// Key of the hashes of the redacted values. A random key is used for
+	// each export when empty, so hashes can't be compared across exports.
+	RedactionKey []byte
+}
+
+// SnapshotMetadata describes an inventory snapshot. It is the first line
+// of an ndjson snapshot and the `metadata` of a json one.
+type SnapshotMetadata struct {
+	// Empty when XO doesn't report its version
+	XoVersion string         `json:"xoVersion,omitempty"`
+	Time      time.Time      `json:"time"`
+	Counts    map[string]int `json:"counts"`
+	Redacted  bool           `json:"redacted"`
+}
+
+// ExportInventorySnapshot writes the objects of the selected types as XO
+// reports them to w, e.g. to attach them to a support request. The objects
+// are all listed before the first one is written so that the metadata can
+// hold their counts, and ctx bounds the whole export.
+func (c *Client) ExportInventorySnapshot(ctx context.Context, w io.Writer, opts SnapshotOptions) error {
+	types := opts.Types
+	if len(types) == 0 {
+		types = DefaultSnapshotTypes
+	}
+	format := opts.Format
+	if format == "" {
+		format = SnapshotFormatJson
+	}
+	if format != SnapshotFormatJson && format != SnapshotFormatNdjson {
+		return errors.New(fmt.Sprintf("unknown snapshot format `%s`, expected %s or %s", format, SnapshotFormatJson, SnapshotFormatNdjson))
+	}
+
+	var r *snapshotRedactor
+	if opts.Redact {
+		var err error
+		if r, err = newSnapshotRedactor(opts.RedactionKey); err != nil {
+			return err
+		}
+	}
+
+	metadata := SnapshotMetadata{
+		Time:     time.Now().UTC(),
+		Counts:   map[string]int{},
+		Redacted: opts.Redact,
+	}
+	if err := c.Call("system.getServerVersion", map[string]interface{}{}, &metadata.XoVersion); err != nil {
+		c.logf("[WARN] Failed to get the XO version of the inventory snapshot: %v\n", err)
+	}
+
+	objsByType := map[string]map[string]json.RawMessage{}
+	for _, xoType := range types {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var objs map[string]json.RawMessage
+		if err := c.getAllObjectsOfXoType(xoType, &objs); err != nil {
+			return err
+		}
+		objsByType[xoType] = objs
+		metadata.Counts[xoType] = len(objs)
+	}
+
+	s := &snapshotWriter{w: w, format: format}
+	s.begin(metadata)
+	for _, xoType := range types {
+		objs := objsByType[xoType]
+		for _, id := range sortedKeys(objs) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			obj, err := r.redact(objs[id])
+			if err != nil {
+				return errors.New(fmt.Sprintf("failed to export %s `%s`: %v", xoType, id, err))
+			}
+			s.object(obj)
+		}
+	}
+	s.end()
+	return s.err
+}
+
+// snapshotWriter writes the documents of a snapshot, keeping the first
+// write error.
+type snapshotWriter struct {
+	w       io.Writer
+	format  SnapshotFormat
+	objects int
+	err     error
+}
+
+func (s *snapshotWriter) write(b ...[]byte) {
+	for _, p := range b {
+		if s.err == nil {
+			_, s.err = s.w.Write(p)
+		}
+	}
+}
+
+func (s *snapshotWriter) begin(metadata SnapshotMetadata) {
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if s.format == SnapshotFormatNdjson {
+		s.write([]byte(`{"metadata":`), b, []byte("}\n"))
+		return
+	}
+	s.write([]byte(`{"metadata":`), b, []byte(`,"objects":[`))
+}
+
+func (s *snapshotWriter) object(obj []byte) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, obj); err != nil {
+		if s.err == nil {
+			s.err = err
+		}
+		return
+	}
+
+	if s.format == SnapshotFormatNdjson {
+		s.write(compact.Bytes(), []byte("\n"))
+		return
+	}
+	if s.objects > 0 {
+		s.write([]byte(","))
+	}
+	s.write(compact.Bytes())
+	s.objects++
+}
+
+func (s *snapshotWriter) end() {
+	if s.format == SnapshotFormatJson {
+		s.write([]byte("]}\n"))
+	}
+}
+
+// Fields whose values are redacted regardless of their content
+var redactedSnapshotFields = []string{"name_label", "name_description"}
+
+// snapshotRedactor replaces the identifying values of objects with an HMAC
+// of their value, so that equal values are replaced by equal hashes.
+type snapshotRedactor struct {
+	key []byte
+}
+
+func newSnapshotRedactor(key []byte) (*snapshotRedactor, error) {
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &snapshotRedactor{key: key}, nil
+}
+
+// redact returns obj with its identifying values redacted, obj itself when
+// r is nil.
+func (r *snapshotRedactor) redact(obj json.RawMessage) ([]byte, error) {
+	if r == nil {
+		return obj, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(obj))
+	// Keep large numbers, e.g. sizes in bytes, as they are
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.value("", v))
+}
+
+func (r *snapshotRedactor) value(field string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, e := range v {
+			v[key] = r.value(key, e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = r.value(field, e)
+		}
+		return v
+	case string:
+		return r.string(field, v)
+	}
+	return v
+}
+
+func (r *snapshotRedactor) string(field, s string) string {
+	if s == "" {
+		return s
+	}
+	if stringInSlice(field, redactedSnapshotFields) {
+		return r.hash("name", s)
+	}
+	// Addresses are hashed in their canonical form so that they match
+	// regardless of how XO formats them.
+	if ip := net.ParseIP(s); ip != nil {
+		return r.hash("ip", ip.String())
+	}
+	if ip, network, err := net.ParseCIDR(s); err == nil {
+		ones, _ := network.Mask.Size()
+		return r.hash("ip", fmt.Sprintf("%s/%d", ip, ones))
+	}
+	if mac, err := net.ParseMAC(s); err == nil {
+		return r.hash("mac", mac.String())
+	}
+	return s
+}
+
+func (r *snapshotRedactor) hash(kind, s string) string {
+	h := hmac.New(sha256.New, r.key)
+	h.Write([]byte(kind + ":" + s))
+	return fmt.Sprintf("%s-%s", kind, hex.EncodeToString(h.Sum(nil))[:16])
+}
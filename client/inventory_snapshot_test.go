@@ -0,0 +1,158 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func snapshotClient(objects ...map[string]interface{}) *Client {
+	return &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "system.getServerVersion" {
+			return "5.90.0", nil
+		}
+		return fakeGetAllObjects(params, objects...), nil
+	}}}
+}
+
+func TestExportInventorySnapshot_ndjson(t *testing.T) {
+	objects := []map[string]interface{}{}
+	for i := 0; i < 1000; i++ {
+		objects = append(objects, map[string]interface{}{
+			"id":         fmt.Sprintf("vm-%04d", i),
+			"type":       "VM",
+			"name_label": fmt.Sprintf("web %d", i),
+			"memory":     map[string]interface{}{"size": 68719476736},
+		})
+	}
+	objects = append(objects, map[string]interface{}{"id": "pool-1", "type": "pool", "name_label": "prod"})
+	c := snapshotClient(objects...)
+
+	var out bytes.Buffer
+	err := c.ExportInventorySnapshot(context.Background(), &out, SnapshotOptions{Types: []string{"pool", "VM"}, Format: SnapshotFormatNdjson})
+	if err != nil {
+		t.Fatalf("failed to export the snapshot with error: %v", err)
+	}
+
+	scanner := bufio.NewScanner(&out)
+	lines := 0
+	var metadata struct {
+		Metadata SnapshotMetadata `json:"metadata"`
+	}
+	for ; scanner.Scan(); lines++ {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+			t.Fatalf("line %d isn't a json document: %v\n%s", lines+1, err, scanner.Text())
+		}
+		if lines == 0 {
+			json.Unmarshal(scanner.Bytes(), &metadata)
+		} else if doc["type"] == nil {
+			t.Errorf("line %d isn't an object: %s", lines+1, scanner.Text())
+		}
+	}
+
+	if lines != 1002 {
+		t.Errorf("expected the metadata and 1001 objects but received %d lines", lines)
+	}
+	if m := metadata.Metadata; m.XoVersion != "5.90.0" || m.Counts["VM"] != 1000 || m.Counts["pool"] != 1 || m.Time.IsZero() || m.Redacted {
+		t.Errorf("expected the metadata to describe the snapshot but received: %+v", m)
+	}
+}
+
+func TestExportInventorySnapshot_json(t *testing.T) {
+	c := snapshotClient(
+		map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "web"},
+		map[string]interface{}{"id": "vm-2", "type": "VM", "name_label": "db"},
+	)
+
+	var out bytes.Buffer
+	if err := c.ExportInventorySnapshot(context.Background(), &out, SnapshotOptions{Types: []string{"VM"}}); err != nil {
+		t.Fatalf("failed to export the snapshot with error: %v", err)
+	}
+
+	var snapshot struct {
+		Metadata SnapshotMetadata         `json:"metadata"`
+		Objects  []map[string]interface{} `json:"objects"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &snapshot); err != nil {
+		t.Fatalf("expected a json document but received error: %v\n%s", err, out.String())
+	}
+	if len(snapshot.Objects) != 2 || snapshot.Objects[0]["id"] != "vm-1" || snapshot.Objects[1]["name_label"] != "db" {
+		t.Errorf("expected the VMs sorted by id but received: %v", snapshot.Objects)
+	}
+}
+
+func TestExportInventorySnapshot_redaction(t *testing.T) {
+	c := snapshotClient(
+		map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "web", "addresses": map[string]interface{}{"0/ipv4/0": "10.0.0.5"}, "memory": map[string]interface{}{"size": 9007199254740993}},
+		map[string]interface{}{"id": "vm-2", "type": "VM", "name_label": "web", "addresses": map[string]interface{}{"0/ipv4/0": "10.0.0.6"}},
+		map[string]interface{}{"id": "vif-1", "type": "VIF", "MAC": "02:16:3E:00:00:01", "$VM": "vm-1"},
+		map[string]interface{}{"id": "vif-2", "type": "VIF", "MAC": "02:16:3e:00:00:01", "$VM": "vm-2"},
+		map[string]interface{}{"id": "pif-1", "type": "PIF", "ip": "10.0.0.5", "netmask": "255.255.255.0", "ipv6": []string{"fd00::5/64"}},
+	)
+
+	export := func(key []byte) map[string]map[string]interface{} {
+		var out bytes.Buffer
+		err := c.ExportInventorySnapshot(context.Background(), &out, SnapshotOptions{Types: []string{"VM", "VIF", "PIF"}, Format: SnapshotFormatNdjson, Redact: true, RedactionKey: key})
+		if err != nil {
+			t.Fatalf("failed to export the snapshot with error: %v", err)
+		}
+		if s := out.String(); strings.Contains(s, "10.0.0.5") || strings.Contains(s, `"web"`) || strings.Contains(strings.ToLower(s), "02:16:3e") || strings.Contains(s, "fd00::") {
+			t.Errorf("expected the names and addresses to be redacted but received:\n%s", s)
+		}
+		if !strings.Contains(out.String(), "9007199254740993") {
+			t.Errorf("expected numbers to be kept as they are but received:\n%s", out.String())
+		}
+
+		objs := map[string]map[string]interface{}{}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		for _, line := range lines[1:] {
+			var obj map[string]interface{}
+			json.Unmarshal([]byte(line), &obj)
+			objs[obj["id"].(string)] = obj
+		}
+		return objs
+	}
+
+	objs := export(nil)
+	ip := func(id string) interface{} { return objs[id]["addresses"].(map[string]interface{})["0/ipv4/0"] }
+	if objs["vm-1"]["name_label"] != objs["vm-2"]["name_label"] {
+		t.Errorf("expected equal names to have the same hash but received %v and %v", objs["vm-1"]["name_label"], objs["vm-2"]["name_label"])
+	}
+	if ip("vm-1") == ip("vm-2") {
+		t.Errorf("expected different addresses to have different hashes but received %v", ip("vm-1"))
+	}
+	if ip("vm-1") != objs["pif-1"]["ip"] {
+		t.Errorf("expected the same address to have the same hash across objects but received %v and %v", ip("vm-1"), objs["pif-1"]["ip"])
+	}
+	if objs["vif-1"]["MAC"] != objs["vif-2"]["MAC"] {
+		t.Errorf("expected the MAC address to have the same hash regardless of its case but received %v and %v", objs["vif-1"]["MAC"], objs["vif-2"]["MAC"])
+	}
+	if objs["vif-1"]["$VM"] != "vm-1" {
+		t.Errorf("expected the references between objects to be kept but received %v", objs["vif-1"]["$VM"])
+	}
+
+	key := []byte("support-ticket-42")
+	first, second := export(key), export(key)
+	if first["vm-1"]["name_label"] != second["vm-1"]["name_label"] {
+		t.Errorf("expected the hashes to be reproducible with the same key")
+	}
+	if first["vm-1"]["name_label"] == objs["vm-1"]["name_label"] {
+		t.Errorf("expected the hashes to depend on the key")
+	}
+}
+
+func TestExportInventorySnapshot_canceled(t *testing.T) {
+	c := snapshotClient(map[string]interface{}{"id": "vm-1", "type": "VM"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	if err := c.ExportInventorySnapshot(ctx, &out, SnapshotOptions{}); err != context.Canceled {
+		t.Errorf("expected the export to be canceled but received: %v", err)
+	}
+}
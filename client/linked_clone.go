@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// LinkedClonesExistError is returned when deleting a snapshot which is the
+// base of linked clones.
+type LinkedClonesExistError struct {
+	SnapshotId string
+	// Ids of the VMs whose disks are based on the snapshot's
+	Clones []string
+}
+
+func (e LinkedClonesExistError) Error() string {
+	return fmt.Sprintf("cannot delete snapshot `%s` while it is the base of the linked clones: %s", e.SnapshotId, strings.Join(e.Clones, ", "))
+}
+
+// CreateLinkedClone snapshots a VM and creates a VM of the given name from
+// the snapshot. The disks of the new VM are differencing VHDs on top of
+// the snapshot's rather than full copies, so the snapshot can't be
+// deleted while the clone exists.
+func (c *Client) CreateLinkedClone(vmId, name string) (*Vm, error) {
+	var snapshotId string
+	params := map[string]interface{}{
+		"id":   vmId,
+		"name": fmt.Sprintf("%s base", name),
+	}
+	err := c.Call("vm.snapshot", params, &snapshotId)
+	if err != nil {
+		return nil, err
+	}
+
+	var cloneId string
+	params = map[string]interface{}{
+		"id":        snapshotId,
+		"name":      name,
+		"full_copy": false,
+	}
+	err = c.Call("vm.clone", params, &cloneId)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[DEBUG] Created linked clone `%s` of vm `%s` based on snapshot `%s`\n", cloneId, vmId, snapshotId)
+	return c.GetVm(Vm{Id: cloneId})
+}
+
+// getLinkedClones returns the VMs whose disks are based on the disks of
+// the snapshot, nil when id isn't a snapshot. Like the disks of the
+// snapshot and of the VM it was taken of, the disks of linked clones are
+// children of the snapshot's base VHDs. Only the objects related to the
+// snapshot are looked up.
+func (c *Client) getLinkedClones(ctx context.Context, id string) ([]string, error) {
+	var snapshots map[string]struct {
+		SnapshotOf string `json:"$snapshot_of"`
+	}
+	err := c.getObjectsMatching(ctx, map[string]interface{}{"id": id, "type": "VM-snapshot"}, &snapshots)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, ok := snapshots[id]
+	if !ok {
+		return nil, nil
+	}
+
+	var snapshotVbds map[string]VBD
+	if err := c.getObjectsMatching(ctx, map[string]interface{}{"type": "VBD", "VM": id}, &snapshotVbds); err != nil {
+		return nil, err
+	}
+	snapshotVdiIds := []string{}
+	for _, vbd := range snapshotVbds {
+		snapshotVdiIds = append(snapshotVdiIds, vbd.VDI)
+	}
+	if len(snapshotVdiIds) == 0 {
+		return nil, nil
+	}
+
+	var snapshotVdis map[string]VDI
+	if err := c.getObjectsMatching(ctx, map[string]interface{}{"type": "VDI-snapshot", "id": anyOf(snapshotVdiIds)}, &snapshotVdis); err != nil {
+		return nil, err
+	}
+	bases := []string{}
+	for _, vdi := range snapshotVdis {
+		bases = append(bases, vdi.VDIId)
+		if vdi.Parent != "" {
+			bases = append(bases, vdi.Parent)
+		}
+	}
+	if len(bases) == 0 {
+		return nil, nil
+	}
+
+	var vdis map[string]VDI
+	if err := c.getObjectsMatching(ctx, map[string]interface{}{"type": "VDI", "parent": anyOf(bases)}, &vdis); err != nil {
+		return nil, err
+	}
+	vdiIds := []string{}
+	for vdiId := range vdis {
+		vdiIds = append(vdiIds, vdiId)
+	}
+	if len(vdiIds) == 0 {
+		return nil, nil
+	}
+
+	var vbds map[string]VBD
+	if err := c.getObjectsMatching(ctx, map[string]interface{}{"type": "VBD", "VDI": anyOf(vdiIds)}, &vbds); err != nil {
+		return nil, err
+	}
+	clones := map[string]bool{}
+	for _, vbd := range vbds {
+		if vbd.VmId != id && vbd.VmId != snapshot.SnapshotOf {
+			clones[vbd.VmId] = true
+		}
+	}
+
+	ids := []string{}
+	for cloneId := range clones {
+		ids = append(ids, cloneId)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func linkedCloneFixtures() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"id": "vm-1", "type": "VM"},
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "vdi-1"},
+		{"id": "vdi-1", "name_label": "vdi-1", "type": "VDI", "parent": "base-1"},
+		{"id": "snap-1", "type": "VM-snapshot", "$snapshot_of": "vm-1"},
+		{"id": "vbd-2", "type": "VBD", "VM": "snap-1", "VDI": "snap-vdi-1"},
+		{"id": "snap-vdi-1", "name_label": "snap-vdi-1", "type": "VDI-snapshot", "parent": "base-1"},
+		{"id": "clone-1", "type": "VM", "name_label": "web-2"},
+		{"id": "vbd-3", "type": "VBD", "VM": "clone-1", "VDI": "clone-vdi-1"},
+		{"id": "clone-vdi-1", "name_label": "clone-vdi-1", "type": "VDI", "parent": "base-1"},
+		{"id": "vm-2", "type": "VM"},
+		{"id": "vbd-4", "type": "VBD", "VM": "vm-2", "VDI": "vdi-2"},
+		{"id": "vdi-2", "name_label": "vdi-2", "type": "VDI"},
+	}
+}
+
+func TestCreateLinkedClone(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "vm.snapshot":
+			return "snap-1", nil
+		case "vm.clone":
+			return "clone-1", nil
+		}
+		return fakeGetAllObjects(params, linkedCloneFixtures()...), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	vm, err := c.CreateLinkedClone("vm-1", "web-2")
+	if err != nil {
+		t.Fatalf("failed to create linked clone with error: %v", err)
+	}
+	if vm.Id != "clone-1" || vm.NameLabel != "web-2" {
+		t.Errorf("expected the clone to be returned but received: %+v", vm)
+	}
+
+	if snapshot := rpc.callsTo("vm.snapshot")[0].params; snapshot["id"] != "vm-1" {
+		t.Errorf("expected vm-1 to be snapshotted but received: %v", snapshot)
+	}
+	expected := map[string]interface{}{"id": "snap-1", "name": "web-2", "full_copy": false}
+	if clone := rpc.callsTo("vm.clone")[0].params; !reflect.DeepEqual(clone, expected) {
+		t.Errorf("expected a fast clone of the snapshot %v but received: %v", expected, clone)
+	}
+
+	disks, err := c.GetDisks(vm)
+	if err != nil || len(disks) != 1 || disks[0].Parent != "base-1" {
+		t.Errorf("expected the clone's disk to report a parent but received %+v with error: %v", disks, err)
+	}
+}
+
+func TestDeleteVmContext_snapshotWithLinkedClones(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "vm.delete" {
+			return []interface{}{}, nil
+		}
+		return fakeGetAllObjects(params, linkedCloneFixtures()...), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	var clonesExist LinkedClonesExistError
+	err := c.DeleteVmContext(context.Background(), "snap-1", DeleteVmOptions{})
+	if !errors.As(err, &clonesExist) || !reflect.DeepEqual(clonesExist.Clones, []string{"clone-1"}) {
+		t.Fatalf("expected a LinkedClonesExistError listing clone-1 but received: %v", err)
+	}
+	if len(rpc.callsTo("vm.delete")) != 0 {
+		t.Errorf("expected the snapshot not to be deleted")
+	}
+
+	if err := c.DeleteVmContext(context.Background(), "clone-1", DeleteVmOptions{}); err != nil {
+		t.Errorf("expected the clone to be deleted but received: %v", err)
+	}
+	if err := c.DeleteVmContext(context.Background(), "vm-2", DeleteVmOptions{}); err != nil {
+		t.Errorf("expected vm-2 to be deleted but received: %v", err)
+	}
+}
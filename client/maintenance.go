@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+type VmEvacuationFailure struct {
+	VmId string
+	Err  error
+}
+
+// EvacuationError is returned by EnterMaintenanceMode when some VMs could
+// not be moved off the host, usually because no other host of the pool
+// has enough capacity left.
+type EvacuationError struct {
+	HostId string
+	Failed []VmEvacuationFailure
+}
+
+func (e EvacuationError) Error() string {
+	failures := []string{}
+	for _, f := range e.Failed {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.VmId, f.Err))
+	}
+	return fmt.Sprintf("failed to evacuate %d VM(s) from host `%s`: %s", len(e.Failed), e.HostId, strings.Join(failures, ", "))
+}
+
+// EnterMaintenanceMode disables the host so it doesn't accept new VMs and
+// migrates its running VMs to the other enabled hosts of its pool. It
+// returns once every VM has been migrated. The host is left disabled when
+// some VMs couldn't be moved, see EvacuationError.
+func (c *Client) EnterMaintenanceMode(ctx context.Context, hostId string) error {
+	host, err := c.GetHostById(hostId)
+	if err != nil {
+		return err
+	}
+
+	var success bool
+	err = c.callContext(ctx, "host.disable", map[string]interface{}{"id": hostId}, &success)
+	if err != nil {
+		return err
+	}
+
+	vms := map[string]Vm{}
+	err = c.callContext(ctx, "xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":        "VM",
+			"$container":  hostId,
+			"power_state": PowerStateRunning,
+		},
+	}, &vms)
+	if err != nil {
+		return err
+	}
+	if len(vms) == 0 {
+		return nil
+	}
+
+	targets, err := c.getEvacuationTargets(ctx, host)
+	if err != nil {
+		return err
+	}
+
+	ids := []string{}
+	for id := range vms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	evacuationErr := EvacuationError{HostId: hostId}
+	for _, id := range ids {
+		vm := vms[id]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The SRs and networks of the VM must be reachable from the target
+		if err := c.loadVmResources(&vm); err != nil {
+			evacuationErr.Failed = append(evacuationErr.Failed, VmEvacuationFailure{VmId: vm.Id, Err: err})
+			continue
+		}
+		target, reason := pickEvacuationTarget(targets, vm)
+		if target == nil {
+			evacuationErr.Failed = append(evacuationErr.Failed, VmEvacuationFailure{
+				VmId: vm.Id,
+				Err:  fmt.Errorf("no host can fit the VM: %s", reason),
+			})
+			continue
+		}
+
+		log.Printf("[DEBUG] Evacuating vm `%s` from host `%s` to host `%s`\n", vm.Id, hostId, target.Id)
+		err := c.callContext(ctx, "vm.migrate", map[string]interface{}{
+			"vm":         vm.Id,
+			"targetHost": target.Id,
+		}, &success)
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			evacuationErr.Failed = append(evacuationErr.Failed, VmEvacuationFailure{VmId: vm.Id, Err: err})
+			continue
+		}
+		target.ResidentVms = append(target.ResidentVms, vm)
+	}
+
+	if len(evacuationErr.Failed) > 0 {
+		return evacuationErr
+	}
+	return nil
+}
+
+// ExitMaintenanceMode enables the host again. VMs migrated away by
+// EnterMaintenanceMode are not moved back.
+func (c *Client) ExitMaintenanceMode(hostId string) error {
+	var success bool
+	return c.Call("host.enable", map[string]interface{}{"id": hostId}, &success)
+}
+
+// getEvacuationTargets returns the other enabled hosts of the host's pool
+// along with their inventory.
+func (c *Client) getEvacuationTargets(ctx context.Context, host Host) ([]*Host, error) {
+	hosts := map[string]Host{}
+	err := c.callContext(ctx, "xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type":    "host",
+			"$pool":   host.Pool,
+			"enabled": true,
+		},
+	}, &hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []*Host{}
+	for _, h := range hosts {
+		if h.Id == host.Id {
+			continue
+		}
+		target, err := c.GetHostWithInventory(h.Id)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Id < targets[j].Id
+	})
+	return targets, nil
+}
+
+// pickEvacuationTarget returns the host with the most free memory that
+// can fit the VM, or the reason the last host couldn't.
+func pickEvacuationTarget(targets []*Host, vm Vm) (*Host, string) {
+	var best *Host
+	reason := "no other enabled host in the pool"
+	for _, target := range targets {
+		fits, why := CanHostFitVm(*target, vm)
+		if !fits {
+			reason = why
+			continue
+		}
+		if best == nil || target.FreeMemory() > best.FreeMemory() {
+			best = target
+		}
+	}
+	return best, reason
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func fakeMaintenanceRPC(targetMemory int) *fakeRPC {
+	objects := []map[string]interface{}{
+		{"id": "host-1", "type": "host", "$pool": "pool-1", "enabled": true, "memory": map[string]interface{}{"size": 64 * gib}},
+		{"id": "host-2", "type": "host", "$pool": "pool-1", "enabled": true, "memory": map[string]interface{}{"size": targetMemory}},
+		{"id": "host-3", "type": "host", "$pool": "pool-2", "enabled": true, "memory": map[string]interface{}{"size": 64 * gib}},
+		{"id": "vm-a", "type": "VM", "$container": "host-1", "power_state": "Running", "memory": map[string]interface{}{"static": []int{0, 8 * gib}}},
+		{"id": "vm-b", "type": "VM", "$container": "host-1", "power_state": "Running", "memory": map[string]interface{}{"static": []int{0, 12 * gib}}},
+		{"id": "vm-c", "type": "VM", "$container": "host-1", "power_state": "Halted"},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, objects...), nil
+		}
+		return true, nil
+	}}
+}
+
+func TestEnterMaintenanceMode_disablesThenEvacuates(t *testing.T) {
+	rpc := fakeMaintenanceRPC(32 * gib)
+	c := &Client{rpc: rpc}
+
+	if err := c.EnterMaintenanceMode(context.Background(), "host-1"); err != nil {
+		t.Fatalf("failed to enter maintenance mode with error: %v", err)
+	}
+
+	mutations := []fakeRPCCall{}
+	for _, call := range rpc.calls {
+		if call.method != "xo.getAllObjects" {
+			mutations = append(mutations, call)
+		}
+	}
+	if len(mutations) != 3 || mutations[0].method != "host.disable" || mutations[0].params["id"] != "host-1" {
+		t.Fatalf("expected the host to be disabled before its VMs are migrated but received calls: %v", rpc.methods())
+	}
+	for i, vmId := range []string{"vm-a", "vm-b"} {
+		migrate := mutations[i+1]
+		if migrate.method != "vm.migrate" || migrate.params["vm"] != vmId || migrate.params["targetHost"] != "host-2" {
+			t.Errorf("expected %s to be migrated to host-2 but received: %+v", vmId, migrate)
+		}
+	}
+}
+
+func TestEnterMaintenanceMode_stopsWithContext(t *testing.T) {
+	rpc := fakeMaintenanceRPC(32 * gib)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The migration of vm-a is interrupted, vm-b isn't migrated
+	interceptor := func(next CallFunc) CallFunc {
+		return func(callCtx context.Context, method string, params, result interface{}) error {
+			if method != "xo.getAllObjects" && callCtx != ctx {
+				t.Errorf("expected %s to be called with the context of the caller", method)
+			}
+			if method == "vm.migrate" {
+				cancel()
+				return callCtx.Err()
+			}
+			return next(callCtx, method, params, result)
+		}
+	}
+	c := &Client{rpc: rpc, interceptors: []CallInterceptor{interceptor}}
+
+	if err := c.EnterMaintenanceMode(ctx, "host-1"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected entering maintenance mode to stop with the context but received: %v", err)
+	}
+	if calls := rpc.callsTo("vm.migrate"); len(calls) != 0 {
+		t.Errorf("expected no VM to be migrated but received: %v", calls)
+	}
+}
+
+func TestEnterMaintenanceMode_reportsVmsThatCouldNotMove(t *testing.T) {
+	rpc := fakeMaintenanceRPC(16 * gib)
+	c := &Client{rpc: rpc}
+
+	err := c.EnterMaintenanceMode(context.Background(), "host-1")
+	var evacuationErr EvacuationError
+	if !errors.As(err, &evacuationErr) {
+		t.Fatalf("expected an EvacuationError but received: %v", err)
+	}
+	if len(evacuationErr.Failed) != 1 || evacuationErr.Failed[0].VmId != "vm-b" {
+		t.Errorf("expected vm-b not to fit on host-2 once vm-a moved but received: %+v", evacuationErr.Failed)
+	}
+	if migrations := rpc.callsTo("vm.migrate"); len(migrations) != 1 || migrations[0].params["vm"] != "vm-a" {
+		t.Errorf("expected only vm-a to be migrated but received: %v", migrations)
+	}
+}
+
+func TestExitMaintenanceMode(t *testing.T) {
+	rpc := fakeMaintenanceRPC(16 * gib)
+	c := &Client{rpc: rpc}
+
+	if err := c.ExitMaintenanceMode("host-1"); err != nil {
+		t.Fatalf("failed to exit maintenance mode with error: %v", err)
+	}
+	if enable := rpc.callsTo("host.enable"); len(enable) != 1 || enable[0].params["id"] != "host-1" {
+		t.Errorf("expected the host to be enabled but received calls: %v", rpc.methods())
+	}
+}
+
+func TestEnterMaintenanceMode_checksStorageOfVms(t *testing.T) {
+	objects := []map[string]interface{}{
+		{"id": "host-1", "type": "host", "$pool": "pool-1", "enabled": true, "memory": map[string]interface{}{"size": 64 * gib}},
+		{"id": "host-2", "type": "host", "$pool": "pool-1", "enabled": true, "memory": map[string]interface{}{"size": 64 * gib}},
+		{"id": "host-3", "type": "host", "$pool": "pool-1", "enabled": true, "memory": map[string]interface{}{"size": 32 * gib}},
+		{"id": "pbd-2", "type": "PBD", "host": "host-2", "SR": "sr-local-1", "attached": true},
+		{"id": "pbd-3", "type": "PBD", "host": "host-3", "SR": "sr-shared", "attached": true},
+		{"id": "vm-a", "type": "VM", "$container": "host-1", "power_state": "Running", "memory": map[string]interface{}{"static": []int{0, 8 * gib}}},
+		{"id": "vbd-a", "type": "VBD", "VM": "vm-a", "VDI": "vdi-a", "is_cd_drive": false},
+		{"id": "vdi-a", "type": "VDI", "name_label": "root", "$SR": "sr-shared"},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, objects...), nil
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	if err := c.EnterMaintenanceMode(context.Background(), "host-1"); err != nil {
+		t.Fatalf("failed to enter maintenance mode with error: %v", err)
+	}
+	// host-2 has more free memory but can't reach the SR of the disk
+	if migrations := rpc.callsTo("vm.migrate"); len(migrations) != 1 || migrations[0].params["targetHost"] != "host-3" {
+		t.Errorf("expected vm-a to be migrated to host-3 but received: %v", migrations)
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// How long SetMetadataOptions.Verify waits for XO to report the change.
+var metadataVerifyTimeout = 2 * time.Minute
+
+type SetMetadataOptions struct {
+	// Wait for XO to report the new value before returning. XO updates
+	// its objects asynchronously so they can still have the old value
+	// right after the call otherwise.
+	Verify bool
+}
+
+// The methods below change the name or description of an object with a
+// single *.set call. Unlike the Update methods, they never need the
+// object to be halted.
+
+func (c *Client) RenameVm(id, nameLabel string, opts SetMetadataOptions) error {
+	return c.setMetadata("vm.set", id, map[string]string{"name_label": nameLabel}, opts)
+}
+
+func (c *Client) SetVmDescription(id, description string, opts SetMetadataOptions) error {
+	return c.setMetadata("vm.set", id, map[string]string{"name_description": description}, opts)
+}
+
+func (c *Client) RenameHost(id, nameLabel string, opts SetMetadataOptions) error {
+	return c.setMetadata("host.set", id, map[string]string{"name_label": nameLabel}, opts)
+}
+
+func (c *Client) SetHostDescription(id, description string, opts SetMetadataOptions) error {
+	return c.setMetadata("host.set", id, map[string]string{"name_description": description}, opts)
+}
+
+func (c *Client) RenameSr(id, nameLabel string, opts SetMetadataOptions) error {
+	return c.setMetadata("sr.set", id, map[string]string{"name_label": nameLabel}, opts)
+}
+
+func (c *Client) SetSrDescription(id, description string, opts SetMetadataOptions) error {
+	return c.setMetadata("sr.set", id, map[string]string{"name_description": description}, opts)
+}
+
+func (c *Client) RenameNetwork(id, nameLabel string, opts SetMetadataOptions) error {
+	return c.setMetadata("network.set", id, map[string]string{"name_label": nameLabel}, opts)
+}
+
+func (c *Client) SetNetworkDescription(id, description string, opts SetMetadataOptions) error {
+	return c.setMetadata("network.set", id, map[string]string{"name_description": description}, opts)
+}
+
+func (c *Client) RenameVdi(id, nameLabel string, opts SetMetadataOptions) error {
+	return c.setMetadata("vdi.set", id, map[string]string{"name_label": nameLabel}, opts)
+}
+
+func (c *Client) SetVdiDescription(id, description string, opts SetMetadataOptions) error {
+	return c.setMetadata("vdi.set", id, map[string]string{"name_description": description}, opts)
+}
+
+// setMetadata sets the string fields of an object with method.
+func (c *Client) setMetadata(method, id string, fields map[string]string, opts SetMetadataOptions) error {
+	params := map[string]interface{}{
+		"id": id,
+	}
+	for field, value := range fields {
+		params[field] = value
+	}
+
+	var success bool
+	err := c.Call(method, params, &success)
+	if err != nil {
+		return err
+	}
+
+	if !opts.Verify {
+		return nil
+	}
+	return c.waitForMetadata(id, fields)
+}
+
+// waitForMetadata waits for XO to report the values of the fields of an
+// object.
+func (c *Client) waitForMetadata(id string, fields map[string]string) error {
+	refreshFn := func() (result interface{}, state string, err error) {
+		var objsRes map[string]map[string]interface{}
+		params := map[string]interface{}{
+			"filter": map[string]string{
+				"id": id,
+			},
+		}
+		err = c.Call("xo.getAllObjects", params, &objsRes)
+		if err != nil {
+			return nil, "", err
+		}
+
+		obj, ok := objsRes[id]
+		if !ok {
+			return nil, "", NotFound{Query: Object{Id: id}}
+		}
+		for field, value := range fields {
+			if obj[field] != value {
+				return obj, "pending", nil
+			}
+		}
+		return obj, "applied", nil
+	}
+	waiter := stateWait{
+		Pending: []string{"pending"},
+		Refresh: refreshFn,
+		Target:  []string{"applied"},
+		Timeout: metadataVerifyTimeout,
+	}
+	_, err := waiter.wait(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to verify the update of object `%s`: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+// fakeMetadataRPC serves the object and applies the *.set calls to it.
+func fakeMetadataRPC(obj map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, obj), nil
+		}
+		for k, v := range params {
+			obj[k] = v
+		}
+		return true, nil
+	}}
+}
+
+func TestSetMetadata(t *testing.T) {
+	obj := map[string]interface{}{"id": "sr-1", "type": "SR", "name_label": "old", "name_description": ""}
+	rpc := fakeMetadataRPC(obj)
+	c := &Client{rpc: rpc}
+
+	if err := c.RenameSr("sr-1", "nfs", SetMetadataOptions{}); err != nil {
+		t.Fatalf("failed to rename SR with error: %v", err)
+	}
+	if err := c.SetSrDescription("sr-1", "backups", SetMetadataOptions{Verify: true}); err != nil {
+		t.Fatalf("failed to set SR description with error: %v", err)
+	}
+
+	expected := []string{"sr.set", "sr.set", "xo.getAllObjects"}
+	if !reflect.DeepEqual(rpc.methods(), expected) {
+		t.Errorf("expected calls %v but received %v", expected, rpc.methods())
+	}
+	calls := rpc.callsTo("sr.set")
+	if !reflect.DeepEqual(calls[0].params, map[string]interface{}{"id": "sr-1", "name_label": "nfs"}) {
+		t.Errorf("expected only the name to be set but received: %v", calls[0].params)
+	}
+	if !reflect.DeepEqual(calls[1].params, map[string]interface{}{"id": "sr-1", "name_description": "backups"}) {
+		t.Errorf("expected only the description to be set but received: %v", calls[1].params)
+	}
+}
+
+func TestUpdateVm_renameRunningVm(t *testing.T) {
+	rpc := fakeMetadataRPC(map[string]interface{}{
+		"id":          testUuid,
+		"type":        "VM",
+		"name_label":  "old",
+		"power_state": "Running",
+		"CPUs":        map[string]interface{}{"number": 1},
+		"memory":      map[string]interface{}{"static": []int64{0, minVmMemory}},
+	})
+	c := &Client{rpc: rpc}
+
+	vm, err := c.UpdateVm(Vm{Id: testUuid, NameLabel: "new", CPUs: CPUs{Number: 1}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}})
+	if err != nil {
+		t.Fatalf("failed to rename VM with error: %v", err)
+	}
+	if vm.NameLabel != "new" {
+		t.Errorf("expected the renamed VM to be returned but received: %+v", vm)
+	}
+
+	for _, method := range rpc.methods() {
+		if method != "vm.set" && method != "xo.getAllObjects" {
+			t.Errorf("expected no other call than vm.set but received: %v", rpc.methods())
+		}
+	}
+	calls := rpc.callsTo("vm.set")
+	if len(calls) != 1 || !reflect.DeepEqual(calls[0].params, map[string]interface{}{"id": testUuid, "name_label": "new"}) {
+		t.Errorf("expected a single vm.set call setting the name but received: %v", calls)
+	}
+}
+
+func TestVmMetadataChanges(t *testing.T) {
+	actual := Vm{NameLabel: "old", CPUs: CPUs{Number: 1}, Tags: []string{"a"}}
+
+	desired := actual
+	desired.NameLabel = "new"
+	desired.NameDescription = "web server"
+	desired.Tags = []string{"b"}
+	expected := map[string]string{"name_label": "new", "name_description": "web server"}
+	if fields := vmMetadataChanges(desired, actual); !reflect.DeepEqual(fields, expected) {
+		t.Errorf("expected %v to be changed but received %v", expected, fields)
+	}
+
+	desired.CPUs.Number = 2
+	if fields := vmMetadataChanges(desired, actual); fields != nil {
+		t.Errorf("expected the full update to be needed when the CPUs change but received %v", fields)
+	}
+
+	desired.CPUs.Number = actual.CPUs.Number
+	desired.SecureBoot = true
+	if fields := vmMetadataChanges(desired, actual); fields != nil {
+		t.Errorf("expected the full update to be needed when secure boot changes but received %v", fields)
+	}
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"strings"
+)
+
+// Methods which only read state but whose name doesn't follow the
+// get* naming convention.
+var readOnlyMethods = []string{
+	"acl.getCurrentPermissions",
+	"session.getUser",
+	"system.getMethodsInfo",
+	"xo.getAllObjects",
+}
+
+// isReadOnlyMethod reports whether calling method leaves the XO and XAPI
+// state untouched, in which case it is safe to retry or to send again to
+// another XO server.
+func isReadOnlyMethod(method string) bool {
+	if stringInSlice(method, readOnlyMethods) {
+		return true
+	}
+
+	i := strings.LastIndex(method, ".")
+	name := method[i+1:]
+	return strings.HasPrefix(name, "get") || strings.HasPrefix(name, "list")
+}
+
+// Namespaces of the XO api whose methods are restricted to admins.
+var adminNamespaces = []string{
+	"acl",
+	"backupNg",
+	"group",
+	"job",
+	"plugin",
+	"remote",
+	"resourceSet",
+	"schedule",
+	"server",
+	"user",
+}
+
+// Methods of the admin namespaces which every user can call.
+var nonAdminMethods = []string{
+	"acl.getCurrentPermissions",
+	"resourceSet.getAll",
+}
+
+// isAdminMethod reports whether XO only lets admins call method.
+func isAdminMethod(method string) bool {
+	if stringInSlice(method, nonAdminMethods) {
+		return false
+	}
+
+	i := strings.LastIndex(method, ".")
+	if i < 0 {
+		return false
+	}
+	return stringInSlice(method[:i], adminNamespaces)
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HostCpuDetails is XAPI's cpu_info of a host. Feature sets are masks of
+// 32 bits words formatted in hexadecimal and separated by dashes.
+type HostCpuDetails struct {
+	Vendor      string `json:"vendor"`
+	ModelName   string `json:"modelname"`
+	Family      string `json:"family"`
+	Model       string `json:"model"`
+	Features    string `json:"features"`
+	FeaturesPv  string `json:"features_pv"`
+	FeaturesHvm string `json:"features_hvm"`
+}
+
+// featuresFor returns the feature set available to a guest of the given
+// virtualization mode, falling back to the host's feature set.
+func (d HostCpuDetails) featuresFor(virtualizationMode string) string {
+	switch {
+	case virtualizationMode == "hvm" && d.FeaturesHvm != "":
+		return d.FeaturesHvm
+	case virtualizationMode == "pv" && d.FeaturesPv != "":
+		return d.FeaturesPv
+	}
+	return d.Features
+}
+
+// Deprecated: use CompatibilityReport.
+type CompatReport = CompatibilityReport
+
+type CompatibilityReport struct {
+	VmId         string
+	SourceHostId string
+	TargetHostId string
+	Compatible   bool
+
+	SourceVendor   string
+	TargetVendor   string
+	VendorMismatch bool
+
+	// Features the VM may use on its current host but that the target
+	// host lacks, formatted as `<word>:<bit>`
+	MissingFeatures []string
+	// When features are missing, the VM's features must be masked with
+	// RequiredMask, the features common to both hosts, before it can be
+	// migrated. This requires the VM to be rebooted.
+	MaskingNeeded bool
+	RequiredMask  string
+
+	// SRs of the VM's disks and networks of its VIFs the target host
+	// can't reach, see CanHostFitVm
+	MissingResources []string
+}
+
+// MigrationIncompatibleError is returned by MigrateVmWithOptions when the
+// compatibility check finds the target host unfit for the VM.
+type MigrationIncompatibleError struct {
+	Report *CompatibilityReport
+}
+
+func (e MigrationIncompatibleError) Error() string {
+	problems := []string{}
+	if e.Report.VendorMismatch {
+		problems = append(problems, fmt.Sprintf("CPU vendor `%s` differs from `%s`", e.Report.TargetVendor, e.Report.SourceVendor))
+	}
+	if e.Report.MaskingNeeded {
+		problems = append(problems, fmt.Sprintf("CPU features %s are missing", strings.Join(e.Report.MissingFeatures, ", ")))
+	}
+	problems = append(problems, e.Report.MissingResources...)
+	return fmt.Sprintf("cannot migrate vm `%s` to host `%s`: %s", e.Report.VmId, e.Report.TargetHostId, strings.Join(problems, "; "))
+}
+
+// CheckMigrationCompatibility compares the CPU of the host a VM runs on
+// with the one of the target host and checks the target host reaches the
+// SRs and networks of the VM, without migrating the VM.
+func (c *Client) CheckMigrationCompatibility(vmId, targetHostId string) (*CompatibilityReport, error) {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return nil, err
+	}
+
+	if vm.Host == "" || vm.Host == vm.PoolId {
+		return nil, errors.New(fmt.Sprintf("vm `%s` is not running on a host", vmId))
+	}
+
+	source, err := c.GetHostById(vm.Host)
+	if err != nil {
+		return nil, err
+	}
+	target, err := c.GetHostWithInventory(targetHostId)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompatibilityReport{
+		VmId:         vmId,
+		SourceHostId: source.Id,
+		TargetHostId: target.Id,
+		SourceVendor: source.CpuDetails.Vendor,
+		TargetVendor: target.CpuDetails.Vendor,
+	}
+	report.VendorMismatch = report.SourceVendor != report.TargetVendor
+
+	sourceFeatures, err := parseCpuFeatures(source.CpuDetails.featuresFor(vm.VirtualizationMode))
+	if err != nil {
+		return nil, err
+	}
+	targetFeatures, err := parseCpuFeatures(target.CpuDetails.featuresFor(vm.VirtualizationMode))
+	if err != nil {
+		return nil, err
+	}
+
+	mask := make([]uint32, len(sourceFeatures))
+	for i, word := range sourceFeatures {
+		var available uint32
+		if i < len(targetFeatures) {
+			available = targetFeatures[i]
+		}
+		mask[i] = word & available
+
+		missing := word &^ available
+		for bit := 0; bit < 32; bit++ {
+			if missing&(1<<uint(bit)) != 0 {
+				report.MissingFeatures = append(report.MissingFeatures, fmt.Sprintf("%d:%d", i, bit))
+			}
+		}
+	}
+
+	report.MaskingNeeded = len(report.MissingFeatures) > 0
+	if report.MaskingNeeded {
+		report.RequiredMask = formatCpuFeatures(mask)
+	}
+
+	if err := c.loadVmResources(vm); err != nil {
+		return nil, err
+	}
+	report.MissingResources = hostMissingResources(*target, *vm)
+
+	report.Compatible = !report.VendorMismatch && !report.MaskingNeeded && len(report.MissingResources) == 0
+	return report, nil
+}
+
+func parseCpuFeatures(features string) ([]uint32, error) {
+	words := []uint32{}
+	if features == "" {
+		return words, nil
+	}
+
+	for _, s := range strings.Split(features, "-") {
+		word, err := strconv.ParseUint(s, 16, 32)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("failed to parse CPU features `%s`: %v", features, err))
+		}
+		words = append(words, uint32(word))
+	}
+	return words, nil
+}
+
+func formatCpuFeatures(words []uint32) string {
+	s := []string{}
+	for _, word := range words {
+		s = append(s, fmt.Sprintf("%08x", word))
+	}
+	return strings.Join(s, "-")
+}
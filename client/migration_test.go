@@ -0,0 +1,158 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckMigrationCompatibility_reportsMissingFeatures(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "vm-1", "type": "VM", "$container": "host-new", "virtualizationMode": "hvm"},
+			map[string]interface{}{
+				"id":    "host-new",
+				"$pool": "pool-1",
+				"type":  "host",
+				"cpus":  map[string]interface{}{"cores": 8, "sockets": 1},
+				"CPUs":  map[string]interface{}{"vendor": "GenuineIntel", "features": "ffffffff-ffffffff", "features_hvm": "1fcbfbff-f7fa3223"},
+			},
+			map[string]interface{}{
+				"id":    "host-old",
+				"$pool": "pool-1",
+				"type":  "host",
+				"CPUs":  map[string]interface{}{"vendor": "GenuineIntel", "features": "ffffffff-ffffffff", "features_hvm": "1fcbfbff-f7fa3203"},
+			},
+		), nil
+	}}
+	c := Client{rpc: rpc}
+
+	report, err := c.CheckMigrationCompatibility("vm-1", "host-old")
+	if err != nil {
+		t.Fatalf("failed to check migration compatibility with error: %v", err)
+	}
+
+	if report.Compatible || report.VendorMismatch {
+		t.Errorf("expected the hosts to be incompatible because of missing features only but received %+v", report)
+	}
+	if !reflect.DeepEqual(report.MissingFeatures, []string{"1:5"}) {
+		t.Errorf("expected feature 1:5 to be missing but received %v", report.MissingFeatures)
+	}
+	if !report.MaskingNeeded || report.RequiredMask != "1fcbfbff-f7fa3203" {
+		t.Errorf("expected masking with 1fcbfbff-f7fa3203 to be needed but received %+v", report)
+	}
+	if len(rpc.callsTo("vm.migrate")) != 0 {
+		t.Errorf("expected the vm not to be migrated")
+	}
+}
+
+// The VM runs on host-intel-new with a disk on sr-1 and a VIF on
+// network-1.
+func fakeMigrationRPC(objects ...map[string]interface{}) *fakeRPC {
+	objects = append(objects,
+		map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "web", "power_state": "Running", "$poolId": "pool-1", "$container": "host-intel-new", "virtualizationMode": "hvm"},
+		map[string]interface{}{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "vdi-1", "is_cd_drive": false},
+		map[string]interface{}{"id": "vdi-1", "type": "VDI", "name_label": "web disk", "$SR": "sr-1"},
+		map[string]interface{}{"id": "vif-1", "type": "VIF", "$VM": "vm-1", "$network": "network-1", "MAC": "02:16:3e:00:00:01"},
+		map[string]interface{}{"id": "host-intel-new", "type": "host", "$pool": "pool-1", "CPUs": map[string]interface{}{"vendor": "GenuineIntel", "features_hvm": "1fcbfbff-f7fa3223"}},
+		map[string]interface{}{"id": "host-intel-same", "type": "host", "$pool": "pool-1", "CPUs": map[string]interface{}{"vendor": "GenuineIntel", "features_hvm": "1fcbfbff-f7fa3223"}},
+		map[string]interface{}{"id": "host-intel-old", "type": "host", "$pool": "pool-1", "CPUs": map[string]interface{}{"vendor": "GenuineIntel", "features_hvm": "1fcbfbff-f7fa3203"}},
+		map[string]interface{}{"id": "host-amd", "type": "host", "$pool": "pool-1", "CPUs": map[string]interface{}{"vendor": "AuthenticAMD", "features_hvm": "1fcbfbff-f7fa3223"}},
+		map[string]interface{}{"id": "pbd-1", "type": "PBD", "host": "host-intel-same", "SR": "sr-1", "attached": true},
+		map[string]interface{}{"id": "pbd-2", "type": "PBD", "host": "host-intel-old", "SR": "sr-1", "attached": true},
+		map[string]interface{}{"id": "pbd-3", "type": "PBD", "host": "host-amd", "SR": "sr-1", "attached": true},
+		map[string]interface{}{"id": "pif-1", "type": "PIF", "$host": "host-intel-same", "$network": "network-1", "device": "eth0"},
+		map[string]interface{}{"id": "pif-2", "type": "PIF", "$host": "host-intel-old", "$network": "network-1", "device": "eth0"},
+		map[string]interface{}{"id": "pif-3", "type": "PIF", "$host": "host-amd", "$network": "network-2", "device": "eth0"},
+	)
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "resourceSet.getAll":
+			return []interface{}{}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestCheckMigrationCompatibility(t *testing.T) {
+	tests := []struct {
+		name             string
+		target           string
+		compatible       bool
+		vendorMismatch   bool
+		missingFeatures  []string
+		missingResources []string
+	}{
+		{
+			name:       "Intel to Intel",
+			target:     "host-intel-same",
+			compatible: true,
+		},
+		{
+			name:             "Intel to AMD",
+			target:           "host-amd",
+			vendorMismatch:   true,
+			missingResources: []string{"network: network `network-1` has no PIF on host `host-amd`"},
+		},
+		{
+			name:            "newer to older generation",
+			target:          "host-intel-old",
+			missingFeatures: []string{"1:5"},
+		},
+	}
+	for _, test := range tests {
+		c := Client{rpc: fakeMigrationRPC()}
+
+		report, err := c.CheckMigrationCompatibility("vm-1", test.target)
+		if err != nil {
+			t.Fatalf("%s: failed to check migration compatibility with error: %v", test.name, err)
+		}
+		if report.Compatible != test.compatible || report.VendorMismatch != test.vendorMismatch {
+			t.Errorf("%s: expected compatible %t and vendor mismatch %t but received %+v", test.name, test.compatible, test.vendorMismatch, report)
+		}
+		if !reflect.DeepEqual(report.MissingFeatures, test.missingFeatures) {
+			t.Errorf("%s: expected missing features %v but received %v", test.name, test.missingFeatures, report.MissingFeatures)
+		}
+		if len(report.MissingResources) != len(test.missingResources) || (len(test.missingResources) > 0 && !reflect.DeepEqual(report.MissingResources, test.missingResources)) {
+			t.Errorf("%s: expected missing resources %v but received %v", test.name, test.missingResources, report.MissingResources)
+		}
+	}
+}
+
+func TestMigrateVmWithOptions_failsFastWhenIncompatible(t *testing.T) {
+	rpc := fakeMigrationRPC()
+	c := Client{rpc: rpc}
+
+	err := c.MigrateVmWithOptions("vm-1", "host-amd", MigrateVmOptions{CheckCompatibility: true})
+	var incompatible MigrationIncompatibleError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("expected a MigrationIncompatibleError but received: %v", err)
+	}
+	if incompatible.Report.TargetHostId != "host-amd" || !incompatible.Report.VendorMismatch {
+		t.Errorf("expected the report to be embedded in the error but received %+v", incompatible.Report)
+	}
+	if len(rpc.callsTo("vm.migrate")) != 0 {
+		t.Errorf("expected the vm not to be migrated")
+	}
+
+	if err := c.MigrateVmWithOptions("vm-1", "host-intel-same", MigrateVmOptions{CheckCompatibility: true}); err != nil {
+		t.Fatalf("failed to migrate vm with error: %v", err)
+	}
+	if calls := rpc.callsTo("vm.migrate"); len(calls) != 1 || calls[0].params["targetHost"] != "host-intel-same" {
+		t.Errorf("expected the vm to be migrated to host-intel-same but received: %v", calls)
+	}
+}
+
+func TestCheckMigrationCompatibility_haltedVm(t *testing.T) {
+	c := Client{rpc: fakeMigrationRPC(
+		map[string]interface{}{"id": "vm-2", "type": "VM", "name_label": "halted", "power_state": "Halted", "$poolId": "pool-1", "$container": "pool-1", "virtualizationMode": "hvm"},
+	)}
+
+	_, err := c.CheckMigrationCompatibility("vm-2", "host-intel-same")
+	if err == nil || !strings.Contains(err.Error(), "is not running on a host") {
+		t.Errorf("expected a halted vm to be rejected as not running on a host but received: %v", err)
+	}
+}
@@ -0,0 +1,302 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/vatesfr/xo-sdk-go/client/wait"
+)
+
+const defaultHealthCheckInterval = 30 * time.Second
+
+type FailoverPolicy struct {
+	// How often the primary endpoint is checked once the client failed
+	// over to a secondary one. Defaults to 30 seconds, negative values
+	// disable fail-back.
+	HealthCheckInterval time.Duration
+}
+
+// MultiClient sends calls to the first endpoint of its configs and fails
+// over to the next ones when the connection to the current endpoint is
+// lost. It is meant for active/passive XO appliances managing the same
+// pools.
+//
+// Only connection errors trigger a failover, errors returned by XO are
+// passed along as is. A call that failed because of a connection error is
+// only sent again to the next endpoint when it is read only since the
+// first endpoint may have applied it before the connection dropped.
+type MultiClient struct {
+	*Client
+	failover *failoverRPC
+	cancel   context.CancelFunc
+}
+
+func NewMultiClient(configs []Config, policy FailoverPolicy) (*MultiClient, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("at least one XO endpoint must be configured")
+	}
+
+	for _, config := range configs {
+		if err := checkTransport(config); err != nil {
+			return nil, err
+		}
+	}
+
+	n := newNotifier()
+	rpc := &failoverRPC{
+		configs: configs,
+		conns:   make([]jsonrpc2.JSONRPC2, len(configs)),
+		dial: func(config Config) (jsonrpc2.JSONRPC2, error) {
+			return dialTransport(config, n, newHttpClient(config))
+		},
+	}
+	if err := rpc.connectAny(); err != nil {
+		return nil, err
+	}
+
+	return newMultiClient(rpc, n, policy), nil
+}
+
+func newMultiClient(rpc *failoverRPC, n *notifier, policy FailoverPolicy) *MultiClient {
+	rpc.httpClients = make([]*http.Client, len(rpc.configs))
+	for i, config := range rpc.configs {
+		rpc.httpClients[i] = newHttpClient(config)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &MultiClient{
+		Client: &Client{
+			rpc:            rpc,
+			url:            rpc.configs[0].Url,
+			endpoint:       rpc.currentEndpoint,
+			notifier:       n,
+			skipValidation: rpc.configs[0].SkipValidation,
+			requireAdmin:   rpc.configs[0].RequireAdmin,
+			timeout:        rpc.configs[0].Timeout,
+			maxRetries:     rpc.configs[0].MaxRetries,
+			retryJitter:    rpc.configs[0].RetryJitter,
+			logger:         rpc.configs[0].Logger,
+			changes:        newChangeRecording(rpc.configs[0].ChangeRecorder),
+			interceptors:   rpc.configs[0].CallInterceptors,
+		},
+		failover: rpc,
+		cancel:   cancel,
+	}
+
+	interval := policy.HealthCheckInterval
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	if interval > 0 {
+		go rpc.failBackLoop(ctx, interval, rpc.configs[0].RetryJitter)
+	}
+	return c
+}
+
+// CurrentEndpoint returns the url of the XO endpoint calls are sent to.
+func (c *MultiClient) CurrentEndpoint() string {
+	return c.failover.currentConfig().Url
+}
+
+// Close stops the health checks, signs out and closes every connection.
+func (c *MultiClient) Close() error {
+	c.cancel()
+	return c.Client.Close()
+}
+
+// failoverRPC implements jsonrpc2.JSONRPC2 on top of a connection to each
+// of the configured endpoints. Connections are opened lazily.
+type failoverRPC struct {
+	configs     []Config
+	httpClients []*http.Client
+	dial        func(config Config) (jsonrpc2.JSONRPC2, error)
+
+	mu      sync.Mutex
+	current int
+	conns   []jsonrpc2.JSONRPC2
+}
+
+func (f *failoverRPC) currentConfig() Config {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.configs[f.current]
+}
+
+// currentEndpoint returns the url and the http client of the current
+// endpoint, file transfers must go to the endpoint which answered the
+// call that started them.
+func (f *failoverRPC) currentEndpoint() (string, *http.Client) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.configs[f.current].Url, f.httpClients[f.current]
+}
+
+// conn returns the connection to the endpoint i, dialing it if needed.
+func (f *failoverRPC) conn(i int) (jsonrpc2.JSONRPC2, error) {
+	f.mu.Lock()
+	conn := f.conns[i]
+	f.mu.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	conn, err := f.dial(f.configs[i])
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns[i] != nil {
+		// Another call connected in the meantime
+		conn.Close()
+		return f.conns[i], nil
+	}
+	f.conns[i] = conn
+	return conn, nil
+}
+
+// markDown drops the connection to endpoint i and moves on to the next
+// endpoint if i is the current one.
+func (f *failoverRPC) markDown(i int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.conns[i] != nil {
+		f.conns[i].Close()
+		f.conns[i] = nil
+	}
+	if f.current == i {
+		f.current = (i + 1) % len(f.configs)
+		log.Printf("[WARN] Failing over from XO endpoint `%s` to `%s` after error: %v\n", f.configs[i].Url, f.configs[f.current].Url, err)
+	}
+}
+
+// connectAny makes sure the current endpoint, or the first one after it
+// that can be reached, is connected.
+func (f *failoverRPC) connectAny() error {
+	var err error
+	for range f.configs {
+		f.mu.Lock()
+		i := f.current
+		f.mu.Unlock()
+
+		if _, err = f.conn(i); err == nil {
+			return nil
+		}
+		f.markDown(i, err)
+	}
+	return fmt.Errorf("failed to connect to any XO endpoint: %w", err)
+}
+
+func (f *failoverRPC) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	var err error
+	for range f.configs {
+		f.mu.Lock()
+		i := f.current
+		f.mu.Unlock()
+
+		var conn jsonrpc2.JSONRPC2
+		conn, err = f.conn(i)
+		if err != nil {
+			// Nothing was sent so any call can go to the next endpoint
+			f.markDown(i, err)
+			continue
+		}
+
+		err = conn.Call(ctx, method, params, result, opt...)
+		if !isConnectionError(ctx, err) {
+			return err
+		}
+
+		f.markDown(i, err)
+		if !isReadOnlyMethod(method) {
+			return err
+		}
+	}
+	return err
+}
+
+func (f *failoverRPC) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	f.mu.Lock()
+	i := f.current
+	f.mu.Unlock()
+
+	conn, err := f.conn(i)
+	if err != nil {
+		return err
+	}
+	return conn.Notify(ctx, method, params, opt...)
+}
+
+func (f *failoverRPC) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var err error
+	for i, conn := range f.conns {
+		if conn == nil {
+			continue
+		}
+		if closeErr := conn.Close(); closeErr != nil {
+			err = closeErr
+		}
+		f.conns[i] = nil
+	}
+	return err
+}
+
+// checkPrimary switches back to the primary endpoint once it can be
+// connected to again.
+func (f *failoverRPC) checkPrimary() {
+	f.mu.Lock()
+	current := f.current
+	f.mu.Unlock()
+	if current == 0 {
+		return
+	}
+
+	if _, err := f.conn(0); err != nil {
+		log.Printf("[DEBUG] Primary XO endpoint `%s` is still unavailable: %v\n", f.configs[0].Url, err)
+		return
+	}
+
+	f.mu.Lock()
+	f.current = 0
+	f.mu.Unlock()
+	log.Printf("[INFO] Failing back to primary XO endpoint `%s`\n", f.configs[0].Url)
+}
+
+// failBackLoop checks the primary endpoint every interval, or after a
+// random delay of up to interval with jitter so that the clients which
+// failed over at the same time don't all reconnect at once.
+func (f *failoverRPC) failBackLoop(ctx context.Context, interval time.Duration, jitter bool) {
+	backoff := wait.Backoff{Initial: interval, Factor: 1, FullJitter: jitter}
+	for {
+		timer := time.NewTimer(backoff.Next())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			f.checkPrimary()
+		}
+	}
+}
+
+// isConnectionError reports whether err was caused by the connection to
+// XO rather than returned by XO itself.
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var rpcErr *jsonrpc2.Error
+	return !errors.As(err, &rpcErr)
+}
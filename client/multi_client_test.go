@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+type fakeEndpoint struct {
+	rpc  *fakeRPC
+	down bool
+}
+
+func newFakeEndpoints(urls ...string) (map[string]*fakeEndpoint, *failoverRPC) {
+	endpoints := map[string]*fakeEndpoint{}
+	configs := []Config{}
+	for _, url := range urls {
+		url := url
+		endpoint := &fakeEndpoint{}
+		endpoint.rpc = &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+			if endpoint.down {
+				return nil, jsonrpc2.ErrClosed
+			}
+			if method == "vm.delete" {
+				return nil, &jsonrpc2.Error{Code: 1, Message: "invalid parameters"}
+			}
+			return url, nil
+		}}
+		endpoints[url] = endpoint
+		configs = append(configs, Config{Url: url})
+	}
+
+	rpc := &failoverRPC{
+		configs: configs,
+		conns:   make([]jsonrpc2.JSONRPC2, len(configs)),
+		dial: func(config Config) (jsonrpc2.JSONRPC2, error) {
+			endpoint := endpoints[config.Url]
+			if endpoint.down {
+				return nil, errors.New("connection refused")
+			}
+			return endpoint.rpc, nil
+		},
+	}
+	return endpoints, rpc
+}
+
+func TestMultiClient_primaryDiesMidSequence(t *testing.T) {
+	endpoints, rpc := newFakeEndpoints("ws://primary", "ws://secondary")
+	c := newMultiClient(rpc, newNotifier(), FailoverPolicy{HealthCheckInterval: -1})
+
+	var served string
+	if err := c.Call("vm.getCloudInitConfig", map[string]interface{}{}, &served); err != nil || served != "ws://primary" {
+		t.Fatalf("expected the primary to serve the first call but received %s with error: %v", served, err)
+	}
+
+	endpoints["ws://primary"].down = true
+
+	// Mutations must not be sent again to another endpoint
+	var success bool
+	if err := c.Call("vm.start", map[string]interface{}{"id": "vm-1"}, &success); !errors.Is(err, jsonrpc2.ErrClosed) {
+		t.Fatalf("expected the connection error to be returned for a mutation but received: %v", err)
+	}
+	if len(endpoints["ws://secondary"].rpc.callsTo("vm.start")) != 0 {
+		t.Errorf("expected vm.start not to be retried against the secondary endpoint")
+	}
+	if current := c.CurrentEndpoint(); current != "ws://secondary" {
+		t.Errorf("expected to fail over to the secondary endpoint but current endpoint is %s", current)
+	}
+
+	// Application errors don't trigger a failover
+	var reply []interface{}
+	if err := c.Call("vm.delete", map[string]interface{}{"id": "vm-1"}, &reply); err == nil {
+		t.Errorf("expected the application error to be returned")
+	}
+	if current := c.CurrentEndpoint(); current != "ws://secondary" {
+		t.Errorf("expected an application error to keep the current endpoint but it is %s", current)
+	}
+
+	// Primary is still down so the health check keeps the secondary
+	rpc.checkPrimary()
+	if current := c.CurrentEndpoint(); current != "ws://secondary" {
+		t.Errorf("expected to stay on the secondary endpoint but current endpoint is %s", current)
+	}
+
+	endpoints["ws://primary"].down = false
+	rpc.checkPrimary()
+	if current := c.CurrentEndpoint(); current != "ws://primary" {
+		t.Errorf("expected to fail back to the primary endpoint but current endpoint is %s", current)
+	}
+}
+
+func TestMultiClient_readsAreRetriedOnSecondary(t *testing.T) {
+	endpoints, rpc := newFakeEndpoints("ws://primary", "ws://secondary")
+	c := newMultiClient(rpc, newNotifier(), FailoverPolicy{HealthCheckInterval: -1})
+
+	var served string
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, &served); err != nil {
+		t.Fatalf("failed to call primary with error: %v", err)
+	}
+
+	endpoints["ws://primary"].down = true
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, &served); err != nil {
+		t.Fatalf("expected read to be retried against the secondary but received error: %v", err)
+	}
+	if served != "ws://secondary" {
+		t.Errorf("expected the secondary to serve the read but it was served by %s", served)
+	}
+}
+
+func TestIsReadOnlyMethod(t *testing.T) {
+	tests := map[string]bool{
+		"xo.getAllObjects":   true,
+		"user.getAll":        true,
+		"session.getUser":    true,
+		"vm.start":           false,
+		"vm.set":             false,
+		"session.signOut":    false,
+		"host.getServerTime": true,
+	}
+
+	for method, expected := range tests {
+		if isReadOnlyMethod(method) != expected {
+			t.Errorf("expected isReadOnlyMethod(%s) to be %t", method, expected)
+		}
+	}
+}
+
+func TestMultiClient_transfersFollowFailover(t *testing.T) {
+	urls := []string{}
+	for _, name := range []string{"primary", "secondary"} {
+		name := name
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}))
+		defer server.Close()
+		urls = append(urls, strings.Replace(server.URL, "http", "ws", 1))
+	}
+	endpoints, rpc := newFakeEndpoints(urls...)
+	c := newMultiClient(rpc, newNotifier(), FailoverPolicy{HealthCheckInterval: -1})
+
+	served := func() string {
+		r, err := c.download(context.Background(), "/api/download/vm")
+		if err != nil {
+			t.Fatalf("failed to download with error: %v", err)
+		}
+		defer r.Close()
+		b, _ := ioutil.ReadAll(r)
+		return string(b)
+	}
+	if name := served(); name != "primary" {
+		t.Errorf("expected the primary to serve the download but it was served by %s", name)
+	}
+
+	endpoints[urls[0]].down = true
+	var reply string
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, &reply); err != nil {
+		t.Fatalf("expected read to be retried against the secondary but received error: %v", err)
+	}
+	if name := served(); name != "secondary" {
+		t.Errorf("expected the secondary to serve the download after the failover but it was served by %s", name)
+	}
+}
+
+func TestNewMultiClient_rejectsUnknownTransport(t *testing.T) {
+	configs := []Config{{Url: "ws://primary"}, {Url: "ws://secondary", Transport: "grpc"}}
+	if _, err := NewMultiClient(configs, FailoverPolicy{}); err == nil || !strings.Contains(err.Error(), "unknown transport `grpc`") {
+		t.Errorf("expected the unknown transport of the secondary to be rejected but received: %v", err)
+	}
+}
+
+func TestNewMultiClient_honorsTransport(t *testing.T) {
+	server := restFixtureServer(t)
+	defer server.Close()
+
+	config := Config{Url: strings.Replace(server.URL, "http", "ws", 1), Username: "admin", Password: "secret", Transport: TransportRest}
+	c, err := NewMultiClient([]Config{config}, FailoverPolicy{HealthCheckInterval: -1})
+	if err != nil {
+		t.Fatalf("expected the endpoint to be reached over the REST api but received: %v", err)
+	}
+	defer c.cancel()
+
+	if _, ok := c.failover.conns[0].(*restRPC); !ok {
+		t.Errorf("expected the endpoint to be dialed with the REST transport but received %T", c.failover.conns[0])
+	}
+	testListingContract(t, c.Client)
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var multipathSettleTimeout = 5 * time.Minute
+
+// MultipathPaths is the number of paths to a LUN of the SR, XAPI reports
+// them in the PBD's other_config as `mpath-<SCSI id>: [active, total]`.
+type MultipathPaths struct {
+	ScsiId string
+	Active int
+	Total  int
+}
+
+// MultipathStatus is the multipathing status of an SR on one host.
+type MultipathStatus struct {
+	HostId string
+	PBDId  string
+	SrId   string
+	// Whether the host is connected to the SR
+	Attached bool
+	// Whether multipathing is enabled on the host
+	Enabled bool
+	// Whether the host currently accesses the SR through multipathing
+	Multipathed bool
+	Paths       []MultipathPaths
+}
+
+// settled reports whether the SR is accessed the way the host is
+// configured, through every path when multipathing is enabled.
+func (s MultipathStatus) settled(enabled bool) bool {
+	if !s.Attached || s.Enabled != enabled || s.Multipathed != enabled {
+		return false
+	}
+	for _, p := range s.Paths {
+		if enabled && p.Active < p.Total {
+			return false
+		}
+	}
+	return true
+}
+
+func multipathStatus(pbd PBD, host Host) MultipathStatus {
+	status := MultipathStatus{
+		HostId:      pbd.Host,
+		PBDId:       pbd.Id,
+		SrId:        pbd.SR,
+		Attached:    pbd.Attached,
+		Enabled:     host.Multipathing,
+		Multipathed: pbd.OtherConfig["multipathed"] == "true",
+		Paths:       []MultipathPaths{},
+	}
+	for key, value := range pbd.OtherConfig {
+		if !strings.HasPrefix(key, "mpath-") {
+			continue
+		}
+		counts := strings.Split(strings.Trim(value, "[] "), ",")
+		if len(counts) != 2 {
+			continue
+		}
+		active, err := strconv.Atoi(strings.TrimSpace(counts[0]))
+		if err != nil {
+			continue
+		}
+		total, err := strconv.Atoi(strings.TrimSpace(counts[1]))
+		if err != nil {
+			continue
+		}
+		status.Paths = append(status.Paths, MultipathPaths{
+			ScsiId: strings.TrimPrefix(key, "mpath-"),
+			Active: active,
+			Total:  total,
+		})
+	}
+	sort.Slice(status.Paths, func(i, j int) bool {
+		return status.Paths[i].ScsiId < status.Paths[j].ScsiId
+	})
+	return status
+}
+
+// GetSrMultipathStatus returns the multipathing status of the SR on every
+// host connected to it, sorted by host.
+func (c *Client) GetSrMultipathStatus(srId string) ([]MultipathStatus, error) {
+	pbds := map[string]PBD{}
+	err := c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type": "PBD",
+			"SR":   srId,
+		},
+	}, &pbds)
+	if err != nil {
+		return nil, err
+	}
+	if len(pbds) == 0 {
+		return nil, errors.New(fmt.Sprintf("SR `%s` is not connected to any host", srId))
+	}
+
+	hosts := map[string]Host{}
+	if err := c.getAllObjectsOfXoType("host", &hosts); err != nil {
+		return nil, err
+	}
+
+	statuses := []MultipathStatus{}
+	for _, pbd := range pbds {
+		statuses = append(statuses, multipathStatus(pbd, hosts[pbd.Host]))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].HostId < statuses[j].HostId
+	})
+	return statuses, nil
+}
+
+// SetMultipathing enables or disables multipathing on every host connected
+// to the SR. The SR is reconnected on each host so the change is applied,
+// which XAPI refuses while VMs use the SR, and SetMultipathing returns
+// once every host accesses the SR through all of its paths.
+func (c *Client) SetMultipathing(srId string, enabled bool) error {
+	statuses, err := c.GetSrMultipathStatus(srId)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		var success bool
+		params := map[string]interface{}{
+			"id": status.PBDId,
+		}
+		if status.Attached {
+			err := c.Call("pbd.disconnect", params, &success)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := c.Call("host.set", map[string]interface{}{
+			"id":           status.HostId,
+			"multipathing": enabled,
+		}, &success)
+		if err != nil {
+			// Leave the SR connected to the host with its previous setting
+			if status.Attached {
+				if connectErr := c.Call("pbd.connect", params, &success); connectErr != nil {
+					log.Printf("[WARN] Failed to reconnect PBD `%s` of SR `%s` on host `%s`: %v\n", status.PBDId, srId, status.HostId, connectErr)
+				}
+			}
+			return err
+		}
+
+		err = c.Call("pbd.connect", params, &success)
+		if err != nil {
+			return err
+		}
+	}
+
+	refreshFn := func() (result interface{}, state string, err error) {
+		statuses, err := c.GetSrMultipathStatus(srId)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, status := range statuses {
+			if !status.settled(enabled) {
+				return statuses, "Settling", nil
+			}
+		}
+		return statuses, "Settled", nil
+	}
+	waiter := stateWait{
+		Pending: []string{"Settling"},
+		Refresh: refreshFn,
+		Target:  []string{"Settled"},
+		Timeout: multipathSettleTimeout,
+	}
+	result, err := waiter.wait(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// Paths are only reported once multipathing is enabled
+	if enabled && !hasMultiplePaths(result.([]MultipathStatus)) {
+		log.Printf("[WARN] Enabled multipathing for SR `%s` which only has a single path to its storage\n", srId)
+	}
+	return nil
+}
+
+func hasMultiplePaths(statuses []MultipathStatus) bool {
+	for _, status := range statuses {
+		for _, p := range status.Paths {
+			if p.Total > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
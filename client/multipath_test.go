@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeMultipathRPC simulates hosts connected to an iSCSI SR through
+// `paths` paths. PBDs report the multipathing status of their host once
+// reconnected.
+func fakeMultipathRPC(paths string, hostIds ...string) *fakeRPC {
+	hosts := map[string]map[string]interface{}{}
+	pbds := map[string]map[string]interface{}{}
+	for _, id := range hostIds {
+		hosts[id] = map[string]interface{}{"id": id, "type": "host", "multipathing": false}
+		pbds["pbd-"+id] = map[string]interface{}{"id": "pbd-" + id, "type": "PBD", "host": id, "SR": "sr-iscsi", "attached": true, "otherConfig": map[string]string{}}
+	}
+
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			objects := []map[string]interface{}{}
+			for _, id := range hostIds {
+				objects = append(objects, hosts[id], pbds["pbd-"+id])
+			}
+			return fakeGetAllObjects(params, objects...), nil
+		case "host.set":
+			hosts[params["id"].(string)]["multipathing"] = params["multipathing"]
+		case "pbd.disconnect":
+			pbds[params["id"].(string)]["attached"] = false
+		case "pbd.connect":
+			pbd := pbds[params["id"].(string)]
+			pbd["attached"] = true
+			if hosts[pbd["host"].(string)]["multipathing"] == true {
+				pbd["otherConfig"] = map[string]string{"multipathed": "true", "mpath-36001405a1b2c3d4": paths}
+			} else {
+				pbd["otherConfig"] = map[string]string{}
+			}
+		}
+		return true, nil
+	}}
+}
+
+func TestMultipathStatus(t *testing.T) {
+	pbd := PBD{
+		Id:       "pbd-1",
+		Host:     "host-1",
+		SR:       "sr-iscsi",
+		Attached: true,
+		OtherConfig: map[string]string{
+			"multipathed":            "true",
+			"mpath-36001405a1b2c3d4": "[1, 2]",
+			"mpath-36001405e5f6a7b8": "[2, 2]",
+			"iscsi_sessions":         "2",
+		},
+	}
+
+	status := multipathStatus(pbd, Host{Id: "host-1", Multipathing: true})
+	expected := []MultipathPaths{
+		{ScsiId: "36001405a1b2c3d4", Active: 1, Total: 2},
+		{ScsiId: "36001405e5f6a7b8", Active: 2, Total: 2},
+	}
+	if !status.Enabled || !status.Multipathed || !reflect.DeepEqual(status.Paths, expected) {
+		t.Errorf("expected the multipath status to be decoded but received: %+v", status)
+	}
+	if status.settled(true) {
+		t.Errorf("expected the status not to be settled while a path is down")
+	}
+}
+
+func TestSetMultipathing(t *testing.T) {
+	rpc := fakeMultipathRPC("[2, 2]", "host-1", "host-2")
+	c := &Client{rpc: rpc}
+
+	if err := c.SetMultipathing("sr-iscsi", true); err != nil {
+		t.Fatalf("failed to enable multipathing with error: %v", err)
+	}
+
+	calls := rpc.callsTo("host.set")
+	if len(calls) != 2 {
+		t.Fatalf("expected multipathing to be set on both hosts but received: %v", calls)
+	}
+	for i, id := range []string{"host-1", "host-2"} {
+		expected := map[string]interface{}{"id": id, "multipathing": true}
+		if !reflect.DeepEqual(calls[i].params, expected) {
+			t.Errorf("expected host.set to be called with %v but received: %v", expected, calls[i].params)
+		}
+	}
+	if len(rpc.callsTo("pbd.disconnect")) != 2 || len(rpc.callsTo("pbd.connect")) != 2 {
+		t.Errorf("expected the SR to be reconnected on both hosts but received calls: %v", rpc.methods())
+	}
+
+	statuses, err := c.GetSrMultipathStatus("sr-iscsi")
+	if err != nil || len(statuses) != 2 || !statuses[0].Multipathed || statuses[1].Paths[0].Total != 2 {
+		t.Errorf("expected the SR to be multipathed on both hosts but received %+v with error: %v", statuses, err)
+	}
+
+	if err := c.SetMultipathing("sr-iscsi", false); err != nil {
+		t.Fatalf("failed to disable multipathing with error: %v", err)
+	}
+	calls = rpc.callsTo("host.set")
+	if len(calls) != 4 || calls[3].params["multipathing"] != false {
+		t.Errorf("expected multipathing to be disabled but received: %v", calls)
+	}
+}
+
+func TestSetMultipathing_reconnectsWhenHostSetFails(t *testing.T) {
+	rpc := fakeMultipathRPC("[2, 2]", "host-1", "host-2")
+	handler := rpc.handler
+	rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "host.set" && params["id"] == "host-2" {
+			return nil, errors.New("HOST_OFFLINE")
+		}
+		return handler(method, params)
+	}
+	c := &Client{rpc: rpc}
+
+	if err := c.SetMultipathing("sr-iscsi", true); err == nil || !strings.Contains(err.Error(), "HOST_OFFLINE") {
+		t.Fatalf("expected the failure of host.set to be returned but received: %v", err)
+	}
+	connected := []interface{}{}
+	for _, call := range rpc.callsTo("pbd.connect") {
+		connected = append(connected, call.params["id"])
+	}
+	if !reflect.DeepEqual(connected, []interface{}{"pbd-host-1", "pbd-host-2"}) {
+		t.Errorf("expected the SR to be reconnected on both hosts but received: %v", connected)
+	}
+}
+
+func TestSetMultipathing_warnsOnSinglePath(t *testing.T) {
+	c := &Client{rpc: fakeMultipathRPC("[1, 1]", "host-1")}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	if err := c.SetMultipathing("sr-iscsi", true); err != nil {
+		t.Fatalf("failed to enable multipathing with error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "[WARN] Enabled multipathing for SR `sr-iscsi` which only has a single path") {
+		t.Errorf("expected a warning for the single path SR but received logs: %s", logs.String())
+	}
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/json"
+)
+
+// The methods below resolve the objects an object refers to through its
+// `$poolId`, `$pool`, `$container`, `$SR` or `$network` property, each with a single
+// xo.getAllObjects call scoped to the related objects.
+
+// GetPoolOfVm returns the pool the VM belongs to.
+func (c *Client) GetPoolOfVm(vm Vm) (*Pool, error) {
+	var pool Pool
+	if err := c.getObjectOfType("pool", vm.PoolId, Pool{Id: vm.PoolId}, &pool); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// GetHostOfVm returns the host the VM is resident on. XO reports the pool
+// as the container of halted VMs, which aren't resident on any host, so a
+// nil host is returned for them.
+func (c *Client) GetHostOfVm(vm Vm) (*Host, error) {
+	if vm.Host == "" || vm.Host == vm.PoolId {
+		return nil, nil
+	}
+
+	var host Host
+	if err := c.getObjectOfType("host", vm.Host, Host{Id: vm.Host}, &host); err != nil {
+		return nil, err
+	}
+	return &host, nil
+}
+
+// GetSrOfVdi returns the storage repository the VDI is stored on.
+func (c *Client) GetSrOfVdi(vdi VDI) (*StorageRepository, error) {
+	var sr StorageRepository
+	if err := c.getObjectOfType("SR", vdi.SrId, StorageRepository{Id: vdi.SrId}, &sr); err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+// GetPifsOfNetwork returns the PIFs connecting the hosts to the network,
+// one per host for a network with a physical interface and none for a
+// private network.
+func (c *Client) GetPifsOfNetwork(network Network) ([]PIF, error) {
+	var pifsRes map[string]PIF
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":     "PIF",
+			"$network": network.Id,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &pifsRes)
+	if err != nil {
+		return nil, err
+	}
+
+	pifs := []PIF{}
+	for _, id := range sortedKeys(pifsRes) {
+		pifs = append(pifs, pifsRes[id])
+	}
+	return pifs, nil
+}
+
+// GetPoolHosts returns the hosts of the pool sorted by id, none when there
+// is no such pool.
+func (c *Client) GetPoolHosts(poolId string) ([]Host, error) {
+	var hostsRes map[string]Host
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":  "host",
+			"$pool": poolId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &hostsRes)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []Host{}
+	for _, id := range sortedKeys(hostsRes) {
+		hosts = append(hosts, hostsRes[id])
+	}
+	return hosts, nil
+}
+
+// GetHostPool returns the pool the host belongs to.
+func (c *Client) GetHostPool(hostId string) (*Pool, error) {
+	var host Host
+	if err := c.getObjectOfType("host", hostId, Host{Id: hostId}, &host); err != nil {
+		return nil, err
+	}
+
+	var pool Pool
+	if err := c.getObjectOfType("pool", host.Pool, Pool{Id: host.Pool}, &pool); err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// getObjectOfType decodes the object of type xoType with the given id into
+// obj, failing with a NotFound error for query when there is none.
+func (c *Client) getObjectOfType(xoType, id string, query XoObject, obj interface{}) error {
+	var objsRes map[string]json.RawMessage
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"id":   id,
+			"type": xoType,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return err
+	}
+
+	res, ok := objsRes[id]
+	if !ok {
+		return NotFound{Query: query}
+	}
+	return json.Unmarshal(res, obj)
+}
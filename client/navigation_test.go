@@ -0,0 +1,151 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+// The halted VM's container is its pool, the running one's is its host.
+var navigationObjects = []map[string]interface{}{
+	{"id": "pool-1", "type": "pool", "name_label": "pool"},
+	{"id": "host-1", "type": "host", "name_label": "host", "$pool": "pool-1"},
+	{"id": "host-2", "type": "host", "name_label": "host", "$pool": "pool-1"},
+	{"id": "host-3", "type": "host", "name_label": "orphan", "$pool": "pool-2"},
+	{"id": "pool-3", "type": "pool", "name_label": "empty"},
+	{"id": "vm-halted", "type": "VM", "power_state": "Halted", "$poolId": "pool-1", "$container": "pool-1"},
+	{"id": "vm-running", "type": "VM", "power_state": "Running", "$poolId": "pool-1", "$container": "host-1"},
+	{"id": "sr-1", "type": "SR", "name_label": "local storage", "$poolId": "pool-1", "$container": "host-1"},
+	{"id": "vdi-1", "type": "VDI", "name_label": "disk", "$SR": "sr-1", "$poolId": "pool-1"},
+	{"id": "network-1", "type": "network", "name_label": "eth0", "$poolId": "pool-1"},
+	{"id": "network-2", "type": "network", "name_label": "private", "$poolId": "pool-1"},
+	{"id": "pif-2", "type": "PIF", "device": "eth0", "$network": "network-1", "$host": "host-2"},
+	{"id": "pif-1", "type": "PIF", "device": "eth0", "$network": "network-1", "$host": "host-1"},
+	{"id": "pif-3", "type": "PIF", "device": "eth1", "$network": "network-3", "$host": "host-1"},
+}
+
+func navigationClient() (*Client, *fakeRPC) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, navigationObjects...), nil
+	}}
+	return &Client{rpc: rpc}, rpc
+}
+
+func TestGetPoolOfVm(t *testing.T) {
+	c, rpc := navigationClient()
+
+	pool, err := c.GetPoolOfVm(Vm{Id: "vm-halted", PoolId: "pool-1", Host: "pool-1"})
+	if err != nil {
+		t.Fatalf("failed to get the pool of the vm with error: %v", err)
+	}
+	if pool.Id != "pool-1" || pool.NameLabel != "pool" {
+		t.Errorf("expected pool-1 to be returned but received: %+v", pool)
+	}
+	if calls := len(rpc.calls); calls != 1 {
+		t.Errorf("expected a single lookup but received %d calls", calls)
+	}
+}
+
+func TestGetPoolOfVm_notFound(t *testing.T) {
+	c, _ := navigationClient()
+
+	_, err := c.GetPoolOfVm(Vm{Id: "vm-1", PoolId: "pool-2"})
+	var notFound NotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected a NotFound error but received: %v", err)
+	}
+}
+
+func TestGetHostOfVm_running(t *testing.T) {
+	c, rpc := navigationClient()
+
+	host, err := c.GetHostOfVm(Vm{Id: "vm-running", PoolId: "pool-1", Host: "host-1"})
+	if err != nil {
+		t.Fatalf("failed to get the host of the vm with error: %v", err)
+	}
+	if host == nil || host.Id != "host-1" || host.Pool != "pool-1" {
+		t.Errorf("expected host-1 to be returned but received: %+v", host)
+	}
+	if calls := len(rpc.calls); calls != 1 {
+		t.Errorf("expected a single lookup but received %d calls", calls)
+	}
+}
+
+func TestGetHostOfVm_haltedVmContainedByPool(t *testing.T) {
+	c, rpc := navigationClient()
+
+	host, err := c.GetHostOfVm(Vm{Id: "vm-halted", PoolId: "pool-1", Host: "pool-1"})
+	if err != nil {
+		t.Fatalf("expected no error for a halted vm but received: %v", err)
+	}
+	if host != nil {
+		t.Errorf("expected a nil host for a halted vm but received: %+v", host)
+	}
+	if calls := len(rpc.calls); calls != 0 {
+		t.Errorf("expected no lookup for a halted vm but received %d calls", calls)
+	}
+}
+
+func TestGetSrOfVdi(t *testing.T) {
+	c, _ := navigationClient()
+
+	sr, err := c.GetSrOfVdi(VDI{VDIId: "vdi-1", SrId: "sr-1"})
+	if err != nil {
+		t.Fatalf("failed to get the sr of the vdi with error: %v", err)
+	}
+	if sr.Id != "sr-1" || sr.Container != "host-1" {
+		t.Errorf("expected sr-1 to be returned but received: %+v", sr)
+	}
+}
+
+func TestGetPifsOfNetwork(t *testing.T) {
+	c, _ := navigationClient()
+
+	pifs, err := c.GetPifsOfNetwork(Network{Id: "network-1"})
+	if err != nil {
+		t.Fatalf("failed to get the pifs of the network with error: %v", err)
+	}
+	if len(pifs) != 2 || pifs[0].Id != "pif-1" || pifs[1].Id != "pif-2" {
+		t.Errorf("expected pif-1 and pif-2 to be returned but received: %+v", pifs)
+	}
+
+	pifs, err = c.GetPifsOfNetwork(Network{Id: "network-2"})
+	if err != nil || len(pifs) != 0 {
+		t.Errorf("expected no pif for a private network but received: %+v, %v", pifs, err)
+	}
+}
+
+func TestGetPoolHosts(t *testing.T) {
+	c, _ := navigationClient()
+
+	hosts, err := c.GetPoolHosts("pool-1")
+	if err != nil {
+		t.Fatalf("failed to get the hosts of the pool with error: %v", err)
+	}
+	if len(hosts) != 2 || hosts[0].Id != "host-1" || hosts[1].Id != "host-2" {
+		t.Errorf("expected host-1 and host-2 to be returned but received: %+v", hosts)
+	}
+
+	hosts, err = c.GetPoolHosts("pool-3")
+	if err != nil || hosts == nil || len(hosts) != 0 {
+		t.Errorf("expected an empty slice for a pool without hosts but received: %#v, %v", hosts, err)
+	}
+}
+
+func TestGetHostPool(t *testing.T) {
+	c, _ := navigationClient()
+
+	pool, err := c.GetHostPool("host-2")
+	if err != nil {
+		t.Fatalf("failed to get the pool of the host with error: %v", err)
+	}
+	if pool.Id != "pool-1" || pool.NameLabel != "pool" {
+		t.Errorf("expected pool-1 to be returned but received: %+v", pool)
+	}
+
+	for _, id := range []string{"host-3", "host-4"} {
+		var notFound NotFound
+		if _, err := c.GetHostPool(id); !errors.As(err, &notFound) {
+			t.Errorf("expected a NotFound error for `%s` but received: %v", id, err)
+		}
+	}
+}
@@ -9,10 +9,22 @@ import (
 )
 
 type Network struct {
-	Id        string `json:"id"`
-	NameLabel string `json:"name_label"`
-	Bridge    string `json:"bridge"`
-	PoolId    string `json:"$poolId"`
+	Id              string `json:"id"`
+	XapiRef         string `json:"_xapiRef,omitempty"`
+	NameLabel       string `json:"name_label"`
+	NameDescription string `json:"name_description"`
+	Bridge          string `json:"bridge"`
+	PoolId          string `json:"$poolId"`
+	// Whether XO may export disks over NBD through the network
+	Nbd bool `json:"nbd"`
+
+	// Only used by CreateNetwork to create a VLAN network on the PIF
+	PIFId string `json:"-"`
+	Vlan  int    `json:"-"`
+
+	// Bond backing the network on each host, empty for networks that
+	// aren't bonded. Only filled by GetNetworkWithBonds.
+	Bonds []Bond `json:"-"`
 }
 
 func (net Network) Compare(obj interface{}) bool {
@@ -36,11 +48,22 @@ func (net Network) Compare(obj interface{}) bool {
 }
 
 func (c *Client) CreateNetwork(netReq Network) (*Network, error) {
+	if err := c.validateCreateNetwork(netReq); err != nil {
+		return nil, err
+	}
+
 	var id string
 	params := map[string]interface{}{
 		"pool": netReq.PoolId,
 		"name": netReq.NameLabel,
 	}
+	if netReq.NameDescription != "" {
+		params["description"] = netReq.NameDescription
+	}
+	if netReq.PIFId != "" {
+		params["pif"] = netReq.PIFId
+		params["vlan"] = netReq.Vlan
+	}
 
 	err := c.Call("network.create", params, &id)
 
@@ -77,15 +100,212 @@ func (c *Client) GetNetworks() ([]Network, error) {
 	return nets, err
 }
 
+// Deprecated: use DeleteNetworkWithOptions.
 func (c *Client) DeleteNetwork(id string) error {
+	return c.DeleteNetworkWithOptions(id, DeleteNetworkOptions{})
+}
+
+type DeleteNetworkOptions struct {
+	// Look for the VIFs and VLAN PIFs using the network before deleting it
+	// rather than after XO refused to delete it
+	Preflight bool
+	// Delete the VIFs and VLAN PIFs using the network before deleting it.
+	// The VIFs of VMs that aren't halted are only unplugged and deleted
+	// with Force.
+	Cascade bool
+	Force   bool
+}
+
+// NetworkVifUsage is a VIF using a network.
+type NetworkVifUsage struct {
+	VifId        string
+	VmId         string
+	VmNameLabel  string
+	VmPowerState PowerState
+}
+
+// NetworkInUseError is returned when a network can't be deleted because
+// VIFs or VLAN PIFs still use it. Err is the error XO returned, if any.
+type NetworkInUseError struct {
+	NetworkId string
+	Vifs      []NetworkVifUsage
+	// PIFs of the VLAN networks built on the PIFs of the network. The own
+	// PIFs of a VLAN network are deleted along with it and aren't listed.
+	VlanPifs []string
+	Err      error
+}
+
+func (e NetworkInUseError) Error() string {
+	users := []string{}
+	if len(e.Vifs) > 0 {
+		vms := []string{}
+		for _, vif := range e.Vifs {
+			vms = append(vms, fmt.Sprintf("`%s` (%s, %s)", vif.VmNameLabel, vif.VmId, vif.VmPowerState))
+		}
+		users = append(users, fmt.Sprintf("the VIFs of VMs %s", strings.Join(vms, ", ")))
+	}
+	if len(e.VlanPifs) > 0 {
+		users = append(users, fmt.Sprintf("VLAN PIFs %s", strings.Join(e.VlanPifs, ", ")))
+	}
+
+	msg := fmt.Sprintf("network `%s` is still used by %s", e.NetworkId, strings.Join(users, " and "))
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e NetworkInUseError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteNetworkWithOptions deletes the network. When VIFs or VLAN PIFs
+// still use it a NetworkInUseError listing them is returned, unless
+// opts.Cascade deletes them first.
+func (c *Client) DeleteNetworkWithOptions(id string, opts DeleteNetworkOptions) error {
+	_, err := c.deleteNetwork(id, opts)
+	return err
+}
+
+// DeleteNetworkWithResult deletes the network like DeleteNetworkWithOptions
+// and reports the VIFs and VLAN PIFs deleted along with it. A network that
+// doesn't exist is reported as already absent.
+func (c *Client) DeleteNetworkWithResult(id string, opts DeleteNetworkOptions) (*DeleteResult, error) {
+	return absentIfNoSuchObject(c.deleteNetwork(id, opts))
+}
+
+func (c *Client) deleteNetwork(id string, opts DeleteNetworkOptions) (*DeleteResult, error) {
+	res := &DeleteResult{DeletedObject: DeletedObject{Type: "network", Id: id}}
+	if opts.Preflight || opts.Cascade {
+		inUse, err := c.getNetworkUsage(id)
+		if err != nil {
+			return res, err
+		}
+
+		if len(inUse.Vifs) > 0 || len(inUse.VlanPifs) > 0 {
+			if !opts.Cascade {
+				return res, *inUse
+			}
+			if err := c.deleteNetworkUsers(*inUse, opts.Force); err != nil {
+				return res, err
+			}
+			for _, vif := range inUse.Vifs {
+				res.Cascaded = append(res.Cascaded, DeletedObject{Type: "VIF", Id: vif.VifId})
+			}
+			for _, pifId := range inUse.VlanPifs {
+				res.Cascaded = append(res.Cascaded, DeletedObject{Type: "PIF", Id: pifId})
+			}
+		}
+	}
+
 	var success bool
 	params := map[string]interface{}{
 		"id": id,
 	}
-
 	err := c.Call("network.delete", params, &success)
+	if err == nil || !strings.Contains(err.Error(), "NETWORK_CONTAINS_") {
+		return res, err
+	}
 
-	return err
+	inUse, lookupErr := c.getNetworkUsage(id)
+	if lookupErr != nil {
+		log.Printf("[WARN] Failed to look for the users of network `%s`: %v\n", id, lookupErr)
+		return res, err
+	}
+	inUse.Err = err
+	return res, *inUse
+}
+
+// getNetworkUsage returns the VIFs using the network and the PIFs of the
+// VLAN networks built on it.
+func (c *Client) getNetworkUsage(id string) (*NetworkInUseError, error) {
+	inUse := &NetworkInUseError{NetworkId: id}
+
+	var vifs map[string]VIF
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":     "VIF",
+			"$network": id,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &vifs)
+	if err != nil {
+		return nil, err
+	}
+	if len(vifs) > 0 {
+		var vms map[string]Vm
+		if err := c.getAllObjectsOfXoType("VM", &vms); err != nil {
+			return nil, err
+		}
+		for _, vifId := range sortedKeys(vifs) {
+			vm := vms[vifs[vifId].VmId]
+			inUse.Vifs = append(inUse.Vifs, NetworkVifUsage{
+				VifId:        vifId,
+				VmId:         vifs[vifId].VmId,
+				VmNameLabel:  vm.NameLabel,
+				VmPowerState: vm.PowerState,
+			})
+		}
+	}
+
+	var pifs map[string]PIF
+	if err := c.getAllObjectsOfXoType("PIF", &pifs); err != nil {
+		return nil, err
+	}
+	// A VLAN PIF shares the device of the PIF it tags on its host
+	tagged := map[string]bool{}
+	for _, pif := range pifs {
+		if pif.Network == id && pif.Vlan == 0 {
+			tagged[pif.Host+"/"+pif.Device] = true
+		}
+	}
+	for _, pifId := range sortedKeys(pifs) {
+		pif := pifs[pifId]
+		if pif.Network != id && pif.Vlan > 0 && tagged[pif.Host+"/"+pif.Device] {
+			inUse.VlanPifs = append(inUse.VlanPifs, pifId)
+		}
+	}
+	return inUse, nil
+}
+
+// deleteNetworkUsers deletes the VIFs and VLAN PIFs of inUse. Nothing is
+// deleted when a VIF belongs to a VM that isn't halted and force is unset.
+func (c *Client) deleteNetworkUsers(inUse NetworkInUseError, force bool) error {
+	if !force {
+		running := []string{}
+		for _, vif := range inUse.Vifs {
+			if vif.VmPowerState != PowerStateHalted {
+				running = append(running, vif.VmId)
+			}
+		}
+		if len(running) > 0 {
+			inUse.Err = errors.New(fmt.Sprintf("refusing to unplug the VIFs of VMs %v which aren't halted without forcing it", running))
+			return inUse
+		}
+	}
+
+	for _, vif := range inUse.Vifs {
+		var err error
+		if vif.VmPowerState == PowerStateHalted {
+			var success bool
+			err = c.Call("vif.delete", map[string]interface{}{"id": vif.VifId}, &success)
+		} else {
+			log.Printf("[DEBUG] Unplugging VIF `%s` of vm `%s` to delete network `%s`\n", vif.VifId, vif.VmId, inUse.NetworkId)
+			err = c.DeleteVIF(&VIF{Id: vif.VifId})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, pifId := range inUse.VlanPifs {
+		var success bool
+		err := c.Call("pif.delete", map[string]interface{}{"id": pifId}, &success)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func RemoveNetworksWithNamePrefix(prefix string) func(string) error {
@@ -1,6 +1,11 @@
 package client
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 var testNetworkName string = integrationTestPrefix + "network"
 
@@ -92,3 +97,156 @@ func TestGetNetwork(t *testing.T) {
 		t.Errorf("expected network pool id to not be an empty string")
 	}
 }
+
+// fakeNetworkInUseRPC refuses to delete a network while VIFs or the VLAN
+// PIFs of other networks still use it, the way XAPI does. VLAN 20 of
+// network-2 is built on the PIF of network-1 on host-1, network-3 is a
+// VLAN network on host-2.
+func fakeNetworkInUseRPC(objects ...map[string]interface{}) *fakeRPC {
+	objects = append(objects,
+		map[string]interface{}{"id": "vm-halted", "type": "VM", "name_label": "db", "power_state": "Halted"},
+		map[string]interface{}{"id": "vm-running", "type": "VM", "name_label": "web", "power_state": "Running"},
+		map[string]interface{}{"id": "pif-eth0", "type": "PIF", "vlan": 0, "device": "eth0", "$host": "host-1", "$network": "network-1"},
+		map[string]interface{}{"id": "pif-vlan", "type": "PIF", "vlan": 20, "device": "eth0", "$host": "host-1", "$network": "network-2"},
+		map[string]interface{}{"id": "pif-vlan-host-2", "type": "PIF", "vlan": 30, "device": "eth0", "$host": "host-2", "$network": "network-3"},
+	)
+	inUse := func(id interface{}) error {
+		for _, obj := range objects {
+			if obj["type"] == "VIF" && obj["$network"] == id {
+				return errors.New("NETWORK_CONTAINS_VIF")
+			}
+		}
+		for _, pif := range objects {
+			if pif["type"] != "PIF" || pif["$network"] != id || pif["vlan"] != 0 {
+				continue
+			}
+			for _, vlan := range objects {
+				if vlan["type"] == "PIF" && vlan["$network"] != id && vlan["vlan"] != 0 && vlan["$host"] == pif["$host"] && vlan["device"] == pif["device"] {
+					return errors.New("NETWORK_CONTAINS_PIF")
+				}
+			}
+		}
+		return nil
+	}
+	remove := func(id interface{}) {
+		for i, obj := range objects {
+			if obj["id"] == id {
+				objects = append(objects[:i], objects[i+1:]...)
+				return
+			}
+		}
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "network.delete":
+			if err := inUse(params["id"]); err != nil {
+				return nil, err
+			}
+		case "vif.delete", "pif.delete":
+			remove(params["id"])
+		}
+		return true, nil
+	}}
+}
+
+var haltedVmVif = map[string]interface{}{"id": "vif-1", "type": "VIF", "MAC": "02:16:3e:00:00:01", "$VM": "vm-halted", "$network": "network-1"}
+var runningVmVif = map[string]interface{}{"id": "vif-2", "type": "VIF", "MAC": "02:16:3e:00:00:02", "$VM": "vm-running", "$network": "network-1"}
+
+func TestDeleteNetwork_inUse(t *testing.T) {
+	rpc := fakeNetworkInUseRPC(haltedVmVif, runningVmVif)
+	c := &Client{rpc: rpc}
+
+	err := c.DeleteNetwork("network-1")
+	var inUse NetworkInUseError
+	if !errors.As(err, &inUse) {
+		t.Fatalf("expected a NetworkInUseError but received: %v", err)
+	}
+
+	expected := []NetworkVifUsage{
+		{VifId: "vif-1", VmId: "vm-halted", VmNameLabel: "db", VmPowerState: PowerStateHalted},
+		{VifId: "vif-2", VmId: "vm-running", VmNameLabel: "web", VmPowerState: PowerStateRunning},
+	}
+	if !reflect.DeepEqual(inUse.Vifs, expected) || !reflect.DeepEqual(inUse.VlanPifs, []string{"pif-vlan"}) {
+		t.Errorf("expected the VIFs and VLAN PIF using the network but received: %+v", inUse)
+	}
+	if inUse.Err == nil || !strings.Contains(err.Error(), "NETWORK_CONTAINS_") {
+		t.Errorf("expected the error of XO to be kept but received: %v", err)
+	}
+}
+
+func TestDeleteNetworkWithOptions_preflight(t *testing.T) {
+	rpc := fakeNetworkInUseRPC(haltedVmVif)
+	c := &Client{rpc: rpc}
+
+	err := c.DeleteNetworkWithOptions("network-1", DeleteNetworkOptions{Preflight: true})
+	var inUse NetworkInUseError
+	if !errors.As(err, &inUse) || len(inUse.Vifs) != 1 || inUse.Err != nil {
+		t.Errorf("expected a NetworkInUseError found before deleting but received: %v", err)
+	}
+	if deletes := rpc.callsTo("network.delete"); len(deletes) != 0 {
+		t.Errorf("expected no deletion attempt but received: %v", deletes)
+	}
+}
+
+func TestDeleteNetworkWithOptions_cascade(t *testing.T) {
+	rpc := fakeNetworkInUseRPC(haltedVmVif)
+	c := &Client{rpc: rpc}
+
+	err := c.DeleteNetworkWithOptions("network-1", DeleteNetworkOptions{Cascade: true})
+	if err != nil {
+		t.Fatalf("failed to delete the network with error: %v", err)
+	}
+
+	expected := []string{"vif.delete", "pif.delete", "network.delete"}
+	methods := []string{}
+	for _, method := range rpc.methods() {
+		if method != "xo.getAllObjects" {
+			methods = append(methods, method)
+		}
+	}
+	if !reflect.DeepEqual(methods, expected) {
+		t.Errorf("expected the VIF of the halted vm and the VLAN PIF to be deleted first but received: %v", methods)
+	}
+}
+
+func TestDeleteNetworkWithOptions_vlanNetworkOwnPifs(t *testing.T) {
+	rpc := fakeNetworkInUseRPC()
+	c := &Client{rpc: rpc}
+
+	if err := c.DeleteNetworkWithOptions("network-3", DeleteNetworkOptions{Preflight: true}); err != nil {
+		t.Fatalf("expected the own PIFs of the VLAN network not to be reported as users but received: %v", err)
+	}
+	if err := c.DeleteNetworkWithOptions("network-2", DeleteNetworkOptions{Cascade: true}); err != nil {
+		t.Fatalf("failed to delete the VLAN network with error: %v", err)
+	}
+	if deletes := rpc.callsTo("pif.delete"); len(deletes) != 0 {
+		t.Errorf("expected the own PIFs of the VLAN network to be left to network.delete but received: %v", deletes)
+	}
+}
+
+func TestDeleteNetworkWithOptions_cascadeRefusesRunningVms(t *testing.T) {
+	rpc := fakeNetworkInUseRPC(haltedVmVif, runningVmVif)
+	c := &Client{rpc: rpc}
+
+	err := c.DeleteNetworkWithOptions("network-1", DeleteNetworkOptions{Cascade: true})
+	var inUse NetworkInUseError
+	if !errors.As(err, &inUse) || !strings.Contains(err.Error(), "vm-running") {
+		t.Fatalf("expected the cascade to be refused for the running vm but received: %v", err)
+	}
+	for _, method := range rpc.methods() {
+		if method != "xo.getAllObjects" {
+			t.Errorf("expected nothing to be deleted but received: %v", rpc.methods())
+			break
+		}
+	}
+
+	err = c.DeleteNetworkWithOptions("network-1", DeleteNetworkOptions{Cascade: true, Force: true})
+	if err != nil {
+		t.Fatalf("failed to force the deletion of the network with error: %v", err)
+	}
+	if disconnects := rpc.callsTo("vif.disconnect"); len(disconnects) != 1 || disconnects[0].params["id"] != "vif-2" {
+		t.Errorf("expected only the VIF of the running vm to be unplugged but received: %v", disconnects)
+	}
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Number of notifications buffered for each RawNotifications or Subscribe
+// channel before new notifications start being dropped.
+const RawNotificationBufferSize = 256
+
+// RawNotification is a server initiated json rpc notification as it
+// was received on the connection.
+type RawNotification struct {
+	Method string
+	Params json.RawMessage
+}
+
+// Types of ObjectEvent
+const (
+	// The objects were added or updated
+	ObjectEventEnter = "enter"
+	// The objects were removed
+	ObjectEventExit = "exit"
+)
+
+// ObjectEvent is a change of XO objects, pushed by XO as an `all`
+// notification.
+type ObjectEvent struct {
+	// ObjectEventEnter or ObjectEventExit
+	Type string
+	// The changed objects as XO sent them, keyed by id
+	Objects map[string]json.RawMessage
+}
+
+type notificationListener func(req *jsonrpc2.Request)
+
+// notifier fans out the notifications received by the connection's
+// handler to every registered listener. Listeners are invoked from the
+// connection's read loop so they must never block.
+type notifier struct {
+	// dropped is accessed atomically and must stay the first field
+	// to be 64-bit aligned on 32-bit platforms.
+	dropped uint64
+
+	mu        sync.RWMutex
+	nextId    int
+	listeners map[int]notificationListener
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		listeners: map[int]notificationListener{},
+	}
+}
+
+func (n *notifier) subscribe(l notificationListener) (unsubscribe func()) {
+	n.mu.Lock()
+	id := n.nextId
+	n.nextId++
+	n.listeners[id] = l
+	n.mu.Unlock()
+
+	return func() {
+		n.mu.Lock()
+		delete(n.listeners, id)
+		n.mu.Unlock()
+	}
+}
+
+func (n *notifier) dispatch(req *jsonrpc2.Request) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, l := range n.listeners {
+		l(req)
+	}
+}
+
+// RawNotifications returns a channel receiving every notification XO pushes
+// on the connection until ctx is done, at which point the channel is closed.
+//
+// The channel is buffered with RawNotificationBufferSize entries. When a
+// consumer falls behind and the buffer is full, newly received notifications
+// are dropped rather than blocking the connection. The number of dropped
+// notifications is reported by DroppedNotifications.
+func (c *Client) RawNotifications(ctx context.Context) (<-chan RawNotification, error) {
+	if c.notifier == nil {
+		return nil, errors.New("client is not able to receive notifications")
+	}
+
+	ch := make(chan RawNotification, RawNotificationBufferSize)
+	unsubscribe := c.notifier.subscribe(func(req *jsonrpc2.Request) {
+		n := RawNotification{Method: req.Method}
+		if req.Params != nil {
+			n.Params = append(json.RawMessage(nil), (*req.Params)...)
+		}
+
+		select {
+		case ch <- n:
+		default:
+			atomic.AddUint64(&c.notifier.dropped, 1)
+			log.Printf("[WARN] Dropped `%s` notification since the raw notification channel is full\n", req.Method)
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		// Once unsubscribe returns the listener can no longer be running
+		// so it is safe to close the channel.
+		unsubscribe()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// Subscribe returns a channel receiving the changes of XO objects pushed on
+// the connection until ctx is done, at which point the channel is closed.
+// It shares the connection's notifications with RawNotifications, both can
+// be used at the same time.
+//
+// The channel is buffered and drops events like the channel of
+// RawNotifications, dropped events are counted by DroppedNotifications.
+func (c *Client) Subscribe(ctx context.Context) (<-chan ObjectEvent, error) {
+	if c.notifier == nil {
+		return nil, errors.New("client is not able to receive notifications")
+	}
+
+	ch := make(chan ObjectEvent, RawNotificationBufferSize)
+	unsubscribe := c.notifier.subscribe(func(req *jsonrpc2.Request) {
+		if req.Method != "all" || req.Params == nil {
+			return
+		}
+		var params struct {
+			Type  string                     `json:"type"`
+			Items map[string]json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(*req.Params, &params); err != nil {
+			log.Printf("[WARN] Ignoring an `all` notification which isn't an object event: %v\n", err)
+			return
+		}
+
+		select {
+		case ch <- ObjectEvent{Type: params.Type, Objects: params.Items}:
+		default:
+			atomic.AddUint64(&c.notifier.dropped, 1)
+			log.Printf("[WARN] Dropped `%s` object event since the subscription channel is full\n", params.Type)
+		}
+	})
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// DroppedNotifications returns the number of notifications that could not be
+// delivered to a RawNotifications or Subscribe channel because its buffer
+// was full.
+func (c *Client) DroppedNotifications() uint64 {
+	if c.notifier == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.notifier.dropped)
+}
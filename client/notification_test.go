@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func notification(method, params string) *jsonrpc2.Request {
+	raw := json.RawMessage(params)
+	return &jsonrpc2.Request{
+		Method: method,
+		Params: &raw,
+		Notif:  true,
+	}
+}
+
+func TestRawNotifications_fanOutToTypedAndRawConsumers(t *testing.T) {
+	n := newNotifier()
+	c := Client{notifier: n}
+	h := handler{notifier: n}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	raw, err := c.RawNotifications(ctx)
+	if err != nil {
+		t.Fatalf("failed to get raw notifications with error: %v", err)
+	}
+
+	typed, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe with error: %v", err)
+	}
+
+	h.Handle(context.Background(), nil, notification("all", `{"type":"enter","items":{"vm-1":{"id":"vm-1","type":"VM"}}}`))
+
+	select {
+	case n := <-raw:
+		if n.Method != "all" || string(n.Params) != `{"type":"enter","items":{"vm-1":{"id":"vm-1","type":"VM"}}}` {
+			t.Errorf("raw consumer received unexpected notification: %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("raw consumer did not receive the notification")
+	}
+
+	select {
+	case event := <-typed:
+		var vm Vm
+		if err := json.Unmarshal(event.Objects["vm-1"], &vm); event.Type != ObjectEventEnter || err != nil || vm.Id != "vm-1" {
+			t.Errorf("typed consumer expected vm-1 to enter but received %+v with error: %v", event, err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("typed consumer did not receive the notification")
+	}
+}
+
+func TestSubscribe_onlyReceivesObjectEvents(t *testing.T) {
+	n := newNotifier()
+	c := Client{notifier: n}
+	h := handler{notifier: n}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe with error: %v", err)
+	}
+
+	h.Handle(context.Background(), nil, notification("session.expired", `{}`))
+	h.Handle(context.Background(), nil, notification("all", `{"type":"exit","items":{"vm-1":{"id":"vm-1"}}}`))
+	cancel()
+
+	received := []ObjectEvent{}
+	for event := range events {
+		received = append(received, event)
+	}
+	if len(received) != 1 || received[0].Type != ObjectEventExit || len(received[0].Objects) != 1 {
+		t.Errorf("expected only the exit of vm-1 to be received but received %+v", received)
+	}
+}
+
+func TestRawNotifications_dropsWhenConsumerIsSlow(t *testing.T) {
+	n := newNotifier()
+	c := Client{notifier: n}
+	h := handler{notifier: n}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	raw, err := c.RawNotifications(ctx)
+	if err != nil {
+		t.Fatalf("failed to get raw notifications with error: %v", err)
+	}
+
+	extra := 10
+	for i := 0; i < RawNotificationBufferSize+extra; i++ {
+		h.Handle(context.Background(), nil, notification("all", `{}`))
+	}
+
+	if dropped := c.DroppedNotifications(); dropped != uint64(extra) {
+		t.Errorf("expected %d dropped notifications, instead found %d", extra, dropped)
+	}
+
+	cancel()
+	received := 0
+	for range raw {
+		received++
+	}
+	if received != RawNotificationBufferSize {
+		t.Errorf("expected the %d buffered notifications to be delivered, received %d", RawNotificationBufferSize, received)
+	}
+}
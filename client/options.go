@@ -0,0 +1,97 @@
+package client
+
+import (
+	"crypto/tls"
+	"log"
+	"time"
+)
+
+// Option changes the Config built by NewClientWithOptions.
+type Option func(*Config)
+
+// WithCredentials signs in with the username and password.
+func WithCredentials(username, password string) Option {
+	return func(c *Config) {
+		c.Username = username
+		c.Password = password
+	}
+}
+
+// WithToken signs in with an authentication token instead of a username
+// and password.
+func WithToken(token string) Option {
+	return func(c *Config) {
+		c.Token = token
+	}
+}
+
+// WithRetry retries read only calls up to maxRetries times when they fail
+// without an answer from XO.
+func WithRetry(maxRetries int) Option {
+	return func(c *Config) {
+		c.MaxRetries = maxRetries
+	}
+}
+
+// WithRetryJitter randomizes the delays between retries, see
+// Config.RetryJitter.
+func WithRetryJitter() Option {
+	return func(c *Config) {
+		c.RetryJitter = true
+	}
+}
+
+// WithLogger logs the messages about the client's calls to logger instead
+// of the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *Config) {
+		c.Logger = logger
+	}
+}
+
+// WithTLSConfig uses tlsConfig for the connections to XO.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = tlsConfig
+	}
+}
+
+// WithTimeout limits the duration of the connection to XO and of each api
+// call.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = timeout
+	}
+}
+
+// WithChangeRecorder records every successful call changing the state of
+// XO or XAPI with recorder.
+func WithChangeRecorder(recorder ChangeRecorder) Option {
+	return func(c *Config) {
+		c.ChangeRecorder = recorder
+	}
+}
+
+// WithCallInterceptors wraps every api call with the interceptors, after
+// those of the earlier options.
+func WithCallInterceptors(interceptors ...CallInterceptor) Option {
+	return func(c *Config) {
+		c.CallInterceptors = append(c.CallInterceptors, interceptors...)
+	}
+}
+
+// NewClientWithOptions creates a client connected to the XO server at url.
+// The options are applied in order, so a later option overrides an earlier
+// one setting the same field. The client uses the defaults of NewClient
+// for everything the options don't set.
+func NewClientWithOptions(url string, opts ...Option) (XOClient, error) {
+	return NewClient(newConfig(url, opts...))
+}
+
+func newConfig(url string, opts ...Option) Config {
+	config := Config{Url: url}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
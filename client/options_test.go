@@ -0,0 +1,212 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestNewConfig_optionsComposeAndOverride(t *testing.T) {
+	logger := log.New(&bytes.Buffer{}, "", 0)
+	tlsConfig := &tls.Config{ServerName: "xo.example.com"}
+
+	config := newConfig("wss://xo.example.com",
+		WithCredentials("admin", "secret"),
+		WithToken("token-1"),
+		WithRetry(2),
+		WithTimeout(time.Minute),
+		WithLogger(logger),
+		WithTLSConfig(tlsConfig),
+		WithRetryJitter(),
+		WithRetry(5),
+		WithToken("token-2"),
+	)
+
+	if config.Url != "wss://xo.example.com" || config.Username != "admin" || config.Password != "secret" {
+		t.Errorf("expected the url and credentials to be set but received: %+v", config)
+	}
+	if config.Token != "token-2" || config.MaxRetries != 5 || !config.RetryJitter {
+		t.Errorf("expected the later options to override the earlier ones but received: %+v", config)
+	}
+	if config.Timeout != time.Minute || config.Logger != logger || config.TLSConfig != tlsConfig {
+		t.Errorf("expected the options to compose but received: %+v", config)
+	}
+}
+
+func TestNewConfig_defaults(t *testing.T) {
+	config := newConfig("wss://xo.example.com")
+
+	if !reflect.DeepEqual(config, Config{Url: "wss://xo.example.com"}) {
+		t.Errorf("expected the zero config to be used without options but received: %+v", config)
+	}
+}
+
+func TestSignInParams(t *testing.T) {
+	method, params := signInParams(newConfig("", WithCredentials("admin", "secret"), WithToken("token-1")))
+	if method != "session.signInWithToken" || params["token"] != "token-1" {
+		t.Errorf("expected to sign in with the token but received %s: %v", method, params)
+	}
+
+	method, params = signInParams(newConfig("", WithCredentials("admin", "secret")))
+	if method != "session.signInWithPassword" || params["email"] != "admin" || params["password"] != "secret" {
+		t.Errorf("expected to sign in with the password but received %s: %v", method, params)
+	}
+}
+
+func TestNewTLSConfig_insecureSkipVerifyTakesPrecedence(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "xo.example.com"}
+
+	config := newTLSConfig(Config{TLSConfig: tlsConfig, InsecureSkipVerify: true})
+	if !config.InsecureSkipVerify || config.ServerName != "xo.example.com" {
+		t.Errorf("expected the TLS config to skip verification but received: %+v", config)
+	}
+	if tlsConfig.InsecureSkipVerify {
+		t.Errorf("expected the given TLS config to be left untouched")
+	}
+	if newTLSConfig(Config{}) != nil {
+		t.Errorf("expected the default TLS config to be used without options")
+	}
+}
+
+func TestCall_retriesReadOnlyMethodsOnTransportErrors(t *testing.T) {
+	defer func(d time.Duration) { retryInitialDelay = d }(retryInitialDelay)
+	retryInitialDelay = time.Millisecond
+
+	var buf bytes.Buffer
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("connection reset by peer")
+	}}
+	c := &Client{rpc: rpc, maxRetries: 2, logger: log.New(&buf, "", 0)}
+
+	if err := c.Call("xo.getAllObjects", map[string]interface{}{}, nil); err == nil {
+		t.Fatalf("expected the transport error to be returned")
+	}
+	if calls := len(rpc.callsTo("xo.getAllObjects")); calls != 3 {
+		t.Errorf("expected the call to be made 3 times but was made %d times", calls)
+	}
+	if !strings.Contains(buf.String(), "[WARN] Retrying rpc call `xo.getAllObjects`") {
+		t.Errorf("expected the retries to be logged to the client's logger but received: %s", buf.String())
+	}
+}
+
+func TestCall_doesNotRetryWritesOrServerErrors(t *testing.T) {
+	tests := []struct {
+		method string
+		err    error
+	}{
+		{"vm.delete", errors.New("connection reset by peer")},
+		{"xo.getAllObjects", &jsonrpc2.Error{Code: 10, Message: "invalid parameters"}},
+	}
+	for _, test := range tests {
+		rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+			return nil, test.err
+		}}
+		c := &Client{rpc: rpc, maxRetries: 2, logger: log.New(&bytes.Buffer{}, "", 0)}
+
+		c.Call(test.method, map[string]interface{}{}, nil)
+		if calls := len(rpc.calls); calls != 1 {
+			t.Errorf("expected `%s` failing with %v to be called once but was called %d times", test.method, test.err, calls)
+		}
+	}
+}
+
+func retryDelays(t *testing.T, jitter bool) []time.Duration {
+	defer func(sleep func(context.Context, time.Duration) error) { sleepRetryDelay = sleep }(sleepRetryDelay)
+	delays := []time.Duration{}
+	sleepRetryDelay = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	}}
+	c := &Client{rpc: rpc, maxRetries: 5, retryJitter: jitter, logger: log.New(&bytes.Buffer{}, "", 0)}
+	c.Call("xo.getAllObjects", map[string]interface{}{}, nil)
+	return delays
+}
+
+func TestCall_retryJitter(t *testing.T) {
+	defer func(r *rand.Rand) { retryJitterRand = r }(retryJitterRand)
+
+	caps := retryDelays(t, false)
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	if !reflect.DeepEqual(caps, expected) {
+		t.Fatalf("expected the delays without jitter to be %v but received %v", expected, caps)
+	}
+
+	retryJitterRand = rand.New(rand.NewSource(42))
+	delays := retryDelays(t, true)
+	if len(delays) != len(caps) {
+		t.Fatalf("expected %d retries but received delays %v", len(caps), delays)
+	}
+	distinct := map[time.Duration]bool{}
+	for i, d := range delays {
+		if d < 0 || d > caps[i] {
+			t.Errorf("expected retry %d to wait between 0 and %s but received %s", i, caps[i], d)
+		}
+		distinct[d] = true
+	}
+	if len(distinct) != len(delays) {
+		t.Errorf("expected the jittered delays to vary but received %v", delays)
+	}
+
+	retryJitterRand = rand.New(rand.NewSource(42))
+	if again := retryDelays(t, true); !reflect.DeepEqual(again, delays) {
+		t.Errorf("expected the same delays with the same seed but received %v and %v", delays, again)
+	}
+}
+
+func TestCall_retryStopsWithContext(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, errors.New("connection refused")
+	}}
+	c := &Client{rpc: rpc, maxRetries: 5, logger: log.New(&bytes.Buffer{}, "", 0)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.callContext(ctx, "xo.getAllObjects", map[string]interface{}{}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) >= retryInitialDelay {
+		t.Errorf("expected the retry delay to stop with the context but received after %s: %v", time.Since(start), err)
+	}
+	if calls := len(rpc.calls); calls != 1 {
+		t.Errorf("expected no retry once the context is done but received %d calls", calls)
+	}
+}
+
+type deadlineRPC struct {
+	fakeRPC
+	deadline time.Time
+	ok       bool
+}
+
+func (rpc *deadlineRPC) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	rpc.deadline, rpc.ok = ctx.Deadline()
+	return nil
+}
+
+func TestCall_timeout(t *testing.T) {
+	rpc := &deadlineRPC{}
+	c := &Client{rpc: rpc, timeout: time.Minute, logger: log.New(&bytes.Buffer{}, "", 0)}
+
+	c.Call("xo.getAllObjects", map[string]interface{}{}, nil)
+	if !rpc.ok || time.Until(rpc.deadline) > time.Minute {
+		t.Errorf("expected the call to time out within a minute but received deadline %v", rpc.deadline)
+	}
+
+	c.timeout = 0
+	c.Call("xo.getAllObjects", map[string]interface{}{}, nil)
+	if rpc.ok {
+		t.Errorf("expected the call to have no deadline without a timeout")
+	}
+}
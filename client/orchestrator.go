@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Orchestrator runs a sequence of steps changing XO objects and undoes
+// the changes of the completed steps when one of them fails, e.g. deletes
+// a created VM when its disks can't be attached.
+type Orchestrator struct {
+	c     *Client
+	steps []orchestratorStep
+}
+
+type orchestratorStep struct {
+	desc     string
+	action   func() error
+	rollback func() error
+}
+
+func NewOrchestrator(c *Client) *Orchestrator {
+	return &Orchestrator{c: c}
+}
+
+// Do registers a step. rollback undoes the changes of action and is only
+// called when action succeeded and a later step fails, it can be nil for
+// steps without changes to undo.
+func (o *Orchestrator) Do(desc string, action func() error, rollback func() error) {
+	o.steps = append(o.steps, orchestratorStep{desc: desc, action: action, rollback: rollback})
+}
+
+// RollbackError is the failure to undo the changes of a step.
+type RollbackError struct {
+	Step string
+	Err  error
+}
+
+func (e RollbackError) Error() string {
+	return fmt.Sprintf("failed to roll back `%s`: %v", e.Step, e.Err)
+}
+
+func (e RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// OrchestrationError is returned by Orchestrator.Run when a step fails or
+// the context is done before a step starts. It wraps the error of the
+// step, or the context's, while the failures to roll back the completed
+// steps are listed separately.
+type OrchestrationError struct {
+	Step           string
+	Err            error
+	RollbackErrors []RollbackError
+}
+
+func (e OrchestrationError) Error() string {
+	msg := fmt.Sprintf("failed to %s: %v", e.Step, e.Err)
+	if len(e.RollbackErrors) == 0 {
+		return msg
+	}
+
+	rollbackMsgs := []string{}
+	for _, err := range e.RollbackErrors {
+		rollbackMsgs = append(rollbackMsgs, err.Error())
+	}
+	return fmt.Sprintf("%s, then %s", msg, strings.Join(rollbackMsgs, ", "))
+}
+
+func (e OrchestrationError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the steps in the order they were registered. When a step fails
+// or ctx is done before a step starts, the completed steps are rolled back
+// in reverse order and an OrchestrationError is returned. Every rollback
+// is attempted even when one of them fails.
+func (o *Orchestrator) Run(ctx context.Context) error {
+	for i, step := range o.steps {
+		err := ctx.Err()
+		if err == nil {
+			err = step.action()
+		}
+		if err != nil {
+			return OrchestrationError{
+				Step:           step.desc,
+				Err:            err,
+				RollbackErrors: o.rollback(o.steps[:i]),
+			}
+		}
+	}
+	return nil
+}
+
+func (o *Orchestrator) rollback(completed []orchestratorStep) []RollbackError {
+	var errs []RollbackError
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+		if step.rollback == nil {
+			continue
+		}
+
+		o.c.logf("[DEBUG] Rolling back `%s`\n", step.desc)
+		if err := step.rollback(); err != nil {
+			o.c.logf("[WARN] Failed to roll back `%s`: %v\n", step.desc, err)
+			errs = append(errs, RollbackError{Step: step.desc, Err: err})
+		}
+	}
+	return errs
+}
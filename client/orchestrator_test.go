@@ -0,0 +1,203 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingSteps registers steps appending what they do to log, the
+// actions of the steps named in failing and the rollbacks of the ones
+// named in failingRollbacks fail.
+func recordingSteps(orc *Orchestrator, log *[]string, names []string, failing, failingRollbacks map[string]bool) {
+	for _, name := range names {
+		name := name
+		orc.Do(name, func() error {
+			*log = append(*log, "do "+name)
+			if failing[name] {
+				return errors.New(name + " failed")
+			}
+			return nil
+		}, func() error {
+			*log = append(*log, "undo "+name)
+			if failingRollbacks[name] {
+				return errors.New("undo " + name + " failed")
+			}
+			return nil
+		})
+	}
+}
+
+func TestOrchestrator_rollsBackCompletedStepsInReverse(t *testing.T) {
+	orc := NewOrchestrator(&Client{})
+	var log []string
+	recordingSteps(orc, &log, []string{"create vm", "attach disk", "create vif", "add tags"}, map[string]bool{"create vif": true}, nil)
+
+	err := orc.Run(context.Background())
+	var orcErr OrchestrationError
+	if !errors.As(err, &orcErr) {
+		t.Fatalf("expected an OrchestrationError but received: %v", err)
+	}
+	if orcErr.Step != "create vif" || orcErr.Err.Error() != "create vif failed" || len(orcErr.RollbackErrors) != 0 {
+		t.Errorf("expected the failure of `create vif` without rollback errors but received: %+v", orcErr)
+	}
+
+	expected := []string{"do create vm", "do attach disk", "do create vif", "undo attach disk", "undo create vm"}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("expected steps %v but received %v", expected, log)
+	}
+}
+
+func TestOrchestrator_collectsRollbackErrors(t *testing.T) {
+	orc := NewOrchestrator(&Client{})
+	var log []string
+	recordingSteps(orc, &log, []string{"create vm", "attach disk", "create vif"}, map[string]bool{"create vif": true}, map[string]bool{"create vm": true, "attach disk": true})
+	orc.Do("without rollback", func() error { return nil }, nil)
+
+	err := orc.Run(context.Background())
+	var orcErr OrchestrationError
+	if !errors.As(err, &orcErr) {
+		t.Fatalf("expected an OrchestrationError but received: %v", err)
+	}
+	if orcErr.Err.Error() != "create vif failed" {
+		t.Errorf("expected the original error to be kept but received: %v", orcErr.Err)
+	}
+
+	steps := []string{}
+	for _, rollbackErr := range orcErr.RollbackErrors {
+		steps = append(steps, rollbackErr.Step)
+	}
+	if !reflect.DeepEqual(steps, []string{"attach disk", "create vm"}) {
+		t.Errorf("expected every rollback to be attempted and its failure collected but received: %v", orcErr.RollbackErrors)
+	}
+	expectedMsg := "failed to create vif: create vif failed, then failed to roll back `attach disk`: undo attach disk failed, failed to roll back `create vm`: undo create vm failed"
+	if err.Error() != expectedMsg {
+		t.Errorf("expected error message %q but received %q", expectedMsg, err.Error())
+	}
+}
+
+func TestOrchestrator_contextCancelledBetweenSteps(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	orc := NewOrchestrator(&Client{})
+	var log []string
+	recordingSteps(orc, &log, []string{"create vm"}, nil, nil)
+	orc.Do("cancel", func() error {
+		cancel()
+		return nil
+	}, nil)
+	recordingSteps(orc, &log, []string{"attach disk"}, nil, nil)
+
+	err := orc.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the error to wrap context.Canceled but received: %v", err)
+	}
+	expected := []string{"do create vm", "undo create vm"}
+	if !reflect.DeepEqual(log, expected) {
+		t.Errorf("expected the remaining steps to be skipped and the others rolled back but received %v", log)
+	}
+}
+
+func TestOrchestrator_success(t *testing.T) {
+	orc := NewOrchestrator(&Client{})
+	var log []string
+	recordingSteps(orc, &log, []string{"create vm", "attach disk"}, nil, nil)
+
+	if err := orc.Run(context.Background()); err != nil {
+		t.Fatalf("expected the steps to succeed but received: %v", err)
+	}
+	if !reflect.DeepEqual(log, []string{"do create vm", "do attach disk"}) {
+		t.Errorf("expected no rollback but received %v", log)
+	}
+}
+
+const (
+	rollbackDataVdi = "5f0a6a41-8b3c-4c59-a4cb-1f3e5d2e9a77"
+	rollbackLogsVdi = "6a1b7b52-9c4d-4d6a-b5dc-2a4f6e3f0b88"
+)
+
+// fakeRollbackRPC creates new-vm with a root disk, a CD and the data VDI
+// attached but fails to enroll its secure boot keys.
+func fakeRollbackRPC() *fakeRPC {
+	objects := []map[string]interface{}{
+		{"id": testUuid, "type": "VM-template", "name_label": "Debian", "$poolId": "pool-1"},
+		{"id": rollbackDataVdi, "type": "VDI", "name_label": "data", "$VBDs": []string{}},
+		{"id": "vbd-root", "type": "VBD", "VM": "new-vm", "VDI": "vdi-root"},
+		{"id": "vbd-cd", "type": "VBD", "VM": "new-vm", "VDI": "vdi-iso", "is_cd_drive": true},
+		{"id": "vbd-data", "type": "VBD", "VM": "new-vm", "VDI": rollbackDataVdi},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "vm.create":
+			return "new-vm", nil
+		case "vm.setUefiMode":
+			return nil, errors.New("VM_BAD_POWER_STATE")
+		}
+		return true, nil
+	}}
+}
+
+func rollbackVmRequest() Vm {
+	vmReq := validVmRequest()
+	vmReq.Boot.Firmware = "uefi"
+	vmReq.SecureBootKeys = SecureBootKeysDefault
+	vmReq.Disks = append(vmReq.Disks, Disk{VDI: VDI{VDIId: rollbackDataVdi}})
+	return vmReq
+}
+
+func TestCreateVm_rollbackKeepsAttachedVdis(t *testing.T) {
+	rpc := fakeRollbackRPC()
+	c := &Client{rpc: rpc}
+
+	_, err := c.CreateVm(rollbackVmRequest(), time.Minute)
+	var orcErr OrchestrationError
+	if !errors.As(err, &orcErr) || len(orcErr.RollbackErrors) != 0 {
+		t.Fatalf("expected an OrchestrationError without rollback errors but received: %v", err)
+	}
+
+	vmDelete := rpc.callsTo("vm.delete")
+	if len(vmDelete) != 1 || !reflect.DeepEqual(vmDelete[0].params, map[string]interface{}{"id": "new-vm"}) {
+		t.Errorf("expected the VM to be deleted without its disks but received: %v", vmDelete)
+	}
+	if vdiDelete := rpc.callsTo("vdi.delete"); len(vdiDelete) != 1 || vdiDelete[0].params["id"] != "vdi-root" {
+		t.Errorf("expected only the disk created with the VM to be deleted but received: %v", vdiDelete)
+	}
+	if len(rpc.callsTo("vm.start")) != 0 {
+		t.Errorf("expected the VM not to be started")
+	}
+}
+
+func TestCreateVm_keepsVmWhenWaitTimesOut(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params,
+				map[string]interface{}{"id": testUuid, "type": "VM-template", "name_label": "Debian", "$poolId": "pool-1"},
+				map[string]interface{}{"id": "new-vm", "type": "VM", "name_label": "web", "power_state": "Running"},
+			), nil
+		case "vm.create":
+			return "new-vm", nil
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForIpAssigned
+	vm, err := c.CreateVm(vmReq, time.Millisecond)
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected the wait for the VM's ip to time out but received: %v", err)
+	}
+	if vm == nil || vm.Id != "new-vm" {
+		t.Errorf("expected the created VM to be returned along with the error but received: %+v", vm)
+	}
+	if vmDelete := rpc.callsTo("vm.delete"); len(vmDelete) != 0 {
+		t.Errorf("expected the VM to be kept once the wait timed out but received: %v", vmDelete)
+	}
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+// PermissionSummary describes what the session's user is allowed to do.
+type PermissionSummary struct {
+	UserId string
+	Email  string
+	// Admins are allowed to do anything, the other fields only describe
+	// the permissions of non admin users
+	Admin bool
+	// Actions (`view`, `operate` or `administrate`) the user is granted
+	// through ACLs, keyed by object id. nil when the XO server doesn't
+	// report them.
+	Permissions map[string][]string
+	// Ids of the resource sets the user or one of its groups belongs to
+	ResourceSets []string
+}
+
+// CanAdministrate reports whether the user is allowed to administrate the
+// object.
+func (s PermissionSummary) CanAdministrate(objectId string) bool {
+	return s.Admin || stringInSlice("administrate", s.Permissions[objectId])
+}
+
+// AdministrableObjects returns the ids of the objects the user is allowed
+// to administrate through ACLs, sorted.
+func (s PermissionSummary) AdministrableObjects() []string {
+	ids := []string{}
+	for id, actions := range s.Permissions {
+		if stringInSlice("administrate", actions) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// InsufficientPermissionsError is returned before calling a method
+// restricted to admins when the session's user isn't one.
+type InsufficientPermissionsError struct {
+	Email      string
+	Permission string
+	// Method that was about to be called, empty for RequireAdmin
+	Method string
+}
+
+func (e InsufficientPermissionsError) Error() string {
+	msg := fmt.Sprintf("user `%s` has the `%s` permission but admin is required", e.Email, e.Permission)
+	if e.Method != "" {
+		msg += fmt.Sprintf(" to call `%s`", e.Method)
+	}
+	return msg
+}
+
+// GetCurrentPermissions returns the permissions of the session's user.
+func (c *Client) GetCurrentPermissions() (*PermissionSummary, error) {
+	session, err := c.SessionInfo()
+	if err != nil {
+		return nil, err
+	}
+	user := session.User
+
+	summary := &PermissionSummary{
+		UserId:       user.Id,
+		Email:        user.Email,
+		Admin:        user.Permission == "admin",
+		ResourceSets: []string{},
+	}
+	if summary.Admin {
+		return summary, nil
+	}
+
+	var permissions json.RawMessage
+	err = c.Call("acl.getCurrentPermissions", map[string]interface{}{}, &permissions)
+	if err = featureDetect("acl.getCurrentPermissions", err); err != nil {
+		var unsupported UnsupportedOnThisServerError
+		if !errors.As(err, &unsupported) {
+			return nil, err
+		}
+		log.Printf("[DEBUG] Unable to list the ACL permissions of user `%s`: %v\n", user.Email, err)
+	} else {
+		summary.Permissions, err = decodeCurrentPermissions(permissions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resourceSets, err := c.GetResourceSets()
+	if err != nil {
+		return nil, err
+	}
+	for _, rs := range resourceSets {
+		for _, subject := range rs.Subjects {
+			if subject == user.Id || stringInSlice(subject, user.Groups) {
+				summary.ResourceSets = append(summary.ResourceSets, rs.Id)
+				break
+			}
+		}
+	}
+	sort.Strings(summary.ResourceSets)
+	return summary, nil
+}
+
+// decodeCurrentPermissions decodes the permissions XO reports for each
+// object as a set of actions.
+func decodeCurrentPermissions(data json.RawMessage) (map[string][]string, error) {
+	var objects map[string]map[string]bool
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, err
+	}
+
+	permissions := map[string][]string{}
+	for id, actions := range objects {
+		for action, granted := range actions {
+			if granted {
+				permissions[id] = append(permissions[id], action)
+			}
+		}
+		sort.Strings(permissions[id])
+	}
+	return permissions, nil
+}
+
+// RequireAdmin fails with an InsufficientPermissionsError unless the
+// session's user is an admin.
+func (c *Client) RequireAdmin() error {
+	return c.requireAdminFor("")
+}
+
+func (c *Client) requireAdminFor(method string) error {
+	session, err := c.SessionInfo()
+	if err != nil {
+		return err
+	}
+	if session.User.Permission != "admin" {
+		return InsufficientPermissionsError{
+			Email:      session.User.Email,
+			Permission: session.User.Permission,
+			Method:     method,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func fakePermissionsRPC(user map[string]interface{}, permissions interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "session.getUser":
+			return user, nil
+		case "acl.getCurrentPermissions":
+			return permissions, nil
+		case "resourceSet.getAll":
+			return []map[string]interface{}{
+				{"id": "rs-dev", "subjects": []string{"user-2"}},
+				{"id": "rs-qa", "subjects": []string{"group-qa"}},
+				{"id": "rs-ops", "subjects": []string{"group-ops"}},
+			}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestGetCurrentPermissions(t *testing.T) {
+	tests := []struct {
+		name            string
+		user            map[string]interface{}
+		permissions     interface{}
+		admin           bool
+		administrable   []string
+		resourceSets    []string
+		canAdministrate map[string]bool
+	}{
+		{
+			name:            "admin",
+			user:            map[string]interface{}{"id": "user-1", "email": "admin@example.org", "permission": "admin"},
+			admin:           true,
+			administrable:   []string{},
+			resourceSets:    []string{},
+			canAdministrate: map[string]bool{"pool-1": true},
+		},
+		{
+			name: "operator on a subset",
+			user: map[string]interface{}{"id": "user-2", "email": "operator@example.org", "permission": "none", "groups": []string{"group-ops"}},
+			permissions: map[string]interface{}{
+				"pool-1": map[string]bool{"view": true, "operate": true},
+				"vm-1":   map[string]bool{"view": true, "operate": true, "administrate": true},
+				"vm-2":   map[string]bool{"view": true, "operate": true, "administrate": true},
+			},
+			administrable:   []string{"vm-1", "vm-2"},
+			resourceSets:    []string{"rs-dev", "rs-ops"},
+			canAdministrate: map[string]bool{"pool-1": false, "vm-1": true},
+		},
+		{
+			name:            "self service only",
+			user:            map[string]interface{}{"id": "user-3", "email": "dev@example.org", "permission": "none", "groups": []string{"group-qa"}},
+			permissions:     map[string]interface{}{},
+			administrable:   []string{},
+			resourceSets:    []string{"rs-qa"},
+			canAdministrate: map[string]bool{"vm-1": false},
+		},
+	}
+
+	for _, test := range tests {
+		c := &Client{rpc: fakePermissionsRPC(test.user, test.permissions)}
+
+		summary, err := c.GetCurrentPermissions()
+		if err != nil {
+			t.Fatalf("%s: failed to get permissions with error: %v", test.name, err)
+		}
+		if summary.Admin != test.admin || summary.UserId != test.user["id"] {
+			t.Errorf("%s: expected admin to be %t but received: %+v", test.name, test.admin, summary)
+		}
+		if administrable := summary.AdministrableObjects(); !reflect.DeepEqual(administrable, test.administrable) {
+			t.Errorf("%s: expected %v to be administrable but received %v", test.name, test.administrable, administrable)
+		}
+		if !reflect.DeepEqual(summary.ResourceSets, test.resourceSets) {
+			t.Errorf("%s: expected resource sets %v but received %v", test.name, test.resourceSets, summary.ResourceSets)
+		}
+		for id, expected := range test.canAdministrate {
+			if summary.CanAdministrate(id) != expected {
+				t.Errorf("%s: expected CanAdministrate(%s) to be %t", test.name, id, expected)
+			}
+		}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	user := map[string]interface{}{"id": "user-2", "email": "operator@example.org", "permission": "none"}
+	rpc := fakePermissionsRPC(user, nil)
+	c := &Client{rpc: rpc}
+
+	var insufficient InsufficientPermissionsError
+	if err := c.RequireAdmin(); !errors.As(err, &insufficient) || insufficient.Email != "operator@example.org" {
+		t.Errorf("expected an InsufficientPermissionsError but received: %v", err)
+	}
+
+	// Only checked when the client is configured to
+	if err := c.DeleteUser(User{Id: "user-4"}); err != nil {
+		t.Errorf("expected user.delete to be called without checking permissions but received: %v", err)
+	}
+
+	rpc.calls = nil
+	c.requireAdmin = true
+	err := c.DeleteUser(User{Id: "user-4"})
+	if !errors.As(err, &insufficient) || insufficient.Method != "user.delete" {
+		t.Errorf("expected user.delete to fail with an InsufficientPermissionsError but received: %v", err)
+	}
+	if len(rpc.callsTo("user.delete")) != 0 {
+		t.Errorf("expected user.delete not to be called")
+	}
+	if _, err := c.GetResourceSets(); err != nil {
+		t.Errorf("expected methods open to every user to be called but received: %v", err)
+	}
+
+	user["permission"] = "admin"
+	if err := c.DeleteUser(User{Id: "user-4"}); err != nil {
+		t.Errorf("expected admins to call user.delete but received: %v", err)
+	}
+}
@@ -13,6 +13,11 @@ type PIF struct {
 	PoolId   string `json:"$poolId"`
 	Attached bool   `json:"attached"`
 	Vlan     int    `json:"vlan"`
+
+	IsBondMaster bool `json:"isBondMaster"`
+	IsBondSlave  bool `json:"isBondSlave"`
+	// Whether the link of the NIC is up
+	Carrier bool `json:"carrier"`
 }
 
 func (p PIF) Compare(obj interface{}) bool {
@@ -0,0 +1,120 @@
+package client
+
+// Plugin is an XO server plugin. Its configuration is write-only since it
+// often holds secrets, only whether it is configured is exposed.
+type Plugin struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+	// Whether the plugin is currently running
+	Loaded bool `json:"loaded"`
+	// Whether the plugin is loaded when XO starts
+	Autoload bool `json:"autoload"`
+	// Whether the plugin can be unloaded once loaded
+	Unloadable bool `json:"unloadable"`
+	Configured bool `json:"-"`
+}
+
+func (p Plugin) Compare(obj interface{}) bool {
+	other := obj.(Plugin)
+	return p.Id != "" && p.Id == other.Id
+}
+
+// pluginConfiguration keeps plugin configurations, and the secrets they
+// hold, out of the logs.
+type pluginConfiguration map[string]interface{}
+
+func (pluginConfiguration) String() string {
+	return "<redacted>"
+}
+
+func (c *Client) GetPlugins() ([]Plugin, error) {
+	var response []struct {
+		Plugin
+		Configuration pluginConfiguration `json:"configuration"`
+	}
+	err := c.Call("plugin.get", map[string]interface{}{}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := []Plugin{}
+	for _, p := range response {
+		p.Plugin.Configured = p.Configuration != nil
+		plugins = append(plugins, p.Plugin)
+	}
+	return plugins, nil
+}
+
+// ConfigurePlugin replaces the configuration of a plugin. XO validates it
+// against the plugin's configuration schema.
+func (c *Client) ConfigurePlugin(id string, config map[string]interface{}) error {
+	var success bool
+	params := map[string]interface{}{
+		"id":            id,
+		"configuration": pluginConfiguration(config),
+	}
+	return c.Call("plugin.configure", params, &success)
+}
+
+func (c *Client) getPlugin(id string) (*Plugin, error) {
+	plugins, err := c.GetPlugins()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plugins {
+		if p.Id == id {
+			return &p, nil
+		}
+	}
+	return nil, NotFound{Query: Plugin{Id: id}}
+}
+
+// EnablePlugin loads the plugin and makes sure it is loaded again when XO
+// restarts. Enabling an enabled plugin is a no-op.
+func (c *Client) EnablePlugin(id string) error {
+	plugin, err := c.getPlugin(id)
+	if err != nil {
+		return err
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id": id,
+	}
+	if !plugin.Autoload {
+		err = c.Call("plugin.enableAutoload", params, &success)
+		if err != nil {
+			return err
+		}
+	}
+	if !plugin.Loaded {
+		return c.Call("plugin.load", params, &success)
+	}
+	return nil
+}
+
+// DisablePlugin unloads the plugin and keeps it from being loaded when XO
+// restarts. Disabling a disabled plugin is a no-op.
+func (c *Client) DisablePlugin(id string) error {
+	plugin, err := c.getPlugin(id)
+	if err != nil {
+		return err
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id": id,
+	}
+	if plugin.Autoload {
+		err = c.Call("plugin.disableAutoload", params, &success)
+		if err != nil {
+			return err
+		}
+	}
+	if plugin.Loaded {
+		return c.Call("plugin.unload", params, &success)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func fakePluginRPC(plugins ...map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "plugin.get" {
+			return plugins, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestEnablePlugin(t *testing.T) {
+	rpc := fakePluginRPC(map[string]interface{}{"id": "auth-ldap", "name": "auth-ldap", "loaded": false, "autoload": false})
+	c := &Client{rpc: rpc}
+
+	if err := c.EnablePlugin("auth-ldap"); err != nil {
+		t.Fatalf("failed to enable plugin with error: %v", err)
+	}
+	methods := rpc.methods()
+	if len(methods) != 3 || methods[1] != "plugin.enableAutoload" || methods[2] != "plugin.load" {
+		t.Fatalf("expected the plugin to be autoloaded and loaded but received calls: %v", methods)
+	}
+	if id := rpc.callsTo("plugin.load")[0].params["id"]; id != "auth-ldap" {
+		t.Errorf("expected auth-ldap to be loaded but received %v", id)
+	}
+
+	rpc = fakePluginRPC(map[string]interface{}{"id": "auth-ldap", "loaded": true, "autoload": true})
+	c = &Client{rpc: rpc}
+	if err := c.EnablePlugin("auth-ldap"); err != nil || len(rpc.methods()) != 1 {
+		t.Errorf("expected enabling an enabled plugin to be a no-op but received calls %v with error: %v", rpc.methods(), err)
+	}
+
+	if err := c.EnablePlugin("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected enabling a missing plugin to fail with a NotFound error but received: %v", err)
+	}
+}
+
+func TestConfigurePlugin_secretsAreWriteOnly(t *testing.T) {
+	rpc := fakePluginRPC(map[string]interface{}{"id": "auth-ldap", "configuration": map[string]interface{}{"bind": map[string]interface{}{"password": "hunter2"}}})
+	c := &Client{rpc: rpc}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	config := map[string]interface{}{
+		"uri":  "ldap://ldap.example.org",
+		"bind": map[string]interface{}{"dn": "cn=xo", "password": "hunter2"},
+	}
+	if err := c.ConfigurePlugin("auth-ldap", config); err != nil {
+		t.Fatalf("failed to configure plugin with error: %v", err)
+	}
+
+	calls := rpc.callsTo("plugin.configure")
+	if len(calls) != 1 || calls[0].params["id"] != "auth-ldap" {
+		t.Fatalf("expected auth-ldap to be configured but received: %v", calls)
+	}
+	sent := calls[0].params["configuration"].(map[string]interface{})
+	if sent["uri"] != "ldap://ldap.example.org" || sent["bind"].(map[string]interface{})["password"] != "hunter2" {
+		t.Errorf("expected the configuration to be sent as is but received: %v", sent)
+	}
+
+	plugins, err := c.GetPlugins()
+	if err != nil || len(plugins) != 1 || !plugins[0].Configured {
+		t.Errorf("expected the plugin to be reported as configured but received %+v with error: %v", plugins, err)
+	}
+
+	if strings.Contains(logs.String(), "hunter2") {
+		t.Errorf("expected the plugin configuration to be kept out of the logs")
+	}
+}
@@ -2,16 +2,37 @@ package client
 
 import (
 	"fmt"
+	"log"
 	"os"
 )
 
 type Pool struct {
-	Id          string  `json:"id"`
-	NameLabel   string  `json:"name_label"`
-	Description string  `json:"name_description"`
-	Cpus        CpuInfo `json:"cpus"`
-	DefaultSR   string  `json:"default_SR"`
-	Master      string  `json:"master"`
+	Id          string            `json:"id"`
+	NameLabel   string            `json:"name_label"`
+	Description string            `json:"name_description"`
+	Cpus        CpuInfo           `json:"cpus"`
+	DefaultSR   string            `json:"default_SR"`
+	Master      string            `json:"master"`
+	Tags        []string          `json:"tags"`
+	OtherConfig map[string]string `json:"otherConfig"`
+	AutoPoweron bool              `json:"auto_poweron"`
+	CrashDumpSr string            `json:"crashDumpSr"`
+	SuspendSr   string            `json:"suspendSr"`
+	// Only reported by XCP-ng 8.3 and later, nil on older releases
+	MigrationCompression *bool `json:"migrationCompression"`
+}
+
+// UpdatePoolRequest describes the pool settings to change. Nil fields
+// are left untouched.
+type UpdatePoolRequest struct {
+	Id                   string
+	NameLabel            *string
+	Description          *string
+	Tags                 *[]string
+	AutoPoweron          *bool
+	MigrationCompression *bool
+	CrashDumpSr          *string
+	SuspendSr            *string
 }
 
 type CpuInfo struct {
@@ -52,6 +73,84 @@ func (c *Client) GetPools(pool Pool) (pools []Pool, err error) {
 	return pools, nil
 }
 
+func (c *Client) GetPoolById(id string) (*Pool, error) {
+	pools, err := c.GetPools(Pool{Id: id})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pool := range pools {
+		if pool.Id == id {
+			return &pool, nil
+		}
+	}
+	return nil, NotFound{Query: Pool{Id: id}}
+}
+
+// GetPoolsWithTags returns the pools having every one of the tags.
+func (c *Client) GetPoolsWithTags(tags []string) ([]Pool, error) {
+	pools := map[string]Pool{}
+	err := c.Call("xo.getAllObjects", map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type": "pool",
+			"tags": tags,
+		},
+	}, &pools)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Pool{}
+	for _, id := range sortedKeys(pools) {
+		res = append(res, pools[id])
+	}
+	return res, nil
+}
+
+// UpdatePool applies the non nil settings of the request with pool.set.
+// Tags are reconciled with SetTags since pool.set does not accept them.
+func (c *Client) UpdatePool(req UpdatePoolRequest) (*Pool, error) {
+	params := map[string]interface{}{
+		"id": req.Id,
+	}
+	if req.NameLabel != nil {
+		params["name_label"] = *req.NameLabel
+	}
+	if req.Description != nil {
+		params["name_description"] = *req.Description
+	}
+	if req.AutoPoweron != nil {
+		params["auto_poweron"] = *req.AutoPoweron
+	}
+	if req.MigrationCompression != nil {
+		params["migrationCompression"] = *req.MigrationCompression
+	}
+	if req.CrashDumpSr != nil {
+		params["crashDumpSr"] = *req.CrashDumpSr
+	}
+	if req.SuspendSr != nil {
+		params["suspendSr"] = *req.SuspendSr
+	}
+
+	if len(params) > 1 {
+		log.Printf("[DEBUG] Pool params for pool.set: %#v", params)
+		var success bool
+		err := c.Call("pool.set", params, &success)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.Tags != nil {
+		if err := c.SetTags(req.Id, *req.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.GetPoolById(req.Id)
+}
+
 func FindPoolForTests(pool *Pool) {
 	poolName, found := os.LookupEnv("XOA_POOL")
 
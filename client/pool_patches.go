@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+)
+
+// Names of the XAPI tasks installing patches: XCP-ng installs them
+// through the updater plugin of each host, XenServer applies pool updates
+var poolPatchesTaskNameLabels = []string{"Async.host.call_plugin", "Async.pool_update.apply"}
+
+// InstallPoolPatches installs the given patches on every host of the pool,
+// or every missing patch when patches is empty, which XCP-ng requires.
+func (c *Client) InstallPoolPatches(poolId string, patches []string) error {
+	var success bool
+	return c.Call("pool.installPatches", installPoolPatchesParams(poolId, patches), &success)
+}
+
+// InstallPoolPatchesAsync starts the installation of InstallPoolPatches
+// and returns without waiting for it to complete.
+func (c *Client) InstallPoolPatchesAsync(poolId string, patches []string) (*PendingOperation, error) {
+	return c.startOperation(context.Background(), "pool.installPatches", poolId, operationTask(poolPatchesTaskNameLabels, "", ""), func() (string, error) {
+		return "", c.InstallPoolPatches(poolId, patches)
+	})
+}
+
+func installPoolPatchesParams(poolId string, patches []string) map[string]interface{} {
+	params := map[string]interface{}{
+		"pool": poolId,
+	}
+	if len(patches) > 0 {
+		params["patches"] = patches
+	}
+	return params
+}
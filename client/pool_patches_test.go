@@ -0,0 +1,28 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInstallPoolPatches(t *testing.T) {
+	tests := []struct {
+		patches []string
+		params  map[string]interface{}
+	}{
+		{nil, map[string]interface{}{"pool": "pool-1"}},
+		{[]string{"patch-1", "patch-2"}, map[string]interface{}{"pool": "pool-1", "patches": []interface{}{"patch-1", "patch-2"}}},
+	}
+	for _, test := range tests {
+		rpc := &fakeRPC{}
+		c := Client{rpc: rpc}
+
+		if err := c.InstallPoolPatches("pool-1", test.patches); err != nil {
+			t.Fatalf("failed to install patches %v with error: %v", test.patches, err)
+		}
+		calls := rpc.callsTo("pool.installPatches")
+		if len(calls) != 1 || !reflect.DeepEqual(calls[0].params, test.params) {
+			t.Errorf("expected pool.installPatches to be called with %v but received: %v", test.params, calls)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package client
+
+// XO reaches a pool through its master only: once the master is down, the
+// objects of the other hosts are stale and every call to the pool,
+// pool.setPoolMaster included, fails. Their emergency mode can neither be
+// detected nor left through XO, the pool must be recovered from one of its
+// hosts with `xe pool-emergency-transition-to-master` followed by
+// `xe pool-recover-slaves`.
+const poolRecoveryUnsupportedReason = "XO can't reach the hosts of a pool whose master is down"
+
+// GetHostsInEmergencyMode returns the hosts of the pool which lost their
+// master. An UnsupportedOnThisServerError is always returned, see
+// poolRecoveryUnsupportedReason.
+func (c *Client) GetHostsInEmergencyMode(poolId string) ([]Host, error) {
+	return nil, UnsupportedOnThisServerError{Method: "xo.getAllObjects", Reason: poolRecoveryUnsupportedReason}
+}
+
+// RecoverPool designates a host in emergency mode as the new master of
+// the pool. An UnsupportedOnThisServerError is always returned, see
+// poolRecoveryUnsupportedReason.
+func (c *Client) RecoverPool(poolId string) error {
+	return UnsupportedOnThisServerError{Method: "pool.setPoolMaster", Reason: poolRecoveryUnsupportedReason}
+}
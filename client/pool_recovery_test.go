@@ -0,0 +1,24 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPoolRecovery_unsupported(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	var unsupported UnsupportedOnThisServerError
+	if _, err := c.GetHostsInEmergencyMode("pool-1"); !errors.As(err, &unsupported) {
+		t.Errorf("expected an UnsupportedOnThisServerError but received: %v", err)
+	}
+	if err := c.RecoverPool("pool-1"); !errors.As(err, &unsupported) || unsupported.Method != "pool.setPoolMaster" {
+		t.Errorf("expected an UnsupportedOnThisServerError for pool.setPoolMaster but received: %v", err)
+	}
+	if methods := rpc.methods(); len(methods) != 0 {
+		t.Errorf("expected no call to XO but received: %v", methods)
+	}
+}
@@ -1,6 +1,9 @@
 package client
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestPoolCompare(t *testing.T) {
 	tests := []struct {
@@ -64,3 +67,124 @@ func TestGetPoolByName(t *testing.T) {
 		t.Errorf("expected pool cpu sockets to be set")
 	}
 }
+
+func fakePoolRPC(pool map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, pool), nil
+		case "pool.set":
+			for k, v := range params {
+				pool[k] = v
+			}
+		case "tag.add":
+			pool["tags"] = append(pool["tags"].([]interface{}), params["tag"])
+		case "tag.remove":
+			tags := []interface{}{}
+			for _, tag := range pool["tags"].([]interface{}) {
+				if tag != params["tag"] {
+					tags = append(tags, tag)
+				}
+			}
+			pool["tags"] = tags
+		}
+		return true, nil
+	}}
+}
+
+func TestUpdatePool_enablesMigrationCompressionOnXcpng83(t *testing.T) {
+	rpc := fakePoolRPC(map[string]interface{}{
+		"id":                   "pool-83",
+		"type":                 "pool",
+		"name_label":           "xcp-ng 8.3",
+		"tags":                 []interface{}{"old"},
+		"otherConfig":          map[string]interface{}{"auto_poweron": "false"},
+		"migrationCompression": false,
+	})
+	c := Client{rpc: rpc}
+
+	enabled := true
+	tags := []string{"prod"}
+	pool, err := c.UpdatePool(UpdatePoolRequest{
+		Id:                   "pool-83",
+		MigrationCompression: &enabled,
+		Tags:                 &tags,
+	})
+	if err != nil {
+		t.Fatalf("failed to update pool with error: %v", err)
+	}
+
+	calls := rpc.callsTo("pool.set")
+	if len(calls) != 1 {
+		t.Fatalf("expected a single pool.set call but received %v", rpc.methods())
+	}
+	expected := map[string]interface{}{"id": "pool-83", "migrationCompression": true}
+	if fmt.Sprint(calls[0].params) != fmt.Sprint(expected) {
+		t.Errorf("expected pool.set params %v but received %v", expected, calls[0].params)
+	}
+
+	if pool.MigrationCompression == nil || !*pool.MigrationCompression {
+		t.Errorf("expected migration compression to be enabled but received %v", pool.MigrationCompression)
+	}
+	if fmt.Sprint(pool.Tags) != "[prod]" {
+		t.Errorf("expected pool tags to be [prod] but received %v", pool.Tags)
+	}
+	if pool.OtherConfig["auto_poweron"] != "false" {
+		t.Errorf("expected otherConfig to be decoded but received %v", pool.OtherConfig)
+	}
+}
+
+func TestPoolUnmarshal_migrationCompressionAbsentOnXcpng82(t *testing.T) {
+	rpc := fakePoolRPC(map[string]interface{}{
+		"id":           "pool-82",
+		"type":         "pool",
+		"name_label":   "xcp-ng 8.2",
+		"auto_poweron": true,
+		"crashDumpSr":  "sr-1",
+	})
+	c := Client{rpc: rpc}
+
+	pool, err := c.GetPoolById("pool-82")
+	if err != nil {
+		t.Fatalf("failed to get pool with error: %v", err)
+	}
+
+	if pool.MigrationCompression != nil {
+		t.Errorf("expected migration compression to be absent but received %v", *pool.MigrationCompression)
+	}
+	if !pool.AutoPoweron || pool.CrashDumpSr != "sr-1" {
+		t.Errorf("expected auto_poweron and crashDumpSr to be decoded but received %+v", pool)
+	}
+}
+
+func TestPoolTags_roundTrip(t *testing.T) {
+	rpc := fakePoolRPC(map[string]interface{}{
+		"id":   "pool-1",
+		"type": "pool",
+		"tags": []interface{}{},
+	})
+	c := Client{rpc: rpc}
+
+	if err := c.AddTag("pool-1", "region:eu"); err != nil {
+		t.Fatalf("failed to tag pool with error: %v", err)
+	}
+
+	pools, err := c.GetPoolsWithTags([]string{"region:eu"})
+	if err != nil {
+		t.Fatalf("failed to get pools by tag with error: %v", err)
+	}
+	if len(pools) != 1 || pools[0].Id != "pool-1" || fmt.Sprint(pools[0].Tags) != "[region:eu]" {
+		t.Errorf("expected pool-1 to be returned with its tag but received %+v", pools)
+	}
+	objects, err := c.GetObjectsWithTags([]string{"region:eu"})
+	if err != nil || len(objects) != 1 || objects[0] != (Object{Id: "pool-1", Type: "pool"}) {
+		t.Errorf("expected pool-1 to be listed among the tagged objects but received %+v with error: %v", objects, err)
+	}
+
+	if err := c.RemoveTag("pool-1", "region:eu"); err != nil {
+		t.Fatalf("failed to untag pool with error: %v", err)
+	}
+	if pools, err := c.GetPoolsWithTags([]string{"region:eu"}); err != nil || len(pools) != 0 {
+		t.Errorf("expected no pool to have the tag anymore but received %+v with error: %v", pools, err)
+	}
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PowerState is the power state XAPI reports for a VM or a host.
+type PowerState string
+
+const (
+	PowerStateRunning   PowerState = "Running"
+	PowerStateHalted    PowerState = "Halted"
+	PowerStatePaused    PowerState = "Paused"
+	PowerStateSuspended PowerState = "Suspended"
+	// Only reported for hosts, when XAPI can't reach them
+	PowerStateUnknown PowerState = "Unknown"
+)
+
+var powerStates = []PowerState{
+	PowerStateRunning,
+	PowerStateHalted,
+	PowerStatePaused,
+	PowerStateSuspended,
+	PowerStateUnknown,
+}
+
+// ParsePowerState returns the power state named s, regardless of its
+// casing.
+func ParsePowerState(s string) (PowerState, error) {
+	for _, state := range powerStates {
+		if strings.EqualFold(s, string(state)) {
+			return state, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf("unknown power state `%s`", s))
+}
+
+func (s PowerState) String() string {
+	return string(s)
+}
+
+// UnmarshalJSON normalizes the casing of known power states. Unknown
+// ones are kept as is.
+func (s *PowerState) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	state, err := ParsePowerState(str)
+	if err != nil {
+		state = PowerState(str)
+	}
+	*s = state
+	return nil
+}
+
+// TaskStatus is the status XAPI reports for a task.
+type TaskStatus string
+
+const (
+	TaskStatusPending    TaskStatus = "pending"
+	TaskStatusSuccess    TaskStatus = "success"
+	TaskStatusFailure    TaskStatus = "failure"
+	TaskStatusCancelling TaskStatus = "cancelling"
+	TaskStatusCancelled  TaskStatus = "cancelled"
+)
+
+var taskStatuses = []TaskStatus{
+	TaskStatusPending,
+	TaskStatusSuccess,
+	TaskStatusFailure,
+	TaskStatusCancelling,
+	TaskStatusCancelled,
+}
+
+// ParseTaskStatus returns the task status named s, regardless of its
+// casing.
+func ParseTaskStatus(s string) (TaskStatus, error) {
+	for _, status := range taskStatuses {
+		if strings.EqualFold(s, string(status)) {
+			return status, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf("unknown task status `%s`", s))
+}
+
+func (s TaskStatus) String() string {
+	return string(s)
+}
+
+// UnmarshalJSON normalizes the casing of known task statuses. Unknown
+// ones are kept as is.
+func (s *TaskStatus) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	status, err := ParseTaskStatus(str)
+	if err != nil {
+		status = TaskStatus(str)
+	}
+	*s = status
+	return nil
+}
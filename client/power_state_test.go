@@ -0,0 +1,59 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePowerState_ignoresCasing(t *testing.T) {
+	for _, s := range []string{"Running", "running", "RUNNING"} {
+		state, err := ParsePowerState(s)
+		if err != nil || state != PowerStateRunning {
+			t.Errorf("expected `%s` to parse to %s but received %s with error: %v", s, PowerStateRunning, state, err)
+		}
+	}
+
+	if _, err := ParsePowerState("stopped"); err == nil {
+		t.Errorf("expected an unknown power state to be rejected")
+	}
+}
+
+func TestPowerState_unmarshalNormalizesCasing(t *testing.T) {
+	for _, data := range []string{`{"power_state": "Halted"}`, `{"power_state": "halted"}`} {
+		var vm Vm
+		if err := json.Unmarshal([]byte(data), &vm); err != nil {
+			t.Fatalf("failed to unmarshal %s with error: %v", data, err)
+		}
+		if vm.PowerState != PowerStateHalted {
+			t.Errorf("expected %s to unmarshal to %s but received %s", data, PowerStateHalted, vm.PowerState)
+		}
+	}
+
+	var vm Vm
+	if err := json.Unmarshal([]byte(`{"power_state": "Migrating"}`), &vm); err != nil || vm.PowerState != "Migrating" {
+		t.Errorf("expected unknown power states to be kept but received %s with error: %v", vm.PowerState, err)
+	}
+}
+
+func TestHostPowerState_unmarshalNormalizesCasing(t *testing.T) {
+	var host Host
+	if err := json.Unmarshal([]byte(`{"power_state": "unknown"}`), &host); err != nil || host.PowerState != PowerStateUnknown {
+		t.Errorf("expected the power state of the host to unmarshal to %s but received %s with error: %v", PowerStateUnknown, host.PowerState, err)
+	}
+}
+
+func TestTaskStatus_ignoresCasing(t *testing.T) {
+	for _, data := range []string{`{"status": "success"}`, `{"status": "Success"}`} {
+		var task Task
+		if err := json.Unmarshal([]byte(data), &task); err != nil {
+			t.Fatalf("failed to unmarshal %s with error: %v", data, err)
+		}
+		if task.Status != TaskStatusSuccess {
+			t.Errorf("expected %s to unmarshal to %s but received %s", data, TaskStatusSuccess, task.Status)
+		}
+	}
+
+	if _, err := ParseTaskStatus("done"); err == nil {
+		t.Errorf("expected an unknown task status to be rejected")
+	}
+}
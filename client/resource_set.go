@@ -1,10 +1,13 @@
 package client
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
 )
 
 type ResourceSet struct {
@@ -22,8 +25,22 @@ type ResourceSetLimits struct {
 }
 
 type ResourceSetLimit struct {
-	Available int `json:"available,omitempty"`
-	Total     int `json:"total,omitempty"`
+	Available int64 `json:"available,omitempty"`
+	Total     int64 `json:"total,omitempty"`
+}
+
+// Get returns the limit of the given name: `cpus`, `memory` or `disk`,
+// as reported by a QuotaExceededError.
+func (l ResourceSetLimits) Get(name string) (ResourceSetLimit, bool) {
+	switch name {
+	case "cpus":
+		return l.Cpus, true
+	case "memory":
+		return l.Memory, true
+	case "disk":
+		return l.Disk, true
+	}
+	return ResourceSetLimit{}, false
 }
 
 func (rs ResourceSet) Compare(obj interface{}) bool {
@@ -227,6 +244,64 @@ func (c Client) AddResourceSetLimit(rsReq ResourceSet, limit string, quantity in
 	return err
 }
 
+// XO's error code when a resource set doesn't have enough resources left.
+const notEnoughResourcesCode = 13
+
+type ExceededLimit struct {
+	// Either `cpus`, `memory` or `disk`. Memory and disk are in bytes.
+	Name      string `json:"resourceType"`
+	Available int64  `json:"available"`
+	Requested int64  `json:"requested"`
+}
+
+// Excess returns by how much the request exceeds the available resources.
+func (l ExceededLimit) Excess() int64 {
+	return l.Requested - l.Available
+}
+
+// QuotaExceededError is returned when an operation needs more resources
+// than a resource set has left.
+type QuotaExceededError struct {
+	ResourceSetId string
+	Limits        []ExceededLimit
+	Err           error
+}
+
+func (e QuotaExceededError) Error() string {
+	limits := []string{}
+	for _, l := range e.Limits {
+		limits = append(limits, fmt.Sprintf("%s exceeded by %d (requested %d, available %d)", l.Name, l.Excess(), l.Requested, l.Available))
+	}
+	return fmt.Sprintf("not enough resources in resource set `%s`: %s", e.ResourceSetId, strings.Join(limits, ", "))
+}
+
+func (e QuotaExceededError) Unwrap() error {
+	return e.Err
+}
+
+// quotaExceeded converts XO's not enough resources error into a
+// QuotaExceededError.
+func quotaExceeded(err error) error {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != notEnoughResourcesCode || rpcErr.Data == nil {
+		return err
+	}
+
+	var data []struct {
+		ExceededLimit
+		ResourceSet string `json:"resourceSet"`
+	}
+	if json.Unmarshal(*rpcErr.Data, &data) != nil || len(data) == 0 {
+		return err
+	}
+
+	quotaErr := QuotaExceededError{ResourceSetId: data[0].ResourceSet, Err: err}
+	for _, d := range data {
+		quotaErr.Limits = append(quotaErr.Limits, d.ExceededLimit)
+	}
+	return quotaErr
+}
+
 func RemoveResourceSetsWithNamePrefix(rsNamePrefix string) func(string) error {
 	return func(_ string) error {
 		fmt.Println("[DEBUG] Running sweeper")
@@ -251,3 +326,52 @@ func RemoveResourceSetsWithNamePrefix(rsNamePrefix string) func(string) error {
 		return nil
 	}
 }
+
+// ResourceSetPlacementError is returned before starting or migrating a VM
+// on a host its resource set doesn't allow.
+type ResourceSetPlacementError struct {
+	VmId          string
+	ResourceSetId string
+	HostId        string
+}
+
+func (e ResourceSetPlacementError) Error() string {
+	return fmt.Sprintf("host `%s` is not allowed by resource set `%s` of vm `%s`", e.HostId, e.ResourceSetId, e.VmId)
+}
+
+// checkResourceSetPlacement fails with a ResourceSetPlacementError when
+// the VM belongs to a resource set restricting its hosts and the host
+// isn't one of them. A resource set restricts the hosts of its VMs to the
+// hosts and pools among its objects, it doesn't when it has none.
+func (c *Client) checkResourceSetPlacement(vm Vm, hostId string) error {
+	if vm.ResourceSet == "" {
+		return nil
+	}
+	rs, err := c.GetResourceSetById(vm.ResourceSet)
+	if err != nil {
+		return err
+	}
+
+	var hosts map[string]Host
+	if err := c.getAllObjectsOfXoType("host", &hosts); err != nil {
+		return err
+	}
+	restricted := false
+	for _, objectId := range rs.Objects {
+		for _, host := range hosts {
+			if objectId == host.Id || objectId == host.Pool {
+				restricted = true
+				break
+			}
+		}
+	}
+	if !restricted {
+		return nil
+	}
+
+	host, ok := hosts[hostId]
+	if ok && (stringInSlice(host.Id, rs.Objects) || stringInSlice(host.Pool, rs.Objects)) {
+		return nil
+	}
+	return ResourceSetPlacementError{VmId: vm.Id, ResourceSetId: rs.Id, HostId: hostId}
+}
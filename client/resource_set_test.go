@@ -1,7 +1,12 @@
 package client
 
 import (
+	"encoding/json"
+	"errors"
+	"reflect"
 	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
 )
 
 var testResourceSetName string = "xenorchestra-client-resource-set2"
@@ -70,3 +75,211 @@ func TestGetResourceSet(t *testing.T) {
 		t.Errorf("resource set should have contained 2 CPUs")
 	}
 }
+
+func TestQuotaExceeded_surfacesTheBreachedLimit(t *testing.T) {
+	data := json.RawMessage(`[{"resourceSet":"rs-1","resourceType":"memory","available":1073741824,"requested":4294967296}]`)
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, &jsonrpc2.Error{Code: 13, Message: "not enough resources in resource set", Data: &data}
+	}}}
+
+	var vmId string
+	err := quotaExceeded(c.Call("vm.create", map[string]interface{}{"resourceSet": "rs-1"}, &vmId))
+
+	var quotaErr QuotaExceededError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected a QuotaExceededError but received: %v", err)
+	}
+	if quotaErr.ResourceSetId != "rs-1" || len(quotaErr.Limits) != 1 {
+		t.Fatalf("expected the memory limit of rs-1 to be reported but received: %+v", quotaErr)
+	}
+	if limit := quotaErr.Limits[0]; limit.Name != "memory" || limit.Excess() != 3221225472 {
+		t.Errorf("expected memory to be exceeded by 3GiB but received: %+v", limit)
+	}
+	if _, ok := (ResourceSetLimits{}).Get(quotaErr.Limits[0].Name); !ok {
+		t.Errorf("expected the limit name to match a resource set limit")
+	}
+
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		t.Errorf("expected the XO error to be wrapped")
+	}
+}
+
+func TestQuotaExceeded_keepsOtherErrors(t *testing.T) {
+	err := &jsonrpc2.Error{Code: 10, Message: "invalid parameters"}
+	if quotaExceeded(err) != err {
+		t.Errorf("expected other errors to be returned as is")
+	}
+}
+
+func fakePlacementRPC(objects ...map[string]interface{}) *fakeRPC {
+	objects = append(objects,
+		map[string]interface{}{"id": "host-1", "type": "host", "name_label": "host 1", "$pool": "pool-1"},
+		map[string]interface{}{"id": "host-2", "type": "host", "name_label": "host 2", "$pool": "pool-1"},
+		map[string]interface{}{"id": "host-3", "type": "host", "name_label": "host 3", "$pool": "pool-2"},
+		map[string]interface{}{"id": "vm-1", "type": "VM", "name_label": "tenant vm", "power_state": "Running", "$poolId": "pool-1", "$container": "host-1", "resourceSet": "rs-hosts"},
+		map[string]interface{}{"id": "vm-2", "type": "VM", "name_label": "pool vm", "power_state": "Halted", "$poolId": "pool-2", "$container": "pool-2", "resourceSet": "rs-pool"},
+		map[string]interface{}{"id": "vm-3", "type": "VM", "name_label": "storage vm", "power_state": "Running", "$poolId": "pool-1", "$container": "host-1", "resourceSet": "rs-storage"},
+	)
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "resourceSet.getAll":
+			return []map[string]interface{}{
+				{"id": "rs-hosts", "name": "hosts", "objects": []string{"host-1", "sr-1"}},
+				{"id": "rs-pool", "name": "pool", "objects": []string{"pool-2", "sr-2"}},
+				{"id": "rs-storage", "name": "storage", "objects": []string{"sr-1", "network-1"}},
+			}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestMigrateVm_rejectsHostOutsideResourceSet(t *testing.T) {
+	rpc := fakePlacementRPC()
+	c := &Client{rpc: rpc}
+
+	err := c.MigrateVm("vm-1", "host-2")
+	var placementErr ResourceSetPlacementError
+	if !errors.As(err, &placementErr) {
+		t.Fatalf("expected a ResourceSetPlacementError but received: %v", err)
+	}
+	expected := ResourceSetPlacementError{VmId: "vm-1", ResourceSetId: "rs-hosts", HostId: "host-2"}
+	if placementErr != expected {
+		t.Errorf("expected %+v but received %+v", expected, placementErr)
+	}
+	if calls := rpc.callsTo("vm.migrate"); len(calls) != 0 {
+		t.Errorf("expected the migration to be rejected before calling XO but received: %v", calls)
+	}
+}
+
+func TestMigrateVm_allowedHosts(t *testing.T) {
+	tests := []struct {
+		vmId   string
+		hostId string
+	}{
+		// The host is one of the resource set's objects
+		{"vm-1", "host-1"},
+		// The host's pool is one of the resource set's objects
+		{"vm-2", "host-3"},
+		// The resource set doesn't restrict hosts
+		{"vm-3", "host-2"},
+	}
+	for _, test := range tests {
+		rpc := fakePlacementRPC()
+		c := &Client{rpc: rpc}
+
+		if err := c.MigrateVm(test.vmId, test.hostId); err != nil {
+			t.Errorf("expected vm `%s` to be migrated to host `%s` but received: %v", test.vmId, test.hostId, err)
+			continue
+		}
+		calls := rpc.callsTo("vm.migrate")
+		if len(calls) != 1 || calls[0].params["vm"] != test.vmId || calls[0].params["targetHost"] != test.hostId {
+			t.Errorf("expected vm `%s` to be migrated to host `%s` but received: %v", test.vmId, test.hostId, calls)
+		}
+	}
+}
+
+func TestStartVmWithOptions_rejectsHostOutsideResourceSet(t *testing.T) {
+	rpc := fakePlacementRPC()
+	c := &Client{rpc: rpc}
+
+	err := c.StartVmWithOptions("vm-2", StartVmOptions{HostId: "host-1", CloudConfig: "#cloud-config\n", CloudConfigSrId: "sr-1"})
+	var placementErr ResourceSetPlacementError
+	if !errors.As(err, &placementErr) || placementErr.ResourceSetId != "rs-pool" {
+		t.Fatalf("expected a ResourceSetPlacementError for rs-pool but received: %v", err)
+	}
+	for _, method := range []string{"vm.start", "cloudConfig.createConfigDrive", "vdi.delete"} {
+		if calls := rpc.callsTo(method); len(calls) != 0 {
+			t.Errorf("expected the start to be rejected before calling %s but received: %v", method, calls)
+		}
+	}
+}
+
+func fakeResourceSetUsageRPC(limits map[string]interface{}) *fakeRPC {
+	objects := []map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "name_label": "web", "resourceSet": "rs-1", "CPUs": map[string]interface{}{"number": 2}, "memory": map[string]interface{}{"size": 2147483648}},
+		{"id": "vm-2", "type": "VM", "name_label": "db", "resourceSet": "rs-1", "CPUs": map[string]interface{}{"number": 4}, "memory": map[string]interface{}{"size": 4294967296}},
+		{"id": "vm-3", "type": "VM", "name_label": "other tenant", "resourceSet": "rs-2", "CPUs": map[string]interface{}{"number": 8}},
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "vdi-1"},
+		{"id": "vbd-2", "type": "VBD", "VM": "vm-1", "VDI": "vdi-iso", "is_cd_drive": true},
+		{"id": "vbd-3", "type": "VBD", "VM": "vm-2", "VDI": "vdi-2"},
+		{"id": "vbd-4", "type": "VBD", "VM": "vm-2", "VDI": "vdi-3"},
+		{"id": "vdi-1", "type": "VDI", "size": 10737418240},
+		{"id": "vdi-2", "type": "VDI", "size": 21474836480},
+		{"id": "vdi-3", "type": "VDI", "size": 1073741824},
+		{"id": "vdi-iso", "type": "VDI", "size": 734003200},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "resourceSet.getAll" {
+			return []map[string]interface{}{
+				{"id": "rs-1", "name": "tenant", "limits": limits},
+				{"id": "rs-2", "name": "other tenant", "limits": map[string]interface{}{}},
+			}, nil
+		}
+		return fakeGetAllObjects(params, objects...), nil
+	}}
+}
+
+func TestGetResourceSetUsage_limited(t *testing.T) {
+	c := &Client{rpc: fakeResourceSetUsageRPC(map[string]interface{}{
+		"cpus":   map[string]interface{}{"total": 8, "available": 2},
+		"memory": map[string]interface{}{"total": 8589934592, "available": 2147483648},
+		"disk":   map[string]interface{}{"total": 107374182400, "available": 75161927680},
+	})}
+
+	usage, err := c.GetResourceSetUsage("rs-1")
+	if err != nil {
+		t.Fatalf("failed to get the usage with error: %v", err)
+	}
+	if *usage.Cpus != (ResourceUsage{Total: 8, Available: 2, Used: 6}) || usage.Memory.Used != 6442450944 || usage.Disk.Used != 32212254720 || usage.Cpus.OverQuota() {
+		t.Errorf("expected the used resources to be total minus available but received: %+v %+v %+v", usage.Cpus, usage.Memory, usage.Disk)
+	}
+
+	expected := []ResourceSetVmUsage{
+		{VmId: "vm-1", NameLabel: "web", Cpus: 2, Memory: 2147483648, Disk: 10737418240},
+		{VmId: "vm-2", NameLabel: "db", Cpus: 4, Memory: 4294967296, Disk: 22548578304},
+	}
+	if !reflect.DeepEqual(usage.Vms, expected) {
+		t.Errorf("expected the VMs of the set and their disks without CD drives but received: %+v", usage.Vms)
+	}
+}
+
+func TestGetResourceSetUsage_unlimited(t *testing.T) {
+	c := &Client{rpc: fakeResourceSetUsageRPC(map[string]interface{}{
+		"cpus": map[string]interface{}{"total": 0, "available": 0},
+	})}
+
+	usage, err := c.GetResourceSetUsage("rs-1")
+	if err != nil {
+		t.Fatalf("failed to get the usage with error: %v", err)
+	}
+	if usage.Cpus == nil || usage.Cpus.Total != 0 {
+		t.Errorf("expected a zero cpus limit to be kept but received: %+v", usage.Cpus)
+	}
+	if usage.Memory != nil || usage.Disk != nil {
+		t.Errorf("expected the absent limits to be nil but received: %+v %+v", usage.Memory, usage.Disk)
+	}
+	if len(usage.Vms) != 2 {
+		t.Errorf("expected the VMs of an unlimited set to be listed but received: %+v", usage.Vms)
+	}
+
+	if _, err := c.GetResourceSetUsage("rs-missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a NotFound error but received: %v", err)
+	}
+}
+
+func TestGetResourceSetUsage_overQuota(t *testing.T) {
+	c := &Client{rpc: fakeResourceSetUsageRPC(map[string]interface{}{
+		"memory": map[string]interface{}{"total": 4294967296, "available": -2147483648},
+	})}
+
+	usage, err := c.GetResourceSetUsage("rs-1")
+	if err != nil {
+		t.Fatalf("failed to get the usage with error: %v", err)
+	}
+	if !usage.Memory.OverQuota() || usage.Memory.Used != 6442450944 {
+		t.Errorf("expected the memory to be over quota by 2GiB but received: %+v", usage.Memory)
+	}
+}
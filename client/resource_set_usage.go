@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ResourceUsage is the consumption of a limited resource of a resource set.
+// Memory and disk are in bytes.
+type ResourceUsage struct {
+	Total     int64
+	Available int64
+	// Total - Available, above Total once the set is over quota
+	Used int64
+}
+
+// OverQuota reports whether the resources of the set consume more than its
+// limit, e.g. when the limit was lowered below the current usage.
+func (u ResourceUsage) OverQuota() bool {
+	return u.Available < 0
+}
+
+// ResourceSetVmUsage is what a VM of the resource set consumes. Disk is the
+// size of its VDIs, CD drives excluded.
+type ResourceSetVmUsage struct {
+	VmId      string
+	NameLabel string
+	Cpus      int64
+	Memory    int64
+	Disk      int64
+}
+
+// ResourceSetUsage is the consumption of a resource set. The resources the
+// set doesn't limit are nil rather than zero.
+type ResourceSetUsage struct {
+	ResourceSetId string
+	Cpus          *ResourceUsage
+	Memory        *ResourceUsage
+	Disk          *ResourceUsage
+	// VMs of the set sorted by id
+	Vms []ResourceSetVmUsage
+}
+
+// GetResourceSetUsage returns the consumption of each limit of the resource
+// set along with what each of its VMs consumes.
+func (c *Client) GetResourceSetUsage(setId string) (*ResourceSetUsage, error) {
+	// ResourceSetLimits can't tell an absent limit from a zero one
+	var resourceSets []struct {
+		Id     string `json:"id"`
+		Limits struct {
+			Cpus   *ResourceSetLimit `json:"cpus"`
+			Memory *ResourceSetLimit `json:"memory"`
+			Disk   *ResourceSetLimit `json:"disk"`
+		} `json:"limits"`
+	}
+	params := map[string]interface{}{
+		"id": "dummy",
+	}
+	if err := c.Call("resourceSet.getAll", params, &resourceSets); err != nil {
+		return nil, err
+	}
+
+	var usage *ResourceSetUsage
+	for _, rs := range resourceSets {
+		if rs.Id != setId {
+			continue
+		}
+		if usage != nil {
+			return nil, errors.New(fmt.Sprintf("found several resource sets with id `%s`", setId))
+		}
+		usage = &ResourceSetUsage{
+			ResourceSetId: setId,
+			Cpus:          resourceUsage(rs.Limits.Cpus),
+			Memory:        resourceUsage(rs.Limits.Memory),
+			Disk:          resourceUsage(rs.Limits.Disk),
+		}
+	}
+	if usage == nil {
+		return nil, NotFound{Query: ResourceSet{Id: setId}}
+	}
+
+	vms, err := c.getResourceSetVmUsage(setId)
+	if err != nil {
+		return nil, err
+	}
+	usage.Vms = vms
+	return usage, nil
+}
+
+func resourceUsage(limit *ResourceSetLimit) *ResourceUsage {
+	if limit == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		Total:     limit.Total,
+		Available: limit.Available,
+		Used:      limit.Total - limit.Available,
+	}
+}
+
+// getResourceSetVmUsage returns what the VMs XO assigned to the resource set,
+// through their `xo:resource_set` other config, consume.
+func (c *Client) getResourceSetVmUsage(setId string) ([]ResourceSetVmUsage, error) {
+	var vmsRes map[string]Vm
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":        "VM",
+			"resourceSet": setId,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &vmsRes); err != nil {
+		return nil, err
+	}
+
+	vms := []ResourceSetVmUsage{}
+	if len(vmsRes) == 0 {
+		return vms, nil
+	}
+
+	var vbds map[string]VBD
+	if err := c.getAllObjectsOfXoType("VBD", &vbds); err != nil {
+		return nil, err
+	}
+	var vdis map[string]VDI
+	if err := c.getAllObjectsOfXoType("VDI", &vdis); err != nil {
+		return nil, err
+	}
+	disk := map[string]int64{}
+	for _, vbd := range vbds {
+		if _, ok := vmsRes[vbd.VmId]; ok && !vbd.IsCdDrive {
+			disk[vbd.VmId] += vdis[vbd.VDI].Size
+		}
+	}
+
+	for _, id := range sortedKeys(vmsRes) {
+		vm := vmsRes[id]
+		vms = append(vms, ResourceSetVmUsage{
+			VmId:      id,
+			NameLabel: vm.NameLabel,
+			Cpus:      int64(vm.CPUs.Number),
+			Memory:    vm.Memory.Size,
+			Disk:      disk[id],
+		})
+	}
+	return vms, nil
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+const (
+	TransportJsonRpc = "jsonrpc"
+	// Experimental, see restRPC
+	TransportRest = "rest"
+)
+
+const restApiPath = "/rest/v0"
+
+// REST collections serving the XO object types of the same name.
+var restCollections = map[string]string{
+	"VM":           "vms",
+	"VM-template":  "vm-templates",
+	"VM-snapshot":  "vm-snapshots",
+	"host":         "hosts",
+	"pool":         "pools",
+	"SR":           "srs",
+	"network":      "networks",
+	"PIF":          "pifs",
+	"VIF":          "vifs",
+	"VBD":          "vbds",
+	"VDI":          "vdis",
+	"VDI-snapshot": "vdi-snapshots",
+}
+
+// restVmAction returns the REST action equivalent to a json rpc call on a
+// VM along with its body, and the params of the call the action can't
+// honor, e.g. the host of vm.start. ok is false when the method has no
+// REST equivalent.
+func restVmAction(method string, params map[string]interface{}) (action string, body map[string]interface{}, unmapped []string, ok bool) {
+	mapped := map[string]bool{"id": true}
+	switch method {
+	case "vm.start":
+		action = "start"
+	case "vm.restart", "vm.stop":
+		action = "clean_reboot"
+		if method == "vm.stop" {
+			action = "clean_shutdown"
+		}
+		if force, _ := params["force"].(bool); force {
+			action = strings.Replace(action, "clean", "hard", 1)
+		}
+		mapped["force"] = true
+	case "vm.snapshot":
+		action = "snapshot"
+		if name, ok := params["name"]; ok {
+			body = map[string]interface{}{"name_label": name}
+		}
+		mapped["name"] = true
+	default:
+		return "", nil, nil, false
+	}
+
+	for _, param := range sortedKeys(params) {
+		if !mapped[param] {
+			unmapped = append(unmapped, param)
+		}
+	}
+	return action, body, unmapped, true
+}
+
+// restRPC serves the json rpc calls made by the client through XO's REST
+// api where it has an equivalent: xo.getAllObjects for the object types
+// exposed as REST collections and a few VM actions. Any other call, or a
+// VM action with params the REST api can't honor, is sent to the json rpc
+// connection when there is one, otherwise it fails with an
+// UnsupportedOnThisServerError.
+type restRPC struct {
+	url        string
+	username   string
+	password   string
+	token      string
+	httpClient *http.Client
+	fallback   jsonrpc2.JSONRPC2
+}
+
+func newRestRPC(config Config, httpClient *http.Client, fallback jsonrpc2.JSONRPC2) *restRPC {
+	url := config.Url
+	if strings.HasPrefix(url, "ws") {
+		url = "http" + strings.TrimPrefix(url, "ws")
+	}
+	return &restRPC{
+		url:        strings.TrimSuffix(url, "/") + restApiPath,
+		username:   config.Username,
+		password:   config.Password,
+		token:      config.Token,
+		httpClient: httpClient,
+		fallback:   fallback,
+	}
+}
+
+func (r *restRPC) Call(ctx context.Context, method string, params, result interface{}, opt ...jsonrpc2.CallOption) error {
+	var p map[string]interface{}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	if err := decodeJsonNumbers(bytes.NewReader(b), &p); err != nil {
+		return err
+	}
+
+	if method == "xo.getAllObjects" {
+		filter, _ := p["filter"].(map[string]interface{})
+		xoType, _ := filter["type"].(string)
+		if collection, ok := restCollections[xoType]; ok {
+			return r.getAllObjects(ctx, collection, filter, result)
+		}
+	}
+
+	if action, body, unmapped, ok := restVmAction(method, p); ok {
+		if len(unmapped) == 0 {
+			id, _ := p["id"].(string)
+			return r.vmAction(ctx, id, action, body, result)
+		}
+		if r.fallback == nil {
+			return UnsupportedOnThisServerError{Method: method, Reason: fmt.Sprintf("the REST api can't honor the params %v", unmapped)}
+		}
+	} else if r.fallback == nil {
+		return UnsupportedOnThisServerError{Method: method}
+	}
+	return r.fallback.Call(ctx, method, params, result, opt...)
+}
+
+func (r *restRPC) Notify(ctx context.Context, method string, params interface{}, opt ...jsonrpc2.CallOption) error {
+	if r.fallback == nil {
+		return UnsupportedOnThisServerError{Method: method}
+	}
+	return r.fallback.Notify(ctx, method, params, opt...)
+}
+
+func (r *restRPC) Close() error {
+	if r.fallback == nil {
+		return nil
+	}
+	return r.fallback.Close()
+}
+
+// restNotFound is returned by do when the requested object doesn't exist.
+var restNotFound = errors.New("not found")
+
+func (r *restRPC) do(ctx context.Context, httpMethod, path string, body, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, httpMethod, r.url+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		req.AddCookie(&http.Cookie{Name: "authenticationToken", Value: r.token})
+	} else {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	log.Printf("[TRACE] Made REST request `%s %s` and received status: %s\n", httpMethod, path, resp.Status)
+	if resp.StatusCode == http.StatusNotFound {
+		return restNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.New(fmt.Sprintf("REST request `%s %s` failed with status %s: %s", httpMethod, path, resp.Status, msg))
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return decodeJsonNumbers(resp.Body, result)
+}
+
+// decodeJsonNumbers decodes numbers held by interface{} values as
+// json.Number rather than float64, which would round sizes above 2^53
+// bytes when the objects are encoded again.
+func decodeJsonNumbers(r io.Reader, v interface{}) error {
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// ping checks that the REST api is reachable with the configured
+// credentials.
+func (r *restRPC) ping(ctx context.Context) error {
+	return r.do(ctx, http.MethodGet, "/", nil, nil)
+}
+
+// getAllObjects answers an xo.getAllObjects call with the objects of a
+// REST collection. Like XO, the objects are keyed by id and must match
+// every property of the filter.
+func (r *restRPC) getAllObjects(ctx context.Context, collection string, filter map[string]interface{}, result interface{}) error {
+	objects := []map[string]interface{}{}
+	if id, ok := filter["id"].(string); ok {
+		obj := map[string]interface{}{}
+		err := r.do(ctx, http.MethodGet, fmt.Sprintf("/%s/%s", collection, id), nil, &obj)
+
+		if err != nil && err != restNotFound {
+			return err
+		}
+		if err == nil {
+			objects = append(objects, obj)
+		}
+	} else {
+		err := r.do(ctx, http.MethodGet, fmt.Sprintf("/%s?fields=*", collection), nil, &objects)
+		if err != nil {
+			return err
+		}
+	}
+
+	res := map[string]interface{}{}
+	for _, obj := range objects {
+		if !matchesFilter(obj, filter) {
+			continue
+		}
+		id, _ := obj["id"].(string)
+		res[id] = obj
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, result)
+}
+
+// matchesFilter reports whether obj has every property of the filter.
+// Arrays in the filter match arrays of obj containing all of their items.
+func matchesFilter(obj, filter map[string]interface{}) bool {
+	for k, v := range filter {
+		expected, isArray := v.([]interface{})
+		if !isArray {
+			if !reflect.DeepEqual(obj[k], v) {
+				return false
+			}
+			continue
+		}
+
+		actual, _ := obj[k].([]interface{})
+		for _, item := range expected {
+			found := false
+			for _, a := range actual {
+				if reflect.DeepEqual(a, item) {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// vmAction runs the action synchronously. XO answers with the result of
+// the action, e.g. the id of a snapshot, or no content.
+func (r *restRPC) vmAction(ctx context.Context, id, action string, body map[string]interface{}, result interface{}) error {
+	var res json.RawMessage
+	var b interface{}
+	if body != nil {
+		b = body
+	}
+	err := r.do(ctx, http.MethodPost, fmt.Sprintf("/vms/%s/actions/%s?sync=true", id, action), b, &res)
+	if err != nil {
+		return err
+	}
+
+	// Mirror the json rpc methods which report their success
+	if success, ok := result.(*bool); ok {
+		*success = true
+		return nil
+	}
+	if result == nil {
+		return nil
+	}
+	if len(res) == 0 {
+		return errors.New(fmt.Sprintf("REST action `%s` of VM `%s` returned no result", action, id))
+	}
+	return json.Unmarshal(res, result)
+}
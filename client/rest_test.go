@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var restFixtures = []map[string]interface{}{
+	{"id": "net-1", "type": "network", "name_label": "Pool-wide network", "$poolId": "pool-1"},
+	{"id": "net-2", "type": "network", "name_label": "Private network", "$poolId": "pool-1"},
+	{"id": "host-1", "type": "host", "name_label": "host 1", "$pool": "pool-1"},
+	{"id": "host-2", "type": "host", "name_label": "host 2", "$pool": "pool-1"},
+	{"id": "sr-1", "type": "SR", "name_label": "Local storage", "$poolId": "pool-1", "tags": []string{"ssd"}},
+	{"id": "sr-2", "type": "SR", "name_label": "NFS", "$poolId": "pool-1", "size": int64(1<<53 + 1)},
+	{"id": "vm-1", "type": "VM", "name_label": "vm 1", "power_state": "Running"},
+}
+
+// restFixtureServer serves restFixtures the way XO's REST api does.
+func restFixtureServer(t *testing.T) *httptest.Server {
+	collections := map[string]string{}
+	for xoType, collection := range restCollections {
+		collections[collection] = xoType
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, restApiPath+"/"), "/")
+		if path[0] == "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if len(path) == 4 && path[0] == "vms" && path[2] == "actions" && r.Method == http.MethodPost {
+			if r.URL.Query().Get("sync") != "true" {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			if path[3] != "snapshot" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(path[1] + " snapshot " + body["name_label"])
+			return
+		}
+		xoType, ok := collections[path[0]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		objects := []map[string]interface{}{}
+		for _, obj := range restFixtures {
+			if obj["type"] == xoType && (len(path) == 1 || obj["id"] == path[1]) {
+				objects = append(objects, obj)
+			}
+		}
+
+		if len(path) == 1 {
+			json.NewEncoder(w).Encode(objects)
+			return
+		}
+		if len(objects) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(objects[0])
+	}))
+}
+
+// testListingContract runs the same listings against every transport so
+// they can't drift apart.
+func testListingContract(t *testing.T, c *Client) {
+	nets, err := c.GetNetworks()
+	if err != nil || len(nets) != 2 {
+		t.Errorf("expected to list 2 networks but received %+v with error: %v", nets, err)
+	}
+
+	host, err := c.GetHostById("host-2")
+	if err != nil || host.NameLabel != "host 2" {
+		t.Errorf("expected to find host-2 but received %+v with error: %v", host, err)
+	}
+
+	srs, err := c.GetStorageRepository(StorageRepository{NameLabel: "NFS"})
+	if err != nil || len(srs) != 1 || srs[0].Id != "sr-2" {
+		t.Errorf("expected to find the NFS SR but received %+v with error: %v", srs, err)
+	} else if srs[0].Size != 1<<53+1 {
+		t.Errorf("expected the size of the NFS SR to be decoded exactly but received: %d", srs[0].Size)
+	}
+
+	vm, err := c.GetVm(Vm{Id: "vm-1"})
+	if err != nil || vm.PowerState != "Running" {
+		t.Errorf("expected to find the running vm-1 but received %+v with error: %v", vm, err)
+	}
+
+	var notFound NotFound
+	if _, err := c.GetVm(Vm{Id: "missing"}); !errors.As(err, &notFound) {
+		t.Errorf("expected a NotFound error for a missing VM but received: %v", err)
+	}
+}
+
+func TestTransportContract_jsonRpc(t *testing.T) {
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, restFixtures...), nil
+	}}}
+	testListingContract(t, c)
+}
+
+func TestTransportContract_rest(t *testing.T) {
+	server := restFixtureServer(t)
+	defer server.Close()
+
+	config := Config{Url: strings.Replace(server.URL, "http", "ws", 1), Username: "admin", Password: "secret"}
+	c := &Client{rpc: newRestRPC(config, server.Client(), nil)}
+	testListingContract(t, c)
+}
+
+func TestRestRPC_filtersObjects(t *testing.T) {
+	server := restFixtureServer(t)
+	defer server.Close()
+
+	rpc := newRestRPC(Config{Url: server.URL, Username: "admin", Password: "secret"}, server.Client(), nil)
+	c := &Client{rpc: rpc}
+
+	var response map[string]StorageRepository
+	params := map[string]interface{}{
+		"filter": map[string]interface{}{"type": "SR", "tags": []string{"ssd"}},
+	}
+	if err := c.Call("xo.getAllObjects", params, &response); err != nil {
+		t.Fatalf("failed to get SRs with error: %v", err)
+	}
+	if _, ok := response["sr-1"]; !ok || len(response) != 1 {
+		t.Errorf("expected only sr-1 to have the ssd tag but received: %+v", response)
+	}
+}
+
+func TestRestRPC_unsupportedMethods(t *testing.T) {
+	server := restFixtureServer(t)
+	defer server.Close()
+	config := Config{Url: server.URL, Username: "admin", Password: "secret"}
+
+	c := &Client{rpc: newRestRPC(config, server.Client(), nil)}
+	var unsupported UnsupportedOnThisServerError
+	if err := c.Call("vm.set", map[string]interface{}{"id": "vm-1"}, nil); !errors.As(err, &unsupported) {
+		t.Errorf("expected an UnsupportedOnThisServerError without a json rpc connection but received: %v", err)
+	}
+
+	fallback := &fakeRPC{}
+	c = &Client{rpc: newRestRPC(config, server.Client(), fallback)}
+	if err := c.Call("vm.set", map[string]interface{}{"id": "vm-1"}, nil); err != nil {
+		t.Errorf("expected vm.set to be sent over json rpc but received: %v", err)
+	}
+	if len(fallback.callsTo("vm.set")) != 1 {
+		t.Errorf("expected vm.set to be sent over json rpc but received calls: %v", fallback.methods())
+	}
+}
+
+func TestRestRPC_ping(t *testing.T) {
+	server := restFixtureServer(t)
+	defer server.Close()
+
+	rpc := newRestRPC(Config{Url: server.URL, Username: "admin", Password: "secret"}, server.Client(), nil)
+	if err := rpc.ping(context.Background()); err != nil {
+		t.Errorf("expected ping to succeed but received: %v", err)
+	}
+
+	rpc = newRestRPC(Config{Url: server.URL, Username: "admin", Password: "wrong"}, server.Client(), nil)
+	if err := rpc.ping(context.Background()); err == nil {
+		t.Errorf("expected invalid credentials to be rejected")
+	}
+}
+
+func TestRestRPC_vmActions(t *testing.T) {
+	var actions []string
+	fixtures := restFixtureServer(t)
+	defer fixtures.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actions = append(actions, r.URL.Path)
+		fixtures.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	config := Config{Url: server.URL, Username: "admin", Password: "secret"}
+	c := &Client{rpc: newRestRPC(config, server.Client(), nil)}
+
+	tests := []struct {
+		method string
+		params map[string]interface{}
+		action string
+	}{
+		{"vm.start", map[string]interface{}{"id": "vm-1"}, "start"},
+		{"vm.restart", map[string]interface{}{"id": "vm-1"}, "clean_reboot"},
+		{"vm.restart", map[string]interface{}{"id": "vm-1", "force": true}, "hard_reboot"},
+		{"vm.stop", map[string]interface{}{"id": "vm-1", "force": true}, "hard_shutdown"},
+	}
+	for _, test := range tests {
+		actions = nil
+		var success bool
+		if err := c.Call(test.method, test.params, &success); err != nil || !success {
+			t.Errorf("expected %s with %v to succeed but received: %v", test.method, test.params, err)
+		}
+		expected := restApiPath + "/vms/vm-1/actions/" + test.action
+		if len(actions) != 1 || actions[0] != expected {
+			t.Errorf("expected %s with %v to run %s but received: %v", test.method, test.params, expected, actions)
+		}
+	}
+
+	var snapshotId string
+	if err := c.Call("vm.snapshot", map[string]interface{}{"id": "vm-1", "name": "base"}, &snapshotId); err != nil || snapshotId != "vm-1 snapshot base" {
+		t.Errorf("expected the id of the snapshot named base to be returned but received %s with error: %v", snapshotId, err)
+	}
+}
+
+func TestRestRPC_vmActionsWithUnmappedParams(t *testing.T) {
+	server := restFixtureServer(t)
+	defer server.Close()
+	config := Config{Url: server.URL, Username: "admin", Password: "secret"}
+
+	c := &Client{rpc: newRestRPC(config, server.Client(), nil)}
+	var unsupported UnsupportedOnThisServerError
+	err := c.Call("vm.start", map[string]interface{}{"id": "vm-1", "host": "host-2"}, nil)
+	if !errors.As(err, &unsupported) || !strings.Contains(unsupported.Reason, "host") {
+		t.Errorf("expected the host of vm.start to be reported as unsupported but received: %v", err)
+	}
+
+	fallback := &fakeRPC{}
+	c = &Client{rpc: newRestRPC(config, server.Client(), fallback)}
+	var success bool
+	if err := c.Call("vm.start", map[string]interface{}{"id": "vm-1", "host": "host-2"}, &success); err != nil {
+		t.Errorf("expected vm.start on a host to be sent over json rpc but received: %v", err)
+	}
+	if calls := fallback.callsTo("vm.start"); len(calls) != 1 || calls[0].params["host"] != "host-2" {
+		t.Errorf("expected vm.start on a host to be sent over json rpc but received calls: %v", fallback.methods())
+	}
+}
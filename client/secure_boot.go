@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// SecureBootKeys selects the keys of a VM's UEFI variable store.
+type SecureBootKeys string
+
+const (
+	// Copy the pool's default secure boot certificates to the VM.
+	SecureBootKeysDefault SecureBootKeys = "default"
+	// Clear the keys so that the VM boots in setup mode, where the guest
+	// enrolls its own custom keys.
+	SecureBootKeysReset SecureBootKeys = "reset"
+)
+
+// UEFI modes of XAPI's VM.set_uefi_mode for each SecureBootKeys.
+var secureBootUefiModes = map[SecureBootKeys]string{
+	SecureBootKeysDefault: "user",
+	SecureBootKeysReset:   "setup",
+}
+
+type SecureBootReadiness string
+
+const (
+	SecureBootReady           SecureBootReadiness = "ready"
+	SecureBootReadyNoDbx      SecureBootReadiness = "ready_no_dbx"
+	SecureBootSetupMode       SecureBootReadiness = "setup_mode"
+	SecureBootCertsIncomplete SecureBootReadiness = "certs_incomplete"
+)
+
+// Ready reports whether a VM can boot with secure boot enforced. A VM
+// without a revocation list (dbx) can boot but isn't fully protected.
+func (r SecureBootReadiness) Ready() bool {
+	return r == SecureBootReady || r == SecureBootReadyNoDbx
+}
+
+// SetVmSecureBootKeys enrolls the pool's default certificates in the UEFI
+// variable store of a halted VM, or clears its keys so that custom keys
+// can be enrolled from the guest.
+func (c *Client) SetVmSecureBootKeys(vmId string, keys SecureBootKeys) error {
+	mode, ok := secureBootUefiModes[keys]
+	if !ok {
+		return errors.New(fmt.Sprintf("unknown secure boot keys `%s`, expected `%s` or `%s`", keys, SecureBootKeysDefault, SecureBootKeysReset))
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id":   vmId,
+		"mode": mode,
+	}
+	return featureDetect("vm.setUefiMode", c.Call("vm.setUefiMode", params, &success))
+}
+
+// GetVmSecureBootReadiness reports whether the keys enrolled in the UEFI
+// variable store of a VM let it boot with secure boot.
+func (c *Client) GetVmSecureBootReadiness(vmId string) (SecureBootReadiness, error) {
+	var readiness SecureBootReadiness
+	params := map[string]interface{}{
+		"id": vmId,
+	}
+	err := c.Call("vm.getSecurebootReadiness", params, &readiness)
+	if err != nil {
+		return "", featureDetect("vm.getSecurebootReadiness", err)
+	}
+	return readiness, nil
+}
+
+// warnSecureBootNotReady logs a warning when secure boot is enabled on a
+// VM which has no keys to verify its bootloader with.
+func (c *Client) warnSecureBootNotReady(vmId string) {
+	readiness, err := c.GetVmSecureBootReadiness(vmId)
+	if err != nil {
+		log.Printf("[DEBUG] Failed to check the secure boot readiness of VM `%s`: %v\n", vmId, err)
+		return
+	}
+	if !readiness.Ready() {
+		log.Printf("[WARN] Secure boot is enabled on VM `%s` but its keys aren't enrolled (%s), the VM may fail to boot\n", vmId, readiness)
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestCreateVm_secureBootKeys(t *testing.T) {
+	tests := []struct {
+		keys SecureBootKeys
+		mode string
+	}{
+		{SecureBootKeysDefault, "user"},
+		{SecureBootKeysReset, "setup"},
+	}
+
+	for _, test := range tests {
+		rpc := fakeCreateVmRPC()
+		c := &Client{rpc: rpc}
+
+		vmReq := validVmRequest()
+		vmReq.WaitFor = WaitForTaskComplete
+		vmReq.Boot.Firmware = "uefi"
+		vmReq.SecureBoot = true
+		vmReq.SecureBootKeys = test.keys
+
+		if _, err := c.CreateVm(vmReq, time.Minute); err != nil {
+			t.Fatalf("failed to create VM with error: %v", err)
+		}
+
+		create := rpc.callsTo("vm.create")[0].params
+		if create["secureBoot"] != true || create["bootAfterCreate"] != false {
+			t.Errorf("expected a halted VM to be created with secure boot but received: %v", create)
+		}
+
+		setMode := rpc.callsTo("vm.setUefiMode")
+		if len(setMode) != 1 || setMode[0].params["id"] != "new-vm" || setMode[0].params["mode"] != test.mode {
+			t.Errorf("expected keys %s to set the UEFI mode to %s but received: %v", test.keys, test.mode, setMode)
+		}
+
+		methods := rpc.methods()
+		if methods[len(methods)-2] != "vm.start" {
+			t.Errorf("expected the VM to be started once its keys are enrolled but received calls: %v", methods)
+		}
+	}
+}
+
+func TestCreateVm_warnsWhenSecureBootKeysAreMissing(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	rpc := fakeCreateVmRPC()
+	handler := rpc.handler
+	rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "vm.getSecurebootReadiness" {
+			return SecureBootSetupMode, nil
+		}
+		return handler(method, params)
+	}
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.Boot.Firmware = "uefi"
+	vmReq.SecureBoot = true
+
+	if _, err := c.CreateVm(vmReq, time.Minute); err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "[WARN] Secure boot is enabled on VM `new-vm`") {
+		t.Errorf("expected a warning about the missing keys but received logs: %s", logs.String())
+	}
+	if len(rpc.callsTo("vm.setUefiMode")) != 0 {
+		t.Errorf("expected the template's keys to be kept")
+	}
+}
+
+func TestSetVmSecureBootKeys_unknownKeys(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := &Client{rpc: rpc}
+
+	if err := c.SetVmSecureBootKeys("vm-1", "custom"); err == nil {
+		t.Errorf("expected unknown keys to be rejected")
+	}
+	if len(rpc.methods()) != 0 {
+		t.Errorf("expected no call to be made but received: %v", rpc.methods())
+	}
+
+	rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeMethodNotFound, Message: "method not found"}
+	}
+	var unsupported UnsupportedOnThisServerError
+	if err := c.SetVmSecureBootKeys("vm-1", SecureBootKeysReset); !errors.As(err, &unsupported) {
+		t.Errorf("expected an UnsupportedOnThisServerError but received: %v", err)
+	}
+}
+
+func TestUpdateVm_secureBoot(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	tests := []struct {
+		actual  bool
+		desired bool
+		sent    interface{}
+	}{
+		{actual: false, desired: true, sent: true},
+		{actual: true, desired: false, sent: false},
+		{actual: true, desired: true, sent: nil},
+		{actual: false, desired: false, sent: nil},
+	}
+
+	for _, test := range tests {
+		c, rpc := cpuMaskClient(map[string]interface{}{"id": testUuid, "type": "VM", "power_state": "Halted", "secureBoot": test.actual, "CPUs": map[string]interface{}{"number": 1}, "memory": map[string]interface{}{"static": []int64{0, minVmMemory}}})
+
+		vmReq := Vm{Id: testUuid, Boot: Boot{Firmware: "uefi"}, CPUs: CPUs{Number: 1}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, SecureBoot: test.desired}
+		if _, err := c.UpdateVm(vmReq); err != nil {
+			t.Fatalf("failed to update VM with error: %v", err)
+		}
+
+		set := rpc.callsTo("vm.set")
+		if len(set) != 1 {
+			t.Fatalf("expected a single vm.set call but received: %v", set)
+		}
+		if sent, ok := set[0].params["secureBoot"]; sent != test.sent || ok != (test.sent != nil) {
+			t.Errorf("expected secure boot %v to be updated to %v by sending %v but received: %v", test.actual, test.desired, test.sent, set[0].params)
+		}
+	}
+}
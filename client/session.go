@@ -0,0 +1,40 @@
+package client
+
+import (
+	"log"
+)
+
+type Session struct {
+	User User
+}
+
+// SignOut ends the XO session while keeping the connection open. Any
+// further call requiring authentication fails until signing in again.
+func (c *Client) SignOut() error {
+	var success bool
+	params := map[string]interface{}{}
+	return c.Call("session.signOut", params, &success)
+}
+
+// Close signs out of XO and closes the connection.
+func (c *Client) Close() error {
+	err := c.SignOut()
+
+	if err != nil {
+		log.Printf("[WARN] Failed to sign out before closing the connection: %v\n", err)
+	}
+	return c.rpc.Close()
+}
+
+// SessionInfo returns the user the session is authenticated as.
+func (c *Client) SessionInfo() (*Session, error) {
+	var user User
+	params := map[string]interface{}{}
+	err := c.Call("session.getUser", params, &user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{User: user}, nil
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func TestSignOut_invalidatesAuthenticatedCalls(t *testing.T) {
+	signedIn := true
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch {
+		case method == "session.signOut":
+			signedIn = false
+			return true, nil
+		case !signedIn:
+			return nil, &jsonrpc2.Error{Code: 2, Message: "not authenticated"}
+		case method == "session.getUser":
+			return map[string]interface{}{"id": "user-1", "email": "admin@admin.net", "permission": "admin"}, nil
+		}
+		return nil, nil
+	}}
+	c := Client{rpc: rpc}
+
+	session, err := c.SessionInfo()
+	if err != nil {
+		t.Fatalf("failed to get session info with error: %v", err)
+	}
+	if session.User.Email != "admin@admin.net" || session.User.Permission != "admin" {
+		t.Errorf("expected session of admin@admin.net but received %+v", session)
+	}
+
+	if err := c.SignOut(); err != nil {
+		t.Fatalf("failed to sign out with error: %v", err)
+	}
+
+	if _, err := c.SessionInfo(); err == nil {
+		t.Errorf("expected calls made after signing out to fail")
+	}
+}
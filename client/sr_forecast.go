@@ -0,0 +1,121 @@
+package client
+
+import (
+	"fmt"
+)
+
+// Ratio of the virtual size of the disks to the size of a thin provisioned
+// SR ForecastSrUsage allows by default: no overcommit.
+const DefaultSrOvercommitRatio = 1.0
+
+// Types of the SRs storing disks as files, which only use the space
+// written by the VMs. The others allocate the whole size of the disks.
+var thinProvisionedSrTypes = []string{"ext", "nfs", "smb", "file", "xfs", "zfs", "glusterfs", "cephfs"}
+
+// DiskSpec is a disk planned on an SR.
+type DiskSpec struct {
+	NameLabel string
+	// Virtual size in bytes
+	Size int64
+}
+
+type ForecastSrUsageOptions struct {
+	// Ratio of the virtual size of the disks to the size of a thin
+	// provisioned SR above which the disks don't fit. Ratios below 1
+	// keep a safety margin. Defaults to DefaultSrOvercommitRatio, ignored
+	// for thick provisioned SRs.
+	OvercommitRatio float64
+}
+
+type Forecast struct {
+	SrId            string
+	ThinProvisioned bool
+	OvercommitRatio float64
+	// Size of the SR in bytes
+	Size int64
+	// Total size of the planned disks in bytes
+	Requested int64
+	// Usage of the SR in bytes, virtual being the total size of its disks,
+	// before and after creating the planned disks
+	Physical          int64
+	Virtual           int64
+	ProjectedPhysical int64
+	ProjectedVirtual  int64
+	// Whether the disks would be larger in total than the SR once created
+	Overcommitted bool
+	Fits          bool
+	// Why the disks don't fit, empty when they do
+	Reason string
+}
+
+// ForecastSrUsage is ForecastSrUsageWithOptions with the default
+// overcommit ratio.
+func (c *Client) ForecastSrUsage(srId string, additionalDisks []DiskSpec) (*Forecast, error) {
+	return c.ForecastSrUsageWithOptions(srId, additionalDisks, ForecastSrUsageOptions{})
+}
+
+// ForecastSrUsageWithOptions projects the usage of the SR once the planned
+// disks are created, to check they fit before provisioning them. On a
+// thick provisioned SR the disks take their whole size right away, they
+// fit when the SR has the space for them. On a thin provisioned SR the
+// disks only take the space written to them, they fit as long as the
+// virtual size of every disk of the SR stays within the overcommit ratio
+// of its size. Other disks created meanwhile aren't accounted for.
+func (c *Client) ForecastSrUsageWithOptions(srId string, additionalDisks []DiskSpec, opts ForecastSrUsageOptions) (*Forecast, error) {
+	if err := c.validateForecastSrUsage(additionalDisks, opts); err != nil {
+		return nil, err
+	}
+
+	var sr StorageRepository
+	if err := c.getObjectOfType("SR", srId, StorageRepository{Id: srId}, &sr); err != nil {
+		return nil, err
+	}
+
+	ratio := opts.OvercommitRatio
+	if ratio == 0 {
+		ratio = DefaultSrOvercommitRatio
+	}
+	forecast := &Forecast{
+		SrId:            srId,
+		ThinProvisioned: stringInSlice(sr.SRType, thinProvisionedSrTypes),
+		OvercommitRatio: ratio,
+		Size:            sr.Size,
+		Physical:        sr.PhysicalUsage,
+		Virtual:         sr.Usage,
+	}
+	for _, disk := range additionalDisks {
+		forecast.Requested += disk.Size
+	}
+	forecast.ProjectedVirtual = forecast.Virtual + forecast.Requested
+	forecast.ProjectedPhysical = forecast.Physical
+	if !forecast.ThinProvisioned {
+		forecast.ProjectedPhysical += forecast.Requested
+	}
+	forecast.Overcommitted = forecast.ProjectedVirtual > forecast.Size
+
+	switch {
+	case !forecast.ThinProvisioned && forecast.ProjectedPhysical > forecast.Size:
+		forecast.Reason = fmt.Sprintf("the disks need %d bytes but SR `%s` only has %d bytes free", forecast.Requested, sr.NameLabel, forecast.Size-forecast.Physical)
+	case forecast.ThinProvisioned && float64(forecast.ProjectedVirtual) > float64(forecast.Size)*ratio:
+		forecast.Reason = fmt.Sprintf("the disks of SR `%s` would total %d bytes, over %g times its size of %d bytes", sr.NameLabel, forecast.ProjectedVirtual, ratio, forecast.Size)
+	}
+	forecast.Fits = forecast.Reason == ""
+	return forecast, nil
+}
+
+func (c *Client) validateForecastSrUsage(disks []DiskSpec, opts ForecastSrUsageOptions) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	for i, disk := range disks {
+		if disk.Size <= 0 {
+			v.addf(fmt.Sprintf("additionalDisks[%d].Size", i), "must be positive, got %d", disk.Size)
+		}
+	}
+	if opts.OvercommitRatio < 0 {
+		v.addf("OvercommitRatio", "must not be negative, got %g", opts.OvercommitRatio)
+	}
+	return v.err()
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fakeForecastRPC() *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params,
+			map[string]interface{}{"id": "sr-nfs", "type": "SR", "name_label": "nfs", "SR_type": "nfs", "size": 1000 * gib, "usage": 900 * gib, "physical_usage": 300 * gib},
+			map[string]interface{}{"id": "sr-lvm", "type": "SR", "name_label": "lvm", "SR_type": "lvmoiscsi", "size": 1000 * gib, "usage": 800 * gib, "physical_usage": 800 * gib},
+		), nil
+	}}
+}
+
+func TestForecastSrUsage_thinProvisionedOvercommit(t *testing.T) {
+	c := &Client{rpc: fakeForecastRPC()}
+	disks := []DiskSpec{{NameLabel: "db", Size: 200 * gib}, {NameLabel: "logs", Size: 100 * gib}}
+
+	forecast, err := c.ForecastSrUsage("sr-nfs", disks)
+	if err != nil {
+		t.Fatalf("failed to forecast the usage of the SR with error: %v", err)
+	}
+	if !forecast.ThinProvisioned || forecast.Requested != 300*gib || forecast.ProjectedVirtual != 1200*gib || forecast.ProjectedPhysical != 300*gib {
+		t.Errorf("expected the thin disks to only add to the virtual usage but received %+v", forecast)
+	}
+	if forecast.Fits || !forecast.Overcommitted || !strings.Contains(forecast.Reason, "over 1 times its size") {
+		t.Errorf("expected the overcommit to be flagged without an overcommit ratio but received %+v", forecast)
+	}
+
+	forecast, err = c.ForecastSrUsageWithOptions("sr-nfs", disks, ForecastSrUsageOptions{OvercommitRatio: 1.5})
+	if err != nil || !forecast.Fits || !forecast.Overcommitted || forecast.Reason != "" {
+		t.Errorf("expected the disks to fit within an overcommit ratio of 1.5 but received %+v with error: %v", forecast, err)
+	}
+}
+
+func TestForecastSrUsage_thickProvisioned(t *testing.T) {
+	c := &Client{rpc: fakeForecastRPC()}
+
+	forecast, err := c.ForecastSrUsageWithOptions("sr-lvm", []DiskSpec{{Size: 150 * gib}}, ForecastSrUsageOptions{OvercommitRatio: 3})
+	if err != nil || !forecast.Fits || forecast.ThinProvisioned || forecast.ProjectedPhysical != 950*gib {
+		t.Errorf("expected the disk to fit in the free space of the SR but received %+v with error: %v", forecast, err)
+	}
+
+	forecast, err = c.ForecastSrUsageWithOptions("sr-lvm", []DiskSpec{{Size: 150 * gib}, {Size: 100 * gib}}, ForecastSrUsageOptions{OvercommitRatio: 3})
+	if err != nil || forecast.Fits || forecast.ProjectedPhysical != 1050*gib {
+		t.Errorf("expected the overcommit ratio to be ignored on a thick provisioned SR but received %+v with error: %v", forecast, err)
+	}
+}
+
+func TestForecastSrUsage_invalid(t *testing.T) {
+	rpc := fakeForecastRPC()
+	c := &Client{rpc: rpc}
+
+	_, err := c.ForecastSrUsageWithOptions("sr-nfs", []DiskSpec{{Size: gib}, {Size: 0}}, ForecastSrUsageOptions{OvercommitRatio: -1})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"additionalDisks[1].Size", "OvercommitRatio"}) {
+		t.Errorf("expected the empty disk and the negative ratio to be rejected but received: %v", err)
+	}
+	if len(rpc.methods()) != 0 {
+		t.Errorf("expected no call for an invalid forecast but received: %v", rpc.methods())
+	}
+}
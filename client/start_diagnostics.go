@@ -0,0 +1,134 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+type StartFailureReason string
+
+const (
+	StartFailureUnknown                 StartFailureReason = "unknown"
+	StartFailureNoHostsAvailable        StartFailureReason = "NO_HOSTS_AVAILABLE"
+	StartFailureHostNotEnoughFreeMemory StartFailureReason = "HOST_NOT_ENOUGH_FREE_MEMORY"
+	StartFailureVmRequiresSr            StartFailureReason = "VM_REQUIRES_SR"
+	StartFailureBootloaderFailed        StartFailureReason = "BOOTLOADER_FAILED"
+)
+
+var classifiedStartFailures = []StartFailureReason{
+	StartFailureNoHostsAvailable,
+	StartFailureHostNotEnoughFreeMemory,
+	StartFailureVmRequiresSr,
+	StartFailureBootloaderFailed,
+}
+
+type HostCandidate struct {
+	Id         string
+	NameLabel  string
+	FreeMemory int64
+}
+
+type StartResult struct {
+	Started bool
+
+	// The fields below are only set when the VM failed to start
+	Reason StartFailureReason
+	// XAPI error code and parameters as returned by XO
+	XapiCode   string
+	XapiParams []string
+	// Memory the VM needs to boot
+	RequiredMemory int64
+	// Hosts of the VM's pool the VM could have started on
+	Hosts []HostCandidate
+	Disks []Disk
+}
+
+// StartVmWithDiagnostics starts a VM like StartVm. When vm.start fails,
+// the XAPI error is classified and the returned StartResult is filled with
+// the context needed to explain the failure: the pool's hosts and their
+// free memory and the VM's disks with their bootable and attached state.
+// The vm.start error is returned alongside the result.
+func (c *Client) StartVmWithDiagnostics(vmId string) (*StartResult, error) {
+	params := map[string]interface{}{
+		"id": vmId,
+	}
+	var success bool
+	startErr := c.Call("vm.start", params, &success)
+
+	if startErr == nil {
+		err := c.waitForVmPowerState(vmId, []PowerState{PowerStateHalted}, PowerStateRunning, 2*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		return &StartResult{Started: true}, nil
+	}
+
+	result := &StartResult{}
+	result.XapiCode, result.XapiParams = xapiErrorCode(startErr)
+	result.Reason = classifyStartFailure(result.XapiCode, startErr)
+
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return result, startErr
+	}
+	result.RequiredMemory = vmMemoryMax(*vm)
+
+	hosts, err := c.GetSortedHosts(Host{Pool: vm.PoolId}, sortFieldNameLabel, sortOrderAsc)
+	if err == nil {
+		for _, host := range hosts {
+			if host.Pool != vm.PoolId {
+				continue
+			}
+			result.Hosts = append(result.Hosts, HostCandidate{
+				Id:         host.Id,
+				NameLabel:  host.NameLabel,
+				FreeMemory: host.Memory.Size - host.Memory.Usage,
+			})
+		}
+	}
+
+	disks, err := c.GetDisks(vm)
+	if err == nil {
+		result.Disks = disks
+	}
+
+	return result, startErr
+}
+
+// xapiErrorCode extracts the XAPI error code XO includes in the data of
+// its json rpc errors.
+func xapiErrorCode(err error) (string, []string) {
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Data == nil {
+		return "", nil
+	}
+
+	var data struct {
+		Code   string   `json:"code"`
+		Params []string `json:"params"`
+	}
+	if json.Unmarshal(*rpcErr.Data, &data) != nil {
+		return "", nil
+	}
+	return data.Code, data.Params
+}
+
+func classifyStartFailure(code string, err error) StartFailureReason {
+	for _, reason := range classifiedStartFailures {
+		if code == string(reason) {
+			return reason
+		}
+	}
+
+	// Older XO versions only report the XAPI error in the message
+	for _, reason := range classifiedStartFailures {
+		if strings.Contains(err.Error(), string(reason)) {
+			return reason
+		}
+	}
+	return StartFailureUnknown
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+func xapiError(code string, params ...string) error {
+	data, _ := json.Marshal(map[string]interface{}{"code": code, "params": params})
+	raw := json.RawMessage(data)
+	return &jsonrpc2.Error{Code: -32000, Message: "unknown error from the peer", Data: &raw}
+}
+
+func TestStartVmWithDiagnostics_classifiesFailures(t *testing.T) {
+	gib := int64(1 << 30)
+	objects := []map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "$poolId": "pool-1", "memory": map[string]interface{}{"static": []int64{0, 8 * gib}, "size": 8 * gib}},
+		{"id": "host-1", "type": "host", "name_label": "a", "$pool": "pool-1", "memory": map[string]interface{}{"size": 16 * gib, "usage": 12 * gib}},
+		{"id": "host-2", "type": "host", "name_label": "b", "$pool": "pool-1", "memory": map[string]interface{}{"size": 16 * gib, "usage": 10 * gib}},
+		{"id": "host-3", "type": "host", "name_label": "c", "$pool": "pool-2", "memory": map[string]interface{}{"size": 64 * gib}},
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "vdi-1", "bootable": false, "attached": false},
+		{"id": "vdi-1", "type": "VDI", "name_label": "root", "$SR": "sr-1"},
+	}
+
+	tests := []struct {
+		err    error
+		reason StartFailureReason
+	}{
+		{xapiError("NO_HOSTS_AVAILABLE"), StartFailureNoHostsAvailable},
+		{xapiError("HOST_NOT_ENOUGH_FREE_MEMORY", "8589934592", "4294967296"), StartFailureHostNotEnoughFreeMemory},
+		{xapiError("VM_REQUIRES_SR", "vm-1", "sr-1"), StartFailureVmRequiresSr},
+		{xapiError("BOOTLOADER_FAILED", "vm-1", "no bootable disk"), StartFailureBootloaderFailed},
+		{&jsonrpc2.Error{Code: -32000, Message: "BOOTLOADER_FAILED(vm-1, no bootable disk)"}, StartFailureBootloaderFailed},
+		{xapiError("INTERNAL_ERROR"), StartFailureUnknown},
+	}
+
+	for _, test := range tests {
+		startErr := test.err
+		c := Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+			if method == "vm.start" {
+				return nil, startErr
+			}
+			return fakeGetAllObjects(params, objects...), nil
+		}}}
+
+		result, err := c.StartVmWithDiagnostics("vm-1")
+		if err == nil {
+			t.Fatalf("expected the vm.start error to be returned")
+		}
+
+		if result.Started || result.Reason != test.reason {
+			t.Errorf("expected failure reason %s but received %s for error: %v", test.reason, result.Reason, test.err)
+		}
+
+		if result.RequiredMemory != 8*gib {
+			t.Errorf("expected required memory of %d but received %d", 8*gib, result.RequiredMemory)
+		}
+
+		expectedHosts := []HostCandidate{
+			{Id: "host-1", NameLabel: "a", FreeMemory: 4 * gib},
+			{Id: "host-2", NameLabel: "b", FreeMemory: 6 * gib},
+		}
+		if len(result.Hosts) != len(expectedHosts) || result.Hosts[0] != expectedHosts[0] || result.Hosts[1] != expectedHosts[1] {
+			t.Errorf("expected candidate hosts %+v but received %+v", expectedHosts, result.Hosts)
+		}
+
+		if len(result.Disks) != 1 || result.Disks[0].Bootable || result.Disks[0].NameLabel != "root" {
+			t.Errorf("expected the vm's non bootable root disk to be reported but received %+v", result.Disks)
+		}
+	}
+}
@@ -11,10 +11,13 @@ package client
 // will likely need to be reconsidered
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
 	"time"
+
+	"github.com/vatesfr/xo-sdk-go/client/wait"
 )
 
 var refreshGracePeriod = 30 * time.Second
@@ -31,6 +34,12 @@ var refreshGracePeriod = 30 * time.Second
 // may have happened while refreshing the state.
 type StateRefreshFunc func() (result interface{}, state string, err error)
 
+// waitDescriber is implemented by the objects waited for, e.g. *Vm and
+// *Task, to describe their state in a TimeoutError.
+type waitDescriber interface {
+	describeWait() string
+}
+
 // StateChangeConf is the configuration struct used for `WaitForState`.
 type StateChangeConf struct {
 	Delay          time.Duration    // Wait this time before starting checks
@@ -62,6 +71,7 @@ type StateChangeConf struct {
 // reach the target state.
 func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 	log.Printf("[DEBUG] Waiting for state to become: %s", conf.Target)
+	start := time.Now()
 
 	notfoundTick := 0
 	targetOccurence := 0
@@ -80,6 +90,7 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 		State  string
 		Error  error
 		Done   bool
+		Polls  int
 	}
 
 	// Read every result from the refresh loop, waiting for a positive result.Done.
@@ -95,7 +106,12 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 		time.Sleep(conf.Delay)
 
 		// start with 0 delay for the first loop
-		var wait time.Duration
+		var delay time.Duration
+		polls := 0
+		backoff := wait.Backoff{
+			Initial: 200 * time.Millisecond,
+			Max:     10 * time.Second,
+		}
 
 		for {
 			// store the last result
@@ -105,18 +121,16 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 			select {
 			case <-cancelCh:
 				return
-			case <-time.After(wait):
-				// first round had no wait
-				if wait == 0 {
-					wait = 100 * time.Millisecond
-				}
+			case <-time.After(delay):
 			}
 
 			res, currentState, err := conf.Refresh()
+			polls++
 			result = Result{
 				Result: res,
 				State:  currentState,
 				Error:  err,
+				Polls:  polls,
 			}
 
 			if err != nil {
@@ -186,28 +200,32 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 
 			// Wait between refreshes using exponential backoff, except when
 			// waiting for the target state to reoccur.
-			if targetOccurence == 0 {
-				wait *= 2
+			if targetOccurence == 0 || delay == 0 {
+				delay = backoff.Next()
 			}
 
 			// If a poll interval has been specified, choose that interval.
 			// Otherwise bound the default value.
 			if conf.PollInterval > 0 && conf.PollInterval < 180*time.Second {
-				wait = conf.PollInterval
-			} else {
-				if wait < conf.MinTimeout {
-					wait = conf.MinTimeout
-				} else if wait > 10*time.Second {
-					wait = 10 * time.Second
-				}
+				delay = conf.PollInterval
+			} else if delay < conf.MinTimeout {
+				delay = conf.MinTimeout
 			}
 
-			log.Printf("[TRACE] Waiting %s before next try", wait)
+			log.Printf("[TRACE] Waiting %s before next try", delay)
 		}
 	}()
 
-	// store the last value result from the refresh loop
+	// store the last value result from the refresh loop, and the last
+	// object successfully refreshed for the TimeoutError
 	lastResult := Result{}
+	var lastObserved interface{}
+	observe := func(r Result) {
+		lastResult = r
+		if r.Error == nil && r.Result != nil {
+			lastObserved = r.Result
+		}
+	}
 
 	timeout := time.After(conf.Timeout)
 	for {
@@ -224,7 +242,7 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 			}
 
 			// still waiting, store the last result
-			lastResult = r
+			observe(r)
 
 		case <-timeout:
 			log.Printf("[WARN] WaitForState timeout after %s", conf.Timeout)
@@ -253,7 +271,7 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 
 					// target state not reached, save the result for the
 					// TimeoutError and wait for the channel to close
-					lastResult = r
+					observe(r)
 				case <-timeout:
 					log.Println("[ERROR] WaitForState exceeded refresh grace period")
 					break forSelect
@@ -265,6 +283,9 @@ func (conf *StateChangeConf) WaitForState() (interface{}, error) {
 				LastState:     lastResult.State,
 				Timeout:       conf.Timeout,
 				ExpectedState: conf.Target,
+				LastResult:    lastObserved,
+				Polls:         lastResult.Polls,
+				Elapsed:       time.Since(start),
 			}
 		}
 	}
@@ -306,12 +327,26 @@ func (e *UnexpectedStateError) Error() string {
 	)
 }
 
-// TimeoutError is returned when WaitForState times out
+// TimeoutError is returned when WaitForState or WaitForTask times out. It
+// matches context.DeadlineExceeded with errors.Is.
 type TimeoutError struct {
 	LastError     error
 	LastState     string
 	Timeout       time.Duration
 	ExpectedState []string
+	// Last object observed, e.g. a *Vm or a *Task, nil if none was
+	LastResult interface{}
+	// Number of times the object was refreshed
+	Polls   int
+	Elapsed time.Duration
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.LastError
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
 }
 
 func (e *TimeoutError) Error() string {
@@ -327,6 +362,12 @@ func (e *TimeoutError) Error() string {
 	if e.Timeout > 0 {
 		extraInfo = append(extraInfo, fmt.Sprintf("timeout: %s", e.Timeout.String()))
 	}
+	if e.Polls > 0 {
+		extraInfo = append(extraInfo, fmt.Sprintf("%d polls in %s", e.Polls, e.Elapsed.Round(time.Millisecond)))
+	}
+	if d, ok := e.LastResult.(waitDescriber); ok {
+		extraInfo = append(extraInfo, fmt.Sprintf("last seen: %s", d.describeWait()))
+	}
 
 	suffix := ""
 	if len(extraInfo) > 0 {
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/vatesfr/xo-sdk-go/client/wait"
+)
+
+// stateWait waits for an object to reach a state with the primitives of
+// the wait package, the states and errors are those of StateChangeConf.
+type stateWait struct {
+	Pending []string
+	Refresh StateRefreshFunc
+	Target  []string
+	Timeout time.Duration
+}
+
+// wait refreshes the object until its state is one of Target and returns
+// the last result. A refresh error or a state which is neither pending
+// nor targeted stops the wait, a *TimeoutError describing the last
+// refresh is returned once Timeout or the deadline of ctx elapses.
+func (w stateWait) wait(ctx context.Context) (interface{}, error) {
+	log.Printf("[DEBUG] Waiting for state to become: %s", w.Target)
+
+	var result interface{}
+	var lastState string
+	err := wait.Until(ctx, func(ctx context.Context) (bool, error) {
+		res, state, err := w.Refresh()
+		if err != nil {
+			return false, wait.Permanent(err)
+		}
+		result = res
+		lastState = state
+		if stringInSlice(state, w.Target) {
+			return true, nil
+		}
+		if !stringInSlice(state, w.Pending) {
+			return false, wait.Permanent(&UnexpectedStateError{State: state, ExpectedState: w.Target})
+		}
+		return false, nil
+	}, wait.UntilOptions{
+		Backoff: wait.Backoff{Initial: 200 * time.Millisecond, Max: 10 * time.Second},
+		Timeout: w.Timeout,
+	})
+
+	var stopped *wait.StoppedError
+	if errors.As(err, &stopped) && errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("[WARN] Waiting for state %s stopped after %s", w.Target, stopped.Elapsed)
+		return nil, &TimeoutError{
+			LastError:     stopped.LastError,
+			LastState:     lastState,
+			Timeout:       w.Timeout,
+			ExpectedState: w.Target,
+			LastResult:    result,
+			Polls:         stopped.Attempts,
+			Elapsed:       stopped.Elapsed,
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStateWait_stopsOnUnexpectedStateOrError(t *testing.T) {
+	refreshErr := errors.New("connection reset")
+	tests := []struct {
+		name   string
+		states []string
+		err    error
+		check  func(error) bool
+	}{
+		{
+			name:   "target reached",
+			states: []string{"pending", "pending", "done"},
+			check:  func(err error) bool { return err == nil },
+		},
+		{
+			name:   "unexpected state",
+			states: []string{"pending", "failed"},
+			check: func(err error) bool {
+				var unexpected *UnexpectedStateError
+				return errors.As(err, &unexpected) && unexpected.State == "failed"
+			},
+		},
+		{
+			name:   "refresh error",
+			states: []string{"pending"},
+			err:    refreshErr,
+			check:  func(err error) bool { return errors.Is(err, refreshErr) },
+		},
+	}
+	for _, test := range tests {
+		refreshes := 0
+		waiter := stateWait{
+			Pending: []string{"pending"},
+			Target:  []string{"done"},
+			Timeout: 10 * time.Second,
+			Refresh: func() (interface{}, string, error) {
+				refreshes++
+				if refreshes > len(test.states) {
+					return nil, "", test.err
+				}
+				return refreshes, test.states[refreshes-1], nil
+			},
+		}
+
+		_, err := waiter.wait(context.Background())
+		if !test.check(err) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		}
+		if refreshes > len(test.states)+1 {
+			t.Errorf("%s: expected the wait to stop but refreshed %d times", test.name, refreshes)
+		}
+	}
+}
@@ -7,15 +7,21 @@ import (
 )
 
 type StorageRepository struct {
-	Id            string   `json:"id"`
-	Uuid          string   `json:"uuid"`
-	NameLabel     string   `json:"name_label"`
-	PoolId        string   `json:"$poolId"`
-	SRType        string   `json:"SR_type"`
+	Id        string `json:"id"`
+	Uuid      string `json:"uuid"`
+	XapiRef   string `json:"_xapiRef,omitempty"`
+	NameLabel string `json:"name_label"`
+	PoolId    string `json:"$poolId"`
+	SRType    string `json:"SR_type"`
+	// `iso` for the SRs holding ISOs
+	ContentType string `json:"content_type"`
+	// Set on the SR holding the guest tools ISO by recent XAPI versions
+	IsToolsSr     bool     `json:"is_tools_sr"`
 	Container     string   `json:"$container"`
-	PhysicalUsage int      `json:"physical_usage"`
-	Size          int      `json:"size"`
-	Usage         int      `json:"usage"`
+	PhysicalUsage int64    `json:"physical_usage"`
+	Size          int64    `json:"size"`
+	Usage         int64    `json:"usage"`
+	Shared        bool     `json:"shared"`
 	Tags          []string `json:"tags,omitempty"`
 }
 
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// XO object types a VDI of a VM or of one of its snapshots can have. Base
+// copies shared by a snapshot chain are reported as VDI-unmanaged.
+var vdiObjectTypes = []string{"VDI", "VDI-snapshot", "VDI-unmanaged"}
+
+type VdiStorageUsage struct {
+	VDIId     string
+	Uuid      string
+	NameLabel string
+	SrId      string
+	Type      string
+	// Space used on the SR in bytes
+	Physical int64
+	// Size of the disk as seen by the VM in bytes
+	Virtual int64
+}
+
+type SrStorageUsage struct {
+	SrId     string
+	Physical int64
+	Virtual  int64
+}
+
+type VmStorageUsage struct {
+	VmId  string
+	Disks []VdiStorageUsage
+	Srs   []SrStorageUsage
+	// Totals across every SR
+	Physical int64
+	Virtual  int64
+}
+
+func (c *Client) getAllObjectsOfXoType(xoType string, response interface{}) error {
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type": xoType,
+		},
+	}
+	return c.Call("xo.getAllObjects", params, response)
+}
+
+// getObjectsMatching lists the objects matching filter, whose values can
+// be a single value or anyOf several.
+func (c *Client) getObjectsMatching(ctx context.Context, filter map[string]interface{}, response interface{}) error {
+	params := map[string]interface{}{
+		"filter": filter,
+	}
+	return c.callContext(ctx, "xo.getAllObjects", params, response)
+}
+
+// anyOf is the pattern of XO filters matching any of the values.
+func anyOf(values []string) map[string]interface{} {
+	return map[string]interface{}{"__or": values}
+}
+
+// GetVmStorageUsage reports the SR space consumed by a VM's disks, the disks
+// of its snapshots and the parent VDIs of their chains. Every VDI is only
+// counted once, even when shared by several snapshots.
+func (c *Client) GetVmStorageUsage(vmId string) (*VmStorageUsage, error) {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return nil, err
+	}
+	vmIds := append([]string{vm.Id}, vm.Snapshots...)
+
+	vbds := map[string]VBD{}
+	err = c.getAllObjectsOfXoType("VBD", &vbds)
+	if err != nil {
+		return nil, err
+	}
+
+	vdis := map[string]VDI{}
+	for _, xoType := range vdiObjectTypes {
+		objs := map[string]VDI{}
+		err = c.getAllObjectsOfXoType(xoType, &objs)
+		if err != nil {
+			return nil, err
+		}
+
+		for id, vdi := range objs {
+			vdis[id] = vdi
+		}
+	}
+
+	usage := &VmStorageUsage{VmId: vm.Id}
+	seen := map[string]bool{}
+	srs := map[string]*SrStorageUsage{}
+	for _, vbd := range vbds {
+		if vbd.IsCdDrive || !stringInSlice(vbd.VmId, vmIds) {
+			continue
+		}
+
+		// Walk up the chain so that base copies are accounted for
+		for id := vbd.VDI; id != ""; {
+			vdi, ok := vdis[id]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("failed to find VDI `%s` referenced by vm `%s`", id, vm.Id))
+			}
+			id = vdi.Parent
+
+			key := vdi.Uuid
+			if key == "" {
+				key = vdi.VDIId
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			usage.Disks = append(usage.Disks, VdiStorageUsage{
+				VDIId:     vdi.VDIId,
+				Uuid:      vdi.Uuid,
+				NameLabel: vdi.NameLabel,
+				SrId:      vdi.SrId,
+				Type:      vdi.Type,
+				Physical:  vdi.Usage,
+				Virtual:   int64(vdi.Size),
+			})
+
+			sr, ok := srs[vdi.SrId]
+			if !ok {
+				sr = &SrStorageUsage{SrId: vdi.SrId}
+				srs[vdi.SrId] = sr
+			}
+			sr.Physical += vdi.Usage
+			sr.Virtual += int64(vdi.Size)
+			usage.Physical += vdi.Usage
+			usage.Virtual += int64(vdi.Size)
+		}
+	}
+
+	for _, sr := range srs {
+		usage.Srs = append(usage.Srs, *sr)
+	}
+	sort.Slice(usage.Disks, func(i, j int) bool {
+		return usage.Disks[i].VDIId < usage.Disks[j].VDIId
+	})
+	sort.Slice(usage.Srs, func(i, j int) bool {
+		return usage.Srs[i].SrId < usage.Srs[j].SrId
+	})
+	return usage, nil
+}
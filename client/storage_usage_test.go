@@ -0,0 +1,60 @@
+package client
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetVmStorageUsage_deduplicatesSnapshotChain(t *testing.T) {
+	gib := int64(1 << 30)
+	objects := []map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "snapshots": []string{"snap-1", "snap-2", "snap-3"}},
+		// The VM's disk and its three snapshots share a single base copy
+		{"id": "vbd-vm", "type": "VBD", "VM": "vm-1", "VDI": "vdi-active"},
+		{"id": "vbd-cd", "type": "VBD", "VM": "vm-1", "VDI": "vdi-iso", "is_cd_drive": true},
+		{"id": "vbd-s1", "type": "VBD", "VM": "snap-1", "VDI": "vdi-s1"},
+		{"id": "vbd-s2", "type": "VBD", "VM": "snap-2", "VDI": "vdi-s2"},
+		{"id": "vbd-s3", "type": "VBD", "VM": "snap-3", "VDI": "vdi-s3"},
+		{"id": "vbd-other", "type": "VBD", "VM": "vm-2", "VDI": "vdi-other"},
+		{"id": "vdi-active", "uuid": "u-active", "type": "VDI", "$SR": "sr-1", "size": 10 * gib, "usage": 2 * gib, "parent": "vdi-base"},
+		{"id": "vdi-data", "uuid": "u-data", "type": "VDI", "$SR": "sr-2", "size": 5 * gib, "usage": 5 * gib},
+		{"id": "vdi-other", "uuid": "u-other", "type": "VDI", "$SR": "sr-1", "size": gib, "usage": gib},
+		{"id": "vdi-s1", "uuid": "u-s1", "type": "VDI-snapshot", "$SR": "sr-1", "size": 10 * gib, "usage": gib, "parent": "vdi-base"},
+		{"id": "vdi-s2", "uuid": "u-s2", "type": "VDI-snapshot", "$SR": "sr-1", "size": 10 * gib, "usage": gib, "parent": "vdi-base"},
+		{"id": "vdi-s3", "uuid": "u-s3", "type": "VDI-snapshot", "$SR": "sr-1", "size": 10 * gib, "usage": gib, "parent": "vdi-base"},
+		{"id": "vdi-base", "uuid": "u-base", "type": "VDI-unmanaged", "$SR": "sr-1", "size": 10 * gib, "usage": 4 * gib},
+	}
+	// The data disk is attached to the VM as well
+	objects = append(objects, map[string]interface{}{"id": "vbd-data", "type": "VBD", "VM": "vm-1", "VDI": "vdi-data"})
+
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, objects...), nil
+	}}
+	c := Client{rpc: rpc}
+
+	usage, err := c.GetVmStorageUsage("vm-1")
+	if err != nil {
+		t.Fatalf("failed to get vm storage usage with error: %v", err)
+	}
+
+	disks := []string{}
+	for _, disk := range usage.Disks {
+		disks = append(disks, disk.VDIId)
+	}
+	expectedDisks := []string{"vdi-active", "vdi-base", "vdi-data", "vdi-s1", "vdi-s2", "vdi-s3"}
+	if !reflect.DeepEqual(disks, expectedDisks) {
+		t.Errorf("expected disks %v but received %v", expectedDisks, disks)
+	}
+
+	expectedSrs := []SrStorageUsage{
+		{SrId: "sr-1", Physical: 9 * gib, Virtual: 50 * gib},
+		{SrId: "sr-2", Physical: 5 * gib, Virtual: 5 * gib},
+	}
+	if !reflect.DeepEqual(usage.Srs, expectedSrs) {
+		t.Errorf("expected per SR usage %+v but received %+v", expectedSrs, usage.Srs)
+	}
+
+	if usage.Physical != 14*gib || usage.Virtual != 55*gib {
+		t.Errorf("expected totals of %d physical and %d virtual but received %d and %d", 14*gib, 55*gib, usage.Physical, usage.Virtual)
+	}
+}
@@ -34,11 +34,64 @@ func (c *Client) RemoveTag(id, tag string) error {
 	return nil
 }
 
+// SetTags makes the tags of the object exactly tags. Only the missing tags
+// are added and the extra ones removed, the others are left untouched.
+func (c *Client) SetTags(objectId string, tags []string) error {
+	current, err := c.getObjectTags(objectId)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if stringInSlice(tag, current) {
+			continue
+		}
+		if err := c.AddTag(objectId, tag); err != nil {
+			return err
+		}
+	}
+	for _, tag := range current {
+		if stringInSlice(tag, tags) {
+			continue
+		}
+		if err := c.RemoveTag(objectId, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getObjectTags returns the tags of an object of any type.
+func (c *Client) getObjectTags(objectId string) ([]string, error) {
+	var objsRes map[string]struct {
+		Tags []string `json:"tags"`
+	}
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"id": objectId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := objsRes[objectId]
+	if !ok {
+		return nil, NotFound{Query: Object{Id: objectId}}
+	}
+	return obj.Tags, nil
+}
+
 type Object struct {
 	Id   string
 	Type string
 }
 
+func (o Object) Compare(obj interface{}) bool {
+	other := obj.(Object)
+	return other.Id == o.Id
+}
+
 func (c *Client) GetObjectsWithTags(tags []string) ([]Object, error) {
 	var objsRes struct {
 		Objects map[string]interface{} `json:"-"`
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+type TagReport struct {
+	PoolId string
+	// Number of VMs in the pool
+	Vms int
+	// Ids of the VMs without any tag starting with each required prefix,
+	// sorted
+	Missing map[string][]string
+	// Ids of the VMs with several tags starting with each required
+	// prefix, sorted
+	Duplicates map[string][]string
+	// Number of VMs carrying each tag
+	Frequency map[string]int
+}
+
+// GetTagReport checks the tags of the VMs of the pool, e.g. that each VM
+// carries exactly one tag starting with every prefix of requiredPrefixes
+// such as `env:` and `owner:`. VM templates and snapshots aren't checked.
+func (c *Client) GetTagReport(poolId string, requiredPrefixes []string) (*TagReport, error) {
+	var vmsRes map[string]Vm
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":    "VM",
+			"$poolId": poolId,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &vmsRes); err != nil {
+		return nil, err
+	}
+
+	report := &TagReport{
+		PoolId:     poolId,
+		Vms:        len(vmsRes),
+		Missing:    map[string][]string{},
+		Duplicates: map[string][]string{},
+		Frequency:  map[string]int{},
+	}
+	for _, prefix := range requiredPrefixes {
+		report.Missing[prefix] = []string{}
+		report.Duplicates[prefix] = []string{}
+	}
+
+	for _, id := range sortedKeys(vmsRes) {
+		tags := vmsRes[id].Tags
+		seen := map[string]bool{}
+		for _, tag := range tags {
+			// XO doesn't let a tag be added twice, but don't count it twice
+			// if it were
+			if !seen[tag] {
+				seen[tag] = true
+				report.Frequency[tag]++
+			}
+		}
+
+		for _, prefix := range requiredPrefixes {
+			matches := 0
+			for tag := range seen {
+				if strings.HasPrefix(tag, prefix) {
+					matches++
+				}
+			}
+			switch {
+			case matches == 0:
+				report.Missing[prefix] = append(report.Missing[prefix], id)
+			case matches > 1:
+				report.Duplicates[prefix] = append(report.Duplicates[prefix], id)
+			}
+		}
+	}
+	return report, nil
+}
+
+// TagChange describes the tags to add to and remove from an object of any
+// type.
+type TagChange struct {
+	ObjectId string
+	Add      []string
+	Remove   []string
+}
+
+type TagChangeResult struct {
+	ObjectId string
+	// Tags the object didn't carry and were added, or would be in a dry
+	// run
+	Added []string
+	// Tags the object carried and were removed, or would be in a dry run
+	Removed []string
+	Err     error
+}
+
+type RetagResult struct {
+	DryRun bool
+	// Result of each change, in the order of the changes
+	Results []TagChangeResult
+}
+
+// Failed returns the results of the changes which failed.
+func (r RetagResult) Failed() []TagChangeResult {
+	failed := []TagChangeResult{}
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// BulkRetag applies the tag changes concurrently, only adding the tags an
+// object doesn't carry and removing those it does. Every change is
+// attempted, an error is returned alongside the result when any of them
+// failed. With dryRun, the tags of the objects are read to compute the
+// same result but nothing is changed.
+func (c *Client) BulkRetag(changes []TagChange, dryRun bool) (*RetagResult, error) {
+	if err := c.validateTagChanges(changes); err != nil {
+		return nil, err
+	}
+
+	result := &RetagResult{
+		DryRun:  dryRun,
+		Results: make([]TagChangeResult, len(changes)),
+	}
+	forEachConcurrently(len(changes), defaultConcurrency, func(i int) {
+		result.Results[i] = c.retag(changes[i], dryRun)
+	})
+
+	failed := result.Failed()
+	log.Printf("[DEBUG] Retagged %d objects, dry run: %t, failed: %d\n", len(changes), dryRun, len(failed))
+	if len(failed) > 0 {
+		return result, errors.New(fmt.Sprintf("failed to retag %d of %d objects, first error on `%s`: %v", len(failed), len(changes), failed[0].ObjectId, failed[0].Err))
+	}
+	return result, nil
+}
+
+func (c *Client) retag(change TagChange, dryRun bool) TagChangeResult {
+	result := TagChangeResult{ObjectId: change.ObjectId, Added: []string{}, Removed: []string{}}
+	current, err := c.getObjectTags(change.ObjectId)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for _, tag := range change.Add {
+		if stringInSlice(tag, current) || stringInSlice(tag, result.Added) {
+			continue
+		}
+		if !dryRun {
+			if err := c.AddTag(change.ObjectId, tag); err != nil {
+				result.Err = err
+				return result
+			}
+		}
+		result.Added = append(result.Added, tag)
+	}
+	for _, tag := range change.Remove {
+		if !stringInSlice(tag, current) || stringInSlice(tag, result.Removed) {
+			continue
+		}
+		if !dryRun {
+			if err := c.RemoveTag(change.ObjectId, tag); err != nil {
+				result.Err = err
+				return result
+			}
+		}
+		result.Removed = append(result.Removed, tag)
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	return result
+}
+
+func (c *Client) validateTagChanges(changes []TagChange) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	for i, change := range changes {
+		v.required(fmt.Sprintf("changes[%d].ObjectId", i), change.ObjectId)
+		for _, tag := range change.Add {
+			if stringInSlice(tag, change.Remove) {
+				v.addf(fmt.Sprintf("changes[%d].Add", i), "tag `%s` cannot be both added and removed", tag)
+			}
+		}
+	}
+	return v.err()
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeTaggedVmsRPC serves 200 VMs of pool-1, vm-000 to vm-199, and applies
+// tag.add and tag.remove to them. For VM i:
+//   - i%10 == 0 has no env tag and i%10 == 1 has both env:prod and
+//     env:dev, the others have env:prod when i is even and env:dev when
+//     it is odd
+//   - i%4 == 0 has no owner tag, the others have owner:team-<i%3> and
+//     i%8 == 1 also has owner:ops
+//   - i%50 == 0 has the backup tag
+func fakeTaggedVmsRPC() *fakeRPC {
+	var mu sync.Mutex
+	objects := []map[string]interface{}{
+		{"id": "vm-other-pool", "type": "VM", "$poolId": "pool-2", "tags": []string{"env:prod", "env:dev"}},
+	}
+	index := map[string]int{}
+	for i := 0; i < 200; i++ {
+		tags := []string{}
+		switch {
+		case i%10 == 0:
+		case i%10 == 1:
+			tags = append(tags, "env:prod", "env:dev")
+		case i%2 == 0:
+			tags = append(tags, "env:prod")
+		default:
+			tags = append(tags, "env:dev")
+		}
+		if i%4 != 0 {
+			tags = append(tags, fmt.Sprintf("owner:team-%d", i%3))
+		}
+		if i%8 == 1 {
+			tags = append(tags, "owner:ops")
+		}
+		if i%50 == 0 {
+			tags = append(tags, "backup")
+		}
+		id := fmt.Sprintf("vm-%03d", i)
+		index[id] = len(objects)
+		objects = append(objects, map[string]interface{}{"id": id, "type": "VM", "$poolId": "pool-1", "tags": tags})
+	}
+
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "tag.add", "tag.remove":
+			// Replace the object rather than changing the one already
+			// returned, it may still be being encoded
+			i := index[params["id"].(string)]
+			tags := []string{}
+			for _, tag := range objects[i]["tags"].([]string) {
+				if tag != params["tag"] {
+					tags = append(tags, tag)
+				}
+			}
+			if method == "tag.add" {
+				tags = append(tags, params["tag"].(string))
+			}
+			objects[i] = map[string]interface{}{"id": objects[i]["id"], "type": "VM", "$poolId": "pool-1", "tags": tags}
+		}
+		return true, nil
+	}}
+}
+
+func TestGetTagReport(t *testing.T) {
+	c := &Client{rpc: fakeTaggedVmsRPC()}
+
+	report, err := c.GetTagReport("pool-1", []string{"env:", "owner:"})
+	if err != nil {
+		t.Fatalf("failed to get the tag report with error: %v", err)
+	}
+
+	if report.Vms != 200 {
+		t.Errorf("expected the 200 VMs of the pool to be checked but received %d", report.Vms)
+	}
+	counts := map[string]int{
+		"missing env:":     len(report.Missing["env:"]),
+		"duplicate env:":   len(report.Duplicates["env:"]),
+		"missing owner:":   len(report.Missing["owner:"]),
+		"duplicate owner:": len(report.Duplicates["owner:"]),
+	}
+	expectedCounts := map[string]int{
+		"missing env:":     20,
+		"duplicate env:":   20,
+		"missing owner:":   50,
+		"duplicate owner:": 25,
+	}
+	if !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("expected %v but received %v", expectedCounts, counts)
+	}
+	if report.Missing["env:"][0] != "vm-000" || report.Missing["env:"][1] != "vm-010" || report.Duplicates["owner:"][0] != "vm-001" {
+		t.Errorf("expected the VMs to be sorted by id but received: %v, %v", report.Missing["env:"], report.Duplicates["owner:"])
+	}
+
+	expectedFrequency := map[string]int{
+		"env:prod":     100,
+		"env:dev":      100,
+		"owner:team-0": 50,
+		"owner:team-1": 50,
+		"owner:team-2": 50,
+		"owner:ops":    25,
+		"backup":       4,
+	}
+	if !reflect.DeepEqual(report.Frequency, expectedFrequency) {
+		t.Errorf("expected the frequency of the tags to be %v but received %v", expectedFrequency, report.Frequency)
+	}
+}
+
+// governanceChanges gives every VM of fakeTaggedVmsRPC env:prod, removes
+// env:dev and owner:ops, and includes a missing object.
+func governanceChanges() []TagChange {
+	changes := []TagChange{}
+	for i := 0; i < 200; i++ {
+		changes = append(changes, TagChange{ObjectId: fmt.Sprintf("vm-%03d", i), Add: []string{"env:prod"}, Remove: []string{"env:dev", "owner:ops"}})
+	}
+	return append(changes, TagChange{ObjectId: "vm-deleted", Add: []string{"env:prod"}})
+}
+
+func TestBulkRetag_dryRunMatchesChanges(t *testing.T) {
+	dryRPC := fakeTaggedVmsRPC()
+	dry, dryErr := (&Client{rpc: dryRPC}).BulkRetag(governanceChanges(), true)
+
+	rpc := fakeTaggedVmsRPC()
+	c := &Client{rpc: rpc}
+	applied, err := c.BulkRetag(governanceChanges(), false)
+
+	if dryErr == nil || err == nil || len(dry.Failed()) != 1 || dry.Failed()[0].ObjectId != "vm-deleted" {
+		t.Fatalf("expected the missing object to fail in both runs but received: %v, %v", dryErr, err)
+	}
+	if !dry.DryRun || applied.DryRun {
+		t.Errorf("expected only the first result to be a dry run")
+	}
+	if !reflect.DeepEqual(dry.Results, applied.Results) {
+		t.Errorf("expected the dry run to produce the same results as the changes")
+	}
+	if methods := dryRPC.methods(); len(methods) != 201 || len(dryRPC.callsTo("xo.getAllObjects")) != 201 {
+		t.Errorf("expected the dry run to only read the tags but received %d calls", len(methods))
+	}
+
+	added, removed := 0, 0
+	for _, result := range applied.Results {
+		added += len(result.Added)
+		removed += len(result.Removed)
+	}
+	// env:prod is added to the VMs with no env tag or env:dev only, env:dev
+	// removed from those having it and owner:ops from 25 VMs
+	if added != 100 || removed != 125 || len(rpc.callsTo("tag.add")) != added || len(rpc.callsTo("tag.remove")) != removed {
+		t.Errorf("expected 100 tags to be added and 125 removed but received %d and %d", added, removed)
+	}
+	if first := applied.Results[0]; !reflect.DeepEqual(first.Added, []string{"env:prod"}) || len(first.Removed) != 0 {
+		t.Errorf("expected env:prod to be added to vm-000 but received %+v", first)
+	}
+
+	report, err := c.GetTagReport("pool-1", []string{"env:"})
+	if err != nil || len(report.Missing["env:"]) != 0 || len(report.Duplicates["env:"]) != 0 || report.Frequency["env:prod"] != 200 {
+		t.Errorf("expected every VM to have env:prod only once retagged but received %+v with error: %v", report, err)
+	}
+}
+
+func TestBulkRetag_invalidChanges(t *testing.T) {
+	rpc := fakeTaggedVmsRPC()
+	c := &Client{rpc: rpc}
+
+	_, err := c.BulkRetag([]TagChange{{Add: []string{"env:prod"}}, {ObjectId: "vm-001", Add: []string{"env:prod"}, Remove: []string{"env:prod"}}}, false)
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"changes[0].ObjectId", "changes[1].Add"}) {
+		t.Errorf("expected the missing id and the conflicting change to be rejected but received: %v", err)
+	}
+	if len(rpc.methods()) != 0 {
+		t.Errorf("expected no call for invalid changes but received: %v", rpc.methods())
+	}
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSetTags(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params,
+				map[string]interface{}{"id": "vm-1", "type": "VM", "tags": []string{"prod", "web", "legacy"}},
+			), nil
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	if err := c.SetTags("vm-1", []string{"web", "prod", "eu-west"}); err != nil {
+		t.Fatalf("failed to set tags with error: %v", err)
+	}
+
+	expected := []string{"xo.getAllObjects", "tag.add", "tag.remove"}
+	if !reflect.DeepEqual(rpc.methods(), expected) {
+		t.Fatalf("expected calls %v but received %v", expected, rpc.methods())
+	}
+	if params := rpc.callsTo("tag.add")[0].params; params["id"] != "vm-1" || params["tag"] != "eu-west" {
+		t.Errorf("expected the missing tag to be added but received: %v", params)
+	}
+	if params := rpc.callsTo("tag.remove")[0].params; params["id"] != "vm-1" || params["tag"] != "legacy" {
+		t.Errorf("expected the extra tag to be removed but received: %v", params)
+	}
+}
+
+func TestSetTags_notFound(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{}, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	err := c.SetTags("vm-1", []string{"prod"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a NotFound error but received: %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vatesfr/xo-sdk-go/client/wait"
+)
+
+type Task struct {
+	Id                string     `json:"id"`
+	Uuid              string     `json:"uuid"`
+	NameLabel         string     `json:"name_label"`
+	NameDescription   string     `json:"name_description"`
+	Status            TaskStatus `json:"status"`
+	Progress          float64    `json:"progress"`
+	AllowedOperations []string   `json:"allowedOperations"`
+	PoolId            string     `json:"$poolId"`
+	// Id or XAPI reference of the object the task works on, when known
+	AppliesTo string `json:"applies_to"`
+
+	Created Timestamp `json:"created"`
+	// Zero until the task completes
+	Finished Timestamp `json:"finished"`
+}
+
+func (t Task) Compare(obj interface{}) bool {
+	other := obj.(Task)
+
+	if t.Id != "" && t.Id == other.Id {
+		return true
+	}
+
+	if t.NameLabel != "" && t.NameLabel == other.NameLabel {
+		return true
+	}
+
+	return false
+}
+
+// Cancelable reports whether XAPI currently allows the task to be cancelled.
+func (t Task) Cancelable() bool {
+	return t.Status == TaskStatusPending && stringInSlice("cancel", t.AllowedOperations)
+}
+
+// TaskNotCancelableError is returned by CancelTask when the task has already
+// completed or XAPI does not allow it to be cancelled.
+type TaskNotCancelableError struct {
+	Id     string
+	Status TaskStatus
+}
+
+func (e TaskNotCancelableError) Error() string {
+	return fmt.Sprintf("task `%s` cannot be cancelled since its status is `%s`", e.Id, e.Status)
+}
+
+func (c *Client) GetTask(id string) (*Task, error) {
+	obj, err := c.FindFromGetAllObjects(Task{Id: id})
+	if err != nil {
+		return nil, err
+	}
+	tasks, ok := obj.([]Task)
+
+	if !ok {
+		return nil, errors.New("failed to coerce response into Task slice")
+	}
+
+	if len(tasks) != 1 {
+		return nil, errors.New(fmt.Sprintf("expected to find a single task with id `%s` but found %d", id, len(tasks)))
+	}
+
+	return &tasks[0], nil
+}
+
+// CancelTask asks XAPI to cancel a running task. A TaskNotCancelableError
+// is returned when the task is already finished or cannot be cancelled.
+func (c *Client) CancelTask(id string) error {
+	task, err := c.GetTask(id)
+	if err != nil {
+		return err
+	}
+
+	if !task.Cancelable() {
+		return TaskNotCancelableError{Id: id, Status: task.Status}
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id": id,
+	}
+	return c.Call("task.cancel", params, &success)
+}
+
+// DestroyTask removes a task record once it is no longer needed.
+func (c *Client) DestroyTask(id string) error {
+	var success bool
+	params := map[string]interface{}{
+		"id": id,
+	}
+	return c.Call("task.destroy", params, &success)
+}
+
+// How often WaitForTask checks the status of the task.
+var taskPollInterval = 2 * time.Second
+
+// TaskFailedError is returned by WaitForTask when the task failed or was
+// cancelled.
+type TaskFailedError struct {
+	Id     string
+	Status TaskStatus
+}
+
+func (e TaskFailedError) Error() string {
+	return fmt.Sprintf("task `%s` completed with status `%s`", e.Id, e.Status)
+}
+
+func (t *Task) describeWait() string {
+	if t == nil {
+		return "no task"
+	}
+	return fmt.Sprintf("task `%s` with status %s, progress %.0f%%", t.Id, t.Status, t.Progress*100)
+}
+
+// WaitForTask waits for a task to complete and returns it. A
+// TaskFailedError is returned alongside the task when it didn't succeed,
+// a *TimeoutError with the last state of the task when ctx times out.
+func (c *Client) WaitForTask(ctx context.Context, id string) (*Task, error) {
+	var task *Task
+	err := wait.Poll(ctx, taskPollInterval, func(ctx context.Context) (bool, error) {
+		t, err := c.GetTask(id)
+		if err != nil {
+			var notFound NotFound
+			if errors.As(err, &notFound) {
+				return false, wait.Permanent(err)
+			}
+			return false, err
+		}
+		task = t
+		return t.Status != TaskStatusPending && t.Status != TaskStatusCancelling, nil
+	})
+	var stopped *wait.StoppedError
+	if errors.As(err, &stopped) && errors.Is(err, context.DeadlineExceeded) {
+		timeoutErr := &TimeoutError{
+			LastError:     stopped.LastError,
+			ExpectedState: []string{TaskStatusSuccess.String(), TaskStatusFailure.String(), TaskStatusCancelled.String()},
+			Polls:         stopped.Attempts,
+			Elapsed:       stopped.Elapsed,
+		}
+		if task != nil {
+			timeoutErr.LastState = task.Status.String()
+			timeoutErr.LastResult = task
+		}
+		return nil, timeoutErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if task.Status != TaskStatusSuccess {
+		return task, TaskFailedError{Id: id, Status: task.Status}
+	}
+	return task, nil
+}
+
+// PendingOperation is returned by the Async variants of long running
+// methods, e.g. MigrateVmAsync, rather than waiting for the work to
+// complete. The XAPI task doing the work is found among the tasks of the
+// method's kind that appear while the call runs and apply to the object
+// it works on. Concurrent operations of the same kind on objects that
+// don't exist yet, e.g. VM imports, can't be told apart. TaskId is empty
+// when the call completed before any such task was seen.
+type PendingOperation struct {
+	TaskId string
+	// Method which started the task
+	Method string
+
+	c      *Client
+	done   chan struct{}
+	result string
+	err    error
+}
+
+// Wait waits for the task of the operation with WaitForTask, then for the
+// call which started it, and returns the task. The error of the call is
+// returned when the task succeeded but the call failed. An operation whose
+// task is already finished, or was already destroyed by XO, only waits
+// for the call.
+func (op *PendingOperation) Wait(ctx context.Context) (*Task, error) {
+	if op.c == nil || op.done == nil {
+		return nil, errors.New(fmt.Sprintf("operation `%s` wasn't started by the client", op.Method))
+	}
+
+	var task *Task
+	if op.TaskId != "" {
+		t, err := op.c.WaitForTask(ctx, op.TaskId)
+		var notFound NotFound
+		if err != nil && !errors.As(err, &notFound) {
+			return t, err
+		}
+		task = t
+	}
+
+	select {
+	case <-op.done:
+	case <-ctx.Done():
+		return task, ctx.Err()
+	}
+	return task, op.err
+}
+
+// Result returns the id of the object created by the operation once Wait
+// returned, e.g. the VM of ImportVmAsync, or an empty string.
+func (op *PendingOperation) Result() string {
+	if op.done == nil {
+		return ""
+	}
+	select {
+	case <-op.done:
+		return op.result
+	default:
+		return ""
+	}
+}
+
+// operationTask matches the tasks named one of taskNames which apply to
+// the object with the id or XAPI reference, or to any object when both
+// are empty.
+func operationTask(taskNames []string, objectId, objectRef string) func(Task) bool {
+	return func(task Task) bool {
+		if !stringInSlice(task.NameLabel, taskNames) {
+			return false
+		}
+		if objectId == "" && objectRef == "" {
+			return true
+		}
+		return task.AppliesTo != "" && (task.AppliesTo == objectId || task.AppliesTo == objectRef)
+	}
+}
+
+// startOperation runs call in the background and returns once the XAPI
+// task doing its work is found among the tasks matched by isTask, in the
+// pool when poolId isn't empty, or once the call completed. call returns
+// the id of the object it created, if any. The lookup of the task stops
+// when ctx is done, the call itself is left to honor ctx.
+func (c *Client) startOperation(ctx context.Context, method, poolId string, isTask func(Task) bool, call func() (string, error)) (*PendingOperation, error) {
+	tasks := func() (map[string]Task, error) {
+		filter := map[string]string{
+			"type": "task",
+		}
+		if poolId != "" {
+			filter["$poolId"] = poolId
+		}
+		var tasksRes map[string]Task
+		err := c.callContext(ctx, "xo.getAllObjects", map[string]interface{}{"filter": filter}, &tasksRes)
+		if err != nil {
+			return nil, err
+		}
+		for id, task := range tasksRes {
+			if !isTask(task) {
+				delete(tasksRes, id)
+			}
+		}
+		return tasksRes, nil
+	}
+	existing, err := tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	op := &PendingOperation{Method: method, c: c, done: make(chan struct{})}
+	go func() {
+		defer close(op.done)
+		op.result, op.err = call()
+	}()
+
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+	for {
+		finished := false
+		select {
+		case <-op.done:
+			finished = true
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		current, err := tasks()
+		if err != nil {
+			c.logf("[WARN] Failed to look up the task of `%s`: %v\n", method, err)
+		}
+		for _, id := range sortedKeys(current) {
+			if _, ok := existing[id]; !ok {
+				op.TaskId = id
+				return op, nil
+			}
+		}
+		if finished {
+			if op.err != nil {
+				return nil, op.err
+			}
+			return op, nil
+		}
+	}
+}
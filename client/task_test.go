@@ -0,0 +1,247 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func fakeTaskRPC(task map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "xo.getAllObjects" {
+			return fakeGetAllObjects(params, task), nil
+		}
+		return true, nil
+	}}
+}
+
+func TestCancelTask_callsTaskCancelWithId(t *testing.T) {
+	rpc := fakeTaskRPC(map[string]interface{}{
+		"id":                "task-1",
+		"type":              "task",
+		"status":            "pending",
+		"allowedOperations": []string{"cancel", "destroy"},
+	})
+	c := Client{rpc: rpc}
+
+	if err := c.CancelTask("task-1"); err != nil {
+		t.Fatalf("failed to cancel task with error: %v", err)
+	}
+
+	calls := rpc.callsTo("task.cancel")
+	if len(calls) != 1 {
+		t.Fatalf("expected a single task.cancel call but received %v", rpc.methods())
+	}
+	if calls[0].params["id"] != "task-1" {
+		t.Errorf("expected task.cancel to be called with id task-1 but received %v", calls[0].params)
+	}
+}
+
+func TestCancelTask_completedTaskIsNotCancelable(t *testing.T) {
+	rpc := fakeTaskRPC(map[string]interface{}{
+		"id":                "task-1",
+		"type":              "task",
+		"status":            "success",
+		"allowedOperations": []string{"destroy"},
+	})
+	c := Client{rpc: rpc}
+
+	err := c.CancelTask("task-1")
+	var notCancelable TaskNotCancelableError
+	if !errors.As(err, &notCancelable) {
+		t.Fatalf("expected a TaskNotCancelableError but received: %v", err)
+	}
+	if notCancelable.Status != TaskStatusSuccess {
+		t.Errorf("expected error to report status success but received %s", notCancelable.Status)
+	}
+	if len(rpc.callsTo("task.cancel")) != 0 {
+		t.Errorf("expected task.cancel not to be called for a completed task")
+	}
+}
+
+func TestDestroyTask(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := Client{rpc: rpc}
+
+	if err := c.DestroyTask("task-1"); err != nil {
+		t.Fatalf("failed to destroy task with error: %v", err)
+	}
+
+	calls := rpc.callsTo("task.destroy")
+	if len(calls) != 1 || calls[0].params["id"] != "task-1" {
+		t.Errorf("expected task.destroy to be called with id task-1 but received %v", rpc.calls)
+	}
+}
+
+// fakeCompletingTaskRPC serves a task which completes with status once it
+// was polled the given number of times.
+func fakeCompletingTaskRPC(polls int, status TaskStatus) *fakeRPC {
+	task := map[string]interface{}{"id": "task-1", "type": "task", "status": "pending"}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		polls--
+		if polls <= 0 {
+			task["status"] = status
+		}
+		return fakeGetAllObjects(params, task), nil
+	}}
+}
+
+func TestWaitForTask_failedTask(t *testing.T) {
+	c := &Client{rpc: fakeCompletingTaskRPC(1, TaskStatusFailure)}
+
+	var failed TaskFailedError
+	task, err := c.WaitForTask(context.Background(), "task-1")
+	if !errors.As(err, &failed) || failed.Status != TaskStatusFailure || task == nil {
+		t.Errorf("expected a TaskFailedError along with the task but received %+v with error: %v", task, err)
+	}
+
+	var notFound NotFound
+	if _, err := c.WaitForTask(context.Background(), "task-2"); !errors.As(err, &notFound) {
+		t.Errorf("expected a NotFound error for a missing task but received: %v", err)
+	}
+}
+
+func TestWaitForTask_timeoutReportsLastState(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	c := &Client{rpc: fakeTaskRPC(map[string]interface{}{"id": "task-1", "type": "task", "status": TaskStatusPending, "progress": 0.45})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.WaitForTask(ctx, "task-1")
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a TimeoutError matching context.DeadlineExceeded but received: %v", err)
+	}
+	task, ok := timeoutErr.LastResult.(*Task)
+	if !ok || task.Progress != 0.45 || timeoutErr.LastState != TaskStatusPending.String() || timeoutErr.Polls < 2 || timeoutErr.Elapsed < 20*time.Millisecond {
+		t.Errorf("expected the last state of the task and the polls to be reported but received: %+v", timeoutErr)
+	}
+	if !strings.Contains(err.Error(), "last seen: task `task-1` with status pending, progress 45%") {
+		t.Errorf("expected the message to describe the task but received: %v", err)
+	}
+}
+
+// fakeMigrationTaskRPC serves a VM whose migration creates a pending XAPI
+// task and only returns once release is closed.
+func fakeMigrationTaskRPC(release chan struct{}) (*fakeRPC, func(status TaskStatus)) {
+	var mu sync.Mutex
+	objects := []map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "power_state": "Running", "$poolId": "pool-1", "$container": "host-1", "_xapiRef": "OpaqueRef:vm-1"},
+		{"id": "task-0", "type": "task", "name_label": vmMigrateTaskNameLabel, "status": "pending", "$poolId": "pool-1", "applies_to": "OpaqueRef:vm-1"},
+	}
+	setStatus := func(status TaskStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, obj := range objects {
+			if obj["id"] == "task-1" {
+				obj["status"] = status
+			}
+		}
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			mu.Lock()
+			defer mu.Unlock()
+			return fakeGetAllObjects(params, objects...), nil
+		case "vm.migrate":
+			mu.Lock()
+			// The migration of another VM of the pool starts at the same time
+			objects = append(objects,
+				map[string]interface{}{"id": "task-01", "type": "task", "name_label": vmMigrateTaskNameLabel, "status": "pending", "$poolId": "pool-1", "applies_to": "OpaqueRef:vm-2"},
+				map[string]interface{}{"id": "task-1", "type": "task", "name_label": vmMigrateTaskNameLabel, "status": "pending", "$poolId": "pool-1", "applies_to": "OpaqueRef:vm-1"},
+			)
+			mu.Unlock()
+			<-release
+		}
+		return true, nil
+	}}, setStatus
+}
+
+func TestMigrateVmAsync_wait(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	release := make(chan struct{})
+	rpc, setStatus := fakeMigrationTaskRPC(release)
+	c := &Client{rpc: rpc}
+
+	op, err := c.MigrateVmAsync("vm-1", "host-1", MigrateVmOptions{})
+	if err != nil {
+		t.Fatalf("failed to start the migration with error: %v", err)
+	}
+	// task-0 was already running before the migration started and task-01
+	// migrates another VM
+	if op.TaskId != "task-1" || op.Method != "vm.migrate" {
+		t.Fatalf("expected the operation to track the new migration task but received: %+v", op)
+	}
+
+	setStatus(TaskStatusSuccess)
+	close(release)
+	task, err := op.Wait(context.Background())
+	if err != nil || task == nil || task.Status != TaskStatusSuccess {
+		t.Fatalf("expected the task to succeed but received %+v with error: %v", task, err)
+	}
+
+	// Already finished
+	task, err = op.Wait(context.Background())
+	if err != nil || task == nil || task.Status != TaskStatusSuccess {
+		t.Errorf("expected the finished task to be returned right away but received %+v with error: %v", task, err)
+	}
+}
+
+func TestMigrateVmAsync_callFailsBeforeTask(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, map[string]interface{}{"id": "vm-1", "type": "VM", "$poolId": "pool-1", "$container": "host-1"}), nil
+		case "vm.migrate":
+			return nil, errors.New("no hosts available")
+		}
+		return true, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	op, err := c.MigrateVmAsync("vm-1", "host-2", MigrateVmOptions{})
+	if err == nil || err.Error() != "no hosts available" {
+		t.Errorf("expected the error of the migration to be returned right away but received %+v with error: %v", op, err)
+	}
+}
+
+func TestStartOperation_stopsWithContext(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params), nil
+	}}
+	c := &Client{rpc: rpc}
+
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	op, err := c.startOperation(ctx, "vm.migrate", "", operationTask([]string{vmMigrateTaskNameLabel}, "", ""), func() (string, error) {
+		<-release
+		return "", nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the lookup of the task to stop with the context but received %+v with error: %v", op, err)
+	}
+}
+
+func TestPendingOperation_waitWithoutClient(t *testing.T) {
+	op := &PendingOperation{TaskId: "task-1", Method: "vm.export"}
+	if _, err := op.Wait(context.Background()); err == nil {
+		t.Errorf("expected an operation not started by the client to fail to be waited for")
+	}
+}
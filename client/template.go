@@ -9,7 +9,7 @@ import (
 type TemplateDisk struct {
 	Bootable bool   `json:"bootable"`
 	Device   string `json:"device"`
-	Size     int    `json:"size"`
+	Size     int64  `json:"size"`
 	Type     string `json:"type"`
 	SR       string `json:"SR"`
 }
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type timestampEncoding int
+
+const (
+	timestampSeconds timestampEncoding = iota
+	timestampMilliseconds
+	timestampString
+)
+
+// Epoch timestamps above this are in milliseconds, it is year 5138 in
+// seconds and 1973 in milliseconds.
+const maxSecondsTimestamp = 1e11
+
+// Timestamp is a time XO encodes, depending on the object, as seconds or
+// milliseconds since the epoch or as an ISO 8601 string. null and 0 decode
+// to the zero time, see IsZero. A decoded timestamp is encoded back the
+// same way, others as seconds since the epoch.
+type Timestamp struct {
+	time.Time
+
+	encoding timestampEncoding
+}
+
+// NewTimestamp returns the timestamp of t, encoded as seconds since the
+// epoch.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*t = Timestamp{}
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*t = Timestamp{encoding: timestampString}
+			return nil
+		}
+		parsed, err := parseXapiTime(s)
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid timestamp `%s`: %v", s, err))
+		}
+		*t = Timestamp{Time: parsed, encoding: timestampString}
+		return nil
+	}
+
+	epoch, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return errors.New(fmt.Sprintf("invalid timestamp `%s`: expected a number, a string or null", data))
+	}
+	*t = timestampFromEpoch(epoch)
+	return nil
+}
+
+// timestampFromEpoch decodes seconds or milliseconds since the epoch, 0
+// being the zero time.
+func timestampFromEpoch(epoch float64) Timestamp {
+	switch {
+	case epoch == 0:
+		return Timestamp{}
+	case epoch > maxSecondsTimestamp || epoch < -maxSecondsTimestamp:
+		return Timestamp{Time: time.Unix(0, int64(epoch)*int64(time.Millisecond)), encoding: timestampMilliseconds}
+	}
+	return Timestamp{Time: time.Unix(0, int64(epoch*float64(time.Second)))}
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+
+	switch t.encoding {
+	case timestampMilliseconds:
+		return []byte(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)), nil
+	case timestampString:
+		return json.Marshal(t.UTC().Format(time.RFC3339))
+	}
+	return []byte(strconv.FormatInt(t.Unix(), 10)), nil
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestamp_encodings(t *testing.T) {
+	startTime := time.Date(2019, 3, 13, 2, 56, 42, 0, time.UTC)
+	tests := []struct {
+		name    string
+		json    string
+		time    time.Time
+		encoded string
+	}{
+		{"seconds", `1552445802`, startTime, `1552445802`},
+		{"fractional seconds", `1552445802.5`, startTime.Add(500 * time.Millisecond), `1552445802`},
+		{"milliseconds", `1552445802123`, startTime.Add(123 * time.Millisecond), `1552445802123`},
+		{"ISO 8601", `"2019-03-13T02:56:42Z"`, startTime, `"2019-03-13T02:56:42Z"`},
+		{"ISO 8601 with offset", `"2019-03-13T03:56:42+01:00"`, startTime, `"2019-03-13T02:56:42Z"`},
+		{"XAPI", `"20190313T02:56:42Z"`, startTime, `"2019-03-13T02:56:42Z"`},
+		{"null", `null`, time.Time{}, `null`},
+		{"zero", `0`, time.Time{}, `null`},
+		{"empty string", `""`, time.Time{}, `null`},
+	}
+	for _, test := range tests {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(test.json), &ts); err != nil {
+			t.Errorf("%s: failed to decode `%s` with error: %v", test.name, test.json, err)
+			continue
+		}
+		if !ts.Equal(test.time) {
+			t.Errorf("%s: expected `%s` to be decoded as %s but received %s", test.name, test.json, test.time, ts.Time)
+		}
+
+		b, err := json.Marshal(ts)
+		if err != nil || string(b) != test.encoded {
+			t.Errorf("%s: expected `%s` to be encoded back as `%s` but received `%s` with error: %v", test.name, test.json, test.encoded, b, err)
+		}
+	}
+
+	for _, invalid := range []string{`"yesterday"`, `true`, `{}`} {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(invalid), &ts); err == nil {
+			t.Errorf("expected `%s` to be rejected but received %s", invalid, ts.Time)
+		}
+	}
+
+	if b, _ := json.Marshal(NewTimestamp(startTime)); string(b) != `1552445802` {
+		t.Errorf("expected a new timestamp to be encoded in seconds but received `%s`", b)
+	}
+}
+
+func TestVm_decodesTimestamps(t *testing.T) {
+	var vm Vm
+	data := `{"id": "snapshot-1", "type": "VM-snapshot", "installTime": 1552287083, "startTime": null, "snapshot_time": 1552445802}`
+	if err := json.Unmarshal([]byte(data), &vm); err != nil {
+		t.Fatalf("failed to decode the VM with error: %v", err)
+	}
+	if vm.InstallTime.Unix() != 1552287083 || !vm.StartTime.IsZero() || vm.SnapshotTime.Unix() != 1552445802 {
+		t.Errorf("expected the timestamps of the VM to be decoded but received: %s, %s, %s", vm.InstallTime, vm.StartTime, vm.SnapshotTime)
+	}
+}
+
+func TestHost_decodesTimestamps(t *testing.T) {
+	var host Host
+	if err := json.Unmarshal([]byte(`{"id": "host-1", "startTime": 1552000000, "agentStartTime": 1552000060}`), &host); err != nil {
+		t.Fatalf("failed to decode the host with error: %v", err)
+	}
+	if host.AgentStartTime.Sub(host.StartTime.Time) != time.Minute {
+		t.Errorf("expected the agent to start a minute after the host but received: %s, %s", host.StartTime, host.AgentStartTime)
+	}
+}
+
+func TestTask_decodesTimestamps(t *testing.T) {
+	var task Task
+	if err := json.Unmarshal([]byte(`{"id": "task-1", "status": "pending", "created": 1552445802, "finished": 0}`), &task); err != nil {
+		t.Fatalf("failed to decode the task with error: %v", err)
+	}
+	if task.Created.Unix() != 1552445802 || !task.Finished.IsZero() {
+		t.Errorf("expected a pending task with its creation time but received: %s, %s", task.Created, task.Finished)
+	}
+}
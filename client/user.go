@@ -25,14 +25,16 @@ type SshKey struct {
 	Key   string
 }
 
+// Compare matches users by id or by email. XO treats sign-in emails case
+// insensitively so they are compared the same way.
 func (user User) Compare(obj interface{}) bool {
 	other := obj.(User)
 
-	if user.Id == other.Id {
+	if user.Id != "" && user.Id == other.Id {
 		return true
 	}
 
-	if user.Email == other.Email {
+	if user.Email != "" && strings.EqualFold(user.Email, other.Email) {
 		return true
 	}
 
@@ -51,7 +53,8 @@ func (c *Client) CreateUser(user User) (*User, error) {
 		return nil, err
 	}
 
-	return c.GetUser(User{Id: id})
+	// Looked up by id only, other users may share the email up to its case
+	return c.getUserById(id)
 }
 
 func (c *Client) GetAllUsers() ([]User, error) {
@@ -88,7 +91,59 @@ func (c *Client) GetUser(userReq User) (*User, error) {
 	return &foundUser, nil
 }
 
+func (c *Client) getUserById(id string) (*User, error) {
+	users, err := c.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range users {
+		if user.Id == id {
+			return &user, nil
+		}
+	}
+	return nil, NotFound{Query: User{Id: id}}
+}
+
+// GetUserByEmail returns the user signing in with the email, regardless of
+// its case. An AmbiguousResultError is returned when several users share
+// the email up to its case.
+func (c *Client) GetUserByEmail(email string) (*User, error) {
+	users, err := c.GetAllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	query := User{Email: email}
+	matches := []User{}
+	for _, user := range users {
+		if strings.EqualFold(user.Email, email) {
+			matches = append(matches, user)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, NotFound{Query: query}
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, AmbiguousResultError{Query: query, Matches: len(matches)}
+}
+
 func (c *Client) DeleteUser(user User) error {
+	_, err := c.deleteUser(user)
+	return err
+}
+
+// DeleteUserWithResult deletes the user like DeleteUser. A user that
+// doesn't exist is reported as already absent.
+func (c *Client) DeleteUserWithResult(user User) (*DeleteResult, error) {
+	return absentIfNoSuchObject(c.deleteUser(user))
+}
+
+func (c *Client) deleteUser(user User) (*DeleteResult, error) {
+	res := &DeleteResult{DeletedObject: DeletedObject{Type: "user", Id: user.Id}}
 	var success bool
 	params := map[string]interface{}{
 		"id": user.Id,
@@ -96,13 +151,13 @@ func (c *Client) DeleteUser(user User) error {
 	err := c.Call("user.delete", params, &success)
 
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	if !success {
-		return errors.New("failed to delete user")
+		return res, errors.New("failed to delete user")
 	}
-	return nil
+	return res, nil
 }
 
 func RemoveUsersWithPrefix(usernamePrefix string) func(string) error {
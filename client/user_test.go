@@ -1,6 +1,7 @@
 package client
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -37,3 +38,64 @@ func TestGetUser(t *testing.T) {
 		t.Errorf("failed to find user by id `%s` with error: %v", user.Id, err)
 	}
 }
+
+func fakeUsersRPC(users ...map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "user.getAll":
+			return users, nil
+		case "user.create":
+			return "user-new", nil
+		}
+		return true, nil
+	}}
+}
+
+func TestGetUserByEmail(t *testing.T) {
+	c := &Client{rpc: fakeUsersRPC(
+		map[string]interface{}{"id": "user-1", "email": "Alice@Example.com"},
+		map[string]interface{}{"id": "user-2", "email": "bob@example.com"},
+		map[string]interface{}{"id": "user-3", "email": "BOB@example.com"},
+	)}
+
+	user, err := c.GetUserByEmail("alice@example.COM")
+	if err != nil || user.Id != "user-1" {
+		t.Errorf("expected the email to match regardless of its case but received: %+v, %v", user, err)
+	}
+
+	var ambiguous AmbiguousResultError
+	if _, err := c.GetUserByEmail("bob@example.com"); !errors.As(err, &ambiguous) || ambiguous.Matches != 2 {
+		t.Errorf("expected an AmbiguousResultError for the mixed-case duplicates but received: %v", err)
+	}
+
+	if _, err := c.GetUserByEmail("carol@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a NotFound error but received: %v", err)
+	}
+}
+
+func TestCreateUser_fetchesCreatedUserById(t *testing.T) {
+	rpc := fakeUsersRPC(
+		map[string]interface{}{"id": "user-1", "email": "Alice@example.com"},
+		map[string]interface{}{"id": "user-new", "email": "alice@example.com"},
+		map[string]interface{}{"id": "user-2", "email": ""},
+		map[string]interface{}{"id": "user-3", "email": "ALICE@example.com"},
+	)
+	c := &Client{rpc: rpc}
+
+	user, err := c.CreateUser(User{Email: "alice@example.com", Password: "password"})
+	if err != nil {
+		t.Fatalf("failed to create user with error: %v", err)
+	}
+	if user.Id != "user-new" {
+		t.Errorf("expected the created user to be returned but received: %+v", user)
+	}
+}
+
+func TestUserCompare(t *testing.T) {
+	if !(User{Email: "alice@example.com"}).Compare(User{Id: "user-1", Email: "Alice@Example.com"}) {
+		t.Errorf("expected emails to match regardless of their case")
+	}
+	if (User{Id: "user-1"}).Compare(User{Id: "user-2"}) {
+		t.Errorf("expected users without emails not to match on their empty email")
+	}
+}
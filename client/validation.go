@@ -0,0 +1,218 @@
+package client
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Smallest amount of memory XAPI lets a VM boot with.
+const minVmMemory = 64 * 1024 * 1024
+
+var uuidRegexp = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+
+// ValidationError is a problem found in a request before sending it to XO.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors lists every problem found in a request. Each of them
+// is a ValidationError. Validation is skipped when the client is
+// configured with SkipValidation.
+type ValidationErrors []error
+
+func (e ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("invalid request: %s", strings.Join(msgs, "; "))
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	return e
+}
+
+// validator accumulates the problems found in a request.
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) addf(field, format string, a ...interface{}) {
+	v.errs = append(v.errs, ValidationError{Field: field, Message: fmt.Sprintf(format, a...)})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.addf(field, "is required")
+	}
+}
+
+func (v *validator) uuid(field, value string) {
+	if value != "" && !uuidRegexp.MatchString(value) {
+		v.addf(field, "`%s` is not a valid UUID", value)
+	}
+}
+
+func (v *validator) vmResources(vm Vm) {
+	if vm.CPUs.Number < 1 {
+		v.addf("CPUs.Number", "must be at least 1, got %d", vm.CPUs.Number)
+	}
+	if len(vm.Memory.Static) < 2 {
+		v.addf("Memory.Static", "must contain the minimum and maximum memory")
+	} else if vm.Memory.Static[1] < minVmMemory {
+		v.addf("Memory.Static", "maximum memory must be at least 64 MiB, got %d bytes", vm.Memory.Static[1])
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (c *Client) validateCreateVm(vm Vm) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	v.required("NameLabel", vm.NameLabel)
+	v.required("Template", vm.Template)
+	v.uuid("Template", vm.Template)
+	v.uuid("AffinityHost", vm.AffinityHost)
+	v.vmResources(vm)
+	v.haRestart(vm)
+	v.placementHint(vm)
+
+	v.cloudConfig("CloudConfig", vm.CloudConfig)
+	v.cloudNetworkConfig("CloudNetworkConfig", vm.CloudNetworkConfig)
+	if vm.CloudConfig != "" && vm.Installation.Method != "" {
+		v.addf("CloudConfig", "cannot be combined with an installation method, cloud config requires a template with disks")
+	}
+	switch vm.Installation.Method {
+	case "", "cdrom", "network":
+	default:
+		v.addf("Installation.Method", "must be `cdrom` or `network`, got `%s`", vm.Installation.Method)
+	}
+
+	if _, ok := secureBootUefiModes[vm.SecureBootKeys]; vm.SecureBootKeys != "" && !ok {
+		v.addf("SecureBootKeys", "must be `%s` or `%s`, got `%s`", SecureBootKeysDefault, SecureBootKeysReset, vm.SecureBootKeys)
+	}
+	if (vm.SecureBoot || vm.SecureBootKeys != "") && vm.Boot.Firmware != "uefi" {
+		v.addf("Boot.Firmware", "must be `uefi` to use secure boot, got `%s`", vm.Boot.Firmware)
+	}
+
+	if len(vm.Disks) == 0 {
+		v.addf("Disks", "at least one disk is required")
+	}
+	for i, disk := range vm.Disks {
+		v.uuid(fmt.Sprintf("Disks[%d].SrId", i), disk.SrId)
+		v.uuid(fmt.Sprintf("Disks[%d].VDIId", i), disk.VDIId)
+	}
+	for i, vif := range vm.VIFsMap {
+		field := fmt.Sprintf("VIFsMap[%d].network", i)
+		v.required(field, vif["network"])
+		v.uuid(field, vif["network"])
+	}
+	return v.err()
+}
+
+func (c *Client) validateUpdateVm(vm Vm) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	v.required("Id", vm.Id)
+	v.uuid("Id", vm.Id)
+	v.uuid("AffinityHost", vm.AffinityHost)
+	v.vmResources(vm)
+	v.haRestart(vm)
+	v.cpuMask("VcpuMask", vm.VcpuMask)
+	return v.err()
+}
+
+func (c *Client) validateStartVm(opts StartVmOptions) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	v.cloudConfig("CloudConfig", opts.CloudConfig)
+	v.cloudNetworkConfig("CloudNetworkConfig", opts.CloudNetworkConfig)
+	return v.err()
+}
+
+func (c *Client) validateCreateCloudConfig(name, template string) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	v.required("Name", name)
+	v.cloudConfig("Template", template)
+	return v.err()
+}
+
+func (c *Client) validateCreateNetwork(net Network) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	v.required("NameLabel", net.NameLabel)
+	v.required("PoolId", net.PoolId)
+	v.uuid("PoolId", net.PoolId)
+	v.uuid("PIFId", net.PIFId)
+	if net.Vlan < 0 || net.Vlan > 4094 {
+		v.addf("Vlan", "must be between 0 and 4094, got %d", net.Vlan)
+	}
+	if net.Vlan != 0 && net.PIFId == "" {
+		v.addf("Vlan", "requires a PIF to create the VLAN on")
+	}
+	return v.err()
+}
+
+func (c *Client) validateEmailTransport(config EmailTransportConfig) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	v.required("FromAddress", config.FromAddress)
+	v.required("Host", config.Host)
+	if config.Port < 0 || config.Port > 65535 {
+		v.addf("Port", "must be between 0 and 65535, got %d", config.Port)
+	}
+	switch config.Secure {
+	case "", "auto", "force", "disabled":
+	default:
+		v.addf("Secure", "must be `auto`, `force` or `disabled`, got `%s`", config.Secure)
+	}
+	if config.Password != "" && config.Username == "" {
+		v.addf("Username", "is required to authenticate with a password")
+	}
+	return v.err()
+}
+
+func (c *Client) validateUpdateBackupJob(job BackupJob) error {
+	if c.skipValidation {
+		return nil
+	}
+
+	v := &validator{}
+	for _, key := range sortedKeys(job.Settings) {
+		reportWhen := job.Settings[key].ReportWhen
+		if reportWhen != "" && !stringInSlice(reportWhen, reportWhenValues) {
+			v.addf(fmt.Sprintf("Settings[%q].ReportWhen", key), "must be `always`, `failure` or `never`, got `%s`", reportWhen)
+		}
+	}
+	return v.err()
+}
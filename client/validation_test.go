@@ -0,0 +1,182 @@
+package client
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	testUuid  = "b55b5e53-1a31-4ba5-b0b3-ba5b0d8c7f10"
+	testUuid2 = "0b3f1b9e-6b53-49c4-a3a4-5e1d6f7b2c3d"
+)
+
+func validVmRequest() Vm {
+	return Vm{
+		NameLabel: "web",
+		Template:  testUuid,
+		CPUs:      CPUs{Number: 2},
+		Memory:    MemoryObject{Static: []int64{0, 1024 * 1024 * 1024}},
+		Disks:     []Disk{{VDI: VDI{SrId: testUuid2, NameLabel: "root", Size: 1024}}},
+		VIFsMap:   []map[string]string{{"network": testUuid2}},
+	}
+}
+
+// validationFields returns the fields reported by a ValidationErrors.
+func validationFields(t *testing.T, err error) []string {
+	fields := []string{}
+	if err == nil {
+		return fields
+	}
+
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors but received: %v", err)
+	}
+	for _, e := range errs {
+		var validationErr ValidationError
+		if !errors.As(e, &validationErr) {
+			t.Fatalf("expected a ValidationError but received: %v", e)
+		}
+		fields = append(fields, validationErr.Field)
+	}
+	return fields
+}
+
+func TestValidateCreateVm(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Vm)
+		fields []string
+	}{
+		{name: "valid", modify: func(vm *Vm) {}, fields: []string{}},
+		{
+			name:   "missing name and template",
+			modify: func(vm *Vm) { vm.NameLabel = ""; vm.Template = "" },
+			fields: []string{"NameLabel", "Template"},
+		},
+		{
+			name:   "no cpus",
+			modify: func(vm *Vm) { vm.CPUs.Number = 0 },
+			fields: []string{"CPUs.Number"},
+		},
+		{
+			name:   "too little memory",
+			modify: func(vm *Vm) { vm.Memory.Static = []int64{0, 32 * 1024 * 1024} },
+			fields: []string{"Memory.Static"},
+		},
+		{
+			name:   "missing memory",
+			modify: func(vm *Vm) { vm.Memory.Static = nil },
+			fields: []string{"Memory.Static"},
+		},
+		{
+			name:   "cloud config with installation",
+			modify: func(vm *Vm) { vm.CloudConfig = "#cloud-config"; vm.Installation.Method = "cdrom" },
+			fields: []string{"CloudConfig"},
+		},
+		{
+			name:   "invalid ids",
+			modify: func(vm *Vm) { vm.Template = "ubuntu"; vm.Disks[0].SrId = "local"; vm.VIFsMap[0]["network"] = "lan" },
+			fields: []string{"Template", "Disks[0].SrId", "VIFsMap[0].network"},
+		},
+		{
+			name:   "no disks",
+			modify: func(vm *Vm) { vm.Disks = nil },
+			fields: []string{"Disks"},
+		},
+		{
+			name:   "secure boot without uefi",
+			modify: func(vm *Vm) { vm.SecureBoot = true; vm.SecureBootKeys = "custom" },
+			fields: []string{"SecureBootKeys", "Boot.Firmware"},
+		},
+	}
+
+	c := &Client{}
+	for _, test := range tests {
+		vm := validVmRequest()
+		test.modify(&vm)
+
+		fields := validationFields(t, c.validateCreateVm(vm))
+		if len(fields) != len(test.fields) {
+			t.Errorf("%s: expected problems with %v but received %v", test.name, test.fields, fields)
+			continue
+		}
+		for i := range fields {
+			if fields[i] != test.fields[i] {
+				t.Errorf("%s: expected problems with %v but received %v", test.name, test.fields, fields)
+			}
+		}
+	}
+}
+
+func TestValidateUpdateVm(t *testing.T) {
+	tests := []struct {
+		name   string
+		vm     Vm
+		fields []string
+	}{
+		{
+			name:   "valid",
+			vm:     Vm{Id: testUuid, CPUs: CPUs{Number: 1}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}},
+			fields: []string{},
+		},
+		{
+			name:   "everything wrong",
+			vm:     Vm{Id: "vm-1"},
+			fields: []string{"Id", "CPUs.Number", "Memory.Static"},
+		},
+	}
+
+	c := &Client{}
+	for _, test := range tests {
+		fields := validationFields(t, c.validateUpdateVm(test.vm))
+		if len(fields) != len(test.fields) {
+			t.Errorf("%s: expected problems with %v but received %v", test.name, test.fields, fields)
+		}
+	}
+}
+
+func TestValidateCreateNetwork(t *testing.T) {
+	tests := []struct {
+		name   string
+		net    Network
+		fields []string
+	}{
+		{name: "valid", net: Network{NameLabel: "lan", PoolId: testUuid}, fields: []string{}},
+		{name: "valid vlan", net: Network{NameLabel: "lan", PoolId: testUuid, PIFId: testUuid2, Vlan: 12}, fields: []string{}},
+		{name: "vlan without pif", net: Network{NameLabel: "lan", PoolId: testUuid, Vlan: 12}, fields: []string{"Vlan"}},
+		{name: "vlan out of range", net: Network{NameLabel: "lan", PoolId: testUuid, PIFId: testUuid2, Vlan: 5000}, fields: []string{"Vlan"}},
+		{name: "missing fields", net: Network{PoolId: "pool-1"}, fields: []string{"NameLabel", "PoolId"}},
+	}
+
+	c := &Client{}
+	for _, test := range tests {
+		fields := validationFields(t, c.validateCreateNetwork(test.net))
+		if len(fields) != len(test.fields) {
+			t.Errorf("%s: expected problems with %v but received %v", test.name, test.fields, fields)
+		}
+	}
+}
+
+func TestCreateVm_validationIsSkippable(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return map[string]interface{}{}, nil
+	}}
+
+	c := &Client{rpc: rpc}
+	var errs ValidationErrors
+	if _, err := c.CreateVm(Vm{}, 0); !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors but received: %v", err)
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected an invalid request not to be sent but received calls: %v", rpc.methods())
+	}
+
+	c = &Client{rpc: rpc, skipValidation: true}
+	if _, err := c.CreateVm(Vm{}, 0); errors.As(err, &errs) {
+		t.Errorf("expected validation to be skipped but received: %v", err)
+	}
+	if len(rpc.calls) == 0 {
+		t.Errorf("expected the request to be sent to XO when skipping validation")
+	}
+}
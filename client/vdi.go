@@ -1,8 +1,17 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
 )
 
 type Disk struct {
@@ -15,10 +24,24 @@ type VDI struct {
 	SrId            string   `json:"$SR"`
 	NameLabel       string   `json:"name_label"`
 	NameDescription string   `json:"name_description"`
-	Size            int      `json:"size"`
+	Size            int64    `json:"size"`
 	VBDs            []string `json:"$VBDs"`
 	PoolId          string   `json:"$poolId"`
 	Tags            []string `json:"tags,omitempty"`
+	CbtEnabled      bool     `json:"cbt_enabled"`
+	Uuid            string   `json:"uuid"`
+	XapiRef         string   `json:"_xapiRef,omitempty"`
+	Type            string   `json:"type"`
+	Usage           int64    `json:"usage"`
+	Parent          string   `json:"parent"`
+
+	// Allows the VDI to be attached read-write by several VMs at once,
+	// for clustered filesystems. Requires a shared SR.
+	Sharable bool `json:"sharable"`
+
+	// Only used when creating a disk without an SR id, in which case
+	// the SR is looked up by name within the VM's pool.
+	SrNameLabel string `json:"-"`
 }
 
 func (v VDI) Compare(obj interface{}) bool {
@@ -167,16 +190,154 @@ func (c *Client) GetParentVDI(vbd VBD) (VDI, error) {
 }
 
 func (c *Client) CreateDisk(vm Vm, d Disk) (string, error) {
+	disk, err := c.CreateVmDisk(vm, d)
+	if err != nil {
+		return "", err
+	}
+	return disk.VDIId, nil
+}
+
+// CreateVmDisk creates a disk attached to the VM and returns it with the
+// id of its VDI and of the SR it was created on. When the disk has no SR
+// id, the SR named SrNameLabel in the VM's pool is used or, without a
+// name, the pool's default SR. The disk is deleted when it can't be made
+// sharable.
+func (c *Client) CreateVmDisk(vm Vm, d Disk) (*Disk, error) {
+	if !c.skipValidation {
+		v := &validator{}
+		v.newDiskMode(d)
+		if err := v.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	srId, err := c.resolveDiskSr(vm, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Sharable {
+		err = c.validateSharableSr(srId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var id string
 	params := map[string]interface{}{
 		"name": d.NameLabel,
 		"size": d.Size,
-		"sr":   d.SrId,
+		"sr":   srId,
 		"vm":   vm.Id,
 	}
-	err := c.Call("disk.create", params, &id)
+	if d.ReadOnly {
+		params["mode"] = "RO"
+	}
+	err = c.Call("disk.create", params, &id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Sharable {
+		err = c.setVdiSharable(id, true)
+		if err != nil {
+			var success bool
+			if deleteErr := c.Call("vdi.delete", map[string]interface{}{"id": id}, &success); deleteErr != nil {
+				log.Printf("[WARN] Failed to delete disk `%s` which couldn't be made sharable: %v\n", id, deleteErr)
+			}
+			return nil, err
+		}
+	}
+
+	d.VDIId = id
+	d.SrId = srId
+	return &d, nil
+}
+
+// newDiskMode checks the mode of a disk to create. The mode is the one of
+// the VBD, it can't be changed once the VBD is created, and a new disk is
+// empty: a read only disk can only be written to by another VM sharing it.
+func (v *validator) newDiskMode(d Disk) {
+	if d.ReadOnly && !d.Sharable {
+		v.addf("ReadOnly", "a new disk is empty, it can only be read only when it is sharable")
+	}
+}
+
+// SetVdiSharable allows or prevents several VMs from attaching the VDI at
+// the same time. Only VDIs on shared SRs can be made sharable.
+func (c *Client) SetVdiSharable(vdiId string, sharable bool) error {
+	if sharable {
+		vdis, err := c.GetVDIs(VDI{VDIId: vdiId})
+		if err != nil {
+			return err
+		}
 
-	return id, err
+		err = c.validateSharableSr(vdis[0].SrId)
+		if err != nil {
+			return err
+		}
+	}
+	return c.setVdiSharable(vdiId, sharable)
+}
+
+func (c *Client) setVdiSharable(vdiId string, sharable bool) error {
+	var success bool
+	params := map[string]interface{}{
+		"id":       vdiId,
+		"sharable": sharable,
+	}
+	return c.Call("vdi.set", params, &success)
+}
+
+func (c *Client) validateSharableSr(srId string) error {
+	sr, err := c.GetStorageRepositoryById(srId)
+	if err != nil {
+		return err
+	}
+
+	if !sr.Shared {
+		return errors.New(fmt.Sprintf("sharable disks require a shared SR but SR `%s` (%s) is not shared", sr.NameLabel, sr.SRType))
+	}
+	return nil
+}
+
+func (c *Client) resolveDiskSr(vm Vm, d Disk) (string, error) {
+	if d.SrId != "" {
+		return d.SrId, nil
+	}
+
+	poolId := vm.PoolId
+	if poolId == "" {
+		actual, err := c.GetVm(Vm{Id: vm.Id})
+		if err != nil {
+			return "", err
+		}
+		poolId = actual.PoolId
+	}
+
+	if d.SrNameLabel == "" {
+		pool, err := c.GetPoolById(poolId)
+		if err != nil {
+			return "", err
+		}
+
+		if pool.DefaultSR == "" {
+			return "", NoDefaultSrError{PoolId: poolId}
+		}
+		return pool.DefaultSR, nil
+	}
+
+	query := StorageRepository{NameLabel: d.SrNameLabel, PoolId: poolId}
+	srs, err := c.GetStorageRepository(query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(srs) > 1 {
+		return "", AmbiguousResultError{Query: query, Matches: len(srs)}
+	}
+	return srs[0].Id, nil
 }
 
 func (c *Client) DeleteDisk(vm Vm, d Disk) error {
@@ -212,6 +373,104 @@ func (c *Client) DisconnectDisk(d Disk) error {
 	return c.Call("vbd.disconnect", params, &success)
 }
 
+type DetachDiskOptions struct {
+	// Unplug the disk even when the guest refuses to release it. This can
+	// corrupt data the guest didn't flush to the disk yet.
+	Force bool
+}
+
+// DiskAttachedError is returned when attaching a VDI that isn't sharable
+// to a VM while it is already attached to another one.
+type DiskAttachedError struct {
+	VdiId string
+	VBDs  []string
+}
+
+func (e DiskAttachedError) Error() string {
+	return fmt.Sprintf("VDI `%s` is already attached by VBDs %v and is not sharable", e.VdiId, e.VBDs)
+}
+
+// getVdiById looks a VDI up by id only, unlike GetVDIs which also
+// matches VDIs by name.
+func (c *Client) getVdiById(id string) (VDI, error) {
+	vdis := map[string]VDI{}
+	params := map[string]interface{}{
+		"filter": map[string]interface{}{
+			"type": "VDI",
+			"id":   id,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &vdis); err != nil {
+		return VDI{}, err
+	}
+
+	vdi, ok := vdis[id]
+	if !ok {
+		return VDI{}, NotFound{Query: VDI{VDIId: id}}
+	}
+	return vdi, nil
+}
+
+// checkVdiAttachable makes sure a VDI exists and can be attached to
+// another VM.
+func (c *Client) checkVdiAttachable(id string) error {
+	vdi, err := c.getVdiById(id)
+	if err != nil {
+		return err
+	}
+	if len(vdi.VBDs) > 0 && !vdi.Sharable {
+		return DiskAttachedError{VdiId: id, VBDs: vdi.VBDs}
+	}
+	return nil
+}
+
+// DiskInUseError is returned by DetachDisk when the guest refused to
+// release the disk.
+type DiskInUseError struct {
+	VbdId string
+	VmId  string
+	Err   error
+}
+
+func (e DiskInUseError) Error() string {
+	return fmt.Sprintf("vm `%s` refused to release the disk attached by VBD `%s`, it is likely still in use: %v", e.VmId, e.VbdId, e.Err)
+}
+
+func (e DiskInUseError) Unwrap() error {
+	return e.Err
+}
+
+// DetachDisk unplugs a disk from its VM. On a running VM the guest is
+// asked to release the disk first and a DiskInUseError is returned if it
+// refuses, unless opts.Force is set.
+func (c *Client) DetachDisk(d Disk, opts DetachDiskOptions) error {
+	if !d.Attached {
+		return nil
+	}
+
+	err := c.DisconnectDisk(d)
+	if err == nil {
+		return nil
+	}
+
+	code, _ := xapiErrorCode(err)
+	if code != "DEVICE_DETACH_REJECTED" {
+		return err
+	}
+
+	if !opts.Force {
+		return DiskInUseError{VbdId: d.Id, VmId: d.VmId, Err: err}
+	}
+
+	log.Printf("[WARN] Forcing the detach of VBD `%s` from vm `%s` after the guest refused to release it\n", d.Id, d.VmId)
+	var success bool
+	params := map[string]interface{}{
+		"id":    d.Id,
+		"force": true,
+	}
+	return c.Call("vbd.disconnect", params, &success)
+}
+
 func (c *Client) UpdateVDI(d Disk) error {
 	var success bool
 	params := map[string]interface{}{
@@ -238,3 +497,303 @@ func (c *Client) InsertCd(vmId, cdId string) error {
 	}
 	return c.Call("vm.insertCd", params, &success)
 }
+
+const (
+	VdiFormatRaw = "raw"
+	VdiFormatVhd = "vhd"
+)
+
+// ImportVdiContent replaces the content of an existing VDI with the data
+// read from r and waits for the XAPI task importing it. The content is
+// streamed to XO as it is read so callers can pass large files or network
+// streams without buffering them in memory.
+//
+// For raw content the amount of data must match the size of the VDI. It
+// is checked before anything is sent when the size of r is known up front
+// (files, bytes.Reader, etc), while streaming otherwise, in which case the
+// upload fails once r holds more or less data than the VDI.
+func (c *Client) ImportVdiContent(ctx context.Context, vdiId string, r io.Reader, format string) error {
+	if format != VdiFormatRaw && format != VdiFormatVhd {
+		return errors.New(fmt.Sprintf("unsupported VDI import format `%s`, expected one of `%s` or `%s`", format, VdiFormatRaw, VdiFormatVhd))
+	}
+
+	vdis, err := c.GetVDIs(VDI{VDIId: vdiId})
+	if err != nil {
+		return err
+	}
+	if len(vdis) != 1 {
+		return errors.New(fmt.Sprintf("expected to find a single VDI with id `%s`, instead found %d", vdiId, len(vdis)))
+	}
+	vdi := vdis[0]
+
+	body := r
+	size := readerSize(r)
+	if format == VdiFormatRaw {
+		if size >= 0 && size != vdi.Size {
+			return errors.New(fmt.Sprintf("cannot import %d bytes of raw content into VDI `%s` of size %d", size, vdiId, vdi.Size))
+		}
+		body = &vdiSizeReader{r: r, vdiId: vdiId, size: vdi.Size}
+		size = vdi.Size
+	}
+
+	sr, err := c.GetStorageRepositoryById(vdi.SrId)
+	if err != nil {
+		return err
+	}
+
+	var res struct {
+		SendTo string `json:"$sendTo"`
+	}
+	params := map[string]interface{}{
+		"id":     vdiId,
+		"format": format,
+	}
+	err = c.Call("vdi.importContent", params, &res)
+	if err != nil {
+		return err
+	}
+
+	isTask := operationTask([]string{vdiImportTaskNameLabel(vdi, sr)}, vdi.VDIId, vdi.XapiRef)
+	op, err := c.startOperation(ctx, "vdi.importContent", vdi.PoolId, isTask, func() (string, error) {
+		return "", c.upload(ctx, res.SendTo, body, size, nil)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
+
+// vdiImportTaskNameLabel returns the name of the XAPI task
+// XO creates to import content into the VDI.
+func vdiImportTaskNameLabel(vdi VDI, sr StorageRepository) string {
+	return fmt.Sprintf("Importing content into VDI %s on SR %s", vdi.NameLabel, sr.NameLabel)
+}
+
+// readerSize returns the number of bytes that can be read from r
+// or -1 if it cannot be known without consuming the reader.
+func readerSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// vdiSizeReader fails the read once more data than the VDI can hold was
+// read from the underlying reader, or when it ends before filling it.
+type vdiSizeReader struct {
+	r     io.Reader
+	vdiId string
+	size  int64
+	read  int64
+}
+
+func (v *vdiSizeReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.read += int64(n)
+	if v.read > v.size || (err == io.EOF && v.read != v.size) {
+		return n, errors.New(fmt.Sprintf("raw content for VDI `%s` must be exactly %d bytes, read %d bytes", v.vdiId, v.size, v.read))
+	}
+	return n, err
+}
+
+// CbtNotSupportedError is returned when enabling changed block tracking
+// on a VDI whose SR doesn't support it.
+type CbtNotSupportedError struct {
+	VdiId string
+	Err   error
+}
+
+func (e CbtNotSupportedError) Error() string {
+	return fmt.Sprintf("the SR of VDI `%s` does not support changed block tracking: %v", e.VdiId, e.Err)
+}
+
+func (e CbtNotSupportedError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Client) EnableVdiCbt(vdiId string) error {
+	var success bool
+	params := map[string]interface{}{
+		"id": vdiId,
+	}
+	err := c.Call("vdi.enableCbt", params, &success)
+	if code, _ := xapiErrorCode(err); code == "SR_OPERATION_NOT_SUPPORTED" {
+		return CbtNotSupportedError{VdiId: vdiId, Err: err}
+	}
+	return featureDetect("vdi.enableCbt", err)
+}
+
+// DisableVdiCbt turns off changed block tracking for a VDI. Doing so
+// invalidates the delta chain of any backup relying on it, the next
+// delta backup of the disk will be a full one. force must be set to
+// acknowledge this.
+func (c *Client) DisableVdiCbt(vdiId string, force bool) error {
+	if !force {
+		return errors.New(fmt.Sprintf("refusing to disable CBT on VDI `%s` without force since it invalidates the delta chain of its backups", vdiId))
+	}
+
+	var success bool
+	params := map[string]interface{}{
+		"id": vdiId,
+	}
+	err := c.Call("vdi.disableCbt", params, &success)
+	return featureDetect("vdi.disableCbt", err)
+}
+
+// GetCbtStatusForVm returns whether changed block tracking
+// is enabled for each of the VM's disks keyed by VDI id.
+func (c *Client) GetCbtStatusForVm(vmId string) (map[string]bool, error) {
+	disks, err := c.GetDisks(&Vm{Id: vmId})
+	if err != nil {
+		return nil, err
+	}
+
+	status := map[string]bool{}
+	for _, disk := range disks {
+		status[disk.VDIId] = disk.CbtEnabled
+	}
+	return status, nil
+}
+
+// GetChangedBlocks returns the bitmap of the blocks of a VDI that changed
+// since baseSnapshotId, a snapshot of the VDI taken while CBT was enabled.
+// Each bit of the bitmap covers a 64KiB block of the VDI, in order.
+func (c *Client) GetChangedBlocks(vdiId, baseSnapshotId string) (io.ReadCloser, error) {
+	var bitmap string
+	params := map[string]interface{}{
+		"id":     vdiId,
+		"baseId": baseSnapshotId,
+	}
+	err := c.Call("vdi.listChangedBlocks", params, &bitmap)
+	if err != nil {
+		return nil, featureDetect("vdi.listChangedBlocks", err)
+	}
+
+	// XAPI encodes the bitmap in base64
+	b, err := base64.StdEncoding.DecodeString(bitmap)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("failed to decode the changed blocks of VDI `%s`: %v", vdiId, err))
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
+// ExportVdiDelta exports the blocks of a VDI that changed since
+// baseSnapshotId, a snapshot of the VDI, as a differencing VHD whose
+// parent is the base. The whole VDI is exported as a VHD when
+// baseSnapshotId is empty, e.g. for the first backup of a chain. The
+// caller must close the returned stream.
+func (c *Client) ExportVdiDelta(ctx context.Context, vdiId, baseSnapshotId string) (io.ReadCloser, error) {
+	var res struct {
+		GetFrom string `json:"$getFrom"`
+	}
+	params := map[string]interface{}{
+		"id":     vdiId,
+		"format": VdiFormatVhd,
+	}
+	if baseSnapshotId != "" {
+		params["baseId"] = baseSnapshotId
+	}
+	err := c.Call("vdi.exportContent", params, &res)
+	if err != nil {
+		return nil, featureDetect("vdi.exportContent", err)
+	}
+
+	return c.download(ctx, res.GetFrom)
+}
+
+// ExportVdiDeltaTo writes the blocks of a VDI that changed since
+// baseSnapshotId to w, in the format of ExportVdiDelta: a VHD delta, i.e.
+// a differencing VHD whose parent is the base snapshot. XO reads the
+// changed blocks over NBD, the way it does for delta backups, which
+// requires:
+//   - changed block tracking to be enabled on the VDI, see EnableVdiCbt,
+//     before the base snapshot was taken
+//   - NBD to be enabled on a network of the pool of the VDI
+//
+// An UnsupportedOnThisServerError is returned before anything is exported
+// when either is missing.
+func (c *Client) ExportVdiDeltaTo(ctx context.Context, vdiId, baseSnapshotId string, w io.Writer) error {
+	if baseSnapshotId == "" {
+		return errors.New(fmt.Sprintf("a base snapshot is required to export the delta of VDI `%s`", vdiId))
+	}
+
+	vdi, err := c.getVdiById(vdiId)
+	if err != nil {
+		return err
+	}
+	if !vdi.CbtEnabled {
+		return UnsupportedOnThisServerError{Method: "vdi.exportContent", Reason: fmt.Sprintf("changed block tracking isn't enabled on VDI `%s`", vdiId)}
+	}
+
+	var networksRes map[string]Network
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":    "network",
+			"$poolId": vdi.PoolId,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &networksRes); err != nil {
+		return err
+	}
+	nbd := false
+	for _, net := range networksRes {
+		nbd = nbd || net.Nbd
+	}
+	if !nbd {
+		return UnsupportedOnThisServerError{Method: "vdi.exportContent", Reason: fmt.Sprintf("NBD isn't enabled on any network of pool `%s`", vdi.PoolId)}
+	}
+
+	r, err := c.ExportVdiDelta(ctx, vdiId, baseSnapshotId)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return errors.New(fmt.Sprintf("failed to export the delta of VDI `%s`: %v", vdiId, err))
+	}
+	return nil
+}
+
+// VerifyVdiChecksum returns the hex encoded SHA-256 checksum of the raw
+// content of a VDI, to be compared with the checksum of a known good
+// copy. The content is streamed from XO rather than buffered so it can be
+// used on disks of any size, at the cost of reading the whole disk, which
+// can be interrupted with ctx.
+func (c *Client) VerifyVdiChecksum(ctx context.Context, vdiId string) (string, error) {
+	var res struct {
+		GetFrom string `json:"$getFrom"`
+	}
+	params := map[string]interface{}{
+		"id":     vdiId,
+		"format": VdiFormatRaw,
+	}
+	err := c.Call("vdi.exportContent", params, &res)
+	if err != nil {
+		return "", featureDetect("vdi.exportContent", err)
+	}
+
+	body, err := c.download(ctx, res.GetFrom)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return "", errors.New(fmt.Sprintf("failed to read the content of VDI `%s`: %v", vdiId, err))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Name of the XAPI task copying a VDI
+const vdiCopyTaskNameLabel = "Async.VDI.copy"
+
+type CopyVdiOptions struct {
+	// Called with the XAPI task copying the VDI every time it is polled
+	// while the copy runs. The task is found among the copies started
+	// during the call, concurrent copies of the pool can't be told apart.
+	Progress func(Task)
+	// Defaults to 2 seconds
+	PollInterval time.Duration
+}
+
+// CopyVdi copies the VDI like CopyVdiWithOptions, without tracking the
+// progress of the copy.
+func (c *Client) CopyVdi(vdiId, targetSrId, name string) (*VDI, error) {
+	return c.CopyVdiWithOptions(vdiId, targetSrId, name, CopyVdiOptions{})
+}
+
+// CopyVdiWithOptions makes a full, independent copy of the VDI on the
+// target SR and returns it. Unlike a migration, the source VDI stays on its
+// SR. The copy keeps the name of the source when name is empty.
+func (c *Client) CopyVdiWithOptions(vdiId, targetSrId, name string, opts CopyVdiOptions) (*VDI, error) {
+	source, err := c.getVdiById(vdiId)
+	if err != nil {
+		return nil, err
+	}
+	var sr StorageRepository
+	if err := c.getObjectOfType("SR", targetSrId, StorageRepository{Id: targetSrId}, &sr); err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = source.NameLabel
+	}
+	params := map[string]interface{}{
+		"id":         vdiId,
+		"sr":         targetSrId,
+		"name_label": name,
+	}
+
+	var stopTracking func()
+	if opts.Progress != nil {
+		stopTracking, err = c.trackVdiCopy(source.PoolId, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var copyId string
+	err = c.Call("vdi.copy", params, &copyId)
+	if stopTracking != nil {
+		stopTracking()
+	}
+	if err != nil {
+		return nil, featureDetect("vdi.copy", err)
+	}
+
+	vdi, err := c.getVdiById(copyId)
+	if err != nil {
+		return nil, err
+	}
+	return &vdi, nil
+}
+
+// trackVdiCopy polls the VDI copy tasks of the pool started after it is
+// called and reports them to opts.Progress until the returned function is
+// called.
+func (c *Client) trackVdiCopy(poolId string, opts CopyVdiOptions) (func(), error) {
+	tasks := func() (map[string]Task, error) {
+		var tasksRes map[string]Task
+		params := map[string]interface{}{
+			"filter": map[string]string{
+				"type":       "task",
+				"name_label": vdiCopyTaskNameLabel,
+				"$poolId":    poolId,
+			},
+		}
+		err := c.Call("xo.getAllObjects", params, &tasksRes)
+		return tasksRes, err
+	}
+	existing, err := tasks()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = taskPollInterval
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			current, err := tasks()
+			if err != nil {
+				c.logf("[WARN] Failed to poll the progress of the VDI copy: %v\n", err)
+				continue
+			}
+			for _, id := range sortedKeys(current) {
+				if _, ok := existing[id]; !ok && current[id].Status == TaskStatusPending {
+					opts.Progress(current[id])
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}, nil
+}
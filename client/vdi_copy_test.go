@@ -0,0 +1,91 @@
+package client
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVdiCopyRPC copies vdi-1 of sr-1 and runs the copy task for a while
+// when copying.
+func fakeVdiCopyRPC(copyDuration time.Duration) *fakeRPC {
+	var mu sync.Mutex
+	objects := []map[string]interface{}{
+		{"id": "vdi-1", "type": "VDI", "name_label": "debian base", "$SR": "sr-1", "$poolId": "pool-1", "size": 10737418240},
+		{"id": "sr-1", "type": "SR", "name_label": "local"},
+		{"id": "sr-2", "type": "SR", "name_label": "new nfs"},
+		{"id": "task-old", "type": "task", "name_label": vdiCopyTaskNameLabel, "$poolId": "pool-1", "status": "pending", "progress": 0.9},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if method != "vdi.copy" {
+			return fakeGetAllObjects(params, objects...), nil
+		}
+		task := func(status string, progress float64) map[string]interface{} {
+			return map[string]interface{}{"id": "task-copy", "type": "task", "name_label": vdiCopyTaskNameLabel, "$poolId": "pool-1", "status": status, "progress": progress}
+		}
+		objects = append(objects, task("pending", 0.5))
+		mu.Unlock()
+		time.Sleep(copyDuration)
+		mu.Lock()
+		objects[len(objects)-1] = task("success", 1)
+		objects = append(objects, map[string]interface{}{"id": "vdi-copy", "type": "VDI", "name_label": params["name_label"], "$SR": params["sr"], "$poolId": "pool-1", "size": 10737418240})
+		return "vdi-copy", nil
+	}}
+}
+
+func TestCopyVdi(t *testing.T) {
+	rpc := fakeVdiCopyRPC(0)
+	c := &Client{rpc: rpc}
+
+	vdi, err := c.CopyVdi("vdi-1", "sr-2", "")
+	if err != nil {
+		t.Fatalf("failed to copy the VDI with error: %v", err)
+	}
+	if vdi.VDIId == "vdi-1" || vdi.SrId != "sr-2" || vdi.NameLabel != "debian base" {
+		t.Errorf("expected a copy of vdi-1 named after it on sr-2 but received: %+v", vdi)
+	}
+
+	source, err := c.getVdiById("vdi-1")
+	if err != nil || source.SrId != "sr-1" {
+		t.Errorf("expected the source VDI to stay on sr-1 but received: %+v, %v", source, err)
+	}
+
+	if _, err := c.CopyVdi("vdi-1", "sr-missing", "seed"); err == nil {
+		t.Errorf("expected copying to a missing SR to fail")
+	}
+	if copies := rpc.callsTo("vdi.copy"); len(copies) != 1 {
+		t.Errorf("expected no copy to a missing SR to be attempted but received: %v", copies)
+	}
+}
+
+func TestCopyVdiWithOptions_progress(t *testing.T) {
+	c := &Client{rpc: fakeVdiCopyRPC(50 * time.Millisecond)}
+
+	var mu sync.Mutex
+	progress := []Task{}
+	opts := CopyVdiOptions{
+		PollInterval: time.Millisecond,
+		Progress: func(task Task) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, task)
+		},
+	}
+	if _, err := c.CopyVdiWithOptions("vdi-1", "sr-2", "seed", opts); err != nil {
+		t.Fatalf("failed to copy the VDI with error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(progress) == 0 {
+		t.Fatalf("expected the progress of the copy to be reported")
+	}
+	for _, task := range progress {
+		if task.Id != "task-copy" || task.Progress != 0.5 {
+			t.Errorf("expected only the task of the copy to be reported but received: %+v", task)
+		}
+	}
+}
@@ -1,8 +1,21 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetVmDisks(t *testing.T) {
@@ -106,3 +119,517 @@ func TestDisconnectDiskAndConnectDisk(t *testing.T) {
 		t.Errorf("failed to connect disk: %+v with error: %v", disks[1], err)
 	}
 }
+
+// fakeImportVdiContentRPC serves a 1024 bytes VDI, the upload server
+// creates the import task of the VDI with the given status.
+func fakeImportVdiContentRPC(taskStatus TaskStatus) (*fakeRPC, *httptest.Server, *[]byte) {
+	var mu sync.Mutex
+	var received []byte
+	objects := []map[string]interface{}{
+		{"id": "vdi-id", "type": "VDI", "name_label": "data", "size": 1024, "$SR": "sr-id", "$poolId": "pool-id"},
+		{"id": "sr-id", "type": "SR", "name_label": "Local storage"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received, _ = ioutil.ReadAll(r.Body)
+		// The import of another VDI named alike runs at the same time
+		objects = append(objects,
+			map[string]interface{}{"id": "task-a", "type": "task", "name_label": "Importing content into VDI data on SR Local storage", "status": TaskStatusSuccess, "$poolId": "pool-id", "applies_to": "other-vdi-id"},
+			map[string]interface{}{"id": "task-id", "type": "task", "name_label": "Importing content into VDI data on SR Local storage", "status": taskStatus, "$poolId": "pool-id", "applies_to": "vdi-id"},
+		)
+	}))
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			mu.Lock()
+			defer mu.Unlock()
+			return fakeGetAllObjects(params, objects...), nil
+		case "vdi.importContent":
+			return map[string]string{"$sendTo": "/api/upload/vdi"}, nil
+		}
+		return nil, nil
+	}}
+	return rpc, server, &received
+}
+
+func TestImportVdiContent_vhdFormat(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	rpc, server, received := fakeImportVdiContentRPC(TaskStatusSuccess)
+	defer server.Close()
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	content := []byte("conectix vhd content")
+	err := c.ImportVdiContent(context.Background(), "vdi-id", bytes.NewReader(content), VdiFormatVhd)
+	if err != nil {
+		t.Fatalf("failed to import VDI content with error: %v", err)
+	}
+
+	calls := rpc.callsTo("vdi.importContent")
+	if len(calls) != 1 {
+		t.Fatalf("expected a single vdi.importContent call, instead received %d", len(calls))
+	}
+	if calls[0].params["id"] != "vdi-id" || calls[0].params["format"] != "vhd" {
+		t.Errorf("expected vdi.importContent to receive the VDI id and vhd format, instead received: %v", calls[0].params)
+	}
+
+	if !bytes.Equal(*received, content) {
+		t.Errorf("expected upload to receive `%s` but received `%s`", content, *received)
+	}
+}
+
+func TestImportVdiContent_failedTask(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	rpc, server, _ := fakeImportVdiContentRPC(TaskStatusFailure)
+	defer server.Close()
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	err := c.ImportVdiContent(context.Background(), "vdi-id", bytes.NewReader(make([]byte, 1024)), VdiFormatRaw)
+
+	var failed TaskFailedError
+	if !errors.As(err, &failed) || failed.Id != "task-id" {
+		t.Errorf("expected the import to fail with the status of its task but received: %v", err)
+	}
+}
+
+func TestImportVdiContent_rawSizeMismatch(t *testing.T) {
+	rpc, server, _ := fakeImportVdiContentRPC(TaskStatusSuccess)
+	defer server.Close()
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	err := c.ImportVdiContent(context.Background(), "vdi-id", bytes.NewReader(make([]byte, 512)), VdiFormatRaw)
+	if err == nil {
+		t.Fatalf("expected raw import with a size mismatch to fail")
+	}
+	if len(rpc.callsTo("vdi.importContent")) != 0 {
+		t.Errorf("expected the size mismatch to be detected before calling vdi.importContent")
+	}
+
+	// The size of a stream is only known once it is read
+	for _, size := range []int{512, 2048} {
+		err = c.ImportVdiContent(context.Background(), "vdi-id", io.MultiReader(bytes.NewReader(make([]byte, size))), VdiFormatRaw)
+		if err == nil || !strings.Contains(err.Error(), "must be exactly 1024 bytes") {
+			t.Errorf("expected raw import of a %d bytes stream to fail but received: %v", size, err)
+		}
+	}
+}
+
+func TestImportVdiContent_rawStream(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	rpc, server, received := fakeImportVdiContentRPC(TaskStatusSuccess)
+	defer server.Close()
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	content := bytes.Repeat([]byte("x"), 1024)
+	err := c.ImportVdiContent(context.Background(), "vdi-id", io.MultiReader(bytes.NewReader(content)), VdiFormatRaw)
+	if err != nil {
+		t.Fatalf("failed to import VDI content with error: %v", err)
+	}
+	if !bytes.Equal(*received, content) {
+		t.Errorf("expected the whole stream to be uploaded but received %d bytes", len(*received))
+	}
+}
+
+func TestVDIUnmarshal_cbtEnabled(t *testing.T) {
+	var vdi VDI
+	err := json.Unmarshal([]byte(`{"id": "vdi-id", "type": "VDI", "cbt_enabled": true}`), &vdi)
+	if err != nil {
+		t.Fatalf("failed to unmarshal VDI with error: %v", err)
+	}
+
+	if !vdi.CbtEnabled {
+		t.Errorf("expected VDI to have CBT enabled")
+	}
+}
+
+func TestDisableVdiCbt_requiresForce(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := Client{rpc: rpc}
+
+	if err := c.DisableVdiCbt("vdi-id", false); err == nil {
+		t.Errorf("expected disabling CBT without force to fail")
+	}
+	if len(rpc.callsTo("vdi.disableCbt")) != 0 {
+		t.Errorf("expected vdi.disableCbt not to be called without force")
+	}
+
+	if err := c.DisableVdiCbt("vdi-id", true); err != nil {
+		t.Fatalf("failed to disable CBT with error: %v", err)
+	}
+	calls := rpc.callsTo("vdi.disableCbt")
+	if len(calls) != 1 || calls[0].params["id"] != "vdi-id" {
+		t.Errorf("expected a single vdi.disableCbt call for the VDI, instead received: %v", calls)
+	}
+}
+
+func TestGetChangedBlocks_afterEnablingCbt(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "vdi.listChangedBlocks" {
+			return "gAE=", nil
+		}
+		return true, nil
+	}}
+	c := Client{rpc: rpc}
+
+	if err := c.EnableVdiCbt("vdi-id"); err != nil {
+		t.Fatalf("failed to enable CBT with error: %v", err)
+	}
+
+	r, err := c.GetChangedBlocks("vdi-id", "snapshot-id")
+	if err != nil {
+		t.Fatalf("failed to get changed blocks with error: %v", err)
+	}
+	defer r.Close()
+
+	bitmap, err := ioutil.ReadAll(r)
+	if err != nil || !bytes.Equal(bitmap, []byte{0x80, 0x01}) {
+		t.Errorf("expected the decoded bitmap but received %v with error: %v", bitmap, err)
+	}
+
+	calls := rpc.callsTo("vdi.listChangedBlocks")
+	if len(calls) != 1 || calls[0].params["id"] != "vdi-id" || calls[0].params["baseId"] != "snapshot-id" {
+		t.Errorf("expected the changed blocks since the base snapshot to be requested but received: %v", calls)
+	}
+}
+
+func TestEnableVdiCbt_unsupportedSr(t *testing.T) {
+	c := Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return nil, xapiError("SR_OPERATION_NOT_SUPPORTED", "OpaqueRef:sr")
+	}}}
+
+	var notSupported CbtNotSupportedError
+	if err := c.EnableVdiCbt("vdi-id"); !errors.As(err, &notSupported) || notSupported.VdiId != "vdi-id" {
+		t.Errorf("expected a CbtNotSupportedError but received: %v", err)
+	}
+}
+
+func fakeDiskCreationRPC(objects ...map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "disk.create":
+			return "new-vdi", nil
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		}
+		return true, nil
+	}}
+}
+
+func TestCreateVmDisk_fallsBackToDefaultSr(t *testing.T) {
+	rpc := fakeDiskCreationRPC(
+		map[string]interface{}{"id": "vm-1", "type": "VM", "$poolId": "pool-1"},
+		map[string]interface{}{"id": "pool-1", "type": "pool", "default_SR": "sr-default"},
+	)
+	c := Client{rpc: rpc}
+
+	disk, err := c.CreateVmDisk(Vm{Id: "vm-1"}, Disk{VDI: VDI{NameLabel: "data", Size: 1024}})
+	if err != nil {
+		t.Fatalf("failed to create disk with error: %v", err)
+	}
+
+	if disk.SrId != "sr-default" || disk.VDIId != "new-vdi" {
+		t.Errorf("expected disk to be created on the default SR but received %+v", disk.VDI)
+	}
+	if sr := rpc.callsTo("disk.create")[0].params["sr"]; sr != "sr-default" {
+		t.Errorf("expected disk.create to be called with the default SR but received %v", sr)
+	}
+}
+
+func TestCreateVmDisk_resolvesSrNameWithinPool(t *testing.T) {
+	rpc := fakeDiskCreationRPC(
+		map[string]interface{}{"id": "sr-1", "type": "SR", "name_label": "fast", "$poolId": "pool-1"},
+		map[string]interface{}{"id": "sr-2", "type": "SR", "name_label": "fast", "$poolId": "pool-2"},
+	)
+	c := Client{rpc: rpc}
+
+	disk, err := c.CreateVmDisk(Vm{Id: "vm-1", PoolId: "pool-1"}, Disk{VDI: VDI{NameLabel: "data", SrNameLabel: "fast"}})
+	if err != nil {
+		t.Fatalf("failed to create disk with error: %v", err)
+	}
+
+	if disk.SrId != "sr-1" {
+		t.Errorf("expected disk to be created on sr-1 but received %s", disk.SrId)
+	}
+}
+
+func TestCreateVmDisk_ambiguousSrName(t *testing.T) {
+	rpc := fakeDiskCreationRPC(
+		map[string]interface{}{"id": "sr-1", "type": "SR", "name_label": "fast", "$poolId": "pool-1"},
+		map[string]interface{}{"id": "sr-2", "type": "SR", "name_label": "fast", "$poolId": "pool-1"},
+	)
+	c := Client{rpc: rpc}
+
+	_, err := c.CreateVmDisk(Vm{Id: "vm-1", PoolId: "pool-1"}, Disk{VDI: VDI{NameLabel: "data", SrNameLabel: "fast"}})
+	if _, ok := err.(AmbiguousResultError); !ok {
+		t.Fatalf("expected an AmbiguousResultError but received: %v", err)
+	}
+	if len(rpc.callsTo("disk.create")) != 0 {
+		t.Errorf("expected no disk to be created")
+	}
+}
+
+func TestCreateVmDisk_poolWithoutDefaultSr(t *testing.T) {
+	rpc := fakeDiskCreationRPC(
+		map[string]interface{}{"id": "pool-1", "type": "pool", "default_SR": ""},
+	)
+	c := Client{rpc: rpc}
+
+	_, err := c.CreateVmDisk(Vm{Id: "vm-1", PoolId: "pool-1"}, Disk{VDI: VDI{NameLabel: "data"}})
+	if e, ok := err.(NoDefaultSrError); !ok || e.PoolId != "pool-1" {
+		t.Fatalf("expected a NoDefaultSrError for pool-1 but received: %v", err)
+	}
+}
+
+func TestDetachDisk_guestRefusesThenForce(t *testing.T) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if params["force"] == true {
+			return true, nil
+		}
+		return nil, xapiError("DEVICE_DETACH_REJECTED", "VBD", "vbd-1", "device in use")
+	}}
+	c := Client{rpc: rpc}
+	disk := Disk{VBD: VBD{Id: "vbd-1", VmId: "vm-1", Attached: true}}
+
+	err := c.DetachDisk(disk, DetachDiskOptions{})
+	var inUse DiskInUseError
+	if !errors.As(err, &inUse) || inUse.VbdId != "vbd-1" {
+		t.Fatalf("expected a DiskInUseError for vbd-1 but received: %v", err)
+	}
+	if calls := rpc.callsTo("vbd.disconnect"); len(calls) != 1 {
+		t.Fatalf("expected a single unplug attempt without force but received %d", len(calls))
+	}
+
+	if err := c.DetachDisk(disk, DetachDiskOptions{Force: true}); err != nil {
+		t.Fatalf("expected forced detach to succeed but received: %v", err)
+	}
+	calls := rpc.callsTo("vbd.disconnect")
+	if len(calls) != 3 || calls[1].params["force"] != nil || calls[2].params["force"] != true {
+		t.Errorf("expected a clean unplug attempt followed by a forced one but received %v", calls)
+	}
+}
+
+func TestCreateVmDisk_sharableAndReadOnly(t *testing.T) {
+	rpc := fakeDiskCreationRPC(
+		map[string]interface{}{"id": "sr-shared", "type": "SR", "name_label": "iscsi", "shared": true},
+		map[string]interface{}{"id": "sr-local", "type": "SR", "name_label": "local", "shared": false},
+	)
+	c := Client{rpc: rpc}
+
+	disk := Disk{VBD: VBD{ReadOnly: true}, VDI: VDI{NameLabel: "quorum", SrId: "sr-shared", Sharable: true}}
+	if _, err := c.CreateVmDisk(Vm{Id: "vm-1"}, disk); err != nil {
+		t.Fatalf("failed to create sharable disk with error: %v", err)
+	}
+
+	if mode := rpc.callsTo("disk.create")[0].params["mode"]; mode != "RO" {
+		t.Errorf("expected a read only disk to be created with mode RO but received %v", mode)
+	}
+	calls := rpc.callsTo("vdi.set")
+	if len(calls) != 1 || calls[0].params["sharable"] != true || calls[0].params["id"] != "new-vdi" {
+		t.Errorf("expected the new VDI to be made sharable but received %v", calls)
+	}
+
+	disk.SrId = "sr-local"
+	if _, err := c.CreateVmDisk(Vm{Id: "vm-1"}, disk); err == nil {
+		t.Errorf("expected an error when creating a sharable disk on a local SR")
+	}
+	if len(rpc.callsTo("disk.create")) != 1 {
+		t.Errorf("expected no disk to be created on the local SR")
+	}
+}
+
+func TestCreateVmDisk_deletesDiskWhichCannotBeMadeSharable(t *testing.T) {
+	rpc := fakeDiskCreationRPC(map[string]interface{}{"id": "sr-shared", "type": "SR", "name_label": "iscsi", "shared": true})
+	handler := rpc.handler
+	rpc.handler = func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "vdi.set" {
+			return nil, errors.New("VDI_ON_BOOT_MODE_INCOMPATIBLE_WITH_OPERATION")
+		}
+		return handler(method, params)
+	}
+	c := Client{rpc: rpc}
+
+	disk := Disk{VDI: VDI{NameLabel: "quorum", SrId: "sr-shared", Sharable: true}}
+	if _, err := c.CreateVmDisk(Vm{Id: "vm-1"}, disk); err == nil {
+		t.Fatalf("expected the failure to make the disk sharable to be returned")
+	}
+	if calls := rpc.callsTo("vdi.delete"); len(calls) != 1 || calls[0].params["id"] != "new-vdi" {
+		t.Errorf("expected the created disk to be deleted but received: %v", calls)
+	}
+}
+
+func TestCreateVmDisk_readOnlyRequiresSharable(t *testing.T) {
+	rpc := fakeDiskCreationRPC()
+	c := Client{rpc: rpc}
+
+	disk := Disk{VBD: VBD{ReadOnly: true}, VDI: VDI{NameLabel: "data", SrId: "sr-shared"}}
+	_, err := c.CreateVmDisk(Vm{Id: "vm-1"}, disk)
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"ReadOnly"}) {
+		t.Errorf("expected a read only disk which isn't sharable to be rejected but received: %v", err)
+	}
+	if methods := rpc.methods(); len(methods) != 0 {
+		t.Errorf("expected nothing to be created but received: %v", methods)
+	}
+}
+
+func TestVerifyVdiChecksum(t *testing.T) {
+	content := bytes.Repeat([]byte("restored disk content"), 1<<16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/vdi" || r.Method != http.MethodGet {
+			t.Errorf("unexpected download request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "vdi.exportContent" {
+			return map[string]string{"$getFrom": "/api/download/vdi"}, nil
+		}
+		return nil, nil
+	}}
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	checksum, err := c.VerifyVdiChecksum(context.Background(), "vdi-id")
+	if err != nil {
+		t.Fatalf("failed to compute the VDI checksum with error: %v", err)
+	}
+	expected := fmt.Sprintf("%x", sha256.Sum256(content))
+	if checksum != expected {
+		t.Errorf("expected checksum %s but received %s", expected, checksum)
+	}
+
+	again, err := c.VerifyVdiChecksum(context.Background(), "vdi-id")
+	if err != nil || again != checksum {
+		t.Errorf("expected the same content to yield the same checksum %s but received %s with error: %v", checksum, again, err)
+	}
+
+	calls := rpc.callsTo("vdi.exportContent")
+	if calls[0].params["id"] != "vdi-id" || calls[0].params["format"] != "raw" {
+		t.Errorf("expected the raw content of the VDI to be exported but received: %v", calls[0].params)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.VerifyVdiChecksum(ctx, "vdi-id"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the checksum to stop with the context but received: %v", err)
+	}
+}
+
+func TestExportVdiDelta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		base     string
+		params   map[string]interface{}
+		download string
+	}{
+		{"snapshot-id", map[string]interface{}{"id": "vdi-id", "format": "vhd", "baseId": "snapshot-id"}, "/api/download/delta"},
+		{"", map[string]interface{}{"id": "vdi-id", "format": "vhd"}, "/api/download/full"},
+	}
+
+	for _, test := range tests {
+		rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+			if _, ok := params["baseId"]; ok {
+				return map[string]string{"$getFrom": "/api/download/delta"}, nil
+			}
+			return map[string]string{"$getFrom": "/api/download/full"}, nil
+		}}
+		c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+		r, err := c.ExportVdiDelta(context.Background(), "vdi-id", test.base)
+		if err != nil {
+			t.Fatalf("failed to export VDI with base `%s` with error: %v", test.base, err)
+		}
+		content, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil || string(content) != test.download {
+			t.Errorf("expected the content of %s to be streamed but received %s with error: %v", test.download, content, err)
+		}
+
+		calls := rpc.callsTo("vdi.exportContent")
+		if len(calls) != 1 || !reflect.DeepEqual(calls[0].params, test.params) {
+			t.Errorf("expected vdi.exportContent to be called with %v but received: %v", test.params, calls)
+		}
+	}
+}
+
+func fakeVdiDeltaRPC(cbt, nbd bool) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params,
+				map[string]interface{}{"id": "vdi-id", "type": "VDI", "$poolId": "pool-1", "cbt_enabled": cbt},
+				map[string]interface{}{"id": "net-1", "type": "network", "$poolId": "pool-1", "nbd": false},
+				map[string]interface{}{"id": "net-2", "type": "network", "$poolId": "pool-1", "nbd": nbd},
+				map[string]interface{}{"id": "net-3", "type": "network", "$poolId": "pool-2", "nbd": true},
+			), nil
+		case "vdi.exportContent":
+			return map[string]string{"$getFrom": "/api/download/delta"}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestExportVdiDeltaTo_preflight(t *testing.T) {
+	tests := []struct {
+		name   string
+		cbt    bool
+		nbd    bool
+		reason string
+	}{
+		{"cbt disabled", false, true, "changed block tracking isn't enabled on VDI `vdi-id`"},
+		{"nbd disabled", true, false, "NBD isn't enabled on any network of pool `pool-1`"},
+	}
+	for _, test := range tests {
+		rpc := fakeVdiDeltaRPC(test.cbt, test.nbd)
+		c := Client{rpc: rpc}
+
+		var unsupported UnsupportedOnThisServerError
+		err := c.ExportVdiDeltaTo(context.Background(), "vdi-id", "snapshot-id", &bytes.Buffer{})
+		if !errors.As(err, &unsupported) || unsupported.Reason != test.reason {
+			t.Errorf("%s: expected an UnsupportedOnThisServerError because %s but received: %v", test.name, test.reason, err)
+		}
+		if len(rpc.callsTo("vdi.exportContent")) != 0 {
+			t.Errorf("%s: expected nothing to be exported", test.name)
+		}
+	}
+}
+
+func TestExportVdiDeltaTo_streamsDelta(t *testing.T) {
+	delta := bytes.Repeat([]byte("cxsparse"), 512)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/delta" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(delta)
+	}))
+	defer server.Close()
+
+	rpc := fakeVdiDeltaRPC(true, true)
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	var w bytes.Buffer
+	if err := c.ExportVdiDeltaTo(context.Background(), "vdi-id", "snapshot-id", &w); err != nil {
+		t.Fatalf("failed to export the delta with error: %v", err)
+	}
+	if !bytes.Equal(w.Bytes(), delta) {
+		t.Errorf("expected the %d bytes of the delta to be written but received %d", len(delta), w.Len())
+	}
+	export := rpc.callsTo("vdi.exportContent")
+	if len(export) != 1 || export[0].params["baseId"] != "snapshot-id" || export[0].params["format"] != VdiFormatVhd {
+		t.Errorf("expected the VHD delta since the snapshot to be exported but received: %v", export)
+	}
+}
@@ -1,18 +1,38 @@
 package client
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type VIF struct {
 	Id         string `json:"id"`
+	XapiRef    string `json:"_xapiRef,omitempty"`
 	Attached   bool   `json:"attached"`
 	Network    string `json:"$network"`
 	Device     string `json:"device"`
 	MacAddress string `json:"MAC"`
 	VmId       string `json:"$VM"`
+
+	// Addresses assigned to the VIF from an IP pool
+	AllowedIpv4Addresses []string `json:"allowedIpv4Addresses"`
+	AllowedIpv6Addresses []string `json:"allowedIpv6Addresses"`
+	// Bandwidth limit of the VIF in kilobits per second, 0 for no limit.
+	// XO sets the VIF's `ratelimit` QoS algorithm with it.
+	RateLimitKbps int64 `json:"rateLimit,omitempty"`
+	// Addresses assigned from an IP pool followed by the ones reported by
+	// the guest tools. Only set by GetVIFsWithFilter and its helpers.
+	IpAddresses []string `json:"-"`
+
+	// When creating a VIF without a MacAddress, a MAC address starting
+	// with this OUI prefix (ex. 02:16:3e) is generated for it. This is
+	// not a real field as far as the XO api is concerned.
+	MacOui string `json:"-"`
 }
 
 func (v VIF) Compare(obj interface{}) bool {
@@ -49,6 +69,119 @@ func (c *Client) GetVIFs(vm *Vm) ([]VIF, error) {
 	return vifs, nil
 }
 
+// VIFFilter selects VIFs server side. Empty fields match any VIF.
+type VIFFilter struct {
+	VmId       string
+	NetworkId  string
+	MacAddress string
+	Attached   *bool
+}
+
+// GetVIFsWithFilter returns the VIFs matching every field of the filter
+// along with their IP addresses. An empty slice is returned when no VIF
+// matches.
+func (c *Client) GetVIFsWithFilter(filter VIFFilter) ([]VIF, error) {
+	f := map[string]interface{}{
+		"type": "VIF",
+	}
+	if filter.VmId != "" {
+		f["$VM"] = filter.VmId
+	}
+	if filter.NetworkId != "" {
+		f["$network"] = filter.NetworkId
+	}
+	if filter.MacAddress != "" {
+		mac, err := NormalizeMacAddress(filter.MacAddress)
+		if err != nil {
+			return nil, err
+		}
+		f["MAC"] = mac
+	}
+	if filter.Attached != nil {
+		f["attached"] = *filter.Attached
+	}
+
+	objs := map[string]VIF{}
+	params := map[string]interface{}{
+		"filter": f,
+	}
+	err := c.Call("xo.getAllObjects", params, &objs)
+	if err != nil {
+		return nil, err
+	}
+
+	vifs := []VIF{}
+	for _, vif := range objs {
+		vifs = append(vifs, vif)
+	}
+	sort.Slice(vifs, func(i, j int) bool {
+		return vifs[i].Id < vifs[j].Id
+	})
+
+	if len(vifs) == 0 {
+		return vifs, nil
+	}
+
+	vms := map[string]Vm{}
+	err = c.getAllObjectsOfXoType("VM", &vms)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vifs {
+		vifs[i].IpAddresses = vifIpAddresses(vifs[i], vms[vifs[i].VmId])
+	}
+	return vifs, nil
+}
+
+// vifIpAddresses combines the addresses of the VIF's IP pool assignments
+// with the ones the guest reports for its device. The guest metrics are
+// keyed by `<device>/ipv4/<index>` or `<device>/ipv6/<index>`.
+func vifIpAddresses(vif VIF, vm Vm) []string {
+	addresses := []string{}
+	for _, addr := range append(append([]string{}, vif.AllowedIpv4Addresses...), vif.AllowedIpv6Addresses...) {
+		if !stringInSlice(addr, addresses) {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	keys := []string{}
+	for key := range vm.Addresses {
+		if strings.HasPrefix(key, vif.Device+"/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if addr := vm.Addresses[key]; !stringInSlice(addr, addresses) {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+func (c *Client) GetVIFsByNetwork(networkId string) ([]VIF, error) {
+	return c.GetVIFsWithFilter(VIFFilter{NetworkId: networkId})
+}
+
+// GetVIFByMac returns the VIF with the given MAC address, which is
+// normalized before the lookup.
+func (c *Client) GetVIFByMac(mac string) (*VIF, error) {
+	vifs, err := c.GetVIFsWithFilter(VIFFilter{MacAddress: mac})
+	if err != nil {
+		return nil, err
+	}
+
+	query := VIF{MacAddress: mac}
+	switch len(vifs) {
+	case 0:
+		return nil, NotFound{Query: query}
+	case 1:
+		return &vifs[0], nil
+	}
+	return nil, AmbiguousResultError{Query: query, Matches: len(vifs)}
+}
+
 func (c *Client) GetVIF(vifReq *VIF) (*VIF, error) {
 
 	obj, err := c.FindFromGetAllObjects(VIF{
@@ -69,22 +202,170 @@ func (c *Client) GetVIF(vifReq *VIF) (*VIF, error) {
 }
 
 func (c *Client) CreateVIF(vm *Vm, vif *VIF) (*VIF, error) {
+	mac := vif.MacAddress
+	var err error
+	if mac != "" {
+		mac, err = NormalizeMacAddress(mac)
+	} else if vif.MacOui != "" {
+		mac, err = c.generateUniqueMacAddress(vm, vif.MacOui)
+	}
+
+	if err != nil {
+		return nil, err
+	}
 
 	var id string
 	params := map[string]interface{}{
 		"network": vif.Network,
 		"vm":      vm.Id,
-		"mac":     vif.MacAddress,
+		"mac":     mac,
 	}
-	err := c.Call("vm.createInterface", params, &id)
+	err = c.Call("vm.createInterface", params, &id)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// vm.createInterface doesn't support QoS, the limit is set afterwards
+	if vif.RateLimitKbps != 0 {
+		err = c.setVIFRateLimit(id, vif.RateLimitKbps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return c.GetVIF(&VIF{Id: id})
 }
 
+// UpdateVIF updates the rate limit of the VIF, a RateLimitKbps of 0 removes
+// it.
+func (c *Client) UpdateVIF(vif *VIF) (*VIF, error) {
+	err := c.setVIFRateLimit(vif.Id, vif.RateLimitKbps)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetVIF(&VIF{Id: vif.Id})
+}
+
+func (c *Client) setVIFRateLimit(id string, kbps int64) error {
+	if kbps < 0 {
+		return errors.New(fmt.Sprintf("the rate limit of VIF `%s` must be positive or 0 for no limit, received %d kbps", id, kbps))
+	}
+
+	var rateLimit interface{}
+	if kbps != 0 {
+		rateLimit = kbps
+	}
+	params := map[string]interface{}{
+		"id":        id,
+		"rateLimit": rateLimit,
+	}
+	var success bool
+	return c.Call("vif.set", params, &success)
+}
+
+// Number of times a MAC address is regenerated when it
+// collides with one of the VM's existing VIFs.
+const macGenerationAttempts = 10
+
+func (c *Client) generateUniqueMacAddress(vm *Vm, oui string) (string, error) {
+	vifs, err := c.GetVIFs(vm)
+	if err != nil {
+		return "", err
+	}
+
+	existing := []string{}
+	for _, vif := range vifs {
+		if mac, err := NormalizeMacAddress(vif.MacAddress); err == nil {
+			existing = append(existing, mac)
+		}
+	}
+
+	for i := 0; i < macGenerationAttempts; i++ {
+		mac, err := GenerateMacAddress(oui)
+		if err != nil {
+			return "", err
+		}
+
+		if !stringInSlice(mac, existing) {
+			return mac, nil
+		}
+	}
+	return "", errors.New(fmt.Sprintf("failed to generate a MAC address with OUI `%s` that is unique within VM `%s`", oui, vm.Id))
+}
+
+// GenerateMacAddress returns a MAC address starting with the given OUI
+// prefix (ex. 02:16:3e) where the remaining 3 octets are random. The OUI
+// must be a unicast prefix.
+func GenerateMacAddress(oui string) (string, error) {
+	prefix, err := parseMacOctets(oui, 3)
+	if err != nil {
+		return "", err
+	}
+	if err := validateUnicast(oui, prefix[0]); err != nil {
+		return "", err
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return formatMacOctets(append(prefix, suffix...)), nil
+}
+
+// NormalizeMacAddress validates that mac is a unicast MAC address using
+// either `:` or `-` separators and returns it in the lower case colon
+// separated form used by XO.
+func NormalizeMacAddress(mac string) (string, error) {
+	octets, err := parseMacOctets(mac, 6)
+	if err != nil {
+		return "", err
+	}
+	if err := validateUnicast(mac, octets[0]); err != nil {
+		return "", err
+	}
+	return formatMacOctets(octets), nil
+}
+
+func parseMacOctets(s string, count int) ([]byte, error) {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ':' || r == '-'
+	})
+	if len(parts) != count {
+		return nil, errors.New(fmt.Sprintf("`%s` is not a valid MAC address prefix, expected %d octets", s, count))
+	}
+
+	octets := make([]byte, 0, count)
+	for _, part := range parts {
+		if len(part) != 2 {
+			return nil, errors.New(fmt.Sprintf("`%s` is not a valid MAC address, octet `%s` must be 2 hex digits", s, part))
+		}
+		b, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("`%s` is not a valid MAC address, octet `%s` is not hexadecimal", s, part))
+		}
+		octets = append(octets, byte(b))
+	}
+	return octets, nil
+}
+
+// The least significant bit of the first octet marks multicast
+// addresses which cannot be assigned to an interface.
+func validateUnicast(mac string, firstOctet byte) error {
+	if firstOctet&0x01 != 0 {
+		return errors.New(fmt.Sprintf("`%s` is a multicast MAC address, VIFs require a unicast address", mac))
+	}
+	return nil
+}
+
+func formatMacOctets(octets []byte) string {
+	parts := make([]string, 0, len(octets))
+	for _, b := range octets {
+		parts = append(parts, fmt.Sprintf("%02x", b))
+	}
+	return strings.Join(parts, ":")
+}
+
 func (c *Client) ConnectVIF(vifReq *VIF) (err error) {
 	vif, err := c.GetVIF(vifReq)
 
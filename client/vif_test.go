@@ -1,6 +1,7 @@
 package client
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -91,3 +92,212 @@ func TestCreateVIF_DeleteVIF(t *testing.T) {
 		t.Errorf("failed to delete the VIF with error: %v", err)
 	}
 }
+
+func TestGenerateMacAddress_withOui(t *testing.T) {
+	mac, err := GenerateMacAddress("02:AB:cd")
+	if err != nil {
+		t.Fatalf("failed to generate MAC address with error: %v", err)
+	}
+
+	if !strings.HasPrefix(mac, "02:ab:cd:") {
+		t.Errorf("expected MAC address `%s` to start with the OUI", mac)
+	}
+
+	octets, err := parseMacOctets(mac, 6)
+	if err != nil {
+		t.Fatalf("generated MAC address `%s` is invalid: %v", mac, err)
+	}
+	if octets[0]&0x01 != 0 {
+		t.Errorf("expected MAC address `%s` to be unicast", mac)
+	}
+	if octets[0]&0x02 == 0 {
+		t.Errorf("expected MAC address `%s` to be locally administered", mac)
+	}
+}
+
+func TestGenerateMacAddress_rejectsMulticastOui(t *testing.T) {
+	if _, err := GenerateMacAddress("03:ab:cd"); err == nil {
+		t.Errorf("expected a multicast OUI to be rejected")
+	}
+}
+
+func TestNormalizeMacAddress(t *testing.T) {
+	tests := []struct {
+		mac      string
+		expected string
+		valid    bool
+	}{
+		{mac: "E8:61:7E:8E:F1:81", expected: "e8:61:7e:8e:f1:81", valid: true},
+		{mac: "e8-61-7e-8e-f1-81", expected: "e8:61:7e:8e:f1:81", valid: true},
+		{mac: "01:00:5e:00:00:01", valid: false},
+		{mac: "e8:61:7e:8e:f1", valid: false},
+		{mac: "e8:61:7e:8e:f1:zz", valid: false},
+	}
+
+	for _, test := range tests {
+		mac, err := NormalizeMacAddress(test.mac)
+		if test.valid && (err != nil || mac != test.expected) {
+			t.Errorf("expected `%s` to normalize to `%s`, instead received `%s` with error: %v", test.mac, test.expected, mac, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected `%s` to be rejected", test.mac)
+		}
+	}
+}
+
+func fakeVifRPC(objects ...map[string]interface{}) *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, objects...), nil
+	}}
+}
+
+func TestGetVIFByMac_normalizesMac(t *testing.T) {
+	rpc := fakeVifRPC(
+		map[string]interface{}{"id": "vif-1", "type": "VIF", "MAC": "02:16:3e:aa:bb:cc", "$VM": "vm-1", "device": "0", "allowedIpv4Addresses": []string{"10.0.0.5"}},
+		map[string]interface{}{"id": "vif-2", "type": "VIF", "MAC": "02:16:3e:aa:bb:cd", "$VM": "vm-1", "device": "1"},
+		map[string]interface{}{"id": "vm-1", "type": "VM", "addresses": map[string]string{"0/ipv4/0": "10.0.0.5", "0/ipv6/0": "fe80::1", "1/ipv4/0": "10.0.1.5"}},
+	)
+	c := Client{rpc: rpc}
+
+	vif, err := c.GetVIFByMac("02-16-3E-AA-BB-CC")
+	if err != nil {
+		t.Fatalf("failed to get VIF by MAC with error: %v", err)
+	}
+
+	if vif.Id != "vif-1" {
+		t.Errorf("expected to find vif-1 but found %s", vif.Id)
+	}
+	if filter := rpc.calls[0].params["filter"].(map[string]interface{}); filter["MAC"] != "02:16:3e:aa:bb:cc" {
+		t.Errorf("expected the MAC to be normalized in the server side filter but received %v", filter)
+	}
+	if strings.Join(vif.IpAddresses, ",") != "10.0.0.5,fe80::1" {
+		t.Errorf("expected the VIF's pool and guest addresses but received %v", vif.IpAddresses)
+	}
+}
+
+func TestGetVIFByMac_ambiguousAndNotFound(t *testing.T) {
+	c := Client{rpc: fakeVifRPC(
+		map[string]interface{}{"id": "vif-1", "type": "VIF", "MAC": "02:16:3e:aa:bb:cc"},
+		map[string]interface{}{"id": "vif-2", "type": "VIF", "MAC": "02:16:3e:aa:bb:cc"},
+	)}
+
+	if _, err := c.GetVIFByMac("02:16:3e:aa:bb:cc"); err == nil {
+		t.Errorf("expected an error for a MAC shared by several VIFs")
+	} else if _, ok := err.(AmbiguousResultError); !ok {
+		t.Errorf("expected an AmbiguousResultError but received: %v", err)
+	}
+
+	if _, err := c.GetVIFByMac("02:16:3e:00:00:01"); err == nil {
+		t.Errorf("expected an error for an unknown MAC")
+	} else if _, ok := err.(NotFound); !ok {
+		t.Errorf("expected a NotFound error but received: %v", err)
+	}
+}
+
+func TestGetVIFsByNetwork_withoutVifs(t *testing.T) {
+	rpc := fakeVifRPC(
+		map[string]interface{}{"id": "vif-1", "type": "VIF", "$network": "net-1"},
+	)
+	c := Client{rpc: rpc}
+
+	vifs, err := c.GetVIFsByNetwork("net-2")
+	if err != nil {
+		t.Fatalf("failed to get VIFs by network with error: %v", err)
+	}
+
+	if vifs == nil || len(vifs) != 0 {
+		t.Errorf("expected an empty slice of VIFs but received %v", vifs)
+	}
+	if len(rpc.calls) != 1 {
+		t.Errorf("expected a single call when no VIF matches but received %v", rpc.methods())
+	}
+}
+
+// fakeVifQosRPC creates VIFs and applies their rate limit the way XO
+// reports it, as the `rateLimit` of the VIF.
+func fakeVifQosRPC() *fakeRPC {
+	vifs := map[string]map[string]interface{}{}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "vm.createInterface":
+			vifs["vif-1"] = map[string]interface{}{"id": "vif-1", "type": "VIF", "$VM": params["vm"], "$network": params["network"]}
+			return "vif-1", nil
+		case "vif.set":
+			vif := vifs[params["id"].(string)]
+			if params["rateLimit"] == nil {
+				delete(vif, "rateLimit")
+			} else {
+				vif["rateLimit"] = params["rateLimit"]
+			}
+			return true, nil
+		}
+		objects := []map[string]interface{}{}
+		for _, vif := range vifs {
+			objects = append(objects, vif)
+		}
+		return fakeGetAllObjects(params, objects...), nil
+	}}
+}
+
+func TestCreateVIF_withRateLimit(t *testing.T) {
+	rpc := fakeVifQosRPC()
+	c := Client{rpc: rpc}
+
+	vif, err := c.CreateVIF(&Vm{Id: "vm-1"}, &VIF{Network: "net-1", RateLimitKbps: 10240})
+	if err != nil {
+		t.Fatalf("failed to create VIF with error: %v", err)
+	}
+
+	sets := rpc.callsTo("vif.set")
+	if len(sets) != 1 || sets[0].params["id"] != "vif-1" || sets[0].params["rateLimit"] != float64(10240) {
+		t.Errorf("expected the rate limit to be set on the created VIF but received: %v", sets)
+	}
+	if vif.RateLimitKbps != 10240 {
+		t.Errorf("expected the VIF to report a rate limit of 10240 kbps but received: %d", vif.RateLimitKbps)
+	}
+}
+
+func TestCreateVIF_withoutRateLimit(t *testing.T) {
+	rpc := fakeVifQosRPC()
+	c := Client{rpc: rpc}
+
+	vif, err := c.CreateVIF(&Vm{Id: "vm-1"}, &VIF{Network: "net-1"})
+	if err != nil {
+		t.Fatalf("failed to create VIF with error: %v", err)
+	}
+
+	if sets := rpc.callsTo("vif.set"); len(sets) != 0 {
+		t.Errorf("expected no rate limit to be set but received: %v", sets)
+	}
+	if vif.RateLimitKbps != 0 {
+		t.Errorf("expected the VIF to be unlimited but received: %d kbps", vif.RateLimitKbps)
+	}
+}
+
+func TestUpdateVIF_rateLimit(t *testing.T) {
+	rpc := fakeVifQosRPC()
+	c := Client{rpc: rpc}
+
+	vif, err := c.CreateVIF(&Vm{Id: "vm-1"}, &VIF{Network: "net-1", RateLimitKbps: 1024})
+	if err != nil {
+		t.Fatalf("failed to create VIF with error: %v", err)
+	}
+
+	vif.RateLimitKbps = 0
+	vif, err = c.UpdateVIF(vif)
+	if err != nil {
+		t.Fatalf("failed to update VIF with error: %v", err)
+	}
+
+	sets := rpc.callsTo("vif.set")
+	if rateLimit, ok := sets[len(sets)-1].params["rateLimit"]; !ok || rateLimit != nil {
+		t.Errorf("expected a null rate limit to be sent to remove it but received: %v", sets[len(sets)-1].params)
+	}
+	if vif.RateLimitKbps != 0 {
+		t.Errorf("expected the VIF to be unlimited but received: %d kbps", vif.RateLimitKbps)
+	}
+
+	if _, err := c.UpdateVIF(&VIF{Id: "vif-1", RateLimitKbps: -1}); err == nil {
+		t.Errorf("expected an error for a negative rate limit")
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Placeholder of the VLAN in the name template of CreateVlanNetworks
+const vlanNamePlaceholder = "{{vlan}}"
+
+type VlanNetworksReport struct {
+	// Networks created, sorted by VLAN
+	Created []Network
+	// Id of the network already carrying each VLAN skipped
+	Skipped map[int]string
+	// Error of each VLAN whose network couldn't be created
+	Failed map[int]error
+}
+
+// FailedVlans returns the VLANs whose network couldn't be created, sorted.
+func (r VlanNetworksReport) FailedVlans() []int {
+	vlans := []int{}
+	for vlan := range r.Failed {
+		vlans = append(vlans, vlan)
+	}
+	sort.Ints(vlans)
+	return vlans
+}
+
+// CreateVlanNetworks creates a network in the pool for each VLAN on the
+// device of the PIF, named after nameTemplate with `{{vlan}}` replaced by
+// the VLAN, e.g. `prod-vlan-{{vlan}}`. The VLANs the device already carries
+// are skipped. The networks are created concurrently and every VLAN is
+// attempted, an error is returned alongside the report when any of them
+// failed.
+func (c *Client) CreateVlanNetworks(poolId, pifId string, vlans []int, nameTemplate string) (*VlanNetworksReport, error) {
+	if !strings.Contains(nameTemplate, vlanNamePlaceholder) {
+		return nil, errors.New(fmt.Sprintf("the name template `%s` must contain %s to give each network its own name", nameTemplate, vlanNamePlaceholder))
+	}
+
+	existing, err := c.getPifVlanNetworks(pifId)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VlanNetworksReport{
+		Created: []Network{},
+		Skipped: map[int]string{},
+		Failed:  map[int]error{},
+	}
+	pending := []int{}
+	seen := map[int]bool{}
+	for _, vlan := range vlans {
+		if networkId, ok := existing[vlan]; ok {
+			report.Skipped[vlan] = networkId
+			continue
+		}
+		if !seen[vlan] {
+			seen[vlan] = true
+			pending = append(pending, vlan)
+		}
+	}
+	sort.Ints(pending)
+
+	created := make([]*Network, len(pending))
+	errs := make([]error, len(pending))
+	forEachConcurrently(len(pending), defaultConcurrency, func(i int) {
+		created[i], errs[i] = c.CreateNetwork(Network{
+			PoolId:    poolId,
+			NameLabel: strings.Replace(nameTemplate, vlanNamePlaceholder, strconv.Itoa(pending[i]), -1),
+			PIFId:     pifId,
+			Vlan:      pending[i],
+		})
+	})
+	for i, vlan := range pending {
+		if errs[i] != nil {
+			report.Failed[vlan] = errs[i]
+			continue
+		}
+		report.Created = append(report.Created, *created[i])
+	}
+
+	log.Printf("[DEBUG] Created the VLAN networks of pif `%s`, skipped: %v, failed: %v\n", pifId, report.Skipped, report.Failed)
+	if failed := report.FailedVlans(); len(failed) > 0 {
+		return report, errors.New(fmt.Sprintf("failed to create the networks of VLANs %v, first error: %v", failed, report.Failed[failed[0]]))
+	}
+	return report, nil
+}
+
+// getPifVlanNetworks returns the network of each VLAN carried by the device
+// of the PIF on its host.
+func (c *Client) getPifVlanNetworks(pifId string) (map[int]string, error) {
+	var pif PIF
+	if err := c.getObjectOfType("PIF", pifId, PIF{Id: pifId}, &pif); err != nil {
+		return nil, err
+	}
+
+	var pifsRes map[string]PIF
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type":   "PIF",
+			"$host":  pif.Host,
+			"device": pif.Device,
+		},
+	}
+	if err := c.Call("xo.getAllObjects", params, &pifsRes); err != nil {
+		return nil, err
+	}
+
+	networks := map[int]string{}
+	for _, vlanPif := range pifsRes {
+		if vlanPif.Vlan > 0 {
+			networks[vlanPif.Vlan] = vlanPif.Network
+		}
+	}
+	return networks, nil
+}
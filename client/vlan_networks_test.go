@@ -0,0 +1,85 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeVlanNetworksRPC has VLANs 101 and 103 on eth1 of the host of PIF
+// testUuid2, and fails to create the network of VLAN 104.
+func fakeVlanNetworksRPC() *fakeRPC {
+	var mu sync.Mutex
+	objects := []map[string]interface{}{
+		{"id": testUuid2, "type": "PIF", "device": "eth1", "$host": "host-1", "$network": "net-trunk", "vlan": 0},
+		{"id": "pif-101", "type": "PIF", "device": "eth1", "$host": "host-1", "$network": "net-101", "vlan": 101},
+		{"id": "pif-103", "type": "PIF", "device": "eth1", "$host": "host-1", "$network": "net-103", "vlan": 103},
+		{"id": "pif-eth0-102", "type": "PIF", "device": "eth0", "$host": "host-1", "$network": "net-eth0-102", "vlan": 102},
+		{"id": "pif-host-2-105", "type": "PIF", "device": "eth1", "$host": "host-2", "$network": "net-other-host", "vlan": 105},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "network.create":
+			vlan := int(params["vlan"].(float64))
+			if vlan == 104 {
+				return nil, errors.New("VLAN_TAG_INVALID")
+			}
+			id := fmt.Sprintf("new-net-%d", vlan)
+			objects = append(objects, map[string]interface{}{"id": id, "type": "network", "name_label": params["name"], "$poolId": params["pool"]})
+			return id, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestCreateVlanNetworks_skipsExistingVlans(t *testing.T) {
+	rpc := fakeVlanNetworksRPC()
+	c := &Client{rpc: rpc}
+
+	report, err := c.CreateVlanNetworks(testUuid, testUuid2, []int{105, 101, 102, 103, 102}, "prod-vlan-{{vlan}}")
+	if err != nil {
+		t.Fatalf("failed to create the VLAN networks with error: %v", err)
+	}
+
+	names := []string{}
+	for _, net := range report.Created {
+		names = append(names, net.NameLabel)
+	}
+	if !reflect.DeepEqual(names, []string{"prod-vlan-102", "prod-vlan-105"}) {
+		t.Errorf("expected the networks of VLANs 102 and 105 to be created once but received: %v", names)
+	}
+	if !reflect.DeepEqual(report.Skipped, map[int]string{101: "net-101", 103: "net-103"}) {
+		t.Errorf("expected VLANs 101 and 103 to be skipped but received: %v", report.Skipped)
+	}
+	for _, create := range rpc.callsTo("network.create") {
+		if create.params["pif"] != testUuid2 || create.params["pool"] != testUuid {
+			t.Errorf("expected the networks to be created on the PIF in the pool but received: %v", create.params)
+		}
+	}
+}
+
+func TestCreateVlanNetworks_partialFailure(t *testing.T) {
+	c := &Client{rpc: fakeVlanNetworksRPC()}
+
+	report, err := c.CreateVlanNetworks(testUuid, testUuid2, []int{101, 104, 106}, "prod-vlan-{{vlan}}")
+	if err == nil {
+		t.Fatalf("expected the failure of VLAN 104 to be returned")
+	}
+	if len(report.Created) != 1 || report.Created[0].NameLabel != "prod-vlan-106" {
+		t.Errorf("expected the network of VLAN 106 to be created despite the failure but received: %+v", report.Created)
+	}
+	if len(report.Skipped) != 1 || !reflect.DeepEqual(report.FailedVlans(), []int{104}) {
+		t.Errorf("expected VLAN 101 to be skipped and VLAN 104 to fail but received: %+v", report)
+	}
+
+	if _, err := c.CreateVlanNetworks(testUuid, testUuid2, []int{107}, "prod-vlan"); err == nil {
+		t.Errorf("expected a template without the VLAN placeholder to be rejected")
+	}
+}
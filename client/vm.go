@@ -1,13 +1,19 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/vatesfr/xo-sdk-go/client/wait"
 )
 
 type allObjectResponse struct {
@@ -20,9 +26,9 @@ type CPUs struct {
 }
 
 type MemoryObject struct {
-	Dynamic []int `json:"dynamic"`
-	Static  []int `json:"static"`
-	Size    int   `json:"size"`
+	Dynamic []int64 `json:"dynamic"`
+	Static  []int64 `json:"static"`
+	Size    int64   `json:"size"`
 }
 
 type Boot struct {
@@ -62,38 +68,87 @@ type Vm struct {
 	Boot               Boot              `json:"boot,omitempty"`
 	Type               string            `json:"type,omitempty"`
 	Id                 string            `json:"id,omitempty"`
+	XapiRef            string            `json:"_xapiRef,omitempty"`
 	AffinityHost       string            `json:"affinityHost,omitempty"`
 	NameDescription    string            `json:"name_description"`
 	NameLabel          string            `json:"name_label"`
 	CPUs               CPUs              `json:"CPUs"`
 	ExpNestedHvm       bool              `json:"expNestedHvm,omitempty"`
 	Memory             MemoryObject      `json:"memory"`
-	PowerState         string            `json:"power_state"`
+	PowerState         PowerState        `json:"power_state"`
+	CurrentOperations  map[string]string `json:"current_operations,omitempty"`
 	VIFs               []string          `json:"VIFs"`
 	VBDs               []string          `json:"$VBDs"`
+	Snapshots          []string          `json:"snapshots"`
 	VirtualizationMode string            `json:"virtualizationMode"`
 	PoolId             string            `json:"$poolId"`
+	InstallTime        Timestamp         `json:"installTime"`
+	StartTime          Timestamp         `json:"startTime"`
+	SnapshotTime       Timestamp         `json:"snapshot_time"`
 	Template           string            `json:"template"`
 	AutoPoweron        bool              `json:"auto_poweron"`
 	HA                 string            `json:"high_availability"`
 	CloudConfig        string            `json:"cloudConfig"`
 	ResourceSet        string            `json:"resourceSet,omitempty"`
-	// TODO: (#145) Uncomment this once issues with secure_boot have been figured out
-	// SecureBoot         bool              `json:"secureBoot,omitempty"`
-	Tags       []string `json:"tags"`
-	Videoram   Videoram `json:"videoram,omitempty"`
-	Vga        string   `json:"vga,omitempty"`
-	StartDelay int      `json:startDelay,omitempty"`
-	Host       string   `json:"$container"`
+	SecureBoot         bool              `json:"secureBoot,omitempty"`
+	Tags               []string          `json:"tags"`
+	Videoram           Videoram          `json:"videoram,omitempty"`
+	Vga                string            `json:"vga,omitempty"`
+	StartDelay         int               `json:"startDelay,omitempty"`
+	StartOrder         int               `json:"order"`
+	Host               string            `json:"$container"`
+	// UpdateVm leaves the pinning of the VM unchanged when nil and unpins
+	// it with UnpinnedCpuMask
+	VcpuMask CpuMask `json:"cpuMask,omitempty"`
+
+	// Reported by the guest tools, e.g. `name`, `distro` and `major`.
+	// Empty when the tools aren't running.
+	OsVersion               map[string]string `json:"os_version"`
+	ManagementAgentDetected bool              `json:"managementAgentDetected"`
+	PvDriversDetected       bool              `json:"pvDriversDetected"`
 
 	// These fields are used for passing in disk inputs when
 	// creating Vms, however, this is not a real field as far
-	// as the XO api or XAPI is concerned
+	// as the XO api or XAPI is concerned. Disks with a VDI id
+	// attach that existing VDI rather than creating a new one.
 	Disks              []Disk              `json:"-"`
 	CloudNetworkConfig string              `json:"-"`
 	VIFsMap            []map[string]string `json:"-"`
 	WaitForIps         bool                `json:"-"`
 	Installation       Installation        `json:"-"`
+
+	// Keys CreateVm enrolls in the UEFI variable store of the VM before
+	// its first boot. The template's keys are kept when empty.
+	SecureBootKeys SecureBootKeys `json:"-"`
+
+	// Milestone CreateVm waits for before returning. Defaults to running,
+	// or ip-assigned when WaitForIps is set.
+	WaitFor VmWaitFor `json:"-"`
+	// Timeout of the ip-assigned milestone once the VM is running.
+	// Defaults to the creation timeout.
+	WaitForIpTimeout time.Duration `json:"-"`
+	// Once the milestone is reached, CreateVm waits for the VM to receive
+	// no update for this quiet period before reading it. Disabled when zero.
+	WaitForStable time.Duration `json:"-"`
+
+	// Fail with a NameConflictError when a VM of the same name already
+	// exists in the template's pool.
+	FailIfNameExists bool `json:"-"`
+	// Suffix CreateVm adds to NameLabel to make it unique within the pool.
+	NameSuffix NameSuffixStrategy `json:"-"`
+
+	// Anti-affinity group CreateVm tags the VM with, it is started on a
+	// host of the pool running no other VM of the group.
+	AntiAffinityGroup string `json:"-"`
+
+	// Hint for the host CreateVm starts the VM on, and where it placed the
+	// VM given that hint.
+	PlacementHint *PlacementHint `json:"-"`
+	Placement     *Placement     `json:"-"`
+
+	// Fields changed by UpdateVm which only apply once the running VM is
+	// rebooted, e.g. `cpuMask`. Empty when the changes already apply.
+	RebootRequiredFields []string `json:"-"`
 }
 
 type Installation struct {
@@ -140,6 +195,10 @@ func (v Vm) Compare(obj interface{}) bool {
 }
 
 func (c *Client) CreateVm(vmReq Vm, createTime time.Duration) (*Vm, error) {
+	if err := c.validateCreateVm(vmReq); err != nil {
+		return nil, err
+	}
+
 	tmpl, err := c.GetTemplate(Template{
 		Id: vmReq.Template,
 	})
@@ -152,33 +211,84 @@ func (c *Client) CreateVm(vmReq Vm, createTime time.Duration) (*Vm, error) {
 		return nil, errors.New(fmt.Sprintf("cannot create VM when multiple templates are returned: %v", tmpl))
 	}
 
+	switch vmReq.WaitFor {
+	case "", WaitForTaskComplete, WaitForRunning, WaitForIpAssigned:
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown milestone `%s` to wait for", vmReq.WaitFor))
+	}
+
+	vmReq.NameLabel, err = c.uniqueVmName(vmReq, tmpl[0].PoolId)
+	if err != nil {
+		return nil, err
+	}
+
 	useExistingDisks := tmpl[0].isDiskTemplate()
 	installation := vmReq.Installation
 	if !useExistingDisks && installation.Method != "cdrom" && installation.Method != "network" {
 		return nil, errors.New("cannot create a VM from a diskless template without an ISO")
 	}
 
+	// Disks referencing a VDI are attached by vm.create, the others are
+	// created along with the VM.
+	disks := []Disk{}
+	attachedDisks := []Disk{}
+	for _, disk := range vmReq.Disks {
+		if disk.VDIId == "" {
+			disks = append(disks, disk)
+			continue
+		}
+
+		if err := c.checkVdiAttachable(disk.VDIId); err != nil {
+			return nil, err
+		}
+		attachedDisks = append(attachedDisks, disk)
+	}
+
 	existingDisks := map[string]interface{}{}
 	vdis := []interface{}{}
-	disks := vmReq.Disks
-
-	firstDisk := createVdiMap(disks[0])
-	// Treat the first disk differently. This covers the
-	// case where we are using a template with an already
-	// installed OS or a diskless template.
-	if useExistingDisks {
-		existingDisks["0"] = firstDisk
-	} else {
-		vdis = append(vdis, firstDisk)
+
+	if len(disks) > 0 {
+		firstDisk := createVdiMap(disks[0])
+		// Treat the first disk differently. This covers the
+		// case where we are using a template with an already
+		// installed OS or a diskless template.
+		if useExistingDisks {
+			existingDisks["0"] = firstDisk
+		} else {
+			vdis = append(vdis, firstDisk)
+		}
 	}
 
 	for i := 1; i < len(disks); i++ {
 		vdis = append(vdis, createVdiMap(disks[i]))
 	}
+	for _, disk := range attachedDisks {
+		vdis = append(vdis, attachVdiMap(disk))
+	}
+
+	tags := vmReq.Tags
+	startHost := ""
+	if group := vmReq.AntiAffinityGroup; group != "" {
+		startHost, err = c.antiAffinityHost(tmpl[0].PoolId, group, "", vmReq.AffinityHost)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(append([]string{}, tags...), antiAffinityTag(group))
+	}
+	placement, err := c.placeVm(tmpl[0].PoolId, vmReq, startHost)
+	if err != nil {
+		return nil, err
+	}
+	if placement != nil {
+		startHost = placement.HostId
+	}
 
+	// The VM must be halted to enroll its keys, and is started by
+	// vm.start to choose its host
+	bootAfterCreate := vmReq.SecureBootKeys == "" && startHost == ""
 	params := map[string]interface{}{
 		"affinityHost":     vmReq.AffinityHost,
-		"bootAfterCreate":  true,
+		"bootAfterCreate":  bootAfterCreate,
 		"name_label":       vmReq.NameLabel,
 		"name_description": vmReq.NameDescription,
 		"hvmBootFirmware":  vmReq.Boot.Firmware,
@@ -189,12 +299,11 @@ func (c *Client) CreateVm(vmReq Vm, createTime time.Duration) (*Vm, error) {
 		"CPUs":             vmReq.CPUs.Number,
 		"memoryMax":        vmReq.Memory.Static[1],
 		"existingDisks":    existingDisks,
-		// TODO: (#145) Uncomment this once issues with secure_boot have been figured out
-		// "secureBoot":       vmReq.SecureBoot,
-		"expNestedHvm": vmReq.ExpNestedHvm,
-		"VDIs":         vdis,
-		"VIFs":         vmReq.VIFsMap,
-		"tags":         vmReq.Tags,
+		"secureBoot":       vmReq.SecureBoot,
+		"expNestedHvm":     vmReq.ExpNestedHvm,
+		"VDIs":             vdis,
+		"VIFs":             vmReq.VIFsMap,
+		"tags":             tags,
 	}
 
 	videoram := vmReq.Videoram.Value
@@ -242,24 +351,126 @@ func (c *Client) CreateVm(vmReq Vm, createTime time.Duration) (*Vm, error) {
 		params["networkConfig"] = cloudNetworkConfig
 	}
 	log.Printf("[DEBUG] VM params for vm.create %#v", params)
+
+	// A VM left behind by a failed step would be a half configured VM
+	// the caller doesn't know about, it is deleted along with the disks
+	// created with it. The existing VDIs attached to it are kept.
+	attachedVdis := []string{}
+	for _, disk := range attachedDisks {
+		attachedVdis = append(attachedVdis, disk.VDIId)
+	}
 	var vmId string
-	err = c.Call("vm.create", params, &vmId)
+	orc := NewOrchestrator(c)
+	orc.Do("create vm", func() error {
+		return quotaExceeded(c.Call("vm.create", params, &vmId))
+	}, func() error {
+		return c.deleteCreatedVm(vmId, attachedVdis)
+	})
+	if c.dryRun {
+		// The VM isn't created, there is nothing to configure nor read
+		// back
+		if err := orc.Run(context.Background()); err != nil {
+			return nil, err
+		}
+		vm := vmReq
+		vm.Placement = placement
+		return &vm, nil
+	}
 
-	if err != nil {
-		return nil, err
+	if haParams := vmHaRestartParams(vmReq); haParams != nil {
+		orc.Do("set ha restart priority", func() error {
+			haParams["id"] = vmId
+			var success bool
+			return c.Call("vm.set", haParams, &success)
+		}, nil)
+	}
+
+	if vmReq.SecureBootKeys != "" {
+		orc.Do("set secure boot keys", func() error {
+			return c.SetVmSecureBootKeys(vmId, vmReq.SecureBootKeys)
+		}, nil)
+	}
+
+	if vmReq.SecureBoot {
+		orc.Do("check secure boot readiness", func() error {
+			c.warnSecureBootNotReady(vmId)
+			return nil
+		}, nil)
 	}
 
-	err = c.waitForModifyVm(vmId, vmReq.WaitForIps, createTime)
+	if !bootAfterCreate {
+		orc.Do("start vm", func() error {
+			var success bool
+			params := map[string]interface{}{"id": vmId}
+			if startHost != "" {
+				params["host"] = startHost
+			}
+			return c.Call("vm.start", params, &success)
+		}, nil)
+	}
 
+	err = orc.Run(context.Background())
 	if err != nil {
 		return nil, err
 	}
 
-	return c.GetVm(
-		Vm{
-			Id: vmId,
+	// The VM is fully configured at this point, one which is slow to
+	// reach its milestone, e.g. to get an IP, is returned along with the
+	// error rather than deleted. It is returned with its id alone when
+	// it can't be read back.
+	var vm *Vm
+	err = c.waitForCreatedVm(vmId, vmReq, createTime)
+	if err == nil && vmReq.WaitForStable > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), createTime)
+		defer cancel()
+		vm, err = c.WaitForStableVm(ctx, vmId, vmReq.WaitForStable)
+	} else {
+		var getErr error
+		vm, getErr = c.GetVm(
+			Vm{
+				Id: vmId,
+			},
+		)
+		if err == nil {
+			err = getErr
+		}
+	}
+	if vm == nil {
+		vm = &Vm{Id: vmId}
+	}
+	vm.Placement = placement
+	return vm, err
+}
+
+// deleteCreatedVm deletes a VM created by CreateVm along with the disks
+// created with it, but not the VDIs of keepVdis nor the CDs which were
+// attached to it.
+func (c *Client) deleteCreatedVm(vmId string, keepVdis []string) error {
+	var vbds map[string]VBD
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"type": "VBD",
+			"VM":   vmId,
 		},
-	)
+	}
+	if err := c.Call("xo.getAllObjects", params, &vbds); err != nil {
+		return err
+	}
+
+	var success bool
+	if err := c.Call("vm.delete", map[string]interface{}{"id": vmId}, &success); err != nil {
+		return err
+	}
+	for _, id := range sortedKeys(vbds) {
+		vbd := vbds[id]
+		if vbd.VDI == "" || vbd.IsCdDrive || stringInSlice(vbd.VDI, keepVdis) {
+			continue
+		}
+		if err := c.Call("vdi.delete", map[string]interface{}{"id": vbd.VDI}, &success); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func createVdiMap(disk Disk) map[string]interface{} {
@@ -273,7 +484,85 @@ func createVdiMap(disk Disk) map[string]interface{} {
 	}
 }
 
+// attachVdiMap describes an existing VDI which vm.create attaches to the
+// VM rather than creating a new one.
+func attachVdiMap(disk Disk) map[string]interface{} {
+	vdi := map[string]interface{}{
+		"vdi":  disk.VDIId,
+		"mode": "RW",
+	}
+	if disk.ReadOnly {
+		vdi["mode"] = "RO"
+	}
+	if disk.Bootable {
+		vdi["bootable"] = true
+	}
+	return vdi
+}
+
+// updateVmSettleDelay is how long UpdateVm waits after vm.set before
+// reading the VM back. Tests set it to 0.
+var updateVmSettleDelay = 25 * time.Second
+
+type UpdateVmOptions struct {
+	// VM as read by the caller before building the update. When set, the
+	// update fails with a ConflictError if the fields it writes were
+	// modified since then, rather than overwriting them.
+	Read *Vm
+	// Skips the check of Read, e.g. to apply an update despite a conflict.
+	ForceUpdate bool
+}
+
+// UpdateVm is UpdateVmWithOptions without checking for concurrent
+// modifications.
+//
+// Deprecated: use UpdateVmWithOptions.
 func (c *Client) UpdateVm(vmReq Vm) (*Vm, error) {
+	return c.UpdateVmWithOptions(vmReq, UpdateVmOptions{})
+}
+
+// UpdateVmWithOptions applies the settings of vmReq to the VM. With
+// opts.Read, the VM is read again right before the update and compared to
+// it, so that the changes of another client made in between aren't
+// silently overwritten. XO has no conditional update, a change made
+// between that last read and vm.set still goes unnoticed. The changes
+// which only apply once the VM is rebooted are listed by the
+// RebootRequiredFields of the returned VM.
+func (c *Client) UpdateVmWithOptions(vmReq Vm, opts UpdateVmOptions) (*Vm, error) {
+	if err := c.validateUpdateVm(vmReq); err != nil {
+		return nil, err
+	}
+	if err := c.validateCpuMaskOnHost(vmReq); err != nil {
+		return nil, err
+	}
+
+	actual, err := c.GetVm(Vm{Id: vmReq.Id})
+	if opts.Read != nil && !opts.ForceUpdate {
+		if err != nil {
+			return nil, err
+		}
+		if changes := vmConflicts(*opts.Read, *actual); len(changes) > 0 {
+			return nil, ConflictError{Type: "VM", Id: vmReq.Id, Changes: changes}
+		}
+	}
+
+	// Renames are applied live and don't need the full vm.set call nor
+	// its settle delay
+	var rebootRequired []string
+	if err == nil {
+		if fields := vmMetadataChanges(vmReq, *actual); len(fields) > 0 {
+			err = c.setMetadata("vm.set", vmReq.Id, fields, SetMetadataOptions{Verify: true})
+			if err != nil {
+				return nil, err
+			}
+			return c.GetVm(Vm{Id: vmReq.Id})
+		}
+		if fields := RebootRequiredFields(DiffVm(vmReq, *actual)); len(fields) > 0 && actual.PowerState != PowerStateHalted {
+			c.logf("[WARN] Changes to %v of VM %s only apply once it is rebooted\n", fields, vmReq.Id)
+			rebootRequired = fields
+		}
+	}
+
 	var resourceSet interface{} = vmReq.ResourceSet
 	if vmReq.ResourceSet == "" {
 		resourceSet = nil
@@ -302,15 +591,29 @@ func (c *Client) UpdateVm(vmReq Vm) (*Vm, error) {
 
 		// share relates to resource sets. This can be accomplished with the resource set resource so supporting it isn't necessary
 
-		// cpusMask, cpuWeight and cpuCap can be changed at runtime to an integer value or null
+		// cpuWeight and cpuCap can be changed at runtime to an integer value or null
 		// coresPerSocket is null or a number of cores per socket. Putting an invalid value doesn't seem to cause an error :(
 	}
 
-	// TODO: (#145) Uncomment this once issues with secure_boot have been figured out
-	// secureBoot := vmReq.SecureBoot
-	// if secureBoot {
-	// 	params["secureBoot"] = true
-	// }
+	// The start order is only sent when it changed, or when the VM
+	// couldn't be read to tell
+	if err != nil || vmReq.StartOrder != actual.StartOrder {
+		params["order"] = vmReq.StartOrder
+	}
+
+	switch {
+	case vmReq.VcpuMask == nil:
+	case len(vmReq.VcpuMask) == 0:
+		params["cpuMask"] = nil
+	default:
+		params["cpuMask"] = []int(vmReq.VcpuMask.normalized())
+	}
+
+	// Secure boot is only sent when it changed, so that disabling it is
+	// applied as well
+	if err != nil || vmReq.SecureBoot != actual.SecureBoot {
+		params["secureBoot"] = vmReq.SecureBoot
+	}
 
 	blockedOperations := map[string]interface{}{}
 	for k, v := range vmReq.BlockedOperations {
@@ -326,7 +629,7 @@ func (c *Client) UpdateVm(vmReq Vm) (*Vm, error) {
 	log.Printf("[DEBUG] VM params for vm.set: %#v", params)
 
 	var success bool
-	err := c.Call("vm.set", params, &success)
+	err = c.Call("vm.set", params, &success)
 
 	if err != nil {
 		return nil, err
@@ -334,15 +637,28 @@ func (c *Client) UpdateVm(vmReq Vm) (*Vm, error) {
 
 	// TODO: This is a poor way to ensure that terraform will see the updated
 	// attributes after calling vm.set. Need to investigate a better way to detect this.
-	time.Sleep(25 * time.Second)
+	time.Sleep(updateVmSettleDelay)
 
-	return c.GetVm(vmReq)
+	vm, err := c.GetVm(vmReq)
+	if err != nil {
+		return nil, err
+	}
+	vm.RebootRequiredFields = rebootRequired
+	return vm, nil
 }
 
+// Deprecated: use StartVmWithOptions.
 func (c *Client) StartVm(id string) error {
+	return c.StartVmWithOptions(id, StartVmOptions{})
+}
+
+func (c *Client) startVm(id, hostId string) error {
 	params := map[string]interface{}{
 		"id": id,
 	}
+	if hostId != "" {
+		params["host"] = hostId
+	}
 	var success bool
 	// TODO: This can block indefinitely before we get to the waitForVmHalt
 	err := c.Call("vm.start", params, &success)
@@ -350,14 +666,201 @@ func (c *Client) StartVm(id string) error {
 	if err != nil {
 		return err
 	}
-	return c.waitForVmState(
-		id,
-		StateChangeConf{
-			Pending: []string{"Halted", "Stopped"},
-			Target:  []string{"Running"},
-			Timeout: 2 * time.Minute,
-		},
-	)
+	return c.waitForVmPowerState(id, []PowerState{PowerStateHalted}, PowerStateRunning, 2*time.Minute)
+}
+
+// Name XO gives to the VDI holding a VM's cloud-init config drive
+const cloudConfigDriveNameLabel = "XO CloudConfigDrive"
+
+type StartVmOptions struct {
+	// Rendered cloud-config user data. Takes precedence over CloudConfigId.
+	CloudConfig string
+	// Id of a cloud config stored in XO
+	CloudConfigId      string
+	CloudNetworkConfig string
+	// SR where the new config drive is created. Defaults to the SR
+	// of the config drive being replaced.
+	CloudConfigSrId string
+	// Host the VM is started on, chosen by XO when empty. It must be
+	// allowed by the VM's resource set, see ResourceSetPlacementError.
+	HostId string
+}
+
+// StartVmWithOptions starts a halted VM like StartVm. When a cloud config
+// is supplied, the VM's existing config drive is destroyed and replaced by
+// a new one holding the given user data before the VM boots. A VM of an
+// anti-affinity group is started on a host running no other VM of the
+// group, see AntiAffinityError.
+func (c *Client) StartVmWithOptions(id string, opts StartVmOptions) error {
+	if err := c.validateStartVm(opts); err != nil {
+		return err
+	}
+
+	// Every check runs before the config drive is replaced, so that a VM
+	// which can't be started is left untouched
+	vm, err := c.GetVm(Vm{Id: id})
+	if err != nil {
+		return err
+	}
+	if group := vm.antiAffinityGroup(); group != "" {
+		opts.HostId, err = c.antiAffinityHost(vm.PoolId, group, id, opts.HostId)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.HostId != "" {
+		if err := c.checkResourceSetPlacement(*vm, opts.HostId); err != nil {
+			return err
+		}
+	}
+
+	if opts.CloudConfig != "" || opts.CloudConfigId != "" {
+		err := c.replaceCloudConfigDrive(id, opts)
+
+		if err != nil {
+			return err
+		}
+	}
+	return c.startVm(id, opts.HostId)
+}
+
+type MigrateVmOptions struct {
+	// Run CheckMigrationCompatibility before migrating and fail with a
+	// MigrationIncompatibleError when the host is unfit for the VM
+	CheckCompatibility bool
+}
+
+// MigrateVm is MigrateVmWithOptions with the default options.
+func (c *Client) MigrateVm(vmId, hostId string) error {
+	return c.MigrateVmWithOptions(vmId, hostId, MigrateVmOptions{})
+}
+
+// MigrateVmWithOptions migrates a running VM to another host of its pool.
+// The host must be allowed by the VM's resource set, see
+// ResourceSetPlacementError.
+func (c *Client) MigrateVmWithOptions(vmId, hostId string, opts MigrateVmOptions) error {
+	if _, err := c.checkMigrateVm(vmId, hostId, opts); err != nil {
+		return err
+	}
+
+	return c.migrateVm(vmId, hostId)
+}
+
+// Name of the XAPI task migrating a VM within its pool
+const vmMigrateTaskNameLabel = "Async.VM.pool_migrate"
+
+// MigrateVmAsync starts the migration of MigrateVmWithOptions once the
+// same checks passed and returns without waiting for it to complete.
+func (c *Client) MigrateVmAsync(vmId, hostId string, opts MigrateVmOptions) (*PendingOperation, error) {
+	vm, err := c.checkMigrateVm(vmId, hostId, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.startOperation(context.Background(), "vm.migrate", vm.PoolId, operationTask([]string{vmMigrateTaskNameLabel}, vm.Id, vm.XapiRef), func() (string, error) {
+		return "", c.migrateVm(vmId, hostId)
+	})
+}
+
+// checkMigrateVm returns the VM once it was checked to be allowed to
+// migrate to the host.
+func (c *Client) checkMigrateVm(vmId, hostId string, opts MigrateVmOptions) (*Vm, error) {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkResourceSetPlacement(*vm, hostId); err != nil {
+		return nil, err
+	}
+
+	if opts.CheckCompatibility {
+		report, err := c.CheckMigrationCompatibility(vmId, hostId)
+		if err != nil {
+			return nil, err
+		}
+		if !report.Compatible {
+			return nil, MigrationIncompatibleError{Report: report}
+		}
+	}
+	return vm, nil
+}
+
+func (c *Client) migrateVm(vmId, hostId string) error {
+	var success bool
+	return c.Call("vm.migrate", map[string]interface{}{
+		"vm":         vmId,
+		"targetHost": hostId,
+	}, &success)
+}
+
+func (c *Client) replaceCloudConfigDrive(vmId string, opts StartVmOptions) error {
+	template := opts.CloudConfig
+	if template == "" {
+		cloudConfig, err := c.GetCloudConfig(opts.CloudConfigId)
+		if err != nil {
+			return err
+		}
+
+		if cloudConfig == nil {
+			return NotFound{Query: CloudConfig{Id: opts.CloudConfigId}}
+		}
+		template = cloudConfig.Template
+	}
+
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return err
+	}
+
+	if vm.PowerState != PowerStateHalted {
+		return errors.New(fmt.Sprintf("vm `%s` must be halted to replace its cloud config drive, instead found power state `%s`", vmId, vm.PowerState))
+	}
+
+	disks, err := c.GetDisks(vm)
+	if err != nil {
+		return err
+	}
+
+	srId := opts.CloudConfigSrId
+	oldDrives := []string{}
+	for _, disk := range disks {
+		if disk.NameLabel != cloudConfigDriveNameLabel {
+			continue
+		}
+
+		if srId == "" {
+			srId = disk.SrId
+		}
+		oldDrives = append(oldDrives, disk.VDIId)
+	}
+
+	if srId == "" {
+		return errors.New(fmt.Sprintf("vm `%s` has no cloud config drive to replace, the SR for the new one must be provided", vmId))
+	}
+
+	// The old drives are only removed once the new one exists, so that a
+	// failure leaves the VM with its previous config
+	params := map[string]interface{}{
+		"vm":       vmId,
+		"template": template,
+		"sr":       srId,
+	}
+	if opts.CloudNetworkConfig != "" {
+		params["networkConfig"] = opts.CloudNetworkConfig
+	}
+	var vdiId string
+	if err := c.Call("cloudConfig.createConfigDrive", params, &vdiId); err != nil {
+		return err
+	}
+
+	for _, oldDrive := range oldDrives {
+		log.Printf("[DEBUG] Removing cloud config drive `%s` from vm `%s`\n", oldDrive, vmId)
+		var success bool
+		if err := c.Call("vdi.delete", map[string]interface{}{"id": oldDrive}, &success); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *Client) HaltVm(vmReq Vm) error {
@@ -371,22 +874,152 @@ func (c *Client) HaltVm(vmReq Vm) error {
 	if err != nil {
 		return err
 	}
-	return c.waitForVmState(
-		vmReq.Id,
-		StateChangeConf{
-			Pending: []string{"Running", "Stopped"},
-			Target:  []string{"Halted"},
-			Timeout: 2 * time.Minute,
-		},
-	)
+	return c.waitForVmPowerState(vmReq.Id, []PowerState{PowerStateRunning}, PowerStateHalted, 2*time.Minute)
 }
 
+// Deprecated: use DeleteVmContext.
 func (c *Client) DeleteVm(id string) error {
+	return c.DeleteVmContext(context.Background(), id, DeleteVmOptions{})
+}
+
+const defaultDeletePollInterval = 2 * time.Second
+
+type DeleteVmOptions struct {
+	// Delete the VM's disks along with it
+	DeleteDisks bool
+	// Wait for the VM, and its disks when DeleteDisks is set, to be gone
+	// before returning. Implied by Progress.
+	Wait bool
+	// Called every time one of the deleted objects disappears
+	Progress func(DeleteProgress)
+	// Defaults to 2 seconds
+	PollInterval time.Duration
+}
+
+type DeleteProgress struct {
+	// Either VM or VDI
+	Type string
+	Id   string
+	// Number of objects still to be removed
+	Remaining int
+}
+
+// DeleteIncompleteError is returned by DeleteVmContext when the context is
+// done before every deleted object disappeared. The deletion continues
+// on the XO side, Remaining lists the objects that were still present.
+type DeleteIncompleteError struct {
+	VmId      string
+	Remaining []DeleteProgress
+	Err       error
+}
+
+func (e DeleteIncompleteError) Error() string {
+	ids := []string{}
+	for _, r := range e.Remaining {
+		ids = append(ids, fmt.Sprintf("%s %s", r.Type, r.Id))
+	}
+	return fmt.Sprintf("stopped waiting for the deletion of vm `%s` with resources remaining (%s): %v", e.VmId, strings.Join(ids, ", "), e.Err)
+}
+
+func (e DeleteIncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// DeleteVmContext deletes a VM and, depending on opts, its disks and waits
+// for them to disappear. The disks are enumerated before the deletion so
+// their removal can be tracked. Snapshots which are the base of linked
+// clones aren't deleted, see LinkedClonesExistError.
+func (c *Client) DeleteVmContext(ctx context.Context, id string, opts DeleteVmOptions) error {
+	_, err := c.deleteVm(ctx, id, opts)
+	return err
+}
+
+// DeleteVmWithResult deletes a VM like DeleteVmContext and reports the
+// disks deleted along with it. A VM that doesn't exist is reported as
+// already absent.
+func (c *Client) DeleteVmWithResult(ctx context.Context, id string, opts DeleteVmOptions) (*DeleteResult, error) {
+	return absentIfNoSuchObject(c.deleteVm(ctx, id, opts))
+}
+
+func (c *Client) deleteVm(ctx context.Context, id string, opts DeleteVmOptions) (*DeleteResult, error) {
+	res := &DeleteResult{DeletedObject: DeletedObject{Type: "VM", Id: id}}
+	clones, err := c.getLinkedClones(ctx, id)
+	if err != nil {
+		return res, err
+	}
+	if len(clones) > 0 {
+		return res, LinkedClonesExistError{SnapshotId: id, Clones: clones}
+	}
+
+	remaining := []DeleteProgress{{Type: "VM", Id: id}}
+	if opts.DeleteDisks {
+		disks, err := c.GetDisks(&Vm{Id: id})
+		if err != nil {
+			return res, err
+		}
+
+		sort.Slice(disks, func(i, j int) bool {
+			return disks[i].VDIId < disks[j].VDIId
+		})
+		for _, disk := range disks {
+			remaining = append(remaining, DeleteProgress{Type: "VDI", Id: disk.VDIId})
+			res.Cascaded = append(res.Cascaded, DeletedObject{Type: "VDI", Id: disk.VDIId})
+		}
+	}
+
 	params := map[string]interface{}{
 		"id": id,
 	}
+	if opts.DeleteDisks {
+		params["deleteDisks"] = true
+	}
 	var reply []interface{}
-	return c.Call("vm.delete", params, &reply)
+	err = c.Call("vm.delete", params, &reply)
+
+	if err != nil || !(opts.Wait || opts.Progress != nil) {
+		return res, err
+	}
+
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = defaultDeletePollInterval
+	}
+	err = wait.Poll(ctx, interval, func(ctx context.Context) (bool, error) {
+		// Only the objects still being deleted are looked up
+		ids := []string{}
+		for _, r := range remaining {
+			ids = append(ids, r.Id)
+		}
+		existing := map[string]interface{}{}
+		if err := c.getObjectsMatching(ctx, map[string]interface{}{"id": anyOf(ids)}, &existing); err != nil {
+			return false, err
+		}
+
+		left := []DeleteProgress{}
+		gone := []DeleteProgress{}
+		for _, r := range remaining {
+			if _, ok := existing[r.Id]; ok {
+				left = append(left, r)
+			} else {
+				gone = append(gone, r)
+			}
+		}
+		remaining = left
+
+		for _, r := range gone {
+			log.Printf("[DEBUG] %s `%s` of deleted vm `%s` is gone, %d resources remaining\n", r.Type, r.Id, id, len(remaining))
+			if opts.Progress != nil {
+				r.Remaining = len(remaining)
+				opts.Progress(r)
+			}
+		}
+		return len(remaining) == 0, nil
+	})
+
+	if err != nil {
+		return res, DeleteIncompleteError{VmId: id, Remaining: remaining, Err: err}
+	}
+	return res, nil
 }
 
 func (c *Client) GetVm(vmReq Vm) (*Vm, error) {
@@ -415,6 +1048,141 @@ func (c *Client) GetVms(vm Vm) ([]Vm, error) {
 	return vms, nil
 }
 
+const (
+	sortFieldMemory  = "memory"
+	sortFieldCreated = "created"
+)
+
+var vmSortFields = []string{sortFieldNameLabel, sortFieldMemory, sortFieldCreated}
+
+type SearchParams struct {
+	// Vm used to filter the results. An empty Vm matches every VM.
+	Filter Vm
+	// One of name_label, memory or created, sorted by id when empty
+	SortBy string
+	// One of asc or desc, asc when empty
+	SortOrder string
+	// 1-based page number. Defaults to the first page.
+	Page int
+	// Number of VMs per page. A limit of 0 returns every VM.
+	Limit int
+}
+
+type VmSearchResult struct {
+	Vms []Vm
+	// Number of VMs matching the filter across all pages
+	Total int
+}
+
+// SearchVms returns a sorted page of the VMs matching params.Filter. XO's
+// xo.getAllObjects does not support sorting or paging so both are done
+// client side.
+func (c *Client) SearchVms(params SearchParams) (*VmSearchResult, error) {
+	if !c.skipValidation {
+		v := &validator{}
+		if params.SortBy != "" && !stringInSlice(params.SortBy, vmSortFields) {
+			v.addf("SortBy", "must be one of %s, got `%s`", strings.Join(vmSortFields, ", "), params.SortBy)
+		}
+		if params.SortOrder != "" && params.SortOrder != sortOrderAsc && params.SortOrder != sortOrderDesc {
+			v.addf("SortOrder", "must be %s or %s, got `%s`", sortOrderAsc, sortOrderDesc, params.SortOrder)
+		}
+		if err := v.err(); err != nil {
+			return nil, err
+		}
+	}
+
+	vms, err := c.findVms(params.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	order := params.SortOrder
+	if order == "" {
+		order = sortOrderAsc
+	}
+	sortVmsByField(vms, params.SortBy, order)
+	return &VmSearchResult{
+		Vms:   paginateVms(vms, params.Page, params.Limit),
+		Total: len(vms),
+	}, nil
+}
+
+// findVms returns the VMs matching filter, every VM when filter is empty.
+// No VM matching the filter is not an error.
+func (c *Client) findVms(filter Vm) ([]Vm, error) {
+	vms := []Vm{}
+	if reflect.DeepEqual(filter, Vm{}) {
+		var response map[string]Vm
+		err := c.GetAllObjectsOfType(Vm{}, &response)
+		if err != nil {
+			return nil, err
+		}
+		for _, vm := range response {
+			vms = append(vms, vm)
+		}
+		return vms, nil
+	}
+
+	obj, err := c.FindFromGetAllObjects(filter)
+	if _, ok := err.(NotFound); !ok && err != nil {
+		return nil, err
+	}
+	if err == nil {
+		vms = obj.([]Vm)
+	}
+	return vms, nil
+}
+
+// sortVmsByField sorts vms by field, by id when field is empty, so that
+// pages are cut from the same order on every call.
+func sortVmsByField(vms []Vm, by, order string) []Vm {
+	sort.Slice(vms, func(i, j int) bool {
+		if order == sortOrderDesc {
+			return vmLess(vms[j], vms[i], by)
+		}
+		return vmLess(vms[i], vms[j], by)
+	})
+	return vms
+}
+
+// vmLess compares two VMs by field falling back to their ids
+// so that the order is stable across pages.
+func vmLess(a, b Vm, field string) bool {
+	switch field {
+	case sortFieldNameLabel:
+		if a.NameLabel != b.NameLabel {
+			return a.NameLabel < b.NameLabel
+		}
+	case sortFieldMemory:
+		if a.Memory.Size != b.Memory.Size {
+			return a.Memory.Size < b.Memory.Size
+		}
+	case sortFieldCreated:
+		if !a.InstallTime.Equal(b.InstallTime.Time) {
+			return a.InstallTime.Before(b.InstallTime.Time)
+		}
+	}
+	return a.Id < b.Id
+}
+
+func paginateVms(vms []Vm, page, limit int) []Vm {
+	if limit <= 0 {
+		return vms
+	}
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * limit
+	if start >= len(vms) {
+		return []Vm{}
+	}
+	end := start + limit
+	if end > len(vms) {
+		end = len(vms)
+	}
+	return vms[start:end]
+}
+
 func (c *Client) EjectVmCd(vm *Vm) error {
 	params := map[string]interface{}{
 		"id": vm.Id,
@@ -427,6 +1195,17 @@ func (c *Client) EjectVmCd(vm *Vm) error {
 	return nil
 }
 
+func (vm *Vm) describeWait() string {
+	if vm == nil {
+		return "no VM"
+	}
+	ops := []string{}
+	for _, id := range sortedKeys(vm.CurrentOperations) {
+		ops = append(ops, vm.CurrentOperations[id])
+	}
+	return fmt.Sprintf("VM `%s` with power state %s, current operations %v, addresses %v", vm.Id, vm.PowerState, ops, vm.Addresses)
+}
+
 func GetVmPowerState(c *Client, id string) func() (result interface{}, state string, err error) {
 	return func() (interface{}, string, error) {
 		vm, err := c.GetVm(Vm{Id: id})
@@ -435,59 +1214,87 @@ func GetVmPowerState(c *Client, id string) func() (result interface{}, state str
 			return vm, "", err
 		}
 
-		return vm, vm.PowerState, nil
+		return vm, vm.PowerState.String(), nil
 	}
 }
 
-func (c *Client) waitForVmState(id string, stateConf StateChangeConf) error {
-	stateConf.Refresh = GetVmPowerState(c, id)
-	_, err := stateConf.WaitForState()
+// waitForVmPowerState waits for the VM to go from one of the pending power
+// states to the target one.
+func (c *Client) waitForVmPowerState(id string, pending []PowerState, target PowerState, timeout time.Duration) error {
+	waiter := stateWait{
+		Refresh: GetVmPowerState(c, id),
+		Target:  []string{target.String()},
+		Timeout: timeout,
+	}
+	for _, state := range pending {
+		waiter.Pending = append(waiter.Pending, state.String())
+	}
+	_, err := waiter.wait(context.Background())
 	return err
 }
 
-func (c *Client) waitForModifyVm(id string, waitForIp bool, timeout time.Duration) error {
-	if !waitForIp {
-		refreshFn := func() (result interface{}, state string, err error) {
-			vm, err := c.GetVm(Vm{Id: id})
+type VmWaitFor string
 
-			if err != nil {
-				return vm, "", err
-			}
+const (
+	// vm.create only returns once XO's creation task completed
+	WaitForTaskComplete VmWaitFor = "task-complete"
+	WaitForRunning      VmWaitFor = "running"
+	WaitForIpAssigned   VmWaitFor = "ip-assigned"
+)
+
+// waitForCreatedVm waits for the milestone requested by vmReq. Each
+// milestone waits for the previous ones first, with its own timeout.
+func (c *Client) waitForCreatedVm(id string, vmReq Vm, timeout time.Duration) error {
+	switch vmReq.WaitFor {
+	case "":
+		return c.waitForModifyVm(id, vmReq.WaitForIps, timeout)
+	case WaitForTaskComplete:
+		return nil
+	case WaitForRunning:
+		return c.waitForModifyVm(id, false, timeout)
+	case WaitForIpAssigned:
+		err := c.waitForModifyVm(id, false, timeout)
 
-			return vm, vm.PowerState, nil
+		if err != nil {
+			return err
 		}
-		stateConf := &StateChangeConf{
-			Pending: []string{"Halted", "Stopped"},
-			Refresh: refreshFn,
-			Target:  []string{"Running"},
-			Timeout: timeout,
+
+		ipTimeout := vmReq.WaitForIpTimeout
+		if ipTimeout == 0 {
+			ipTimeout = timeout
 		}
-		_, err := stateConf.WaitForState()
-		return err
-	} else {
-		refreshFn := func() (result interface{}, state string, err error) {
-			vm, err := c.GetVm(Vm{Id: id})
+		return c.waitForModifyVm(id, true, ipTimeout)
+	}
+	return errors.New(fmt.Sprintf("unknown milestone `%s` to wait for", vmReq.WaitFor))
+}
 
-			if err != nil {
-				return vm, "", err
-			}
+func (c *Client) waitForModifyVm(id string, waitForIp bool, timeout time.Duration) error {
+	if !waitForIp {
+		return c.waitForVmPowerState(id, []PowerState{PowerStateHalted}, PowerStateRunning, timeout)
+	}
 
-			l := len(vm.Addresses)
-			if l == 0 || vm.PowerState != "Running" {
-				return vm, "Waiting", nil
-			}
+	refreshFn := func() (result interface{}, state string, err error) {
+		vm, err := c.GetVm(Vm{Id: id})
 
-			return vm, "Ready", nil
+		if err != nil {
+			return vm, "", err
 		}
-		stateConf := &StateChangeConf{
-			Pending: []string{"Waiting"},
-			Refresh: refreshFn,
-			Target:  []string{"Ready"},
-			Timeout: timeout,
+
+		l := len(vm.Addresses)
+		if l == 0 || vm.PowerState != PowerStateRunning {
+			return vm, "Waiting", nil
 		}
-		_, err := stateConf.WaitForState()
-		return err
+
+		return vm, "Ready", nil
 	}
+	waiter := stateWait{
+		Pending: []string{"Waiting"},
+		Refresh: refreshFn,
+		Target:  []string{"Ready"},
+		Timeout: timeout,
+	}
+	_, err := waiter.wait(context.Background())
+	return err
 }
 
 func FindOrCreateVmForTests(vm *Vm, poolId, srId, templateName, tag string) {
@@ -527,7 +1334,7 @@ func FindOrCreateVmForTests(vm *Vm, poolId, srId, templateName, tag string) {
 					Number: 1,
 				},
 				Memory: MemoryObject{
-					Static: []int{
+					Static: []int64{
 						0, 2147483648,
 					},
 				},
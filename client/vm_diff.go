@@ -0,0 +1,142 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// FieldChange describes a VM field whose desired value differs from the
+// actual one. Field uses the name of the corresponding vm.set parameter
+// where one exists. Slices compared with set semantics are reported sorted.
+type FieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+// DiffVm returns the fields that need to change for actual to match
+// desired. Fields managed by the server (power state, object refs,
+// addresses, ...) are ignored and tags, disks and VIFs are compared
+// regardless of their order. Disks are only compared when the ones of
+// actual were loaded, i.e. actual was read with GetVmWithInventory rather
+// than GetVm.
+func DiffVm(desired, actual Vm) []FieldChange {
+	changes := []FieldChange{}
+	compare := func(field string, old, new interface{}) {
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	compareSet := func(field string, old, new []string) {
+		old, new = sortedCopy(old), sortedCopy(new)
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+
+	compare("name_label", actual.NameLabel, desired.NameLabel)
+	compare("name_description", actual.NameDescription, desired.NameDescription)
+	compare("affinityHost", actual.AffinityHost, desired.AffinityHost)
+	compare("hvmBootFirmware", actual.Boot.Firmware, desired.Boot.Firmware)
+	compare("secureBoot", actual.SecureBoot, desired.SecureBoot)
+	compare("auto_poweron", actual.AutoPoweron, desired.AutoPoweron)
+	compare("resourceSet", actual.ResourceSet, desired.ResourceSet)
+	compare("high_availability", actual.HA, desired.HA)
+	compare("CPUs", actual.CPUs.Number, desired.CPUs.Number)
+	compare("memoryMax", vmMemoryMax(actual), vmMemoryMax(desired))
+	compare("expNestedHvm", actual.ExpNestedHvm, desired.ExpNestedHvm)
+	compare("startDelay", actual.StartDelay, desired.StartDelay)
+	compare("order", actual.StartOrder, desired.StartOrder)
+	compare("vga", actual.Vga, desired.Vga)
+	compare("videoram", actual.Videoram.Value, desired.Videoram.Value)
+	if desired.VcpuMask != nil {
+		compare("cpuMask", actual.VcpuMask.String(), desired.VcpuMask.String())
+	}
+	compare("blockedOperations", nonNilMap(actual.BlockedOperations), nonNilMap(desired.BlockedOperations))
+	compareSet("tags", actual.Tags, desired.Tags)
+	if actual.Disks != nil {
+		compareSet("disks", diskKeys(actual.Disks), diskKeys(desired.Disks))
+	}
+	compareSet("VIFs", actual.VIFs, desired.VIFs)
+
+	return changes
+}
+
+// Fields compared by DiffVm which UpdateVm doesn't write
+var vmFieldsNotUpdated = []string{"tags", "disks", "VIFs"}
+
+// vmConflicts returns the fields written by UpdateVm which were modified
+// since the VM was read, from their value in read to the one in actual.
+func vmConflicts(read, actual Vm) []FieldChange {
+	conflicts := []FieldChange{}
+	for _, change := range DiffVm(actual, read) {
+		if !stringInSlice(change.Field, vmFieldsNotUpdated) {
+			conflicts = append(conflicts, change)
+		}
+	}
+	return conflicts
+}
+
+// Fields of a running VM whose changes only apply once it is rebooted
+var rebootRequiredVmFields = []string{"cpuMask", "expNestedHvm", "hvmBootFirmware", "memoryMax", "secureBoot", "vga", "videoram"}
+
+// RebootRequiredFields returns the fields of changes which only apply to a
+// running VM once it is rebooted, e.g. to decide whether to halt the VM
+// before updating it.
+func RebootRequiredFields(changes []FieldChange) []string {
+	fields := []string{}
+	for _, change := range changes {
+		if stringInSlice(change.Field, rebootRequiredVmFields) {
+			fields = append(fields, change.Field)
+		}
+	}
+	return fields
+}
+
+// vmMetadataChanges returns the name and description to set when they are
+// the only changes UpdateVm would make to actual, nil otherwise.
+func vmMetadataChanges(desired, actual Vm) map[string]string {
+	fields := map[string]string{}
+	for _, change := range DiffVm(desired, actual) {
+		switch change.Field {
+		case "name_label", "name_description":
+			fields[change.Field] = change.New.(string)
+		// Left untouched by UpdateVm
+		case "tags", "disks", "VIFs":
+		default:
+			return nil
+		}
+	}
+	return fields
+}
+
+// vmMemoryMax returns the static max memory vm.set updates, falling back
+// to the memory size when the static range isn't known.
+func vmMemoryMax(vm Vm) int64 {
+	if len(vm.Memory.Static) > 1 {
+		return vm.Memory.Static[1]
+	}
+	return vm.Memory.Size
+}
+
+func diskKeys(disks []Disk) []string {
+	keys := []string{}
+	for _, disk := range disks {
+		keys = append(keys, fmt.Sprintf("%s:%s:%d", disk.SrId, disk.NameLabel, disk.Size))
+	}
+	return keys
+}
+
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+func sortedCopy(s []string) []string {
+	c := append([]string{}, s...)
+	sort.Strings(c)
+	return c
+}
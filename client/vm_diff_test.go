@@ -0,0 +1,77 @@
+package client
+
+import (
+	"testing"
+)
+
+func TestDiffVm_reorderedTagsProduceNoDiff(t *testing.T) {
+	actual := Vm{
+		Id:         "vm-1",
+		NameLabel:  "web",
+		PowerState: "Running",
+		Tags:       []string{"prod", "web", "eu"},
+		VIFs:       []string{"vif-1", "vif-2"},
+		Memory:     MemoryObject{Static: []int64{0, 1073741824}},
+		Disks:      []Disk{{VDI: VDI{NameLabel: "root", SrId: "sr-1", Size: 10}}, {VDI: VDI{NameLabel: "data", SrId: "sr-1", Size: 20}}},
+	}
+	desired := Vm{
+		NameLabel:  "web",
+		PowerState: "Halted",
+		Tags:       []string{"eu", "prod", "web"},
+		VIFs:       []string{"vif-2", "vif-1"},
+		Memory:     MemoryObject{Static: []int64{0, 1073741824}},
+		Disks:      []Disk{{VDI: VDI{NameLabel: "data", SrId: "sr-1", Size: 20}}, {VDI: VDI{NameLabel: "root", SrId: "sr-1", Size: 10}}},
+	}
+
+	if changes := DiffVm(desired, actual); len(changes) != 0 {
+		t.Errorf("expected no changes but received %+v", changes)
+	}
+}
+
+func TestDiffVm_changedMemory(t *testing.T) {
+	actual := Vm{NameLabel: "web", Memory: MemoryObject{Static: []int64{0, 1073741824}}}
+	desired := Vm{NameLabel: "web", Memory: MemoryObject{Static: []int64{0, 2147483648}}}
+
+	changes := DiffVm(desired, actual)
+	if len(changes) != 1 {
+		t.Fatalf("expected a single change but received %+v", changes)
+	}
+
+	change := changes[0]
+	if change.Field != "memoryMax" || change.Old != int64(1073741824) || change.New != int64(2147483648) {
+		t.Errorf("expected memoryMax to change from 1073741824 to 2147483648 but received %+v", change)
+	}
+}
+
+func TestDiffVm_disksOfFetchedVm(t *testing.T) {
+	objects := []map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "name_label": "web"},
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "vdi-1", "is_cd_drive": false},
+		{"id": "vdi-1", "type": "VDI", "name_label": "root", "$SR": "sr-1", "size": 10},
+	}
+	c := &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, objects...), nil
+	}}}
+	desired := Vm{
+		NameLabel: "web",
+		Disks:     []Disk{{VDI: VDI{NameLabel: "root", SrId: "sr-1", Size: 10}}, {VDI: VDI{NameLabel: "data", SrId: "sr-1", Size: 20}}},
+	}
+
+	// GetVm doesn't read the disks so they can't be compared
+	actual, err := c.GetVm(Vm{Id: "vm-1"})
+	if err != nil {
+		t.Fatalf("failed to get vm with error: %v", err)
+	}
+	if changes := DiffVm(desired, *actual); len(changes) != 0 {
+		t.Errorf("expected the disks not to be compared but received %+v", changes)
+	}
+
+	actual, err = c.GetVmWithInventory("vm-1")
+	if err != nil {
+		t.Fatalf("failed to get vm with error: %v", err)
+	}
+	changes := DiffVm(desired, *actual)
+	if len(changes) != 1 || changes[0].Field != "disks" {
+		t.Fatalf("expected the missing data disk to be reported but received %+v", changes)
+	}
+}
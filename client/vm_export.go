@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	VmExportCompressionGzip = "gzip"
+	VmExportCompressionZstd = "zstd"
+)
+
+type ExportVmOptions struct {
+	// Compression of the XVA, VmExportCompressionGzip,
+	// VmExportCompressionZstd or empty for none
+	Compress string
+}
+
+// ExportVm writes the VM to w as an XVA.
+func (c *Client) ExportVm(ctx context.Context, vmId string, w io.Writer, opts ExportVmOptions) error {
+	getFrom, err := c.startExportVm(vmId, opts)
+	if err != nil {
+		return err
+	}
+
+	return c.downloadVm(ctx, vmId, getFrom, w)
+}
+
+// Name of the task XO creates to export a VM
+const vmExportTaskNameLabel = "[XO] VM export"
+
+// ExportVmAsync starts the export of ExportVm and returns without waiting
+// for the XVA to be written to w. w must not be used by the caller until
+// the operation was waited for.
+func (c *Client) ExportVmAsync(ctx context.Context, vmId string, w io.Writer, opts ExportVmOptions) (*PendingOperation, error) {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return nil, err
+	}
+	getFrom, err := c.startExportVm(vmId, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.startOperation(ctx, "vm.export", vm.PoolId, operationTask([]string{vmExportTaskNameLabel}, vm.Id, vm.XapiRef), func() (string, error) {
+		return "", c.downloadVm(ctx, vmId, getFrom, w)
+	})
+}
+
+// startExportVm returns the path the XVA of the VM is downloaded from.
+func (c *Client) startExportVm(vmId string, opts ExportVmOptions) (string, error) {
+	params := map[string]interface{}{
+		"vm": vmId,
+	}
+	switch opts.Compress {
+	case "":
+	case VmExportCompressionGzip:
+		params["compress"] = true
+	case VmExportCompressionZstd:
+		params["compress"] = VmExportCompressionZstd
+	default:
+		return "", errors.New(fmt.Sprintf("unsupported VM export compression `%s`, expected one of `%s` or `%s`", opts.Compress, VmExportCompressionGzip, VmExportCompressionZstd))
+	}
+
+	var res struct {
+		GetFrom string `json:"$getFrom"`
+	}
+	if err := c.Call("vm.export", params, &res); err != nil {
+		return "", err
+	}
+	return res.GetFrom, nil
+}
+
+func (c *Client) downloadVm(ctx context.Context, vmId, getFrom string, w io.Writer) error {
+	r, err := c.download(ctx, getFrom)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return errors.New(fmt.Sprintf("failed to export VM `%s`: %v", vmId, err))
+	}
+	return nil
+}
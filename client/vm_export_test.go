@@ -0,0 +1,90 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fakeExportVmRPC() *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, map[string]interface{}{"id": "vm-1", "type": "VM", "$poolId": "pool-1"}), nil
+		case "vm.export":
+			return map[string]string{"$getFrom": "/api/download/vm"}, nil
+		}
+		return true, nil
+	}}
+}
+
+func TestExportVm_compression(t *testing.T) {
+	xva := []byte("xva content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(xva)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		compress string
+		params   map[string]interface{}
+	}{
+		{"", map[string]interface{}{"vm": "vm-1"}},
+		{VmExportCompressionGzip, map[string]interface{}{"vm": "vm-1", "compress": true}},
+		{VmExportCompressionZstd, map[string]interface{}{"vm": "vm-1", "compress": "zstd"}},
+	}
+	for _, test := range tests {
+		rpc := fakeExportVmRPC()
+		c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+		var w bytes.Buffer
+		if err := c.ExportVm(context.Background(), "vm-1", &w, ExportVmOptions{Compress: test.compress}); err != nil {
+			t.Fatalf("failed to export the VM with compression `%s` with error: %v", test.compress, err)
+		}
+		if !bytes.Equal(w.Bytes(), xva) {
+			t.Errorf("expected the XVA to be written but received: %s", w.Bytes())
+		}
+		calls := rpc.callsTo("vm.export")
+		if len(calls) != 1 || !reflect.DeepEqual(calls[0].params, test.params) {
+			t.Errorf("expected vm.export to be called with %v but received: %v", test.params, calls)
+		}
+	}
+
+	c := Client{rpc: fakeExportVmRPC()}
+	if err := c.ExportVm(context.Background(), "vm-1", &bytes.Buffer{}, ExportVmOptions{Compress: "xz"}); err == nil {
+		t.Errorf("expected an unsupported compression to be rejected")
+	}
+}
+
+func TestExportVmAsync_writesOnWait(t *testing.T) {
+	interval := taskPollInterval
+	taskPollInterval = time.Millisecond
+	defer func() { taskPollInterval = interval }()
+
+	xva := bytes.Repeat([]byte("xva"), 1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(xva)
+	}))
+	defer server.Close()
+
+	rpc := fakeExportVmRPC()
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	var w bytes.Buffer
+	op, err := c.ExportVmAsync(context.Background(), "vm-1", &w, ExportVmOptions{})
+	if err != nil {
+		t.Fatalf("failed to start the export with error: %v", err)
+	}
+	// The fake server creates no task
+	if _, err := op.Wait(context.Background()); err != nil {
+		t.Fatalf("failed to wait for the export with error: %v", err)
+	}
+	if op.TaskId != "" || !bytes.Equal(w.Bytes(), xva) {
+		t.Errorf("expected the %d bytes of the XVA to be written but received %d", len(xva), w.Len())
+	}
+}
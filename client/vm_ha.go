@@ -0,0 +1,36 @@
+package client
+
+// HA restart priorities of a VM, see Vm.HA. After a host failure, XAPI
+// restarts the VMs of an HA pool by StartOrder, waiting StartDelay
+// seconds after each group.
+const (
+	HaRestart      = "restart"
+	HaBestEffort   = "best-effort"
+	HaDoNotRestart = ""
+)
+
+func (v *validator) haRestart(vm Vm) {
+	switch vm.HA {
+	case HaRestart, HaBestEffort, HaDoNotRestart:
+	default:
+		v.addf("HA", "must be `%s`, `%s` or empty to not restart the VM, got `%s`", HaRestart, HaBestEffort, vm.HA)
+	}
+	if vm.StartOrder < 0 {
+		v.addf("StartOrder", "must not be negative, got %d", vm.StartOrder)
+	}
+	if vm.StartDelay < 0 {
+		v.addf("StartDelay", "must not be negative, got %d", vm.StartDelay)
+	}
+}
+
+// vmHaRestartParams returns the vm.set params of the HA restart settings
+// of the VM, nil when it uses the defaults. vm.create doesn't take them.
+func vmHaRestartParams(vm Vm) map[string]interface{} {
+	if vm.HA == HaDoNotRestart && vm.StartOrder == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"high_availability": vm.HA,
+		"order":             vm.StartOrder,
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestVm_decodesHaRestart(t *testing.T) {
+	var vm Vm
+	if err := json.Unmarshal([]byte(`{"id": "db", "high_availability": "restart", "order": 1, "startDelay": 30}`), &vm); err != nil {
+		t.Fatalf("failed to decode the VM with error: %v", err)
+	}
+	if vm.HA != HaRestart || vm.StartOrder != 1 || vm.StartDelay != 30 {
+		t.Errorf("expected the HA restart settings to be decoded but received: %q, %d, %d", vm.HA, vm.StartOrder, vm.StartDelay)
+	}
+}
+
+func TestCreateVm_setsHaRestart(t *testing.T) {
+	rpc := fakeCreateVmRPC()
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.HA = HaRestart
+	vmReq.StartOrder = 2
+	vmReq.StartDelay = 30
+	if _, err := c.CreateVm(vmReq, time.Minute); err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+
+	if delay := rpc.callsTo("vm.create")[0].params["startDelay"]; delay != float64(30) {
+		t.Errorf("expected vm.create to receive the start delay but received: %v", delay)
+	}
+	set := rpc.callsTo("vm.set")
+	if len(set) != 1 {
+		t.Fatalf("expected one vm.set call but received: %v", set)
+	}
+	params := set[0].params
+	if params["id"] != "new-vm" || params["high_availability"] != HaRestart || params["order"] != float64(2) {
+		t.Errorf("expected the restart priority and order to be set on the new VM but received: %v", params)
+	}
+
+	rpc = fakeCreateVmRPC()
+	c = &Client{rpc: rpc}
+	vmReq.HA = HaDoNotRestart
+	vmReq.StartOrder = 0
+	if _, err := c.CreateVm(vmReq, time.Minute); err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if set := rpc.callsTo("vm.set"); len(set) != 0 {
+		t.Errorf("expected no vm.set call for the default settings but received: %v", set)
+	}
+}
+
+func TestUpdateVm_haRestartParams(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	c, rpc := cpuMaskClient(map[string]interface{}{"id": testUuid, "type": "VM", "power_state": "Halted", "CPUs": map[string]interface{}{"number": 1}, "memory": map[string]interface{}{"static": []int64{0, minVmMemory}}})
+
+	vmReq := Vm{Id: testUuid, CPUs: CPUs{Number: 1}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, HA: HaBestEffort, StartOrder: 3, StartDelay: 10}
+	if _, err := c.UpdateVm(vmReq); err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	set := rpc.callsTo("vm.set")
+	if len(set) != 1 || set[0].params["high_availability"] != HaBestEffort || set[0].params["order"] != float64(3) || set[0].params["startDelay"] != float64(10) {
+		t.Errorf("expected vm.set to receive the restart settings but received: %v", set)
+	}
+
+	vmReq.HA = "always"
+	vmReq.StartOrder = -1
+	_, err := c.UpdateVm(vmReq)
+	if fields := validationFields(t, err); len(fields) != 2 || fields[0] != "HA" || fields[1] != "StartOrder" {
+		t.Errorf("expected the invalid priority and order to be rejected but received: %v", err)
+	}
+	if set := rpc.callsTo("vm.set"); len(set) != 1 {
+		t.Errorf("expected no vm.set call for invalid settings but received: %v", set)
+	}
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+const (
+	VmImportTypeXva = "xva"
+	VmImportTypeOva = "ova"
+)
+
+// OvaMetadata describes the VM of an OVA as read from its OVF descriptor.
+// XO needs it to import an OVA.
+type OvaMetadata struct {
+	NameLabel   string `json:"nameLabel"`
+	Description string `json:"descriptionLabel"`
+	// In bytes
+	Memory int64 `json:"memory"`
+	Cpus   int   `json:"nCpus"`
+	// Ids of the networks the VIFs of the VM are connected to, in order
+	Networks []string  `json:"networks"`
+	Disks    []OvaDisk `json:"-"`
+}
+
+// OvaDisk is a disk of an OVA.
+type OvaDisk struct {
+	// Index of the disk in the OVF descriptor
+	Position    int    `json:"position"`
+	NameLabel   string `json:"nameLabel"`
+	Description string `json:"descriptionLabel"`
+	// In bytes
+	Capacity int64 `json:"capacity"`
+	// Path of the disk's file within the OVA
+	Path string `json:"path"`
+	// Compression of the disk's file, e.g. `gzip`, empty for none
+	Compression string `json:"compression,omitempty"`
+}
+
+type ImportVmOptions struct {
+	// VmImportTypeXva or VmImportTypeOva
+	Type string
+	// SR of the disks missing from DiskSrs
+	SrId string
+	// Only used for OVAs
+	Ova *OvaMetadata
+	// SR of each disk of an OVA keyed by the disk's position, e.g. `0`,
+	// or its name
+	DiskSrs map[string]string
+}
+
+// ImportVm imports the VM read from r and returns the id of the new VM. The
+// disks of an OVA can be spread across SRs with DiskSrs, every SR is
+// checked to exist before anything is sent to XO.
+func (c *Client) ImportVm(ctx context.Context, r io.Reader, opts ImportVmOptions) (string, error) {
+	sendTo, err := c.startImportVm(opts)
+	if err != nil {
+		return "", err
+	}
+
+	return c.uploadVm(ctx, sendTo, r)
+}
+
+// Name of the task XO creates to import an XVA
+const vmImportTaskNameLabel = "[XO] VM import"
+
+// ImportVmAsync starts the import of ImportVm and returns without waiting
+// for the upload of r to complete. The id of the new VM is returned by the
+// Result of the operation once it was waited for. r must not be used by
+// the caller until then. The task is only tracked for XVAs, XO imports the
+// disks of an OVA one by one.
+func (c *Client) ImportVmAsync(ctx context.Context, r io.Reader, opts ImportVmOptions) (*PendingOperation, error) {
+	sendTo, err := c.startImportVm(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	taskNames := []string{}
+	if opts.Type == VmImportTypeXva {
+		taskNames = append(taskNames, vmImportTaskNameLabel)
+	}
+	return c.startOperation(ctx, "vm.import", "", operationTask(taskNames, "", ""), func() (string, error) {
+		return c.uploadVm(ctx, sendTo, r)
+	})
+}
+
+// startImportVm checks the options and returns the path the VM must be
+// uploaded to.
+func (c *Client) startImportVm(opts ImportVmOptions) (string, error) {
+	params := map[string]interface{}{
+		"type": opts.Type,
+		"sr":   opts.SrId,
+	}
+	srIds := []string{opts.SrId}
+
+	switch opts.Type {
+	case VmImportTypeXva:
+		if len(opts.DiskSrs) > 0 {
+			return "", errors.New("the disks of an XVA are imported to a single SR, disk SRs are only supported for OVAs")
+		}
+		if opts.SrId == "" {
+			return "", errors.New("an SR is required to import an XVA")
+		}
+	case VmImportTypeOva:
+		if opts.Ova == nil {
+			return "", errors.New("the metadata of the OVA is required to import it")
+		}
+		disks, err := ovaDiskSrs(*opts.Ova, opts.SrId, opts.DiskSrs)
+		if err != nil {
+			return "", err
+		}
+
+		data := map[string]interface{}{
+			"nameLabel":        opts.Ova.NameLabel,
+			"descriptionLabel": opts.Ova.Description,
+			"memory":           opts.Ova.Memory,
+			"nCpus":            opts.Ova.Cpus,
+			"networks":         opts.Ova.Networks,
+			"disks":            disks,
+		}
+		params["data"] = data
+		for _, position := range sortedKeys(disks) {
+			srIds = append(srIds, disks[position].SrId)
+		}
+	default:
+		return "", errors.New(fmt.Sprintf("unsupported VM import type `%s`, expected one of `%s` or `%s`", opts.Type, VmImportTypeXva, VmImportTypeOva))
+	}
+
+	if err := c.checkSrsExist(srIds); err != nil {
+		return "", err
+	}
+
+	var res struct {
+		SendTo string `json:"$sendTo"`
+	}
+	err := c.Call("vm.import", params, &res)
+	if err != nil {
+		return "", err
+	}
+	return res.SendTo, nil
+}
+
+func (c *Client) uploadVm(ctx context.Context, sendTo string, r io.Reader) (string, error) {
+	// XO answers the upload with the id of the imported VM
+	var vmId string
+	if err := c.upload(ctx, sendTo, r, readerSize(r), &vmId); err != nil {
+		return "", err
+	}
+	return vmId, nil
+}
+
+// ovaImportDisk is an OvaDisk along with the SR it is imported to.
+type ovaImportDisk struct {
+	OvaDisk
+	SrId string `json:"sr"`
+}
+
+// ovaDiskSrs returns the disks of the OVA keyed by position with their SR,
+// the one mapped to the disk's position or name in diskSrs or srId.
+func ovaDiskSrs(ova OvaMetadata, srId string, diskSrs map[string]string) (map[string]ovaImportDisk, error) {
+	mapped := map[string]bool{}
+	disks := map[string]ovaImportDisk{}
+	for _, disk := range ova.Disks {
+		position := strconv.Itoa(disk.Position)
+		importDisk := ovaImportDisk{OvaDisk: disk, SrId: srId}
+		for _, key := range []string{position, disk.NameLabel} {
+			if sr, ok := diskSrs[key]; ok && key != "" {
+				importDisk.SrId = sr
+				mapped[key] = true
+				break
+			}
+		}
+		if importDisk.SrId == "" {
+			return nil, errors.New(fmt.Sprintf("no SR for disk `%s` at position %d, a default SR is required for the disks missing from the mapping", disk.NameLabel, disk.Position))
+		}
+		disks[position] = importDisk
+	}
+
+	unknown := []string{}
+	for key := range diskSrs {
+		if !mapped[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, errors.New(fmt.Sprintf("the OVA has no disk at position or named %v", unknown))
+	}
+	return disks, nil
+}
+
+// checkSrsExist fails with a NotFound error for the first SR that doesn't
+// exist, empty ids are ignored.
+func (c *Client) checkSrsExist(srIds []string) error {
+	checked := map[string]bool{}
+	for _, srId := range srIds {
+		if srId == "" || checked[srId] {
+			continue
+		}
+		checked[srId] = true
+
+		exists, err := c.Exists("SR", srId)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return NotFound{Query: StorageRepository{Id: srId}}
+		}
+	}
+	return nil
+}
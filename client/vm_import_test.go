@@ -0,0 +1,123 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testOva = OvaMetadata{
+	NameLabel: "appliance",
+	Memory:    4 << 30,
+	Cpus:      2,
+	Networks:  []string{"network-1"},
+	Disks: []OvaDisk{
+		{Position: 0, NameLabel: "system", Capacity: 20 << 30, Path: "appliance-disk1.vmdk"},
+		{Position: 1, NameLabel: "data", Capacity: 500 << 30, Path: "appliance-disk2.vmdk", Compression: "gzip"},
+	},
+}
+
+func fakeImportVmRPC() *fakeRPC {
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params,
+				map[string]interface{}{"id": "sr-ssd", "type": "SR"},
+				map[string]interface{}{"id": "sr-hdd", "type": "SR"},
+			), nil
+		case "vm.import":
+			return map[string]string{"$sendTo": "/api/upload/vm"}, nil
+		}
+		return nil, nil
+	}}
+}
+
+func TestImportVm_ovaDisksMappedToSrs(t *testing.T) {
+	content := []byte("ova content")
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/upload/vm" || r.Method != http.MethodPut {
+			t.Errorf("unexpected upload request %s %s", r.Method, r.URL.Path)
+		}
+		received, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":0,"result":"vm-imported"}`))
+	}))
+	defer server.Close()
+
+	rpc := fakeImportVmRPC()
+	c := Client{rpc: rpc, url: strings.Replace(server.URL, "http", "ws", 1), httpClient: server.Client()}
+
+	vmId, err := c.ImportVm(context.Background(), bytes.NewReader(content), ImportVmOptions{
+		Type:    VmImportTypeOva,
+		SrId:    "sr-ssd",
+		Ova:     &testOva,
+		DiskSrs: map[string]string{"data": "sr-hdd"},
+	})
+	if err != nil {
+		t.Fatalf("failed to import vm with error: %v", err)
+	}
+	if vmId != "vm-imported" {
+		t.Errorf("expected the id of the imported VM to be returned but received `%s`", vmId)
+	}
+
+	calls := rpc.callsTo("vm.import")
+	if len(calls) != 1 || calls[0].params["type"] != "ova" || calls[0].params["sr"] != "sr-ssd" {
+		t.Fatalf("expected a single ova import but received: %v", calls)
+	}
+	disks := calls[0].params["data"].(map[string]interface{})["disks"].(map[string]interface{})
+	srs := map[string]interface{}{}
+	for position, disk := range disks {
+		srs[position] = disk.(map[string]interface{})["sr"]
+	}
+	if !reflect.DeepEqual(srs, map[string]interface{}{"0": "sr-ssd", "1": "sr-hdd"}) {
+		t.Errorf("expected the system disk on sr-ssd and the data disk on sr-hdd but received: %v", disks)
+	}
+	if disk := disks["1"].(map[string]interface{}); disk["path"] != "appliance-disk2.vmdk" || disk["compression"] != "gzip" {
+		t.Errorf("expected the disk's metadata to be sent but received: %v", disk)
+	}
+	if !bytes.Equal(received, content) {
+		t.Errorf("expected upload to receive `%s` but received `%s`", content, received)
+	}
+}
+
+func TestImportVm_mappingByPosition(t *testing.T) {
+	disks, err := ovaDiskSrs(testOva, "", map[string]string{"0": "sr-ssd", "1": "sr-hdd"})
+	if err != nil {
+		t.Fatalf("failed to map disks with error: %v", err)
+	}
+	if disks["0"].SrId != "sr-ssd" || disks["1"].SrId != "sr-hdd" {
+		t.Errorf("expected the disks to be mapped by position but received: %+v", disks)
+	}
+
+	if _, err := ovaDiskSrs(testOva, "", map[string]string{"0": "sr-ssd"}); err == nil {
+		t.Errorf("expected an unmapped disk without a default SR to fail")
+	}
+	if _, err := ovaDiskSrs(testOva, "sr-ssd", map[string]string{"logs": "sr-hdd"}); err == nil {
+		t.Errorf("expected a mapping of a missing disk to fail")
+	}
+}
+
+func TestImportVm_missingSr(t *testing.T) {
+	rpc := fakeImportVmRPC()
+	c := Client{rpc: rpc}
+
+	_, err := c.ImportVm(context.Background(), bytes.NewReader(nil), ImportVmOptions{
+		Type:    VmImportTypeOva,
+		SrId:    "sr-ssd",
+		Ova:     &testOva,
+		DiskSrs: map[string]string{"1": "sr-missing"},
+	})
+	var notFound NotFound
+	if !errors.As(err, &notFound) || notFound.Query.(StorageRepository).Id != "sr-missing" {
+		t.Fatalf("expected a NotFound error for sr-missing but received: %v", err)
+	}
+	if len(rpc.callsTo("vm.import")) != 0 {
+		t.Errorf("expected nothing to be imported")
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+type NameSuffixStrategy string
+
+const (
+	NameSuffixNone NameSuffixStrategy = "none"
+	// Appends 8 random hexadecimal characters, e.g. `web-3f9a01c2`
+	NameSuffixRandomHex NameSuffixStrategy = "random-hex"
+	// Appends the number following the highest one already used by VMs
+	// of the same name, e.g. `web-3` when `web` and `web-2` exist. The
+	// name is left as is when no VM uses it, suffixed or not.
+	NameSuffixIncrementing NameSuffixStrategy = "incrementing"
+)
+
+// Attempts at finding a random suffix that isn't used yet.
+const randomSuffixAttempts = 5
+
+// uniqueVmName returns the name CreateVm must give to the VM requested by
+// vmReq according to its NameSuffix and FailIfNameExists options.
+//
+// The existence check isn't atomic with the creation of the VM: two VMs
+// created concurrently with the same name may both pass it. Callers
+// creating VMs concurrently should rely on NameSuffixRandomHex whose
+// suffixes are unlikely to ever collide.
+func (c *Client) uniqueVmName(vmReq Vm, poolId string) (string, error) {
+	name := vmReq.NameLabel
+	switch vmReq.NameSuffix {
+	case "", NameSuffixNone:
+		if !vmReq.FailIfNameExists {
+			return name, nil
+		}
+	case NameSuffixRandomHex:
+		for i := 0; i < randomSuffixAttempts; i++ {
+			suffix, err := randomHexSuffix()
+			if err != nil {
+				return "", err
+			}
+			vms, err := c.getVmsByName(name+"-"+suffix, poolId)
+			if err != nil {
+				return "", err
+			}
+			if len(vms) == 0 {
+				return name + "-" + suffix, nil
+			}
+		}
+		return "", errors.New(fmt.Sprintf("failed to find an unused random suffix for VM `%s` after %d attempts", name, randomSuffixAttempts))
+	case NameSuffixIncrementing:
+		vms, err := c.getVmsByName("", poolId)
+		if err != nil {
+			return "", err
+		}
+		return incrementVmName(name, vms), nil
+	default:
+		return "", errors.New(fmt.Sprintf("unknown name suffix strategy `%s`", vmReq.NameSuffix))
+	}
+
+	vms, err := c.getVmsByName(name, poolId)
+	if err != nil {
+		return "", err
+	}
+	if len(vms) > 0 {
+		return "", NameConflictError{NameLabel: name, PoolId: poolId, VmId: vms[0].Id}
+	}
+	return name, nil
+}
+
+// getVmsByName returns the VMs of a pool with the given name, or every VM
+// of the pool when name is empty.
+func (c *Client) getVmsByName(name, poolId string) ([]Vm, error) {
+	filter := map[string]interface{}{
+		"type": "VM",
+	}
+	if name != "" {
+		filter["name_label"] = name
+	}
+	if poolId != "" {
+		filter["$poolId"] = poolId
+	}
+
+	objs := map[string]Vm{}
+	params := map[string]interface{}{
+		"filter": filter,
+	}
+	if err := c.Call("xo.getAllObjects", params, &objs); err != nil {
+		return nil, err
+	}
+
+	vms := []Vm{}
+	for _, vm := range objs {
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+func randomHexSuffix() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// incrementVmName returns name, or name followed by the number after the
+// highest suffix already used by vms when name is taken.
+func incrementVmName(name string, vms []Vm) string {
+	suffixed := regexp.MustCompile("^" + regexp.QuoteMeta(name) + "-([0-9]+)$")
+
+	taken := false
+	highest := 1
+	for _, vm := range vms {
+		if vm.NameLabel == name {
+			taken = true
+			continue
+		}
+		m := suffixed.FindStringSubmatch(vm.NameLabel)
+		if m == nil {
+			continue
+		}
+		taken = true
+		if n, err := strconv.Atoi(m[1]); err == nil && n > highest {
+			highest = n
+		}
+	}
+
+	if !taken {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", name, highest+1)
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func fakeVmNamesRPC(names ...string) *fakeRPC {
+	vms := []map[string]interface{}{
+		{"id": "other-pool-vm", "type": "VM", "name_label": "web", "$poolId": "pool-2"},
+	}
+	for _, name := range names {
+		vms = append(vms, map[string]interface{}{"id": "vm-" + name, "type": "VM", "name_label": name, "$poolId": "pool-1"})
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, vms...), nil
+	}}
+}
+
+func TestUniqueVmName_failIfNameExists(t *testing.T) {
+	c := &Client{rpc: fakeVmNamesRPC("web")}
+
+	_, err := c.uniqueVmName(Vm{NameLabel: "web", FailIfNameExists: true}, "pool-1")
+	var conflict NameConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a NameConflictError but received: %v", err)
+	}
+	if conflict.VmId != "vm-web" || conflict.PoolId != "pool-1" {
+		t.Errorf("expected the conflict with vm-web in pool-1 to be reported but received: %+v", conflict)
+	}
+
+	// The check is scoped to the pool
+	c = &Client{rpc: fakeVmNamesRPC()}
+	if name, err := c.uniqueVmName(Vm{NameLabel: "web", FailIfNameExists: true}, "pool-1"); err != nil || name != "web" {
+		t.Errorf("expected `web` to be available in pool-1 but received %s with error: %v", name, err)
+	}
+}
+
+func TestUniqueVmName_noneKeepsDuplicates(t *testing.T) {
+	rpc := fakeVmNamesRPC("web")
+	c := &Client{rpc: rpc}
+
+	name, err := c.uniqueVmName(Vm{NameLabel: "web", NameSuffix: NameSuffixNone}, "pool-1")
+	if err != nil || name != "web" {
+		t.Errorf("expected the name to be kept but received %s with error: %v", name, err)
+	}
+	if len(rpc.calls) != 0 {
+		t.Errorf("expected no existence check without FailIfNameExists but received calls: %v", rpc.methods())
+	}
+}
+
+func TestUniqueVmName_randomHex(t *testing.T) {
+	c := &Client{rpc: fakeVmNamesRPC("web")}
+
+	first, err := c.uniqueVmName(Vm{NameLabel: "web", NameSuffix: NameSuffixRandomHex}, "pool-1")
+	if err != nil {
+		t.Fatalf("failed to generate a name with error: %v", err)
+	}
+	if !regexp.MustCompile("^web-[0-9a-f]{8}$").MatchString(first) {
+		t.Errorf("expected a random hex suffix but received %s", first)
+	}
+
+	second, _ := c.uniqueVmName(Vm{NameLabel: "web", NameSuffix: NameSuffixRandomHex}, "pool-1")
+	if first == second {
+		t.Errorf("expected successive names to differ but both are %s", first)
+	}
+}
+
+func TestUniqueVmName_incrementing(t *testing.T) {
+	tests := []struct {
+		existing []string
+		expected string
+	}{
+		{existing: []string{}, expected: "web"},
+		{existing: []string{"web"}, expected: "web-2"},
+		{existing: []string{"web", "web-2", "web-7", "web-frontend", "webapp-9"}, expected: "web-8"},
+		{existing: []string{"web-3"}, expected: "web-4"},
+	}
+
+	for _, test := range tests {
+		c := &Client{rpc: fakeVmNamesRPC(test.existing...)}
+		name, err := c.uniqueVmName(Vm{NameLabel: "web", NameSuffix: NameSuffixIncrementing}, "pool-1")
+		if err != nil || name != test.expected {
+			t.Errorf("expected %s with existing VMs %v but received %s with error: %v", test.expected, test.existing, name, err)
+		}
+	}
+}
+
+func TestUniqueVmName_unknownStrategy(t *testing.T) {
+	c := &Client{rpc: fakeVmNamesRPC()}
+	if _, err := c.uniqueVmName(Vm{NameLabel: "web", NameSuffix: "uuid"}, "pool-1"); err == nil {
+		t.Errorf("expected an unknown strategy to be rejected")
+	}
+}
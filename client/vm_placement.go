@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PlacementHint steers the host CreateVm starts a large VM on. Hints which
+// can't be honoured fall back to XAPI's placement, the reason being
+// reported in the Placement of the created VM.
+type PlacementHint struct {
+	// Start the VM on the enabled host of the pool with the most free
+	// memory able to fit it, leaving the most room for its memory to be
+	// allocated on a single NUMA node.
+	LargestFreeMemory bool
+	// NUMA node of the host the VM should run on. XO doesn't report which
+	// CPUs belong to each NUMA node of a host, see Host.NumaTopology,
+	// CreateVm returns an UnsupportedOnThisServerError before creating
+	// anything when it is set.
+	NumaNode *int
+}
+
+// Placement is where CreateVm placed a VM given a PlacementHint.
+type Placement struct {
+	// Host the VM was started on, empty when left to XAPI
+	HostId string
+	// Why the hint wasn't fully honoured, empty when it was
+	Reason string
+}
+
+func (v *validator) placementHint(vm Vm) {
+	if vm.PlacementHint == nil || vm.PlacementHint.NumaNode == nil {
+		return
+	}
+	if node := *vm.PlacementHint.NumaNode; node < 0 {
+		v.addf("PlacementHint.NumaNode", "must not be negative, got %d", node)
+	}
+}
+
+// placeVm chooses the host CreateVm starts the VM on following its
+// placement hint, nil without a hint. The VM stays on hostId when it is
+// already chosen, e.g. by an anti-affinity group, or else on its affinity
+// host when it has one. Otherwise it goes to one of the running enabled
+// hosts of the pool.
+func (c *Client) placeVm(poolId string, vm Vm, hostId string) (*Placement, error) {
+	hint := vm.PlacementHint
+	if hint == nil {
+		return nil, nil
+	}
+	if hint.NumaNode != nil {
+		return nil, UnsupportedOnThisServerError{Method: "vm.create", Reason: fmt.Sprintf("XO doesn't report which CPUs belong to each NUMA node of a host, the vCPUs of the VM can't be pinned to NUMA node %d", *hint.NumaNode)}
+	}
+	if !hint.LargestFreeMemory {
+		return nil, nil
+	}
+	if hostId == "" {
+		hostId = vm.AffinityHost
+	}
+
+	candidates := []string{hostId}
+	if hostId == "" {
+		hosts, err := c.GetPoolHosts(poolId)
+		if err != nil {
+			return nil, err
+		}
+		candidates = []string{}
+		for _, host := range hosts {
+			if host.Enabled && host.PowerState == PowerStateRunning {
+				candidates = append(candidates, host.Id)
+			}
+		}
+	}
+
+	placement := &Placement{HostId: hostId}
+	// Why each candidate which can't fit the VM was rejected
+	rejected := []string{}
+	var best *Host
+	for _, id := range candidates {
+		host, err := c.GetHostWithInventory(id)
+		if err != nil {
+			return nil, err
+		}
+		if fits, reason := CanHostFitVm(*host, vm); !fits {
+			rejected = append(rejected, fmt.Sprintf("host `%s`: %s", id, reason))
+			continue
+		}
+		if best == nil || host.FreeMemory() > best.FreeMemory() {
+			best = host
+		}
+	}
+
+	if best == nil {
+		placement.Reason = strings.Join(rejected, "; ")
+		if len(rejected) == 0 {
+			placement.Reason = "no running enabled host in the pool"
+		}
+		c.logf("[WARN] Leaving the placement of VM `%s` to XAPI: %s\n", vm.NameLabel, placement.Reason)
+		return placement, nil
+	}
+	placement.HostId = best.Id
+	c.logf("[DEBUG] Placing VM `%s` on host `%s`\n", vm.NameLabel, placement.HostId)
+	return placement, nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Id of the host with the most memory, a uuid to be used as affinity host
+const largeHostId = "9c1b5e2f-3a4d-4e6f-8a7b-0c1d2e3f4a5b"
+
+// fakeNumaHostsRPC serves a single socket host with the most memory, a two
+// socket host, a disabled host and a halted host.
+func fakeNumaHostsRPC() *fakeRPC {
+	objects := []map[string]interface{}{
+		{"id": largeHostId, "type": "host", "$pool": "pool-1", "power_state": "Running", "enabled": true, "memory": map[string]interface{}{"size": 256 * gib}, "cpus": map[string]interface{}{"cores": 32, "sockets": 1}},
+		{"id": "host-numa", "type": "host", "$pool": "pool-1", "power_state": "Running", "enabled": true, "memory": map[string]interface{}{"size": 128 * gib}, "cpus": map[string]interface{}{"cores": 32, "sockets": 2}},
+		{"id": "host-disabled", "type": "host", "$pool": "pool-1", "power_state": "Running", "enabled": false, "memory": map[string]interface{}{"size": 512 * gib}, "cpus": map[string]interface{}{"cores": 64, "sockets": 4}},
+		{"id": "host-halted", "type": "host", "$pool": "pool-1", "power_state": "Halted", "enabled": true, "memory": map[string]interface{}{"size": 512 * gib}, "cpus": map[string]interface{}{"cores": 64, "sockets": 4}},
+	}
+	// Every host reaches the SR and network of validVmRequest
+	for _, host := range []string{largeHostId, "host-numa", "host-disabled", "host-halted"} {
+		objects = append(objects,
+			map[string]interface{}{"id": "pbd-" + host, "type": "PBD", "host": host, "SR": testUuid2, "attached": true},
+			map[string]interface{}{"id": "pif-" + host, "type": "PIF", "$host": host, "$network": testUuid2},
+		)
+	}
+	return fakeCreateVmRPC(objects...)
+}
+
+func placementVmRequest(hint PlacementHint) Vm {
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.PlacementHint = &hint
+	return vmReq
+}
+
+func TestCreateVm_placementLargestFreeMemory(t *testing.T) {
+	rpc := fakeNumaHostsRPC()
+	c := &Client{rpc: rpc}
+
+	vm, err := c.CreateVm(placementVmRequest(PlacementHint{LargestFreeMemory: true}), time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if create := rpc.callsTo("vm.create")[0].params; create["bootAfterCreate"] != false {
+		t.Errorf("expected the VM to be started by vm.start on the chosen host")
+	}
+	if start := rpc.callsTo("vm.start"); len(start) != 1 || start[0].params["host"] != largeHostId {
+		t.Errorf("expected the VM to be started on the host with the most free memory but received: %v", start)
+	}
+	if !reflect.DeepEqual(vm.Placement, &Placement{HostId: largeHostId}) {
+		t.Errorf("expected the placement to be reported but received %+v", vm.Placement)
+	}
+	if set := rpc.callsTo("vm.set"); len(set) != 0 {
+		t.Errorf("expected the vCPUs not to be pinned but received: %v", set)
+	}
+}
+
+func TestCreateVm_placementNumaNodeIsUnsupported(t *testing.T) {
+	rpc := fakeNumaHostsRPC()
+	c := &Client{rpc: rpc}
+
+	node := 1
+	_, err := c.CreateVm(placementVmRequest(PlacementHint{NumaNode: &node}), time.Minute)
+	var unsupported UnsupportedOnThisServerError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected an UnsupportedOnThisServerError but received: %v", err)
+	}
+	if create := rpc.callsTo("vm.create"); len(create) != 0 {
+		t.Errorf("expected no VM to be created but received: %v", create)
+	}
+}
+
+func TestCreateVm_placementReasonOfRejectedHosts(t *testing.T) {
+	rpc := fakeNumaHostsRPC()
+	c := &Client{rpc: rpc}
+
+	// Only fits on the host with the most memory, host-numa is rejected
+	// after it
+	vmReq := placementVmRequest(PlacementHint{LargestFreeMemory: true})
+	vmReq.Memory.Static = []int64{0, 200 * gib}
+	vm, err := c.CreateVm(vmReq, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if !reflect.DeepEqual(vm.Placement, &Placement{HostId: largeHostId}) {
+		t.Errorf("expected the reason of the rejected host not to be reported but received %+v", vm.Placement)
+	}
+
+	rpc = fakeNumaHostsRPC()
+	c = &Client{rpc: rpc}
+	vmReq.Memory.Static = []int64{0, 300 * gib}
+	vm, err = c.CreateVm(vmReq, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if vm.Placement == nil || vm.Placement.HostId != "" || !strings.Contains(vm.Placement.Reason, "host `"+largeHostId+"`: memory") || !strings.Contains(vm.Placement.Reason, "host `host-numa`: memory") {
+		t.Errorf("expected the reason of every rejected host to be reported but received %+v", vm.Placement)
+	}
+}
+
+func TestCreateVm_placementKeepsAffinityHost(t *testing.T) {
+	rpc := fakeNumaHostsRPC()
+	c := &Client{rpc: rpc}
+
+	vmReq := placementVmRequest(PlacementHint{LargestFreeMemory: true})
+	vmReq.AffinityHost = largeHostId
+	vm, err := c.CreateVm(vmReq, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if !reflect.DeepEqual(vm.Placement, &Placement{HostId: largeHostId}) {
+		t.Errorf("expected the VM to stay on its affinity host but received %+v", vm.Placement)
+	}
+}
+
+func TestCreateVm_invalidPlacementHint(t *testing.T) {
+	rpc := fakeNumaHostsRPC()
+	c := &Client{rpc: rpc}
+
+	node := -1
+	_, err := c.CreateVm(placementVmRequest(PlacementHint{NumaNode: &node}), time.Minute)
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"PlacementHint.NumaNode"}) {
+		t.Errorf("expected the negative NUMA node to be rejected but received: %v", err)
+	}
+}
+
+func indexOf(s string, slice []string) int {
+	for i, v := range slice {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timeout of WaitForStableVm when its context has no deadline.
+const DefaultStableVmTimeout = 5 * time.Minute
+
+// WaitForStableVm waits until XO has pushed no update of the VM, or of the
+// VBDs and VIFs attached to it, for the quiet period and then returns a
+// fresh read of the VM. Right after its creation a VM keeps changing while
+// its disks attach, its memory settles and its addresses are reported, so
+// reading it too early gives a state which is about to change.
+//
+// The wait is bounded by the deadline of ctx, or DefaultStableVmTimeout when
+// it has none.
+func (c *Client) WaitForStableVm(ctx context.Context, vmId string, quiet time.Duration) (*Vm, error) {
+	if quiet <= 0 {
+		return nil, errors.New(fmt.Sprintf("quiet period must be positive, got %s", quiet))
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultStableVmTimeout)
+		defer cancel()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	notifications, err := c.RawNotifications(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	updates := 0
+	for {
+		select {
+		case n, ok := <-notifications:
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("VM %s did not stay unchanged for %s after %d updates: %v", vmId, quiet, updates, ctx.Err()))
+			}
+			if !notificationConcernsVm(n, vmId) {
+				continue
+			}
+			updates++
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(quiet)
+		case <-timer.C:
+			c.logf("[DEBUG] VM %s is stable after %d updates\n", vmId, updates)
+			return c.GetVm(Vm{Id: vmId})
+		}
+	}
+}
+
+// notificationConcernsVm reports whether n is an object notification
+// containing the VM or one of its VBDs or VIFs.
+func notificationConcernsVm(n RawNotification, vmId string) bool {
+	if n.Method != "all" {
+		return false
+	}
+
+	var params struct {
+		Items map[string]struct {
+			VM    string `json:"VM"`
+			VifVM string `json:"$VM"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(n.Params, &params); err != nil {
+		return false
+	}
+	for id, item := range params.Items {
+		if id == vmId || item.VM == vmId || item.VifVM == vmId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChurningVm pushes a notification for every update of the VM and
+// serves its latest state through xo.getAllObjects.
+type fakeChurningVm struct {
+	mu      sync.Mutex
+	address string
+
+	notifier *notifier
+	handler  handler
+}
+
+func newFakeChurningVm() *fakeChurningVm {
+	n := newNotifier()
+	return &fakeChurningVm{address: "10.0.0.1", notifier: n, handler: handler{notifier: n}}
+}
+
+func (f *fakeChurningVm) client(objects ...map[string]interface{}) *Client {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			f.mu.Lock()
+			vm := map[string]interface{}{"id": "new-vm", "type": "VM", "name_label": "web", "power_state": "Running", "addresses": map[string]string{"0/ipv4/0": f.address}}
+			f.mu.Unlock()
+			return fakeGetAllObjects(params, append(objects, vm)...), nil
+		case "vm.create":
+			return "new-vm", nil
+		}
+		return true, nil
+	}}
+	return &Client{rpc: rpc, notifier: f.notifier}
+}
+
+func (f *fakeChurningVm) update(id, address string) {
+	f.mu.Lock()
+	f.address = address
+	f.mu.Unlock()
+	f.handler.Handle(context.Background(), nil, notification("all", fmt.Sprintf(`{"type":"enter","items":{"%s":{"id":"%s","type":"VM"}}}`, id, id)))
+}
+
+// burst updates the VM every interval and returns the last address.
+func (f *fakeChurningVm) burst(updates int, interval time.Duration) string {
+	address := ""
+	for i := 2; i < updates+2; i++ {
+		time.Sleep(interval)
+		address = fmt.Sprintf("10.0.0.%d", i)
+		f.update("new-vm", address)
+	}
+	return address
+}
+
+func TestWaitForStableVm_returnsStateAfterLastUpdate(t *testing.T) {
+	f := newFakeChurningVm()
+	c := f.client()
+
+	last := make(chan string, 1)
+	go func() { last <- f.burst(5, 20*time.Millisecond) }()
+
+	vm, err := c.WaitForStableVm(context.Background(), "new-vm", 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to wait for the VM to be stable with error: %v", err)
+	}
+	if expected := <-last; vm.Addresses["0/ipv4/0"] != expected {
+		t.Errorf("expected the VM read after its last update with address `%s` but received: %v", expected, vm.Addresses)
+	}
+}
+
+func TestWaitForStableVm_ignoresOtherObjects(t *testing.T) {
+	f := newFakeChurningVm()
+	c := f.client()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			time.Sleep(20 * time.Millisecond)
+			f.handler.Handle(context.Background(), nil, notification("all", `{"type":"enter","items":{"other-vm":{"id":"other-vm","type":"VM"}}}`))
+		}
+	}()
+
+	start := time.Now()
+	if _, err := c.WaitForStableVm(context.Background(), "new-vm", 100*time.Millisecond); err != nil {
+		t.Fatalf("failed to wait for the VM to be stable with error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("expected updates of other objects to be ignored but the wait took %s", elapsed)
+	}
+	<-done
+}
+
+func TestWaitForStableVm_timeout(t *testing.T) {
+	f := newFakeChurningVm()
+	c := f.client()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+				f.update("new-vm", fmt.Sprintf("10.0.1.%d", i))
+			}
+		}
+	}()
+
+	_, err := c.WaitForStableVm(ctx, "new-vm", 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "did not stay unchanged") {
+		t.Errorf("expected the wait to time out for a VM which keeps changing but received: %v", err)
+	}
+}
+
+func TestCreateVm_waitForStable(t *testing.T) {
+	f := newFakeChurningVm()
+	c := f.client(map[string]interface{}{"id": testUuid, "type": "VM-template", "name_label": "Debian", "$poolId": "pool-1"})
+
+	last := make(chan string, 1)
+	go func() { last <- f.burst(3, 20*time.Millisecond) }()
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.WaitForStable = 100 * time.Millisecond
+	vm, err := c.CreateVm(vmReq, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+	if expected := <-last; vm.Addresses["0/ipv4/0"] != expected {
+		t.Errorf("expected the VM read after its last update with address `%s` but received: %v", expected, vm.Addresses)
+	}
+}
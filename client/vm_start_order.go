@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sleepStartDelay waits for the start delay of a group of VMs. Tests
+// replace it to avoid waiting.
+var sleepStartDelay = func(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+type VmStartFailure struct {
+	VmId string
+	Err  error
+}
+
+// StartOrderError is returned by StartVmsInOrder when some VMs failed to
+// start. Skipped holds the VMs of the later orders that weren't started
+// because of the failures.
+type StartOrderError struct {
+	Failed  []VmStartFailure
+	Skipped []string
+}
+
+func (e StartOrderError) Error() string {
+	failures := []string{}
+	for _, f := range e.Failed {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.VmId, f.Err))
+	}
+	msg := fmt.Sprintf("failed to start %d VM(s): %s", len(e.Failed), strings.Join(failures, ", "))
+	if len(e.Skipped) > 0 {
+		msg += fmt.Sprintf(", skipped %d VM(s) of later orders", len(e.Skipped))
+	}
+	return msg
+}
+
+// StartVmsInOrder starts the VMs by group of StartOrder, lowest first, the
+// way XAPI starts the VMs of an HA pool. The VMs of a group are started
+// together and the next group is only started once they are all running
+// and, when respectDelays is set, once the longest StartDelay of the group
+// has elapsed. VMs already running are left as is. A failure stops the
+// VMs of the later orders from being started, see
+// StartVmsInOrderBestEffort.
+func (c *Client) StartVmsInOrder(ctx context.Context, vmIds []string, respectDelays bool) error {
+	return c.startVmsInOrder(ctx, vmIds, respectDelays, false)
+}
+
+// StartVmsInOrderBestEffort starts the VMs like StartVmsInOrder but keeps
+// starting the later orders when VMs fail to start.
+func (c *Client) StartVmsInOrderBestEffort(ctx context.Context, vmIds []string, respectDelays bool) error {
+	return c.startVmsInOrder(ctx, vmIds, respectDelays, true)
+}
+
+func (c *Client) startVmsInOrder(ctx context.Context, vmIds []string, respectDelays, continueOnError bool) error {
+	groups := map[int][]*Vm{}
+	for _, id := range vmIds {
+		vm, err := c.GetVm(Vm{Id: id})
+		if err != nil {
+			return err
+		}
+		groups[vm.StartOrder] = append(groups[vm.StartOrder], vm)
+	}
+
+	orders := []int{}
+	for order := range groups {
+		orders = append(orders, order)
+	}
+	sort.Ints(orders)
+
+	startErr := StartOrderError{}
+	for i, order := range orders {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		group := groups[order]
+		var mu sync.Mutex
+		forEachConcurrently(len(group), len(group), func(j int) {
+			vm := group[j]
+			if vm.PowerState == PowerStateRunning {
+				return
+			}
+			if err := c.startVm(vm.Id, ""); err != nil {
+				mu.Lock()
+				startErr.Failed = append(startErr.Failed, VmStartFailure{VmId: vm.Id, Err: err})
+				mu.Unlock()
+			}
+		})
+		sort.Slice(startErr.Failed, func(i, j int) bool {
+			return startErr.Failed[i].VmId < startErr.Failed[j].VmId
+		})
+
+		if len(startErr.Failed) > 0 && !continueOnError {
+			for _, later := range orders[i+1:] {
+				for _, vm := range groups[later] {
+					startErr.Skipped = append(startErr.Skipped, vm.Id)
+				}
+			}
+			return startErr
+		}
+
+		if respectDelays && i < len(orders)-1 {
+			delay := 0
+			for _, vm := range group {
+				if vm.StartDelay > delay {
+					delay = vm.StartDelay
+				}
+			}
+			if err := sleepStartDelay(ctx, time.Duration(delay)*time.Second); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(startErr.Failed) > 0 {
+		return startErr
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStartOrderRPC serves halted VMs which are running once vm.start is
+// called, except for the ones in failing.
+func fakeStartOrderRPC(vms []map[string]interface{}, failing ...string) *fakeRPC {
+	var mu sync.Mutex
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, vms...), nil
+		case "vm.start":
+			for _, id := range failing {
+				if params["id"] == id {
+					return nil, xapiError("BOOTLOADER_FAILED", id)
+				}
+			}
+			for _, vm := range vms {
+				if vm["id"] == params["id"] {
+					vm["power_state"] = "Running"
+				}
+			}
+			return true, nil
+		}
+		return nil, nil
+	}}
+}
+
+func startOrderFixtures() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"id": "app-1", "type": "VM", "power_state": "Halted", "order": 2, "startDelay": 0},
+		{"id": "app-2", "type": "VM", "power_state": "Halted", "order": 2, "startDelay": 0},
+		{"id": "db-1", "type": "VM", "power_state": "Halted", "order": 0, "startDelay": 30},
+		{"id": "db-2", "type": "VM", "power_state": "Halted", "order": 0, "startDelay": 60},
+		{"id": "cache", "type": "VM", "power_state": "Running", "order": 1, "startDelay": 10},
+	}
+}
+
+// startedVms returns the VMs vm.start was called for, in order.
+func startedVms(rpc *fakeRPC) []string {
+	ids := []string{}
+	for _, call := range rpc.callsTo("vm.start") {
+		ids = append(ids, call.params["id"].(string))
+	}
+	return ids
+}
+
+func recordStartDelays(t *testing.T) *[]time.Duration {
+	delays := []time.Duration{}
+	sleep := sleepStartDelay
+	sleepStartDelay = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	t.Cleanup(func() { sleepStartDelay = sleep })
+	return &delays
+}
+
+func TestStartVmsInOrder(t *testing.T) {
+	delays := recordStartDelays(t)
+	rpc := fakeStartOrderRPC(startOrderFixtures())
+	c := &Client{rpc: rpc}
+
+	err := c.StartVmsInOrder(context.Background(), []string{"app-1", "app-2", "cache", "db-1", "db-2"}, true)
+	if err != nil {
+		t.Fatalf("failed to start VMs in order with error: %v", err)
+	}
+
+	started := startedVms(rpc)
+	if len(started) != 4 {
+		t.Fatalf("expected the 4 halted VMs to be started but received: %v", started)
+	}
+	for _, id := range started[:2] {
+		if id != "db-1" && id != "db-2" {
+			t.Errorf("expected the databases of order 0 to be started first but received: %v", started)
+		}
+	}
+	for _, id := range started[2:] {
+		if id != "app-1" && id != "app-2" {
+			t.Errorf("expected the app servers of order 2 to be started last but received: %v", started)
+		}
+	}
+
+	// The longest delay of each group but the last one
+	expected := []time.Duration{60 * time.Second, 10 * time.Second}
+	if !reflect.DeepEqual(*delays, expected) {
+		t.Errorf("expected start delays %v but received %v", expected, *delays)
+	}
+}
+
+func TestStartVmsInOrder_withoutDelays(t *testing.T) {
+	delays := recordStartDelays(t)
+	c := &Client{rpc: fakeStartOrderRPC(startOrderFixtures())}
+
+	if err := c.StartVmsInOrder(context.Background(), []string{"app-1", "db-1"}, false); err != nil {
+		t.Fatalf("failed to start VMs in order with error: %v", err)
+	}
+	if len(*delays) != 0 {
+		t.Errorf("expected start delays to be ignored but received: %v", *delays)
+	}
+}
+
+func TestStartVmsInOrder_failureAbortsLaterGroups(t *testing.T) {
+	recordStartDelays(t)
+	rpc := fakeStartOrderRPC(startOrderFixtures(), "db-2")
+	c := &Client{rpc: rpc}
+
+	err := c.StartVmsInOrder(context.Background(), []string{"app-1", "app-2", "db-1", "db-2"}, true)
+	var startErr StartOrderError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("expected a StartOrderError but received: %v", err)
+	}
+	if len(startErr.Failed) != 1 || startErr.Failed[0].VmId != "db-2" {
+		t.Errorf("expected db-2 to fail to start but received: %+v", startErr.Failed)
+	}
+	if !reflect.DeepEqual(startErr.Skipped, []string{"app-1", "app-2"}) {
+		t.Errorf("expected the app servers to be skipped but received: %v", startErr.Skipped)
+	}
+	if started := startedVms(rpc); len(started) != 2 {
+		t.Errorf("expected only the databases to be started but received: %v", started)
+	}
+}
+
+func TestStartVmsInOrderBestEffort(t *testing.T) {
+	recordStartDelays(t)
+	rpc := fakeStartOrderRPC(startOrderFixtures(), "db-2")
+	c := &Client{rpc: rpc}
+
+	err := c.StartVmsInOrderBestEffort(context.Background(), []string{"app-1", "app-2", "db-1", "db-2"}, true)
+	var startErr StartOrderError
+	if !errors.As(err, &startErr) || len(startErr.Failed) != 1 || len(startErr.Skipped) != 0 {
+		t.Fatalf("expected db-2 to be reported as failed without skipping VMs but received: %v", err)
+	}
+	if started := startedVms(rpc); len(started) != 4 {
+		t.Errorf("expected the app servers to be started despite the failure but received: %v", started)
+	}
+}
+
+func TestUpdateVm_startOrder(t *testing.T) {
+	params := map[string]interface{}{}
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	rpc := &fakeRPC{handler: func(method string, p map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "vm.set":
+			params = p
+			return true, nil
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(p, map[string]interface{}{"id": testUuid, "type": "VM", "order": 3, "startDelay": 45}), nil
+		}
+		return nil, nil
+	}}
+	c := &Client{rpc: rpc}
+
+	vm, err := c.UpdateVm(Vm{Id: testUuid, CPUs: CPUs{Number: 1}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, StartOrder: 5, StartDelay: 45})
+	if err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	if params["order"] != float64(5) || params["startDelay"] != float64(45) {
+		t.Errorf("expected vm.set to receive the start order and delay but received: %v", params)
+	}
+	if vm.StartOrder != 3 || vm.StartDelay != 45 {
+		t.Errorf("expected the start order and delay to be decoded but received: %+v", vm)
+	}
+
+	if _, err := c.UpdateVm(Vm{Id: testUuid, CPUs: CPUs{Number: 1}, Memory: MemoryObject{Static: []int64{0, minVmMemory}}, StartOrder: 3, StartDelay: 45}); err != nil {
+		t.Fatalf("failed to update VM with error: %v", err)
+	}
+	if _, ok := params["order"]; ok {
+		t.Errorf("expected the unchanged start order not to be sent but received: %v", params)
+	}
+}
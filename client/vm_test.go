@@ -1,8 +1,15 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var vmObjectData string = `
@@ -180,7 +187,7 @@ func TestUpdateVmWithUpdatesThatRequireHalt(t *testing.T) {
 
 	prevCPUs := accVm.CPUs.Number
 	updatedCPUs := prevCPUs + 1
-	vm, err := c.UpdateVm(Vm{Id: accVm.Id, CPUs: CPUs{Number: updatedCPUs}, NameLabel: "terraform testing", Memory: MemoryObject{Static: []int{0, 4294967296}}})
+	vm, err := c.UpdateVm(Vm{Id: accVm.Id, CPUs: CPUs{Number: updatedCPUs}, NameLabel: "terraform testing", Memory: MemoryObject{Static: []int64{0, 4294967296}}})
 
 	if err != nil {
 		t.Fatalf("failed to update vm with error: %v", err)
@@ -191,6 +198,108 @@ func TestUpdateVmWithUpdatesThatRequireHalt(t *testing.T) {
 	}
 }
 
+func TestSearchVms_sortByNameAcrossPages(t *testing.T) {
+	vms := []map[string]interface{}{
+		{"id": "1", "type": "VM", "name_label": "echo"},
+		{"id": "2", "type": "VM", "name_label": "alpha"},
+		{"id": "3", "type": "VM", "name_label": "delta"},
+		{"id": "4", "type": "VM", "name_label": "charlie"},
+		{"id": "5", "type": "VM", "name_label": "bravo"},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, vms...), nil
+	}}
+	c := Client{rpc: rpc}
+
+	expected := [][]string{
+		{"alpha", "bravo"},
+		{"charlie", "delta"},
+		{"echo"},
+	}
+	for i, names := range expected {
+		res, err := c.SearchVms(SearchParams{SortBy: "name_label", SortOrder: "asc", Page: i + 1, Limit: 2})
+		if err != nil {
+			t.Fatalf("failed to search vms with error: %v", err)
+		}
+
+		if res.Total != len(vms) {
+			t.Errorf("expected total of %d vms, instead received %d", len(vms), res.Total)
+		}
+
+		got := []string{}
+		for _, vm := range res.Vms {
+			got = append(got, vm.NameLabel)
+		}
+		if !reflect.DeepEqual(got, names) {
+			t.Errorf("expected page %d to contain %v, instead received %v", i+1, names, got)
+		}
+	}
+}
+
+func TestSearchVms_unsortedPagesFollowIds(t *testing.T) {
+	vms := []map[string]interface{}{}
+	for _, id := range []string{"3", "1", "5", "2", "4"} {
+		vms = append(vms, map[string]interface{}{"id": id, "type": "VM", "name_label": "web"})
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, vms...), nil
+	}}
+	c := Client{rpc: rpc}
+
+	expected := [][]string{{"1", "2"}, {"3", "4"}, {"5"}}
+	for i, ids := range expected {
+		res, err := c.SearchVms(SearchParams{Page: i + 1, Limit: 2})
+		if err != nil {
+			t.Fatalf("failed to search vms with error: %v", err)
+		}
+		got := []string{}
+		for _, vm := range res.Vms {
+			got = append(got, vm.Id)
+		}
+		if !reflect.DeepEqual(got, ids) {
+			t.Errorf("expected page %d to contain %v, instead received %v", i+1, ids, got)
+		}
+	}
+}
+
+func TestSearchVms_sortByMemoryAndCreation(t *testing.T) {
+	vms := []map[string]interface{}{
+		{"id": "1", "type": "VM", "name_label": "small", "memory": map[string]interface{}{"size": 1 * gib}, "installTime": 1552287083},
+		{"id": "2", "type": "VM", "name_label": "large", "memory": map[string]interface{}{"size": 8 * gib}, "installTime": 1552000000},
+		{"id": "3", "type": "VM", "name_label": "medium", "memory": map[string]interface{}{"size": 4 * gib}, "installTime": 1552500000},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, vms...), nil
+	}}
+	c := Client{rpc: rpc}
+
+	tests := []struct {
+		params   SearchParams
+		expected []string
+	}{
+		{SearchParams{SortBy: "memory", SortOrder: "desc"}, []string{"large", "medium", "small"}},
+		{SearchParams{SortBy: "created"}, []string{"large", "small", "medium"}},
+	}
+	for _, test := range tests {
+		res, err := c.SearchVms(test.params)
+		if err != nil {
+			t.Fatalf("failed to search vms with error: %v", err)
+		}
+		got := []string{}
+		for _, vm := range res.Vms {
+			got = append(got, vm.NameLabel)
+		}
+		if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("expected %+v to return %v, instead received %v", test.params, test.expected, got)
+		}
+	}
+
+	_, err := c.SearchVms(SearchParams{SortBy: "power_state", SortOrder: "up"})
+	if fields := validationFields(t, err); !reflect.DeepEqual(fields, []string{"SortBy", "SortOrder"}) {
+		t.Errorf("expected the unknown sort field and order to be rejected but received: %v", err)
+	}
+}
+
 func validateVmObject(o Vm) bool {
 	if o.Type != "VM" {
 		return false
@@ -229,3 +338,403 @@ func validateVmObject(o Vm) bool {
 
 	return true
 }
+
+func TestStartVmWithOptions_replacesCloudConfigDrive(t *testing.T) {
+	vm := map[string]interface{}{"id": "vm-1", "type": "VM", "power_state": "Halted"}
+	objects := []map[string]interface{}{
+		vm,
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "old-drive", "is_cd_drive": false},
+		{"id": "old-drive", "type": "VDI", "name_label": "XO CloudConfigDrive", "$SR": "sr-1"},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "vm.start":
+			vm["power_state"] = "Running"
+		case "cloudConfig.createConfigDrive":
+			return "new-drive", nil
+		}
+		return true, nil
+	}}
+	c := Client{rpc: rpc}
+
+	userData := "#cloud-config\nhostname: rotated\n"
+	err := c.StartVmWithOptions("vm-1", StartVmOptions{CloudConfig: userData})
+	if err != nil {
+		t.Fatalf("failed to start vm with error: %v", err)
+	}
+
+	expected := []string{"cloudConfig.createConfigDrive", "vdi.delete", "vm.start"}
+	got := []string{}
+	for _, method := range rpc.methods() {
+		if method != "xo.getAllObjects" {
+			got = append(got, method)
+		}
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected calls %v but received %v", expected, got)
+	}
+
+	if deleted := rpc.callsTo("vdi.delete")[0].params["id"]; deleted != "old-drive" {
+		t.Errorf("expected the old config drive to be deleted, instead deleted %v", deleted)
+	}
+
+	params := rpc.callsTo("cloudConfig.createConfigDrive")[0].params
+	if params["template"] != userData || params["sr"] != "sr-1" || params["vm"] != "vm-1" {
+		t.Errorf("expected the new config drive to hold the new user data on sr-1 but received %v", params)
+	}
+}
+
+func TestStartVmWithOptions_keepsCloudConfigDriveWhenCreationFails(t *testing.T) {
+	objects := []map[string]interface{}{
+		{"id": "vm-1", "type": "VM", "power_state": "Halted"},
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "old-drive", "is_cd_drive": false},
+		{"id": "old-drive", "type": "VDI", "name_label": "XO CloudConfigDrive", "$SR": "sr-1"},
+	}
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "cloudConfig.createConfigDrive":
+			return nil, errors.New("SR_FULL")
+		}
+		return true, nil
+	}}
+	c := Client{rpc: rpc}
+
+	if err := c.StartVmWithOptions("vm-1", StartVmOptions{CloudConfig: "#cloud-config\n"}); err == nil {
+		t.Fatalf("expected the failure to create the drive to be returned")
+	}
+	if deleted := rpc.callsTo("vdi.delete"); len(deleted) != 0 {
+		t.Errorf("expected the old config drive to be kept but received: %v", deleted)
+	}
+}
+
+func TestWaitForCreatedVm_ipAssignedPollsAddresses(t *testing.T) {
+	polls := 0
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		polls++
+		vm := map[string]interface{}{"id": "vm-1", "type": "VM", "power_state": "Running"}
+		if polls > 3 {
+			vm["addresses"] = map[string]string{"0/ipv4/0": "10.0.0.2"}
+		}
+		return fakeGetAllObjects(params, vm), nil
+	}}
+	c := Client{rpc: rpc}
+
+	err := c.waitForCreatedVm("vm-1", Vm{WaitFor: WaitForIpAssigned, WaitForIpTimeout: 10 * time.Second}, 10*time.Second)
+	if err != nil {
+		t.Fatalf("failed to wait for vm ip with error: %v", err)
+	}
+
+	if polls != 4 {
+		t.Errorf("expected vm to be polled until an address was assigned, instead polled %d times", polls)
+	}
+}
+
+func TestWaitForModifyVm_timeoutReportsLastState(t *testing.T) {
+	c := Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		return fakeGetAllObjects(params, map[string]interface{}{"id": "vm-1", "type": "VM", "power_state": "Halted", "current_operations": map[string]string{"op-1": "start"}}), nil
+	}}}
+
+	err := c.waitForModifyVm("vm-1", false, 300*time.Millisecond)
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a TimeoutError but received: %v", err)
+	}
+	vm, ok := timeoutErr.LastResult.(*Vm)
+	if !ok || vm.PowerState != PowerStateHalted || vm.CurrentOperations["op-1"] != "start" || timeoutErr.LastState != "Halted" || timeoutErr.Polls < 2 || timeoutErr.Elapsed < 300*time.Millisecond {
+		t.Errorf("expected the last state of the VM and the polls to be reported but received: %+v", timeoutErr)
+	}
+	if !strings.Contains(err.Error(), "last seen: VM `vm-1` with power state Halted, current operations [start]") {
+		t.Errorf("expected the message to describe the VM but received: %v", err)
+	}
+}
+
+func TestWaitForCreatedVm_taskCompleteDoesNotPoll(t *testing.T) {
+	rpc := &fakeRPC{}
+	c := Client{rpc: rpc}
+
+	if err := c.waitForCreatedVm("vm-1", Vm{WaitFor: WaitForTaskComplete}, time.Minute); err != nil {
+		t.Fatalf("failed to wait for vm creation with error: %v", err)
+	}
+
+	if len(rpc.methods()) != 0 {
+		t.Errorf("expected no call once the creation task completed, instead received %v", rpc.methods())
+	}
+}
+
+// fakeDeletingVmRPC serves a VM with three disks. Once vm.delete is called
+// the VM disappears right away and one disk disappears on every poll, which
+// must only look up the VM and its disks.
+func fakeDeletingVmRPC() *fakeRPC {
+	var mu sync.Mutex
+	deleted := false
+	vdis := []map[string]interface{}{
+		{"id": "vdi-1", "type": "VDI", "name_label": "disk 1"},
+		{"id": "vdi-2", "type": "VDI", "name_label": "disk 2"},
+		{"id": "vdi-3", "type": "VDI", "name_label": "disk 3"},
+	}
+	vbds := []map[string]interface{}{
+		{"id": "vbd-1", "type": "VBD", "VM": "vm-1", "VDI": "vdi-1"},
+		{"id": "vbd-2", "type": "VBD", "VM": "vm-1", "VDI": "vdi-2"},
+		{"id": "vbd-3", "type": "VBD", "VM": "vm-1", "VDI": "vdi-3"},
+	}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if method == "vm.delete" {
+			deleted = true
+			return []interface{}{}, nil
+		}
+
+		if !deleted {
+			objects := append([]map[string]interface{}{{"id": "vm-1", "type": "VM"}}, vbds...)
+			return fakeGetAllObjects(params, append(objects, vdis...)...), nil
+		}
+
+		// Only the objects being deleted are expected to be looked up
+		filter := params["filter"].(map[string]interface{})
+		if _, ok := filter["id"].(map[string]interface{}); !ok || len(filter) != 1 {
+			return nil, errors.New(fmt.Sprintf("unexpected lookup of %v", filter))
+		}
+		res := fakeGetAllObjects(params, vdis...)
+		if len(vdis) > 0 {
+			vdis = vdis[1:]
+		}
+		return res, nil
+	}}
+}
+
+func TestDeleteVmContext_tracksStaggeredDiskRemoval(t *testing.T) {
+	rpc := fakeDeletingVmRPC()
+	c := Client{rpc: rpc}
+
+	progress := []string{}
+	err := c.DeleteVmContext(context.Background(), "vm-1", DeleteVmOptions{
+		DeleteDisks:  true,
+		PollInterval: time.Millisecond,
+		Progress: func(p DeleteProgress) {
+			progress = append(progress, fmt.Sprintf("%s %d", p.Id, p.Remaining))
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to delete vm with error: %v", err)
+	}
+
+	expected := []string{"vm-1 3", "vdi-1 2", "vdi-2 1", "vdi-3 0"}
+	if !reflect.DeepEqual(progress, expected) {
+		t.Errorf("expected progress %v but received %v", expected, progress)
+	}
+	if rpc.callsTo("vm.delete")[0].params["deleteDisks"] != true {
+		t.Errorf("expected vm.delete to delete the disks")
+	}
+}
+
+func TestDeleteVmContext_cancelledHalfway(t *testing.T) {
+	c := Client{rpc: fakeDeletingVmRPC()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := c.DeleteVmContext(ctx, "vm-1", DeleteVmOptions{
+		DeleteDisks:  true,
+		PollInterval: time.Millisecond,
+		Progress: func(p DeleteProgress) {
+			if p.Id == "vdi-1" {
+				cancel()
+			}
+		},
+	})
+
+	var incomplete DeleteIncompleteError
+	if !errors.As(err, &incomplete) {
+		t.Fatalf("expected a DeleteIncompleteError but received: %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the error to wrap context.Canceled but received: %v", err)
+	}
+
+	remaining := []string{}
+	for _, r := range incomplete.Remaining {
+		remaining = append(remaining, r.Id)
+	}
+	if !reflect.DeepEqual(remaining, []string{"vdi-2", "vdi-3"}) {
+		t.Errorf("expected vdi-2 and vdi-3 to remain but received %v", remaining)
+	}
+}
+
+func fakeCreateVmRPC(objects ...map[string]interface{}) *fakeRPC {
+	objects = append(objects,
+		map[string]interface{}{"id": testUuid, "type": "VM-template", "name_label": "Debian", "$poolId": "pool-1"},
+		map[string]interface{}{"id": "new-vm", "type": "VM", "name_label": "web", "power_state": "Running"},
+	)
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, objects...), nil
+		case "vm.create":
+			return "new-vm", nil
+		}
+		return true, nil
+	}}
+}
+
+func TestCreateVm_withNewAndExistingDisks(t *testing.T) {
+	existingVdi := "5f0a6a41-8b3c-4c59-a4cb-1f3e5d2e9a77"
+	rpc := fakeCreateVmRPC(map[string]interface{}{"id": existingVdi, "type": "VDI", "name_label": "data", "$VBDs": []string{}})
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.WaitFor = WaitForTaskComplete
+	vmReq.Disks = append(vmReq.Disks, Disk{VDI: VDI{VDIId: existingVdi}, VBD: VBD{ReadOnly: true}})
+
+	if _, err := c.CreateVm(vmReq, time.Minute); err != nil {
+		t.Fatalf("failed to create VM with error: %v", err)
+	}
+
+	create := rpc.callsTo("vm.create")[0].params
+	if create["bootAfterCreate"] != true {
+		t.Errorf("expected the VM to be started by vm.create")
+	}
+	if existing := create["existingDisks"].(map[string]interface{}); len(existing) != 1 {
+		t.Errorf("expected the new disk to replace the template's disk but received: %v", existing)
+	}
+	expected := []interface{}{map[string]interface{}{"vdi": existingVdi, "mode": "RO"}}
+	if !reflect.DeepEqual(create["VDIs"], expected) {
+		t.Errorf("expected the existing VDI to be attached read only by vm.create but received: %v", create["VDIs"])
+	}
+	if len(rpc.callsTo("vm.attachDisk")) != 0 || len(rpc.callsTo("vm.start")) != 0 {
+		t.Errorf("expected the VM to be created with its disks but received calls: %v", rpc.methods())
+	}
+}
+
+func TestCreateVm_existingDiskAttachedElsewhere(t *testing.T) {
+	existingVdi := "5f0a6a41-8b3c-4c59-a4cb-1f3e5d2e9a77"
+	rpc := fakeCreateVmRPC(map[string]interface{}{"id": existingVdi, "type": "VDI", "name_label": "data", "$VBDs": []string{"vbd-1"}})
+	c := &Client{rpc: rpc}
+
+	vmReq := validVmRequest()
+	vmReq.Disks = append(vmReq.Disks, Disk{VDI: VDI{VDIId: existingVdi}})
+
+	var attached DiskAttachedError
+	if _, err := c.CreateVm(vmReq, time.Minute); !errors.As(err, &attached) {
+		t.Fatalf("expected a DiskAttachedError but received: %v", err)
+	}
+	if len(rpc.callsTo("vm.create")) != 0 {
+		t.Errorf("expected the VM not to be created")
+	}
+
+	var notFound NotFound
+	vmReq.Disks[1].VDIId = "0d7f5c2a-0000-4000-8000-000000000000"
+	if _, err := c.CreateVm(vmReq, time.Minute); !errors.As(err, &notFound) {
+		t.Errorf("expected a NotFound error for a missing VDI but received: %v", err)
+	}
+}
+
+// fakeConcurrentVmRPC serves a halted VM and applies the name and memory
+// set by vm.set, so that several clients can update it.
+func fakeConcurrentVmRPC() *fakeRPC {
+	var mu sync.Mutex
+	vm := map[string]interface{}{"id": testUuid, "type": "VM", "name_label": "web", "power_state": "Halted", "tags": []string{"prod"}, "CPUs": map[string]interface{}{"number": 2}, "memory": map[string]interface{}{"static": []int64{0, minVmMemory}}}
+	return &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch method {
+		case "xo.getAllObjects":
+			return fakeGetAllObjects(params, vm), nil
+		case "vm.set":
+			// Replace the VM rather than changing the one already returned
+			updated := map[string]interface{}{}
+			for k, v := range vm {
+				updated[k] = v
+			}
+			if name, ok := params["name_label"]; ok {
+				updated["name_label"] = name
+			}
+			if memory, ok := params["memoryMax"]; ok {
+				updated["memory"] = map[string]interface{}{"static": []interface{}{0, memory}}
+			}
+			vm = updated
+		case "tag.add":
+			updated := map[string]interface{}{}
+			for k, v := range vm {
+				updated[k] = v
+			}
+			updated["tags"] = append(append([]string{}, vm["tags"].([]string)...), params["tag"].(string))
+			vm = updated
+		}
+		return true, nil
+	}}
+}
+
+func TestUpdateVmWithOptions_conflict(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	rpc := fakeConcurrentVmRPC()
+	c := &Client{rpc: rpc}
+	other := &Client{rpc: rpc}
+
+	read, err := c.GetVm(Vm{Id: testUuid})
+	if err != nil {
+		t.Fatalf("failed to read VM with error: %v", err)
+	}
+
+	// Another controller resizes the VM in between
+	resized := *read
+	resized.Memory = MemoryObject{Static: []int64{0, 2 * minVmMemory}}
+	if _, err := other.UpdateVm(resized); err != nil {
+		t.Fatalf("failed to resize VM with error: %v", err)
+	}
+
+	vmReq := *read
+	vmReq.CPUs = CPUs{Number: 4}
+	_, err = c.UpdateVmWithOptions(vmReq, UpdateVmOptions{Read: read})
+	var conflict ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a ConflictError but received: %v", err)
+	}
+	expected := []FieldChange{{Field: "memoryMax", Old: int64(minVmMemory), New: int64(2 * minVmMemory)}}
+	if conflict.Type != "VM" || conflict.Id != testUuid || !reflect.DeepEqual(conflict.Changes, expected) {
+		t.Errorf("expected the resize to be reported as %v but received %+v", expected, conflict)
+	}
+	if !strings.Contains(err.Error(), "fields changed: memoryMax") {
+		t.Errorf("expected the error to list the modified fields but received: %v", err)
+	}
+	if set := rpc.callsTo("vm.set"); len(set) != 1 {
+		t.Errorf("expected only the resize to call vm.set but received: %v", set)
+	}
+
+	vm, err := c.UpdateVmWithOptions(vmReq, UpdateVmOptions{Read: read, ForceUpdate: true})
+	if err != nil || vm.Memory.Static[1] != minVmMemory {
+		t.Errorf("expected the forced update to overwrite the memory but received %+v with error: %v", vm, err)
+	}
+}
+
+func TestUpdateVmWithOptions_ignoresFieldsNotUpdated(t *testing.T) {
+	sleep := updateVmSettleDelay
+	updateVmSettleDelay = 0
+	defer func() { updateVmSettleDelay = sleep }()
+
+	rpc := fakeConcurrentVmRPC()
+	c := &Client{rpc: rpc}
+
+	read, err := c.GetVm(Vm{Id: testUuid})
+	if err != nil {
+		t.Fatalf("failed to read VM with error: %v", err)
+	}
+	if err := c.AddTag(testUuid, "web"); err != nil {
+		t.Fatalf("failed to tag VM with error: %v", err)
+	}
+
+	vmReq := *read
+	vmReq.NameLabel = "web-1"
+	vm, err := c.UpdateVmWithOptions(vmReq, UpdateVmOptions{Read: read})
+	if err != nil || vm.NameLabel != "web-1" {
+		t.Errorf("expected a tag added meanwhile not to conflict with the rename but received %+v with error: %v", vm, err)
+	}
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"time"
+)
+
+// VmUptime returns how long the VM has been running. It is computed from
+// the VM's StartTime, the boot time of XAPI's last boot record, and the
+// clock of the host running the VM so that a skew between the client's
+// and the host's clocks doesn't affect it. Halted and suspended VMs have
+// a zero uptime.
+func (c *Client) VmUptime(vmId string) (time.Duration, error) {
+	vm, err := c.GetVm(Vm{Id: vmId})
+	if err != nil {
+		return 0, err
+	}
+
+	if vm.StartTime.IsZero() || vm.PowerState == PowerStateHalted || vm.PowerState == PowerStateSuspended {
+		return 0, nil
+	}
+
+	now, err := c.GetHostTime(vm.Host)
+	if err != nil {
+		return 0, err
+	}
+
+	uptime := now.Sub(vm.StartTime.Time)
+	if uptime < 0 {
+		return 0, nil
+	}
+	return uptime, nil
+}
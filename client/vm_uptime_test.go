@@ -0,0 +1,55 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func uptimeClient(vm map[string]interface{}) (*Client, *fakeRPC) {
+	rpc := &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "host.getServerTime" {
+			return "20240315T08:30:05Z", nil
+		}
+		return fakeGetAllObjects(params, vm), nil
+	}}
+	return &Client{rpc: rpc}, rpc
+}
+
+func TestVmUptime_running(t *testing.T) {
+	startTime := time.Date(2024, 3, 15, 8, 0, 0, 0, time.UTC).Unix()
+	c, rpc := uptimeClient(map[string]interface{}{"id": "vm-1", "type": "VM", "power_state": "Running", "$container": "host-1", "startTime": startTime})
+
+	uptime, err := c.VmUptime("vm-1")
+	if err != nil {
+		t.Fatalf("failed to get the uptime of the VM with error: %v", err)
+	}
+	if expected := 30*time.Minute + 5*time.Second; uptime != expected {
+		t.Errorf("expected an uptime of %s but received %s", expected, uptime)
+	}
+	if calls := rpc.callsTo("host.getServerTime"); len(calls) != 1 || calls[0].params["id"] != "host-1" {
+		t.Errorf("expected the time of the VM's host to be read but received: %v", calls)
+	}
+}
+
+func TestVmUptime_halted(t *testing.T) {
+	c, rpc := uptimeClient(map[string]interface{}{"id": "vm-1", "type": "VM", "power_state": "Halted", "$container": "pool-1", "startTime": nil})
+
+	uptime, err := c.VmUptime("vm-1")
+	if err != nil || uptime != 0 {
+		t.Errorf("expected a zero uptime for a halted VM but received %s with error: %v", uptime, err)
+	}
+	if calls := rpc.callsTo("host.getServerTime"); len(calls) != 0 {
+		t.Errorf("expected no host time lookup for a halted VM but received: %v", calls)
+	}
+}
+
+func TestVm_startTime(t *testing.T) {
+	var vm Vm
+	if err := json.Unmarshal([]byte(vmObjectData), &vm); err != nil {
+		t.Fatalf("failed to decode the VM with error: %v", err)
+	}
+	if vm.StartTime.Unix() != 1552445802 {
+		t.Errorf("expected the start time to be decoded but received: %s", vm.StartTime)
+	}
+}
@@ -0,0 +1,194 @@
+// Package wait provides the backoff and polling primitives used by the
+// client to wait for XO objects to reach a given state. They are exported
+// so that code built on top of the client can wait on its own conditions
+// the same way.
+package wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultInitial = 100 * time.Millisecond
+	DefaultFactor  = 2
+)
+
+// Backoff computes exponentially growing delays. The zero value starts at
+// DefaultInitial, doubles on every call to Next and is not capped.
+type Backoff struct {
+	// First delay returned by Next
+	Initial time.Duration
+	// Upper bound of the returned delays, including jitter. No cap when 0.
+	Max time.Duration
+	// Multiplier applied after every delay. A factor of 1 gives a
+	// constant delay.
+	Factor float64
+	// Fraction of the delay, between 0 and 1, by which each returned
+	// delay is randomly shortened or lengthened.
+	Jitter float64
+	// Return a random delay between 0 and the computed one instead, the
+	// "full jitter" algorithm. It spreads the attempts of many clients
+	// backing off from the same failure, e.g. a restart of XO, the most.
+	// Takes precedence over Jitter.
+	FullJitter bool
+	// Source of the jitter, the default source of math/rand when nil. A
+	// seeded source makes the delays reproducible. It must not be shared
+	// by backoffs used concurrently.
+	Rand *rand.Rand
+
+	current time.Duration
+}
+
+// Next returns the delay to wait before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.current == 0 {
+		b.current = b.Initial
+		if b.current <= 0 {
+			b.current = DefaultInitial
+		}
+	}
+
+	d := b.current
+	if b.Jitter > 0 && !b.FullJitter {
+		delta := b.Jitter * float64(d)
+		d = time.Duration(float64(d) - delta + b.random()*2*delta)
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+	if b.FullJitter {
+		d = time.Duration(b.random() * float64(d))
+	}
+
+	factor := b.Factor
+	if factor <= 0 {
+		factor = DefaultFactor
+	}
+	b.current = time.Duration(float64(b.current) * factor)
+	if b.Max > 0 && b.current > b.Max {
+		b.current = b.Max
+	}
+	return d
+}
+
+func (b *Backoff) random() float64 {
+	if b.Rand != nil {
+		return b.Rand.Float64()
+	}
+	return rand.Float64()
+}
+
+// Reset makes the next call to Next return the initial delay again.
+func (b *Backoff) Reset() {
+	b.current = 0
+}
+
+// PermanentError wraps an error returned by a condition to stop Poll and
+// Until from trying again.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// ConditionFunc reports whether the awaited condition is met. Errors are
+// retried unless they are wrapped with Permanent.
+type ConditionFunc func(ctx context.Context) (done bool, err error)
+
+// StoppedError is returned by Until when ctx is done before the condition
+// is met. It wraps the error of ctx.
+type StoppedError struct {
+	Err error
+	// Last error returned by the condition, if any
+	LastError error
+	// Number of evaluations of the condition
+	Attempts int
+	Elapsed  time.Duration
+}
+
+func (e *StoppedError) Error() string {
+	msg := fmt.Sprintf("%v after %d attempts in %s", e.Err, e.Attempts, e.Elapsed.Round(time.Millisecond))
+	if e.LastError != nil {
+		msg = fmt.Sprintf("%s: last error: %v", msg, e.LastError)
+	}
+	return msg
+}
+
+func (e *StoppedError) Unwrap() error {
+	return e.Err
+}
+
+type UntilOptions struct {
+	// Delays between evaluations of the condition
+	Backoff Backoff
+	// Gives up after this duration when positive, in addition to ctx
+	Timeout time.Duration
+}
+
+// Until evaluates cond immediately and then after every delay of the
+// backoff until it returns true or a permanent error, or until ctx is done.
+//
+// A permanent error is returned unwrapped. When ctx is done, a
+// *StoppedError wrapping the error of ctx is returned.
+func Until(ctx context.Context, cond ConditionFunc, opts UntilOptions) error {
+	// Taken before the deadline so that Elapsed is never shorter than
+	// the timeout
+	start := time.Now()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	backoff := opts.Backoff
+	attempts := 0
+	var lastErr error
+	for {
+		done, err := cond(ctx)
+		attempts++
+		if err != nil {
+			var permanent *PermanentError
+			if errors.As(err, &permanent) {
+				return permanent.Err
+			}
+			lastErr = err
+		} else if done {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff.Next())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &StoppedError{Err: ctx.Err(), LastError: lastErr, Attempts: attempts, Elapsed: time.Since(start)}
+		case <-timer.C:
+		}
+	}
+}
+
+// Poll evaluates fn immediately and then every interval until it returns
+// true or a permanent error, or until ctx is done. See Until for the
+// returned errors.
+func Poll(ctx context.Context, interval time.Duration, fn ConditionFunc) error {
+	return Until(ctx, fn, UntilOptions{
+		Backoff: Backoff{Initial: interval, Factor: 1},
+	})
+}
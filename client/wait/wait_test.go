@@ -0,0 +1,151 @@
+package wait
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackoff_growsUntilCap(t *testing.T) {
+	b := Backoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond}
+
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, e := range expected {
+		if d := b.Next(); d != e*time.Millisecond {
+			t.Errorf("expected delay %d to be %s, instead received %s", i, e*time.Millisecond, d)
+		}
+	}
+
+	b.Reset()
+	if d := b.Next(); d != 10*time.Millisecond {
+		t.Errorf("expected delay after reset to be 10ms, instead received %s", d)
+	}
+}
+
+func TestBackoff_jitterStaysWithinBounds(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Factor: 1, Jitter: 0.5, Max: 120 * time.Millisecond}
+
+	for i := 0; i < 100; i++ {
+		d := b.Next()
+		if d < 50*time.Millisecond || d > 120*time.Millisecond {
+			t.Fatalf("expected jittered delay to be between 50ms and 120ms, instead received %s", d)
+		}
+	}
+}
+
+func TestBackoff_fullJitter(t *testing.T) {
+	delays := func(seed int64) []time.Duration {
+		b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, FullJitter: true, Rand: rand.New(rand.NewSource(seed))}
+		delays := []time.Duration{}
+		for i := 0; i < 6; i++ {
+			delays = append(delays, b.Next())
+		}
+		return delays
+	}
+
+	caps := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	first := delays(1)
+	for i, d := range first {
+		if d < 0 || d > caps[i]*time.Millisecond {
+			t.Errorf("expected delay %d to be between 0 and %s, instead received %s", i, caps[i]*time.Millisecond, d)
+		}
+	}
+	if first[4] == first[5] {
+		t.Errorf("expected the capped delays to vary, instead received %v", first)
+	}
+
+	if again := delays(1); !reflect.DeepEqual(again, first) {
+		t.Errorf("expected the same delays with the same seed, instead received %v and %v", first, again)
+	}
+	if other := delays(2); reflect.DeepEqual(other, first) {
+		t.Errorf("expected different delays with another seed, instead received %v twice", first)
+	}
+}
+
+func TestPoll_immediateSuccess(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := Poll(context.Background(), time.Hour, func(ctx context.Context) (bool, error) {
+		calls++
+		return true, nil
+	})
+
+	if err != nil {
+		t.Fatalf("expected poll to succeed, instead received: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected condition to be evaluated once, instead evaluated %d times", calls)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected poll to return without waiting")
+	}
+}
+
+func TestPoll_contextCancelledBetweenPolls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	retryable := errors.New("not ready")
+
+	calls := 0
+	err := Poll(ctx, 10*time.Millisecond, func(ctx context.Context) (bool, error) {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return false, retryable
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a context.Canceled error, instead received: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected no evaluation after cancellation, instead evaluated %d times", calls)
+	}
+}
+
+func TestUntil_permanentErrorStopsRetries(t *testing.T) {
+	failure := errors.New("vm was deleted")
+
+	calls := 0
+	err := Until(context.Background(), func(ctx context.Context) (bool, error) {
+		calls++
+		if calls == 2 {
+			return false, Permanent(failure)
+		}
+		return false, errors.New("transient")
+	}, UntilOptions{Backoff: Backoff{Initial: time.Millisecond}})
+
+	if err != failure {
+		t.Fatalf("expected the permanent error to be returned unwrapped, instead received: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 evaluations, instead evaluated %d times", calls)
+	}
+}
+
+func TestUntil_timeout(t *testing.T) {
+	err := Until(context.Background(), func(ctx context.Context) (bool, error) {
+		return false, nil
+	}, UntilOptions{Backoff: Backoff{Initial: time.Millisecond}, Timeout: 20 * time.Millisecond})
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a context.DeadlineExceeded error, instead received: %v", err)
+	}
+}
+
+func TestUntil_timeoutReportsAttempts(t *testing.T) {
+	notReady := errors.New("vm is still halted")
+	err := Until(context.Background(), func(ctx context.Context) (bool, error) {
+		return false, notReady
+	}, UntilOptions{Backoff: Backoff{Initial: time.Millisecond, Factor: 1}, Timeout: 20 * time.Millisecond})
+
+	var stopped *StoppedError
+	if !errors.As(err, &stopped) {
+		t.Fatalf("expected a StoppedError, instead received: %v", err)
+	}
+	if stopped.LastError != notReady || stopped.Attempts < 2 || stopped.Elapsed < 20*time.Millisecond {
+		t.Errorf("expected the attempts, elapsed time and last error to be reported, instead received: %+v", stopped)
+	}
+}
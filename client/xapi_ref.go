@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// XO identifies the objects it gets from XAPI by their uuid and keeps their
+// XAPI opaque reference (e.g. `OpaqueRef:...`) in their `_xapiRef`, decoded
+// into the XapiRef of Vm, Host, StorageRepository, VDI, VIF and Network.
+
+// XapiObject looks an object up by its XAPI opaque reference.
+type XapiObject struct {
+	Ref string
+}
+
+func (o XapiObject) Compare(obj interface{}) bool {
+	other := obj.(XapiObject)
+	return o.Ref == other.Ref
+}
+
+// NoXapiRefError is returned for objects XO doesn't get from XAPI, e.g.
+// cloud configs, which have no opaque reference.
+type NoXapiRefError struct {
+	ObjectId string
+	Type     string
+}
+
+func (e NoXapiRefError) Error() string {
+	return fmt.Sprintf("%s `%s` is not a XAPI object and has no opaque reference", e.Type, e.ObjectId)
+}
+
+// GetXapiRef returns the XAPI opaque reference of the object with the id.
+func (c *Client) GetXapiRef(objectId string) (string, error) {
+	var objsRes map[string]struct {
+		Type    string `json:"type"`
+		XapiRef string `json:"_xapiRef"`
+	}
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"id": objectId,
+		},
+	}
+	err := c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return "", err
+	}
+
+	obj, ok := objsRes[objectId]
+	if ok && obj.XapiRef != "" {
+		return obj.XapiRef, nil
+	}
+	if ok {
+		return "", NoXapiRefError{ObjectId: objectId, Type: obj.Type}
+	}
+
+	// Cloud configs aren't listed by xo.getAllObjects
+	cloudConfig, err := c.GetCloudConfig(objectId)
+	if err != nil {
+		return "", err
+	}
+	if cloudConfig != nil {
+		return "", NoXapiRefError{ObjectId: objectId, Type: "cloud config"}
+	}
+	return "", NotFound{Query: Object{Id: objectId}}
+}
+
+// FindByXapiRef returns the id and XO type of the object with the XAPI
+// opaque reference.
+func (c *Client) FindByXapiRef(ref string) (objectId string, objectType string, err error) {
+	var objsRes map[string]json.RawMessage
+	params := map[string]interface{}{
+		"filter": map[string]string{
+			"_xapiRef": ref,
+		},
+	}
+	err = c.Call("xo.getAllObjects", params, &objsRes)
+	if err != nil {
+		return "", "", err
+	}
+
+	if len(objsRes) == 0 {
+		return "", "", NotFound{Query: XapiObject{Ref: ref}}
+	}
+	// Every pool connected to XO has its own references
+	if len(objsRes) > 1 {
+		return "", "", AmbiguousResultError{Query: XapiObject{Ref: ref}, Matches: len(objsRes)}
+	}
+
+	var obj struct {
+		Id   string `json:"id"`
+		Type string `json:"type"`
+	}
+	for _, raw := range objsRes {
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return "", "", err
+		}
+	}
+	return obj.Id, obj.Type, nil
+}
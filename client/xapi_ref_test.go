@@ -0,0 +1,110 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var xapiRefObjects = []map[string]interface{}{
+	{"id": "vm-1", "type": "VM", "_xapiRef": "OpaqueRef:vm-1"},
+	{"id": "host-1", "type": "host", "_xapiRef": "OpaqueRef:host-1"},
+	{"id": "sr-1", "type": "SR", "_xapiRef": "OpaqueRef:sr-1"},
+	{"id": "vdi-1", "type": "VDI", "_xapiRef": "OpaqueRef:vdi-1"},
+	{"id": "vif-1", "type": "VIF", "_xapiRef": "OpaqueRef:vif-1"},
+	{"id": "network-1", "type": "network", "_xapiRef": "OpaqueRef:network-1"},
+	{"id": "unknown-1", "type": "unknown"},
+}
+
+func xapiRefClient() *Client {
+	return &Client{rpc: &fakeRPC{handler: func(method string, params map[string]interface{}) (interface{}, error) {
+		if method == "cloudConfig.getAll" {
+			return []map[string]interface{}{{"id": "cc-1", "name": "base"}}, nil
+		}
+		return fakeGetAllObjects(params, xapiRefObjects...), nil
+	}}}
+}
+
+func TestXapiRef_decode(t *testing.T) {
+	fixtures := map[string]interface{}{
+		`{"id": "vm-1", "_xapiRef": "OpaqueRef:vm-1"}`:       &Vm{},
+		`{"id": "host-1", "_xapiRef": "OpaqueRef:host-1"}`:   &Host{},
+		`{"id": "sr-1", "_xapiRef": "OpaqueRef:sr-1"}`:       &StorageRepository{},
+		`{"id": "vdi-1", "_xapiRef": "OpaqueRef:vdi-1"}`:     &VDI{},
+		`{"id": "vif-1", "_xapiRef": "OpaqueRef:vif-1"}`:     &VIF{},
+		`{"id": "net-1", "_xapiRef": "OpaqueRef:network-1"}`: &Network{},
+	}
+	for fixture, obj := range fixtures {
+		if err := json.Unmarshal([]byte(fixture), obj); err != nil {
+			t.Fatalf("failed to decode %T with error: %v", obj, err)
+		}
+
+		var ref string
+		switch o := obj.(type) {
+		case *Vm:
+			ref = o.XapiRef
+		case *Host:
+			ref = o.XapiRef
+		case *StorageRepository:
+			ref = o.XapiRef
+		case *VDI:
+			ref = o.XapiRef
+		case *VIF:
+			ref = o.XapiRef
+		case *Network:
+			ref = o.XapiRef
+		}
+		if ref == "" {
+			t.Errorf("expected the XAPI reference of %T to be decoded from %s", obj, fixture)
+		}
+	}
+}
+
+func TestGetXapiRef_FindByXapiRef_roundTrip(t *testing.T) {
+	c := xapiRefClient()
+
+	for _, obj := range xapiRefObjects[:6] {
+		id := obj["id"].(string)
+		ref, err := c.GetXapiRef(id)
+		if err != nil {
+			t.Fatalf("failed to get the XAPI reference of `%s` with error: %v", id, err)
+		}
+		if ref != obj["_xapiRef"] {
+			t.Errorf("expected `%s` to have reference `%s` but received `%s`", id, obj["_xapiRef"], ref)
+		}
+
+		foundId, foundType, err := c.FindByXapiRef(ref)
+		if err != nil {
+			t.Fatalf("failed to find the object of reference `%s` with error: %v", ref, err)
+		}
+		if foundId != id || foundType != obj["type"] {
+			t.Errorf("expected reference `%s` to be %s `%s` but received %s `%s`", ref, obj["type"], id, foundType, foundId)
+		}
+	}
+}
+
+func TestGetXapiRef_objectsWithoutRef(t *testing.T) {
+	c := xapiRefClient()
+
+	for _, id := range []string{"cc-1", "unknown-1"} {
+		_, err := c.GetXapiRef(id)
+		var noRef NoXapiRefError
+		if !errors.As(err, &noRef) || noRef.ObjectId != id {
+			t.Errorf("expected a NoXapiRefError for `%s` but received: %v", id, err)
+		}
+	}
+
+	if _, err := c.GetXapiRef("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected a NotFound error for a missing object but received: %v", err)
+	}
+}
+
+func TestFindByXapiRef_notFound(t *testing.T) {
+	c := xapiRefClient()
+
+	_, _, err := c.FindByXapiRef("OpaqueRef:missing")
+	var notFound NotFound
+	if !errors.As(err, &notFound) || notFound.Query != (XapiObject{Ref: "OpaqueRef:missing"}) {
+		t.Errorf("expected a NotFound error for the reference but received: %v", err)
+	}
+}
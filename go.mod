@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/gorilla/websocket v1.4.2
 	github.com/sourcegraph/jsonrpc2 v0.0.0-20210201082850-366fbb520750
+	gopkg.in/yaml.v3 v3.0.1
 )